@@ -24,6 +24,7 @@
 #include "sampling.h"
 // include std::regex
 #include <cstddef>
+#include <cmath>
 #include <thread>
 #include <mutex>
 #include <chrono>
@@ -219,6 +220,14 @@ struct llama_client_slot
     size_t sent_count = 0;
     size_t sent_token_probs_index = 0;
 
+    // sum of the log-probabilities of the sampled tokens
+    double logprob = 0.0;
+
+    // temperature schedule: the temperatures used from given numbers of generated tokens,
+    // "linear" interpolates between the points, "step" keeps the temperature of the last point reached
+    std::string temperature_schedule;
+    std::vector<std::pair<int32_t, float>> temperature_points;
+
     int64_t t_start_process_prompt;
     int64_t t_start_genereration;
 
@@ -228,6 +237,24 @@ struct llama_client_slot
     // multitasks
     int multitask_id = -1;
 
+    // scheduled_temperature returns the temperature of the schedule for the next token
+    float scheduled_temperature() const {
+        float temp = temperature_points.front().second;
+        for (size_t i = 0; i < temperature_points.size(); i++) {
+            const auto & p = temperature_points[i];
+            if (n_decoded < p.first) {
+                if (temperature_schedule == "linear" && i > 0) {
+                    const auto & prev = temperature_points[i - 1];
+                    const float t = float(n_decoded - prev.first) / float(p.first - prev.first);
+                    temp = prev.second + t * (p.second - prev.second);
+                }
+                break;
+            }
+            temp = p.second;
+        }
+        return std::max(temp, 0.01f);
+    }
+
     void reset() {
         num_prompt_tokens      = 0;
         generated_text         = "";
@@ -239,6 +266,7 @@ struct llama_client_slot
         n_past                 = 0;
         sent_count             = 0;
         sent_token_probs_index = 0;
+        logprob                = 0.0;
         infill                 = false;
         ga_i                   = 0;
         n_past_se              = 0;
@@ -441,6 +469,8 @@ struct llama_server_context
     bool all_slots_are_idle = false;
     bool add_bos_token      = true;
     bool has_eos_token      = true;
+    // LOCALAI changes: process the new prompts before the ongoing sequences, to lower the time to first token
+    bool prompt_priority    = false;
 
     int32_t n_ctx;  // total context for all clients / slots
 
@@ -681,6 +711,23 @@ struct llama_server_context
         slot->sparams.n_probs           = json_value(data, "n_probs",           default_sparams.n_probs);
         slot->sparams.min_keep          = json_value(data, "min_keep",          default_sparams.min_keep);
 
+        slot->temperature_schedule = json_value(data, "temperature_schedule", std::string());
+        slot->temperature_points.clear();
+        if (!slot->temperature_schedule.empty())
+        {
+            const auto tokens = json_value(data, "temperature_schedule_tokens", std::vector<int32_t>());
+            const auto values = json_value(data, "temperature_schedule_values", std::vector<float>());
+            for (size_t i = 0; i < tokens.size() && i < values.size(); i++)
+            {
+                slot->temperature_points.push_back({tokens[i], values[i]});
+            }
+        }
+        if (!slot->temperature_points.empty())
+        {
+            // the logits are scaled by the scheduled temperature before sampling, the temperature sampler is neutral
+            slot->sparams.temp = 1.0f;
+        }
+
         if (slot->n_predict > 0 && slot->params.n_predict > slot->n_predict) {
             // Might be better to reject the request with a 400 ?
             LOG_WARNING("Max tokens to predict exceeds server configuration", {
@@ -1282,6 +1329,7 @@ struct llama_server_context
             {"stopped_limit",       slot.stopped_limit},
             {"stopping_word",       slot.stopping_word},
             {"tokens_cached",       slot.n_past},
+            {"logprob",             slot.logprob},
             {"timings",             slot.get_formated_timings()}
         };
 
@@ -1614,6 +1662,22 @@ struct llama_server_context
             }
         }
 
+        // LOCALAI changes: with prompt_priority, the new prompts are processed alone and the ongoing sequences
+        // wait for the next update, so that the first token of the new requests is sampled sooner. The empty prompts
+        // are released without being processed, and do not hold the ongoing sequences back
+        bool prompt_pending = false;
+        if (prompt_priority)
+        {
+            for (auto & slot : slots)
+            {
+                const bool has_prompt = slot.prompt.is_array() || (slot.prompt.is_string() && !slot.prompt.get<std::string>().empty()) || !slot.images.empty();
+                if (slot.state == IDLE && slot.command == LOAD_PROMPT && (has_prompt || slot.infill))
+                {
+                    prompt_pending = true;
+                }
+            }
+        }
+
         // decode any currently ongoing sequences
         LOG_VERBOSE("decoding ongoing sequences", {});
         for (auto & slot : slots)
@@ -1644,6 +1708,11 @@ struct llama_server_context
                 continue;
             }
 
+            if (prompt_pending)
+            {
+                continue;
+            }
+
             slot.i_batch = batch.n_tokens;
 
             const int32_t slot_npast = slot.n_past_se > 0 ? slot.n_past_se : slot.n_past;
@@ -1964,6 +2033,16 @@ struct llama_server_context
                 }
 
                 completion_token_output result;
+                if (!slot.temperature_points.empty())
+                {
+                    float * logits = llama_get_logits_ith(ctx, slot.i_batch - i);
+                    const float scale = 1.0f / slot.scheduled_temperature();
+                    const int n_vocab = llama_vocab_n_tokens(vocab);
+                    for (int k = 0; k < n_vocab; k++)
+                    {
+                        logits[k] *= scale;
+                    }
+                }
                 const llama_token id = common_sampler_sample(slot.ctx_sampling, ctx, slot.i_batch - i);
 
                 common_sampler_accept(slot.ctx_sampling, id, true);
@@ -1979,6 +2058,11 @@ struct llama_server_context
                 result.tok = id;
                 const auto * cur_p = common_sampler_get_candidates(slot.ctx_sampling);
 
+                if (cur_p->selected >= 0 && (size_t) cur_p->selected < cur_p->size && cur_p->data[cur_p->selected].p > 0.0f)
+                {
+                    slot.logprob += std::log(cur_p->data[cur_p->selected].p);
+                }
+
                 for (size_t i = 0; i < (size_t) slot.sparams.n_probs; ++i) {
                     result.probs.push_back({
                         cur_p->data[i].id,
@@ -2133,6 +2217,12 @@ json parse_options(bool streaming, const backend::PredictOptions* predict, llama
     data["ignore_eos"] = predict->ignoreeos();
     data["embeddings"] = predict->embeddings();
 
+    if (!predict->temperatureschedule().empty()) {
+        data["temperature_schedule"] = predict->temperatureschedule();
+        data["temperature_schedule_tokens"] = std::vector<int32_t>(predict->temperaturescheduletokens().begin(), predict->temperaturescheduletokens().end());
+        data["temperature_schedule_values"] = std::vector<float>(predict->temperatureschedulevalues().begin(), predict->temperatureschedulevalues().end());
+    }
+
     // Add the correlationid to json data
     data["correlation_id"] = predict->correlationid();
 
@@ -2368,6 +2458,8 @@ public:
     llama_backend_init();
     llama_numa_init(params.numa);
 
+    llama.prompt_priority = request->promptpriority();
+
     // load the model
     if (!llama.load_model(params))
     {
@@ -2420,8 +2512,11 @@ public:
                     { "id", data["correlation_id"] }
                 });
 
-                // Send the reply
-                writer->Write(reply);
+                // Send the reply, stopping the generation when the client is gone (e.g. it stopped reading)
+                if (!writer->Write(reply) || context->IsCancelled()) {
+                    llama.request_cancel(task_id);
+                    break;
+                }
 
                 if (result.stop) {
                     break;
@@ -2455,6 +2550,7 @@ public:
             reply->set_prompt_tokens(tokens_evaluated);
             reply->set_tokens(tokens_predicted);
             reply->set_message(completion_text);
+            reply->set_logprob(result.result_json.value("logprob", 0.0));
 
             if (result.result_json.contains("timings")) {
                 double timing_prompt_processing = result.result_json.at("timings").value("prompt_ms", 0.0);
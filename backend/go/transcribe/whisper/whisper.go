@@ -3,8 +3,10 @@ package main
 // This is a wrapper to statisfy the GRPC service interface
 // It is meant to be used by the main executable that is the server for the specific backend type (falcon, gpt3, etc)
 import (
+	"math"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
 	"github.com/go-audio/wav"
@@ -74,6 +76,10 @@ func (sd *Whisper) AudioTranscription(opts *pb.TranscriptRequest) (pb.Transcript
 		context.SetTranslate(true)
 	}
 
+	if opts.TokenTimestamps {
+		context.SetTokenTimestamps(true)
+	}
+
 	if err := context.Process(data, nil, nil); err != nil {
 		return pb.TranscriptResult{}, err
 	}
@@ -87,11 +93,26 @@ func (sd *Whisper) AudioTranscription(opts *pb.TranscriptRequest) (pb.Transcript
 		}
 
 		var tokens []int32
+		var tokenTimings []*pb.TranscriptToken
+		// the average log probability skips the special tokens, e.g. [_BEG_] or <|en|>
+		var logprobs float64
+		textTokens := 0
 		for _, t := range s.Tokens {
 			tokens = append(tokens, int32(t.Id))
+			if t.Text != "" && !strings.HasPrefix(t.Text, "[_") && !strings.HasPrefix(t.Text, "<|") {
+				logprobs += math.Log(math.Max(float64(t.P), math.SmallestNonzeroFloat32))
+				textTokens++
+			}
+			if opts.TokenTimestamps {
+				tokenTimings = append(tokenTimings, &pb.TranscriptToken{Id: int32(t.Id), Text: t.Text, Start: int64(t.Start), End: int64(t.End), Probability: t.P})
+			}
 		}
 
-		segment := &pb.TranscriptSegment{Id: int32(s.Num), Text: s.Text, Start: int64(s.Start), End: int64(s.End), Tokens: tokens}
+		segment := &pb.TranscriptSegment{Id: int32(s.Num), Text: s.Text, Start: int64(s.Start), End: int64(s.End), Tokens: tokens, TokenTimings: tokenTimings}
+		if textTokens > 0 {
+			avgLogprob := float32(logprobs / float64(textTokens))
+			segment.AvgLogprob = &avgLogprob
+		}
 		segments = append(segments, segment)
 
 		text += s.Text
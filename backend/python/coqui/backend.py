@@ -77,7 +77,11 @@ class BackendServicer(backend_pb2_grpc.BackendServicer):
             if self.tts.is_multi_speaker and self.AudioPath is None and request.voice is None:
                 return backend_pb2.Result(success=False, message=f"Model is multi-speaker, but no speaker was provided")
 
-            if self.tts.is_multi_speaker and request.voice is not None:
+            # the reference sample of a cloned voice takes precedence over the configured speaker. The voice
+            # is computed from the sample on every request: request.voice_id is not used to cache it
+            if request.voice_sample:
+                self.tts.tts_to_file(text=request.text, speaker_wav=request.voice_sample, language=lang, file_path=request.dst)
+            elif self.tts.is_multi_speaker and request.voice is not None:
                self.tts.tts_to_file(text=request.text, speaker=request.voice, language=lang, file_path=request.dst)
             else:
                 self.tts.tts_to_file(text=request.text, speaker_wav=self.AudioPath, language=lang, file_path=request.dst)
@@ -134,18 +134,26 @@ func readApiKeysJson(startupAppConfig config.ApplicationConfig) fileHandler {
 
 		if len(fileContent) > 0 {
 			// Parse JSON content from the file
-			var fileKeys []string
-			err := json.Unmarshal(fileContent, &fileKeys)
+			fileKeys, fileKeyModels, err := config.ParseApiKeys(fileContent)
 			if err != nil {
 				return err
 			}
 
-			log.Trace().Int("numKeys", len(fileKeys)).Msg("discovered API keys from api keys dynamic config dile")
+			log.Trace().Int("numKeys", len(fileKeys)).Int("numRestrictedKeys", len(fileKeyModels)).Msg("discovered API keys from api keys dynamic config dile")
 
 			appConfig.ApiKeys = append(startupAppConfig.ApiKeys, fileKeys...)
+			// the restrictions of the file take precedence over the ones of the command line
+			appConfig.ApiKeyModels = map[string][]string{}
+			for key, models := range startupAppConfig.ApiKeyModels {
+				appConfig.ApiKeyModels[key] = models
+			}
+			for key, models := range fileKeyModels {
+				appConfig.ApiKeyModels[key] = models
+			}
 		} else {
 			log.Trace().Msg("no API keys discovered from dynamic config file")
 			appConfig.ApiKeys = startupAppConfig.ApiKeys
+			appConfig.ApiKeyModels = startupAppConfig.ApiKeyModels
 		}
 		log.Trace().Int("numKeys", len(appConfig.ApiKeys)).Msg("total api keys after processing")
 		return nil
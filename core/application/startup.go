@@ -134,6 +134,9 @@ func New(opts ...config.AppOption) (*Application, error) {
 			options.WatchDogIdleTimeout,
 			options.WatchDogBusy,
 			options.WatchDogIdle)
+		if options.WatchDogMemoryThreshold > 0 {
+			wd.EnableMemoryCheck(options.WatchDogMemoryThreshold)
+		}
 		application.ModelLoader().SetWatchDog(wd)
 		go wd.Run()
 		go func() {
@@ -0,0 +1,100 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+const (
+	// MaxBranches caps the number of branches of a request
+	MaxBranches = 8
+	// MaxBranchesTokens caps the tokens generated by a request: the prefix and all the branches
+	MaxBranchesTokens = 8192
+
+	defaultBranches         = 2
+	defaultBranchMaxTokens  = 128
+	defaultBranchPrefixSize = 512
+)
+
+// ErrInvalidBranchesRequest is returned for the branches requests that cannot be served
+var ErrInvalidBranchesRequest = errors.New("invalid branches request")
+
+// BranchPredictFn generates a completion of the prompt with the given maximum number of tokens and seed
+type BranchPredictFn func(prompt string, maxTokens, seed int) (LLMResponse, error)
+
+// GenerateBranches generates the prefix of the completion up to the branch point, then the alternative
+// continuations from it, each one with a different seed. The prompt and the prefix are the same for
+// all the branches, so that backends caching the prompt (e.g. llama.cpp) reuse it
+func GenerateBranches(req schema.BranchesRequest, predict BranchPredictFn) (*schema.BranchesResponse, error) {
+	if (req.BranchAt == nil) == (req.BranchMarker == "") {
+		return nil, fmt.Errorf("%w: either branch_at or branch_marker is required", ErrInvalidBranchesRequest)
+	}
+
+	n := req.N
+	if n == 0 {
+		n = defaultBranches
+	}
+	if n < 1 || n > MaxBranches {
+		return nil, fmt.Errorf("%w: n must be between 1 and %d", ErrInvalidBranchesRequest, MaxBranches)
+	}
+
+	prefixTokens := req.MaxTokens
+	if req.BranchAt != nil {
+		prefixTokens = *req.BranchAt
+	} else if prefixTokens == 0 {
+		prefixTokens = defaultBranchPrefixSize
+	}
+	branchTokens := req.BranchMaxTokens
+	if branchTokens == 0 {
+		branchTokens = defaultBranchMaxTokens
+	}
+	if prefixTokens < 0 || branchTokens < 0 {
+		return nil, fmt.Errorf("%w: the number of tokens cannot be negative", ErrInvalidBranchesRequest)
+	}
+	if prefixTokens+n*branchTokens > MaxBranchesTokens {
+		return nil, fmt.Errorf("%w: the request can generate up to %d tokens", ErrInvalidBranchesRequest, MaxBranchesTokens)
+	}
+
+	resp := &schema.BranchesResponse{}
+	seed := rand.Intn(1 << 30)
+
+	if prefixTokens > 0 {
+		prefix, err := predict(req.Prompt, prefixTokens, seed)
+		if err != nil {
+			return nil, err
+		}
+		resp.Prefix = prefix.Response
+		resp.Usage.PromptTokens += prefix.Usage.Prompt
+		resp.Usage.CompletionTokens += prefix.Usage.Completion
+
+		if req.BranchMarker != "" {
+			i := strings.Index(resp.Prefix, req.BranchMarker)
+			if i < 0 {
+				return nil, fmt.Errorf("%w: the branch marker was not generated in %d tokens", ErrInvalidBranchesRequest, prefixTokens)
+			}
+			resp.Prefix = resp.Prefix[:i+len(req.BranchMarker)]
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		branch, err := predict(req.Prompt+resp.Prefix, branchTokens, seed+i+1)
+		if err != nil {
+			return nil, err
+		}
+		b := schema.Branch{Index: i, Text: branch.Response, Tokens: branch.Usage.Completion}
+		if branch.Logprob != 0 {
+			logprob := branch.Logprob
+			b.Logprob = &logprob
+		}
+		resp.Branches = append(resp.Branches, b)
+		resp.Usage.PromptTokens += branch.Usage.Prompt
+		resp.Usage.CompletionTokens += branch.Usage.Completion
+	}
+	resp.Usage.TotalTokens = resp.Usage.PromptTokens + resp.Usage.CompletionTokens
+
+	return resp, nil
+}
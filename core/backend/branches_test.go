@@ -0,0 +1,84 @@
+package backend_test
+
+import (
+	"fmt"
+
+	. "github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/schema"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GenerateBranches", func() {
+	type call struct {
+		prompt          string
+		maxTokens, seed int
+	}
+	var calls []call
+
+	// the model answers with the seed, and a logprob for the branches
+	predict := func(prompt string, maxTokens, seed int) (LLMResponse, error) {
+		calls = append(calls, call{prompt, maxTokens, seed})
+		return LLMResponse{
+			Response: fmt.Sprintf(" once upon a time. [%d]", len(calls)),
+			Usage:    TokenUsage{Prompt: 3, Completion: 5},
+			Logprob:  -1.5,
+		}, nil
+	}
+
+	BeforeEach(func() {
+		calls = nil
+	})
+
+	It("branches after a number of tokens", func() {
+		at := 10
+		resp, err := GenerateBranches(schema.BranchesRequest{Prompt: "Story:", BranchAt: &at, N: 3, BranchMaxTokens: 20}, predict)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.Prefix).To(Equal(" once upon a time. [1]"))
+		Expect(resp.Branches).To(HaveLen(3))
+		Expect(resp.Branches[2].Text).To(Equal(" once upon a time. [4]"))
+		Expect(*resp.Branches[0].Logprob).To(Equal(-1.5))
+		Expect(resp.Usage.TotalTokens).To(Equal(32))
+
+		Expect(calls[0].maxTokens).To(Equal(10))
+		// the branches continue the prefix with different seeds
+		seeds := map[int]bool{}
+		for _, c := range calls[1:] {
+			Expect(c.prompt).To(Equal("Story: once upon a time. [1]"))
+			Expect(c.maxTokens).To(Equal(20))
+			seeds[c.seed] = true
+		}
+		Expect(seeds).To(HaveLen(3))
+	})
+
+	It("branches from the prompt", func() {
+		at := 0
+		resp, err := GenerateBranches(schema.BranchesRequest{Prompt: "Story:", BranchAt: &at}, predict)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.Prefix).To(BeEmpty())
+		Expect(resp.Branches).To(HaveLen(2))
+		Expect(calls[0].prompt).To(Equal("Story:"))
+	})
+
+	It("branches after a marker", func() {
+		resp, err := GenerateBranches(schema.BranchesRequest{Prompt: "Story:", BranchMarker: "."}, predict)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.Prefix).To(Equal(" once upon a time."))
+		Expect(calls[1].prompt).To(Equal("Story: once upon a time."))
+
+		_, err = GenerateBranches(schema.BranchesRequest{Prompt: "Story:", BranchMarker: "\n\n"}, predict)
+		Expect(err).To(MatchError(ErrInvalidBranchesRequest))
+	})
+
+	It("caps the branches and the tokens", func() {
+		at := 1
+		_, err := GenerateBranches(schema.BranchesRequest{BranchAt: &at, N: MaxBranches + 1}, predict)
+		Expect(err).To(MatchError(ErrInvalidBranchesRequest))
+		_, err = GenerateBranches(schema.BranchesRequest{BranchAt: &at, N: 8, BranchMaxTokens: 2000}, predict)
+		Expect(err).To(MatchError(ErrInvalidBranchesRequest))
+		_, err = GenerateBranches(schema.BranchesRequest{}, predict)
+		Expect(err).To(MatchError(ErrInvalidBranchesRequest))
+		Expect(calls).To(BeEmpty())
+	})
+})
@@ -0,0 +1,48 @@
+package backend
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+type cacheKeyMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type cacheKeyInput struct {
+	Model    string            `json:"model"`
+	Backend  string            `json:"backend"`
+	Prompt   string            `json:"prompt"`
+	Messages []cacheKeyMessage `json:"messages"`
+	Grammar  string            `json:"grammar"`
+	Stop     []string          `json:"stop"`
+
+	Parameters schema.PredictionOptions `json:"parameters"`
+}
+
+// RequestCacheKey returns the canonical cache key of an inference request.
+// The key is the hex encoded SHA-256 of the JSON encoding of the model name, backend,
+// templated prompt, messages (role and text content), grammar, stop words and the
+// sampling parameters after the request has been merged with the model configuration.
+func RequestCacheKey(c config.BackendConfig, prompt string, messages []schema.Message) string {
+	in := cacheKeyInput{
+		Model:      c.Name,
+		Backend:    c.Backend,
+		Prompt:     prompt,
+		Grammar:    c.Grammar,
+		Stop:       c.StopWords,
+		Parameters: c.PredictionOptions,
+	}
+	for _, m := range messages {
+		in.Messages = append(in.Messages, cacheKeyMessage{Role: m.Role, Content: m.StringContent})
+	}
+
+	dat, _ := json.Marshal(in)
+	sum := sha256.Sum256(dat)
+	return hex.EncodeToString(sum[:])
+}
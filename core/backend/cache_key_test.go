@@ -0,0 +1,39 @@
+package backend_test
+
+import (
+	. "github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Request cache key", func() {
+	var cfg config.BackendConfig
+
+	BeforeEach(func() {
+		temperature := 0.2
+		cfg = config.BackendConfig{
+			Name: "test",
+			PredictionOptions: schema.PredictionOptions{
+				Temperature: &temperature,
+			},
+		}
+	})
+
+	It("is stable for the same request", func() {
+		messages := []schema.Message{{Role: "user", StringContent: "hello"}}
+		Expect(RequestCacheKey(cfg, "prompt", messages)).To(Equal(RequestCacheKey(cfg, "prompt", messages)))
+		Expect(RequestCacheKey(cfg, "prompt", messages)).To(HaveLen(64))
+	})
+
+	It("changes with the inputs", func() {
+		key := RequestCacheKey(cfg, "prompt", nil)
+		Expect(RequestCacheKey(cfg, "other prompt", nil)).ToNot(Equal(key))
+
+		temperature := 0.9
+		cfg.Temperature = &temperature
+		Expect(RequestCacheKey(cfg, "prompt", nil)).ToNot(Equal(key))
+	})
+})
@@ -0,0 +1,120 @@
+package backend
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+var (
+	// [1], [doc-1] or [1, 2], with the whitespace before the marker
+	citationBracketsRegex = regexp.MustCompile(`\s*\[([\w.:#/-]+(?:\s*,\s*[\w.:#/-]+)*)\]`)
+	citationTagRegex      = regexp.MustCompile(`(?s)<cite(?:\s+(?:source|id)\s*=\s*"([^"]*)")?\s*>(.*?)</cite>`)
+	// unbalanced opening or closing tags
+	citationStrayTagRegex = regexp.MustCompile(`</?cite\b[^>]*>`)
+)
+
+// ParseCitations strips the citation markup from the output of the model, returning the content
+// and the citations, with the offsets of the cited text in the content
+func ParseCitations(text string, c config.Citations) (string, []schema.Citation) {
+	var sourceRegex *regexp.Regexp
+	if c.SourcePattern != "" {
+		// the pattern is validated with the configuration
+		sourceRegex, _ = regexp.Compile(c.SourcePattern)
+	}
+	validSource := func(id string) bool {
+		return id != "" && (sourceRegex == nil || sourceRegex.MatchString(id))
+	}
+
+	if c.CitationFormat() == config.CitationFormatTags {
+		return parseCitationTags(text, validSource)
+	}
+	return parseCitationBrackets(text, validSource)
+}
+
+func parseCitationBrackets(text string, validSource func(string) bool) (string, []schema.Citation) {
+	citations := []schema.Citation{}
+	out := &strings.Builder{}
+	last := 0
+	for _, m := range citationBracketsRegex.FindAllStringSubmatchIndex(text, -1) {
+		// markdown links
+		if m[1] < len(text) && text[m[1]] == '(' {
+			continue
+		}
+		ids := strings.Split(text[m[2]:m[3]], ",")
+		valid := true
+		for i := range ids {
+			ids[i] = strings.TrimSpace(ids[i])
+			valid = valid && validSource(ids[i])
+		}
+		if !valid {
+			continue
+		}
+
+		out.WriteString(text[last:m[0]])
+		last = m[1]
+		content := out.String()
+		start := sentenceStart(content)
+		for _, id := range ids {
+			citations = append(citations, schema.Citation{
+				SourceID: id,
+				Start:    utf8.RuneCountInString(content[:start]),
+				End:      utf8.RuneCountInString(content),
+				Text:     content[start:],
+			})
+		}
+	}
+	out.WriteString(text[last:])
+	return out.String(), citations
+}
+
+// sentenceStart returns the start of the last sentence of the text
+func sentenceStart(text string) int {
+	end := len(text)
+	// the sentence terminator before the marker
+	if end > 0 && strings.ContainsRune(".!?", rune(text[end-1])) {
+		end--
+	}
+	start := 0
+	for i := end - 1; i >= 0; i-- {
+		if text[i] == '\n' || (strings.ContainsRune(".!?", rune(text[i])) && i+1 < end && text[i+1] == ' ') {
+			start = i + 1
+			break
+		}
+	}
+	for start < len(text) && (text[start] == ' ' || text[start] == '\n') {
+		start++
+	}
+	return start
+}
+
+func parseCitationTags(text string, validSource func(string) bool) (string, []schema.Citation) {
+	citations := []schema.Citation{}
+	out := &strings.Builder{}
+	last := 0
+	for _, m := range citationTagRegex.FindAllStringSubmatchIndex(text, -1) {
+		out.WriteString(citationStrayTagRegex.ReplaceAllString(text[last:m[0]], ""))
+		last = m[1]
+
+		cited := citationStrayTagRegex.ReplaceAllString(text[m[4]:m[5]], "")
+		start := utf8.RuneCountInString(out.String())
+		out.WriteString(cited)
+		id := ""
+		if m[2] >= 0 {
+			id = strings.TrimSpace(text[m[2]:m[3]])
+		}
+		if validSource(id) {
+			citations = append(citations, schema.Citation{
+				SourceID: id,
+				Start:    start,
+				End:      start + utf8.RuneCountInString(cited),
+				Text:     cited,
+			})
+		}
+	}
+	out.WriteString(citationStrayTagRegex.ReplaceAllString(text[last:], ""))
+	return out.String(), citations
+}
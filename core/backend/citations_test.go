@@ -0,0 +1,52 @@
+package backend_test
+
+import (
+	. "github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Citations", func() {
+	It("parses the bracket markers", func() {
+		content, citations := ParseCitations("Rome is the capital of Italy [1]. It has 2.8 million inhabitants [2, doc-3][4].\nSee [the map](https://example.com).", config.Citations{})
+		Expect(content).To(Equal("Rome is the capital of Italy. It has 2.8 million inhabitants.\nSee [the map](https://example.com)."))
+		Expect(citations).To(Equal([]schema.Citation{
+			{SourceID: "1", Start: 0, End: 28, Text: "Rome is the capital of Italy"},
+			{SourceID: "2", Start: 30, End: 60, Text: "It has 2.8 million inhabitants"},
+			{SourceID: "doc-3", Start: 30, End: 60, Text: "It has 2.8 million inhabitants"},
+			{SourceID: "4", Start: 30, End: 60, Text: "It has 2.8 million inhabitants"},
+		}))
+	})
+
+	It("cites the sentence before the marker", func() {
+		content, citations := ParseCitations("Così è. L'Italia è una penisola. [7] Fine.", config.Citations{})
+		Expect(content).To(Equal("Così è. L'Italia è una penisola. Fine."))
+		Expect(citations).To(HaveLen(1))
+		Expect(citations[0].Text).To(Equal("L'Italia è una penisola."))
+		Expect([]rune(content)[citations[0].Start:citations[0].End]).To(Equal([]rune(citations[0].Text)))
+	})
+
+	It("leaves the markers with invalid sources", func() {
+		content, citations := ParseCitations("- [x] done [12]", config.Citations{SourcePattern: "^[0-9]+$"})
+		Expect(content).To(Equal("- [x] done"))
+		Expect(citations).To(HaveLen(1))
+		Expect(citations[0].Text).To(Equal("- [x] done"))
+	})
+
+	It("parses the cite tags", func() {
+		content, citations := ParseCitations(`Rome is <cite source="doc-1">the capital of Italy</cite>. It is <cite>old</cite>, <cite source="doc-2">very old</cite>`, config.Citations{Format: config.CitationFormatTags})
+		Expect(content).To(Equal("Rome is the capital of Italy. It is old, very old"))
+		Expect(citations).To(Equal([]schema.Citation{
+			{SourceID: "doc-1", Start: 8, End: 28, Text: "the capital of Italy"},
+			{SourceID: "doc-2", Start: 41, End: 49, Text: "very old"},
+		}))
+
+		// unbalanced tags are stripped
+		content, citations = ParseCitations(`It is <cite source="doc-1">old`, config.Citations{Format: config.CitationFormatTags})
+		Expect(content).To(Equal("It is old"))
+		Expect(citations).To(BeEmpty())
+	})
+})
@@ -0,0 +1,86 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// ContextOverflowError returns the context length errors to the client (default)
+	ContextOverflowError = "error"
+	// ContextOverflowTruncate drops the oldest messages of the conversation and retries the request
+	ContextOverflowTruncate = "truncate"
+)
+
+// ContextLengthError is returned when the backend rejects a request exceeding the context size of the model
+type ContextLengthError struct {
+	// Tokens is the size of the request and Limit the context size, 0 if unknown
+	Tokens, Limit int
+	Err           error
+}
+
+func (e *ContextLengthError) Error() string {
+	switch {
+	case e.Tokens > 0 && e.Limit > 0:
+		return fmt.Sprintf("the maximum context length of the model is %d tokens, however the request has %d tokens", e.Limit, e.Tokens)
+	case e.Limit > 0:
+		return fmt.Sprintf("the request exceeds the maximum context length of the model (%d tokens)", e.Limit)
+	default:
+		return "the request exceeds the maximum context length of the model"
+	}
+}
+
+func (e *ContextLengthError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	// "context" alone would match the deadlines and cancellations ("context deadline exceeded")
+	contextLengthRegex = regexp.MustCompile(`(?i)context_length_exceeded|maximum context length is|(prompt|input|request) is too (long|large)|` +
+		`(context (size|length|window)|n_ctx).{0,40}(exceed|too (long|large|small)|larger|greater)|` +
+		`(exceed|too many tokens|larger than|greater than|longer than).{0,40}(context (size|length|window)|n_ctx)`)
+
+	// the messages of the backends carrying both the request size and the context size, e.g.
+	// vLLM: "This model's maximum context length is 4096 tokens. However, you requested 5000 tokens"
+	// llama.cpp: "prompt is too long (5000 tokens, max 4096)"
+	limitThenTokensRegex = regexp.MustCompile(`(?i)(?:maximum context length|context size|context length|n_ctx)\D{0,20}?(\d+)\D{0,60}?(\d+) tokens`)
+	tokensThenLimitRegex = regexp.MustCompile(`(?i)(\d+) tokens?\D{0,40}?(?:max(?:imum)?|limit|n_ctx|context (?:size|length|window))\D{0,20}?(\d+)`)
+	tokensRegex          = regexp.MustCompile(`(?i)(\d+) tokens`)
+)
+
+// ParseContextLengthError returns a ContextLengthError if the backend error is due to the request exceeding
+// the context size of the model, otherwise the error as is. contextSize is used when the error does not carry it
+func ParseContextLengthError(err error, contextSize int) error {
+	if err == nil || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return err
+	}
+	if code := status.Code(err); code == codes.DeadlineExceeded || code == codes.Canceled {
+		return err
+	}
+	if !contextLengthRegex.MatchString(err.Error()) {
+		return err
+	}
+	var cle *ContextLengthError
+	if errors.As(err, &cle) {
+		return err
+	}
+
+	cle = &ContextLengthError{Limit: contextSize, Err: err}
+	msg := err.Error()
+	if m := limitThenTokensRegex.FindStringSubmatch(msg); m != nil {
+		cle.Limit, _ = strconv.Atoi(m[1])
+		cle.Tokens, _ = strconv.Atoi(m[2])
+	} else if m := tokensThenLimitRegex.FindStringSubmatch(msg); m != nil {
+		cle.Tokens, _ = strconv.Atoi(m[1])
+		cle.Limit, _ = strconv.Atoi(m[2])
+	} else if m := tokensRegex.FindStringSubmatch(msg); m != nil {
+		cle.Tokens, _ = strconv.Atoi(m[1])
+	}
+	return cle
+}
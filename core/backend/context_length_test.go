@@ -0,0 +1,50 @@
+package backend_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	. "github.com/mudler/LocalAI/core/backend"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Describe("ParseContextLengthError", func() {
+	DescribeTable("detects the context length errors of the backends",
+		func(msg string, tokens, limit int) {
+			err := ParseContextLengthError(errors.New(msg), 2048)
+			var cle *ContextLengthError
+			Expect(errors.As(err, &cle)).To(BeTrue())
+			Expect(cle.Tokens).To(Equal(tokens))
+			Expect(cle.Limit).To(Equal(limit))
+			Expect(errors.Unwrap(err).Error()).To(Equal(msg))
+		},
+		Entry("vLLM", "rpc error: code = Unknown desc = This model's maximum context length is 4096 tokens. However, you requested 5000 tokens (4000 in the messages, 1000 in the completion).", 5000, 4096),
+		Entry("llama.cpp", "rpc error: code = Unknown desc = prompt is too long (5000 tokens, max 4096)", 5000, 4096),
+		Entry("without the sizes", "the request exceeds the available context size, try increasing it", 0, 2048),
+		Entry("with the request size only", "input is too large to process: 3000 tokens", 3000, 2048),
+	)
+
+	It("leaves the other errors as they are", func() {
+		err := errors.New("rpc error: code = Unavailable desc = connection refused")
+		Expect(ParseContextLengthError(err, 2048)).To(BeIdenticalTo(err))
+		Expect(ParseContextLengthError(nil, 2048)).To(BeNil())
+
+		// the deadlines and cancellations are not context length errors, whatever their message
+		err = errors.New("rpc error: code = DeadlineExceeded desc = context deadline exceeded")
+		Expect(ParseContextLengthError(err, 2048)).To(BeIdenticalTo(err))
+		err = status.Error(codes.DeadlineExceeded, "the request exceeds the context size")
+		Expect(ParseContextLengthError(err, 2048)).To(BeIdenticalTo(err))
+		err = fmt.Errorf("prompt is too long: %w", context.Canceled)
+		Expect(ParseContextLengthError(err, 2048)).To(BeIdenticalTo(err))
+	})
+
+	It("describes the error", func() {
+		Expect((&ContextLengthError{Tokens: 5000, Limit: 4096}).Error()).To(Equal("the maximum context length of the model is 4096 tokens, however the request has 5000 tokens"))
+		Expect((&ContextLengthError{Limit: 4096}).Error()).To(Equal("the request exceeds the maximum context length of the model (4096 tokens)"))
+	})
+})
@@ -0,0 +1,62 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	model "github.com/mudler/LocalAI/pkg/model"
+)
+
+const (
+	defaultConversationSummaryPrompt = "Summarize the following conversation between a user and an assistant. Keep the facts, names, decisions and open questions needed to continue it. Answer with the summary only.\n\n"
+
+	// ConversationSummaryPrefix introduces the summary in the message replacing the summarized ones
+	ConversationSummaryPrefix = "Summary of the earlier conversation:\n"
+)
+
+// FormatConversation renders the messages as a transcript, one "role: content" line per message
+func FormatConversation(messages []schema.Message) string {
+	lines := []string{}
+	for _, m := range messages {
+		text := strings.TrimSpace(m.StringContent)
+		for _, call := range m.ToolCalls {
+			text = strings.TrimSpace(fmt.Sprintf("%s\n(called %s with %s)", text, call.FunctionCall.Name, call.FunctionCall.Arguments))
+		}
+		if text == "" {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", m.Role, text))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SummarizeConversation asks the summarizer model to summarize the messages. The summary of the messages
+// preceding them, if any, is given to the model to be extended
+func SummarizeConversation(ctx context.Context, previous string, messages []schema.Message, c config.ConversationSummary, summaryConfig config.BackendConfig, loader *model.ModelLoader, appConfig *config.ApplicationConfig) (string, error) {
+	prompt := c.Prompt
+	if prompt == "" {
+		prompt = defaultConversationSummaryPrompt
+	}
+	if previous != "" {
+		prompt += ConversationSummaryPrefix + previous + "\n\nContinuation of the conversation:\n"
+	}
+	prompt += FormatConversation(messages)
+
+	fn, err := ModelInference(ctx, prompt, nil, nil, nil, nil, loader, summaryConfig, appConfig, nil)
+	if err != nil {
+		return "", err
+	}
+	res, err := fn()
+	if err != nil {
+		return "", err
+	}
+	summary := strings.TrimSpace(res.Response)
+	if summary == "" {
+		return "", errors.New("the summarizer model returned an empty summary")
+	}
+	return summary, nil
+}
@@ -0,0 +1,20 @@
+package backend_test
+
+import (
+	. "github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/schema"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Conversation summary", func() {
+	It("renders the messages as a transcript", func() {
+		Expect(FormatConversation([]schema.Message{
+			{Role: "user", StringContent: " What's the weather in Rome? "},
+			{Role: "assistant", ToolCalls: []schema.ToolCall{{FunctionCall: schema.FunctionCall{Name: "weather", Arguments: `{"city":"Rome"}`}}}},
+			{Role: "tool", StringContent: "sunny"},
+			{Role: "assistant"},
+		})).To(Equal("user: What's the weather in Rome?\nassistant: (called weather with {\"city\":\"Rome\"})\ntool: sunny"))
+	})
+})
@@ -0,0 +1,63 @@
+package backend
+
+import (
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/rs/zerolog/log"
+)
+
+// InferenceFunc starts an inference with the configuration of the model, as ModelInference
+type InferenceFunc func(c config.BackendConfig, tokenCallback func(string, TokenUsage) bool) (func() (LLMResponse, error), error)
+
+// WithCPUFallback runs the inference on the copy of the model on the CPU when loading the model or running the
+// inference fails because the GPU is out of memory. Once fallen back, the next predictions of the request stay on
+// the CPU. The predictions which already streamed tokens are not retried: the tokens would be sent twice
+func WithCPUFallback(c config.BackendConfig, outOfMemory func(modelID string, err error) bool, tokenCallback func(string, TokenUsage) bool, infer InferenceFunc) (func() (LLMResponse, error), error) {
+	cpu := c.CPUConfig()
+	var cpuFn func() (LLMResponse, error)
+	fallback := func(err error) error {
+		log.Warn().Err(err).Str("model", c.Name).Str("backend", cpu.Backend).Msg("the GPU is out of memory, running the request on the CPU")
+		fn, err := infer(cpu, tokenCallback)
+		if err != nil {
+			return err
+		}
+		cpuFn = func() (LLMResponse, error) {
+			r, err := fn()
+			r.Usage.CPUFallback = true
+			return r, err
+		}
+		return nil
+	}
+
+	streamed := false
+	callback := tokenCallback
+	if tokenCallback != nil {
+		callback = func(token string, usage TokenUsage) bool {
+			streamed = true
+			return tokenCallback(token, usage)
+		}
+	}
+	gpuFn, err := infer(c, callback)
+	if err != nil {
+		if !outOfMemory(ModelID(c), err) {
+			return nil, err
+		}
+		if err := fallback(err); err != nil {
+			return nil, err
+		}
+	}
+
+	return func() (LLMResponse, error) {
+		if cpuFn != nil {
+			return cpuFn()
+		}
+		streamed = false
+		r, err := gpuFn()
+		if err == nil || streamed || !outOfMemory(ModelID(c), err) {
+			return r, err
+		}
+		if err := fallback(err); err != nil {
+			return LLMResponse{}, err
+		}
+		return cpuFn()
+	}, nil
+}
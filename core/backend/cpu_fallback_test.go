@@ -0,0 +1,96 @@
+package backend_test
+
+import (
+	"errors"
+
+	. "github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/model"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CPU fallback", func() {
+	var (
+		cfg      config.BackendConfig
+		loads    []string
+		gpuErr   error
+		loadErr  error
+		streamed bool
+	)
+
+	outOfMemory := func(modelID string, err error) bool {
+		return errors.Is(err, model.ErrOutOfMemory)
+	}
+	infer := func(c config.BackendConfig, tokenCallback func(string, TokenUsage) bool) (func() (LLMResponse, error), error) {
+		loads = append(loads, ModelID(c))
+		if !c.OnCPU {
+			if loadErr != nil {
+				return nil, loadErr
+			}
+			return func() (LLMResponse, error) {
+				if streamed && tokenCallback != nil {
+					tokenCallback("Hello", TokenUsage{})
+				}
+				return LLMResponse{}, gpuErr
+			}, nil
+		}
+		return func() (LLMResponse, error) {
+			return LLMResponse{Response: "on the CPU"}, nil
+		}, nil
+	}
+
+	BeforeEach(func() {
+		gpuLayers := 99
+		cfg = config.BackendConfig{Name: "model", Backend: "llama-cpp", LLMConfig: config.LLMConfig{NGPULayers: &gpuLayers}}
+		cfg.CPUFallback.Enabled = true
+		loads, gpuErr, loadErr, streamed = nil, nil, nil, false
+	})
+
+	It("loads the model on the CPU when it does not fit on the GPU", func() {
+		loadErr = model.ErrOutOfMemory
+		fn, err := WithCPUFallback(cfg, outOfMemory, nil, infer)
+		Expect(err).ToNot(HaveOccurred())
+		r, err := fn()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(r.Response).To(Equal("on the CPU"))
+		Expect(r.Usage.CPUFallback).To(BeTrue())
+		Expect(loads).To(Equal([]string{"model", "model@cpu"}))
+	})
+
+	It("runs the inference again on the CPU when the GPU runs out of memory", func() {
+		gpuErr = model.ErrOutOfMemory
+		fn, err := WithCPUFallback(cfg, outOfMemory, nil, infer)
+		Expect(err).ToNot(HaveOccurred())
+		r, err := fn()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(r.Usage.CPUFallback).To(BeTrue())
+		// the next predictions of the request stay on the CPU
+		_, err = fn()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(loads).To(Equal([]string{"model", "model@cpu"}))
+	})
+
+	It("does not fall back on the other errors", func() {
+		loadErr = errors.New("model not found")
+		_, err := WithCPUFallback(cfg, outOfMemory, nil, infer)
+		Expect(err).To(MatchError("model not found"))
+
+		loadErr, gpuErr = nil, errors.New("invalid grammar")
+		fn, err := WithCPUFallback(cfg, outOfMemory, nil, infer)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = fn()
+		Expect(err).To(MatchError("invalid grammar"))
+		Expect(loads).To(Equal([]string{"model", "model"}))
+	})
+
+	It("does not run again the predictions which already streamed tokens", func() {
+		gpuErr, streamed = model.ErrOutOfMemory, true
+		fn, err := WithCPUFallback(cfg, outOfMemory, func(string, TokenUsage) bool { return true }, infer)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = fn()
+		Expect(err).To(MatchError(model.ErrOutOfMemory))
+		Expect(loads).To(Equal([]string{"model"}))
+	})
+})
@@ -1,7 +1,9 @@
 package backend
 
 import (
+	"context"
 	"fmt"
+	"math"
 
 	"github.com/mudler/LocalAI/core/config"
 
@@ -9,7 +11,7 @@ import (
 	model "github.com/mudler/LocalAI/pkg/model"
 )
 
-func ModelEmbedding(s string, tokens []int, loader *model.ModelLoader, backendConfig config.BackendConfig, appConfig *config.ApplicationConfig) (func() ([]float32, error), error) {
+func ModelEmbedding(ctx context.Context, s string, tokens []int, loader *model.ModelLoader, backendConfig config.BackendConfig, appConfig *config.ApplicationConfig) (func() ([]float32, error), error) {
 
 	opts := ModelOptions(backendConfig, appConfig)
 
@@ -31,7 +33,7 @@ func ModelEmbedding(s string, tokens []int, loader *model.ModelLoader, backendCo
 				}
 				predictOptions.EmbeddingTokens = embeds
 
-				res, err := model.Embeddings(appConfig.Context, predictOptions)
+				res, err := model.Embeddings(ctx, predictOptions)
 				if err != nil {
 					return nil, err
 				}
@@ -40,7 +42,7 @@ func ModelEmbedding(s string, tokens []int, loader *model.ModelLoader, backendCo
 			}
 			predictOptions.Embeddings = s
 
-			res, err := model.Embeddings(appConfig.Context, predictOptions)
+			res, err := model.Embeddings(ctx, predictOptions)
 			if err != nil {
 				return nil, err
 			}
@@ -69,3 +71,31 @@ func ModelEmbedding(s string, tokens []int, loader *model.ModelLoader, backendCo
 		return embeds, nil
 	}, nil
 }
+
+// TruncateEmbedding reduces the embedding to its first dimensions, as supported by the models trained with
+// Matryoshka representation learning, optionally re-normalizing it to unit length.
+// dimensions must not exceed the size of the embedding
+func TruncateEmbedding(embedding []float32, dimensions int, normalize bool) ([]float32, error) {
+	if dimensions <= 0 || dimensions > len(embedding) {
+		return nil, fmt.Errorf("dimensions must be between 1 and %d", len(embedding))
+	}
+
+	truncated := make([]float32, dimensions)
+	copy(truncated, embedding)
+	if !normalize {
+		return truncated, nil
+	}
+
+	norm := 0.0
+	for _, v := range truncated {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return truncated, nil
+	}
+	norm = math.Sqrt(norm)
+	for i, v := range truncated {
+		truncated[i] = float32(float64(v) / norm)
+	}
+	return truncated, nil
+}
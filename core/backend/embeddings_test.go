@@ -0,0 +1,45 @@
+package backend_test
+
+import (
+	. "github.com/mudler/LocalAI/core/backend"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TruncateEmbedding", func() {
+	embedding := []float32{0.6, 0.8, 0.5, 0.5}
+
+	It("truncates the embedding", func() {
+		truncated, err := TruncateEmbedding(embedding, 2, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(truncated).To(Equal([]float32{0.6, 0.8}))
+		Expect(embedding).To(HaveLen(4))
+	})
+
+	It("re-normalizes the truncated embedding", func() {
+		truncated, err := TruncateEmbedding(embedding, 3, true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(truncated).To(HaveLen(3))
+
+		norm := float32(0)
+		for _, v := range truncated {
+			norm += v * v
+		}
+		Expect(norm).To(BeNumerically("~", 1, 1e-6))
+		Expect(truncated[1] / truncated[0]).To(BeNumerically("~", 0.8/0.6, 1e-6))
+	})
+
+	It("keeps the full size", func() {
+		truncated, err := TruncateEmbedding(embedding, 4, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(truncated).To(Equal(embedding))
+	})
+
+	It("rejects dimensions out of the native size", func() {
+		_, err := TruncateEmbedding(embedding, 5, true)
+		Expect(err).To(MatchError("dimensions must be between 1 and 4"))
+		_, err = TruncateEmbedding(embedding, -1, true)
+		Expect(err).To(HaveOccurred())
+	})
+})
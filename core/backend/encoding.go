@@ -0,0 +1,156 @@
+package backend
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mudler/LocalAI/core/config"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// EncodingFixer fixes the encoding of the output of a model as it is streamed: the invalid UTF-8 sequences
+// are replaced or stripped, and the UTF-8 text decoded as Windows-1252 (mojibake) is decoded again
+type EncodingFixer struct {
+	cfg config.OutputEncoding
+	// pending holds the bytes of an incomplete character, held holds the text which might be mojibake continuing in the next chunk
+	pending []byte
+	held    string
+	// invalid is set while decoding a run of invalid bytes, which are fixed at once
+	invalid bool
+	fixes   int
+}
+
+func NewEncodingFixer(cfg config.OutputEncoding) *EncodingFixer {
+	return &EncodingFixer{cfg: cfg}
+}
+
+// Feed consumes a chunk of the output and returns the fixed text which can be emitted
+func (f *EncodingFixer) Feed(chunk []byte) string {
+	f.pending = append(f.pending, chunk...)
+	text, rest := f.decode(f.pending, false)
+	f.pending = append([]byte(nil), rest...)
+	text = f.held + text
+	f.held = ""
+	if f.cfg.Mojibake {
+		// the last characters might be the beginning of mojibake completed by the next chunk
+		text, f.held = cutMojibakeTail(text)
+		text = f.fixMojibake(text)
+	}
+	return text
+}
+
+// Flush returns the text held back by Feed, the incomplete characters at the end of the output being invalid
+func (f *EncodingFixer) Flush() string {
+	text, _ := f.decode(f.pending, true)
+	f.pending = nil
+	text = f.held + text
+	f.held = ""
+	if f.cfg.Mojibake {
+		text = f.fixMojibake(text)
+	}
+	return text
+}
+
+// Fixes returns the number of invalid sequences and mojibake fixed
+func (f *EncodingFixer) Fixes() int {
+	return f.fixes
+}
+
+// decode returns the text of the bytes with the invalid sequences fixed, and the bytes of the incomplete character
+// at the end, unless final is set
+func (f *EncodingFixer) decode(b []byte, final bool) (string, []byte) {
+	var sb strings.Builder
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		if r != utf8.RuneError || size > 1 {
+			sb.Write(b[:size])
+			b = b[size:]
+			f.invalid = false
+			continue
+		}
+		if !final && !utf8.FullRune(b) {
+			break
+		}
+		switch f.cfg.InvalidUTF8 {
+		case config.InvalidUTF8Replace:
+			// a run of invalid bytes is replaced once, as strings.ToValidUTF8 does
+			if !f.invalid {
+				sb.WriteRune(utf8.RuneError)
+				f.fixes++
+			}
+		case config.InvalidUTF8Strip:
+			if !f.invalid {
+				f.fixes++
+			}
+		default:
+			sb.WriteByte(b[0])
+		}
+		f.invalid = true
+		b = b[1:]
+	}
+	return sb.String(), b
+}
+
+// mojibakeByte returns the Windows-1252 byte of the non-ASCII characters the UTF-8 bytes are decoded to as mojibake.
+// The bytes undefined in Windows-1252 are usually decoded to the C1 control characters, as in Latin-1
+func mojibakeByte(r rune) (byte, bool) {
+	if r < utf8.RuneSelf {
+		return 0, false
+	}
+	if r <= 0x9f {
+		return byte(r), true
+	}
+	return charmap.Windows1252.EncodeRune(r)
+}
+
+// cutMojibakeTail splits the text before the run of characters which might be mojibake at its end
+func cutMojibakeTail(s string) (string, string) {
+	i := len(s)
+	for i > 0 {
+		r, size := utf8.DecodeLastRuneInString(s[:i])
+		if _, ok := mojibakeByte(r); !ok {
+			break
+		}
+		i -= size
+	}
+	return s[:i], s[i:]
+}
+
+// fixMojibake decodes again the runs of characters whose Windows-1252 bytes are valid multibyte UTF-8
+func (f *EncodingFixer) fixMojibake(s string) string {
+	var sb strings.Builder
+	// run holds the bytes of the characters which might be mojibake, original the characters themselves
+	var run []byte
+	original := ""
+	flush := func() {
+		if len(run) > 0 && utf8.Valid(run) && utf8.RuneCount(run) < utf8.RuneCountInString(original) {
+			sb.Write(run)
+			f.fixes++
+		} else {
+			sb.WriteString(original)
+		}
+		run, original = run[:0], ""
+	}
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		if b, ok := mojibakeByte(r); ok {
+			run = append(run, b)
+			original += s[:size]
+		} else {
+			flush()
+			// the invalid bytes are kept as they are
+			sb.WriteString(s[:size])
+		}
+		s = s[size:]
+	}
+	flush()
+	return sb.String()
+}
+
+// FixEncoding fixes the encoding of a complete output, returning the number of fixes
+func FixEncoding(cfg config.OutputEncoding, s string) (string, int) {
+	f := NewEncodingFixer(cfg)
+	fixed := f.Feed([]byte(s))
+	fixed += f.Flush()
+	return fixed, f.Fixes()
+}
@@ -0,0 +1,68 @@
+package backend_test
+
+import (
+	. "github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("EncodingFixer", func() {
+	// stream feeds the output one byte at a time, as the backends mishandling the multibyte characters stream it
+	stream := func(cfg config.OutputEncoding, output []byte) (string, int) {
+		f := NewEncodingFixer(cfg)
+		emitted := ""
+		for _, b := range output {
+			emitted += f.Feed([]byte{b})
+		}
+		return emitted + f.Flush(), f.Fixes()
+	}
+
+	It("replaces the invalid UTF-8 sequences", func() {
+		output := []byte("caf\xc3\xa9 \xff\xfe ok \xe2\x82")
+		fixed, fixes := FixEncoding(config.OutputEncoding{InvalidUTF8: config.InvalidUTF8Replace}, string(output))
+		Expect(fixed).To(Equal("café � ok �"))
+		Expect(fixes).To(Equal(2))
+
+		streamed, fixes := stream(config.OutputEncoding{InvalidUTF8: config.InvalidUTF8Replace}, output)
+		Expect(streamed).To(Equal(fixed))
+		Expect(fixes).To(Equal(2))
+	})
+
+	It("strips the invalid UTF-8 sequences", func() {
+		output := []byte("\x80h\xc0\xafi \xed\xa0\x80!")
+		fixed, fixes := stream(config.OutputEncoding{InvalidUTF8: config.InvalidUTF8Strip}, output)
+		Expect(fixed).To(Equal("hi !"))
+		Expect(fixes).To(Equal(3))
+	})
+
+	It("keeps the multibyte characters split across chunks", func() {
+		fixed, fixes := stream(config.OutputEncoding{InvalidUTF8: config.InvalidUTF8Replace}, []byte("日本語 🦙"))
+		Expect(fixed).To(Equal("日本語 🦙"))
+		Expect(fixes).To(BeZero())
+	})
+
+	It("fixes the mojibake", func() {
+		cfg := config.OutputEncoding{Mojibake: true}
+		fixed, fixes := FixEncoding(cfg, "cafÃ© â€œquotedâ€\u009d naÃ¯ve")
+		Expect(fixed).To(Equal("café “quoted” naïve"))
+		Expect(fixes).To(Equal(4))
+
+		streamed, _ := stream(cfg, []byte("cafÃ© is Ã\u00a0 cÃ´tÃ©"))
+		Expect(streamed).To(Equal("café is à côté"))
+	})
+
+	It("keeps the text which is not mojibake", func() {
+		cfg := config.OutputEncoding{Mojibake: true, InvalidUTF8: config.InvalidUTF8Replace}
+		fixed, fixes := FixEncoding(cfg, "Él está en São Paulo, 25°C – 3×2 ½")
+		Expect(fixed).To(Equal("Él está en São Paulo, 25°C – 3×2 ½"))
+		Expect(fixes).To(BeZero())
+	})
+
+	It("leaves the output untouched when disabled", func() {
+		fixed, fixes := FixEncoding(config.OutputEncoding{}, "caf\xc3\xa9 \xff cafÃ©")
+		Expect(fixed).To(Equal("caf\xc3\xa9 \xff cafÃ©"))
+		Expect(fixes).To(BeZero())
+	})
+})
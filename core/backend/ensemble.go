@@ -0,0 +1,46 @@
+package backend
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VoteOutputs returns the index of the output given by most members, and its number of votes.
+// The outputs are compared ignoring the case, the whitespace and the trailing punctuation, so that
+// e.g. "Positive" and "positive." are the same vote. Ties go to the earliest output
+func VoteOutputs(outputs []string) (int, int) {
+	winner, winnerVotes := -1, 0
+	votes := map[string]int{}
+	first := map[string]int{}
+	for i, o := range outputs {
+		key := strings.TrimRight(strings.ToLower(strings.Join(strings.Fields(o), " ")), ".!?")
+		if _, ok := first[key]; !ok {
+			first[key] = i
+		}
+		votes[key]++
+		if n := votes[key]; n > winnerVotes || (n == winnerVotes && first[key] < winner) {
+			winner, winnerVotes = first[key], n
+		}
+	}
+	return winner, winnerVotes
+}
+
+// AverageEmbeddings returns the element-wise average of the embeddings, which must have the same size
+func AverageEmbeddings(embeddings [][]float32) ([]float32, error) {
+	if len(embeddings) == 0 {
+		return nil, fmt.Errorf("no embeddings to average")
+	}
+	avg := make([]float32, len(embeddings[0]))
+	for _, e := range embeddings {
+		if len(e) != len(avg) {
+			return nil, fmt.Errorf("the embeddings have different sizes (%d and %d)", len(avg), len(e))
+		}
+		for i, v := range e {
+			avg[i] += v
+		}
+	}
+	for i := range avg {
+		avg[i] /= float32(len(embeddings))
+	}
+	return avg, nil
+}
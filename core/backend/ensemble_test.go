@@ -0,0 +1,40 @@
+package backend_test
+
+import (
+	. "github.com/mudler/LocalAI/core/backend"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Ensembles", func() {
+	It("votes the most common output", func() {
+		winner, votes := VoteOutputs([]string{"Negative", "positive", " Positive.", "negative", "POSITIVE"})
+		Expect(winner).To(Equal(1))
+		Expect(votes).To(Equal(3))
+	})
+
+	It("breaks the ties with the earliest output", func() {
+		winner, votes := VoteOutputs([]string{"a", "b", "b", "a"})
+		Expect(winner).To(Equal(0))
+		Expect(votes).To(Equal(2))
+
+		winner, _ = VoteOutputs([]string{"a", "b", "c"})
+		Expect(winner).To(Equal(0))
+
+		winner, votes = VoteOutputs(nil)
+		Expect(winner).To(Equal(-1))
+		Expect(votes).To(BeZero())
+	})
+
+	It("averages the embeddings", func() {
+		avg, err := AverageEmbeddings([][]float32{{1, 0, 2}, {0, 1, 4}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(avg).To(Equal([]float32{0.5, 0.5, 3}))
+
+		_, err = AverageEmbeddings([][]float32{{1, 0}, {0, 1, 4}})
+		Expect(err).To(HaveOccurred())
+		_, err = AverageEmbeddings(nil)
+		Expect(err).To(HaveOccurred())
+	})
+})
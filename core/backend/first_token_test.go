@@ -0,0 +1,71 @@
+package backend_test
+
+import (
+	"context"
+	"net"
+	"time"
+
+	. "github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/grpc"
+	"github.com/mudler/LocalAI/pkg/grpc/base"
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/mudler/LocalAI/pkg/model"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// slowFirstToken streams its output after processing the prompt for a while
+type slowFirstToken struct {
+	base.SingleThread
+	promptPriority bool
+}
+
+func (llm *slowFirstToken) Load(opts *pb.ModelOptions) error {
+	llm.promptPriority = opts.PromptPriority
+	return nil
+}
+
+func (llm *slowFirstToken) PredictStream(opts *pb.PredictOptions, results chan string) error {
+	time.Sleep(50 * time.Millisecond)
+	for _, token := range []string{"Hello", " world"} {
+		results <- token
+	}
+	close(results)
+	return nil
+}
+
+var _ = Describe("Time to first token", func() {
+	It("prioritizes the prompts and reports the time to the first token", func() {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		address := lis.Addr().String()
+		lis.Close()
+		llm := &slowFirstToken{}
+		go grpc.StartServer(address, llm)
+		Eventually(func() bool {
+			ok, _ := grpc.NewClient(address, false, nil, false).HealthCheck(context.Background())
+			return ok
+		}, "5s").Should(BeTrue())
+
+		appConfig := config.NewApplicationConfig(config.WithExternalBackend("slow", address))
+		cfg := config.BackendConfig{Name: "slow", Backend: "slow"}
+		cfg.FirstToken.Priority = true
+		cfg.SetDefaults()
+		loader := model.NewModelLoader(GinkgoT().TempDir())
+		defer loader.StopAllGRPC()
+
+		output := ""
+		fn, err := ModelInference(context.Background(), "Hi", nil, nil, nil, nil, loader, cfg, appConfig, func(token string, usage TokenUsage) bool {
+			output += token
+			return true
+		})
+		Expect(err).ToNot(HaveOccurred())
+		r, err := fn()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(output).To(Equal("Hello world"))
+		Expect(llm.promptPriority).To(BeTrue())
+		Expect(r.Usage.TimingFirstToken).To(BeNumerically(">=", 50))
+	})
+})
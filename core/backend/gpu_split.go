@@ -0,0 +1,153 @@
+package backend
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mudler/LocalAI/core/config"
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/mudler/LocalAI/pkg/xsysinfo"
+	"github.com/rs/zerolog/log"
+)
+
+// gpuSplitFunc returns the function checking the GPU settings of the model against the GPUs of the host
+// before loading it, and resolving the automatic tensor split
+func gpuSplitFunc(c config.BackendConfig, o *config.ApplicationConfig) func(*pb.ModelOptions) error {
+	return func(opts *pb.ModelOptions) error {
+		usages, err := xsysinfo.MemoryUsages()
+		if err != nil {
+			log.Warn().Err(err).Str("model", c.Name).Msg("failed detecting the GPUs")
+		}
+		gpus := []xsysinfo.MemoryUsage{}
+		for _, u := range usages {
+			if u.Device != "system" {
+				gpus = append(gpus, u)
+			}
+		}
+		// the backends only see the GPUs in CUDA_VISIBLE_DEVICES, numbered in its order
+		if visible, ok := os.LookupEnv("CUDA_VISIBLE_DEVICES"); ok && len(gpus) > 0 {
+			gpus, err = VisibleGPUs(gpus, visible)
+			if err != nil {
+				log.Warn().Err(err).Str("model", c.Name).Msg("cannot check the GPU settings against CUDA_VISIBLE_DEVICES")
+			}
+		}
+
+		var size int64
+		if info, err := os.Stat(filepath.Join(o.ModelPath, c.Model)); err == nil && info.Mode().IsRegular() {
+			size = info.Size()
+		}
+
+		split, err := ResolveGPUSplit(c, size, gpus)
+		if err != nil {
+			return fmt.Errorf("cannot load %s: %w", c.Name, err)
+		}
+		if split != c.TensorSplit {
+			log.Info().Str("model", c.Name).Str("tensor_split", split).Msg("split the model across the GPUs")
+		}
+		opts.TensorSplit = split
+		return nil
+	}
+}
+
+// VisibleGPUs returns the GPUs listed in CUDA_VISIBLE_DEVICES (the given value), in the order the backends
+// number them. Like CUDA, it stops at the first entry which is not a GPU. It fails, returning no GPU, if the
+// entries are not indexes (e.g. GPU UUIDs) and cannot be matched against the detected GPUs
+func VisibleGPUs(gpus []xsysinfo.MemoryUsage, visible string) ([]xsysinfo.MemoryUsage, error) {
+	byDevice := map[string]xsysinfo.MemoryUsage{}
+	for _, g := range gpus {
+		byDevice[g.Device] = g
+	}
+	res := []xsysinfo.MemoryUsage{}
+	for _, e := range strings.Split(visible, ",") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			break
+		}
+		index, err := strconv.Atoi(e)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported CUDA_VISIBLE_DEVICES entry %q: only GPU indexes are supported", e)
+		}
+		g, ok := byDevice["gpu"+strconv.Itoa(index)]
+		if !ok {
+			break
+		}
+		res = append(res, g)
+	}
+	return res, nil
+}
+
+// ResolveGPUSplit returns the tensor split the model is loaded with, computing the automatic split from the
+// free memory of the GPUs. It fails if the settings do not match the GPUs, or if the model (of the given size
+// in bytes, 0 if unknown) does not fit in their free memory even when split across them
+func ResolveGPUSplit(c config.BackendConfig, size int64, gpus []xsysinfo.MemoryUsage) (string, error) {
+	mainGPU, err := c.MainGPUIndex()
+	if err != nil {
+		return "", err
+	}
+	if len(gpus) == 0 {
+		if c.TensorSplit == config.TensorSplitAuto {
+			return "", fmt.Errorf("the automatic tensor split requires detecting the GPUs, but none was found")
+		}
+		// the settings cannot be checked, the backend validates them
+		return c.TensorSplit, nil
+	}
+	if mainGPU >= len(gpus) {
+		return "", fmt.Errorf("main_gpu is %d, but %d GPUs were detected", mainGPU, len(gpus))
+	}
+	if c.TensorSplit == "" {
+		return "", nil
+	}
+
+	var ratios []float64
+	split := c.TensorSplit
+	if c.TensorSplit == config.TensorSplitAuto {
+		total := uint64(0)
+		for _, g := range gpus {
+			total += g.Free
+		}
+		if total == 0 {
+			return "", fmt.Errorf("the GPUs have no free memory")
+		}
+		parts := []string{}
+		for _, g := range gpus {
+			ratio := float64(g.Free) / float64(total)
+			ratios = append(ratios, ratio)
+			parts = append(parts, strconv.FormatFloat(ratio, 'f', 2, 64))
+		}
+		split = strings.Join(parts, ",")
+	} else {
+		ratios, err = config.ParseTensorSplit(c.TensorSplit)
+		if err != nil {
+			return "", fmt.Errorf("tensor_split: %w", err)
+		}
+		if len(ratios) > len(gpus) {
+			return "", fmt.Errorf("tensor_split has %d ratios, but %d GPUs were detected", len(ratios), len(gpus))
+		}
+	}
+
+	// the model only has to fit when all its layers are offloaded
+	if size > 0 && c.OffloadsAllLayers() {
+		total := 0.0
+		for _, r := range ratios {
+			total += r
+		}
+		short := []string{}
+		for i, r := range ratios {
+			if need := uint64(float64(size) * r / total); need > gpus[i].Free {
+				short = append(short, fmt.Sprintf("%s needs %s but has %s free", gpus[i].Device, formatBytes(need), formatBytes(gpus[i].Free)))
+			}
+		}
+		if len(short) > 0 {
+			return "", fmt.Errorf("the model (%s) does not fit in the GPUs with tensor_split %q: %s. Lower gpu_layers to keep part of it on the CPU",
+				formatBytes(uint64(size)), split, strings.Join(short, ", "))
+		}
+	}
+	return split, nil
+}
+
+func formatBytes(b uint64) string {
+	return fmt.Sprintf("%.1f GiB", float64(b)/(1<<30))
+}
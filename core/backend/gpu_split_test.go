@@ -0,0 +1,85 @@
+package backend_test
+
+import (
+	. "github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/xsysinfo"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GPU split", func() {
+	const gib = 1 << 30
+	gpus := []xsysinfo.MemoryUsage{
+		{Device: "gpu0", Total: 24 * gib, Free: 20 * gib},
+		{Device: "gpu1", Total: 24 * gib, Free: 10 * gib},
+	}
+
+	It("splits the model by the free memory of the GPUs", func() {
+		split, err := ResolveGPUSplit(config.BackendConfig{LLMConfig: config.LLMConfig{TensorSplit: "auto"}}, 27*gib, gpus)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(split).To(Equal("0.67,0.33"))
+	})
+
+	It("checks the settings against the GPUs", func() {
+		cfg := config.BackendConfig{LLMConfig: config.LLMConfig{TensorSplit: "1,1,1"}}
+		_, err := ResolveGPUSplit(cfg, 0, gpus)
+		Expect(err).To(MatchError(ContainSubstring("3 ratios, but 2 GPUs")))
+
+		cfg = config.BackendConfig{LLMConfig: config.LLMConfig{MainGPU: "2"}}
+		_, err = ResolveGPUSplit(cfg, 0, gpus)
+		Expect(err).To(MatchError(ContainSubstring("2 GPUs were detected")))
+
+		cfg = config.BackendConfig{LLMConfig: config.LLMConfig{TensorSplit: "3,1", MainGPU: "1"}}
+		Expect(ResolveGPUSplit(cfg, 0, gpus)).To(Equal("3,1"))
+
+		// without detected GPUs only the automatic split fails
+		Expect(ResolveGPUSplit(cfg, 0, nil)).To(Equal("3,1"))
+		cfg.TensorSplit = "auto"
+		_, err = ResolveGPUSplit(cfg, 0, nil)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("fails when the model does not fit even sharded", func() {
+		cfg := config.BackendConfig{LLMConfig: config.LLMConfig{TensorSplit: "auto"}}
+		_, err := ResolveGPUSplit(cfg, 40*gib, gpus)
+		Expect(err).To(MatchError(ContainSubstring("does not fit")))
+
+		// an even split overflows the GPU with less free memory
+		cfg.TensorSplit = "1,1"
+		_, err = ResolveGPUSplit(cfg, 24*gib, gpus)
+		Expect(err).To(MatchError(ContainSubstring("gpu1 needs 12.0 GiB but has 10.0 GiB free")))
+
+		// the layers left on the CPU are not checked
+		layers := 40
+		cfg.NGPULayers = &layers
+		Expect(ResolveGPUSplit(cfg, 24*gib, gpus)).To(Equal("1,1"))
+	})
+})
+
+var _ = Describe("Visible GPUs", func() {
+	gpus := []xsysinfo.MemoryUsage{{Device: "gpu0", Free: 1}, {Device: "gpu1", Free: 2}, {Device: "gpu2", Free: 3}}
+
+	It("numbers the GPUs in the order of CUDA_VISIBLE_DEVICES", func() {
+		visible, err := VisibleGPUs(gpus, "2, 0")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(visible).To(Equal([]xsysinfo.MemoryUsage{gpus[2], gpus[0]}))
+
+		// main_gpu is the index among the visible GPUs
+		_, err = ResolveGPUSplit(config.BackendConfig{LLMConfig: config.LLMConfig{MainGPU: "2"}}, 0, visible)
+		Expect(err).To(MatchError(ContainSubstring("2 GPUs were detected")))
+	})
+
+	It("stops at the first invalid index", func() {
+		Expect(VisibleGPUs(gpus, "1,5,0")).To(Equal([]xsysinfo.MemoryUsage{gpus[1]}))
+		Expect(VisibleGPUs(gpus, "-1")).To(BeEmpty())
+		Expect(VisibleGPUs(gpus, "")).To(BeEmpty())
+	})
+
+	It("does not match the GPU UUIDs", func() {
+		visible, err := VisibleGPUs(gpus, "GPU-8932f937-d72c-4106-c12f-20bd9faed9f6")
+		Expect(err).To(HaveOccurred())
+		Expect(visible).To(BeEmpty())
+	})
+})
@@ -8,6 +8,7 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 	"unicode/utf8"
 
 	"github.com/rs/zerolog/log"
@@ -24,6 +25,9 @@ import (
 type LLMResponse struct {
 	Response string // should this be []byte?
 	Usage    TokenUsage
+	// Logprob is the sum of the log-probabilities of the generated tokens, reported by some backends
+	// (e.g. llama.cpp) for the predictions that are not streamed
+	Logprob float64
 }
 
 type TokenUsage struct {
@@ -31,9 +35,30 @@ type TokenUsage struct {
 	Completion             int
 	TimingPromptProcessing float64
 	TimingTokenGeneration  float64
+	// TimingFirstToken is the time from the request to the backend to the first token of the output, in milliseconds,
+	// measured when the output is streamed from the backend
+	TimingFirstToken float64
+
+	// Segments are the tokens spent in each segment of the output, counted when the request sets a token budget
+	Segments *schema.CompletionTokensDetails
+	// BudgetExhausted is the segment whose token budget stopped the generation, if any
+	BudgetExhausted string
+	// Repetition is set when the generation was stopped because the output repeated the same phrase in a loop
+	Repetition bool
+	// CPUFallback is set when the GPU ran out of memory and the output was generated on the CPU
+	CPUFallback bool
 }
 
 func ModelInference(ctx context.Context, s string, messages []schema.Message, images, videos, audios []string, loader *model.ModelLoader, c config.BackendConfig, o *config.ApplicationConfig, tokenCallback func(string, TokenUsage) bool) (func() (LLMResponse, error), error) {
+	if !c.CPUFallback.Enabled || c.OnCPU {
+		return modelInference(ctx, s, messages, images, videos, audios, loader, c, o, tokenCallback)
+	}
+	return WithCPUFallback(c, loader.OutOfMemory, tokenCallback, func(c config.BackendConfig, tokenCallback func(string, TokenUsage) bool) (func() (LLMResponse, error), error) {
+		return modelInference(ctx, s, messages, images, videos, audios, loader, c, o, tokenCallback)
+	})
+}
+
+func modelInference(ctx context.Context, s string, messages []schema.Message, images, videos, audios []string, loader *model.ModelLoader, c config.BackendConfig, o *config.ApplicationConfig, tokenCallback func(string, TokenUsage) bool) (func() (LLMResponse, error), error) {
 	modelFile := c.Model
 
 	// Check if the modelFile exists, if it doesn't try to load it from the gallery
@@ -104,9 +129,14 @@ func ModelInference(ctx context.Context, s string, messages []schema.Message, im
 				}
 			}
 
-			promptInfo, pErr := inferenceModel.TokenizeString(ctx, opts)
-			if pErr == nil && promptInfo.Length > 0 {
+			cache := loader.TokenCache(ModelID(c))
+			if tokens, ok := cachedTokens(cache, opts.Prompt); ok {
+				tokenUsage.Prompt = len(tokens)
+			} else if promptInfo, pErr := inferenceModel.TokenizeString(ctx, opts); pErr == nil && promptInfo.Length > 0 {
 				tokenUsage.Prompt = int(promptInfo.Length)
+				if cache != nil && len(promptInfo.Tokens) == int(promptInfo.Length) {
+					cache.Add(opts.Prompt, promptInfo.Tokens)
+				}
 			}
 
 			tokenCallback = func(token string, usage TokenUsage) bool {
@@ -115,36 +145,152 @@ func ModelInference(ctx context.Context, s string, messages []schema.Message, im
 			}
 		}
 
-		if tokenCallback != nil {
+		var budget *TokenBudgetTracker
+		if b, tools := c.TokenBudget(); b != nil {
+			budget = NewTokenBudgetTracker(*b, c.Reasoning, tools)
+		}
+
+		streamCallback := tokenCallback
+		if streamCallback == nil && (c.StopNewlines > 0 || budget != nil || c.RepetitionStop.Enabled()) {
+			// the newlines, the budgets and the repetitions are counted while the output is streamed from the backend
+			streamCallback = func(string, TokenUsage) bool { return true }
+		}
+
+		if streamCallback != nil {
 			ss := ""
 
-			var partialRune []byte
-			err := inferenceModel.PredictStream(ctx, opts, func(reply *proto.Reply) {
-				msg := reply.Message
-				partialRune = append(partialRune, msg...)
+			var stopper *NewlineStopper
+			if c.StopNewlines > 0 {
+				stopper = NewNewlineStopper(c.StopNewlines)
+			}
+			var repetition *RepetitionDetector
+			if c.RepetitionStop.Enabled() {
+				repetition = NewRepetitionDetector(c.RepetitionStop)
+			}
+			var encoding *EncodingFixer
+			if c.OutputEncoding.Enabled() {
+				encoding = NewEncodingFixer(c.OutputEncoding)
+			}
 
-				tokenUsage.Prompt = int(reply.PromptTokens)
-				tokenUsage.Completion = int(reply.Tokens)
-				tokenUsage.TimingTokenGeneration = reply.TimingTokenGeneration
-				tokenUsage.TimingPromptProcessing = reply.TimingPromptProcessing
-
-				for len(partialRune) > 0 {
-					r, size := utf8.DecodeRune(partialRune)
-					if r == utf8.RuneError {
-						// incomplete rune, wait for more bytes
-						break
+			// predictStream streams the completion, returning whether the generation was stopped on purpose
+			predictStream := func(opts *proto.PredictOptions) (bool, error) {
+				predictCtx, cancel := context.WithCancel(ctx)
+				defer cancel()
+				stopped := false
+				// the tokens generated before, when the generation is continued
+				completion := tokenUsage.Completion
+
+				var partialRune []byte
+				started := time.Now()
+				err := inferenceModel.PredictStream(predictCtx, opts, func(reply *proto.Reply) {
+					if stopped {
+						return
+					}
+					msg := reply.Message
+					if encoding != nil {
+						partialRune = append(partialRune, encoding.Feed(msg)...)
+					} else {
+						partialRune = append(partialRune, msg...)
+					}
+
+					if completion == 0 {
+						tokenUsage.Prompt = int(reply.PromptTokens)
 					}
+					tokenUsage.Completion = completion + int(reply.Tokens)
+					tokenUsage.TimingTokenGeneration = reply.TimingTokenGeneration
+					tokenUsage.TimingPromptProcessing = reply.TimingPromptProcessing
 
-					tokenCallback(string(r), tokenUsage)
-					ss += string(r)
+					for len(partialRune) > 0 {
+						r, size := utf8.DecodeRune(partialRune)
+						if r == utf8.RuneError {
+							// incomplete rune, wait for more bytes
+							break
+						}
+						partialRune = partialRune[size:]
 
-					partialRune = partialRune[size:]
+						token := string(r)
+						if stopper != nil {
+							token, stopped = stopper.Feed(token)
+						}
+						if token != "" {
+							if tokenUsage.TimingFirstToken == 0 {
+								tokenUsage.TimingFirstToken = float64(time.Since(started).Microseconds()) / 1000
+							}
+							if budget != nil && budget.Feed(token, tokenUsage.Completion) {
+								stopped = true
+							}
+							if repetition != nil && repetition.Feed(token) {
+								log.Debug().Str("model", c.Name).Msg("the output repeats the same phrase, stopping the generation")
+								tokenUsage.Repetition = true
+								stopped = true
+							}
+							streamCallback(token, tokenUsage)
+							ss += token
+						}
+						if stopped {
+							// stop the generation, as for the stop words
+							cancel()
+							break
+						}
+					}
+
+					if len(msg) == 0 {
+						streamCallback("", tokenUsage)
+					}
+				})
+				if stopped {
+					// the stream was cancelled on purpose
+					return true, nil
 				}
+				return false, err
+			}
 
-				if len(msg) == 0 {
-					tokenCallback("", tokenUsage)
+			if budget != nil {
+				tokenUsage.Segments = budget.Usage()
+			}
+			stopped, err := predictStream(opts)
+			if err == nil && budget != nil && budget.Exhausted() == SegmentReasoning {
+				// the reasoning is closed and the model generates the answer from there
+				remaining := opts.Tokens - int32(tokenUsage.Completion)
+				switch {
+				case c.TemplateConfig.UseTokenizerTemplate:
+					log.Warn().Str("model", c.Name).Msg("the reasoning budget is exhausted, but the generation cannot be continued with the tokenizer template")
+				case opts.Tokens > 0 && remaining <= 0:
+					// no tokens are left for the answer
+				default:
+					_, end := c.Reasoning.Tags()
+					budget.CloseReasoning()
+					streamCallback(end, tokenUsage)
+					ss += end
+					opts.Prompt = s + ss
+					if opts.Tokens > 0 {
+						opts.Tokens = remaining
+					}
+					stopped, err = predictStream(opts)
+				}
+			}
+			if budget != nil {
+				tokenUsage.BudgetExhausted = budget.Exhausted()
+			}
+			if !stopped && encoding != nil {
+				held := encoding.Flush()
+				if stopper != nil {
+					held, stopped = stopper.Feed(held)
 				}
-			})
+				if held != "" {
+					streamCallback(held, tokenUsage)
+					ss += held
+				}
+			}
+			if encoding != nil && encoding.Fixes() > 0 {
+				log.Warn().Str("model", c.Name).Int("fixes", encoding.Fixes()).Msg("fixed the encoding of the output")
+			}
+			if !stopped && stopper != nil {
+				if held := stopper.Flush(); held != "" {
+					streamCallback(held, tokenUsage)
+					ss += held
+				}
+			}
 			return LLMResponse{
 				Response: ss,
 				Usage:    tokenUsage,
@@ -165,9 +311,18 @@ func ModelInference(ctx context.Context, s string, messages []schema.Message, im
 			tokenUsage.TimingTokenGeneration = reply.TimingTokenGeneration
 			tokenUsage.TimingPromptProcessing = reply.TimingPromptProcessing
 
+			response := string(reply.Message)
+			if c.OutputEncoding.Enabled() {
+				var fixes int
+				if response, fixes = FixEncoding(c.OutputEncoding, response); fixes > 0 {
+					log.Warn().Str("model", c.Name).Int("fixes", fixes).Msg("fixed the encoding of the output")
+				}
+			}
+
 			return LLMResponse{
-				Response: string(reply.Message),
+				Response: response,
 				Usage:    tokenUsage,
+				Logprob:  reply.Logprob,
 			}, err
 		}
 	}
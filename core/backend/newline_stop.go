@@ -0,0 +1,49 @@
+package backend
+
+import (
+	"unicode"
+)
+
+// NewlineStopper stops the generation after a number of consecutive newlines (separated by whitespace only).
+// The whitespace following a newline is held back until the generation continues, so that the newlines
+// ending the generation are not returned, as for the stop words
+type NewlineStopper struct {
+	limit    int
+	newlines int
+	held     []rune
+}
+
+func NewNewlineStopper(limit int) *NewlineStopper {
+	return &NewlineStopper{limit: limit}
+}
+
+// Feed returns the text to emit for the next piece of the output, and whether the generation must stop
+func (n *NewlineStopper) Feed(s string) (string, bool) {
+	out := []rune{}
+	for _, r := range s {
+		switch {
+		case r == '\n':
+			n.newlines++
+			if n.newlines >= n.limit {
+				n.held = nil
+				return string(out), true
+			}
+			n.held = append(n.held, r)
+		case unicode.IsSpace(r) && n.newlines > 0:
+			n.held = append(n.held, r)
+		default:
+			out = append(append(out, n.held...), r)
+			n.held = nil
+			n.newlines = 0
+		}
+	}
+	return string(out), false
+}
+
+// Flush returns the text held back when the generation ends
+func (n *NewlineStopper) Flush() string {
+	held := string(n.held)
+	n.held = nil
+	n.newlines = 0
+	return held
+}
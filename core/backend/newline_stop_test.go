@@ -0,0 +1,59 @@
+package backend_test
+
+import (
+	"strings"
+
+	. "github.com/mudler/LocalAI/core/backend"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NewlineStopper", func() {
+	// stream feeds the output one character at a time, as the backends stream it
+	stream := func(limit int, output string) (string, bool) {
+		s := NewNewlineStopper(limit)
+		emitted := ""
+		for _, r := range output {
+			out, stop := s.Feed(string(r))
+			emitted += out
+			if stop {
+				return emitted, true
+			}
+		}
+		return emitted + s.Flush(), false
+	}
+
+	It("stops at the first blank line", func() {
+		out, stopped := stream(2, "First paragraph.\nStill first.\n\nSecond paragraph.")
+		Expect(stopped).To(BeTrue())
+		Expect(out).To(Equal("First paragraph.\nStill first."))
+	})
+
+	It("counts the newlines separated by whitespace only", func() {
+		out, stopped := stream(2, "One.\n  \t\nTwo.")
+		Expect(stopped).To(BeTrue())
+		Expect(out).To(Equal("One."))
+
+		out, stopped = stream(3, "One.\n\n  Two.\n\n\nThree.")
+		Expect(stopped).To(BeTrue())
+		Expect(out).To(Equal("One.\n\n  Two."))
+	})
+
+	It("returns the held newlines when the generation ends", func() {
+		out, stopped := stream(3, "One.\n\nTwo.\n\n")
+		Expect(stopped).To(BeFalse())
+		Expect(out).To(Equal("One.\n\nTwo.\n\n"))
+	})
+
+	It("handles pieces with several characters", func() {
+		s := NewNewlineStopper(2)
+		out, stop := s.Feed("a\n")
+		Expect(out).To(Equal("a"))
+		Expect(stop).To(BeFalse())
+		out, stop = s.Feed(" b\n\nc")
+		Expect(out).To(Equal("\n b"))
+		Expect(stop).To(BeTrue())
+		Expect(strings.Contains(out, "c")).To(BeFalse())
+	})
+})
@@ -11,18 +11,30 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-func ModelOptions(c config.BackendConfig, so *config.ApplicationConfig, opts ...model.Option) []model.Option {
+// ModelID returns the ID the model of the configuration is loaded with
+func ModelID(c config.BackendConfig) string {
 	name := c.Name
 	if name == "" {
 		name = c.Model
 	}
+	// the model is loaded separately with the backend requested by the request
+	if c.BackendOverride {
+		name += "@" + c.Backend
+	}
+	// the copy of the model on the CPU is loaded next to the one on the GPU
+	if c.OnCPU {
+		name += "@cpu"
+	}
+	return name
+}
 
+func ModelOptions(c config.BackendConfig, so *config.ApplicationConfig, opts ...model.Option) []model.Option {
 	defOpts := []model.Option{
 		model.WithBackendString(c.Backend),
 		model.WithModel(c.Model),
 		model.WithAssetDir(so.AssetsDestination),
 		model.WithContext(so.Context),
-		model.WithModelID(name),
+		model.WithModelID(ModelID(c)),
 	}
 
 	threads := 1
@@ -48,6 +60,10 @@ func ModelOptions(c config.BackendConfig, so *config.ApplicationConfig, opts ...
 		defOpts = append(defOpts, model.EnableParallelRequests)
 	}
 
+	if so.ModelLoadingWait > 0 {
+		defOpts = append(defOpts, model.WithLoadingTimeout(so.ModelLoadingWait))
+	}
+
 	if c.GRPC.Attempts != 0 {
 		defOpts = append(defOpts, model.WithGRPCAttempts(c.GRPC.Attempts))
 	}
@@ -60,6 +76,31 @@ func ModelOptions(c config.BackendConfig, so *config.ApplicationConfig, opts ...
 		defOpts = append(defOpts, model.WithExternalBackend(k, v))
 	}
 
+	if c.TensorSplit != "" || c.MainGPU != "" {
+		defOpts = append(defOpts, model.WithBeforeLoad(gpuSplitFunc(c, so)))
+	}
+
+	if c.Resources.IsSet() {
+		defOpts = append(defOpts, model.WithResourceLimits(model.ResourceLimits{
+			CPUs:        c.Resources.CPUs,
+			MemoryBytes: uint64(c.Resources.MemoryMB) << 20,
+		}))
+	}
+
+	if r := c.RopeScalingInfo; r != nil {
+		defOpts = append(defOpts, model.WithRopeScaling(&model.RopeScaling{
+			Method:             r.Method,
+			Factor:             r.Factor,
+			FreqScale:          r.FreqScale,
+			TrainedContextSize: r.TrainedContextSize,
+			ContextSize:        r.ContextSize,
+		}))
+	}
+
+	if c.Warmup.Prompt != "" {
+		defOpts = append(defOpts, model.WithOnLoad(warmupFunc(c, so)))
+	}
+
 	return append(defOpts, opts...)
 }
 
@@ -153,6 +194,7 @@ func grpcModelOpts(c config.BackendConfig) *pb.ModelOptions {
 		FlashAttention:       c.FlashAttention,
 		CacheTypeKey:         c.CacheTypeK,
 		CacheTypeValue:       c.CacheTypeV,
+		PromptPriority:       c.FirstToken.Priority,
 		NoKVOffload:          c.NoKVOffloading,
 		YarnExtFactor:        c.YarnExtFactor,
 		YarnAttnFactor:       c.YarnAttnFactor,
@@ -195,7 +237,7 @@ func gRPCPredictOpts(c config.BackendConfig, modelPath string) *pb.PredictOption
 		}
 	}
 
-	return &pb.PredictOptions{
+	opts := &pb.PredictOptions{
 		Temperature:         float32(*c.Temperature),
 		TopP:                float32(*c.TopP),
 		NDraft:              c.NDraft,
@@ -232,4 +274,13 @@ func gRPCPredictOpts(c config.BackendConfig, modelPath string) *pb.PredictOption
 		TailFreeSamplingZ:   float32(*c.TFZ),
 		TypicalP:            float32(*c.TypicalP),
 	}
+
+	if c.TemperatureSchedule.Type != "" {
+		opts.TemperatureSchedule = c.TemperatureSchedule.Type
+		for _, p := range c.TemperatureSchedule.Points(*c.Temperature) {
+			opts.TemperatureScheduleTokens = append(opts.TemperatureScheduleTokens, int32(p.Tokens))
+			opts.TemperatureScheduleValues = append(opts.TemperatureScheduleValues, float32(p.Temperature))
+		}
+	}
+	return opts
 }
@@ -0,0 +1,95 @@
+package backend
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"github.com/mudler/LocalAI/core/config"
+	model "github.com/mudler/LocalAI/pkg/model"
+)
+
+const (
+	PromptCompressionRedundancy = "redundancy"
+	PromptCompressionModel      = "model"
+
+	defaultPromptCompressionPrompt = "Compress the following text, removing the redundant words and sentences while preserving all its information. Answer with the compressed text only.\n\nText:\n"
+
+	// minRedundantWords is the minimum number of words of the sentences that can be removed when repeated,
+	// so that short sentences (e.g. "Yes.") are kept
+	minRedundantWords = 4
+)
+
+// PromptCompressionMethod returns the configured method, defaulting to redundancy
+func PromptCompressionMethod(c config.PromptCompression) string {
+	if strings.ToLower(c.Method) == PromptCompressionModel {
+		return PromptCompressionModel
+	}
+	return PromptCompressionRedundancy
+}
+
+// RemoveRedundancy compresses the text by collapsing the extra whitespace and dropping the sentences
+// already seen, in the text or in the previous ones sharing the same seen map
+func RemoveRedundancy(text string, seen map[string]bool) string {
+	lines := []string{}
+	for _, line := range strings.Split(text, "\n") {
+		kept := []string{}
+		for _, sentence := range splitSentences(line) {
+			key := strings.TrimRight(strings.ToLower(strings.Join(strings.Fields(sentence), " ")), ".!?")
+			if len(strings.Fields(key)) >= minRedundantWords {
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+			}
+			kept = append(kept, strings.Join(strings.Fields(sentence), " "))
+		}
+		line = strings.Join(kept, " ")
+		// collapse the runs of blank lines
+		if line == "" && (len(lines) == 0 || lines[len(lines)-1] == "") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// splitSentences splits a line after the sentence terminators followed by a space
+func splitSentences(line string) []string {
+	sentences := []string{}
+	runes := []rune(line)
+	start := 0
+	for i, r := range runes {
+		if (r == '.' || r == '!' || r == '?') && (i+1 == len(runes) || unicode.IsSpace(runes[i+1])) {
+			if s := strings.TrimSpace(string(runes[start : i+1])); s != "" {
+				sentences = append(sentences, s)
+			}
+			start = i + 1
+		}
+	}
+	if s := strings.TrimSpace(string(runes[start:])); s != "" {
+		sentences = append(sentences, s)
+	}
+	return sentences
+}
+
+// CompressWithModel asks the compression model to compress the text
+func CompressWithModel(ctx context.Context, text string, c config.PromptCompression, compressionConfig config.BackendConfig, loader *model.ModelLoader, appConfig *config.ApplicationConfig) (string, error) {
+	prompt := c.Prompt
+	if prompt == "" {
+		prompt = defaultPromptCompressionPrompt
+	}
+	fn, err := ModelInference(ctx, prompt+text, nil, nil, nil, nil, loader, compressionConfig, appConfig, nil)
+	if err != nil {
+		return "", err
+	}
+	res, err := fn()
+	if err != nil {
+		return "", err
+	}
+	// never make the text longer
+	if compressed := strings.TrimSpace(res.Response); compressed != "" && len(compressed) < len(text) {
+		return compressed, nil
+	}
+	return text, nil
+}
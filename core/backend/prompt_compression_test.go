@@ -0,0 +1,25 @@
+package backend_test
+
+import (
+	. "github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Prompt compression", func() {
+	It("removes the repeated sentences and the extra whitespace", func() {
+		seen := map[string]bool{}
+		text := "The report is due on Friday.   Please review it.\n\n\n\nThe report is due on Friday! Yes. Yes."
+		Expect(RemoveRedundancy(text, seen)).To(Equal("The report is due on Friday. Please review it.\n\nYes. Yes."))
+
+		// the sentences are deduplicated across the texts sharing the seen map
+		Expect(RemoveRedundancy("the report is due   on Friday. Thanks a lot for the help", seen)).To(Equal("Thanks a lot for the help"))
+	})
+
+	It("defaults to the redundancy method", func() {
+		Expect(PromptCompressionMethod(config.PromptCompression{})).To(Equal(PromptCompressionRedundancy))
+		Expect(PromptCompressionMethod(config.PromptCompression{Method: "Model"})).To(Equal(PromptCompressionModel))
+	})
+})
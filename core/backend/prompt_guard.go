@@ -0,0 +1,122 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/mudler/LocalAI/core/config"
+	model "github.com/mudler/LocalAI/pkg/model"
+)
+
+const (
+	PromptGuardActionWarn  = "warn"
+	PromptGuardActionTag   = "tag"
+	PromptGuardActionBlock = "block"
+
+	defaultPromptGuardLabel = "injection"
+)
+
+// DefaultPromptInjectionPatterns is the built-in ruleset used to detect
+// common prompt injection attempts when no patterns are configured
+var DefaultPromptInjectionPatterns = []string{
+	`(?i)ignore\s+(all\s+)?(the\s+)?(previous|prior|above|earlier|preceding)\s+(instructions|prompts|messages|rules|directions)`,
+	`(?i)disregard\s+(all\s+)?(the\s+)?(previous|prior|above|earlier|preceding|your)\s+(instructions|prompts|messages|rules|directions)`,
+	`(?i)forget\s+(all\s+)?(everything|your|the)\s+(previous\s+)?(instructions|rules|you\s+were\s+told)`,
+	`(?i)(reveal|print|show|repeat|output)\s+(me\s+)?(your|the)\s+(system\s+prompt|initial\s+instructions|hidden\s+instructions)`,
+	`(?i)you\s+are\s+now\s+(in\s+)?(developer|dan|jailbreak|god)\s+mode`,
+	`(?i)override\s+(your|the|all)\s+(safety\s+)?(instructions|rules|guidelines|restrictions)`,
+}
+
+// PromptInjectionResult is the outcome of a prompt injection check
+type PromptInjectionResult struct {
+	Detected bool
+	// Matches contains the rules (or the classifier model) that flagged the content
+	Matches []string
+}
+
+var promptGuardRegexes = map[string]*regexp.Regexp{}
+var promptGuardMu sync.Mutex
+
+func promptGuardRegex(pattern string) (*regexp.Regexp, error) {
+	promptGuardMu.Lock()
+	defer promptGuardMu.Unlock()
+	if r, ok := promptGuardRegexes[pattern]; ok {
+		return r, nil
+	}
+	r, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt guard pattern %q: %w", pattern, err)
+	}
+	promptGuardRegexes[pattern] = r
+	return r, nil
+}
+
+// PromptGuardAction returns the configured action, defaulting to warn
+func PromptGuardAction(c config.PromptGuard) string {
+	switch strings.ToLower(c.Action) {
+	case PromptGuardActionTag:
+		return PromptGuardActionTag
+	case PromptGuardActionBlock:
+		return PromptGuardActionBlock
+	default:
+		return PromptGuardActionWarn
+	}
+}
+
+// DetectPromptInjection matches the given texts against the configured regex ruleset
+func DetectPromptInjection(texts []string, c config.PromptGuard) (PromptInjectionResult, error) {
+	result := PromptInjectionResult{}
+
+	patterns := c.Patterns
+	if len(patterns) == 0 {
+		patterns = DefaultPromptInjectionPatterns
+	}
+	patterns = append(append([]string{}, patterns...), c.ExtraPatterns...)
+
+	for _, p := range patterns {
+		r, err := promptGuardRegex(p)
+		if err != nil {
+			return result, err
+		}
+		for _, t := range texts {
+			if r.MatchString(t) {
+				result.Detected = true
+				result.Matches = append(result.Matches, p)
+				break
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// ClassifyPromptInjection asks the classifier model whether the texts contain an injection attempt.
+// The content is flagged if the classifier answer contains the configured label.
+func ClassifyPromptInjection(ctx context.Context, texts []string, c config.PromptGuard, classifierConfig config.BackendConfig, loader *model.ModelLoader, appConfig *config.ApplicationConfig) (bool, error) {
+	label := c.ClassifierLabel
+	if label == "" {
+		label = defaultPromptGuardLabel
+	}
+
+	for _, t := range texts {
+		if strings.TrimSpace(t) == "" {
+			continue
+		}
+		fn, err := ModelInference(ctx, t, nil, nil, nil, nil, loader, classifierConfig, appConfig, nil)
+		if err != nil {
+			return false, err
+		}
+		res, err := fn()
+		if err != nil {
+			return false, err
+		}
+		if strings.Contains(strings.ToLower(res.Response), strings.ToLower(label)) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,65 @@
+package backend
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/mudler/LocalAI/core/config"
+)
+
+// RepetitionDetector detects when the output ends with the same phrase repeated a number of times in a row,
+// a loop the models sometimes get stuck in. The output is compared word by word, so it only applies to the
+// languages separating the words with whitespace
+type RepetitionDetector struct {
+	repeats  int
+	maxWords int
+	words    []string
+	word     strings.Builder
+}
+
+func NewRepetitionDetector(r config.RepetitionStop) *RepetitionDetector {
+	return &RepetitionDetector{repeats: r.Repeats, maxWords: r.PhraseWords()}
+}
+
+// Feed adds the next piece of the output, and returns whether the output ends with a repeated phrase
+func (d *RepetitionDetector) Feed(s string) bool {
+	repeated := false
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			d.word.WriteRune(r)
+			continue
+		}
+		if d.word.Len() == 0 {
+			continue
+		}
+		d.words = append(d.words, d.word.String())
+		d.word.Reset()
+		// only the words of the longest repetition detected are kept
+		if keep := d.maxWords * d.repeats; len(d.words) > 2*keep {
+			d.words = append(d.words[:0], d.words[len(d.words)-keep:]...)
+		}
+		repeated = repeated || d.repeating()
+	}
+	return repeated
+}
+
+// repeating returns whether the last words are a phrase repeated in a row
+func (d *RepetitionDetector) repeating() bool {
+	for length := 1; length <= d.maxWords; length++ {
+		start := len(d.words) - length*d.repeats
+		if start < 0 {
+			return false
+		}
+		periodic := true
+		for i := start; i < len(d.words)-length; i++ {
+			if d.words[i] != d.words[i+length] {
+				periodic = false
+				break
+			}
+		}
+		if periodic {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package backend_test
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RepetitionDetector", func() {
+	// stream feeds the output one character at a time, as the backends stream it, and returns
+	// the output emitted until the generation is stopped
+	stream := func(r config.RepetitionStop, output string) (string, bool) {
+		d := NewRepetitionDetector(r)
+		emitted := ""
+		for _, c := range output {
+			emitted += string(c)
+			if d.Feed(string(c)) {
+				return emitted, true
+			}
+		}
+		return emitted, false
+	}
+
+	It("stops when a phrase is repeated in a row", func() {
+		output := "The answer is 42. " + strings.Repeat("I am not sure about that. ", 10)
+		out, stopped := stream(config.RepetitionStop{Repeats: 3}, output)
+		Expect(stopped).To(BeTrue())
+		Expect(out).To(Equal("The answer is 42. " + strings.Repeat("I am not sure about that. ", 3)))
+	})
+
+	It("stops on a repeated word", func() {
+		out, stopped := stream(config.RepetitionStop{Repeats: 5}, "Sure! "+strings.Repeat("no ", 20))
+		Expect(stopped).To(BeTrue())
+		Expect(out).To(Equal("Sure! " + strings.Repeat("no ", 5)))
+	})
+
+	It("ignores the repetitions below the threshold", func() {
+		_, stopped := stream(config.RepetitionStop{Repeats: 4}, "It is very very very good, and it is what it is. ")
+		Expect(stopped).To(BeFalse())
+	})
+
+	It("ignores the phrases longer than the maximum", func() {
+		phrase := "one two three four five six seven eight nine ten "
+		_, stopped := stream(config.RepetitionStop{Repeats: 3, MaxPhraseWords: 8}, strings.Repeat(phrase, 5))
+		Expect(stopped).To(BeFalse())
+
+		_, stopped = stream(config.RepetitionStop{Repeats: 3}, strings.Repeat(phrase, 5))
+		Expect(stopped).To(BeTrue())
+	})
+
+	It("detects the loops of long outputs", func() {
+		output := ""
+		for i := 0; i < 500; i++ {
+			output += fmt.Sprintf("step %d, ", i)
+		}
+		_, stopped := stream(config.RepetitionStop{Repeats: 4}, output)
+		Expect(stopped).To(BeFalse())
+
+		out, stopped := stream(config.RepetitionStop{Repeats: 4}, output+strings.Repeat("Let me check again. ", 10))
+		Expect(stopped).To(BeTrue())
+		Expect(out).To(HaveSuffix("step 499, " + strings.Repeat("Let me check again. ", 4)))
+	})
+})
@@ -0,0 +1,73 @@
+package backend
+
+import (
+	"context"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/mudler/LocalAI/core/config"
+	model "github.com/mudler/LocalAI/pkg/model"
+)
+
+const defaultRouterPrompt = "Classify the following request into one of the categories. Answer with the category only."
+
+// PromptCategory is the category of a prompt, as answered by the classifier model of a router
+type PromptCategory struct {
+	// Category is empty when the answer is not one of the categories of the router
+	Category string
+	// Confidence is the probability of the answer, 1 when the backend does not report it
+	Confidence float64
+	Usage      TokenUsage
+}
+
+// ClassifyPrompt asks the classifier model of the router for the category of the text
+func ClassifyPrompt(ctx context.Context, text string, r config.Router, classifierConfig config.BackendConfig, loader *model.ModelLoader, appConfig *config.ApplicationConfig) (PromptCategory, error) {
+	categories := r.Categories()
+	prompt := r.Prompt
+	if prompt == "" {
+		prompt = defaultRouterPrompt
+	}
+	prompt += "\nCategories: " + strings.Join(categories, ", ") + "\n\nRequest: " + text + "\nCategory:"
+
+	// the category is a few tokens, and the most likely ones
+	maxTokens, temperature := 16, 0.0
+	classifierConfig.Maxtokens, classifierConfig.Temperature = &maxTokens, &temperature
+
+	fn, err := ModelInference(ctx, prompt, nil, nil, nil, nil, loader, classifierConfig, appConfig, nil)
+	if err != nil {
+		return PromptCategory{}, err
+	}
+	res, err := fn()
+	if err != nil {
+		return PromptCategory{}, err
+	}
+	return PromptCategory{
+		Category:   MatchCategory(res.Response, categories),
+		Confidence: math.Exp(res.Logprob),
+		Usage:      res.Usage,
+	}, nil
+}
+
+// MatchCategory returns the category the answer of the classifier designates: the first word of the answer, or else
+// the category the answer mentions first. It returns an empty string when the answer mentions no category
+func MatchCategory(answer string, categories []string) string {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	words := strings.FieldsFunc(answer, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '-' && r != '_'
+	})
+	if len(words) > 0 {
+		for _, category := range categories {
+			if strings.ToLower(category) == words[0] {
+				return category
+			}
+		}
+	}
+	match, first := "", len(answer)
+	for _, category := range categories {
+		if i := strings.Index(answer, strings.ToLower(category)); i >= 0 && i < first {
+			match, first = category, i
+		}
+	}
+	return match
+}
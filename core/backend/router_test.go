@@ -0,0 +1,20 @@
+package backend_test
+
+import (
+	. "github.com/mudler/LocalAI/core/backend"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Router", func() {
+	categories := []string{"code", "math", "chat"}
+
+	It("matches the answer of the classifier with a category", func() {
+		Expect(MatchCategory("Code", categories)).To(Equal("code"))
+		Expect(MatchCategory(" math.\n", categories)).To(Equal("math"))
+		Expect(MatchCategory("The category is chat, not code", categories)).To(Equal("chat"))
+		Expect(MatchCategory("poetry", categories)).To(BeEmpty())
+		Expect(MatchCategory("", categories)).To(BeEmpty())
+	})
+})
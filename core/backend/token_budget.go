@@ -0,0 +1,163 @@
+package backend
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+// Output segments counted by the token budgets
+const (
+	SegmentReasoning     = "reasoning"
+	SegmentContent       = "content"
+	SegmentToolArguments = "tool_arguments"
+)
+
+// ValidateTokenBudget checks the token budget of a request
+func ValidateTokenBudget(b *schema.TokenBudget) error {
+	if b.Reasoning < 0 || b.Content < 0 || b.ToolArguments < 0 {
+		return fmt.Errorf("token budgets cannot be negative")
+	}
+	return nil
+}
+
+// TokenBudgetTracker follows the segment of the output being generated, counting the tokens spent in each one
+// against its budget. The boundaries are detected from the reasoning delimiters of the model and, when tool calls
+// are expected, from the JSON the tool calls are generated as
+type TokenBudgetTracker struct {
+	budget           schema.TokenBudget
+	startTag, endTag string
+	tools            bool
+
+	// segment is empty while the segment is not known yet, e.g. at the beginning of the output
+	segment       string
+	reasoningDone bool
+	// window holds the recent output, to find the delimiters split across tokens
+	window string
+
+	usage          schema.CompletionTokensDetails
+	pendingTokens  int
+	lastCompletion int
+	exhausted      string
+}
+
+func NewTokenBudgetTracker(budget schema.TokenBudget, reasoning config.Reasoning, tools bool) *TokenBudgetTracker {
+	t := &TokenBudgetTracker{budget: budget, tools: tools}
+	if reasoning.Enabled {
+		t.startTag, t.endTag = reasoning.Tags()
+		if reasoning.StartsOpen {
+			t.segment = SegmentReasoning
+		}
+	} else {
+		t.reasoningDone = true
+	}
+	return t
+}
+
+// Feed consumes the next piece of the output with the (cumulative) number of completion tokens generated so far,
+// and returns whether the budget of the current segment is exhausted
+func (t *TokenBudgetTracker) Feed(text string, completionTokens int) bool {
+	t.pendingTokens += completionTokens - t.lastCompletion
+	t.lastCompletion = completionTokens
+	t.window += text
+
+	for t.advance() {
+	}
+
+	switch t.segment {
+	case SegmentReasoning:
+		t.usage.ReasoningTokens += t.pendingTokens
+	case SegmentContent:
+		t.usage.ContentTokens += t.pendingTokens
+	case SegmentToolArguments:
+		t.usage.ToolArgumentsTokens += t.pendingTokens
+	default:
+		// counted once the segment is known
+		return false
+	}
+	t.pendingTokens = 0
+
+	if limit, used := t.limit(t.segment); limit > 0 && used >= limit && t.exhausted == "" {
+		t.exhausted = t.segment
+		return true
+	}
+	return false
+}
+
+// advance moves to the next segment if its boundary is in the window, and returns whether it did
+func (t *TokenBudgetTracker) advance() bool {
+	switch t.segment {
+	case SegmentReasoning:
+		i := strings.Index(t.window, t.endTag)
+		if i < 0 {
+			if n := len(t.window) - len(t.endTag); n > 0 {
+				t.window = t.window[n:]
+			}
+			return false
+		}
+		// the tokens of the delimiter are still reasoning
+		t.usage.ReasoningTokens += t.pendingTokens
+		t.pendingTokens = 0
+		t.window = t.window[i+len(t.endTag):]
+		t.segment = ""
+		t.reasoningDone = true
+		return true
+	case "":
+		trimmed := strings.TrimLeftFunc(t.window, unicode.IsSpace)
+		switch {
+		case trimmed == "":
+			return false
+		case !t.reasoningDone && strings.HasPrefix(trimmed, t.startTag):
+			t.window = strings.TrimPrefix(trimmed, t.startTag)
+			t.segment = SegmentReasoning
+			return true
+		case !t.reasoningDone && strings.HasPrefix(t.startTag, trimmed):
+			// it might be the beginning of the reasoning delimiter
+			return false
+		case t.tools && (trimmed[0] == '{' || trimmed[0] == '['):
+			t.segment = SegmentToolArguments
+		default:
+			t.segment = SegmentContent
+		}
+		t.window = ""
+		return false
+	default:
+		// the content and the tool calls last until the end of the output
+		t.window = ""
+		return false
+	}
+}
+
+func (t *TokenBudgetTracker) limit(segment string) (int, int) {
+	switch segment {
+	case SegmentReasoning:
+		return t.budget.Reasoning, t.usage.ReasoningTokens
+	case SegmentContent:
+		return t.budget.Content, t.usage.ContentTokens
+	case SegmentToolArguments:
+		return t.budget.ToolArguments, t.usage.ToolArgumentsTokens
+	}
+	return 0, 0
+}
+
+// CloseReasoning ends the reasoning segment, once its delimiter was appended to the output to let the model
+// generate the answer
+func (t *TokenBudgetTracker) CloseReasoning() {
+	t.segment = ""
+	t.reasoningDone = true
+	t.window = ""
+	t.exhausted = ""
+}
+
+// Exhausted returns the segment whose budget stopped the generation, if any
+func (t *TokenBudgetTracker) Exhausted() string {
+	return t.exhausted
+}
+
+// Usage returns the tokens spent in each segment, updated as the output is fed
+func (t *TokenBudgetTracker) Usage() *schema.CompletionTokensDetails {
+	return &t.usage
+}
@@ -0,0 +1,84 @@
+package backend_test
+
+import (
+	"strings"
+
+	. "github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TokenBudgetTracker", func() {
+	reasoning := config.Reasoning{Enabled: true}
+
+	// stream feeds the output one word at a time, each word being a token, and returns the output
+	// generated until a budget is exhausted
+	stream := func(t *TokenBudgetTracker, output string, tokens int) (string, int) {
+		emitted := ""
+		for _, word := range strings.SplitAfter(output, " ") {
+			tokens++
+			emitted += word
+			if t.Feed(word, tokens) {
+				break
+			}
+		}
+		return emitted, tokens
+	}
+
+	It("counts the tokens of each segment", func() {
+		t := NewTokenBudgetTracker(schema.TokenBudget{}, reasoning, false)
+		out, _ := stream(t, "<think> one two </think> three four five", 0)
+		Expect(out).To(Equal("<think> one two </think> three four five"))
+		Expect(t.Exhausted()).To(BeEmpty())
+		Expect(*t.Usage()).To(Equal(schema.CompletionTokensDetails{ReasoningTokens: 4, ContentTokens: 3}))
+	})
+
+	It("detects the delimiters split across tokens", func() {
+		t := NewTokenBudgetTracker(schema.TokenBudget{}, reasoning, false)
+		for i, token := range []string{"<th", "ink>", "a", "</", "think", ">", "\n", "b"} {
+			t.Feed(token, i+1)
+		}
+		Expect(*t.Usage()).To(Equal(schema.CompletionTokensDetails{ReasoningTokens: 6, ContentTokens: 2}))
+	})
+
+	It("stops when the reasoning budget is exhausted and lets the answer continue", func() {
+		t := NewTokenBudgetTracker(schema.TokenBudget{Reasoning: 3, Content: 2}, reasoning, false)
+		out, tokens := stream(t, "<think> one two three four </think> answer", 0)
+		Expect(out).To(Equal("<think> one two "))
+		Expect(t.Exhausted()).To(Equal(SegmentReasoning))
+
+		t.CloseReasoning()
+		Expect(t.Exhausted()).To(BeEmpty())
+		out, _ = stream(t, "the answer is long", tokens)
+		Expect(out).To(Equal("the answer "))
+		Expect(t.Exhausted()).To(Equal(SegmentContent))
+		Expect(*t.Usage()).To(Equal(schema.CompletionTokensDetails{ReasoningTokens: 3, ContentTokens: 2}))
+	})
+
+	It("counts the tool calls against the tool arguments budget", func() {
+		t := NewTokenBudgetTracker(schema.TokenBudget{Content: 1, ToolArguments: 3}, config.Reasoning{}, true)
+		out, _ := stream(t, `{"name": "search", "arguments": {"query": "weather"}}`, 0)
+		Expect(out).To(Equal(`{"name": "search", "arguments": `))
+		Expect(t.Exhausted()).To(Equal(SegmentToolArguments))
+
+		// plain answers are content, also when tools are expected
+		t = NewTokenBudgetTracker(schema.TokenBudget{ToolArguments: 1}, config.Reasoning{StartsOpen: true}, true)
+		stream(t, "no tool is needed", 0)
+		Expect(t.Exhausted()).To(BeEmpty())
+		Expect(*t.Usage()).To(Equal(schema.CompletionTokensDetails{ContentTokens: 4}))
+	})
+
+	It("starts in the reasoning when the template opens it", func() {
+		t := NewTokenBudgetTracker(schema.TokenBudget{}, config.Reasoning{Enabled: true, StartsOpen: true}, false)
+		stream(t, "one two </think> three", 0)
+		Expect(*t.Usage()).To(Equal(schema.CompletionTokensDetails{ReasoningTokens: 3, ContentTokens: 1}))
+	})
+
+	It("rejects negative budgets", func() {
+		Expect(ValidateTokenBudget(&schema.TokenBudget{Content: -1})).To(HaveOccurred())
+		Expect(ValidateTokenBudget(&schema.TokenBudget{Reasoning: 10})).To(Succeed())
+	})
+})
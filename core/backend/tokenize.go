@@ -8,6 +8,10 @@ import (
 )
 
 func ModelTokenize(s string, loader *model.ModelLoader, backendConfig config.BackendConfig, appConfig *config.ApplicationConfig) (schema.TokenizeResponse, error) {
+	// the texts already tokenized by the loaded model do not need the backend
+	if tokens, ok := cachedTokens(loader.TokenCache(ModelID(backendConfig)), s); ok {
+		return schema.TokenizeResponse{Tokens: tokens}, nil
+	}
 
 	modelFile := backendConfig.Model
 
@@ -34,9 +38,20 @@ func ModelTokenize(s string, loader *model.ModelLoader, backendConfig config.Bac
 	if err != nil {
 		return schema.TokenizeResponse{}, err
 	}
+	if cache := loader.TokenCache(ModelID(backendConfig)); cache != nil && len(resp.Tokens) > 0 {
+		cache.Add(s, resp.Tokens)
+	}
 
 	return schema.TokenizeResponse{
 		Tokens: resp.Tokens,
 	}, nil
 
 }
+
+// cachedTokens returns the tokens of the text in the token cache of the model, if it is loaded
+func cachedTokens(cache *model.TokenCache, s string) ([]int32, bool) {
+	if cache == nil {
+		return nil, false
+	}
+	return cache.Get(s)
+}
@@ -1,8 +1,13 @@
 package backend
 
 import (
+	"bytes"
+	"compress/zlib"
 	"context"
 	"fmt"
+	"math"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/mudler/LocalAI/core/config"
@@ -10,14 +15,26 @@ import (
 
 	"github.com/mudler/LocalAI/pkg/grpc/proto"
 	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/rs/zerolog/log"
 )
 
-func ModelTranscription(audio, language string, translate bool, ml *model.ModelLoader, backendConfig config.BackendConfig, appConfig *config.ApplicationConfig) (*schema.TranscriptionResult, error) {
+const (
+	TimestampGranularitySegment = "segment"
+	TimestampGranularityWord    = "word"
+	TimestampGranularityToken   = "token"
+)
+
+// ModelTranscription transcribes the audio file. timestampGranularities can request "word" and/or "token" level timings
+// in addition to the segments: this requires a backend returning per-token timings (e.g. whisper).
+func ModelTranscription(audio, language string, translate bool, timestampGranularities []string, ml *model.ModelLoader, backendConfig config.BackendConfig, appConfig *config.ApplicationConfig) (*schema.TranscriptionResult, error) {
 
 	if backendConfig.Backend == "" {
 		backendConfig.Backend = model.WhisperBackend
 	}
 
+	tokenLevel := slices.Contains(timestampGranularities, TimestampGranularityToken)
+	wordLevel := tokenLevel || slices.Contains(timestampGranularities, TimestampGranularityWord)
+
 	opts := ModelOptions(backendConfig, appConfig)
 
 	transcriptionModel, err := ml.Load(opts...)
@@ -34,6 +51,8 @@ func ModelTranscription(audio, language string, translate bool, ml *model.ModelL
 		Language:  language,
 		Translate: translate,
 		Threads:   uint32(*backendConfig.Threads),
+
+		TokenTimestamps: tokenLevel || wordLevel,
 	})
 	if err != nil {
 		return nil, err
@@ -41,19 +60,158 @@ func ModelTranscription(audio, language string, translate bool, ml *model.ModelL
 	tr := &schema.TranscriptionResult{
 		Text: r.Text,
 	}
+	timings := []schema.TokenTiming{}
 	for _, s := range r.Segments {
 		var tks []int
 		for _, t := range s.Tokens {
 			tks = append(tks, int(t))
 		}
-		tr.Segments = append(tr.Segments,
-			schema.Segment{
-				Text:   s.Text,
-				Id:     int(s.Id),
-				Start:  time.Duration(s.Start),
-				End:    time.Duration(s.End),
-				Tokens: tks,
-			})
+		segment := schema.Segment{
+			Text:   s.Text,
+			Id:     int(s.Id),
+			Start:  time.Duration(s.Start),
+			End:    time.Duration(s.End),
+			Tokens: tks,
+		}
+		segmentConfidence(&segment, s)
+		for _, t := range s.TokenTimings {
+			timing := schema.TokenTiming{
+				Id:          int(t.Id),
+				Text:        t.Text,
+				Start:       time.Duration(t.Start),
+				End:         time.Duration(t.End),
+				Probability: t.Probability,
+			}
+			timings = append(timings, timing)
+			if tokenLevel {
+				segment.TokenTimings = append(segment.TokenTimings, timing)
+			}
+		}
+		tr.Segments = append(tr.Segments, segment)
 	}
+
+	if wordLevel {
+		if len(timings) == 0 {
+			log.Warn().Str("backend", backendConfig.Backend).Msg("the backend did not return token timings, only segment timestamps are available")
+		}
+		tr.Words = WordsFromTokens(timings)
+	}
+
 	return tr, err
 }
+
+// isSpecialToken returns whether the token is a special token of whisper (e.g. "[_BEG_]" or "<|en|>")
+func isSpecialToken(text string) bool {
+	return strings.HasPrefix(text, "[_") || strings.HasPrefix(text, "<|") || text == ""
+}
+
+// segmentConfidence sets the confidence of the segment: the average log probability of its tokens and the probability
+// of no speech reported by the backend, the average log probability being computed from the probabilities of the
+// tokens otherwise, and the compression ratio of its text
+func segmentConfidence(segment *schema.Segment, s *proto.TranscriptSegment) {
+	if s.AvgLogprob != nil {
+		logprob := float64(*s.AvgLogprob)
+		segment.AvgLogprob = &logprob
+	} else {
+		var sum float64
+		n := 0
+		for _, t := range s.TokenTimings {
+			if !isSpecialToken(t.Text) {
+				sum += math.Log(max(float64(t.Probability), math.SmallestNonzeroFloat32))
+				n++
+			}
+		}
+		if n > 0 {
+			logprob := sum / float64(n)
+			segment.AvgLogprob = &logprob
+		}
+	}
+	segment.CompressionRatio = CompressionRatio(segment.Text)
+}
+
+// CompressionRatio returns the ratio between the size of the text and the size of the text compressed with zlib, as
+// computed by Whisper to detect the repetitive hallucinations. It is 0 for an empty text
+func CompressionRatio(text string) float64 {
+	if text == "" {
+		return 0
+	}
+	var buf bytes.Buffer
+	// the default level stores the short texts without compressing them
+	w, _ := zlib.NewWriterLevel(&buf, zlib.BestCompression)
+	w.Write([]byte(text))
+	w.Close()
+	return float64(len(text)) / float64(buf.Len())
+}
+
+// SuppressLowConfidence drops the segments of the transcription with a low confidence: an average log probability
+// lower than the threshold or a compression ratio higher than the threshold. The text and the words of the
+// transcription are those of the segments kept. It returns the number of segments dropped
+func SuppressLowConfidence(tr *schema.TranscriptionResult, confidence config.TranscriptionConfidence) int {
+	logprobThreshold, compressionRatioThreshold := confidence.Thresholds()
+	kept := []schema.Segment{}
+	dropped := []schema.Segment{}
+	for _, s := range tr.Segments {
+		if (s.AvgLogprob != nil && *s.AvgLogprob < logprobThreshold) || s.CompressionRatio > compressionRatioThreshold {
+			dropped = append(dropped, s)
+			continue
+		}
+		kept = append(kept, s)
+	}
+	if len(dropped) == 0 {
+		return 0
+	}
+
+	tr.Segments = kept
+	tr.Text = ""
+	for _, s := range kept {
+		tr.Text += s.Text
+	}
+	if tr.Words != nil {
+		words := []schema.WordTiming{}
+		for _, w := range tr.Words {
+			if !slices.ContainsFunc(dropped, func(s schema.Segment) bool { return w.Start >= s.Start && w.Start < s.End }) {
+				words = append(words, w)
+			}
+		}
+		tr.Words = words
+	}
+	return len(dropped)
+}
+
+// WordsFromTokens groups token timings into words. A token starting with a space begins a new word,
+// special tokens (e.g. "[_BEG_]" or "<|en|>") are skipped.
+func WordsFromTokens(tokens []schema.TokenTiming) []schema.WordTiming {
+	words := []schema.WordTiming{}
+	var probabilities []float32
+
+	closeWord := func() {
+		if len(probabilities) == 0 {
+			return
+		}
+		var sum float32
+		for _, p := range probabilities {
+			sum += p
+		}
+		w := &words[len(words)-1]
+		w.Word = strings.TrimSpace(w.Word)
+		w.Probability = sum / float32(len(probabilities))
+		probabilities = nil
+	}
+
+	for _, t := range tokens {
+		if isSpecialToken(t.Text) {
+			continue
+		}
+		if len(words) == 0 || strings.HasPrefix(t.Text, " ") {
+			closeWord()
+			words = append(words, schema.WordTiming{Start: t.Start})
+		}
+		w := &words[len(words)-1]
+		w.Word += t.Text
+		w.End = t.End
+		probabilities = append(probabilities, t.Probability)
+	}
+	closeWord()
+
+	return words
+}
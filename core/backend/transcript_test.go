@@ -0,0 +1,87 @@
+package backend_test
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Transcription timings", func() {
+	It("groups tokens into words", func() {
+		words := WordsFromTokens([]schema.TokenTiming{
+			{Text: "[_BEG_]", Start: 0, End: 0},
+			{Text: " Hel", Start: 0, End: 100 * time.Millisecond, Probability: 0.5},
+			{Text: "lo", Start: 100 * time.Millisecond, End: 200 * time.Millisecond, Probability: 1},
+			{Text: " world", Start: 300 * time.Millisecond, End: 500 * time.Millisecond, Probability: 0.9},
+			{Text: "<|endoftext|>", Start: 500 * time.Millisecond, End: 500 * time.Millisecond},
+		})
+		Expect(words).To(Equal([]schema.WordTiming{
+			{Word: "Hello", Start: 0, End: 200 * time.Millisecond, Probability: 0.75},
+			{Word: "world", Start: 300 * time.Millisecond, End: 500 * time.Millisecond, Probability: 0.9},
+		}))
+	})
+
+	It("returns no words without tokens", func() {
+		Expect(WordsFromTokens(nil)).To(BeEmpty())
+	})
+
+	Describe("low confidence segments", func() {
+		logprob := func(v float64) *float64 { return &v }
+		// the repetitive hallucinations compress well
+		repeated := strings.Repeat(" Thank you.", 10)
+		transcription := func() *schema.TranscriptionResult {
+			return &schema.TranscriptionResult{
+				Text: " Hello world." + repeated + " Bye.",
+				Segments: []schema.Segment{
+					{Id: 0, Start: 0, End: time.Second, Text: " Hello world.", AvgLogprob: logprob(-0.2), CompressionRatio: CompressionRatio(" Hello world.")},
+					{Id: 1, Start: time.Second, End: 3 * time.Second, Text: repeated, AvgLogprob: logprob(-0.5), CompressionRatio: CompressionRatio(repeated)},
+					{Id: 2, Start: 3 * time.Second, End: 4 * time.Second, Text: " Bye.", AvgLogprob: logprob(-1.5), CompressionRatio: CompressionRatio(" Bye.")},
+				},
+				Words: []schema.WordTiming{
+					{Word: "Hello", Start: 0}, {Word: "world.", Start: 500 * time.Millisecond},
+					{Word: "Thank", Start: time.Second}, {Word: "Bye.", Start: 3 * time.Second},
+				},
+			}
+		}
+
+		It("computes the compression ratio of the repetitive texts", func() {
+			Expect(CompressionRatio("")).To(BeZero())
+			Expect(CompressionRatio(" Hello world.")).To(BeNumerically("<", 1))
+			Expect(CompressionRatio(repeated)).To(BeNumerically(">", 2.4))
+		})
+
+		It("drops the segments below the thresholds", func() {
+			tr := transcription()
+			// the default thresholds are those of Whisper: -1 for the log probability and 2.4 for the compression ratio
+			Expect(SuppressLowConfidence(tr, config.TranscriptionConfidence{})).To(Equal(2))
+			Expect(tr.Segments).To(HaveLen(1))
+			Expect(tr.Text).To(Equal(" Hello world."))
+			Expect(tr.Words).To(HaveLen(2))
+
+			lenient := config.TranscriptionConfidence{LogprobThreshold: logprob(-2), CompressionRatioThreshold: logprob(100)}
+			tr = transcription()
+			Expect(SuppressLowConfidence(tr, lenient)).To(BeZero())
+			Expect(tr.Segments).To(HaveLen(3))
+
+			tr = transcription()
+			Expect(SuppressLowConfidence(tr, config.TranscriptionConfidence{LogprobThreshold: logprob(-0.1)})).To(Equal(3))
+			Expect(tr.Text).To(BeEmpty())
+			Expect(tr.Words).To(BeEmpty())
+		})
+
+		It("keeps the segments whose confidence is not known", func() {
+			tr := transcription()
+			for i := range tr.Segments {
+				tr.Segments[i].AvgLogprob = nil
+			}
+			Expect(SuppressLowConfidence(tr, config.TranscriptionConfidence{CompressionRatioThreshold: logprob(100)})).To(BeZero())
+			Expect(tr.Segments).To(HaveLen(3))
+		})
+	})
+})
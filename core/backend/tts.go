@@ -22,6 +22,7 @@ func ModelTTS(
 	loader *model.ModelLoader,
 	appConfig *config.ApplicationConfig,
 	backendConfig config.BackendConfig,
+	voiceSample *VoiceSample,
 ) (string, *proto.Result, error) {
 	bb := backend
 	if bb == "" {
@@ -63,13 +64,18 @@ func ModelTTS(
 		}
 	}
 
-	res, err := ttsModel.TTS(context.Background(), &proto.TTSRequest{
+	request := &proto.TTSRequest{
 		Text:     text,
 		Model:    modelPath,
 		Voice:    voice,
 		Dst:      filePath,
 		Language: &language,
-	})
+	}
+	if voiceSample != nil {
+		request.VoiceSample, request.VoiceId = voiceSample.Path, voiceSample.Key
+	}
+
+	res, err := ttsModel.TTS(context.Background(), request)
 	if err != nil {
 		return "", nil, err
 	}
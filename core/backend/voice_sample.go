@@ -0,0 +1,107 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/utils"
+)
+
+// ErrInvalidVoiceSample is returned for the voice samples which cannot be cloned
+var ErrInvalidVoiceSample = errors.New("invalid voice sample")
+
+// voiceSampleRate is the sample rate the voice samples in other formats are converted to
+const voiceSampleRate = 24000
+
+var voiceIDRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// VoiceSample is the reference sample of the voice cloned by a TTS request
+type VoiceSample struct {
+	// ID identifies the cloned voice among the voices of the client
+	ID string
+	// Key identifies the cloned voice among the voices of all the clients and models, for the backends caching it
+	Key string
+	// Path is the wav file of the sample
+	Path string
+}
+
+// ValidVoiceID returns whether the voice id can be used to store a sample
+func ValidVoiceID(id string) bool {
+	return voiceIDRegex.MatchString(id)
+}
+
+// StoreVoiceSample validates the audio sample of a voice and stores it as a wav file in the directory, under the id of
+// the voice, replacing the previous sample of the voice. The samples in other formats are converted with ffmpeg
+func StoreVoiceSample(data []byte, dir, id string, cfg config.VoiceCloning) (*VoiceSample, error) {
+	if !ValidVoiceID(id) {
+		return nil, fmt.Errorf("%w: the voice id must be 1 to 64 letters, digits, dashes or underscores", ErrInvalidVoiceSample)
+	}
+	if len(data) > cfg.MaxSize() {
+		return nil, fmt.Errorf("%w: the sample exceeds %d MB", ErrInvalidVoiceSample, cfg.MaxSize()>>20)
+	}
+	format := utils.DetectAudioFormatFromHeader(data[:min(len(data), 512)])
+	if format == "" {
+		return nil, fmt.Errorf("%w: unknown audio format, supported formats are %s", ErrInvalidVoiceSample, strings.Join(utils.AudioFormats, ", "))
+	}
+
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed creating the voices directory: %w", err)
+	}
+	src, err := os.CreateTemp(dir, "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(src.Name())
+	_, err = src.Write(data)
+	if closeErr := src.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the sample is converted next to its final path, so that the previous sample of the voice is replaced at once
+	wav := src.Name() + ".wav"
+	defer os.Remove(wav)
+	info, _ := utils.ReadWavInfo(src.Name())
+	if format == utils.AudioFormatWAV && info != nil && info.AudioFormat == 1 && info.BitsPerSample == 16 {
+		err = os.Rename(src.Name(), wav)
+	} else {
+		err = utils.AudioToWavWithSampleRate(src.Name(), wav, voiceSampleRate)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed converting the sample to wav: %s", ErrInvalidVoiceSample, err.Error())
+	}
+
+	duration, err := utils.WavDuration(wav)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidVoiceSample, err.Error())
+	}
+	if minDuration, maxDuration := cfg.DurationBounds(); duration < minDuration || duration > maxDuration {
+		return nil, fmt.Errorf("%w: the sample lasts %.1f seconds, it must last between %v and %v seconds",
+			ErrInvalidVoiceSample, duration.Seconds(), minDuration.Seconds(), maxDuration.Seconds())
+	}
+
+	path := filepath.Join(dir, id+".wav")
+	if err := os.Rename(wav, path); err != nil {
+		return nil, err
+	}
+	return &VoiceSample{ID: id, Path: path}, nil
+}
+
+// StoredVoiceSample returns the sample stored for the voice in the directory, if any
+func StoredVoiceSample(dir, id string) (*VoiceSample, bool) {
+	if !ValidVoiceID(id) {
+		return nil, false
+	}
+	path := filepath.Join(dir, id+".wav")
+	if _, err := os.Stat(path); err != nil {
+		return nil, false
+	}
+	return &VoiceSample{ID: id, Path: path}, true
+}
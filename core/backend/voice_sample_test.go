@@ -0,0 +1,75 @@
+package backend_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+
+	. "github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// voiceWav returns a 16 kHz mono PCM16 wav file of silence lasting the given seconds
+func voiceWav(seconds int) []byte {
+	size := 16000 * 2 * seconds
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+size))
+	buf.WriteString("WAVEfmt ")
+	for _, v := range []any{uint32(16), uint16(1), uint16(1), uint32(16000), uint32(32000), uint16(2), uint16(16)} {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(size))
+	buf.Write(make([]byte, size))
+	return buf.Bytes()
+}
+
+var _ = Describe("StoreVoiceSample", func() {
+	var dir string
+	cfg := config.VoiceCloning{Enabled: true}
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+	})
+
+	It("stores the sample under the voice id", func() {
+		sample, err := StoreVoiceSample(voiceWav(5), dir, "alice", cfg)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sample.ID).To(Equal("alice"))
+		Expect(sample.Path).To(Equal(filepath.Join(dir, "alice.wav")))
+
+		stored, exists := StoredVoiceSample(dir, "alice")
+		Expect(exists).To(BeTrue())
+		Expect(stored).To(Equal(sample))
+		_, exists = StoredVoiceSample(dir, "bob")
+		Expect(exists).To(BeFalse())
+
+		// only the stored sample is left in the directory
+		entries, err := os.ReadDir(dir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+	})
+
+	It("rejects the samples out of the duration bounds", func() {
+		_, err := StoreVoiceSample(voiceWav(1), dir, "alice", cfg)
+		Expect(err).To(MatchError(ErrInvalidVoiceSample))
+		_, err = StoreVoiceSample(voiceWav(2), dir, "alice", config.VoiceCloning{MinSeconds: 1, MaxSeconds: 1.5})
+		Expect(err).To(MatchError(ErrInvalidVoiceSample))
+		_, exists := StoredVoiceSample(dir, "alice")
+		Expect(exists).To(BeFalse())
+	})
+
+	It("rejects the samples too large, in unknown formats or with invalid voice ids", func() {
+		_, err := StoreVoiceSample(bytes.Repeat([]byte{0}, 11<<20), dir, "alice", cfg)
+		Expect(err).To(MatchError(ErrInvalidVoiceSample))
+		_, err = StoreVoiceSample([]byte("not audio at all"), dir, "alice", cfg)
+		Expect(err).To(MatchError(ErrInvalidVoiceSample))
+		_, err = StoreVoiceSample(voiceWav(5), dir, "../alice", cfg)
+		Expect(err).To(MatchError(ErrInvalidVoiceSample))
+	})
+})
@@ -0,0 +1,43 @@
+package backend
+
+import (
+	"context"
+	"time"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/grpc"
+	"github.com/rs/zerolog/log"
+)
+
+// warmupFunc returns the function running the warmup prompt of the model once loaded
+func warmupFunc(c config.BackendConfig, o *config.ApplicationConfig) func(grpc.Backend) {
+	return func(b grpc.Backend) {
+		if c.Warmup.Blocking {
+			Warmup(o.Context, b, c, o)
+			return
+		}
+		go Warmup(o.Context, b, c, o)
+	}
+}
+
+// Warmup runs the warmup prompt of the model configuration
+func Warmup(ctx context.Context, b grpc.Backend, c config.BackendConfig, o *config.ApplicationConfig) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	opts := gRPCPredictOpts(c, o.ModelPath)
+	opts.Prompt = c.Warmup.Prompt
+	opts.Tokens = 1
+	if c.Warmup.Tokens > 0 {
+		opts.Tokens = int32(c.Warmup.Tokens)
+	}
+
+	log.Debug().Str("model", c.Name).Msg("running the warmup prompt")
+	start := time.Now()
+	if _, err := b.Predict(ctx, opts); err != nil {
+		log.Warn().Err(err).Str("model", c.Name).Msg("warmup failed")
+		return
+	}
+	log.Info().Str("model", c.Name).Dur("duration", time.Since(start)).Msg("model warmed up")
+}
@@ -0,0 +1,41 @@
+package backend_test
+
+import (
+	"context"
+
+	. "github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/grpc"
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	ggrpc "google.golang.org/grpc"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// predictRecorder records the predictions, the other methods of the backend are not implemented
+type predictRecorder struct {
+	grpc.Backend
+	predictions []*pb.PredictOptions
+}
+
+func (p *predictRecorder) Predict(ctx context.Context, in *pb.PredictOptions, opts ...ggrpc.CallOption) (*pb.Reply, error) {
+	p.predictions = append(p.predictions, in)
+	return &pb.Reply{}, nil
+}
+
+var _ = Describe("Warmup", func() {
+	It("runs the warmup prompt", func() {
+		b := &predictRecorder{}
+		cfg := config.BackendConfig{Warmup: config.Warmup{Prompt: "hello"}}
+		cfg.SetDefaults()
+		Warmup(context.Background(), b, cfg, &config.ApplicationConfig{})
+		Expect(b.predictions).To(HaveLen(1))
+		Expect(b.predictions[0].Prompt).To(Equal("hello"))
+		Expect(b.predictions[0].Tokens).To(Equal(int32(1)))
+
+		cfg.Warmup.Tokens = 8
+		Warmup(context.Background(), b, cfg, &config.ApplicationConfig{})
+		Expect(b.predictions[1].Tokens).To(Equal(int32(8)))
+	})
+})
@@ -3,7 +3,11 @@ package cli
 import (
 	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/mudler/LocalAI/core/application"
@@ -12,6 +16,7 @@ import (
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/http"
 	"github.com/mudler/LocalAI/core/p2p"
+	"github.com/mudler/LocalAI/core/schema"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
@@ -23,6 +28,7 @@ type RunCMD struct {
 	BackendAssetsPath            string        `env:"LOCALAI_BACKEND_ASSETS_PATH,BACKEND_ASSETS_PATH" type:"path" default:"/tmp/localai/backend_data" help:"Path used to extract libraries that are required by some of the backends in runtime" group:"storage"`
 	ImagePath                    string        `env:"LOCALAI_IMAGE_PATH,IMAGE_PATH" type:"path" default:"/tmp/generated/images" help:"Location for images generated by backends (e.g. stablediffusion)" group:"storage"`
 	AudioPath                    string        `env:"LOCALAI_AUDIO_PATH,AUDIO_PATH" type:"path" default:"/tmp/generated/audio" help:"Location for audio generated by backends (e.g. piper)" group:"storage"`
+	VoicesPath                   string        `env:"LOCALAI_VOICES_PATH,VOICES_PATH" type:"path" default:"/tmp/localai/voices" help:"Path to store the voice samples of the voices cloned by the TTS models" group:"storage"`
 	UploadPath                   string        `env:"LOCALAI_UPLOAD_PATH,UPLOAD_PATH" type:"path" default:"/tmp/localai/upload" help:"Path to store uploads from files api" group:"storage"`
 	ConfigPath                   string        `env:"LOCALAI_CONFIG_PATH,CONFIG_PATH" default:"/tmp/localai/config" group:"storage"`
 	LocalaiConfigDir             string        `env:"LOCALAI_CONFIG_DIR" type:"path" default:"${basepath}/configuration" help:"Directory for dynamic loading of certain configuration files (currently api_keys.json and external_backends.json)" group:"storage"`
@@ -42,18 +48,22 @@ type RunCMD struct {
 	ContextSize int  `env:"LOCALAI_CONTEXT_SIZE,CONTEXT_SIZE" default:"512" help:"Default context size for models" group:"performance"`
 
 	Address                            string   `env:"LOCALAI_ADDRESS,ADDRESS" default:":8080" help:"Bind address for the API server" group:"api"`
-	CORS                               bool     `env:"LOCALAI_CORS,CORS" help:"" group:"api"`
-	CORSAllowOrigins                   string   `env:"LOCALAI_CORS_ALLOW_ORIGINS,CORS_ALLOW_ORIGINS" group:"api"`
+	CORS                               bool     `env:"LOCALAI_CORS,CORS" help:"Let the browser apps of other origins call the API" group:"api"`
+	CORSAllowOrigins                   string   `env:"LOCALAI_CORS_ALLOW_ORIGINS,CORS_ALLOW_ORIGINS" help:"Comma separated list of the origins allowed by CORS, e.g. https://app.example.com. All of them when empty" group:"api"`
+	CORSAllowCredentials               bool     `env:"LOCALAI_CORS_ALLOW_CREDENTIALS,CORS_ALLOW_CREDENTIALS" help:"Let the allowed origins send their cookies and credentials. Requires --cors-allow-origins" group:"api"`
 	LibraryPath                        string   `env:"LOCALAI_LIBRARY_PATH,LIBRARY_PATH" help:"Path to the library directory (for e.g. external libraries used by backends)" default:"/usr/share/local-ai/libs" group:"backends"`
 	CSRF                               bool     `env:"LOCALAI_CSRF" help:"Enables fiber CSRF middleware" group:"api"`
-	UploadLimit                        int      `env:"LOCALAI_UPLOAD_LIMIT,UPLOAD_LIMIT" default:"15" help:"Default upload-limit in MB" group:"api"`
+	UploadLimit                        int      `env:"LOCALAI_UPLOAD_LIMIT,UPLOAD_LIMIT" default:"15" help:"Limit of the size of the request bodies and of the uploaded files, in MB. 0 uses the default of 15 MB" group:"api"`
 	APIKeys                            []string `env:"LOCALAI_API_KEY,API_KEY" help:"List of API Keys to enable API authentication. When this is set, all the requests must be authenticated with one of these API keys" group:"api"`
+	AdminAPIKeys                       []string `env:"LOCALAI_ADMIN_API_KEY" help:"List of API Keys with admin privileges (e.g. overriding the backend of the requests). They enable the API authentication as well" group:"api"`
+	APIKeyModels                       []string `env:"LOCALAI_API_KEY_MODELS,API_KEY_MODELS" sep:";" help:"API Keys restricted to a subset of the models, as key=model1,model2 entries separated by semicolons. They enable the API authentication as well" group:"api"`
 	DisableWebUI                       bool     `env:"LOCALAI_DISABLE_WEBUI,DISABLE_WEBUI" default:"false" help:"Disable webui" group:"api"`
 	DisablePredownloadScan             bool     `env:"LOCALAI_DISABLE_PREDOWNLOAD_SCAN" help:"If true, disables the best-effort security scanner before downloading any files." group:"hardening" default:"false"`
 	OpaqueErrors                       bool     `env:"LOCALAI_OPAQUE_ERRORS" default:"false" help:"If true, all error responses are replaced with blank 500 errors. This is intended only for hardening against information leaks and is normally not recommended." group:"hardening"`
 	UseSubtleKeyComparison             bool     `env:"LOCALAI_SUBTLE_KEY_COMPARISON" default:"false" help:"If true, API Key validation comparisons will be performed using constant-time comparisons rather than simple equality. This trades off performance on each request for resiliancy against timing attacks." group:"hardening"`
 	DisableApiKeyRequirementForHttpGet bool     `env:"LOCALAI_DISABLE_API_KEY_REQUIREMENT_FOR_HTTP_GET" default:"false" help:"If true, a valid API key is not required to issue GET requests to portions of the web ui. This should only be enabled in secure testing environments" group:"hardening"`
 	DisableMetricsEndpoint             bool     `env:"LOCALAI_DISABLE_METRICS_ENDPOINT,DISABLE_METRICS_ENDPOINT" default:"false" help:"Disable the /metrics endpoint" group:"api"`
+	CapabilitiesRequireAuth            bool     `env:"LOCALAI_CAPABILITIES_REQUIRE_AUTH" default:"false" help:"If true, a valid API key is required to get the capabilities manifest (/.well-known/localai), which is public by default" group:"hardening"`
 	HttpGetExemptedEndpoints           []string `env:"LOCALAI_HTTP_GET_EXEMPTED_ENDPOINTS" default:"^/$,^/browse/?$,^/talk/?$,^/p2p/?$,^/chat/?$,^/text2image/?$,^/tts/?$,^/static/.*$,^/swagger.*$" help:"If LOCALAI_DISABLE_API_KEY_REQUIREMENT_FOR_HTTP_GET is overriden to true, this is the list of endpoints to exempt. Only adjust this in case of a security incident or as a result of a personal security posture review" group:"hardening"`
 	Peer2Peer                          bool     `env:"LOCALAI_P2P,P2P" name:"p2p" default:"false" help:"Enable P2P mode" group:"p2p"`
 	Peer2PeerDHTInterval               int      `env:"LOCALAI_P2P_DHT_INTERVAL,P2P_DHT_INTERVAL" default:"360" name:"p2p-dht-interval" help:"Interval for DHT refresh (used during token generation)" group:"p2p"`
@@ -64,13 +74,46 @@ type RunCMD struct {
 	SingleActiveBackend                bool     `env:"LOCALAI_SINGLE_ACTIVE_BACKEND,SINGLE_ACTIVE_BACKEND" help:"Allow only one backend to be run at a time" group:"backends"`
 	PreloadBackendOnly                 bool     `env:"LOCALAI_PRELOAD_BACKEND_ONLY,PRELOAD_BACKEND_ONLY" default:"false" help:"Do not launch the API services, only the preloaded models / backends are started (useful for multi-node setups)" group:"backends"`
 	ExternalGRPCBackends               []string `env:"LOCALAI_EXTERNAL_GRPC_BACKENDS,EXTERNAL_GRPC_BACKENDS" help:"A list of external grpc backends" group:"backends"`
+	EndpointPriorities                 []string `env:"LOCALAI_ENDPOINT_PRIORITIES,ENDPOINT_PRIORITIES" help:"Priorities of the requests by endpoint type (chat, completion, edit, embeddings, batch), as type:priority. When a backend does not run requests in parallel, the waiting requests with a higher priority run first. Defaults to chat:10,completion:10,edit:10,embeddings:0,batch:0" group:"backends"`
 	EnableWatchdogIdle                 bool     `env:"LOCALAI_WATCHDOG_IDLE,WATCHDOG_IDLE" default:"false" help:"Enable watchdog for stopping backends that are idle longer than the watchdog-idle-timeout" group:"backends"`
 	WatchdogIdleTimeout                string   `env:"LOCALAI_WATCHDOG_IDLE_TIMEOUT,WATCHDOG_IDLE_TIMEOUT" default:"15m" help:"Threshold beyond which an idle backend should be stopped" group:"backends"`
 	EnableWatchdogBusy                 bool     `env:"LOCALAI_WATCHDOG_BUSY,WATCHDOG_BUSY" default:"false" help:"Enable watchdog for stopping backends that are busy longer than the watchdog-busy-timeout" group:"backends"`
 	WatchdogBusyTimeout                string   `env:"LOCALAI_WATCHDOG_BUSY_TIMEOUT,WATCHDOG_BUSY_TIMEOUT" default:"5m" help:"Threshold beyond which a busy backend should be stopped" group:"backends"`
+	WatchdogMemoryThreshold            float64  `env:"LOCALAI_WATCHDOG_MEMORY_THRESHOLD,WATCHDOG_MEMORY_THRESHOLD" default:"0" help:"Minimum percentage of free system or GPU memory: below it, the least recently used idle backends are stopped (0 disables the check)" group:"backends"`
 	Federated                          bool     `env:"LOCALAI_FEDERATED,FEDERATED" help:"Enable federated instance" group:"federated"`
 	DisableGalleryEndpoint             bool     `env:"LOCALAI_DISABLE_GALLERY_ENDPOINT,DISABLE_GALLERY_ENDPOINT" help:"Disable the gallery endpoints" group:"api"`
 	MachineTag                         string   `env:"LOCALAI_MACHINE_TAG" help:"Add Machine-Tag header to each response which is useful to track the machine in the P2P network" group:"api"`
+	MaxImageDimension                  int      `env:"LOCALAI_MAX_IMAGE_DIMENSION,MAX_IMAGE_DIMENSION" default:"0" help:"Maximum size in pixels of the longest side of input images (vision inputs and image edits). Bigger images are downscaled preserving the aspect ratio. 0 disables the limit" group:"api"`
+	MaxImageCount                      int      `env:"LOCALAI_MAX_IMAGE_COUNT,MAX_IMAGE_COUNT" default:"10" help:"Maximum number of images (n) of the image generation requests, for the models not setting their own image_count. 0 disables the limit" group:"api"`
+	RejectOversizedImages              bool     `env:"LOCALAI_REJECT_OVERSIZED_IMAGES,REJECT_OVERSIZED_IMAGES" default:"false" help:"Reject images bigger than max-image-dimension instead of downscaling them" group:"api"`
+	CacheKeyHeader                     bool     `env:"LOCALAI_CACHE_KEY_HEADER,CACHE_KEY_HEADER" default:"false" help:"Return the canonical cache key of inference requests in the LocalAI-Cache-Key response header" group:"api"`
+	GenerationHeaders                  bool     `env:"LOCALAI_GENERATION_HEADERS,GENERATION_HEADERS" default:"false" help:"Return the effective model, seed, temperature and top_p of the completions in the LocalAI-Model, LocalAI-Seed, LocalAI-Temperature and LocalAI-Top-P response headers" group:"api"`
+	ChatTemplateMetadata               bool     `env:"LOCALAI_CHAT_TEMPLATE_METADATA,CHAT_TEMPLATE_METADATA" default:"false" help:"Return the hash of the chat template in the chat responses metadata and in the model list. With --debug, the full template is returned as well" group:"api"`
+	GGUFChatTemplate                   bool     `env:"LOCALAI_GGUF_CHAT_TEMPLATE,GGUF_CHAT_TEMPLATE" default:"false" help:"Use the chat template embedded in the GGUF files of the models without a template, translated to the LocalAI templates. When it cannot be translated, the default template of the model family is used" group:"models"`
+	ModelSuggestions                   bool     `env:"LOCALAI_MODEL_SUGGESTIONS,MODEL_SUGGESTIONS" default:"false" help:"Suggest the models with the closest names in the model_not_found errors" group:"api"`
+	EmbeddingsTokenUsage               bool     `env:"LOCALAI_EMBEDDINGS_TOKEN_USAGE,EMBEDDINGS_TOKEN_USAGE" default:"false" help:"Count the tokens of each input of the embeddings requests: the count of every input is returned with its embedding, and their total in the usage" group:"api"`
+	RequestLogSampleRate               float64  `env:"LOCALAI_REQUEST_LOG_SAMPLE_RATE,REQUEST_LOG_SAMPLE_RATE" default:"1" help:"Fraction of the requests logged, between 0 and 1. The sampling is deterministic per request ID (X-Request-ID). Models can override it with request_log" group:"api"`
+	RequestLogErrors                   bool     `env:"LOCALAI_REQUEST_LOG_ERRORS,REQUEST_LOG_ERRORS" default:"true" negatable:"" help:"Always log the failed requests, regardless of the sample rate" group:"api"`
+	ModelLoadingWait                   string   `env:"LOCALAI_MODEL_LOADING_WAIT,MODEL_LOADING_WAIT" default:"0s" help:"Maximum time a request waits for a model being loaded by another request, after which a 503 model_loading error is returned with a Retry-After header. 0 waits for the load to complete" group:"backends"`
+	IdempotencyWindow                  string   `env:"LOCALAI_IDEMPOTENCY_WINDOW,IDEMPOTENCY_WINDOW" default:"0s" help:"How long the response of a POST request with an Idempotency-Key header is replayed to the retries with the same key, instead of running them again. 0 disables the idempotency keys" group:"api"`
+	GPUStatsInterval                   string   `env:"LOCALAI_GPU_STATS_INTERVAL,GPU_STATS_INTERVAL" default:"0s" help:"Sample the utilization and the memory of the GPUs at this interval during the requests, and report them per device in the metadata of the responses (NVIDIA GPUs only). 0 disables the sampling" group:"api"`
+	StreamHeartbeatInterval            string   `env:"LOCALAI_STREAM_HEARTBEAT_INTERVAL,STREAM_HEARTBEAT_INTERVAL" default:"0s" help:"Interval of the progress heartbeats (localai.heartbeat events with the generated tokens and the elapsed time) sent in the streamed completions. They are not part of the OpenAI API, 0 disables them" group:"api"`
+	StreamLoadingProgress              bool     `env:"LOCALAI_STREAM_LOADING_PROGRESS,STREAM_LOADING_PROGRESS" default:"false" help:"Send the progress of the load of the model (localai.loading events) in the streamed completions waiting for it, before their first chunk. They are not part of the OpenAI API" group:"api"`
+	StreamTrailers                     string   `env:"LOCALAI_STREAM_TRAILERS,STREAM_TRAILERS" enum:",both,only" default:"" help:"Send the usage, the timings and the finish reason of the streamed completions as HTTP trailers: \"both\" also sends them in the final chunk, \"only\" omits the final chunk for the clients accepting trailers (TE: trailers). Disabled by default" group:"api"`
+	StreamBufferSize                   int      `env:"LOCALAI_STREAM_BUFFER_SIZE,STREAM_BUFFER_SIZE" default:"64" help:"Number of chunks of a streamed completion buffered while the client reads the previous ones" group:"api"`
+	StreamBackpressure                 string   `env:"LOCALAI_STREAM_BACKPRESSURE,STREAM_BACKPRESSURE" enum:"block,drop" default:"block" help:"What happens when the client of a streamed completion cannot keep up and its buffer is full: \"block\" slows the backend down to the pace of the client, \"drop\" drops the client with an error" group:"api"`
+	Compression                        string   `env:"LOCALAI_COMPRESSION,COMPRESSION" enum:",best-speed,default,best-compression" default:"" help:"Compress the responses for the clients accepting it (Accept-Encoding) with this level: \"best-speed\", \"default\" or \"best-compression\". The streamed responses are never compressed. Disabled by default" group:"api"`
+	TTSStreamChunkSize                 int      `env:"LOCALAI_TTS_STREAM_CHUNK_SIZE,TTS_STREAM_CHUNK_SIZE" default:"16384" help:"Default size in bytes of the chunks of the streamed TTS responses, rounded to whole audio frames. Requests can override it with chunk_size" group:"api"`
+	TTSStreamChunkDuration             string   `env:"LOCALAI_TTS_STREAM_CHUNK_DURATION,TTS_STREAM_CHUNK_DURATION" default:"0s" help:"Default duration of the chunks of the streamed TTS responses, taking precedence over their size for the wav, mp3, aac and opus audio. Requests can override it with chunk_duration. 0 uses the size" group:"api"`
+	RequestWebhook                     string   `env:"LOCALAI_REQUEST_WEBHOOK,REQUEST_WEBHOOK" help:"URL of a webhook called with each request before inference, which can allow, deny or modify it. Models can override it with request_webhook" group:"api"`
+	RequestWebhookTimeout              string   `env:"LOCALAI_REQUEST_WEBHOOK_TIMEOUT,REQUEST_WEBHOOK_TIMEOUT" default:"5s" help:"Timeout of the calls to the request webhook" group:"api"`
+	RequestWebhookFailOpen             bool     `env:"LOCALAI_REQUEST_WEBHOOK_FAIL_OPEN,REQUEST_WEBHOOK_FAIL_OPEN" help:"Let the requests through when the request webhook fails or times out, instead of rejecting them" group:"api"`
+	TLSCertFile                        string   `env:"LOCALAI_TLS_CERT_FILE,TLS_CERT_FILE" help:"Path to the TLS certificate file. When set together with tls-key-file the API is served over HTTPS" group:"api"`
+	TLSKeyFile                         string   `env:"LOCALAI_TLS_KEY_FILE,TLS_KEY_FILE" help:"Path to the TLS private key file" group:"api"`
+	HTTP2                              bool     `env:"LOCALAI_HTTP2,HTTP2" name:"http2" default:"false" help:"Serve the API over HTTP/2 (requires TLS). HTTP/1.1 clients are still supported" group:"api"`
+	HTTP3                              bool     `env:"LOCALAI_HTTP3,HTTP3" name:"http3" default:"false" help:"Experimental: additionally serve the API over HTTP/3 (QUIC) on the same UDP port (requires TLS)" group:"api"`
+	APIVersion                         int      `env:"LOCALAI_API_VERSION,API_VERSION" default:"0" help:"Version of the shape of the responses to the requests without the X-LocalAI-API-Version header: 1 is the OpenAI shape without the LocalAI extensions. 0 uses the latest" group:"api"`
+	ShutdownTimeout                    string   `env:"LOCALAI_SHUTDOWN_TIMEOUT,SHUTDOWN_TIMEOUT" default:"30s" help:"On SIGINT or SIGTERM, how long the in-flight requests are waited for before the server is stopped. A second signal stops it right away" group:"api"`
 	LoadToMemory                       []string `env:"LOCALAI_LOAD_TO_MEMORY,LOAD_TO_MEMORY" help:"A list of models to load into memory at startup" group:"models"`
 }
 
@@ -84,6 +127,7 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 		config.WithDebug(zerolog.GlobalLevel() <= zerolog.DebugLevel),
 		config.WithImageDir(r.ImagePath),
 		config.WithAudioDir(r.AudioPath),
+		config.WithVoicesDir(r.VoicesPath),
 		config.WithUploadDir(r.UploadPath),
 		config.WithConfigsDir(r.ConfigPath),
 		config.WithDynamicConfigDir(r.LocalaiConfigDir),
@@ -100,21 +144,100 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 		config.WithBackendAssetsOutput(r.BackendAssetsPath),
 		config.WithUploadLimitMB(r.UploadLimit),
 		config.WithApiKeys(r.APIKeys),
+		config.WithAdminApiKeys(r.AdminAPIKeys),
 		config.WithModelsURL(append(r.Models, r.ModelArgs...)...),
 		config.WithOpaqueErrors(r.OpaqueErrors),
 		config.WithEnforcedPredownloadScans(!r.DisablePredownloadScan),
 		config.WithSubtleKeyComparison(r.UseSubtleKeyComparison),
 		config.WithDisableApiKeyRequirementForHttpGet(r.DisableApiKeyRequirementForHttpGet),
 		config.WithHttpGetExemptedEndpoints(r.HttpGetExemptedEndpoints),
+		config.WithCapabilitiesRequireAuth(r.CapabilitiesRequireAuth),
 		config.WithP2PNetworkID(r.Peer2PeerNetworkID),
 		config.WithLoadToMemory(r.LoadToMemory),
 		config.WithMachineTag(r.MachineTag),
+		config.WithMaxImageDimension(r.MaxImageDimension),
+		config.WithMaxImageCount(r.MaxImageCount),
+		config.WithRequestLogSampleRate(r.RequestLogSampleRate),
+		config.WithRequestLogErrors(r.RequestLogErrors),
+		config.WithStreamTrailers(r.StreamTrailers),
+		config.WithStreamBufferSize(r.StreamBufferSize),
+		config.WithStreamBackpressure(r.StreamBackpressure),
+		config.WithTTSStreamChunkSize(r.TTSStreamChunkSize),
+		config.WithRequestWebhook(r.RequestWebhook),
+		config.WithRequestWebhookFailOpen(r.RequestWebhookFailOpen),
 	}
 
 	if r.DisableMetricsEndpoint {
 		opts = append(opts, config.DisableMetricsEndpoint)
 	}
 
+	if r.RejectOversizedImages {
+		opts = append(opts, config.EnableRejectOversizedImages)
+	}
+
+	if r.CacheKeyHeader {
+		opts = append(opts, config.EnableCacheKeyHeader)
+	}
+
+	if r.GenerationHeaders {
+		opts = append(opts, config.EnableGenerationHeaders)
+	}
+
+	if r.ChatTemplateMetadata {
+		opts = append(opts, config.EnableChatTemplateMetadata)
+	}
+
+	if r.GGUFChatTemplate {
+		opts = append(opts, config.EnableGGUFChatTemplate)
+	}
+
+	if r.ModelSuggestions {
+		opts = append(opts, config.EnableModelSuggestions)
+	}
+
+	if r.EmbeddingsTokenUsage {
+		opts = append(opts, config.EnableEmbeddingsTokenUsage)
+	}
+
+	if r.TLSCertFile != "" || r.TLSKeyFile != "" {
+		opts = append(opts, config.WithTLS(r.TLSCertFile, r.TLSKeyFile))
+	}
+	if r.HTTP2 {
+		opts = append(opts, config.EnableHTTP2)
+	}
+	if r.HTTP3 {
+		opts = append(opts, config.EnableHTTP3)
+	}
+	if len(r.APIKeyModels) > 0 {
+		keys, models, err := config.ParseApiKeyModels(r.APIKeyModels)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, config.WithApiKeys(append(r.APIKeys, keys...)), config.WithApiKeyModels(models))
+	}
+	if r.APIVersion != 0 {
+		if r.APIVersion < 1 || r.APIVersion > schema.LatestAPIVersion {
+			return fmt.Errorf("invalid API version %d: the supported versions are 1 to %d", r.APIVersion, schema.LatestAPIVersion)
+		}
+		opts = append(opts, config.WithDefaultAPIVersion(r.APIVersion))
+	}
+	if r.CORSAllowCredentials {
+		if origins := strings.TrimSpace(r.CORSAllowOrigins); origins == "" || origins == "*" {
+			return fmt.Errorf("--cors-allow-credentials requires a list of origins in --cors-allow-origins")
+		}
+		opts = append(opts, config.EnableCorsAllowCredentials)
+	}
+	if r.Compression != "" {
+		opts = append(opts, config.WithCompression(r.Compression))
+	}
+	if r.ShutdownTimeout != "" {
+		dur, err := time.ParseDuration(r.ShutdownTimeout)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, config.WithShutdownTimeout(dur))
+	}
+
 	token := ""
 	if r.Peer2Peer || r.Peer2PeerToken != "" {
 		log.Info().Msg("P2P mode enabled")
@@ -169,6 +292,54 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 			opts = append(opts, config.SetWatchDogBusyTimeout(dur))
 		}
 	}
+	if r.ModelLoadingWait != "" {
+		dur, err := time.ParseDuration(r.ModelLoadingWait)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, config.WithModelLoadingWait(dur))
+	}
+	if r.IdempotencyWindow != "" {
+		dur, err := time.ParseDuration(r.IdempotencyWindow)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, config.WithIdempotencyWindow(dur))
+	}
+	if r.GPUStatsInterval != "" {
+		dur, err := time.ParseDuration(r.GPUStatsInterval)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, config.WithGPUStatsInterval(dur))
+	}
+	if r.TTSStreamChunkDuration != "" {
+		dur, err := time.ParseDuration(r.TTSStreamChunkDuration)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, config.WithTTSStreamChunkDuration(dur))
+	}
+	if r.StreamHeartbeatInterval != "" {
+		dur, err := time.ParseDuration(r.StreamHeartbeatInterval)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, config.WithStreamHeartbeatInterval(dur))
+	}
+	if r.StreamLoadingProgress {
+		opts = append(opts, config.EnableStreamLoadingProgress)
+	}
+	if r.RequestWebhookTimeout != "" {
+		dur, err := time.ParseDuration(r.RequestWebhookTimeout)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, config.WithRequestWebhookTimeout(dur))
+	}
+	if r.WatchdogMemoryThreshold > 0 {
+		opts = append(opts, config.SetWatchDogMemoryThreshold(r.WatchdogMemoryThreshold))
+	}
 	if r.ParallelRequests {
 		opts = append(opts, config.EnableParallelBackendRequests)
 	}
@@ -183,6 +354,15 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 		opts = append(opts, config.WithExternalBackend(backend, uri))
 	}
 
+	for _, v := range r.EndpointPriorities {
+		endpoint, value, _ := strings.Cut(v, ":")
+		priority, err := strconv.Atoi(value)
+		if err != nil || !config.IsEndpointType(endpoint) {
+			return fmt.Errorf("invalid endpoint priority %q: expected type:priority, with type one of %s", v, strings.Join(config.EndpointTypes, ", "))
+		}
+		opts = append(opts, config.WithEndpointPriority(endpoint, priority))
+	}
+
 	if r.AutoloadGalleries {
 		opts = append(opts, config.EnableGalleriesAutoload)
 	}
@@ -203,5 +383,19 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 		return err
 	}
 
-	return appHTTP.Listen(r.Address)
+	// the server stops gracefully on SIGINT or SIGTERM, instead of exiting right away as the other commands do
+	signal.Reset(os.Interrupt, syscall.SIGTERM)
+	shutdown, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// a second signal exits right away
+		<-shutdown.Done()
+		stop()
+	}()
+
+	err = http.Listen(shutdown, appHTTP, r.Address, app.ApplicationConfig())
+	if stopErr := app.ModelLoader().StopAllGRPC(); stopErr != nil {
+		log.Error().Err(stopErr).Msg("error while stopping all grpc backends")
+	}
+	return err
 }
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ApiKey is an entry of the api_keys.json dynamic config file.
+// It can be either a plain string, granting access to all the models,
+// or an object restricting the key to the listed models:
+//
+//	["key-1", {"key": "key-2", "models": ["gpt-4", "whisper-1"]}]
+type ApiKey struct {
+	Key    string   `json:"key"`
+	Models []string `json:"models"`
+}
+
+func (k *ApiKey) UnmarshalJSON(data []byte) error {
+	var key string
+	if err := json.Unmarshal(data, &key); err == nil {
+		*k = ApiKey{Key: key}
+		return nil
+	}
+
+	type apiKey ApiKey
+	var entry apiKey
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return err
+	}
+	if entry.Key == "" {
+		return errors.New("api key entry without a key")
+	}
+	*k = ApiKey(entry)
+	return nil
+}
+
+// ParseApiKeys parses the content of the api_keys.json file, returning the keys and
+// the models allowed for the keys which are restricted to a subset of the models
+func ParseApiKeys(content []byte) ([]string, map[string][]string, error) {
+	var entries []ApiKey
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return nil, nil, err
+	}
+
+	keys := []string{}
+	models := map[string][]string{}
+	for _, e := range entries {
+		keys = append(keys, e.Key)
+		if e.Models != nil {
+			models[e.Key] = e.Models
+		}
+	}
+	return keys, models, nil
+}
+
+// ParseApiKeyModels parses the API keys restricted to a subset of the models given on the command line,
+// as "key=model1,model2" entries, returning the keys and their models
+func ParseApiKeyModels(entries []string) ([]string, map[string][]string, error) {
+	keys := []string{}
+	models := map[string][]string{}
+	for _, entry := range entries {
+		key, list, ok := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, nil, fmt.Errorf("invalid API key models %q, expected key=model1,model2", entry)
+		}
+		allowed := []string{}
+		for _, m := range strings.Split(list, ",") {
+			if m = strings.TrimSpace(m); m != "" {
+				allowed = append(allowed, m)
+			}
+		}
+		if _, exists := models[key]; !exists {
+			keys = append(keys, key)
+		}
+		models[key] = allowed
+	}
+	return keys, models, nil
+}
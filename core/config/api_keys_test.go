@@ -0,0 +1,36 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("API keys file", func() {
+	It("parses plain keys and keys restricted to a subset of the models", func() {
+		keys, models, err := ParseApiKeys([]byte(`["free", {"key": "premium", "models": ["gpt-4", "whisper-1"]}, {"key": "all"}]`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(keys).To(Equal([]string{"free", "premium", "all"}))
+		Expect(models).To(Equal(map[string][]string{"premium": {"gpt-4", "whisper-1"}}))
+	})
+
+	It("rejects entries without a key", func() {
+		_, _, err := ParseApiKeys([]byte(`[{"models": ["gpt-4"]}]`))
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("API key models flag", func() {
+	It("parses the keys restricted to a subset of the models", func() {
+		keys, models, err := ParseApiKeyModels([]string{"free=phi-2", "premium= gpt-4, whisper-1 ", "none="})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(keys).To(Equal([]string{"free", "premium", "none"}))
+		Expect(models).To(Equal(map[string][]string{"free": {"phi-2"}, "premium": {"gpt-4", "whisper-1"}, "none": {}}))
+	})
+
+	It("rejects entries without a key", func() {
+		_, _, err := ParseApiKeyModels([]string{"phi-2"})
+		Expect(err).To(HaveOccurred())
+		_, _, err = ParseApiKeyModels([]string{"=phi-2"})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -21,6 +21,7 @@ type ApplicationConfig struct {
 	Debug                               bool
 	ImageDir                            string
 	AudioDir                            string
+	VoicesDir                           string
 	UploadDir                           string
 	ConfigsDir                          string
 	DynamicConfigsDir                   string
@@ -30,7 +31,10 @@ type ApplicationConfig struct {
 	PreloadJSONModels                   string
 	PreloadModelsFromPath               string
 	CORSAllowOrigins                    string
+	CORSAllowCredentials                bool
 	ApiKeys                             []string
+	ApiKeyModels                        map[string][]string
+	AdminApiKeys                        []string
 	P2PToken                            string
 	P2PNetworkID                        string
 
@@ -39,6 +43,7 @@ type ApplicationConfig struct {
 	OpaqueErrors                       bool
 	UseSubtleKeyComparison             bool
 	DisableApiKeyRequirementForHttpGet bool
+	CapabilitiesRequireAuth            bool
 	DisableMetrics                     bool
 	HttpGetExemptedEndpoints           []*regexp.Regexp
 	DisableGalleryEndpoint             bool
@@ -65,18 +70,119 @@ type ApplicationConfig struct {
 	ModelsURL []string
 
 	WatchDogBusyTimeout, WatchDogIdleTimeout time.Duration
+	WatchDogMemoryThreshold                  float64
 
 	MachineTag string
+
+	CacheKeyHeader bool
+	// GenerationHeaders echoes the effective model, seed, temperature and top_p of the completions in the response headers
+	GenerationHeaders bool
+
+	// RequestLogSampleRate is the fraction of the requests logged (deterministic per request ID).
+	// Failed requests are always logged if RequestLogErrors is set. Models can override both
+	RequestLogSampleRate float64
+	RequestLogErrors     bool
+
+	// ModelLoadingWait is the maximum time a request waits for a model being loaded by another request,
+	// before a model_loading error is returned. 0 waits for the load to complete
+	ModelLoadingWait time.Duration
+
+	// IdempotencyWindow is how long the response of a request with an Idempotency-Key header is replayed
+	// to the requests with the same key. 0 disables the idempotency keys
+	IdempotencyWindow time.Duration
+
+	// GPUStatsInterval is the interval at which the GPUs are sampled during the requests, to report their utilization
+	// in the metadata of the responses. 0 disables the sampling
+	GPUStatsInterval time.Duration
+
+	// StreamHeartbeatInterval is the interval of the progress heartbeats sent in the streamed completions, 0 disables them
+	StreamHeartbeatInterval time.Duration
+
+	// StreamLoadingProgress sends the progress of the load of the model in the streamed completions loading it
+	StreamLoadingProgress bool
+
+	// StreamTrailers sends the usage, the timings and the finish reason of the streamed completions as HTTP trailers:
+	// "both" also sends them in the final chunk, "only" omits the final chunk for the clients accepting trailers
+	StreamTrailers string
+
+	// StreamBufferSize is the number of chunks of a stream buffered while the client reads the previous ones
+	StreamBufferSize int
+	// StreamBackpressure is what happens when the client of a stream cannot keep up and its buffer is full:
+	// "block" blocks the backend until the client reads the chunks, "drop" drops the client
+	StreamBackpressure string
+
+	// TTSStreamChunkSize and TTSStreamChunkDuration are the default size (in bytes) and duration of the chunks
+	// of the streamed TTS responses. The duration takes precedence when set
+	TTSStreamChunkSize     int
+	TTSStreamChunkDuration time.Duration
+
+	// RequestWebhook is the URL of the webhook validating the requests before inference, if any.
+	// Requests are rejected when it cannot be reached in time, unless RequestWebhookFailOpen is set.
+	// Models can override all of them
+	RequestWebhook         string
+	RequestWebhookTimeout  time.Duration
+	RequestWebhookFailOpen bool
+
+	// ChatTemplateMetadata returns the hash of the chat template in the responses and in the model list
+	ChatTemplateMetadata bool
+
+	// ModelSuggestions suggests the models with the closest names in the model_not_found errors
+	ModelSuggestions bool
+
+	// EmbeddingsTokenUsage counts the tokens of each input of the embeddings requests, returning them
+	// with the embeddings and their total in the usage
+	EmbeddingsTokenUsage bool
+
+	// EndpointPriorities are the priorities of the requests to the backends by endpoint type: when a backend does
+	// not run requests in parallel, the waiting requests with a higher priority run first
+	EndpointPriorities map[string]int
+
+	// MaxImageDimension is the maximum size (in pixels) of the longest side of input images.
+	// Bigger images are downscaled, or rejected if RejectOversizedImages is set
+	MaxImageDimension     int
+	RejectOversizedImages bool
+
+	// GGUFChatTemplate translates the chat template embedded in the GGUF files of the models without a template
+	GGUFChatTemplate bool
+
+	// MaxImageCount is the maximum number of images of the image generation requests, for the models
+	// not setting their own. 0 disables the limit
+	MaxImageCount int
+
+	// TLSCertFile and TLSKeyFile enable TLS on the API server.
+	// HTTP2 and HTTP3 require TLS to be configured
+	TLSCertFile, TLSKeyFile string
+	HTTP2                   bool
+	HTTP3                   bool
+
+	// DefaultAPIVersion is the version of the responses of the requests which do not pin one, 0 for the latest
+	DefaultAPIVersion int
+
+	// Compression is the level of the compression of the responses: "best-speed", "default" or "best-compression".
+	// The responses are not compressed when empty
+	Compression string
+
+	// ShutdownTimeout is how long the in-flight requests are waited for when the server is stopped,
+	// before their connections are closed
+	ShutdownTimeout time.Duration
 }
 
 type AppOption func(*ApplicationConfig)
 
+// DefaultUploadLimitMB is the limit of the size of the request bodies, and of the uploaded files, when none is set
+const DefaultUploadLimitMB = 15
+
 func NewApplicationConfig(o ...AppOption) *ApplicationConfig {
 	opt := &ApplicationConfig{
 		Context:       context.Background(),
-		UploadLimitMB: 15,
+		UploadLimitMB: DefaultUploadLimitMB,
 		ContextSize:   512,
 		Debug:         true,
+
+		RequestLogSampleRate: 1,
+		RequestLogErrors:     true,
+
+		EndpointPriorities: DefaultEndpointPriorities(),
 	}
 	for _, oo := range o {
 		oo(opt)
@@ -172,6 +278,17 @@ func SetWatchDogIdleTimeout(t time.Duration) AppOption {
 	}
 }
 
+// SetWatchDogMemoryThreshold sets the minimum percentage of free system or GPU memory:
+// below it, the least recently used idle backends are stopped
+func SetWatchDogMemoryThreshold(percent float64) AppOption {
+	return func(o *ApplicationConfig) {
+		if percent > 0 {
+			o.WatchDog = true
+		}
+		o.WatchDogMemoryThreshold = percent
+	}
+}
+
 var EnableSingleBackend = func(o *ApplicationConfig) {
 	o.SingleBackend = true
 }
@@ -199,6 +316,10 @@ func WithCorsAllowOrigins(b string) AppOption {
 	}
 }
 
+var EnableCorsAllowCredentials AppOption = func(o *ApplicationConfig) {
+	o.CORSAllowCredentials = true
+}
+
 func WithBackendAssetsOutput(out string) AppOption {
 	return func(o *ApplicationConfig) {
 		o.AssetsDestination = out
@@ -260,6 +381,15 @@ func WithUploadLimitMB(limit int) AppOption {
 	}
 }
 
+// EffectiveUploadLimitMB returns the limit of the size of the request bodies and of the uploaded files, in MB:
+// UploadLimitMB, or DefaultUploadLimitMB when it is not set
+func (o *ApplicationConfig) EffectiveUploadLimitMB() int {
+	if o.UploadLimitMB <= 0 {
+		return DefaultUploadLimitMB
+	}
+	return o.UploadLimitMB
+}
+
 func WithThreads(threads int) AppOption {
 	return func(o *ApplicationConfig) {
 		if threads == 0 { // 0 is not allowed
@@ -305,6 +435,12 @@ func WithUploadDir(uploadDir string) AppOption {
 	}
 }
 
+func WithVoicesDir(voicesDir string) AppOption {
+	return func(o *ApplicationConfig) {
+		o.VoicesDir = voicesDir
+	}
+}
+
 func WithConfigsDir(configsDir string) AppOption {
 	return func(o *ApplicationConfig) {
 		o.ConfigsDir = configsDir
@@ -329,6 +465,119 @@ func WithApiKeys(apiKeys []string) AppOption {
 	}
 }
 
+// WithApiKeyModels restricts the given API keys to a subset of the models
+func WithApiKeyModels(apiKeyModels map[string][]string) AppOption {
+	return func(o *ApplicationConfig) {
+		o.ApiKeyModels = apiKeyModels
+	}
+}
+
+// WithAdminApiKeys adds API keys with the admin privileges, e.g. overriding the backend of the requests
+func WithAdminApiKeys(apiKeys []string) AppOption {
+	return func(o *ApplicationConfig) {
+		o.AdminApiKeys = apiKeys
+		o.ApiKeys = append(o.ApiKeys, apiKeys...)
+	}
+}
+
+func WithRequestLogSampleRate(rate float64) AppOption {
+	return func(o *ApplicationConfig) {
+		o.RequestLogSampleRate = rate
+	}
+}
+
+func WithRequestLogErrors(b bool) AppOption {
+	return func(o *ApplicationConfig) {
+		o.RequestLogErrors = b
+	}
+}
+
+func WithModelLoadingWait(wait time.Duration) AppOption {
+	return func(o *ApplicationConfig) {
+		o.ModelLoadingWait = wait
+	}
+}
+
+func WithIdempotencyWindow(window time.Duration) AppOption {
+	return func(o *ApplicationConfig) {
+		o.IdempotencyWindow = window
+	}
+}
+
+func WithGPUStatsInterval(interval time.Duration) AppOption {
+	return func(o *ApplicationConfig) {
+		o.GPUStatsInterval = interval
+	}
+}
+
+func WithStreamHeartbeatInterval(interval time.Duration) AppOption {
+	return func(o *ApplicationConfig) {
+		o.StreamHeartbeatInterval = interval
+	}
+}
+
+var EnableStreamLoadingProgress AppOption = func(o *ApplicationConfig) {
+	o.StreamLoadingProgress = true
+}
+
+func WithStreamTrailers(mode string) AppOption {
+	return func(o *ApplicationConfig) {
+		o.StreamTrailers = mode
+	}
+}
+
+func WithStreamBufferSize(size int) AppOption {
+	return func(o *ApplicationConfig) {
+		o.StreamBufferSize = size
+	}
+}
+
+// WithEndpointPriority sets the priority of the requests of the endpoint type
+func WithEndpointPriority(endpoint string, priority int) AppOption {
+	return func(o *ApplicationConfig) {
+		if o.EndpointPriorities == nil {
+			o.EndpointPriorities = DefaultEndpointPriorities()
+		}
+		o.EndpointPriorities[endpoint] = priority
+	}
+}
+
+func WithStreamBackpressure(policy string) AppOption {
+	return func(o *ApplicationConfig) {
+		o.StreamBackpressure = policy
+	}
+}
+
+func WithTTSStreamChunkSize(size int) AppOption {
+	return func(o *ApplicationConfig) {
+		o.TTSStreamChunkSize = size
+	}
+}
+
+func WithTTSStreamChunkDuration(duration time.Duration) AppOption {
+	return func(o *ApplicationConfig) {
+		o.TTSStreamChunkDuration = duration
+	}
+}
+
+func WithRequestWebhook(url string) AppOption {
+	return func(o *ApplicationConfig) {
+		o.RequestWebhook = url
+	}
+}
+
+func WithRequestWebhookTimeout(timeout time.Duration) AppOption {
+	return func(o *ApplicationConfig) {
+		o.RequestWebhookTimeout = timeout
+	}
+}
+
+func WithRequestWebhookFailOpen(failOpen bool) AppOption {
+	return func(o *ApplicationConfig) {
+		o.RequestWebhookFailOpen = failOpen
+	}
+}
+
 func WithEnforcedPredownloadScans(enforced bool) AppOption {
 	return func(o *ApplicationConfig) {
 		o.EnforcePredownloadScans = enforced
@@ -359,6 +608,85 @@ func WithDisableApiKeyRequirementForHttpGet(required bool) AppOption {
 	}
 }
 
+func WithCapabilitiesRequireAuth(required bool) AppOption {
+	return func(o *ApplicationConfig) {
+		o.CapabilitiesRequireAuth = required
+	}
+}
+
+func WithMaxImageDimension(dim int) AppOption {
+	return func(o *ApplicationConfig) {
+		o.MaxImageDimension = dim
+	}
+}
+
+func WithMaxImageCount(count int) AppOption {
+	return func(o *ApplicationConfig) {
+		o.MaxImageCount = count
+	}
+}
+
+var EnableGGUFChatTemplate AppOption = func(o *ApplicationConfig) {
+	o.GGUFChatTemplate = true
+}
+
+var EnableRejectOversizedImages AppOption = func(o *ApplicationConfig) {
+	o.RejectOversizedImages = true
+}
+
+var EnableCacheKeyHeader AppOption = func(o *ApplicationConfig) {
+	o.CacheKeyHeader = true
+}
+
+var EnableGenerationHeaders AppOption = func(o *ApplicationConfig) {
+	o.GenerationHeaders = true
+}
+
+var EnableChatTemplateMetadata AppOption = func(o *ApplicationConfig) {
+	o.ChatTemplateMetadata = true
+}
+
+var EnableModelSuggestions AppOption = func(o *ApplicationConfig) {
+	o.ModelSuggestions = true
+}
+
+var EnableEmbeddingsTokenUsage AppOption = func(o *ApplicationConfig) {
+	o.EmbeddingsTokenUsage = true
+}
+
+func WithTLS(certFile, keyFile string) AppOption {
+	return func(o *ApplicationConfig) {
+		o.TLSCertFile = certFile
+		o.TLSKeyFile = keyFile
+	}
+}
+
+var EnableHTTP2 AppOption = func(o *ApplicationConfig) {
+	o.HTTP2 = true
+}
+
+var EnableHTTP3 AppOption = func(o *ApplicationConfig) {
+	o.HTTP3 = true
+}
+
+func WithDefaultAPIVersion(version int) AppOption {
+	return func(o *ApplicationConfig) {
+		o.DefaultAPIVersion = version
+	}
+}
+
+func WithCompression(level string) AppOption {
+	return func(o *ApplicationConfig) {
+		o.Compression = level
+	}
+}
+
+func WithShutdownTimeout(timeout time.Duration) AppOption {
+	return func(o *ApplicationConfig) {
+		o.ShutdownTimeout = timeout
+	}
+}
+
 var DisableMetricsEndpoint AppOption = func(o *ApplicationConfig) {
 	o.DisableMetrics = true
 }
@@ -389,6 +717,7 @@ func (o *ApplicationConfig) ToConfigLoaderOptions() []ConfigLoaderOption {
 		LoadOptionDebug(o.Debug),
 		LoadOptionF16(o.F16),
 		LoadOptionThreads(o.Threads),
+		LoadOptionGGUFChatTemplate(o.GGUFChatTemplate),
 		ModelPath(o.ModelPath),
 	}
 }
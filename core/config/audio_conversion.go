@@ -0,0 +1,36 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mudler/LocalAI/pkg/utils"
+)
+
+// NeedsConversion returns if an audio file of the format, detected from its content, must be converted
+// to wav before being sent to the backend. wav is nil for the other formats
+func (a AudioConversion) NeedsConversion(format string, wav *utils.WavInfo) bool {
+	if !a.Enabled {
+		return false
+	}
+	if format != utils.AudioFormatWAV {
+		return !slices.Contains(a.Formats, format)
+	}
+	// a wav file the backend cannot read as is: not 16 bits PCM, or with a different sample rate
+	return wav == nil || wav.AudioFormat != 1 || wav.BitsPerSample != 16 ||
+		(a.SampleRate > 0 && (wav.SampleRate != a.SampleRate || wav.Channels != 1))
+}
+
+func (c *BackendConfig) validateAudioConversion() error {
+	a := c.AudioConversion
+	if a.SampleRate < 0 {
+		return fmt.Errorf("audio conversion: the sample rate cannot be negative")
+	}
+	for _, f := range a.Formats {
+		if !slices.Contains(utils.AudioFormats, f) {
+			return fmt.Errorf("audio conversion: unknown format %q, known formats are %s", f, strings.Join(utils.AudioFormats, ", "))
+		}
+	}
+	return nil
+}
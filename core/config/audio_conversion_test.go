@@ -0,0 +1,37 @@
+package config
+
+import (
+	"github.com/mudler/LocalAI/pkg/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Audio conversion", func() {
+	pcm16k := &utils.WavInfo{AudioFormat: 1, Channels: 1, SampleRate: 16000, BitsPerSample: 16}
+	stereo := &utils.WavInfo{AudioFormat: 1, Channels: 2, SampleRate: 44100, BitsPerSample: 16}
+
+	It("converts the formats the backend does not read", func() {
+		Expect(AudioConversion{}.NeedsConversion(utils.AudioFormatMP3, nil)).To(BeFalse())
+
+		a := AudioConversion{Enabled: true, Formats: []string{utils.AudioFormatFLAC}}
+		Expect(a.NeedsConversion(utils.AudioFormatMP3, nil)).To(BeTrue())
+		Expect(a.NeedsConversion(utils.AudioFormatFLAC, nil)).To(BeFalse())
+		Expect(a.NeedsConversion(utils.AudioFormatWAV, pcm16k)).To(BeFalse())
+		Expect(a.NeedsConversion(utils.AudioFormatWAV, stereo)).To(BeFalse())
+		Expect(a.NeedsConversion(utils.AudioFormatWAV, &utils.WavInfo{AudioFormat: 3, Channels: 1, SampleRate: 16000, BitsPerSample: 32})).To(BeTrue())
+
+		// the wav files are resampled only when a sample rate is set
+		a.SampleRate = 16000
+		Expect(a.NeedsConversion(utils.AudioFormatWAV, pcm16k)).To(BeFalse())
+		Expect(a.NeedsConversion(utils.AudioFormatWAV, stereo)).To(BeTrue())
+	})
+
+	It("validates the formats", func() {
+		cfg := &BackendConfig{AudioConversion: AudioConversion{Enabled: true, Formats: []string{"mp3", "opus"}}}
+		Expect(cfg.validateAudioConversion()).To(MatchError(ContainSubstring(`unknown format "opus"`)))
+		cfg.AudioConversion.Formats = []string{"mp3"}
+		Expect(cfg.validateAudioConversion()).To(Succeed())
+		cfg.AudioConversion.SampleRate = -1
+		Expect(cfg.validateAudioConversion()).To(HaveOccurred())
+	})
+})
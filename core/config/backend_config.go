@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"regexp"
 	"slices"
@@ -22,6 +23,9 @@ type TTSConfig struct {
 	Voice string `yaml:"voice"`
 
 	AudioPath string `yaml:"audio_path"`
+
+	// VoiceCloning clones the voice of the reference samples sent with the requests
+	VoiceCloning VoiceCloning `yaml:"voice_cloning"`
 }
 
 type BackendConfig struct {
@@ -38,14 +42,27 @@ type BackendConfig struct {
 	KnownUsecaseStrings []string               `yaml:"known_usecases"`
 	KnownUsecases       *BackendConfigUsecases `yaml:"-"`
 
-	PromptStrings, InputStrings                []string               `yaml:"-"`
-	InputToken                                 [][]int                `yaml:"-"`
-	functionCallString, functionCallNameString string                 `yaml:"-"`
-	ResponseFormat                             string                 `yaml:"-"`
-	ResponseFormatMap                          map[string]interface{} `yaml:"-"`
+	PromptStrings, InputStrings                []string                `yaml:"-"`
+	InputToken                                 [][]int                 `yaml:"-"`
+	functionCallString, functionCallNameString string                  `yaml:"-"`
+	tokenBudget                                *schema.TokenBudget     `yaml:"-"`
+	tokenBudgetTools                           bool                    `yaml:"-"`
+	ResponseFormat                             string                  `yaml:"-"`
+	ResponseFormatMap                          map[string]interface{}  `yaml:"-"`
+	DerivedStopWords                           []string                `yaml:"-"`
+	RopeScalingInfo                            *schema.RopeScalingInfo `yaml:"-"`
+	ChatTemplate, ChatTemplateHash             string                  `yaml:"-"`
+	// TemplateSource is where the chat template of the model comes from: the configuration, the GGUF file or the model family
+	TemplateSource string `yaml:"-"`
+	// BackendOverride is set when the request overrides the backend of the model
+	BackendOverride bool `yaml:"-"`
+	// OnCPU is set on the copy of the model loaded on the CPU after the GPU ran out of memory
+	OnCPU bool `yaml:"-"`
 
 	FunctionsConfig functions.FunctionsConfig `yaml:"function"`
 
+	DefaultTools DefaultTools `yaml:"default_tools"`
+
 	FeatureFlag FeatureFlag `yaml:"feature_flags"` // Feature Flag registry. We move fast, and features may break on a per model/backend basis. Registry for (usually temporary) flags that indicate aborting something early.
 	// LLM configs (GPT4ALL, Llama.cpp, ...)
 	LLMConfig `yaml:",inline"`
@@ -57,6 +74,9 @@ type BackendConfig struct {
 	Diffusers Diffusers `yaml:"diffusers"`
 	Step      int       `yaml:"step"`
 
+	// ImageCount bounds the number of images of the image generation requests
+	ImageCount ImageCount `yaml:"image_count"`
+
 	// GRPC Options
 	GRPC GRPC `yaml:"grpc"`
 
@@ -71,8 +91,251 @@ type BackendConfig struct {
 
 	Description string `yaml:"description"`
 	Usage       string `yaml:"usage"`
+	// ErrorMessage replaces the message of the server errors of the requests to the model, e.g. when its backend
+	// fails or is unavailable. The actual error is still logged
+	ErrorMessage string `yaml:"error_message"`
+	// Examples are curated example requests, returned by the model detail endpoint and shown in the chat page
+	Examples []schema.RequestExample `yaml:"examples"`
 
 	Options []string `yaml:"options"`
+
+	PromptGuard PromptGuard `yaml:"prompt_guard"`
+
+	PromptCompression PromptCompression `yaml:"prompt_compression"`
+
+	ConversationSummary ConversationSummary `yaml:"conversation_summary"`
+
+	// ContextOverflow is what to do when the backend rejects a chat request exceeding the context size:
+	// "error" (default) returns a context_length_exceeded error, "truncate" drops the oldest messages and retries
+	ContextOverflow string `yaml:"context_overflow"`
+
+	RequestLog RequestLog `yaml:"request_log"`
+
+	RequestWebhook RequestWebhook `yaml:"request_webhook"`
+
+	// Dataset appends the completed chat requests of the consenting users to a training dataset
+	Dataset Dataset `yaml:"dataset"`
+
+	// Resources caps the CPU and memory of the backend process of the model
+	Resources Resources `yaml:"resources"`
+
+	// CPUFallback retries the requests on the CPU when the GPU runs out of memory
+	CPUFallback CPUFallback `yaml:"cpu_fallback"`
+
+	Reasoning Reasoning `yaml:"reasoning"`
+
+	TemperatureSchedule TemperatureSchedule `yaml:"temperature_schedule"`
+
+	Warmup Warmup `yaml:"warmup"`
+
+	FirstToken FirstToken `yaml:"first_token"`
+
+	JSONRepair JSONRepair `yaml:"json_repair"`
+
+	Citations Citations `yaml:"citations"`
+
+	Languages LanguageConstraints `yaml:"languages"`
+
+	// Pipeline composes other models: a request to the model runs through all the stages in order
+	Pipeline []PipelineStage `yaml:"pipeline"`
+
+	// Ensemble runs the requests to the model across several models and combines their results
+	Ensemble Ensemble `yaml:"ensemble"`
+
+	// Router routes the chat requests to the model to another model, chosen by the category of their prompt
+	Router Router `yaml:"router"`
+
+	// Passthrough forwards raw HTTP requests to endpoints of the backend
+	Passthrough []PassthroughRoute `yaml:"passthrough"`
+
+	// Transforms rewrite the JSON requests and responses of the model with jq expressions
+	Transforms []Transform `yaml:"transforms"`
+
+	// AudioConversion converts the audio files sent for transcription to a format the backend reads
+	AudioConversion AudioConversion `yaml:"audio_conversion"`
+	// TranscriptionConfidence are the thresholds of the low confidence transcription segments
+	TranscriptionConfidence TranscriptionConfidence `yaml:"transcription_confidence"`
+}
+
+// AudioConversion converts the uploaded audio files, whose format is detected from their content,
+// to 16 bits mono wav before they are transcribed
+type AudioConversion struct {
+	Enabled bool `yaml:"enabled"`
+	// Formats are the formats other than wav the backend reads directly, and are not converted
+	Formats []string `yaml:"formats"`
+	// SampleRate is the sample rate of the converted files (default 16000). When set, the wav files
+	// with a different sample rate or more than one channel are converted as well
+	SampleRate int `yaml:"sample_rate"`
+}
+
+// Warmup is an inference run right after the model is loaded, so that the first request
+// is not slowed down by the lazy initialization of the backend (e.g. kernel compilation)
+type Warmup struct {
+	Prompt string `yaml:"prompt"`
+	// Tokens is the number of tokens to generate (default: 1)
+	Tokens int `yaml:"tokens"`
+	// Blocking runs the warmup before returning the loaded model, instead of in the background
+	Blocking bool `yaml:"blocking"`
+}
+
+// FirstToken trades the throughput of the model for a lower time to first token of the new requests
+type FirstToken struct {
+	// Priority processes the prompts of the new requests before generating the next tokens of the ongoing ones,
+	// which wait meanwhile (llama.cpp)
+	Priority bool `yaml:"priority"`
+}
+
+// JSONRepair configures the repair of malformed outputs when JSON is requested
+// with the response_format (json_object or json_schema)
+type JSONRepair struct {
+	Enabled bool `yaml:"enabled"`
+	// Retries is the number of times the inference is run again when the output is not valid JSON even after the repair
+	Retries int `yaml:"retries"`
+	// OnFailure is what happens when the output is still not valid JSON: "return" (default) returns it as is, "error" fails the request
+	OnFailure string `yaml:"on_failure"`
+}
+
+// Citations configures the parsing of the citations of the sources (e.g. retrieved documents) from the output of the model.
+// The citation markup is stripped from the content and the citations are returned separately
+type Citations struct {
+	Enabled bool `yaml:"enabled"`
+	// Format of the citations: "brackets" (default) cites the sentence preceding markers like [1] or [doc-1, doc-2],
+	// "tags" cites the text enclosed in <cite source="doc-1">...</cite>
+	Format string `yaml:"format"`
+	// SourcePattern is a regular expression the source IDs must match, e.g. "^[0-9]+$".
+	// Bracket markers with other IDs are left in the content
+	SourcePattern string `yaml:"source_pattern"`
+}
+
+// LanguageConstraints declares the languages (ISO 639-1 codes) expected in the inputs and outputs of the model
+type LanguageConstraints struct {
+	Input  []string `yaml:"input"`
+	Output []string `yaml:"output"`
+	// Mode is the enforcement: "warn" (default) logs off-language inputs and outputs,
+	// "reject" also rejects off-language inputs, "instruct" instructs the model to answer in the output languages
+	Mode string `yaml:"mode"`
+	// Response configures the response language forced by the requests
+	Response ResponseLanguage `yaml:"response"`
+}
+
+// Reasoning configures how the reasoning of reasoning models is separated from the final answer.
+// The reasoning is returned in the reasoning_content field of the messages instead of the content
+type Reasoning struct {
+	Enabled bool `yaml:"enabled"`
+
+	// StartTag and EndTag delimit the reasoning in the model output (default: <think> and </think>)
+	StartTag string `yaml:"start_tag"`
+	EndTag   string `yaml:"end_tag"`
+
+	// StartsOpen is set when the chat template already opens the reasoning in the prompt,
+	// so that the output starts with the reasoning and only the end tag is emitted
+	StartsOpen bool `yaml:"starts_open"`
+
+	// Effort is the default reasoning effort of the requests not setting reasoning_effort: low, medium or high
+	Effort string `yaml:"effort"`
+	// Efforts overrides the settings of the reasoning efforts
+	Efforts map[string]ReasoningEffort `yaml:"efforts"`
+}
+
+// Tags returns the reasoning delimiters, applying the defaults
+func (r Reasoning) Tags() (string, string) {
+	start, end := r.StartTag, r.EndTag
+	if start == "" {
+		start = "<think>"
+	}
+	if end == "" {
+		end = "</think>"
+	}
+	return start, end
+}
+
+// PromptGuard is the configuration of the (opt-in) prompt injection detection
+// applied to the user content before running inference
+type PromptGuard struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Action to take when an injection is detected: "warn" (default) only logs it,
+	// "tag" also flags the response metadata, "block" rejects the request
+	Action string `yaml:"action"`
+
+	// Patterns replaces the built-in regex ruleset
+	Patterns []string `yaml:"patterns"`
+	// ExtraPatterns are appended to the built-in (or configured) ruleset
+	ExtraPatterns []string `yaml:"extra_patterns"`
+
+	// ClassifierModel is a model used to classify the user content in addition to the ruleset.
+	// The content is flagged if the classifier output contains ClassifierLabel (defaults to "injection")
+	ClassifierModel string `yaml:"classifier_model"`
+	ClassifierLabel string `yaml:"classifier_label"`
+}
+
+// Dataset appends the completed chat requests and their answers to JSONL files, e.g. to fine-tune the model on
+// the production traffic. Only the requests setting the store field, by which the user consents, are recorded
+type Dataset struct {
+	Enabled bool `yaml:"enabled"`
+	// Path is the directory of the dataset files, relative to the models path (default: datasets/<model name>)
+	Path string `yaml:"path"`
+	// Fields are the fields of the records: messages (the conversation with the answer, always recorded),
+	// tools, model, created and usage
+	Fields []string `yaml:"fields"`
+	// Redact are regular expressions whose matches are replaced in the recorded texts, in addition to the
+	// built-in rules (e-mail addresses, card numbers, phone numbers and API keys)
+	Redact []string `yaml:"redact"`
+	// MaxSizeMB is the size above which the files are rotated (default 100). They are also rotated daily
+	MaxSizeMB int `yaml:"max_size_mb"`
+}
+
+// RequestWebhook overrides the global request validation webhook for the model. Unset fields use the global settings
+type RequestWebhook struct {
+	URL string `yaml:"url"`
+	// Timeout of the calls to the webhook, e.g. "2s"
+	Timeout string `yaml:"timeout"`
+	// FailOpen lets the requests through when the webhook fails or times out, instead of rejecting them
+	FailOpen *bool `yaml:"fail_open"`
+	// Disabled skips the global webhook for the model
+	Disabled bool `yaml:"disabled"`
+}
+
+// RequestLog overrides the global sampling of the request logs for the model. Unset fields use the global settings
+type RequestLog struct {
+	// SampleRate is the fraction of the requests logged, between 0 and 1
+	SampleRate *float64 `yaml:"sample_rate"`
+	// AlwaysLogErrors logs all the failed requests (status >= 400) regardless of the sample rate
+	AlwaysLogErrors *bool `yaml:"always_log_errors"`
+}
+
+// PromptCompression configures the (lossy) compression of long prompts before inference.
+// The system messages are never compressed
+type PromptCompression struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Method is "redundancy" (default), which removes repeated sentences and extra whitespace,
+	// or "model", which asks Model to compress the messages
+	Method string `yaml:"method"`
+	Model  string `yaml:"model"`
+	// Prompt is the instruction given to the compression model, followed by the text to compress
+	Prompt string `yaml:"prompt"`
+
+	// Threshold is the estimated size (in tokens) of the messages above which they are compressed.
+	// It defaults to 3/4 of the context size: without a context size, the messages are always compressed
+	Threshold int `yaml:"threshold"`
+}
+
+// ConversationSummary configures the summarization of the older messages of long conversations, which are
+// replaced by their summary so that the conversation fits the context. The system messages are never summarized
+type ConversationSummary struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Model is the model writing the summaries (default: the model itself)
+	Model string `yaml:"model"`
+	// Prompt is the instruction given to the summarizer model, followed by the conversation
+	Prompt string `yaml:"prompt"`
+
+	// Threshold is the estimated size (in tokens) of the messages above which the conversation is summarized.
+	// It defaults to 3/4 of the context size: without a context size, the conversations are always summarized
+	Threshold int `yaml:"threshold"`
+	// KeepMessages is the number of recent messages kept verbatim (default 4)
+	KeepMessages *int `yaml:"keep_messages"`
 }
 
 type File struct {
@@ -109,8 +372,8 @@ type Diffusers struct {
 // generic for most of the LLM backends.
 type LLMConfig struct {
 	SystemPrompt    string   `yaml:"system_prompt"`
-	TensorSplit     string   `yaml:"tensor_split"`
-	MainGPU         string   `yaml:"main_gpu"`
+	TensorSplit     string   `yaml:"tensor_split"` // Ratios of the model put on each GPU, e.g. "3,1", or "auto" to split it by free memory
+	MainGPU         string   `yaml:"main_gpu"`     // Index of the GPU holding the intermediate results and the small tensors, or a device name
 	RMSNormEps      float32  `yaml:"rms_norm_eps"`
 	NGQA            int32    `yaml:"ngqa"`
 	PromptCachePath string   `yaml:"prompt_cache_path"`
@@ -125,11 +388,18 @@ type LLMConfig struct {
 	LowVRAM         *bool    `yaml:"low_vram"`
 	Grammar         string   `yaml:"grammar"`
 	StopWords       []string `yaml:"stopwords"`
+	AutoStopWords   *bool    `yaml:"auto_stopwords"` // derive stop words from the template turn delimiters (default: true)
+	StopNewlines    int      `yaml:"stop_newlines"`  // stop after this number of consecutive newlines, e.g. 2 stops at the first blank line
 	Cutstrings      []string `yaml:"cutstrings"`
 	ExtractRegex    []string `yaml:"extract_regex"`
 	TrimSpace       []string `yaml:"trimspace"`
 	TrimSuffix      []string `yaml:"trimsuffix"`
 
+	// RepetitionStop stops the generation when the output repeats the same phrase in a loop
+	RepetitionStop RepetitionStop `yaml:"repetition_stop"`
+	// OutputEncoding fixes the invalid UTF-8 and the mojibake of the output
+	OutputEncoding OutputEncoding `yaml:"output_encoding"`
+
 	ContextSize          *int      `yaml:"context_size"`
 	NUMA                 bool      `yaml:"numa"`
 	LoraAdapter          string    `yaml:"lora_adapter"`
@@ -158,6 +428,11 @@ type LLMConfig struct {
 	RopeScaling string `yaml:"rope_scaling"`
 	ModelType   string `yaml:"type"`
 
+	// AutoRopeScaling sets the rope frequency scale from the ratio between the context size and
+	// the context size the model was trained with (TrainedContextSize, or read from the GGUF file)
+	AutoRopeScaling    bool `yaml:"auto_rope_scaling"`
+	TrainedContextSize int  `yaml:"trained_context_size"`
+
 	YarnExtFactor  float32 `yaml:"yarn_ext_factor"`
 	YarnAttnFactor float32 `yaml:"yarn_attn_factor"`
 	YarnBetaFast   float32 `yaml:"yarn_beta_fast"`
@@ -224,6 +499,18 @@ func (c *BackendConfig) SetFunctionCallNameString(s string) {
 	c.functionCallNameString = s
 }
 
+// SetTokenBudget sets the token budget of the request. tools is set when the output is expected to be
+// tool calls, whose arguments are counted against their own budget
+func (c *BackendConfig) SetTokenBudget(budget *schema.TokenBudget, tools bool) {
+	c.tokenBudget = budget
+	c.tokenBudgetTools = tools
+}
+
+// TokenBudget returns the token budget of the request, if any, and whether tool calls are expected
+func (c *BackendConfig) TokenBudget() (*schema.TokenBudget, bool) {
+	return c.tokenBudget, c.tokenBudgetTools
+}
+
 func (c *BackendConfig) ShouldUseFunctions() bool {
 	return ((c.functionCallString != "none" || c.functionCallString == "") || c.ShouldCallSpecificFunction())
 }
@@ -399,10 +686,23 @@ func (cfg *BackendConfig) SetDefaults(opts ...ConfigLoaderOption) {
 		cfg.Debug = &trueV
 	}
 
-	guessDefaultsFromFile(cfg, lo.modelPath)
+	if cfg.HasTemplate() {
+		cfg.TemplateSource = TemplateSourceConfig
+	}
+	guessDefaultsFromFile(cfg, lo.modelPath, lo.ggufChatTemplate)
+
+	cfg.setDerivedStopWords(lo.modelPath)
+	cfg.setRopeScaling(lo.modelPath)
+	cfg.setChatTemplateHash(lo.modelPath)
 }
 
+// Validate returns whether the configuration is valid, see ValidateErr
 func (c *BackendConfig) Validate() bool {
+	return c.ValidateErr() == nil
+}
+
+// ValidateErr returns why the configuration cannot be loaded, or nil if it is valid
+func (c *BackendConfig) ValidateErr() error {
 	downloadedFileNames := []string{}
 	for _, f := range c.DownloadFiles {
 		downloadedFileNames = append(downloadedFileNames, f.Filename)
@@ -416,17 +716,34 @@ func (c *BackendConfig) Validate() bool {
 		}
 		if strings.HasPrefix(n, string(os.PathSeparator)) ||
 			strings.Contains(n, "..") {
-			return false
+			return fmt.Errorf("%q must be a relative path within the models path", n)
+		}
+	}
+
+	for _, validate := range []func() error{
+		c.validatePipeline, c.validateEnsemble, c.validateTemperatureSchedule,
+		c.validateCitations, c.validatePassthrough, c.validateConversationSummary,
+		c.validateAudioConversion, c.validateRequestWebhook, c.validateDataset,
+		c.validateGPUSplit, c.validateResources, c.validateRouter, c.validateImageCount,
+		c.validateRepetitionStop, c.validateReasoningEffort,
+		c.validateOutputEncoding, c.validateVoiceCloning, c.validateResponseLanguage,
+		c.validateTranscriptionConfidence, c.validateCPUFallback,
+		c.validateTransforms, c.validateDefaultTools,
+	} {
+		if err := validate(); err != nil {
+			return err
 		}
 	}
 
 	if c.Backend != "" {
 		// a regex that checks that is a string name with no special characters, except '-' and '_'
 		re := regexp.MustCompile(`^[a-zA-Z0-9-_]+$`)
-		return re.MatchString(c.Backend)
+		if !re.MatchString(c.Backend) {
+			return fmt.Errorf("invalid backend %q: only letters, digits, '-' and '_' are allowed", c.Backend)
+		}
 	}
 
-	return true
+	return nil
 }
 
 func (c *BackendConfig) HasTemplate() bool {
@@ -490,12 +807,22 @@ func (c *BackendConfig) HasUsecases(u BackendConfigUsecases) bool {
 	return c.GuessUsecases(u)
 }
 
+// JSONOutputRequested returns whether the request asked for a JSON output with the response_format
+func (c *BackendConfig) JSONOutputRequested() bool {
+	if c.ResponseFormatMap == nil {
+		return false
+	}
+	t, _ := c.ResponseFormatMap["type"].(string)
+	return t == "json_object" || t == "json_schema"
+}
+
 // GuessUsecases is a **heuristic based** function, as the backend in question may not be loaded yet, and the config may not record what it's useful at.
 // In its current state, this function should ideally check for properties of the config like templates, rather than the direct backend name checks for the lower half.
 // This avoids the maintenance burden of updating this list for each new backend - but unfortunately, that's the best option for some services currently.
 func (c *BackendConfig) GuessUsecases(u BackendConfigUsecases) bool {
 	if (u & FLAG_CHAT) == FLAG_CHAT {
-		if c.TemplateConfig.Chat == "" && c.TemplateConfig.ChatMessage == "" {
+		if c.TemplateConfig.Chat == "" && c.TemplateConfig.ChatMessage == "" && len(c.Pipeline) == 0 && len(c.Router.Routes) == 0 &&
+			(len(c.Ensemble.Members) == 0 || c.Ensemble.EnsembleStrategy(EnsembleStrategyVote) != EnsembleStrategyVote) {
 			return false
 		}
 	}
@@ -510,7 +837,7 @@ func (c *BackendConfig) GuessUsecases(u BackendConfigUsecases) bool {
 		}
 	}
 	if (u & FLAG_EMBEDDINGS) == FLAG_EMBEDDINGS {
-		if c.Embeddings == nil || !*c.Embeddings {
+		if (c.Embeddings == nil || !*c.Embeddings) && c.Ensemble.Strategy != EnsembleStrategyAverage {
 			return false
 		}
 	}
@@ -36,6 +36,7 @@ type LoadOptions struct {
 	debug            bool
 	threads, ctxSize int
 	f16              bool
+	ggufChatTemplate bool
 }
 
 func LoadOptionDebug(debug bool) ConfigLoaderOption {
@@ -68,6 +69,13 @@ func LoadOptionF16(f16 bool) ConfigLoaderOption {
 	}
 }
 
+// LoadOptionGGUFChatTemplate uses the chat template embedded in the GGUF files of the models without a template
+func LoadOptionGGUFChatTemplate(enabled bool) ConfigLoaderOption {
+	return func(o *LoadOptions) {
+		o.ggufChatTemplate = enabled
+	}
+}
+
 type ConfigLoaderOption func(*LoadOptions)
 
 func (lo *LoadOptions) Apply(options ...ConfigLoaderOption) {
@@ -155,9 +163,11 @@ func (bcl *BackendConfigLoader) LoadMultipleBackendConfigsSingleFile(file string
 	}
 
 	for _, cc := range c {
-		if cc.Validate() {
-			bcl.configs[cc.Name] = *cc
+		if err := cc.ValidateErr(); err != nil {
+			log.Error().Err(err).Str("model", cc.Name).Msgf("config is not valid: %s", file)
+			continue
 		}
+		bcl.configs[cc.Name] = *cc
 	}
 	return nil
 }
@@ -170,11 +180,10 @@ func (bcl *BackendConfigLoader) LoadBackendConfig(file string, opts ...ConfigLoa
 		return fmt.Errorf("cannot read config file: %w", err)
 	}
 
-	if c.Validate() {
-		bcl.configs[c.Name] = *c
-	} else {
-		return fmt.Errorf("config is not valid")
+	if err := c.ValidateErr(); err != nil {
+		return fmt.Errorf("config %q is not valid: %w", c.Name, err)
 	}
+	bcl.configs[c.Name] = *c
 
 	return nil
 }
@@ -347,11 +356,11 @@ func (bcl *BackendConfigLoader) LoadBackendConfigsFromPath(path string, opts ...
 			log.Error().Err(err).Msgf("cannot read config file: %s", file.Name())
 			continue
 		}
-		if c.Validate() {
-			bcl.configs[c.Name] = *c
-		} else {
-			log.Error().Err(err).Msgf("config is not valid")
+		if err := c.ValidateErr(); err != nil {
+			log.Error().Err(err).Str("model", c.Name).Msgf("config is not valid: %s", file.Name())
+			continue
 		}
+		bcl.configs[c.Name] = *c
 	}
 
 	return nil
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"os"
 
+	"github.com/mudler/LocalAI/core/schema"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -65,6 +66,21 @@ parameters:
 			Expect(config.Name).To(Equal("hermes-2-pro-mistral"))
 			Expect(config.Validate()).To(BeTrue())
 		})
+		It("returns why a config is not valid", func() {
+			dir := GinkgoT().TempDir()
+			file := dir + "/foo.yaml"
+			Expect(os.WriteFile(file, []byte(`name: foo
+transcription_confidence:
+  logprob_threshold: 1
+`), 0600)).To(Succeed())
+
+			bcl := NewBackendConfigLoader(dir)
+			err := bcl.LoadBackendConfig(file)
+			Expect(err).To(MatchError(ContainSubstring(`config "foo" is not valid: transcription_confidence: logprob_threshold cannot be positive`)))
+
+			Expect((&BackendConfig{Backend: "foo bar"}).ValidateErr()).To(MatchError(ContainSubstring("invalid backend")))
+			Expect((&BackendConfig{PredictionOptions: schema.PredictionOptions{Model: "../foo"}}).ValidateErr()).To(HaveOccurred())
+		})
 	})
 	It("Properly handles backend usecase matching", func() {
 
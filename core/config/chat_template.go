@@ -0,0 +1,39 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// setChatTemplateHash records the templates used by the chat endpoint and their SHA-256,
+// so that clients can detect when a template change altered the behavior of the model
+func (cfg *BackendConfig) setChatTemplateHash(modelPath string) {
+	parts := []string{}
+	for _, t := range []struct{ name, template string }{
+		{"chat", cfg.TemplateConfig.Chat},
+		{"chat_message", cfg.TemplateConfig.ChatMessage},
+		{"function", cfg.TemplateConfig.Functions},
+		{"multimodal", cfg.TemplateConfig.Multimodal},
+	} {
+		if t.template == "" {
+			continue
+		}
+		parts = append(parts, t.name+":\n"+templateContent(t.template, modelPath))
+	}
+	if len(parts) > 0 && cfg.TemplateConfig.JinjaTemplate {
+		parts = append(parts, "jinja_template")
+	}
+	if cfg.TemplateConfig.UseTokenizerTemplate {
+		// the template is applied by the backend, only its use can be tracked
+		parts = append(parts, "use_tokenizer_template")
+	}
+	if len(parts) == 0 {
+		cfg.ChatTemplate, cfg.ChatTemplateHash = "", ""
+		return
+	}
+
+	cfg.ChatTemplate = strings.Join(parts, "\n")
+	sum := sha256.Sum256([]byte(cfg.ChatTemplate))
+	cfg.ChatTemplateHash = hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Chat template hash", func() {
+	It("changes with the content of the template files", func() {
+		dir, err := os.MkdirTemp("", "")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		Expect(os.WriteFile(filepath.Join(dir, "chatml.tmpl"), []byte("<|im_start|>{{.RoleName}}"), 0600)).To(Succeed())
+		cfg := &BackendConfig{TemplateConfig: TemplateConfig{ChatMessage: "chatml"}}
+		cfg.setChatTemplateHash(dir)
+		Expect(cfg.ChatTemplate).To(ContainSubstring("<|im_start|>{{.RoleName}}"))
+		Expect(cfg.ChatTemplateHash).To(HaveLen(64))
+		hash := cfg.ChatTemplateHash
+
+		cfg.setChatTemplateHash(dir)
+		Expect(cfg.ChatTemplateHash).To(Equal(hash))
+
+		Expect(os.WriteFile(filepath.Join(dir, "chatml.tmpl"), []byte("<|im_start|>{{.RoleName}}\n"), 0600)).To(Succeed())
+		cfg.setChatTemplateHash(dir)
+		Expect(cfg.ChatTemplateHash).ToNot(Equal(hash))
+	})
+
+	It("is empty without chat templates", func() {
+		cfg := &BackendConfig{TemplateConfig: TemplateConfig{Completion: "{{.Input}}"}}
+		cfg.setChatTemplateHash("")
+		Expect(cfg.ChatTemplateHash).To(BeEmpty())
+	})
+})
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const (
+	CitationFormatBrackets = "brackets"
+	CitationFormatTags     = "tags"
+)
+
+// CitationFormat returns the configured format, defaulting to brackets
+func (c Citations) CitationFormat() string {
+	if c.Format == "" {
+		return CitationFormatBrackets
+	}
+	return c.Format
+}
+
+func (c *BackendConfig) validateCitations() error {
+	if f := c.Citations.CitationFormat(); f != CitationFormatBrackets && f != CitationFormatTags {
+		return fmt.Errorf("unknown citation format %q", f)
+	}
+	if c.Citations.SourcePattern != "" {
+		if _, err := regexp.Compile(c.Citations.SourcePattern); err != nil {
+			return fmt.Errorf("invalid citation source pattern: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Citations", func() {
+	It("validates the configuration", func() {
+		Expect((&BackendConfig{Citations: Citations{Enabled: true}}).Validate()).To(BeTrue())
+		Expect((&BackendConfig{Citations: Citations{Format: CitationFormatTags, SourcePattern: "^doc-[0-9]+$"}}).Validate()).To(BeTrue())
+		Expect((&BackendConfig{Citations: Citations{Format: "footnotes"}}).Validate()).To(BeFalse())
+		Expect((&BackendConfig{Citations: Citations{SourcePattern: "[0-9"}}).Validate()).To(BeFalse())
+	})
+})
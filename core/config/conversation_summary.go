@@ -0,0 +1,24 @@
+package config
+
+import "fmt"
+
+const defaultSummaryKeepMessages = 4
+
+// KeptMessages returns the number of recent messages kept verbatim
+func (c ConversationSummary) KeptMessages() int {
+	if c.KeepMessages == nil {
+		return defaultSummaryKeepMessages
+	}
+	return *c.KeepMessages
+}
+
+func (c *BackendConfig) validateConversationSummary() error {
+	s := c.ConversationSummary
+	if s.Threshold < 0 {
+		return fmt.Errorf("conversation summary: the threshold cannot be negative")
+	}
+	if s.KeptMessages() < 0 {
+		return fmt.Errorf("conversation summary: keep_messages cannot be negative")
+	}
+	return nil
+}
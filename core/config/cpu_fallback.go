@@ -0,0 +1,33 @@
+package config
+
+import "fmt"
+
+// CPUFallback runs the text generation requests to the model again on the CPU when the GPU runs out of memory,
+// loading a copy of the model without GPU offloading. The requests succeed, slowly, during the VRAM spikes
+type CPUFallback struct {
+	Enabled bool `yaml:"enabled"`
+	// Backend is the backend of the copy of the model on the CPU, the backend of the model by default
+	Backend string `yaml:"backend"`
+}
+
+// CPUConfig returns the configuration of the copy of the model loaded on the CPU
+func (c BackendConfig) CPUConfig() BackendConfig {
+	cpu := c
+	noGPULayers := 0
+	cpu.NGPULayers = &noGPULayers
+	cpu.CUDA, cpu.Diffusers.CUDA = false, false
+	cpu.TensorSplit, cpu.MainGPU = "", ""
+	cpu.NoKVOffloading = true
+	if c.CPUFallback.Backend != "" {
+		cpu.Backend = c.CPUFallback.Backend
+	}
+	cpu.OnCPU = true
+	return cpu
+}
+
+func (c *BackendConfig) validateCPUFallback() error {
+	if c.CPUFallback.Backend != "" && !c.CPUFallback.Enabled {
+		return fmt.Errorf("cpu_fallback: backend is set, but the fallback is not enabled")
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CPU fallback", func() {
+	It("loads the copy of the model without offloading to the GPU", func() {
+		gpuLayers := 99
+		c := BackendConfig{Backend: "vllm", LLMConfig: LLMConfig{NGPULayers: &gpuLayers, TensorSplit: "3,1", MainGPU: "0"}, CUDA: true}
+		c.CPUFallback = CPUFallback{Enabled: true, Backend: "llama-cpp"}
+
+		cpu := c.CPUConfig()
+		Expect(*cpu.NGPULayers).To(BeZero())
+		Expect(cpu.CUDA).To(BeFalse())
+		Expect(cpu.TensorSplit).To(BeEmpty())
+		Expect(cpu.MainGPU).To(BeEmpty())
+		Expect(cpu.NoKVOffloading).To(BeTrue())
+		Expect(cpu.Backend).To(Equal("llama-cpp"))
+		Expect(cpu.OnCPU).To(BeTrue())
+		// the configuration of the model is left as is
+		Expect(*c.NGPULayers).To(Equal(99))
+		Expect(c.Backend).To(Equal("vllm"))
+		Expect(c.OnCPU).To(BeFalse())
+	})
+
+	It("rejects a backend when the fallback is not enabled", func() {
+		c := BackendConfig{CPUFallback: CPUFallback{Backend: "llama-cpp"}}
+		Expect(c.validateCPUFallback()).To(HaveOccurred())
+		c.CPUFallback.Enabled = true
+		Expect(c.validateCPUFallback()).To(Succeed())
+	})
+})
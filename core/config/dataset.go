@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const defaultDatasetMaxSizeMB = 100
+
+// DatasetFields are the fields the dataset records can have
+var DatasetFields = []string{"messages", "tools", "model", "created", "usage"}
+
+// DatasetDir returns the directory of the dataset files of the model
+func (c *BackendConfig) DatasetDir(modelPath string) string {
+	if c.Dataset.Path != "" {
+		return filepath.Join(modelPath, c.Dataset.Path)
+	}
+	return filepath.Join(modelPath, "datasets", c.Name)
+}
+
+// MaxSize returns the size in bytes above which the dataset files are rotated
+func (d Dataset) MaxSize() int64 {
+	if d.MaxSizeMB <= 0 {
+		return defaultDatasetMaxSizeMB << 20
+	}
+	return int64(d.MaxSizeMB) << 20
+}
+
+// HasField returns whether the records have the field
+func (d Dataset) HasField(field string) bool {
+	return field == "messages" || slices.Contains(d.Fields, field)
+}
+
+func (c *BackendConfig) validateDataset() error {
+	d := c.Dataset
+	if d.Path != "" && (filepath.IsAbs(d.Path) || strings.Contains(d.Path, "..")) {
+		return fmt.Errorf("dataset: the path must be relative to the models path")
+	}
+	for _, f := range d.Fields {
+		if !slices.Contains(DatasetFields, f) {
+			return fmt.Errorf("dataset: unknown field %q, known fields are %s", f, strings.Join(DatasetFields, ", "))
+		}
+	}
+	for _, r := range d.Redact {
+		if _, err := regexp.Compile(r); err != nil {
+			return fmt.Errorf("dataset: invalid redaction rule %q: %w", r, err)
+		}
+	}
+	if d.MaxSizeMB < 0 {
+		return fmt.Errorf("dataset: the maximum size cannot be negative")
+	}
+	return nil
+}
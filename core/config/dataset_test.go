@@ -0,0 +1,35 @@
+package config
+
+import (
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Dataset", func() {
+	It("defaults to a directory per model", func() {
+		cfg := &BackendConfig{Name: "assistant"}
+		Expect(cfg.DatasetDir("/models")).To(Equal(filepath.Join("/models", "datasets", "assistant")))
+		cfg.Dataset.Path = "training/assistant"
+		Expect(cfg.DatasetDir("/models")).To(Equal(filepath.Join("/models", "training", "assistant")))
+		Expect(cfg.Dataset.MaxSize()).To(Equal(int64(100 << 20)))
+		Expect(cfg.Dataset.HasField("messages")).To(BeTrue())
+		Expect(cfg.Dataset.HasField("usage")).To(BeFalse())
+	})
+
+	It("validates the settings", func() {
+		cfg := &BackendConfig{Dataset: Dataset{Enabled: true, Path: "../outside"}}
+		Expect(cfg.validateDataset()).To(HaveOccurred())
+		cfg.Dataset.Path = "/tmp/datasets"
+		Expect(cfg.validateDataset()).To(HaveOccurred())
+		cfg.Dataset.Path = ""
+		cfg.Dataset.Fields = []string{"model", "ip"}
+		Expect(cfg.validateDataset()).To(MatchError(ContainSubstring(`unknown field "ip"`)))
+		cfg.Dataset.Fields = []string{"model"}
+		cfg.Dataset.Redact = []string{"("}
+		Expect(cfg.validateDataset()).To(HaveOccurred())
+		cfg.Dataset.Redact = []string{"ACME-[0-9]+"}
+		Expect(cfg.validateDataset()).To(Succeed())
+	})
+})
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/mudler/LocalAI/pkg/functions"
+)
+
+const (
+	// DefaultToolsMerge adds the default tools missing from the requests, the tools of the requests
+	// overriding the default ones with the same name
+	DefaultToolsMerge = "merge"
+	// DefaultToolsReplace adds the default tools to the requests without tools only
+	DefaultToolsReplace = "replace"
+)
+
+// toolNameRegex matches the names of the functions accepted by the OpenAI API
+var toolNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// DefaultTools are the tools the chat requests to the model have without sending their definitions, e.g. for the
+// assistants which always have the same tools
+type DefaultTools struct {
+	Tools []functions.Tool `yaml:"tools"`
+	// Mode is how they are merged with the tools of the requests: "merge" (default) or "replace"
+	Mode string `yaml:"mode"`
+}
+
+// ToolsFor returns the default tools added to a request with the functions, in order
+func (c *BackendConfig) ToolsFor(requested functions.Functions) []functions.Tool {
+	if c.DefaultTools.Mode == DefaultToolsReplace && len(requested) > 0 {
+		return nil
+	}
+	tools := []functions.Tool{}
+	for _, t := range c.DefaultTools.Tools {
+		if !slices.ContainsFunc(requested, func(f functions.Function) bool { return f.Name == t.Function.Name }) {
+			tools = append(tools, t)
+		}
+	}
+	return tools
+}
+
+func (c *BackendConfig) validateDefaultTools() error {
+	switch c.DefaultTools.Mode {
+	case "", DefaultToolsMerge, DefaultToolsReplace:
+	default:
+		return fmt.Errorf("default tools: invalid mode %q, expected %q or %q", c.DefaultTools.Mode, DefaultToolsMerge, DefaultToolsReplace)
+	}
+	names := map[string]bool{}
+	for _, t := range c.DefaultTools.Tools {
+		if t.Type != "function" {
+			return fmt.Errorf("default tools: unsupported tool type %q", t.Type)
+		}
+		name := t.Function.Name
+		if !toolNameRegex.MatchString(name) {
+			return fmt.Errorf("default tools: invalid function name %q", name)
+		}
+		if names[name] {
+			return fmt.Errorf("default tools: duplicate function %q", name)
+		}
+		names[name] = true
+		// the parameters are a JSON schema of the object of the arguments
+		if t.Function.Parameters != nil {
+			if typ, _ := t.Function.Parameters["type"].(string); typ != "object" {
+				return fmt.Errorf("default tools: the parameters of %q must be a JSON schema of type object", name)
+			}
+			if properties, exists := t.Function.Parameters["properties"]; exists {
+				if _, ok := properties.(map[string]interface{}); !ok {
+					return fmt.Errorf("default tools: the properties of the parameters of %q must be an object", name)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+var _ = Describe("Default tools", func() {
+	parse := func(s string) BackendConfig {
+		c := BackendConfig{}
+		Expect(yaml.Unmarshal([]byte(s), &c)).To(Succeed())
+		return c
+	}
+
+	It("loads the tools and validates their schemas", func() {
+		c := parse(`
+default_tools:
+  tools:
+  - type: function
+    function:
+      name: get_weather
+      description: Get the weather of a city
+      parameters:
+        type: object
+        properties:
+          city:
+            type: string
+        required: [city]
+`)
+		Expect(c.DefaultTools.Tools).To(HaveLen(1))
+		Expect(c.DefaultTools.Tools[0].Function.Name).To(Equal("get_weather"))
+		Expect(c.DefaultTools.Tools[0].Function.Parameters["properties"]).To(HaveKey("city"))
+		Expect(c.validateDefaultTools()).To(Succeed())
+	})
+
+	It("rejects the invalid tools", func() {
+		for _, s := range []string{
+			"default_tools:\n  mode: append",
+			"default_tools:\n  tools:\n  - type: code_interpreter\n    function:\n      name: run",
+			"default_tools:\n  tools:\n  - type: function\n    function:\n      name: get weather",
+			"default_tools:\n  tools:\n  - type: function\n    function:\n      name: a\n  - type: function\n    function:\n      name: a",
+			"default_tools:\n  tools:\n  - type: function\n    function:\n      name: a\n      parameters:\n        type: string",
+			"default_tools:\n  tools:\n  - type: function\n    function:\n      name: a\n      parameters:\n        type: object\n        properties: [city]",
+		} {
+			c := parse(s)
+			Expect(c.validateDefaultTools()).To(HaveOccurred(), s)
+		}
+	})
+})
@@ -0,0 +1,41 @@
+package config
+
+import "slices"
+
+// Endpoint types the priorities of the requests are configured for
+const (
+	EndpointChat       = "chat"
+	EndpointCompletion = "completion"
+	EndpointEdit       = "edit"
+	EndpointEmbeddings = "embeddings"
+	// EndpointBatch is the type of the requests replayed from a log
+	EndpointBatch = "batch"
+)
+
+// EndpointTypes are the endpoint types whose priority can be configured
+var EndpointTypes = []string{EndpointChat, EndpointCompletion, EndpointEdit, EndpointEmbeddings, EndpointBatch}
+
+// DefaultEndpointPriorities returns the default priorities by endpoint type: the interactive endpoints run
+// before the embeddings and the batches. The requests of the other endpoints have priority 0
+func DefaultEndpointPriorities() map[string]int {
+	return map[string]int{
+		EndpointChat:       10,
+		EndpointCompletion: 10,
+		EndpointEdit:       10,
+		EndpointEmbeddings: 0,
+		EndpointBatch:      0,
+	}
+}
+
+// IsEndpointType returns whether the priority of the endpoint type can be configured
+func IsEndpointType(endpoint string) bool {
+	return slices.Contains(EndpointTypes, endpoint)
+}
+
+// EndpointPriority returns the priority of the requests of the endpoint type
+func (o *ApplicationConfig) EndpointPriority(endpoint string) int {
+	if o.EndpointPriorities == nil {
+		return DefaultEndpointPriorities()[endpoint]
+	}
+	return o.EndpointPriorities[endpoint]
+}
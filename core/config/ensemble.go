@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+)
+
+const (
+	// EnsembleStrategyVote returns the chat output given by most members
+	EnsembleStrategyVote = "vote"
+	// EnsembleStrategyAverage returns the average of the embeddings of the members
+	EnsembleStrategyAverage = "average"
+)
+
+// Ensemble runs the requests to the model across several member models and combines their results
+type Ensemble struct {
+	Members []string `yaml:"members"`
+	// Strategy is "vote" (default for chat requests) or "average" (default for embeddings requests)
+	Strategy string `yaml:"strategy"`
+	// MinMembers is the number of members that must succeed for the request to succeed (default 1).
+	// The failed members are left out of the combination
+	MinMembers int `yaml:"min_members"`
+}
+
+// EnsembleStrategy returns the strategy of the ensemble, applying the default for the request type
+func (e Ensemble) EnsembleStrategy(defaultStrategy string) string {
+	if e.Strategy == "" {
+		return defaultStrategy
+	}
+	return e.Strategy
+}
+
+func (c *BackendConfig) validateEnsemble() error {
+	e := c.Ensemble
+	for i, m := range e.Members {
+		if m == "" {
+			return fmt.Errorf("ensemble member %d: no model set", i)
+		}
+		if m == c.Name {
+			return fmt.Errorf("ensemble member %d: the ensemble cannot run itself", i)
+		}
+	}
+	if e.Strategy != "" && e.Strategy != EnsembleStrategyVote && e.Strategy != EnsembleStrategyAverage {
+		return fmt.Errorf("unknown ensemble strategy %q", e.Strategy)
+	}
+	if e.MinMembers < 0 || e.MinMembers > len(e.Members) {
+		return fmt.Errorf("ensemble min_members must be between 0 and the number of members")
+	}
+	return nil
+}
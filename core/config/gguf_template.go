@@ -0,0 +1,188 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nikolalohinski/gonja/v2"
+	jinjaconfig "github.com/nikolalohinski/gonja/v2/config"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+)
+
+// Sources of the chat template of a model
+const (
+	// TemplateSourceConfig is a template set in the model configuration
+	TemplateSourceConfig = "config"
+	// TemplateSourceGGUF is a template translated from the Jinja chat template embedded in the GGUF file
+	TemplateSourceGGUF = "gguf"
+	// TemplateSourceGuessed is the default template of the model family, guessed from the GGUF file
+	TemplateSourceGuessed = "guessed"
+)
+
+// the placeholders of the messages of the conversations rendered to translate a Jinja chat template
+const (
+	jinjaSystem    = "LOCALAI-SYSTEM-MESSAGE"
+	jinjaUser      = "LOCALAI-USER-MESSAGE"
+	jinjaAssistant = "LOCALAI-ASSISTANT-MESSAGE"
+	jinjaNextUser  = "LOCALAI-NEXT-USER-MESSAGE"
+)
+
+// jinjaChatTemplate renders a Jinja chat template, as the HuggingFace tokenizers do
+type jinjaChatTemplate struct {
+	template *exec.Template
+	eosToken string
+}
+
+func newJinjaChatTemplate(source, eosToken string) (*jinjaChatTemplate, error) {
+	cfg := jinjaconfig.New()
+	cfg.TrimBlocks = true
+	cfg.LeftStripBlocks = true
+	loader, err := loaders.NewFileSystemLoader("")
+	if err != nil {
+		return nil, err
+	}
+	shifted, err := loaders.NewShiftedLoader("chat_template", bytes.NewReader([]byte(source)), loader)
+	if err != nil {
+		return nil, err
+	}
+	template, err := exec.NewTemplate("chat_template", cfg, shifted, gonja.DefaultEnvironment)
+	if err != nil {
+		return nil, err
+	}
+	return &jinjaChatTemplate{template: template, eosToken: eosToken}, nil
+}
+
+// render renders the conversation of alternated roles and contents
+func (t *jinjaChatTemplate) render(generationPrompt bool, roleContents ...string) (string, error) {
+	messages := []map[string]interface{}{}
+	for i := 0; i+1 < len(roleContents); i += 2 {
+		messages = append(messages, map[string]interface{}{"role": roleContents[i], "content": roleContents[i+1]})
+	}
+	return t.template.ExecuteToString(exec.NewContext(map[string]interface{}{
+		"messages":              messages,
+		"add_generation_prompt": generationPrompt,
+		// the backends add the BOS token themselves
+		"bos_token": "",
+		"eos_token": t.eosToken,
+		"raise_exception": func(message string) (string, error) {
+			return "", errors.New(message)
+		},
+	}))
+}
+
+// TranslateJinjaChatTemplate translates a Jinja chat template to the LocalAI templates. The template is rendered
+// with a few conversations to find the text around the messages of each role, so only the templates wrapping every
+// message with the same text for its role (e.g. ChatML, Llama 3, Gemma, Phi 3) can be translated
+func TranslateJinjaChatTemplate(source, eosToken string) (TemplateConfig, error) {
+	t, err := newJinjaChatTemplate(source, eosToken)
+	if err != nil {
+		return TemplateConfig{}, fmt.Errorf("invalid Jinja template: %w", err)
+	}
+
+	// the user message alone: the text before it holds the default system prompt of some models
+	user, err := t.render(false, "user", jinjaUser)
+	if err != nil {
+		return TemplateConfig{}, err
+	}
+	head, userSuffix, ok := strings.Cut(user, jinjaUser)
+	if !ok {
+		return TemplateConfig{}, errors.New("the template does not render the messages")
+	}
+	prompted, err := t.render(true, "user", jinjaUser)
+	if err != nil {
+		return TemplateConfig{}, err
+	}
+	generationPrompt, ok := strings.CutPrefix(prompted, user)
+	if !ok {
+		return TemplateConfig{}, errors.New("the generation prompt is not appended to the conversation")
+	}
+
+	conversation, err := t.render(false, "user", jinjaUser, "assistant", jinjaAssistant, "user", jinjaNextUser)
+	if err != nil {
+		return TemplateConfig{}, err
+	}
+	parts, err := splitRendered(conversation, jinjaUser, jinjaAssistant, jinjaNextUser)
+	if err != nil {
+		return TemplateConfig{}, err
+	}
+	assistantPrefix, ok := strings.CutPrefix(parts[1], userSuffix)
+	if !ok {
+		return TemplateConfig{}, errors.New("the user messages are not rendered the same way")
+	}
+	// the text after the assistant message is its suffix followed by the prefix of the next user message,
+	// which ends the text before the first user message too. The turns usually end the same way for all
+	// the roles (e.g. with <|im_end|>), which splits them when the prefix alone is ambiguous
+	assistantSuffix, userPrefix := cutCommonSuffix(parts[2], head)
+	if rest, found := strings.CutPrefix(parts[2], userSuffix); found && strings.HasSuffix(head, rest) {
+		assistantSuffix, userPrefix = userSuffix, rest
+	}
+	preamble := strings.TrimSuffix(head, userPrefix)
+
+	system, err := t.render(false, "system", jinjaSystem, "user", jinjaUser)
+	if err != nil {
+		return TemplateConfig{}, fmt.Errorf("the system messages are not supported: %w", err)
+	}
+	parts, err = splitRendered(system, jinjaSystem, jinjaUser)
+	if err != nil {
+		return TemplateConfig{}, err
+	}
+	systemPrefix := parts[0]
+	systemSuffix, ok := strings.CutSuffix(parts[1], userPrefix)
+	if !ok {
+		return TemplateConfig{}, errors.New("the user messages are not rendered the same way after a system message")
+	}
+
+	tc := TemplateConfig{
+		ChatMessage: fmt.Sprintf(`{{if and (eq .MessageIndex 0) (ne .RoleName "system")}}{{%s}}{{end}}`+
+			`{{if eq .RoleName "system"}}{{%s}}{{.Content}}{{%s}}`+
+			`{{else if eq .RoleName "assistant"}}{{%s}}{{.Content}}{{%s}}`+
+			`{{else}}{{%s}}{{.Content}}{{%s}}{{end}}`,
+			strconv.Quote(preamble),
+			strconv.Quote(systemPrefix), strconv.Quote(systemSuffix),
+			strconv.Quote(assistantPrefix), strconv.Quote(assistantSuffix),
+			strconv.Quote(userPrefix), strconv.Quote(userSuffix)),
+		Chat:                        fmt.Sprintf("{{.Input}}{{%s}}", strconv.Quote(generationPrompt)),
+		JoinChatMessagesByCharacter: new(string),
+	}
+
+	// the translation must render a whole conversation as the Jinja template does
+	expected, err := t.render(true, "system", jinjaSystem, "user", jinjaUser, "assistant", jinjaAssistant, "user", jinjaNextUser)
+	if err != nil {
+		return TemplateConfig{}, err
+	}
+	translated := systemPrefix + jinjaSystem + systemSuffix +
+		userPrefix + jinjaUser + userSuffix +
+		assistantPrefix + jinjaAssistant + assistantSuffix +
+		userPrefix + jinjaNextUser + userSuffix + generationPrompt
+	if translated != expected {
+		return TemplateConfig{}, errors.New("the messages are not rendered the same way in every position of the conversation")
+	}
+	return tc, nil
+}
+
+// splitRendered splits a rendered conversation around the placeholders of its messages, in order
+func splitRendered(rendered string, placeholders ...string) ([]string, error) {
+	parts := []string{}
+	for _, p := range placeholders {
+		before, after, ok := strings.Cut(rendered, p)
+		if !ok {
+			return nil, errors.New("the template does not render all the messages")
+		}
+		parts = append(parts, before)
+		rendered = after
+	}
+	return append(parts, rendered), nil
+}
+
+// cutCommonSuffix splits s in its beginning and its longest end which is also the end of other
+func cutCommonSuffix(s, other string) (string, string) {
+	i := 0
+	for !strings.HasSuffix(other, s[i:]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
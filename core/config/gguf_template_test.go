@@ -0,0 +1,77 @@
+package config
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// renderTranslated renders the conversation of alternated roles and contents with the translated templates
+func renderTranslated(tc TemplateConfig, roleContents ...string) string {
+	message := template.Must(template.New("").Parse(tc.ChatMessage))
+	var messages []string
+	for i := 0; i+1 < len(roleContents); i += 2 {
+		var buf bytes.Buffer
+		Expect(message.Execute(&buf, map[string]interface{}{
+			"RoleName": roleContents[i], "Content": roleContents[i+1], "MessageIndex": i / 2,
+		})).To(Succeed())
+		messages = append(messages, buf.String())
+	}
+	var buf bytes.Buffer
+	Expect(template.Must(template.New("").Parse(tc.Chat)).Execute(&buf, map[string]interface{}{
+		"Input": strings.Join(messages, *tc.JoinChatMessagesByCharacter),
+	})).To(Succeed())
+	return buf.String()
+}
+
+var _ = Describe("GGUF chat template translation", func() {
+	const (
+		chatML = `{% for message in messages %}{{'<|im_start|>' + message['role'] + '\n' + message['content'] + '<|im_end|>' + '\n'}}{% endfor %}{% if add_generation_prompt %}{{ '<|im_start|>assistant\n' }}{% endif %}`
+		llama3 = `{% set loop_messages = messages %}{% for message in loop_messages %}{% set content = '<|start_header_id|>' + message['role'] + '<|end_header_id|>\n\n' + (message['content'] | trim) + '<|eot_id|>' %}{% if loop.index0 == 0 %}{% set content = bos_token + content %}{% endif %}{{ content }}{% endfor %}{% if add_generation_prompt %}{{ '<|start_header_id|>assistant<|end_header_id|>\n\n' }}{% endif %}`
+		qwen25 = `{%- for message in messages %}{%- if loop.first and message.role != 'system' %}{{- '<|im_start|>system\nYou are a helpful assistant.<|im_end|>\n' }}{%- endif %}{{- '<|im_start|>' + message.role + '\n' + message.content + '<|im_end|>\n' }}{%- endfor %}{%- if add_generation_prompt %}{{- '<|im_start|>assistant\n' }}{%- endif %}`
+		gemma  = `{{ bos_token }}{% if messages[0]['role'] == 'system' %}{{ raise_exception('System role not supported') }}{% endif %}{% for message in messages %}{% if (message['role'] == 'assistant') %}{% set role = 'model' %}{% else %}{% set role = message['role'] %}{% endif %}{{ '<start_of_turn>' + role + '\n' + message['content'] | trim + '<end_of_turn>\n' }}{% endfor %}{% if add_generation_prompt %}{{'<start_of_turn>model\n'}}{% endif %}`
+		llama2 = `{% if messages[0]['role'] == 'system' %}{% set loop_messages = messages[1:] %}{% set system_message = messages[0]['content'] %}{% else %}{% set loop_messages = messages %}{% set system_message = false %}{% endif %}{% for message in loop_messages %}{% if loop.index0 == 0 and system_message != false %}{% set content = '<<SYS>>\n' + system_message + '\n<</SYS>>\n\n' + message['content'] %}{% else %}{% set content = message['content'] %}{% endif %}{% if message['role'] == 'user' %}{{ bos_token + '[INST] ' + content | trim + ' [/INST]' }}{% elif message['role'] == 'assistant' %}{{ ' '  + content | trim + ' ' + eos_token }}{% endif %}{% endfor %}`
+	)
+
+	It("translates the ChatML templates", func() {
+		tc, err := TranslateJinjaChatTemplate(chatML, "<|im_end|>")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(renderTranslated(tc, "system", "Be brief.", "user", "Hi", "assistant", "Hello!", "user", "How are you?")).To(Equal(
+			"<|im_start|>system\nBe brief.<|im_end|>\n<|im_start|>user\nHi<|im_end|>\n<|im_start|>assistant\nHello!<|im_end|>\n<|im_start|>user\nHow are you?<|im_end|>\n<|im_start|>assistant\n"))
+	})
+
+	It("translates the Llama 3 templates", func() {
+		tc, err := TranslateJinjaChatTemplate(llama3, "<|eot_id|>")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(renderTranslated(tc, "user", "Hi")).To(Equal(
+			"<|start_header_id|>user<|end_header_id|>\n\nHi<|eot_id|><|start_header_id|>assistant<|end_header_id|>\n\n"))
+	})
+
+	It("keeps the default system prompt of the template", func() {
+		tc, err := TranslateJinjaChatTemplate(qwen25, "<|im_end|>")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(renderTranslated(tc, "user", "Hi")).To(Equal(
+			"<|im_start|>system\nYou are a helpful assistant.<|im_end|>\n<|im_start|>user\nHi<|im_end|>\n<|im_start|>assistant\n"))
+		Expect(renderTranslated(tc, "system", "Be brief.", "user", "Hi")).To(Equal(
+			"<|im_start|>system\nBe brief.<|im_end|>\n<|im_start|>user\nHi<|im_end|>\n<|im_start|>assistant\n"))
+	})
+
+	It("fails for the templates raising on the system messages", func() {
+		_, err := TranslateJinjaChatTemplate(gemma, "<eos>")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("System role not supported"))
+	})
+
+	It("fails for the templates rendering the messages differently depending on their position", func() {
+		_, err := TranslateJinjaChatTemplate(llama2, "</s>")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("fails for the invalid templates", func() {
+		_, err := TranslateJinjaChatTemplate("{% for message in messages %}", "")
+		Expect(err).To(HaveOccurred())
+	})
+})
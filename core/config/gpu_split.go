@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TensorSplitAuto splits the model across the GPUs proportionally to their free memory
+const TensorSplitAuto = "auto"
+
+// allGPULayers is the default of gpu_layers, offloading all the layers
+const allGPULayers = 99999999
+
+// ParseTensorSplit parses the comma separated ratios of a tensor split, e.g. "3,1"
+func ParseTensorSplit(s string) ([]float64, error) {
+	ratios := []float64{}
+	total := 0.0
+	for _, r := range strings.Split(s, ",") {
+		ratio, err := strconv.ParseFloat(strings.TrimSpace(r), 64)
+		if err != nil || ratio < 0 {
+			return nil, fmt.Errorf("invalid tensor split ratio %q", r)
+		}
+		ratios = append(ratios, ratio)
+		total += ratio
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("the tensor split must have a positive ratio")
+	}
+	return ratios, nil
+}
+
+// MainGPUIndex returns the index of the main GPU, or -1 if it is not set or is the name of a device
+// (e.g. "cuda.0" for the transformers backend), which is passed to the backend as is
+func (c *BackendConfig) MainGPUIndex() (int, error) {
+	i, err := strconv.Atoi(strings.TrimSpace(c.MainGPU))
+	if err != nil {
+		return -1, nil
+	}
+	if i < 0 {
+		return -1, fmt.Errorf("main_gpu cannot be negative, got %d", i)
+	}
+	return i, nil
+}
+
+// validateGPUSplit checks the GPU settings. They are checked against the GPUs of the host when the model is loaded
+func (c *BackendConfig) validateGPUSplit() error {
+	if c.TensorSplit != "" && c.TensorSplit != TensorSplitAuto {
+		if _, err := ParseTensorSplit(c.TensorSplit); err != nil {
+			return fmt.Errorf("tensor_split: %w", err)
+		}
+	}
+	_, err := c.MainGPUIndex()
+	return err
+}
+
+// OffloadsAllLayers returns whether all the layers of the model are offloaded to the GPUs, as gpu_layers does not
+// limit them
+func (c *BackendConfig) OffloadsAllLayers() bool {
+	return c.NGPULayers == nil || *c.NGPULayers >= allGPULayers
+}
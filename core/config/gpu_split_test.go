@@ -0,0 +1,36 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GPU split", func() {
+	It("parses the tensor split ratios", func() {
+		Expect(ParseTensorSplit("3, 1")).To(Equal([]float64{3, 1}))
+		_, err := ParseTensorSplit("3,-1")
+		Expect(err).To(HaveOccurred())
+		_, err = ParseTensorSplit("0,0")
+		Expect(err).To(HaveOccurred())
+		_, err = ParseTensorSplit("half")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("validates the GPU settings", func() {
+		cfg := &BackendConfig{}
+		Expect(cfg.validateGPUSplit()).To(Succeed())
+		cfg.TensorSplit = TensorSplitAuto
+		cfg.MainGPU = "1"
+		Expect(cfg.validateGPUSplit()).To(Succeed())
+		Expect(cfg.MainGPUIndex()).To(Equal(1))
+		cfg.TensorSplit = "1,x"
+		Expect(cfg.validateGPUSplit()).To(MatchError(ContainSubstring("tensor_split")))
+		cfg.TensorSplit = "1,1"
+		cfg.MainGPU = "-1"
+		Expect(cfg.validateGPUSplit()).To(MatchError(ContainSubstring("main_gpu")))
+		// device names are passed to the backend as is
+		cfg.MainGPU = "cuda.0"
+		Expect(cfg.validateGPUSplit()).To(Succeed())
+		Expect(cfg.MainGPUIndex()).To(Equal(-1))
+	})
+})
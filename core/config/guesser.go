@@ -142,7 +142,7 @@ var knownTemplates = map[string]familyType{
 	`{{ bos_token }}{% for message in messages %}{% if (message['role'] == 'user') != (loop.index0 % 2 == 0) %}{{ raise_exception('Conversation roles must alternate user/assistant/user/assistant/...') }}{% endif %}{% if message['role'] == 'user' %}{{ '[INST] ' + message['content'] + ' [/INST]' }}{% elif message['role'] == 'assistant' %}{{ message['content'] + eos_token}}{% else %}{{ raise_exception('Only user and assistant roles are supported!') }}{% endif %}{% endfor %}`: Mistral03,
 }
 
-func guessDefaultsFromFile(cfg *BackendConfig, modelPath string) {
+func guessDefaultsFromFile(cfg *BackendConfig, modelPath string, ggufChatTemplate bool) {
 
 	if os.Getenv("LOCALAI_DISABLE_GUESSING") == "true" {
 		log.Debug().Msgf("guessDefaultsFromFile: %s", "guessing disabled with LOCALAI_DISABLE_GUESSING")
@@ -179,6 +179,11 @@ func guessDefaultsFromFile(cfg *BackendConfig, modelPath string) {
 		cfg.Name = f.Model().Name
 	}
 
+	// the chat template embedded in the model file, when it can be translated
+	if ggufChatTemplate && setGGUFChatTemplate(cfg, f) {
+		return
+	}
+
 	family := identifyFamily(f)
 
 	if family == Unknown {
@@ -190,6 +195,7 @@ func guessDefaultsFromFile(cfg *BackendConfig, modelPath string) {
 	settings, ok := defaultsSettings[family]
 	if ok {
 		cfg.TemplateConfig = settings.TemplateConfig
+		cfg.TemplateSource = TemplateSourceGuessed
 		log.Debug().Any("family", family).Msgf("guessDefaultsFromFile: guessed template %+v", cfg.TemplateConfig)
 		if len(cfg.StopWords) == 0 {
 			cfg.StopWords = settings.StopWords
@@ -211,7 +217,42 @@ func guessDefaultsFromFile(cfg *BackendConfig, modelPath string) {
 		// try to use the jinja template
 		cfg.TemplateConfig.JinjaTemplate = true
 		cfg.TemplateConfig.ChatMessage = chatTemplate.ValueString()
+		cfg.TemplateSource = TemplateSourceGGUF
+	}
+}
+
+// setGGUFChatTemplate translates the Jinja chat template of the GGUF file to the template of the model,
+// returning whether it was set. When it cannot be translated, the default template of the model family is used
+func setGGUFChatTemplate(cfg *BackendConfig, f *gguf.GGUFFile) bool {
+	chatTemplate, found := f.Header.MetadataKV.Get("tokenizer.chat_template")
+	if !found || chatTemplate.ValueString() == "" {
+		log.Debug().Any("name", cfg.Name).Msg("the GGUF file has no chat template")
+		return false
+	}
+	tc, err := TranslateJinjaChatTemplate(chatTemplate.ValueString(), ggufEOSToken(f))
+	if err != nil {
+		log.Warn().Err(err).Any("name", cfg.Name).Msg("the chat template of the GGUF file cannot be translated, using the default template of the model family")
+		return false
+	}
+	cfg.TemplateConfig = tc
+	cfg.TemplateSource = TemplateSourceGGUF
+	log.Debug().Any("name", cfg.Name).Msgf("guessDefaultsFromFile: translated the GGUF chat template %+v", cfg.TemplateConfig)
+	return true
+}
+
+// ggufEOSToken returns the text of the end of sequence token of the model, used by some chat templates
+func ggufEOSToken(f *gguf.GGUFFile) string {
+	id := f.Tokenizer().EOSTokenID
+	tokens, found := f.Header.MetadataKV.Get("tokenizer.ggml.tokens")
+	if !found || id < 0 {
+		return ""
+	}
+	arr := tokens.ValueArray()
+	if arr.Type != gguf.GGUFMetadataValueTypeString || id >= int64(len(arr.Array)) {
+		return ""
 	}
+	token, _ := arr.Array[id].(string)
+	return token
 }
 
 func identifyFamily(f *gguf.GGUFFile) familyType {
@@ -0,0 +1,31 @@
+package config
+
+import "fmt"
+
+// ImageCount bounds the number of images (n) of the image generation requests to the model
+type ImageCount struct {
+	// Min is the minimum number of images, 1 if not set
+	Min int `yaml:"min"`
+	// Max is the maximum number of images, the global maximum if not set
+	Max int `yaml:"max"`
+}
+
+// ImageCountBounds returns the number of images allowed for the model, with the global maximum as default. A maximum of 0 is not limited
+func (c *BackendConfig) ImageCountBounds(globalMax int) (int, int) {
+	minCount, maxCount := max(c.ImageCount.Min, 1), c.ImageCount.Max
+	if maxCount == 0 {
+		maxCount = globalMax
+	}
+	return minCount, maxCount
+}
+
+func (c *BackendConfig) validateImageCount() error {
+	count := c.ImageCount
+	if count.Min < 0 || count.Max < 0 {
+		return fmt.Errorf("image_count: min and max cannot be negative")
+	}
+	if count.Max > 0 && count.Min > count.Max {
+		return fmt.Errorf("image_count: min (%d) cannot be greater than max (%d)", count.Min, count.Max)
+	}
+	return nil
+}
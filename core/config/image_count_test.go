@@ -0,0 +1,32 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ImageCount", func() {
+	It("defaults to one image and the global maximum", func() {
+		cfg := &BackendConfig{}
+		minCount, maxCount := cfg.ImageCountBounds(10)
+		Expect(minCount).To(Equal(1))
+		Expect(maxCount).To(Equal(10))
+
+		cfg.ImageCount = ImageCount{Min: 2, Max: 20}
+		minCount, maxCount = cfg.ImageCountBounds(10)
+		Expect(minCount).To(Equal(2))
+		Expect(maxCount).To(Equal(20))
+	})
+
+	It("validates the bounds", func() {
+		cfg := &BackendConfig{ImageCount: ImageCount{Min: 4, Max: 4}}
+		Expect(cfg.validateImageCount()).To(Succeed())
+		cfg.ImageCount = ImageCount{Min: 4}
+		Expect(cfg.validateImageCount()).To(Succeed())
+
+		cfg.ImageCount = ImageCount{Min: 5, Max: 4}
+		Expect(cfg.validateImageCount()).To(MatchError(ContainSubstring("cannot be greater than max")))
+		cfg.ImageCount = ImageCount{Max: -1}
+		Expect(cfg.validateImageCount()).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,33 @@
+package config
+
+import "fmt"
+
+// What happens to the invalid UTF-8 sequences of the model output
+const (
+	// InvalidUTF8Replace replaces the invalid sequences with the replacement character U+FFFD
+	InvalidUTF8Replace = "replace"
+	// InvalidUTF8Strip removes the invalid sequences
+	InvalidUTF8Strip = "strip"
+)
+
+// OutputEncoding fixes the encoding of the output of the backends mishandling the multibyte characters (opt-in)
+type OutputEncoding struct {
+	// InvalidUTF8 is what happens to the invalid UTF-8 sequences: "replace" or "strip". Disabled if empty
+	InvalidUTF8 string `yaml:"invalid_utf8"`
+	// Mojibake fixes the UTF-8 text decoded as Windows-1252, e.g. "cafÃ©" for "café"
+	Mojibake bool `yaml:"mojibake"`
+}
+
+// Enabled returns whether the encoding of the output is fixed
+func (e OutputEncoding) Enabled() bool {
+	return e.InvalidUTF8 != "" || e.Mojibake
+}
+
+func (c *BackendConfig) validateOutputEncoding() error {
+	switch c.OutputEncoding.InvalidUTF8 {
+	case "", InvalidUTF8Replace, InvalidUTF8Strip:
+		return nil
+	default:
+		return fmt.Errorf("output_encoding: invalid_utf8 must be %s or %s", InvalidUTF8Replace, InvalidUTF8Strip)
+	}
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const defaultPassthroughTimeout = 60 * time.Second
+
+// PassthroughRoute forwards the requests to a path of the model, under /v1/passthrough/<model>, to an HTTP
+// endpoint of its backend, for the features of the backend LocalAI does not expose
+type PassthroughRoute struct {
+	// Path is the path of the route, e.g. "/control/reset". A path ending with "/*" forwards all its subpaths
+	Path string `yaml:"path"`
+	// Target is the URL the requests are forwarded to. The subpaths of wildcard routes are appended to it
+	Target string `yaml:"target"`
+	// Methods are the HTTP methods allowed (default GET)
+	Methods []string `yaml:"methods"`
+	// Timeout of the forwarded requests (default 60s)
+	Timeout string `yaml:"timeout"`
+	// APIKey is sent to the target as a bearer token, for the targets requiring one. APIKeyEnv is the name of
+	// the environment variable holding it instead, to keep it out of the configuration file
+	APIKey    string `yaml:"api_key" json:"-"`
+	APIKeyEnv string `yaml:"api_key_env"`
+	// ForwardAuthorization forwards the Authorization header of the request to the target. It carries the
+	// LocalAI API key of the client, so it is meant for the targets sharing the API keys of LocalAI
+	ForwardAuthorization bool `yaml:"forward_authorization"`
+}
+
+// Authorization returns the Authorization header sent to the target, given the one of the request.
+// It is empty when the target gets no credentials
+func (r PassthroughRoute) Authorization(requested string) string {
+	key := r.APIKey
+	if r.APIKeyEnv != "" {
+		key = os.Getenv(r.APIKeyEnv)
+	}
+	if key != "" {
+		return "Bearer " + key
+	}
+	if r.ForwardAuthorization {
+		return requested
+	}
+	return ""
+}
+
+// AllowedMethods returns the methods allowed on the route
+func (r PassthroughRoute) AllowedMethods() []string {
+	if len(r.Methods) == 0 {
+		return []string{http.MethodGet}
+	}
+	methods := []string{}
+	for _, m := range r.Methods {
+		methods = append(methods, strings.ToUpper(m))
+	}
+	return methods
+}
+
+// RequestTimeout returns the timeout of the forwarded requests
+func (r PassthroughRoute) RequestTimeout() time.Duration {
+	if d, err := time.ParseDuration(r.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return defaultPassthroughTimeout
+}
+
+// PassthroughTarget returns the passthrough route matching the path and the URL the request is forwarded to.
+// Exact routes take precedence over wildcard ones, and longer wildcard prefixes over shorter ones
+func (c *BackendConfig) PassthroughTarget(path string) (*PassthroughRoute, string, bool) {
+	var match *PassthroughRoute
+	target := ""
+	for i := range c.Passthrough {
+		r := &c.Passthrough[i]
+		prefix, wildcard := strings.CutSuffix(r.Path, "/*")
+		switch {
+		case !wildcard && r.Path == path:
+			return r, r.Target, true
+		case wildcard && (path == prefix || strings.HasPrefix(path, prefix+"/")):
+			if match == nil || len(prefix) > len(strings.TrimSuffix(match.Path, "/*")) {
+				match = r
+				target = strings.TrimSuffix(r.Target, "/") + strings.TrimPrefix(path, prefix)
+			}
+		}
+	}
+	return match, target, match != nil
+}
+
+func (c *BackendConfig) validatePassthrough() error {
+	for i, r := range c.Passthrough {
+		if !strings.HasPrefix(r.Path, "/") || strings.Contains(r.Path, "..") {
+			return fmt.Errorf("passthrough route %d: the path must start with / and cannot contain ..", i)
+		}
+		u, err := url.Parse(r.Target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("passthrough route %d: the target must be an http or https URL", i)
+		}
+		for _, m := range r.AllowedMethods() {
+			if !slices.Contains([]string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions}, m) {
+				return fmt.Errorf("passthrough route %d: unknown method %q", i, m)
+			}
+		}
+		if r.Timeout != "" {
+			if _, err := time.ParseDuration(r.Timeout); err != nil {
+				return fmt.Errorf("passthrough route %d: invalid timeout: %w", i, err)
+			}
+		}
+		credentials := 0
+		for _, set := range []bool{r.APIKey != "", r.APIKeyEnv != "", r.ForwardAuthorization} {
+			if set {
+				credentials++
+			}
+		}
+		if credentials > 1 {
+			return fmt.Errorf("passthrough route %d: only one of api_key, api_key_env and forward_authorization can be set", i)
+		}
+	}
+	return nil
+}
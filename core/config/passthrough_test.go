@@ -0,0 +1,62 @@
+package config
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Passthrough", func() {
+	cfg := &BackendConfig{Passthrough: []PassthroughRoute{
+		{Path: "/control/*", Target: "http://127.0.0.1:9000/api/", Methods: []string{"get", "post"}},
+		{Path: "/control/reset", Target: "http://127.0.0.1:9000/reset", Methods: []string{"POST"}, Timeout: "5s"},
+		{Path: "/control/slots/*", Target: "http://127.0.0.1:9001/slots"},
+	}}
+
+	It("matches the routes", func() {
+		route, target, ok := cfg.PassthroughTarget("/control/reset")
+		Expect(ok).To(BeTrue())
+		Expect(target).To(Equal("http://127.0.0.1:9000/reset"))
+		Expect(route.AllowedMethods()).To(Equal([]string{"POST"}))
+		Expect(route.RequestTimeout()).To(Equal(5 * time.Second))
+
+		route, target, ok = cfg.PassthroughTarget("/control/status/1")
+		Expect(ok).To(BeTrue())
+		Expect(target).To(Equal("http://127.0.0.1:9000/api/status/1"))
+		Expect(route.AllowedMethods()).To(Equal([]string{"GET", "POST"}))
+		Expect(route.RequestTimeout()).To(Equal(60 * time.Second))
+
+		// the longest prefix wins
+		route, target, ok = cfg.PassthroughTarget("/control/slots/0")
+		Expect(ok).To(BeTrue())
+		Expect(target).To(Equal("http://127.0.0.1:9001/slots/0"))
+		Expect(route.AllowedMethods()).To(Equal([]string{"GET"}))
+
+		_, _, ok = cfg.PassthroughTarget("/controller")
+		Expect(ok).To(BeFalse())
+		_, _, ok = cfg.PassthroughTarget("/metrics")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("sets the credentials sent to the target", func() {
+		Expect(PassthroughRoute{}.Authorization("Bearer localai-key")).To(BeEmpty())
+		Expect(PassthroughRoute{APIKey: "backend-key"}.Authorization("Bearer localai-key")).To(Equal("Bearer backend-key"))
+		Expect(PassthroughRoute{ForwardAuthorization: true}.Authorization("Bearer localai-key")).To(Equal("Bearer localai-key"))
+
+		GinkgoT().Setenv("PASSTHROUGH_TEST_KEY", "env-key")
+		Expect(PassthroughRoute{APIKeyEnv: "PASSTHROUGH_TEST_KEY"}.Authorization("")).To(Equal("Bearer env-key"))
+		Expect(PassthroughRoute{APIKeyEnv: "PASSTHROUGH_TEST_UNSET"}.Authorization("Bearer localai-key")).To(BeEmpty())
+	})
+
+	It("validates the configuration", func() {
+		Expect(cfg.Validate()).To(BeTrue())
+		Expect((&BackendConfig{Passthrough: []PassthroughRoute{{Path: "control", Target: "http://127.0.0.1:9000"}}}).Validate()).To(BeFalse())
+		Expect((&BackendConfig{Passthrough: []PassthroughRoute{{Path: "/../admin", Target: "http://127.0.0.1:9000"}}}).Validate()).To(BeFalse())
+		Expect((&BackendConfig{Passthrough: []PassthroughRoute{{Path: "/control", Target: "unix:///tmp/backend.sock"}}}).Validate()).To(BeFalse())
+		Expect((&BackendConfig{Passthrough: []PassthroughRoute{{Path: "/control", Target: "http://127.0.0.1:9000", Methods: []string{"CONNECT"}}}}).Validate()).To(BeFalse())
+		Expect((&BackendConfig{Passthrough: []PassthroughRoute{{Path: "/control", Target: "http://127.0.0.1:9000", Timeout: "soon"}}}).Validate()).To(BeFalse())
+		Expect((&BackendConfig{Passthrough: []PassthroughRoute{{Path: "/control", Target: "http://127.0.0.1:9000", APIKey: "key"}}}).Validate()).To(BeTrue())
+		Expect((&BackendConfig{Passthrough: []PassthroughRoute{{Path: "/control", Target: "http://127.0.0.1:9000", APIKey: "key", ForwardAuthorization: true}}}).Validate()).To(BeFalse())
+	})
+})
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+)
+
+const (
+	PipelineStageChat = "chat"
+	PipelineStageTTS  = "tts"
+
+	PipelineOnErrorFail = "fail"
+	PipelineOnErrorSkip = "skip"
+)
+
+// PipelineStage is a stage of a pipeline model. The text output of a stage is the input of the next one
+type PipelineStage struct {
+	Model string `yaml:"model"`
+	// Type of the stage: "chat" (default) runs a chat completion, "tts" synthesizes the text and can only be the last stage
+	Type string `yaml:"type"`
+	// SystemPrompt is sent as system message to chat stages
+	SystemPrompt string `yaml:"system_prompt"`
+	// Conversation sends the whole conversation to chat stages, with the last user message replaced by the stage input.
+	// By default only the stage input is sent
+	Conversation bool `yaml:"conversation"`
+	// OnError is what happens when the stage fails: "fail" (default) fails the request,
+	// "skip" passes the stage input to the next stage
+	OnError string `yaml:"on_error"`
+}
+
+// StageType returns the type of the stage, applying the default
+func (s PipelineStage) StageType() string {
+	if s.Type == "" {
+		return PipelineStageChat
+	}
+	return s.Type
+}
+
+func (c *BackendConfig) validatePipeline() error {
+	for i, s := range c.Pipeline {
+		if s.Model == "" {
+			return fmt.Errorf("pipeline stage %d: no model set", i)
+		}
+		if s.Model == c.Name {
+			return fmt.Errorf("pipeline stage %d: the pipeline cannot run itself", i)
+		}
+		switch s.StageType() {
+		case PipelineStageChat:
+		case PipelineStageTTS:
+			if i != len(c.Pipeline)-1 {
+				return fmt.Errorf("pipeline stage %d: tts can only be the last stage", i)
+			}
+		default:
+			return fmt.Errorf("pipeline stage %d: unknown type %q", i, s.Type)
+		}
+		if s.OnError != "" && s.OnError != PipelineOnErrorFail && s.OnError != PipelineOnErrorSkip {
+			return fmt.Errorf("pipeline stage %d: unknown on_error %q", i, s.OnError)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pipeline models", func() {
+	It("validates the stages", func() {
+		for _, tc := range []struct {
+			pipeline []PipelineStage
+			valid    bool
+		}{
+			{pipeline: []PipelineStage{{Model: "llm"}, {Model: "voice", Type: PipelineStageTTS}}, valid: true},
+			{pipeline: []PipelineStage{{Model: "voice", Type: PipelineStageTTS}, {Model: "llm"}}},
+			{pipeline: []PipelineStage{{}}},
+			{pipeline: []PipelineStage{{Model: "assistant"}}},
+			{pipeline: []PipelineStage{{Model: "llm", Type: "image"}}},
+			{pipeline: []PipelineStage{{Model: "llm", OnError: "retry"}}},
+		} {
+			cfg := &BackendConfig{Name: "assistant", Pipeline: tc.pipeline}
+			Expect(cfg.Validate()).To(Equal(tc.valid), "%+v", tc.pipeline)
+		}
+	})
+})
+
+var _ = Describe("Ensemble models", func() {
+	It("validates the members", func() {
+		for _, tc := range []struct {
+			ensemble Ensemble
+			valid    bool
+		}{
+			{ensemble: Ensemble{Members: []string{"a", "b", "c"}, MinMembers: 2}, valid: true},
+			{ensemble: Ensemble{Members: []string{"a", "b"}, Strategy: EnsembleStrategyAverage}, valid: true},
+			{ensemble: Ensemble{Members: []string{"a", ""}}},
+			{ensemble: Ensemble{Members: []string{"a", "assistant"}}},
+			{ensemble: Ensemble{Members: []string{"a", "b"}, Strategy: "median"}},
+			{ensemble: Ensemble{Members: []string{"a", "b"}, MinMembers: 3}},
+		} {
+			cfg := &BackendConfig{Name: "assistant", Ensemble: tc.ensemble}
+			Expect(cfg.Validate()).To(Equal(tc.valid), "%+v", tc.ensemble)
+		}
+	})
+})
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Reasoning efforts of the requests to reasoning models, as with OpenAI
+const (
+	ReasoningEffortLow    = "low"
+	ReasoningEffortMedium = "medium"
+	ReasoningEffortHigh   = "high"
+)
+
+var reasoningEfforts = []string{ReasoningEffortLow, ReasoningEffortMedium, ReasoningEffortHigh}
+
+// ReasoningEffort are the settings of the requests with a reasoning effort
+type ReasoningEffort struct {
+	// MaxTokens is the budget of the reasoning tokens, 0 is not limited
+	MaxTokens int `yaml:"max_tokens"`
+	// Temperature and TopP override the sampling of the model, unless set by the request
+	Temperature *float64 `yaml:"temperature"`
+	TopP        *float64 `yaml:"top_p"`
+}
+
+// defaultReasoningEfforts are the settings of the efforts not configured by the model
+var defaultReasoningEfforts = map[string]ReasoningEffort{
+	ReasoningEffortLow:    {MaxTokens: 1024},
+	ReasoningEffortMedium: {MaxTokens: 4096},
+	ReasoningEffortHigh:   {},
+}
+
+// ResolveEffort returns the effort of a request and its settings: the requested effort, or the default effort
+// of the model. The effort is empty when neither is set
+func (r Reasoning) ResolveEffort(requested string) (string, ReasoningEffort, error) {
+	effort := requested
+	if effort == "" {
+		effort = r.Effort
+	}
+	if effort == "" {
+		return "", ReasoningEffort{}, nil
+	}
+	if !slices.Contains(reasoningEfforts, effort) {
+		return "", ReasoningEffort{}, fmt.Errorf("invalid reasoning_effort %q: supported efforts are low, medium and high", effort)
+	}
+	if !r.Enabled {
+		return "", ReasoningEffort{}, fmt.Errorf("the model does not support reasoning_effort: reasoning is not enabled")
+	}
+	if settings, ok := r.Efforts[effort]; ok {
+		return effort, settings, nil
+	}
+	return effort, defaultReasoningEfforts[effort], nil
+}
+
+func (c *BackendConfig) validateReasoningEffort() error {
+	if c.Reasoning.Effort != "" && !slices.Contains(reasoningEfforts, c.Reasoning.Effort) {
+		return fmt.Errorf("reasoning: invalid effort %q, supported efforts are low, medium and high", c.Reasoning.Effort)
+	}
+	for effort, settings := range c.Reasoning.Efforts {
+		if !slices.Contains(reasoningEfforts, effort) {
+			return fmt.Errorf("reasoning: invalid effort %q in efforts, supported efforts are low, medium and high", effort)
+		}
+		if settings.MaxTokens < 0 {
+			return fmt.Errorf("reasoning: max_tokens of the %s effort cannot be negative", effort)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reasoning effort", func() {
+	It("defaults to the effort of the model", func() {
+		r := Reasoning{Enabled: true, Effort: ReasoningEffortMedium}
+		effort, settings, err := r.ResolveEffort("")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(effort).To(Equal(ReasoningEffortMedium))
+		Expect(settings.MaxTokens).To(Equal(4096))
+
+		effort, settings, err = r.ResolveEffort(ReasoningEffortHigh)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(effort).To(Equal(ReasoningEffortHigh))
+		Expect(settings.MaxTokens).To(BeZero())
+
+		effort, _, err = Reasoning{Enabled: true}.ResolveEffort("")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(effort).To(BeEmpty())
+	})
+
+	It("uses the settings configured by the model", func() {
+		r := Reasoning{Enabled: true, Efforts: map[string]ReasoningEffort{ReasoningEffortLow: {MaxTokens: 256}}}
+		_, settings, err := r.ResolveEffort(ReasoningEffortLow)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(settings.MaxTokens).To(Equal(256))
+	})
+
+	It("rejects the invalid efforts and the models without reasoning", func() {
+		_, _, err := Reasoning{Enabled: true}.ResolveEffort("extreme")
+		Expect(err).To(MatchError(ContainSubstring("invalid reasoning_effort")))
+		_, _, err = Reasoning{}.ResolveEffort(ReasoningEffortLow)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("validates the configuration", func() {
+		Expect((&BackendConfig{Reasoning: Reasoning{Effort: "max"}}).validateReasoningEffort()).ToNot(Succeed())
+		Expect((&BackendConfig{Reasoning: Reasoning{Efforts: map[string]ReasoningEffort{"max": {}}}}).validateReasoningEffort()).ToNot(Succeed())
+		Expect((&BackendConfig{Reasoning: Reasoning{Efforts: map[string]ReasoningEffort{ReasoningEffortLow: {MaxTokens: -1}}}}).validateReasoningEffort()).ToNot(Succeed())
+		Expect((&BackendConfig{Reasoning: Reasoning{Effort: ReasoningEffortLow}}).validateReasoningEffort()).To(Succeed())
+	})
+})
@@ -0,0 +1,38 @@
+package config
+
+import "fmt"
+
+// defaultRepetitionMaxPhraseWords is the length of the longest repeated phrase detected by default
+const defaultRepetitionMaxPhraseWords = 16
+
+// RepetitionStop stops the generation when the model gets stuck in a loop, repeating the same phrase
+type RepetitionStop struct {
+	// Repeats is the number of times a phrase has to be repeated in a row to stop the generation, 0 disables it
+	Repeats int `yaml:"repeats"`
+	// MaxPhraseWords is the length, in words, of the longest repeated phrase detected (16 by default)
+	MaxPhraseWords int `yaml:"max_phrase_words"`
+}
+
+// Enabled returns whether the repetitions stop the generation
+func (r RepetitionStop) Enabled() bool {
+	return r.Repeats > 0
+}
+
+// PhraseWords returns the length of the longest repeated phrase detected
+func (r RepetitionStop) PhraseWords() int {
+	if r.MaxPhraseWords > 0 {
+		return r.MaxPhraseWords
+	}
+	return defaultRepetitionMaxPhraseWords
+}
+
+func (c *BackendConfig) validateRepetitionStop() error {
+	r := c.RepetitionStop
+	if r.Repeats < 0 || r.Repeats == 1 {
+		return fmt.Errorf("repetition_stop: repeats must be at least 2, or 0 to disable it")
+	}
+	if r.MaxPhraseWords < 0 {
+		return fmt.Errorf("repetition_stop: max_phrase_words cannot be negative")
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RepetitionStop", func() {
+	It("validates the sensitivity", func() {
+		cfg := &BackendConfig{}
+		Expect(cfg.validateRepetitionStop()).To(Succeed())
+		Expect(cfg.RepetitionStop.Enabled()).To(BeFalse())
+
+		cfg.RepetitionStop = RepetitionStop{Repeats: 3}
+		Expect(cfg.validateRepetitionStop()).To(Succeed())
+		Expect(cfg.RepetitionStop.PhraseWords()).To(Equal(16))
+
+		cfg.RepetitionStop = RepetitionStop{Repeats: 1}
+		Expect(cfg.validateRepetitionStop()).To(MatchError(ContainSubstring("at least 2")))
+		cfg.RepetitionStop = RepetitionStop{Repeats: 3, MaxPhraseWords: -1}
+		Expect(cfg.validateRepetitionStop()).To(HaveOccurred())
+	})
+})
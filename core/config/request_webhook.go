@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const defaultRequestWebhookTimeout = 5 * time.Second
+
+// RequestWebhookSettings returns the URL, the timeout and the failure policy of the request webhook of the model,
+// applying the global settings. The URL is empty if the requests are not validated
+func (c *BackendConfig) RequestWebhookSettings(appConfig *ApplicationConfig) (string, time.Duration, bool) {
+	w := c.RequestWebhook
+	if w.Disabled {
+		return "", 0, false
+	}
+
+	webhook, timeout, failOpen := appConfig.RequestWebhook, appConfig.RequestWebhookTimeout, appConfig.RequestWebhookFailOpen
+	if w.URL != "" {
+		webhook = w.URL
+	}
+	if d, err := time.ParseDuration(w.Timeout); err == nil && d > 0 {
+		timeout = d
+	}
+	if w.FailOpen != nil {
+		failOpen = *w.FailOpen
+	}
+	if timeout <= 0 {
+		timeout = defaultRequestWebhookTimeout
+	}
+	return webhook, timeout, failOpen
+}
+
+func (c *BackendConfig) validateRequestWebhook() error {
+	w := c.RequestWebhook
+	if w.URL != "" {
+		u, err := url.Parse(w.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("request webhook: invalid URL %q", w.URL)
+		}
+	}
+	if w.Timeout != "" {
+		if d, err := time.ParseDuration(w.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("request webhook: invalid timeout %q", w.Timeout)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Request webhook", func() {
+	It("applies the global settings", func() {
+		appConfig := NewApplicationConfig(WithRequestWebhook("http://policy:8000/check"), WithRequestWebhookFailOpen(true))
+
+		cfg := &BackendConfig{}
+		webhook, timeout, failOpen := cfg.RequestWebhookSettings(appConfig)
+		Expect(webhook).To(Equal("http://policy:8000/check"))
+		Expect(timeout).To(Equal(5 * time.Second))
+		Expect(failOpen).To(BeTrue())
+
+		closed := false
+		cfg.RequestWebhook = RequestWebhook{URL: "https://strict:8000/check", Timeout: "1s", FailOpen: &closed}
+		webhook, timeout, failOpen = cfg.RequestWebhookSettings(appConfig)
+		Expect(webhook).To(Equal("https://strict:8000/check"))
+		Expect(timeout).To(Equal(time.Second))
+		Expect(failOpen).To(BeFalse())
+		Expect(cfg.validateRequestWebhook()).To(Succeed())
+
+		cfg.RequestWebhook = RequestWebhook{Disabled: true}
+		webhook, _, _ = cfg.RequestWebhookSettings(appConfig)
+		Expect(webhook).To(BeEmpty())
+	})
+
+	It("validates the URL and the timeout", func() {
+		cfg := &BackendConfig{RequestWebhook: RequestWebhook{URL: "policy:8000"}}
+		Expect(cfg.validateRequestWebhook()).To(HaveOccurred())
+		cfg.RequestWebhook = RequestWebhook{Timeout: "soon"}
+		Expect(cfg.validateRequestWebhook()).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/mudler/LocalAI/pkg/xsysinfo"
+)
+
+// Resources caps the CPU and memory of the backend process of a model with cgroups, where available,
+// so that a model cannot starve the others on a shared host. Unset fields are not limited
+type Resources struct {
+	// CPUs is the number of CPUs the backend can use, e.g. 2.5
+	CPUs float64 `yaml:"cpus"`
+	// MemoryMB is the memory above which the backend is killed
+	MemoryMB int `yaml:"memory_mb"`
+}
+
+// IsSet returns whether the backend of the model is limited
+func (r Resources) IsSet() bool {
+	return r.CPUs > 0 || r.MemoryMB > 0
+}
+
+// hostCapacity returns the CPUs and the memory of the host, the memory being 0 if unknown
+var hostCapacity = func() (int, uint64) {
+	memory, _ := xsysinfo.TotalMemory()
+	return runtime.NumCPU(), memory
+}
+
+func (c *BackendConfig) validateResources() error {
+	r := c.Resources
+	if r.CPUs < 0 || r.MemoryMB < 0 {
+		return fmt.Errorf("resources: the limits cannot be negative")
+	}
+	if !r.IsSet() {
+		return nil
+	}
+	cpus, memory := hostCapacity()
+	if r.CPUs > float64(cpus) {
+		return fmt.Errorf("resources: cpus (%g) exceeds the CPUs of the host (%d)", r.CPUs, cpus)
+	}
+	if memory > 0 && uint64(r.MemoryMB)<<20 > memory {
+		return fmt.Errorf("resources: memory_mb (%d) exceeds the memory of the host (%d MB)", r.MemoryMB, memory>>20)
+	}
+	return nil
+}
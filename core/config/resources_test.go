@@ -0,0 +1,33 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Resources", func() {
+	var capacity func() (int, uint64)
+
+	BeforeEach(func() {
+		capacity = hostCapacity
+		hostCapacity = func() (int, uint64) { return 8, 16 << 30 }
+	})
+
+	AfterEach(func() {
+		hostCapacity = capacity
+	})
+
+	It("validates the limits against the capacity of the host", func() {
+		cfg := &BackendConfig{}
+		Expect(cfg.validateResources()).To(Succeed())
+		cfg.Resources = Resources{CPUs: 2.5, MemoryMB: 8192}
+		Expect(cfg.validateResources()).To(Succeed())
+
+		cfg.Resources.CPUs = 16
+		Expect(cfg.validateResources()).To(MatchError(ContainSubstring("exceeds the CPUs of the host")))
+		cfg.Resources = Resources{MemoryMB: 32768}
+		Expect(cfg.validateResources()).To(MatchError(ContainSubstring("exceeds the memory of the host")))
+		cfg.Resources = Resources{CPUs: -1}
+		Expect(cfg.validateResources()).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+// defaultResponseLanguageInstruction is the instruction added to the requests forcing the response language
+const defaultResponseLanguageInstruction = "Always answer in {{.Language}}, regardless of the language of the messages."
+
+// ResponseLanguage configures the response_language parameter of the chat requests, which instructs the model
+// to answer in a language whatever the language of the input
+type ResponseLanguage struct {
+	// Allowed are the languages (ISO 639-1 codes) the requests can force, by default the languages LocalAI knows the name of
+	Allowed []string `yaml:"allowed"`
+	// Instruction is the template of the system message added to the requests: {{.Language}} is the name of
+	// the language and {{.Code}} its code
+	Instruction string `yaml:"instruction"`
+}
+
+// RenderInstruction returns the instruction to answer in the language
+func (r ResponseLanguage) RenderInstruction(code, name string) (string, error) {
+	instruction := r.Instruction
+	if instruction == "" {
+		instruction = defaultResponseLanguageInstruction
+	}
+	tmpl, err := template.New("response_language").Parse(instruction)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, struct{ Language, Code string }{name, code}); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (c *BackendConfig) validateResponseLanguage() error {
+	if _, err := c.Languages.Response.RenderInstruction("en", "English"); err != nil {
+		return fmt.Errorf("languages: invalid response instruction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/rs/zerolog/log"
+
+	gguf "github.com/thxcode/gguf-parser-go"
+)
+
+const defaultRopeScalingMethod = "yarn"
+
+// ropeScalingMethods returns the rope scaling methods supported by the backend
+func ropeScalingMethods(backend string) []string {
+	// an empty backend is auto-detected, which resolves to llama.cpp for GGUF files
+	if backend == "" || backend == "llama" || strings.HasPrefix(backend, "llama-cpp") {
+		return []string{"linear", "yarn"}
+	}
+	return nil
+}
+
+// trainedContextSize returns the context size the model was trained with,
+// either from the configuration or from the GGUF metadata of the model file
+func (cfg *BackendConfig) trainedContextSize(modelPath string) int {
+	if cfg.TrainedContextSize > 0 {
+		return cfg.TrainedContextSize
+	}
+	if modelPath == "" || cfg.ModelFileName() == "" {
+		return 0
+	}
+	f, err := gguf.ParseGGUFFile(filepath.Join(modelPath, cfg.ModelFileName()))
+	if err != nil {
+		return 0
+	}
+	return int(f.Architecture().MaximumContextLength)
+}
+
+// setRopeScaling sets the rope frequency scale needed to run the model with a context size
+// bigger than the one it was trained with, if auto_rope_scaling is enabled
+func (cfg *BackendConfig) setRopeScaling(modelPath string) {
+	if !cfg.AutoRopeScaling || cfg.ContextSize == nil {
+		return
+	}
+
+	trained := cfg.trainedContextSize(modelPath)
+	if trained <= 0 {
+		log.Warn().Str("model", cfg.Name).Msg("auto_rope_scaling is enabled but the trained context size is unknown: set trained_context_size")
+		return
+	}
+	ctx := *cfg.ContextSize
+	if ctx <= trained {
+		return
+	}
+
+	method := cfg.RopeScaling
+	if method == "" {
+		method = defaultRopeScalingMethod
+	}
+	if !slices.Contains(ropeScalingMethods(cfg.Backend), method) {
+		log.Error().Str("model", cfg.Name).Str("backend", cfg.Backend).Str("method", method).Msg("the backend does not support the rope scaling method, the context size is not extended")
+		return
+	}
+
+	factor := float32(ctx) / float32(trained)
+	cfg.RopeScaling = method
+	// an explicit frequency scale is not overridden
+	if cfg.RopeFreqScale == 0 {
+		cfg.RopeFreqScale = 1 / factor
+	}
+
+	cfg.RopeScalingInfo = &schema.RopeScalingInfo{
+		Method:             method,
+		Factor:             factor,
+		FreqScale:          cfg.RopeFreqScale,
+		TrainedContextSize: trained,
+		ContextSize:        ctx,
+	}
+	log.Warn().Str("model", cfg.Name).Int("trained_context_size", trained).Int("context_size", ctx).Str("method", method).Float32("factor", factor).
+		Msg("context size extended with rope scaling, the quality of the output may degrade")
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Automatic rope scaling", func() {
+	ctx := func(size int) *int { return &size }
+
+	It("sets the frequency scale from the requested and trained context size", func() {
+		cfg := &BackendConfig{LLMConfig: LLMConfig{ContextSize: ctx(16384), AutoRopeScaling: true, TrainedContextSize: 4096}}
+		cfg.setRopeScaling("")
+		Expect(cfg.RopeScaling).To(Equal("yarn"))
+		Expect(cfg.RopeFreqScale).To(Equal(float32(0.25)))
+		Expect(cfg.RopeScalingInfo).ToNot(BeNil())
+		Expect(cfg.RopeScalingInfo.Factor).To(Equal(float32(4)))
+	})
+
+	It("does nothing when the context fits or when disabled", func() {
+		cfg := &BackendConfig{LLMConfig: LLMConfig{ContextSize: ctx(2048), AutoRopeScaling: true, TrainedContextSize: 4096}}
+		cfg.setRopeScaling("")
+		Expect(cfg.RopeScalingInfo).To(BeNil())
+		Expect(cfg.RopeFreqScale).To(BeZero())
+
+		cfg = &BackendConfig{LLMConfig: LLMConfig{ContextSize: ctx(16384), TrainedContextSize: 4096}}
+		cfg.setRopeScaling("")
+		Expect(cfg.RopeScalingInfo).To(BeNil())
+	})
+
+	It("keeps an explicit frequency scale and validates the method", func() {
+		cfg := &BackendConfig{LLMConfig: LLMConfig{ContextSize: ctx(8192), AutoRopeScaling: true, TrainedContextSize: 4096, RopeScaling: "linear"}}
+		cfg.RopeFreqScale = 0.4
+		cfg.setRopeScaling("")
+		Expect(cfg.RopeFreqScale).To(Equal(float32(0.4)))
+		Expect(cfg.RopeScalingInfo.Method).To(Equal("linear"))
+
+		cfg = &BackendConfig{Backend: "vllm", LLMConfig: LLMConfig{ContextSize: ctx(8192), AutoRopeScaling: true, TrainedContextSize: 4096}}
+		cfg.setRopeScaling("")
+		Expect(cfg.RopeScalingInfo).To(BeNil())
+		Expect(cfg.RopeFreqScale).To(BeZero())
+	})
+})
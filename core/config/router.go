@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Router routes the chat requests to the model to the most appropriate model for their prompt, e.g. a code,
+// a chat and a math model, by asking a classifier model for the category of the prompt
+type Router struct {
+	// ClassifierModel is the model answering with the category of the prompt
+	ClassifierModel string `yaml:"classifier_model"`
+	// Prompt is the instruction given to the classifier, followed by the categories and the prompt
+	Prompt string `yaml:"prompt"`
+	// Routes maps the categories to the models serving them
+	Routes map[string]string `yaml:"routes"`
+	// Default is the model of the requests whose category is unknown, or below MinConfidence
+	Default string `yaml:"default"`
+	// MinConfidence is the probability (0-1) of its answer the classifier needs for the request to be routed to
+	// the model of the category. It is only known with the backends reporting log probabilities (e.g. llama.cpp)
+	MinConfidence float64 `yaml:"min_confidence"`
+}
+
+// Categories returns the categories of the router, sorted
+func (r Router) Categories() []string {
+	categories := []string{}
+	for category := range r.Routes {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+func (c *BackendConfig) validateRouter() error {
+	r := c.Router
+	if len(r.Routes) == 0 {
+		return nil
+	}
+	if r.ClassifierModel == "" || r.Default == "" {
+		return fmt.Errorf("router: classifier_model and default are required")
+	}
+	if r.ClassifierModel == c.Name || r.Default == c.Name {
+		return fmt.Errorf("router: the router cannot run itself")
+	}
+	for category, model := range r.Routes {
+		if category == "" || model == "" {
+			return fmt.Errorf("router: the routes need a category and a model")
+		}
+		if model == c.Name {
+			return fmt.Errorf("router: the route %q cannot run the router itself", category)
+		}
+	}
+	if r.MinConfidence < 0 || r.MinConfidence > 1 {
+		return fmt.Errorf("router: min_confidence must be between 0 and 1")
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Router", func() {
+	It("validates the routes", func() {
+		cfg := &BackendConfig{Name: "auto"}
+		Expect(cfg.validateRouter()).To(Succeed())
+
+		cfg.Router = Router{
+			ClassifierModel: "phi-3",
+			Routes:          map[string]string{"code": "qwen-coder", "math": "deepseek-math"},
+			Default:         "llama-3-8b",
+			MinConfidence:   0.5,
+		}
+		Expect(cfg.validateRouter()).To(Succeed())
+		Expect(cfg.Router.Categories()).To(Equal([]string{"code", "math"}))
+
+		cfg.Router.Default = ""
+		Expect(cfg.validateRouter()).To(MatchError(ContainSubstring("required")))
+		cfg.Router.Default = "auto"
+		Expect(cfg.validateRouter()).To(MatchError(ContainSubstring("cannot run itself")))
+		cfg.Router.Default = "llama-3-8b"
+		cfg.Router.Routes["chat"] = "auto"
+		Expect(cfg.validateRouter()).To(MatchError(ContainSubstring("cannot run the router itself")))
+		delete(cfg.Router.Routes, "chat")
+		cfg.Router.MinConfidence = 1.5
+		Expect(cfg.validateRouter()).To(MatchError(ContainSubstring("min_confidence")))
+	})
+})
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// knownTurnDelimiters are the end of turn markers that, when found in a chat template,
+// are used as default stop words
+var knownTurnDelimiters = []string{
+	"<|im_end|>",
+	"<|eot_id|>",
+	"<|end|>",
+	"<end_of_turn>",
+	"<|END_OF_TURN_TOKEN|>",
+	"<｜end▁of▁sentence｜>",
+	"<|endoftext|>",
+	"<|end_of_text|>",
+	"</s>",
+}
+
+// deriveStopWords returns the turn delimiters found in the templates configured for the model
+func (cfg *BackendConfig) deriveStopWords(modelPath string) []string {
+	derived := []string{}
+	for _, t := range []string{cfg.TemplateConfig.Chat, cfg.TemplateConfig.ChatMessage, cfg.TemplateConfig.Completion, cfg.TemplateConfig.Functions} {
+		if t == "" {
+			continue
+		}
+		content := templateContent(t, modelPath)
+		for _, d := range knownTurnDelimiters {
+			if strings.Contains(content, d) && !slices.Contains(derived, d) {
+				derived = append(derived, d)
+			}
+		}
+	}
+	return derived
+}
+
+// templateContent returns the content of the template, which can be either
+// the name of a template file in the model path or the template itself
+func templateContent(nameOrContent, modelPath string) string {
+	file := nameOrContent + ".tmpl"
+	if modelPath == "" || utils.VerifyPath(file, modelPath) != nil || !utils.ExistsInPath(modelPath, file) {
+		return nameOrContent
+	}
+	dat, err := os.ReadFile(filepath.Join(modelPath, file))
+	if err != nil {
+		return nameOrContent
+	}
+	return string(dat)
+}
+
+// setDerivedStopWords adds the stop words derived from the templates to the configured ones, unless disabled
+func (cfg *BackendConfig) setDerivedStopWords(modelPath string) {
+	if cfg.AutoStopWords != nil && !*cfg.AutoStopWords {
+		return
+	}
+
+	cfg.DerivedStopWords = cfg.deriveStopWords(modelPath)
+	for _, s := range cfg.DerivedStopWords {
+		if !slices.Contains(cfg.StopWords, s) {
+			cfg.StopWords = append(cfg.StopWords, s)
+		}
+	}
+	if len(cfg.DerivedStopWords) > 0 {
+		log.Debug().Str("model", cfg.Name).Strs("stopwords", cfg.DerivedStopWords).Msg("derived stop words from template")
+	}
+}
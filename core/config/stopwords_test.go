@@ -0,0 +1,51 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stop words derived from templates", func() {
+	It("derives the turn delimiters from inline templates", func() {
+		cfg := &BackendConfig{
+			TemplateConfig: TemplateConfig{
+				ChatMessage: "<|im_start|>{{.RoleName}}\n{{.Content}}<|im_end|>",
+			},
+		}
+		cfg.setDerivedStopWords("")
+		Expect(cfg.DerivedStopWords).To(Equal([]string{"<|im_end|>"}))
+		Expect(cfg.StopWords).To(Equal([]string{"<|im_end|>"}))
+
+		// applying the defaults twice does not duplicate stop words
+		cfg.setDerivedStopWords("")
+		Expect(cfg.StopWords).To(Equal([]string{"<|im_end|>"}))
+	})
+
+	It("reads templates from the model path", func() {
+		dir, err := os.MkdirTemp("", "")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+		Expect(os.WriteFile(filepath.Join(dir, "chat.tmpl"), []byte("{{.Input}}<|eot_id|>"), 0600)).To(Succeed())
+
+		cfg := &BackendConfig{
+			TemplateConfig: TemplateConfig{Chat: "chat"},
+			LLMConfig:      LLMConfig{StopWords: []string{"foo"}},
+		}
+		cfg.setDerivedStopWords(dir)
+		Expect(cfg.StopWords).To(Equal([]string{"foo", "<|eot_id|>"}))
+	})
+
+	It("can be disabled", func() {
+		f := false
+		cfg := &BackendConfig{
+			TemplateConfig: TemplateConfig{ChatMessage: "{{.Content}}<|im_end|>"},
+			LLMConfig:      LLMConfig{AutoStopWords: &f},
+		}
+		cfg.setDerivedStopWords("")
+		Expect(cfg.StopWords).To(BeEmpty())
+		Expect(cfg.DerivedStopWords).To(BeEmpty())
+	})
+})
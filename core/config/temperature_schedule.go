@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+const (
+	TemperatureScheduleLinear = "linear"
+	TemperatureScheduleStep   = "step"
+)
+
+// TemperatureSchedule varies the sampling temperature during the generation, e.g. high early and low late.
+// It is applied only by the backends controlling the sampling of every token (llama.cpp): the other backends
+// use the fixed temperature of the model
+type TemperatureSchedule struct {
+	// Type is "linear", going from Start to End over the first Tokens generated tokens,
+	// or "step", using the temperature of the last step reached
+	Type   string  `yaml:"type"`
+	Start  float64 `yaml:"start"`
+	End    float64 `yaml:"end"`
+	Tokens int     `yaml:"tokens"`
+
+	Steps []TemperatureStep `yaml:"steps"`
+}
+
+type TemperatureStep struct {
+	// After is the number of generated tokens from which Temperature is used
+	After       int     `yaml:"after"`
+	Temperature float64 `yaml:"temperature"`
+}
+
+// Points returns the schedule as the temperatures used from given numbers of generated tokens.
+// temperature, the fixed temperature of the model, is used before the first step
+func (s TemperatureSchedule) Points(temperature float64) []schema.TemperaturePoint {
+	switch s.Type {
+	case TemperatureScheduleLinear:
+		return []schema.TemperaturePoint{{Tokens: 0, Temperature: s.Start}, {Tokens: s.Tokens, Temperature: s.End}}
+	case TemperatureScheduleStep:
+		points := []schema.TemperaturePoint{}
+		if len(s.Steps) > 0 && s.Steps[0].After > 0 {
+			points = append(points, schema.TemperaturePoint{Tokens: 0, Temperature: temperature})
+		}
+		for _, step := range s.Steps {
+			points = append(points, schema.TemperaturePoint{Tokens: step.After, Temperature: step.Temperature})
+		}
+		return points
+	}
+	return nil
+}
+
+// TemperatureScheduleSupported tells if the backend of the model applies the temperature schedule
+func (c *BackendConfig) TemperatureScheduleSupported() bool {
+	return c.Backend == "" || c.Backend == "llama" || strings.HasPrefix(c.Backend, "llama-cpp")
+}
+
+func (c *BackendConfig) validateTemperatureSchedule() error {
+	s := c.TemperatureSchedule
+	switch s.Type {
+	case "":
+		return nil
+	case TemperatureScheduleLinear:
+		if s.Tokens <= 0 {
+			return fmt.Errorf("temperature schedule: tokens must be positive")
+		}
+		if s.Start <= 0 || s.End <= 0 {
+			return fmt.Errorf("temperature schedule: the temperatures must be positive")
+		}
+	case TemperatureScheduleStep:
+		if len(s.Steps) == 0 {
+			return fmt.Errorf("temperature schedule: no steps set")
+		}
+		for i, step := range s.Steps {
+			if step.Temperature <= 0 {
+				return fmt.Errorf("temperature schedule step %d: the temperature must be positive", i)
+			}
+			if step.After < 0 || (i > 0 && step.After <= s.Steps[i-1].After) {
+				return fmt.Errorf("temperature schedule step %d: the steps must be in increasing order of tokens", i)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown temperature schedule %q", s.Type)
+	}
+	return nil
+}
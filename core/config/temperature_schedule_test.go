@@ -0,0 +1,46 @@
+package config
+
+import (
+	"github.com/mudler/LocalAI/core/schema"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Temperature schedules", func() {
+	It("validates the schedule", func() {
+		for _, tc := range []struct {
+			schedule TemperatureSchedule
+			valid    bool
+		}{
+			{schedule: TemperatureSchedule{}, valid: true},
+			{schedule: TemperatureSchedule{Type: TemperatureScheduleLinear, Start: 1.2, End: 0.3, Tokens: 100}, valid: true},
+			{schedule: TemperatureSchedule{Type: TemperatureScheduleLinear, Start: 1.2, End: 0.3}},
+			{schedule: TemperatureSchedule{Type: TemperatureScheduleLinear, Start: 1.2, Tokens: 100}},
+			{schedule: TemperatureSchedule{Type: TemperatureScheduleStep, Steps: []TemperatureStep{{After: 0, Temperature: 1}, {After: 50, Temperature: 0.5}}}, valid: true},
+			{schedule: TemperatureSchedule{Type: TemperatureScheduleStep}},
+			{schedule: TemperatureSchedule{Type: TemperatureScheduleStep, Steps: []TemperatureStep{{After: 50, Temperature: 1}, {After: 10, Temperature: 0.5}}}},
+			{schedule: TemperatureSchedule{Type: "cosine"}},
+		} {
+			cfg := &BackendConfig{TemperatureSchedule: tc.schedule}
+			Expect(cfg.Validate()).To(Equal(tc.valid), "%+v", tc.schedule)
+		}
+	})
+
+	It("converts the schedule to points", func() {
+		linear := TemperatureSchedule{Type: TemperatureScheduleLinear, Start: 1.2, End: 0.3, Tokens: 100}
+		Expect(linear.Points(0.7)).To(Equal([]schema.TemperaturePoint{{Tokens: 0, Temperature: 1.2}, {Tokens: 100, Temperature: 0.3}}))
+
+		// the fixed temperature is used before the first step
+		step := TemperatureSchedule{Type: TemperatureScheduleStep, Steps: []TemperatureStep{{After: 20, Temperature: 1}, {After: 50, Temperature: 0.5}}}
+		Expect(step.Points(0.7)).To(Equal([]schema.TemperaturePoint{{Tokens: 0, Temperature: 0.7}, {Tokens: 20, Temperature: 1}, {Tokens: 50, Temperature: 0.5}}))
+
+		Expect(TemperatureSchedule{}.Points(0.7)).To(BeNil())
+	})
+
+	It("tells if the backend applies the schedule", func() {
+		Expect((&BackendConfig{}).TemperatureScheduleSupported()).To(BeTrue())
+		Expect((&BackendConfig{Backend: "llama-cpp-fallback"}).TemperatureScheduleSupported()).To(BeTrue())
+		Expect((&BackendConfig{Backend: "vllm"}).TemperatureScheduleSupported()).To(BeFalse())
+	})
+})
@@ -0,0 +1,42 @@
+package config
+
+import "fmt"
+
+// Default thresholds of the low confidence segments, as with Whisper
+const (
+	defaultLogprobThreshold          = -1.0
+	defaultCompressionRatioThreshold = 2.4
+)
+
+// TranscriptionConfidence are the thresholds of the transcription segments dropped by the requests suppressing the
+// low confidence segments
+type TranscriptionConfidence struct {
+	// LogprobThreshold drops the segments whose average log probability of the tokens is lower (default -1)
+	LogprobThreshold *float64 `yaml:"logprob_threshold"`
+	// CompressionRatioThreshold drops the segments whose text compresses better, as the repetitive
+	// hallucinations do (default 2.4)
+	CompressionRatioThreshold *float64 `yaml:"compression_ratio_threshold"`
+}
+
+// Thresholds returns the thresholds, defaulted
+func (t TranscriptionConfidence) Thresholds() (logprob, compressionRatio float64) {
+	logprob, compressionRatio = defaultLogprobThreshold, defaultCompressionRatioThreshold
+	if t.LogprobThreshold != nil {
+		logprob = *t.LogprobThreshold
+	}
+	if t.CompressionRatioThreshold != nil {
+		compressionRatio = *t.CompressionRatioThreshold
+	}
+	return logprob, compressionRatio
+}
+
+func (c *BackendConfig) validateTranscriptionConfidence() error {
+	logprob, compressionRatio := c.TranscriptionConfidence.Thresholds()
+	if logprob > 0 {
+		return fmt.Errorf("transcription_confidence: logprob_threshold cannot be positive")
+	}
+	if compressionRatio <= 0 {
+		return fmt.Errorf("transcription_confidence: compression_ratio_threshold must be positive")
+	}
+	return nil
+}
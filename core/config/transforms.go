@@ -0,0 +1,132 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/itchyny/gojq"
+)
+
+const defaultTransformTimeout = time.Second
+
+// Transform rewrites the JSON requests to the model before they are handled, and its JSON responses before they
+// are returned, with jq expressions, to adapt them to the clients expecting other shapes
+type Transform struct {
+	// Endpoints are the paths of the endpoints transformed, e.g. "/v1/chat/completions", all of them by default.
+	// The /v1 prefix is optional, as the endpoints are served with and without it
+	Endpoints []string `yaml:"endpoints"`
+	// Request and Response are the jq expressions transforming the requests and the responses
+	Request  string `yaml:"request"`
+	Response string `yaml:"response"`
+	// Timeout of each transformation, e.g. "500ms", 1 second by default
+	Timeout string `yaml:"timeout"`
+	// FailOnError fails the requests whose transformation fails, instead of logging the error and leaving them as they are
+	FailOnError bool `yaml:"fail_on_error"`
+}
+
+// EndpointTransforms returns the transforms of the model applied to the endpoint, in order
+func (c *BackendConfig) EndpointTransforms(path string) []Transform {
+	path = strings.TrimPrefix(path, "/v1")
+	transforms := []Transform{}
+	for _, t := range c.Transforms {
+		if len(t.Endpoints) == 0 || slices.ContainsFunc(t.Endpoints, func(endpoint string) bool {
+			return strings.TrimPrefix(endpoint, "/v1") == path
+		}) {
+			transforms = append(transforms, t)
+		}
+	}
+	return transforms
+}
+
+// TransformRequest applies the request expression to the JSON request
+func (t Transform) TransformRequest(data []byte) ([]byte, error) {
+	return t.apply(t.Request, data)
+}
+
+// TransformResponse applies the response expression to the JSON response
+func (t Transform) TransformResponse(data []byte) ([]byte, error) {
+	return t.apply(t.Response, data)
+}
+
+func (t Transform) timeout() time.Duration {
+	if d, err := time.ParseDuration(t.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return defaultTransformTimeout
+}
+
+// compiledTransforms caches the compiled expressions by their source
+var compiledTransforms sync.Map
+
+func compileTransform(expression string) (*gojq.Code, error) {
+	if code, ok := compiledTransforms.Load(expression); ok {
+		return code.(*gojq.Code), nil
+	}
+	query, err := gojq.Parse(expression)
+	if err != nil {
+		return nil, err
+	}
+	// the expressions cannot read the environment of LocalAI, which holds its secrets
+	code, err := gojq.Compile(query, gojq.WithEnvironLoader(func() []string { return nil }))
+	if err != nil {
+		return nil, err
+	}
+	compiledTransforms.Store(expression, code)
+	return code, nil
+}
+
+// apply returns the first output of the expression for the JSON document. The expression is stopped when it
+// runs longer than the timeout
+func (t Transform) apply(expression string, data []byte) ([]byte, error) {
+	code, err := compileTransform(expression)
+	if err != nil {
+		return nil, err
+	}
+	var input any
+	if err := json.Unmarshal(data, &input); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.timeout())
+	defer cancel()
+	output, ok := code.RunWithContext(ctx, input).Next()
+	if !ok {
+		return nil, fmt.Errorf("the expression has no output")
+	}
+	if err, ok := output.(error); ok {
+		return nil, err
+	}
+	return json.Marshal(output)
+}
+
+func (c *BackendConfig) validateTransforms() error {
+	for _, t := range c.Transforms {
+		if t.Request == "" && t.Response == "" {
+			return fmt.Errorf("transforms: a transform needs a request or a response expression")
+		}
+		for _, endpoint := range t.Endpoints {
+			if !strings.HasPrefix(endpoint, "/") {
+				return fmt.Errorf("transforms: invalid endpoint %q, it must be a path", endpoint)
+			}
+		}
+		for _, expression := range []string{t.Request, t.Response} {
+			if expression == "" {
+				continue
+			}
+			if _, err := compileTransform(expression); err != nil {
+				return fmt.Errorf("transforms: invalid expression %q: %w", expression, err)
+			}
+		}
+		if t.Timeout != "" {
+			if d, err := time.ParseDuration(t.Timeout); err != nil || d <= 0 {
+				return fmt.Errorf("transforms: invalid timeout %q", t.Timeout)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Transforms", func() {
+	It("transforms the JSON documents with jq expressions", func() {
+		t := Transform{
+			Request:  `{model, messages: [{role: "user", content: .prompt}]}`,
+			Response: `{text: .choices[0].message.content}`,
+		}
+		out, err := t.TransformRequest([]byte(`{"model": "gpt-4", "prompt": "Hello"}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out).To(MatchJSON(`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hello"}]}`))
+
+		out, err = t.TransformResponse([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Hi"}}]}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out).To(MatchJSON(`{"text": "Hi"}`))
+	})
+
+	It("fails the expressions without output, with errors or running too long", func() {
+		_, err := Transform{Request: `empty`}.TransformRequest([]byte(`{}`))
+		Expect(err).To(MatchError("the expression has no output"))
+
+		_, err = Transform{Request: `error("rejected")`}.TransformRequest([]byte(`{}`))
+		Expect(err).To(MatchError(ContainSubstring("rejected")))
+
+		_, err = Transform{Request: `last(range(1e12))`, Timeout: "50ms"}.TransformRequest([]byte(`{}`))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("does not expose the environment", func() {
+		os.Setenv("LOCALAI_TRANSFORMS_TEST_SECRET", "secret")
+		defer os.Unsetenv("LOCALAI_TRANSFORMS_TEST_SECRET")
+		out, err := Transform{Response: `{secret: $ENV.LOCALAI_TRANSFORMS_TEST_SECRET, env: env.LOCALAI_TRANSFORMS_TEST_SECRET}`}.TransformResponse([]byte(`{}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out).To(MatchJSON(`{"secret": null, "env": null}`))
+	})
+
+	It("selects the transforms of the endpoint", func() {
+		c := BackendConfig{Transforms: []Transform{
+			{Response: ".", Endpoints: []string{"/v1/chat/completions"}},
+			{Response: "."},
+			{Response: ".", Endpoints: []string{"/v1/embeddings"}},
+			{Response: ".", Endpoints: []string{"/completions"}},
+		}}
+		Expect(c.EndpointTransforms("/v1/chat/completions")).To(Equal(c.Transforms[:2]))
+		// the endpoints are matched with and without the /v1 prefix
+		Expect(c.EndpointTransforms("/chat/completions")).To(Equal(c.Transforms[:2]))
+		Expect(c.EndpointTransforms("/v1/completions")).To(Equal([]Transform{c.Transforms[1], c.Transforms[3]}))
+	})
+
+	It("validates the transforms when the configuration is loaded", func() {
+		Expect((&BackendConfig{Transforms: []Transform{{Request: ".model"}}}).validateTransforms()).To(Succeed())
+		for _, t := range []Transform{
+			{},
+			{Request: ".messages[", Response: "."},
+			{Response: "undefined_function(1)"},
+			{Response: ".", Endpoints: []string{"v1/chat/completions"}},
+			{Response: ".", Timeout: "soon"},
+		} {
+			Expect((&BackendConfig{Transforms: []Transform{t}}).validateTransforms()).To(HaveOccurred(), "%+v", t)
+		}
+	})
+})
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	defaultVoiceSampleMinSeconds = 3
+	defaultVoiceSampleMaxSeconds = 30
+	defaultVoiceSampleMaxSizeMB  = 10
+)
+
+// VoiceCloning lets the TTS requests to the model clone the voice of a reference audio sample, for the backends
+// supporting it. The samples are kept by voice id, to be reused by the next requests
+type VoiceCloning struct {
+	Enabled bool `yaml:"enabled"`
+	// MinSeconds and MaxSeconds bound the duration of the samples (3 and 30 seconds by default)
+	MinSeconds float64 `yaml:"min_seconds"`
+	MaxSeconds float64 `yaml:"max_seconds"`
+	// MaxSizeMB is the maximum size of the samples (10 MB by default)
+	MaxSizeMB int `yaml:"max_size_mb"`
+}
+
+// DurationBounds returns the minimum and the maximum duration of the samples
+func (v VoiceCloning) DurationBounds() (time.Duration, time.Duration) {
+	minSeconds, maxSeconds := v.MinSeconds, v.MaxSeconds
+	if minSeconds == 0 {
+		minSeconds = defaultVoiceSampleMinSeconds
+	}
+	if maxSeconds == 0 {
+		maxSeconds = defaultVoiceSampleMaxSeconds
+	}
+	return time.Duration(minSeconds * float64(time.Second)), time.Duration(maxSeconds * float64(time.Second))
+}
+
+// MaxSize returns the maximum size of the samples, in bytes
+func (v VoiceCloning) MaxSize() int {
+	if v.MaxSizeMB > 0 {
+		return v.MaxSizeMB << 20
+	}
+	return defaultVoiceSampleMaxSizeMB << 20
+}
+
+func (c *BackendConfig) validateVoiceCloning() error {
+	v := c.VoiceCloning
+	if v.MinSeconds < 0 || v.MaxSeconds < 0 || v.MaxSizeMB < 0 {
+		return fmt.Errorf("voice_cloning: min_seconds, max_seconds and max_size_mb cannot be negative")
+	}
+	if minDuration, maxDuration := v.DurationBounds(); minDuration > maxDuration {
+		return fmt.Errorf("voice_cloning: min_seconds (%v) cannot be greater than max_seconds (%v)", minDuration.Seconds(), maxDuration.Seconds())
+	}
+	return nil
+}
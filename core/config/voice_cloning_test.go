@@ -0,0 +1,31 @@
+package config
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Voice cloning", func() {
+	It("defaults the bounds of the samples", func() {
+		minDuration, maxDuration := VoiceCloning{}.DurationBounds()
+		Expect(minDuration).To(Equal(3 * time.Second))
+		Expect(maxDuration).To(Equal(30 * time.Second))
+		Expect(VoiceCloning{}.MaxSize()).To(Equal(10 << 20))
+
+		minDuration, maxDuration = VoiceCloning{MinSeconds: 1.5, MaxSeconds: 10}.DurationBounds()
+		Expect(minDuration).To(Equal(1500 * time.Millisecond))
+		Expect(maxDuration).To(Equal(10 * time.Second))
+		Expect(VoiceCloning{MaxSizeMB: 2}.MaxSize()).To(Equal(2 << 20))
+	})
+
+	It("validates the bounds of the samples", func() {
+		c := &BackendConfig{}
+		Expect(c.validateVoiceCloning()).To(Succeed())
+		c.VoiceCloning = VoiceCloning{MinSeconds: 40}
+		Expect(c.validateVoiceCloning()).ToNot(Succeed())
+		c.VoiceCloning = VoiceCloning{MaxSizeMB: -1}
+		Expect(c.validateVoiceCloning()).ToNot(Succeed())
+	})
+})
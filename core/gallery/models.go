@@ -190,8 +190,8 @@ func InstallModel(basePath, nameOverride string, config *Config, configOverrides
 		if err != nil {
 			return fmt.Errorf("failed to unmarshal updated config YAML: %v", err)
 		}
-		if !backendConfig.Validate() {
-			return fmt.Errorf("failed to validate updated config YAML")
+		if err := backendConfig.ValidateErr(); err != nil {
+			return fmt.Errorf("failed to validate updated config YAML: %w", err)
 		}
 
 		err = os.WriteFile(configFilePath, updatedConfigYAML, 0600)
@@ -4,27 +4,32 @@ import (
 	"embed"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/dave-gray101/v2keyauth"
 	"github.com/mudler/LocalAI/pkg/utils"
 
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
 	"github.com/mudler/LocalAI/core/http/endpoints/localai"
 	"github.com/mudler/LocalAI/core/http/endpoints/openai"
 	"github.com/mudler/LocalAI/core/http/middleware"
 	"github.com/mudler/LocalAI/core/http/routes"
 
 	"github.com/mudler/LocalAI/core/application"
+	"github.com/mudler/LocalAI/core/backend"
 	"github.com/mudler/LocalAI/core/schema"
 	"github.com/mudler/LocalAI/core/services"
+	"github.com/mudler/LocalAI/pkg/model"
 
 	"github.com/gofiber/contrib/fiberzerolog"
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/csrf"
 	"github.com/gofiber/fiber/v2/middleware/favicon"
 	"github.com/gofiber/fiber/v2/middleware/filesystem"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 
 	// swagger handler
 	"github.com/rs/zerolog/log"
@@ -52,7 +57,7 @@ func API(application *application.Application) (*fiber.App, error) {
 
 	fiberCfg := fiber.Config{
 		Views:     renderEngine(),
-		BodyLimit: application.ApplicationConfig().UploadLimitMB * 1024 * 1024, // this is the default limit of 4MB
+		BodyLimit: application.ApplicationConfig().EffectiveUploadLimitMB() * 1024 * 1024,
 		// We disable the Fiber startup message as it does not conform to structured logging.
 		// We register a startup log line with connection information in the OnListen hook to keep things user friendly though
 		DisableStartupMessage: true,
@@ -62,6 +67,12 @@ func API(application *application.Application) (*fiber.App, error) {
 	if !application.ApplicationConfig().OpaqueErrors {
 		// Normally, return errors as JSON responses
 		fiberCfg.ErrorHandler = func(ctx *fiber.Ctx, err error) error {
+			// the errors carry the ID of the request, to find its logs
+			respond := func(code int, apiError *schema.APIError) error {
+				apiError.RequestID = ctx.GetRespHeader(fiber.HeaderXRequestID)
+				return ctx.Status(code).JSON(schema.ErrorResponse{Error: apiError})
+			}
+
 			// Status code defaults to 500
 			code := fiber.StatusInternalServerError
 
@@ -71,12 +82,72 @@ func API(application *application.Application) (*fiber.App, error) {
 				code = e.Code
 			}
 
+			// Request bodies exceeding the upload limit, rejected before being read
+			if errors.Is(err, fiber.ErrRequestEntityTooLarge) {
+				return respond(fiber.StatusRequestEntityTooLarge, &schema.APIError{
+					Message: fmt.Sprintf("the request body exceeds the upload limit of %d MB", application.ApplicationConfig().EffectiveUploadLimitMB()),
+					Code:    fiber.StatusRequestEntityTooLarge,
+					Type:    "invalid_request_error",
+				})
+			}
+
+			// Backends rejecting requests exceeding the context size
+			var contextLengthError *backend.ContextLengthError
+			if errors.As(err, &contextLengthError) {
+				return respond(fiber.StatusBadRequest, &schema.APIError{
+					Message: contextLengthError.Error(),
+					Code:    "context_length_exceeded",
+					Type:    "invalid_request_error",
+				})
+			}
+
+			// Models that do not exist
+			var notFoundError *fiberContext.ModelNotFoundError
+			if errors.As(err, &notFoundError) {
+				apiError := &schema.APIError{
+					Message: notFoundError.Error(),
+					Code:    "model_not_found",
+					Type:    "invalid_request_error",
+				}
+				if len(notFoundError.Suggestions) > 0 {
+					apiError.Metadata = map[string]interface{}{"suggestions": notFoundError.Suggestions}
+				}
+				return respond(fiber.StatusNotFound, apiError)
+			}
+
+			// Models still being loaded by another request
+			var loadingError *model.ModelLoadingError
+			if errors.As(err, &loadingError) {
+				metadata := map[string]interface{}{"elapsed_seconds": math.Round(loadingError.Elapsed.Seconds())}
+				if loadingError.Estimated > 0 {
+					metadata["estimated_load_seconds"] = math.Round(loadingError.Estimated.Seconds())
+				}
+				ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(loadingError.RetryAfter().Seconds())))
+				return respond(fiber.StatusServiceUnavailable, &schema.APIError{
+					Message:  loadingError.Error(),
+					Code:     "model_loading",
+					Type:     "server_error",
+					Metadata: metadata,
+				})
+			}
+
+			var errorCode any = code
+			var loadFailedError *model.ModelLoadFailedError
+			if errors.As(err, &loadFailedError) {
+				errorCode = "model_load_failed"
+			}
+
+			message := err.Error()
+			// Models can replace the message of their server errors
+			if code >= fiber.StatusInternalServerError {
+				if cfg, ok := application.BackendLoader().GetBackendConfig(fiberContext.RequestModel(ctx)); ok && cfg.ErrorMessage != "" {
+					fiberContext.Logger(ctx).Error().Err(err).Str("model", cfg.Name).Msg("request failed")
+					message = cfg.ErrorMessage
+				}
+			}
+
 			// Send custom error page
-			return ctx.Status(code).JSON(
-				schema.ErrorResponse{
-					Error: &schema.APIError{Message: err.Error(), Code: code},
-				},
-			)
+			return respond(code, &schema.APIError{Message: message, Code: errorCode})
 		}
 	} else {
 		// If OpaqueErrors are required, replace everything with a blank 500.
@@ -102,24 +173,33 @@ func API(application *application.Application) (*fiber.App, error) {
 		if listenData.TLS {
 			scheme = "https"
 		}
-		log.Info().Str("endpoint", scheme+"://"+listenData.Host+":"+listenData.Port).Msg("LocalAI API is listening! Please connect to the endpoint for API documentation.")
+		log.Info().Str("endpoint", scheme+"://"+listenData.Host+":"+listenData.Port).Int("upload_limit_mb", application.ApplicationConfig().EffectiveUploadLimitMB()).Msg("LocalAI API is listening! Please connect to the endpoint for API documentation.")
 		return nil
 	})
 
+	// Assign an ID to the requests (or use the X-Request-ID of the client) to correlate their logs
+	router.Use(requestid.New())
+	router.Use(middleware.ContextLogger())
+
 	// Have Fiber use zerolog like the rest of the application rather than it's built-in logger
 	logger := log.Logger
 	router.Use(fiberzerolog.New(fiberzerolog.Config{
-		Logger: &logger,
+		Logger:    &logger,
+		GetLogger: middleware.RequestLogger(logger, application.BackendLoader(), application.ApplicationConfig()),
+		Fields: []string{fiberzerolog.FieldIP, fiberzerolog.FieldLatency, fiberzerolog.FieldStatus, fiberzerolog.FieldMethod,
+			fiberzerolog.FieldURL, fiberzerolog.FieldError, fiberzerolog.FieldRequestID},
 	}))
 
 	// Default middleware config
 
+	router.Use(middleware.Compress(application.ApplicationConfig()))
+
 	if !application.ApplicationConfig().Debug {
 		router.Use(recover.New())
 	}
 
 	if !application.ApplicationConfig().DisableMetrics {
-		metricsService, err := services.NewLocalAIMetricsService()
+		metricsService, err := services.NewLocalAIMetricsService(application.ApplicationConfig().GPUStatsInterval > 0)
 		if err != nil {
 			return nil, err
 		}
@@ -135,30 +215,38 @@ func API(application *application.Application) (*fiber.App, error) {
 	// Health Checks should always be exempt from auth, so register these first
 	routes.HealthRoutes(router)
 
+	// The capabilities manifest is public, for service discovery, unless it requires auth
+	capabilities := localai.CapabilitiesEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig())
+	if !application.ApplicationConfig().CapabilitiesRequireAuth {
+		router.Get(localai.CapabilitiesPath, capabilities)
+	}
+
 	kaConfig, err := middleware.GetKeyAuthConfig(application.ApplicationConfig())
 	if err != nil || kaConfig == nil {
 		return nil, fmt.Errorf("failed to create key auth config: %w", err)
 	}
 
-	// Auth is applied to _all_ endpoints. No exceptions. Filtering out endpoints to bypass is the role of the Filter property of the KeyAuth Configuration
-	router.Use(v2keyauth.New(*kaConfig))
-
+	// CORS comes before the auth, as the preflight requests of the browsers do not carry the API keys
 	if application.ApplicationConfig().CORS {
-		var c func(ctx *fiber.Ctx) error
-		if application.ApplicationConfig().CORSAllowOrigins == "" {
-			c = cors.New()
-		} else {
-			c = cors.New(cors.Config{AllowOrigins: application.ApplicationConfig().CORSAllowOrigins})
-		}
-
-		router.Use(c)
+		router.Use(middleware.CORS(application.ApplicationConfig()))
 	}
 
+	// Auth is applied to _all_ endpoints. No exceptions. Filtering out endpoints to bypass is the role of the Filter property of the KeyAuth Configuration
+	router.Use(v2keyauth.New(*kaConfig))
+
 	if application.ApplicationConfig().CSRF {
 		log.Debug().Msg("Enabling CSRF middleware. Tokens are now required for state-modifying requests")
 		router.Use(csrf.New())
 	}
 
+	router.Use(middleware.APIVersion(application.ApplicationConfig()))
+	router.Use(middleware.Idempotency(application.ApplicationConfig()))
+	router.Use(middleware.ResponseTransforms)
+
+	if application.ApplicationConfig().CapabilitiesRequireAuth {
+		router.Get(localai.CapabilitiesPath, capabilities)
+	}
+
 	// Load config jsons
 	utils.LoadConfig(application.ApplicationConfig().UploadDir, openai.UploadedFilesFile, &openai.UploadedFiles)
 	utils.LoadConfig(application.ApplicationConfig().ConfigsDir, openai.AssistantsConfigFile, &openai.Assistants)
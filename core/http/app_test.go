@@ -1,12 +1,14 @@
 package http_test
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"embed"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -397,6 +399,114 @@ var _ = Describe("API test", func() {
 			})
 		})
 
+		Context("Upload limit", func() {
+			It("rejects the request bodies over the limit", func() {
+				// the body is rejected from its length, before it is sent: the server closes the connection then
+				conn, err := net.Dial("tcp", "127.0.0.1:9090")
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+				_, err = fmt.Fprintf(conn, "POST /v1/chat/completions HTTP/1.1\r\nHost: 127.0.0.1\r\nAuthorization: %s\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n",
+					bearerKey, (config.DefaultUploadLimitMB+1)*1024*1024)
+				Expect(err).ToNot(HaveOccurred())
+				resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+				Expect(err).ToNot(HaveOccurred())
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusRequestEntityTooLarge))
+
+				var errorResponse schema.ErrorResponse
+				Expect(json.NewDecoder(resp.Body).Decode(&errorResponse)).To(Succeed())
+				Expect(errorResponse.Error.Message).To(Equal(fmt.Sprintf("the request body exceeds the upload limit of %d MB", config.DefaultUploadLimitMB)))
+			})
+		})
+
+		Context("Errors", func() {
+			It("return the ID of the request", func() {
+				err, sc, body := getRequest("http://127.0.0.1:9090/api/summary?limit=ten", http.Header{
+					"Authorization": {bearerKey},
+					"X-Request-Id":  {"a-request-id"},
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sc).To(Equal(400))
+
+				var errorResponse schema.ErrorResponse
+				Expect(json.Unmarshal(body, &errorResponse)).To(Succeed())
+				Expect(errorResponse.Error.RequestID).To(Equal("a-request-id"))
+			})
+		})
+
+		Context("Summary", func() {
+			It("returns the data of the welcome page", func() {
+				err, sc, body := getRequest("http://127.0.0.1:9090/api/summary", http.Header{
+					"Authorization": {bearerKey},
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sc).To(Equal(200))
+
+				var summary map[string]any
+				Expect(json.Unmarshal(body, &summary)).To(Succeed())
+				Expect(summary).To(HaveKeyWithValue("base_url", "http://127.0.0.1:9090/"))
+				Expect(summary).To(HaveKey("models_config"))
+				Expect(summary).To(HaveKey("processing_models"))
+				Expect(summary).ToNot(HaveKey("ApplicationConfig"))
+
+				// the page keeps returning its keys to the clients expecting JSON
+				err, sc, body = getRequest("http://127.0.0.1:9090/", http.Header{
+					"Authorization": {bearerKey},
+					"Accept":        {"application/json"},
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sc).To(Equal(200))
+				var page map[string]any
+				Expect(json.Unmarshal(body, &page)).To(Succeed())
+				Expect(page).To(HaveKeyWithValue("BaseURL", "http://127.0.0.1:9090/"))
+				Expect(page).To(HaveKey("ModelsConfig"))
+				Expect(page).To(HaveKey("Title"))
+				Expect(page).To(HaveKey("ProcessingModels"))
+			})
+
+			It("filters and paginates the models", func() {
+				for _, f := range []string{"beta.bin", "Alpha.bin", "alphabet.bin", "gamma-alpha.bin"} {
+					Expect(os.WriteFile(filepath.Join(modelDir, f), []byte{}, 0600)).To(Succeed())
+				}
+
+				summary := func(query string) (int, map[string]any) {
+					err, sc, body := getRequest("http://127.0.0.1:9090/api/summary?"+query, http.Header{
+						"Authorization": {bearerKey},
+					})
+					Expect(err).ToNot(HaveOccurred())
+					var s map[string]any
+					if sc == 200 {
+						Expect(json.Unmarshal(body, &s)).To(Succeed())
+					}
+					return sc, s
+				}
+
+				sc, s := summary("q=ALPHA")
+				Expect(sc).To(Equal(200))
+				Expect(s["models"]).To(Equal([]any{"Alpha.bin", "alphabet.bin", "gamma-alpha.bin"}))
+				Expect(s["pagination"]).To(Equal(map[string]any{"total": 3.0, "offset": 0.0, "limit": 0.0}))
+
+				sc, s = summary("q=alpha&offset=1&limit=1")
+				Expect(sc).To(Equal(200))
+				Expect(s["models"]).To(Equal([]any{"alphabet.bin"}))
+				Expect(s["pagination"]).To(Equal(map[string]any{"total": 3.0, "offset": 1.0, "limit": 1.0}))
+
+				sc, s = summary("q=alpha&offset=2&limit=5")
+				Expect(sc).To(Equal(200))
+				Expect(s["models"]).To(Equal([]any{"gamma-alpha.bin"}))
+
+				sc, s = summary("q=alpha&offset=3")
+				Expect(sc).To(Equal(200))
+				Expect(s["models"]).To(BeEmpty())
+				Expect(s["pagination"]).To(HaveKeyWithValue("total", 3.0))
+
+				sc, _ = summary("offset=-1")
+				Expect(sc).To(Equal(400))
+				sc, _ = summary("limit=ten")
+				Expect(sc).To(Equal(400))
+			})
+		})
+
 		Context("Applying models", func() {
 
 			It("applies models from a gallery", func() {
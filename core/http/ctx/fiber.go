@@ -2,6 +2,7 @@ package fiberContext
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
@@ -29,6 +30,7 @@ func ModelFromContext(ctx *fiber.Ctx, cl *config.BackendConfigLoader, loader *mo
 	// If no model was specified, take the first available
 	if modelInput == "" && !bearerExists && firstModel {
 		models, _ := services.ListModels(cl, loader, config.NoFilterFn, services.SKIP_IF_CONFIGURED)
+		models = FilterAllowedModels(ctx, models)
 		if len(models) > 0 {
 			modelInput = models[0]
 			log.Debug().Msgf("No model specified, using: %s", modelInput)
@@ -43,5 +45,59 @@ func ModelFromContext(ctx *fiber.Ctx, cl *config.BackendConfigLoader, loader *mo
 		log.Debug().Msgf("Using model from bearer token: %s", bearer)
 		modelInput = bearer
 	}
+
+	if modelInput != "" && !ModelAllowed(ctx, modelInput) {
+		return "", fiber.NewError(fiber.StatusForbidden, fmt.Sprintf("the API key is not allowed to use the model %q", modelInput))
+	}
+	ctx.Locals(requestModelKey, modelInput)
 	return modelInput, nil
 }
+
+const adminKey = "admin"
+
+// SetAdmin marks whether the request is authenticated with an admin API key
+func SetAdmin(ctx *fiber.Ctx, admin bool) {
+	ctx.Locals(adminKey, admin)
+}
+
+// IsAdmin returns whether the request is authenticated with an admin API key
+func IsAdmin(ctx *fiber.Ctx) bool {
+	admin, _ := ctx.Locals(adminKey).(bool)
+	return admin
+}
+
+const requestModelKey = "requestModel"
+
+// RequestModel returns the model resolved by ModelFromContext for the request, if any
+func RequestModel(ctx *fiber.Ctx) string {
+	m, _ := ctx.Locals(requestModelKey).(string)
+	return m
+}
+
+const allowedModelsKey = "allowedModels"
+
+// SetAllowedModels restricts the request to the given models, e.g. the ones allowed for its API key.
+// A nil slice allows all the models.
+func SetAllowedModels(ctx *fiber.Ctx, models []string) {
+	ctx.Locals(allowedModelsKey, models)
+}
+
+// ModelAllowed returns whether the request is allowed to use the model
+func ModelAllowed(ctx *fiber.Ctx, model string) bool {
+	allowed, ok := ctx.Locals(allowedModelsKey).([]string)
+	if !ok || allowed == nil {
+		return true
+	}
+	return slices.Contains(allowed, model)
+}
+
+// FilterAllowedModels returns the models the request is allowed to use
+func FilterAllowedModels(ctx *fiber.Ctx, models []string) []string {
+	filtered := []string{}
+	for _, m := range models {
+		if ModelAllowed(ctx, m) {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,16 @@
+package fiberContext
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Logger returns the logger of the request, carrying its ID, set in the user context by the ContextLogger
+// middleware. It is the global logger for the requests which did not go through the middleware
+func Logger(ctx *fiber.Ctx) *zerolog.Logger {
+	if logger := zerolog.Ctx(ctx.UserContext()); logger.GetLevel() != zerolog.Disabled {
+		return logger
+	}
+	return &log.Logger
+}
@@ -0,0 +1,119 @@
+package fiberContext
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/services"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/utils"
+)
+
+// maxModelSuggestions is the maximum number of models suggested for a model not found
+const maxModelSuggestions = 3
+
+// ModelNotFoundError is returned when a request names a model that is neither configured nor in the models path
+type ModelNotFoundError struct {
+	Model string
+	// Suggestions are the existing models with the closest names, if enabled
+	Suggestions []string
+}
+
+func (e *ModelNotFoundError) Error() string {
+	msg := fmt.Sprintf("model %q not found", e.Model)
+	switch len(e.Suggestions) {
+	case 0:
+		return msg
+	case 1:
+		return fmt.Sprintf("%s, did you mean %q?", msg, e.Suggestions[0])
+	}
+	quoted := []string{}
+	for _, s := range e.Suggestions {
+		quoted = append(quoted, fmt.Sprintf("%q", s))
+	}
+	return fmt.Sprintf("%s, did you mean one of %s?", msg, strings.Join(quoted, ", "))
+}
+
+// Unwrap returns the 404 error, for the error handlers not knowing the error
+func (e *ModelNotFoundError) Unwrap() error {
+	return fiber.NewError(fiber.StatusNotFound, e.Error())
+}
+
+// CheckModelExists returns a ModelNotFoundError if the model is neither configured nor in the models path.
+// With suggest, the error suggests the models with the closest names among the ones the request can use
+func CheckModelExists(ctx *fiber.Ctx, cl *config.BackendConfigLoader, loader *model.ModelLoader, name string, suggest bool) error {
+	if _, ok := cl.GetBackendConfig(name); ok || loader.ExistsInModelPath(name) {
+		return nil
+	}
+	return NewModelNotFoundError(ctx, cl, loader, name, suggest)
+}
+
+// NewModelNotFoundError returns the error for a model not found, with the suggested models if suggest is set
+func NewModelNotFoundError(ctx *fiber.Ctx, cl *config.BackendConfigLoader, loader *model.ModelLoader, name string, suggest bool) *ModelNotFoundError {
+	e := &ModelNotFoundError{Model: name}
+	if !suggest {
+		return e
+	}
+
+	// the models are matched by their names and by the files of the configured ones
+	candidates := map[string]string{}
+	models, _ := services.ListModels(cl, loader, config.NoFilterFn, services.ALWAYS_INCLUDE)
+	for _, m := range models {
+		candidates[m] = m
+	}
+	for _, c := range cl.GetAllBackendConfigs() {
+		if _, exists := candidates[c.Model]; c.Model != "" && !exists {
+			candidates[c.Model] = c.Name
+		}
+	}
+	for alias, m := range candidates {
+		if !ModelAllowed(ctx, m) {
+			delete(candidates, alias)
+		}
+	}
+	e.Suggestions = SuggestModels(name, candidates)
+	return e
+}
+
+// SuggestModels returns the models whose names (the keys of candidates, mapped to the model they designate)
+// are close to name, the closest first. The names are compared case insensitively, with and without their
+// file extension
+func SuggestModels(name string, candidates map[string]string) []string {
+	name = strings.ToLower(name)
+	// names this far from the requested one are unrelated
+	maxDistance := max(2, len([]rune(name))/3)
+
+	distances := map[string]int{}
+	for alias, m := range candidates {
+		alias = strings.ToLower(alias)
+		d := utils.EditDistance(name, alias)
+		if ext := filepath.Ext(alias); ext != "" {
+			d = min(d, utils.EditDistance(name, strings.TrimSuffix(alias, ext)))
+		}
+		if d > maxDistance {
+			continue
+		}
+		if current, ok := distances[m]; !ok || d < current {
+			distances[m] = d
+		}
+	}
+
+	suggestions := []string{}
+	for m := range distances {
+		suggestions = append(suggestions, m)
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if distances[suggestions[i]] != distances[suggestions[j]] {
+			return distances[suggestions[i]] < distances[suggestions[j]]
+		}
+		return suggestions[i] < suggestions[j]
+	})
+	if len(suggestions) > maxModelSuggestions {
+		suggestions = suggestions[:maxModelSuggestions]
+	}
+	return suggestions
+}
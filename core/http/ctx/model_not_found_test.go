@@ -0,0 +1,43 @@
+package fiberContext
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuggestModels(t *testing.T) {
+	candidates := map[string]string{
+		"llama-3-8b":            "llama-3-8b",
+		"llama-3-70b":           "llama-3-70b",
+		"phi-2":                 "phi-2",
+		"mistral-7b.gguf":       "mistral-7b.gguf",
+		"whisper-base":          "whisper",
+		"ggml-whisper-base.bin": "whisper",
+		"whisper":               "whisper",
+	}
+
+	// the closest first, the ties by name
+	assert.Equal(t, []string{"llama-3-8b", "llama-3-70b"}, SuggestModels("lama-3-8b", candidates))
+	assert.Equal(t, []string{"llama-3-70b", "llama-3-8b"}, SuggestModels("llama-3-7b", candidates))
+	// case insensitive, and the file extensions are optional
+	assert.Equal(t, []string{"phi-2"}, SuggestModels("Phi-2", candidates))
+	assert.Equal(t, []string{"mistral-7b.gguf"}, SuggestModels("mistral-7b", candidates))
+	// the aliases suggest the model they designate, once
+	assert.Equal(t, []string{"whisper"}, SuggestModels("whisper-bse", candidates))
+	// unrelated names are not suggested
+	assert.Empty(t, SuggestModels("stablediffusion", candidates))
+	assert.Empty(t, SuggestModels("gpt-4", candidates))
+}
+
+func TestModelNotFoundError(t *testing.T) {
+	var fiberError *fiber.Error
+	assert.ErrorAs(t, &ModelNotFoundError{Model: "lama"}, &fiberError)
+	assert.Equal(t, fiber.StatusNotFound, fiberError.Code)
+
+	assert.Equal(t, `model "lama" not found`, (&ModelNotFoundError{Model: "lama"}).Error())
+	assert.Equal(t, `model "lama" not found, did you mean "llama"?`, (&ModelNotFoundError{Model: "lama", Suggestions: []string{"llama"}}).Error())
+	assert.Equal(t, `model "lama" not found, did you mean one of "llama", "llava"?`,
+		(&ModelNotFoundError{Model: "lama", Suggestions: []string{"llama", "llava"}}).Error())
+}
@@ -0,0 +1,62 @@
+package fiberContext
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+)
+
+// PriorityHeader overrides the priority of a request, for the admin API keys
+const PriorityHeader = "X-LocalAI-Priority"
+
+// EndpointType returns the type of the endpoint of the request the priorities are configured for,
+// or an empty string for the other endpoints. The requests replayed from a log are batches
+func EndpointType(ctx *fiber.Ctx) string {
+	path := strings.TrimSuffix(ctx.Path(), "/")
+	switch {
+	case strings.HasPrefix(path, "/v1/replays"):
+		return config.EndpointBatch
+	case strings.HasSuffix(path, "/chat/completions"), strings.HasSuffix(path, "/responses"):
+		return config.EndpointChat
+	case strings.HasSuffix(path, "/completions"):
+		return config.EndpointCompletion
+	case strings.HasSuffix(path, "/edits"):
+		return config.EndpointEdit
+	case strings.HasSuffix(path, "/embeddings"):
+		return config.EndpointEmbeddings
+	}
+	return ""
+}
+
+// RequestPriority returns the priority of the request: the one of its endpoint type,
+// unless overridden with the priority header
+func RequestPriority(ctx *fiber.Ctx, appConfig *config.ApplicationConfig) (int, error) {
+	header := ctx.Get(PriorityHeader)
+	if header == "" {
+		return appConfig.EndpointPriority(EndpointType(ctx)), nil
+	}
+	if len(appConfig.ApiKeys) > 0 && !IsAdmin(ctx) {
+		return 0, fiber.NewError(fiber.StatusForbidden, "overriding the priority requires an admin API key")
+	}
+	priority, err := strconv.Atoi(header)
+	if err != nil {
+		return 0, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid %s header: %q is not an integer", PriorityHeader, header))
+	}
+	return priority, nil
+}
+
+const priorityKey = "priority"
+
+// SetPriority records the effective priority of the request
+func SetPriority(ctx *fiber.Ctx, priority int) {
+	ctx.Locals(priorityKey, priority)
+}
+
+// Priority returns the effective priority of the request, if it was scheduled with one
+func Priority(ctx *fiber.Ctx) (int, bool) {
+	priority, ok := ctx.Locals(priorityKey).(int)
+	return priority, ok
+}
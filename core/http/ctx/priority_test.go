@@ -0,0 +1,75 @@
+package fiberContext
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func requestPriority(t *testing.T, appConfig *config.ApplicationConfig, path, header string, admin bool) (int, error) {
+	var priority int
+	var err error
+	app := fiber.New()
+	app.Post("/*", func(c *fiber.Ctx) error {
+		SetAdmin(c, admin)
+		priority, err = RequestPriority(c, appConfig)
+		return nil
+	})
+	req := httptest.NewRequest("POST", path, nil)
+	if header != "" {
+		req.Header.Set(PriorityHeader, header)
+	}
+	_, testErr := app.Test(req)
+	require.NoError(t, testErr)
+	return priority, err
+}
+
+func TestRequestPriority(t *testing.T) {
+	appConfig := config.NewApplicationConfig()
+	for path, expected := range map[string]int{
+		"/v1/chat/completions":          10,
+		"/chat/completions":             10,
+		"/v1/responses":                 10,
+		"/v1/completions":               10,
+		"/v1/engines/phi-2/completions": 10,
+		"/v1/edits":                     10,
+		"/v1/embeddings":                0,
+		"/v1/replays":                   0,
+		"/v1/audio/transcriptions":      0,
+	} {
+		priority, err := requestPriority(t, appConfig, path, "", false)
+		require.NoError(t, err)
+		assert.Equal(t, expected, priority, path)
+	}
+
+	appConfig = config.NewApplicationConfig(config.WithEndpointPriority(config.EndpointEmbeddings, 20))
+	priority, err := requestPriority(t, appConfig, "/v1/embeddings", "", false)
+	require.NoError(t, err)
+	assert.Equal(t, 20, priority)
+	priority, err = requestPriority(t, appConfig, "/v1/chat/completions", "", false)
+	require.NoError(t, err)
+	assert.Equal(t, 10, priority)
+}
+
+func TestRequestPriorityHeader(t *testing.T) {
+	priority, err := requestPriority(t, config.NewApplicationConfig(), "/v1/embeddings", "42", false)
+	require.NoError(t, err)
+	assert.Equal(t, 42, priority)
+
+	_, err = requestPriority(t, config.NewApplicationConfig(), "/v1/embeddings", "high", false)
+	assert.ErrorContains(t, err, "is not an integer")
+
+	// with API keys, only the admin keys can override the priority
+	appConfig := config.NewApplicationConfig(config.WithApiKeys([]string{"key"}))
+	_, err = requestPriority(t, appConfig, "/v1/embeddings", "42", false)
+	var fiberError *fiber.Error
+	require.ErrorAs(t, err, &fiberError)
+	assert.Equal(t, fiber.StatusForbidden, fiberError.Code)
+	priority, err = requestPriority(t, appConfig, "/v1/embeddings", "42", true)
+	require.NoError(t, err)
+	assert.Equal(t, 42, priority)
+}
@@ -0,0 +1,19 @@
+package fiberContext
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+)
+
+const responseTransformsKey = "responseTransforms"
+
+// SetResponseTransforms records the transforms of the model applied to the response of the request
+func SetResponseTransforms(ctx *fiber.Ctx, transforms []config.Transform) {
+	ctx.Locals(responseTransformsKey, transforms)
+}
+
+// ResponseTransforms returns the transforms applied to the response of the request, if any
+func ResponseTransforms(ctx *fiber.Ctx) []config.Transform {
+	transforms, _ := ctx.Locals(responseTransformsKey).([]config.Transform)
+	return transforms
+}
@@ -25,8 +25,7 @@ func SoundGenerationEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoad
 
 		modelFile, err := fiberContext.ModelFromContext(c, cl, ml, input.ModelID, false)
 		if err != nil {
-			modelFile = input.ModelID
-			log.Warn().Str("ModelID", input.ModelID).Msg("Model not found in context")
+			return err
 		}
 
 		cfg, err := cl.LoadBackendConfigFileByName(modelFile, appConfig.ModelPath,
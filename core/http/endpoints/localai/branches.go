@@ -0,0 +1,83 @@
+package localai
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+)
+
+// BranchesEndpoint generates a completion up to a branch point, then alternative continuations from it
+// @Summary Generate alternative continuations of a completion from a branch point.
+// @Param request body schema.BranchesRequest true "query params"
+// @Success 200 {object} schema.BranchesResponse "Response"
+// @Router /v1/completions/branches [post]
+func BranchesEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		input := new(schema.BranchesRequest)
+
+		// Get input data from the request body
+		if err := c.BodyParser(input); err != nil {
+			return err
+		}
+
+		modelFile, err := fiberContext.ModelFromContext(c, cl, ml, input.Model, true)
+		if err != nil {
+			return err
+		}
+
+		cfg, err := cl.LoadBackendConfigFileByName(modelFile, appConfig.ModelPath,
+			config.LoadOptionDebug(appConfig.Debug),
+			config.LoadOptionThreads(appConfig.Threads),
+			config.LoadOptionContextSize(appConfig.ContextSize),
+			config.LoadOptionF16(appConfig.F16),
+		)
+		if err != nil {
+			return err
+		}
+		if input.Temperature != nil {
+			cfg.Temperature = input.Temperature
+		}
+		// the branches share the prompt and the prefix: keep them in the cache of the backend
+		cfg.PromptCacheAll = true
+
+		predict := func(prompt string, maxTokens, seed int) (backend.LLMResponse, error) {
+			predictConfig := *cfg
+			predictConfig.Maxtokens = &maxTokens
+			predictConfig.Seed = &seed
+			fn, err := backend.ModelInference(c.Context(), prompt, nil, nil, nil, nil, ml, predictConfig, appConfig, nil)
+			if err != nil {
+				return backend.LLMResponse{}, err
+			}
+			resp, err := fn()
+			if err != nil {
+				contextSize := 0
+				if cfg.ContextSize != nil {
+					contextSize = *cfg.ContextSize
+				}
+				return resp, backend.ParseContextLengthError(err, contextSize)
+			}
+			return resp, nil
+		}
+
+		resp, err := backend.GenerateBranches(*input, predict)
+		if errors.Is(err, backend.ErrInvalidBranchesRequest) {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if err != nil {
+			return err
+		}
+
+		resp.ID = uuid.New().String()
+		resp.Object = "completion.branches"
+		resp.Created = int(time.Now().Unix())
+		resp.Model = input.Model
+		return c.JSON(resp)
+	}
+}
@@ -0,0 +1,97 @@
+package localai
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/middleware"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
+	"github.com/mudler/LocalAI/internal"
+	"github.com/mudler/LocalAI/pkg/model"
+)
+
+// CapabilitiesPath is the path of the capabilities manifest
+const CapabilitiesPath = "/.well-known/localai"
+
+// CapabilitiesEndpoint returns the manifest of the endpoints, the features and the limits of the instance,
+// derived from the registered routes and the configured models
+// @Summary Describe the capabilities of the LocalAI instance, for service discovery
+// @Success 200 {object} schema.CapabilitiesManifest "Response"
+// @Router /.well-known/localai [get]
+func CapabilitiesEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		models, err := services.ListModels(cl, ml, config.NoFilterFn, services.SKIP_IF_CONFIGURED)
+		if err != nil {
+			return err
+		}
+		return c.JSON(schema.CapabilitiesManifest{
+			SchemaVersion: schema.CapabilitiesSchemaVersion,
+			Version:       internal.PrintableVersion(),
+			Endpoints:     capabilitiesRoutes(c.App().GetRoutes(true)),
+			Features:      capabilitiesFeatures(cl.GetAllBackendConfigs()),
+			Limits: schema.CapabilitiesLimits{
+				UploadLimitMB:     appConfig.EffectiveUploadLimitMB(),
+				ContextSize:       appConfig.ContextSize,
+				MaxImageDimension: appConfig.MaxImageDimension,
+				MaxImageCount:     appConfig.MaxImageCount,
+			},
+			Models:      len(models),
+			APIVersions: middleware.APIVersions(appConfig),
+		})
+	}
+}
+
+// capabilitiesRoutes lists the API routes, leaving out the HEAD routes added for the GET ones, the static files and the web UI
+func capabilitiesRoutes(routes []fiber.Route) []schema.CapabilitiesRoute {
+	endpoints := []schema.CapabilitiesRoute{}
+	for _, r := range routes {
+		if r.Method == fiber.MethodHead || strings.HasPrefix(r.Path, "/static") || strings.HasPrefix(r.Path, "/swagger") {
+			continue
+		}
+		endpoints = append(endpoints, schema.CapabilitiesRoute{Method: r.Method, Path: r.Path})
+	}
+	slices.SortFunc(endpoints, func(a, b schema.CapabilitiesRoute) int {
+		if a.Path != b.Path {
+			return strings.Compare(a.Path, b.Path)
+		}
+		return strings.Compare(a.Method, b.Method)
+	})
+	return endpoints
+}
+
+// capabilitiesFeatures returns the features supported by at least one of the models
+func capabilitiesFeatures(configs []config.BackendConfig) map[string]bool {
+	usecases := map[string]config.BackendConfigUsecases{
+		"chat":             config.FLAG_CHAT,
+		"completion":       config.FLAG_COMPLETION,
+		"embeddings":       config.FLAG_EMBEDDINGS,
+		"rerank":           config.FLAG_RERANK,
+		"image_generation": config.FLAG_IMAGE,
+		"transcription":    config.FLAG_TRANSCRIPT,
+		"tts":              config.FLAG_TTS,
+		"sound_generation": config.FLAG_SOUND_GENERATION,
+	}
+	features := map[string]bool{}
+	for name := range usecases {
+		features[name] = false
+	}
+	features["vision"] = false
+	for _, cfg := range configs {
+		for name, u := range usecases {
+			if cfg.HasUsecases(u) {
+				features[name] = true
+			}
+		}
+		if cfg.HasUsecases(config.FLAG_CHAT) && (cfg.MMProj != "" || cfg.TemplateConfig.Multimodal != "") {
+			features["vision"] = true
+		}
+	}
+	// the text generation endpoints stream and call tools with every LLM backend
+	features["streaming"] = features["chat"] || features["completion"]
+	features["tools"] = features["chat"]
+	features["audio"] = features["transcription"] || features["tts"] || features["sound_generation"]
+	return features
+}
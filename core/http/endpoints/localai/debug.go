@@ -0,0 +1,41 @@
+package localai
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+// ModelDebugEndpoint returns the effective settings of a model configuration
+// @Summary Show the effective settings of a model
+// @Param name path string true "Model name"
+// @Success 200 {object} schema.ModelDebugResponse "Response"
+// @Router /debug/models/{name} [get]
+func ModelDebugEndpoint(cl *config.BackendConfigLoader) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		cfg, exists := cl.GetBackendConfig(c.Params("name"))
+		if !exists {
+			return fiber.NewError(fiber.StatusNotFound, "model not found")
+		}
+
+		resp := schema.ModelDebugResponse{
+			Name:             cfg.Name,
+			Backend:          cfg.Backend,
+			StopWords:        cfg.StopWords,
+			DerivedStopWords: cfg.DerivedStopWords,
+			RopeScaling:      cfg.RopeScalingInfo,
+		}
+		if schedule := cfg.TemperatureSchedule; schedule.Type != "" {
+			temperature := 0.0
+			if cfg.Temperature != nil {
+				temperature = *cfg.Temperature
+			}
+			resp.TemperatureSchedule = &schema.TemperatureScheduleInfo{
+				Type:      schedule.Type,
+				Points:    schedule.Points(temperature),
+				Supported: cfg.TemperatureScheduleSupported(),
+			}
+		}
+		return c.JSON(resp)
+	}
+}
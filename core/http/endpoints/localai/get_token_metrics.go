@@ -31,8 +31,7 @@ func TokenMetricsEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader,
 
 		modelFile, err := fiberContext.ModelFromContext(c, cl, ml, input.Model, false)
 		if err != nil {
-			modelFile = input.Model
-			log.Warn().Msgf("Model not found in context: %s", input.Model)
+			return err
 		}
 
 		cfg, err := cl.LoadBackendConfigFileByName(modelFile, appConfig.ModelPath,
@@ -0,0 +1,188 @@
+package localai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/proxy"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+)
+
+// passthroughCredentials are the headers carrying the LocalAI API keys, which are not forwarded to the backends
+// unless their route sets the credentials of the backend
+var passthroughCredentials = []string{fiber.HeaderAuthorization, "x-api-key", "xi-api-key"}
+
+// passthroughHopHeaders are the headers of the connections, which are not forwarded
+var passthroughHopHeaders = []string{fiber.HeaderConnection, fiber.HeaderKeepAlive, fiber.HeaderTransferEncoding, fiber.HeaderUpgrade,
+	fiber.HeaderProxyAuthenticate, fiber.HeaderProxyAuthorization, fiber.HeaderTE, fiber.HeaderTrailer, fiber.HeaderContentLength, fiber.HeaderHost}
+
+// PassthroughEndpoint forwards the request to an HTTP endpoint of the backend of a model, as configured in its passthrough routes
+// @Summary Forward a request to an endpoint of the backend of a model.
+// @Param model path string true "Model name"
+// @Param path path string true "Path of the passthrough route"
+// @Router /v1/passthrough/{model}/{path} [get]
+func PassthroughEndpoint(cl *config.BackendConfigLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		name := c.Params("model")
+		if !fiberContext.ModelAllowed(c, name) {
+			return fiber.NewError(fiber.StatusForbidden, "the API key is not allowed to use this model")
+		}
+		cfg, exists := cl.GetBackendConfig(name)
+		if !exists {
+			return fiber.NewError(fiber.StatusNotFound, "model not found")
+		}
+
+		path := "/" + c.Params("*")
+		route, target, ok := cfg.PassthroughTarget(path)
+		if !ok {
+			return fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("no passthrough route %s for model %s", path, name))
+		}
+		methods := route.AllowedMethods()
+		if !slices.Contains(methods, c.Method()) {
+			c.Set(fiber.HeaderAllow, strings.Join(methods, ", "))
+			return fiber.NewError(fiber.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed on %s", c.Method(), route.Path))
+		}
+
+		if query := c.Request().URI().QueryString(); len(query) > 0 {
+			target += "?" + string(query)
+		}
+		authorization := route.Authorization(c.Get(fiber.HeaderAuthorization))
+		for _, h := range passthroughCredentials {
+			c.Request().Header.Del(h)
+		}
+		c.Request().Header.DelCookie("token")
+		if authorization != "" {
+			c.Request().Header.Set(fiber.HeaderAuthorization, authorization)
+		}
+		// the backend gets the ID of the request, to correlate its logs with the ones of LocalAI
+		requestID := c.GetRespHeader(fiber.HeaderXRequestID)
+		if requestID != "" {
+			c.Request().Header.Set(fiber.HeaderXRequestID, requestID)
+		}
+
+		// the logs carry the ID of the request
+		logger := fiberContext.Logger(c)
+		start := time.Now()
+		if passthroughStreamed(c) {
+			return streamPassthrough(c, logger, name, path, target, requestID, route.RequestTimeout())
+		}
+		err := proxy.DoTimeout(c, target, route.RequestTimeout())
+		// the headers of the response of the backend replace the ones of LocalAI
+		if requestID != "" {
+			c.Set(fiber.HeaderXRequestID, requestID)
+		}
+		if err != nil {
+			logger.Warn().Err(err).Str("model", name).Str("method", c.Method()).Str("path", path).Str("target", target).Msg("passthrough request failed")
+			return passthroughFailed(err, errors.Is(err, fasthttp.ErrTimeout), route.RequestTimeout())
+		}
+		logger.Info().Str("model", name).Str("method", c.Method()).Str("path", path).Str("target", target).
+			Int("status", c.Response().StatusCode()).Dur("duration", time.Since(start)).Msg("passthrough request")
+		return nil
+	}
+}
+
+// passthroughFailed returns the error of a request the backend did not answer: 504 when it did not answer
+// within the timeout, 502 otherwise
+func passthroughFailed(err error, timedOut bool, timeout time.Duration) error {
+	if timedOut {
+		return fiber.NewError(fiber.StatusGatewayTimeout, fmt.Sprintf("passthrough request timed out: no response within %s", timeout))
+	}
+	return fiber.NewError(fiber.StatusBadGateway, fmt.Sprintf("passthrough request failed: %v", err))
+}
+
+// passthroughStreamed returns whether the client expects a streamed response: it accepts server-sent events, or
+// its JSON body sets stream, as the OpenAI compatible APIs do
+func passthroughStreamed(c *fiber.Ctx) bool {
+	if strings.Contains(c.Get(fiber.HeaderAccept), "text/event-stream") {
+		return true
+	}
+	if !strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON) {
+		return false
+	}
+	body := struct {
+		Stream bool `json:"stream"`
+	}{}
+	return json.Unmarshal(c.Body(), &body) == nil && body.Stream
+}
+
+// streamPassthrough forwards the request, and copies the response to the client as it is received, flushing it after
+// each read, instead of buffering it. The timeout is the time to the headers of the response. The request to the
+// target is cancelled when the client disconnects
+func streamPassthrough(c *fiber.Ctx, logger *zerolog.Logger, name, path, target, requestID string, timeout time.Duration) error {
+	// the fiber context is released before the response is streamed
+	method := c.Method()
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(c.Body()))
+	if err != nil {
+		cancel()
+		return fiber.NewError(fiber.StatusBadGateway, fmt.Sprintf("passthrough request failed: %v", err))
+	}
+	c.Request().Header.VisitAll(func(key, value []byte) {
+		req.Header.Add(string(key), string(value))
+	})
+	for _, h := range passthroughHopHeaders {
+		req.Header.Del(h)
+	}
+
+	start := time.Now()
+	timer := time.AfterFunc(timeout, cancel)
+	resp, err := http.DefaultClient.Do(req)
+	timedOut := !timer.Stop()
+	if err != nil || timedOut {
+		cancel()
+		if err == nil {
+			resp.Body.Close()
+		}
+		if timedOut {
+			err = context.DeadlineExceeded
+		}
+		logger.Warn().Err(err).Str("model", name).Str("method", method).Str("path", path).Str("target", target).Msg("passthrough request failed")
+		return passthroughFailed(err, timedOut, timeout)
+	}
+
+	c.Status(resp.StatusCode)
+	for key, values := range resp.Header {
+		if slices.ContainsFunc(passthroughHopHeaders, func(h string) bool { return strings.EqualFold(h, key) }) {
+			continue
+		}
+		for _, v := range values {
+			c.Response().Header.Add(key, v)
+		}
+	}
+	if requestID != "" {
+		c.Set(fiber.HeaderXRequestID, requestID)
+	}
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+		defer resp.Body.Close()
+		buf := make([]byte, 32<<10)
+		for {
+			n, err := resp.Body.Read(buf)
+			if n > 0 {
+				w.Write(buf[:n])
+				if flushErr := w.Flush(); flushErr != nil {
+					logger.Debug().Err(flushErr).Str("model", name).Str("path", path).Msg("passthrough client disconnected, closing the stream")
+					return
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+		logger.Info().Str("model", name).Str("method", method).Str("path", path).Str("target", target).
+			Int("status", resp.StatusCode).Dur("duration", time.Since(start)).Msg("passthrough stream")
+	})
+	return nil
+}
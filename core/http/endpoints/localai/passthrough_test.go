@@ -0,0 +1,173 @@
+package localai
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPassthroughTimeout(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer backend.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	modelPath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(modelPath, "slow.yaml"), []byte(fmt.Sprintf(`name: slow
+passthrough:
+  - path: /control
+    target: %s/control
+    timeout: 200ms
+  - path: /down
+    target: %s/down
+`, backend.URL, down.URL)), 0600))
+	cl := config.NewBackendConfigLoader(modelPath)
+	require.NoError(t, cl.LoadBackendConfigsFromPath(modelPath))
+
+	app := fiber.New()
+	app.All("/v1/passthrough/:model/*", PassthroughEndpoint(cl, config.NewApplicationConfig()))
+
+	for _, accept := range []string{"application/json", "text/event-stream"} {
+		req := httptest.NewRequest("GET", "/v1/passthrough/slow/control", nil)
+		req.Header.Set("Accept", accept)
+		start := time.Now()
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err, accept)
+		assert.Equal(t, fiber.StatusGatewayTimeout, resp.StatusCode, accept)
+		assert.Less(t, time.Since(start), 2*time.Second, accept)
+
+		// the backends which cannot be reached are not timeouts
+		req = httptest.NewRequest("GET", "/v1/passthrough/slow/down", nil)
+		req.Header.Set("Accept", accept)
+		resp, err = app.Test(req, -1)
+		require.NoError(t, err, accept)
+		assert.Equal(t, fiber.StatusBadGateway, resp.StatusCode, accept)
+	}
+}
+
+func TestPassthroughStream(t *testing.T) {
+	received := make(chan http.Header, 1)
+	next := make(chan struct{})
+	canceled := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Keep-Alive", "timeout=5")
+		fmt.Fprint(w, "data: 1\n\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-next:
+		case <-r.Context().Done():
+			return
+		}
+		fmt.Fprint(w, "data: 2\n\n")
+		w.(http.Flusher).Flush()
+		// the stream goes on until the client disconnects
+		for {
+			select {
+			case <-r.Context().Done():
+				close(canceled)
+				return
+			case <-time.After(10 * time.Millisecond):
+				fmt.Fprint(w, ": ping\n\n")
+				w.(http.Flusher).Flush()
+			}
+		}
+	}))
+	defer backend.Close()
+	defer backend.CloseClientConnections()
+
+	modelPath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(modelPath, "sse.yaml"), []byte(fmt.Sprintf(`name: sse
+passthrough:
+  - path: /events
+    target: %s/events
+`, backend.URL)), 0600))
+	cl := config.NewBackendConfigLoader(modelPath)
+	require.NoError(t, cl.LoadBackendConfigsFromPath(modelPath))
+
+	app := fiber.New()
+	app.All("/v1/passthrough/:model/*", PassthroughEndpoint(cl, config.NewApplicationConfig()))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go app.Listener(ln)
+	defer app.ShutdownWithTimeout(time.Second)
+
+	req, err := http.NewRequest("GET", "http://"+ln.Addr().String()+"/v1/passthrough/sse/events", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Authorization", "Bearer localai-key")
+	req.Header.Set("x-api-key", "localai-key")
+	req.Header.Set("Proxy-Authorization", "Basic cHJveHk6cHJveHk=")
+	req.Header.Set("X-Custom", "kept")
+	req.AddCookie(&http.Cookie{Name: "token", Value: "localai-key"})
+	// the headers are only received once flushed, with the first event
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true, ResponseHeaderTimeout: 5 * time.Second}}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Empty(t, resp.Header.Get("Keep-Alive"))
+
+	// the credentials of LocalAI and the headers of the connection are not forwarded
+	headers := <-received
+	assert.Equal(t, "kept", headers.Get("X-Custom"))
+	for _, h := range []string{"Authorization", "x-api-key", "Proxy-Authorization", "Cookie"} {
+		assert.Empty(t, headers.Get(h), h)
+	}
+
+	// the events are forwarded as they are received
+	events := make(chan string)
+	go func() {
+		reader := bufio.NewReader(resp.Body)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				close(events)
+				return
+			}
+			if strings.HasPrefix(line, "data: ") {
+				events <- strings.TrimSpace(line)
+			}
+		}
+	}()
+	select {
+	case event := <-events:
+		assert.Equal(t, "data: 1", event)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the first event was not flushed before the end of the stream")
+	}
+	close(next)
+	select {
+	case event := <-events:
+		assert.Equal(t, "data: 2", event)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the second event was not flushed")
+	}
+
+	// the request to the backend is canceled when the client disconnects
+	resp.Body.Close()
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request to the backend was not canceled")
+	}
+}
@@ -24,7 +24,33 @@ func SystemInformations(ml *model.ModelLoader, appConfig *config.ApplicationConf
 
 		sysmodels := []schema.SysInfoModel{}
 		for _, m := range loadedModels {
-			sysmodels = append(sysmodels, schema.SysInfoModel{ID: m.ID})
+			var resources *schema.ModelResources
+			if m.Resources.IsSet() {
+				resources = &schema.ModelResources{
+					CPUs:     m.Resources.CPUs,
+					MemoryMB: int(m.Resources.MemoryBytes >> 20),
+					Enforced: m.ResourcesEnforced,
+				}
+			}
+			var ropeScaling *schema.RopeScalingInfo
+			if r := m.RopeScaling; r != nil {
+				ropeScaling = &schema.RopeScalingInfo{
+					Method:             r.Method,
+					Factor:             r.Factor,
+					FreqScale:          r.FreqScale,
+					TrainedContextSize: r.TrainedContextSize,
+					ContextSize:        r.ContextSize,
+				}
+			}
+			sysmodels = append(sysmodels, schema.SysInfoModel{
+				ID:                m.ID,
+				TensorSplit:       m.TensorSplit,
+				MainGPU:           m.MainGPU,
+				TokenCacheEntries: m.TokenCache().Len(),
+				TokenCacheBytes:   m.TokenCache().Bytes(),
+				Resources:         resources,
+				RopeScaling:       ropeScaling,
+			})
 		}
 		return c.JSON(
 			schema.SystemInformationResponse{
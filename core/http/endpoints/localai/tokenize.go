@@ -26,8 +26,7 @@ func TokenizeEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, app
 
 		modelFile, err := fiberContext.ModelFromContext(c, cl, ml, input.Model, false)
 		if err != nil {
-			modelFile = input.Model
-			log.Warn().Msgf("Model not found in context: %s", input.Model)
+			return err
 		}
 
 		cfg, err := cl.LoadBackendConfigFileByName(modelFile, appConfig.ModelPath,
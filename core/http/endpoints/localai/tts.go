@@ -33,8 +33,7 @@ func TTSEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfi
 
 		modelFile, err := fiberContext.ModelFromContext(c, cl, ml, input.Model, false)
 		if err != nil {
-			modelFile = input.Model
-			log.Warn().Msgf("Model not found in context: %s", input.Model)
+			return err
 		}
 
 		cfg, err := cl.LoadBackendConfigFileByName(modelFile, appConfig.ModelPath,
@@ -65,17 +64,28 @@ func TTSEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfi
 			cfg.Voice = input.Voice
 		}
 
-		filePath, _, err := backend.ModelTTS(cfg.Backend, input.Input, modelFile, cfg.Voice, cfg.Language, ml, appConfig, *cfg)
+		sample, err := voiceSample(c, input, cfg, appConfig)
 		if err != nil {
 			return err
 		}
 
+		filePath, _, err := backend.ModelTTS(cfg.Backend, input.Input, modelFile, cfg.Voice, cfg.Language, ml, appConfig, *cfg, sample)
+		if err != nil {
+			return err
+		}
+		if sample != nil {
+			c.Set(VoiceIDHeader, sample.ID)
+		}
+
 		// Convert generated file to target format
 		filePath, err = utils.AudioConvert(filePath, input.Format)
 		if err != nil {
 			return err
 		}
 
+		if input.Stream {
+			return streamAudio(c, filePath, input, appConfig)
+		}
 		return c.Download(filePath)
 	}
 }
@@ -0,0 +1,47 @@
+package localai
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/rs/zerolog/log"
+	"github.com/valyala/fasthttp"
+)
+
+// streamAudio sends the generated audio in chunks cut at the frame boundaries, flushing each of them, so that the
+// clients can start playing it before receiving the whole file. The chunks of the request take precedence over
+// the default ones
+func streamAudio(c *fiber.Ctx, path string, input *schema.TTSRequest, appConfig *config.ApplicationConfig) error {
+	if input.ChunkSize < 0 || input.ChunkDuration < 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "chunk_size and chunk_duration cannot be negative")
+	}
+	size, duration := appConfig.TTSStreamChunkSize, appConfig.TTSStreamChunkDuration
+	if input.ChunkSize > 0 || input.ChunkDuration > 0 {
+		size, duration = input.ChunkSize, time.Duration(input.ChunkDuration*float64(time.Second))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	chunks := utils.ChunkAudio(data, size, duration)
+
+	c.Type(strings.TrimPrefix(filepath.Ext(path), "."))
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		for _, chunk := range chunks {
+			w.Write(chunk)
+			if err := w.Flush(); err != nil {
+				log.Debug().Err(err).Msg("the client of the streamed audio disconnected")
+				return
+			}
+		}
+	}))
+	return nil
+}
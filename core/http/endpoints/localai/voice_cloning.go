@@ -0,0 +1,115 @@
+package localai
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/dave-gray101/v2keyauth"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+// VoiceIDHeader is the response header with the id of the cloned voice, to reuse it in the next requests
+const VoiceIDHeader = "LocalAI-Voice-ID"
+
+// voiceSample returns the voice sample of a TTS request: the sample uploaded as the voice_sample multipart file
+// or as base64, stored under the voice id of the request (or a new one), or the sample stored for the voice id.
+// It returns nil if the request does not clone a voice
+func voiceSample(c *fiber.Ctx, input *schema.TTSRequest, cfg *config.BackendConfig, appConfig *config.ApplicationConfig) (*backend.VoiceSample, error) {
+	if input.VoiceID == "" {
+		input.VoiceID = c.FormValue("voice_id")
+	}
+	data, err := voiceSampleData(c, input, cfg.TTSConfig.VoiceCloning.MaxSize())
+	if err != nil {
+		return nil, err
+	}
+	if data == nil && input.VoiceID == "" {
+		return nil, nil
+	}
+	if !cfg.TTSConfig.VoiceCloning.Enabled {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "the model does not support voice cloning")
+	}
+
+	// the voices are stored per API key, so that the clients cannot use or replace the voices of the others,
+	// and per model, as the backends cannot share them
+	namespace := voiceNamespace(c)
+	dir := filepath.Join(appConfig.VoicesDir, namespace, cfg.Name)
+	var sample *backend.VoiceSample
+	if data == nil {
+		var exists bool
+		sample, exists = backend.StoredVoiceSample(dir, input.VoiceID)
+		if !exists {
+			return nil, fiber.NewError(fiber.StatusNotFound, "unknown voice_id "+input.VoiceID)
+		}
+	} else {
+		id := input.VoiceID
+		if id == "" {
+			id = uuid.New().String()
+		}
+		sample, err = backend.StoreVoiceSample(data, dir, id, cfg.TTSConfig.VoiceCloning)
+		if errors.Is(err, backend.ErrInvalidVoiceSample) {
+			return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	// the backends see the voices of all the clients, so they cannot tell them apart by the id of the client
+	sample.Key = path.Join(namespace, cfg.Name, sample.ID)
+	return sample, nil
+}
+
+// voiceNamespace returns the directory of the voices of the API key of the request, named by the hash of the key.
+// The voices of the requests without an API key are shared
+func voiceNamespace(c *fiber.Ctx) string {
+	key := v2keyauth.TokenFromContext(c)
+	if key == "" {
+		return "shared"
+	}
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:16])
+}
+
+// voiceSampleData reads the voice sample uploaded with the request, if any
+func voiceSampleData(c *fiber.Ctx, input *schema.TTSRequest, maxSize int) ([]byte, error) {
+	tooLarge := fiber.NewError(fiber.StatusRequestEntityTooLarge, "the voice sample is too large")
+	if file, err := c.FormFile("voice_sample"); err == nil {
+		if file.Size > int64(maxSize) {
+			return nil, tooLarge
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return io.ReadAll(f)
+	}
+	if input.VoiceSample == "" {
+		return nil, nil
+	}
+	encoded := input.VoiceSample
+	// the data URIs are accepted as well, whatever their media type
+	if strings.HasPrefix(encoded, "data:") {
+		_, encoded, _ = strings.Cut(encoded, ",")
+	}
+	if base64.StdEncoding.DecodedLen(len(encoded)) > maxSize+2 {
+		return nil, tooLarge
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "voice_sample is not valid base64")
+	}
+	if len(data) > maxSize {
+		return nil, tooLarge
+	}
+	return data, nil
+}
@@ -0,0 +1,96 @@
+package localai
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dave-gray101/v2keyauth"
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// silenceWav returns a 16 kHz mono PCM16 wav file of silence lasting the given seconds
+func silenceWav(seconds int) []byte {
+	size := 16000 * 2 * seconds
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+size))
+	buf.WriteString("WAVEfmt ")
+	for _, v := range []any{uint32(16), uint16(1), uint16(1), uint32(16000), uint32(32000), uint16(2), uint16(16)} {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(size))
+	buf.Write(make([]byte, size))
+	return buf.Bytes()
+}
+
+func TestVoiceSampleNamespace(t *testing.T) {
+	appConfig := config.NewApplicationConfig()
+	appConfig.VoicesDir = t.TempDir()
+	cfg := &config.BackendConfig{Name: "tts"}
+	cfg.TTSConfig.VoiceCloning.Enabled = true
+
+	app := fiber.New()
+	app.Use(v2keyauth.New(v2keyauth.Config{
+		Validator:  func(*fiber.Ctx, string) (bool, error) { return true, nil },
+		AuthScheme: "Bearer",
+	}))
+	app.Post("/tts", func(c *fiber.Ctx) error {
+		input := &schema.TTSRequest{}
+		if err := c.BodyParser(input); err != nil {
+			return err
+		}
+		sample, err := voiceSample(c, input, cfg, appConfig)
+		if err != nil {
+			return err
+		}
+		c.Set("Voice-Key", sample.Key)
+		return c.SendString(sample.Path)
+	})
+
+	keys := map[string]string{}
+	send := func(key, body string) (int, string) {
+		req := httptest.NewRequest("POST", "/tts", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+key)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		out, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		if voiceKey := resp.Header.Get("Voice-Key"); voiceKey != "" {
+			keys[key] = voiceKey
+		}
+		return resp.StatusCode, string(out)
+	}
+
+	sample := base64.StdEncoding.EncodeToString(silenceWav(5))
+	status, alicePath := send("alice", `{"voice_id":"voice","voice_sample":"`+sample+`"}`)
+	require.Equal(t, 200, status, alicePath)
+
+	// the voices of the other API keys are not found
+	status, _ = send("bob", `{"voice_id":"voice"}`)
+	assert.Equal(t, fiber.StatusNotFound, status)
+
+	// nor replaced
+	status, bobPath := send("bob", `{"voice_id":"voice","voice_sample":"`+sample+`"}`)
+	require.Equal(t, 200, status, bobPath)
+	assert.NotEqual(t, alicePath, bobPath)
+
+	status, path := send("alice", `{"voice_id":"voice"}`)
+	assert.Equal(t, 200, status)
+	assert.Equal(t, alicePath, path)
+
+	// the backends cache the voices by a key which is not shared by the API keys
+	assert.Regexp(t, `^[0-9a-f]{32}/tts/voice$`, keys["alice"])
+	assert.Regexp(t, `^[0-9a-f]{32}/tts/voice$`, keys["bob"])
+	assert.NotEqual(t, keys["alice"], keys["bob"])
+}
@@ -1,6 +1,10 @@
 package localai
 
 import (
+	"sort"
+	"strconv"
+	"strings"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/gallery"
@@ -9,39 +13,159 @@ import (
 	"github.com/mudler/LocalAI/core/services"
 	"github.com/mudler/LocalAI/internal"
 	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/rs/zerolog/log"
 )
 
-func WelcomeEndpoint(appConfig *config.ApplicationConfig,
-	cl *config.BackendConfigLoader, ml *model.ModelLoader, modelStatus func() (map[string]string, map[string]string)) func(*fiber.Ctx) error {
+// Summary is the data of the welcome page returned by /api/summary
+type Summary struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+	BaseURL string `json:"base_url"`
+	// Models are the model files without a configuration
+	Models        []string                   `json:"models"`
+	ModelsConfig  []config.BackendConfig     `json:"models_config"`
+	GalleryConfig map[string]*gallery.Config `json:"gallery_config"`
+	IsP2PEnabled  bool                       `json:"p2p_enabled"`
+	// ProcessingModels are the UUIDs of the jobs installing or deleting the models, by model, and TaskTypes the kind of their job
+	ProcessingModels map[string]string `json:"processing_models"`
+	TaskTypes        map[string]string `json:"task_types"`
+	Pagination       SummaryPagination `json:"pagination"`
+}
+
+// SummaryPagination is the page of the models in a Summary: the configured models, then the model files,
+// both sorted by name
+type SummaryPagination struct {
+	// Total is the number of the models matching the query, in all the pages
+	Total  int `json:"total"`
+	Offset int `json:"offset"`
+	// Limit is the maximum number of the models in the page, 0 for all of them
+	Limit int `json:"limit"`
+}
+
+// paginateModels keeps the models with a name containing the q query parameter (case-insensitive),
+// and the page of them set by the offset and limit query parameters
+func paginateModels(c *fiber.Ctx, configs []config.BackendConfig, files []string) ([]config.BackendConfig, []string, SummaryPagination, error) {
+	var page SummaryPagination
+	var err error
+	if page.Offset, err = strconv.Atoi(c.Query("offset", "0")); err != nil || page.Offset < 0 {
+		return nil, nil, page, fiber.NewError(fiber.StatusBadRequest, "offset must be a non-negative integer")
+	}
+	if page.Limit, err = strconv.Atoi(c.Query("limit", "0")); err != nil || page.Limit < 0 {
+		return nil, nil, page, fiber.NewError(fiber.StatusBadRequest, "limit must be a non-negative integer")
+	}
+
+	q := strings.ToLower(c.Query("q"))
+	var matchingConfigs []config.BackendConfig
+	for _, cfg := range configs {
+		if strings.Contains(strings.ToLower(cfg.Name), q) {
+			matchingConfigs = append(matchingConfigs, cfg)
+		}
+	}
+	var matchingFiles []string
+	for _, f := range files {
+		if strings.Contains(strings.ToLower(f), q) {
+			matchingFiles = append(matchingFiles, f)
+		}
+	}
+	sort.SliceStable(matchingConfigs, func(i, j int) bool {
+		return strings.ToLower(matchingConfigs[i].Name) < strings.ToLower(matchingConfigs[j].Name)
+	})
+	sort.SliceStable(matchingFiles, func(i, j int) bool {
+		return strings.ToLower(matchingFiles[i]) < strings.ToLower(matchingFiles[j])
+	})
+
+	page.Total = len(matchingConfigs) + len(matchingFiles)
+	start := min(page.Offset, page.Total)
+	end := page.Total
+	if page.Limit > 0 && start+page.Limit < end {
+		end = start + page.Limit
+	}
+	// the page spans the configured models, then the model files
+	clamp := func(i, n int) int { return max(0, min(i, n)) }
+	matchingConfigs = matchingConfigs[clamp(start, len(matchingConfigs)):clamp(end, len(matchingConfigs))]
+	matchingFiles = matchingFiles[clamp(start-page.Total+len(matchingFiles), len(matchingFiles)):clamp(end-page.Total+len(matchingFiles), len(matchingFiles))]
+	return matchingConfigs, matchingFiles, page, nil
+}
+
+func summarize(c *fiber.Ctx, cl *config.BackendConfigLoader, ml *model.ModelLoader, modelStatus func() (map[string]string, map[string]string)) (Summary, error) {
+	modelsWithoutConfig, err := services.ListModels(cl, ml, config.NoFilterFn, services.LOOSE_ONLY)
+	if err != nil {
+		// the page still lists the configured models
+		log.Warn().Err(err).Msg("failed listing the model files")
+	}
+
+	backendConfigs, modelsWithoutConfig, pagination, err := paginateModels(c, cl.GetAllBackendConfigs(), modelsWithoutConfig)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	galleryConfigs := map[string]*gallery.Config{}
+
+	for _, m := range backendConfigs {
+		cfg, err := gallery.GetLocalModelConfiguration(ml.ModelPath, m.Name)
+		if err != nil {
+			continue
+		}
+		galleryConfigs[m.Name] = cfg
+	}
+
+	// Get model statuses to display in the UI the operation in progress
+	processingModels, taskTypes := modelStatus()
+
+	return Summary{
+		Title:            "LocalAI API - " + internal.PrintableVersion(),
+		Version:          internal.PrintableVersion(),
+		BaseURL:          utils.BaseURL(c),
+		Models:           modelsWithoutConfig,
+		ModelsConfig:     backendConfigs,
+		GalleryConfig:    galleryConfigs,
+		IsP2PEnabled:     p2p.IsP2PEnabled(),
+		ProcessingModels: processingModels,
+		TaskTypes:        taskTypes,
+		Pagination:       pagination,
+	}, nil
+}
+
+// SummaryEndpoint returns the data of the welcome page. The models can be filtered by name with q,
+// and paginated with offset and limit
+// @Summary Show the models of the instance and the operations in progress on them
+// @Success 200 {object} Summary "Response"
+// @Router /api/summary [get]
+func SummaryEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, modelStatus func() (map[string]string, map[string]string)) func(*fiber.Ctx) error {
 	return func(c *fiber.Ctx) error {
-		backendConfigs := cl.GetAllBackendConfigs()
-		galleryConfigs := map[string]*gallery.Config{}
-
-		for _, m := range backendConfigs {
-			cfg, err := gallery.GetLocalModelConfiguration(ml.ModelPath, m.Name)
-			if err != nil {
-				continue
-			}
-			galleryConfigs[m.Name] = cfg
+		summary, err := summarize(c, cl, ml, modelStatus)
+		if err != nil {
+			return err
 		}
+		return c.JSON(summary)
+	}
+}
 
-		modelsWithoutConfig, _ := services.ListModels(cl, ml, config.NoFilterFn, services.LOOSE_ONLY)
-
-		// Get model statuses to display in the UI the operation in progress
-		processingModels, taskTypes := modelStatus()
-
-		summary := fiber.Map{
-			"Title":             "LocalAI API - " + internal.PrintableVersion(),
-			"Version":           internal.PrintableVersion(),
-			"BaseURL":           utils.BaseURL(c),
-			"Models":            modelsWithoutConfig,
-			"ModelsConfig":      backendConfigs,
-			"GalleryConfig":     galleryConfigs,
-			"IsP2PEnabled":      p2p.IsP2PEnabled(),
-			"ApplicationConfig": appConfig,
-			"ProcessingModels":  processingModels,
-			"TaskTypes":         taskTypes,
+// welcomeData is the summary with the keys the welcome page has always been rendered and returned with
+func welcomeData(summary Summary, appConfig *config.ApplicationConfig) fiber.Map {
+	return fiber.Map{
+		"Title":             summary.Title,
+		"Version":           summary.Version,
+		"BaseURL":           summary.BaseURL,
+		"Models":            summary.Models,
+		"ModelsConfig":      summary.ModelsConfig,
+		"GalleryConfig":     summary.GalleryConfig,
+		"IsP2PEnabled":      summary.IsP2PEnabled,
+		"ApplicationConfig": appConfig,
+		"ProcessingModels":  summary.ProcessingModels,
+		"TaskTypes":         summary.TaskTypes,
+		"Pagination":        summary.Pagination,
+	}
+}
+
+func WelcomeEndpoint(appConfig *config.ApplicationConfig,
+	cl *config.BackendConfigLoader, ml *model.ModelLoader, modelStatus func() (map[string]string, map[string]string)) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		s, err := summarize(c, cl, ml, modelStatus)
+		if err != nil {
+			return err
 		}
+		summary := welcomeData(s, appConfig)
 
 		if string(c.Context().Request.Header.ContentType()) == "application/json" || len(c.Accepts("html")) == 0 {
 			// The client expects a JSON response
@@ -0,0 +1,25 @@
+package openai
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+// checkBackendOverride validates the backend requested with the LocalAI specific `backend` field
+// (reserved to the admin API keys, see readRequest) by loading the model with it
+func checkBackendOverride(cfg *config.BackendConfig, ml *model.ModelLoader, appConfig *config.ApplicationConfig) error {
+	if !cfg.BackendOverride {
+		return nil
+	}
+
+	log.Debug().Str("model", cfg.Name).Str("backend", cfg.Backend).Msg("backend overridden by the request")
+	if _, err := ml.Load(backend.ModelOptions(*cfg, appConfig)...); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("the backend %q cannot load the model: %s", cfg.Backend, err.Error()))
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package openai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/rs/zerolog/log"
+)
+
+// Policies applied when the client of a stream cannot keep up
+const (
+	StreamBackpressureBlock = "block"
+	StreamBackpressureDrop  = "drop"
+)
+
+// streamBuffer holds the chunks of a stream generated by the backend and not sent to the client yet, up to
+// its size. When it is full, the backend is blocked until the client reads the chunks or, with the drop policy,
+// the client is dropped and the generation cancelled, so that slow clients cannot make the server buffer
+// the whole output
+type streamBuffer struct {
+	chunks chan schema.OpenAIResponse
+	drop   bool
+	ctx    context.Context
+	cancel context.CancelFunc
+	model  string
+
+	dropped   atomic.Bool
+	closeOnce sync.Once
+
+	// err is the error of the generation, if it failed
+	errMu sync.Mutex
+	err   error
+}
+
+func newStreamBuffer(req *schema.OpenAIRequest, appConfig *config.ApplicationConfig) *streamBuffer {
+	b := &streamBuffer{
+		drop:   appConfig.StreamBackpressure == StreamBackpressureDrop,
+		ctx:    req.Context,
+		cancel: req.Cancel,
+		model:  req.Model,
+	}
+	size := max(appConfig.StreamBufferSize, 0)
+	if b.drop {
+		// an unbuffered stream would drop the clients not waiting for the chunk at the very moment it is sent
+		size = max(size, 1)
+	}
+	b.chunks = make(chan schema.OpenAIResponse, size)
+	if b.ctx == nil {
+		b.ctx = context.Background()
+	}
+	return b
+}
+
+// Send queues the chunk for the client, blocking if the buffer is full unless the client is dropped.
+// The chunks are discarded once the client was dropped or the request cancelled
+func (b *streamBuffer) Send(resp schema.OpenAIResponse) {
+	if b.dropped.Load() {
+		return
+	}
+	if b.drop {
+		select {
+		case b.chunks <- resp:
+		default:
+			b.dropped.Store(true)
+			log.Warn().Str("model", b.model).Int("buffered_chunks", cap(b.chunks)).Msg("dropping a slow client: the stream buffer is full")
+			if b.cancel != nil {
+				b.cancel()
+			}
+		}
+		return
+	}
+	select {
+	case b.chunks <- resp:
+	case <-b.ctx.Done():
+	}
+}
+
+// Close ends the stream, once all the chunks were sent
+func (b *streamBuffer) Close() {
+	b.closeOnce.Do(func() { close(b.chunks) })
+}
+
+// Fail records the error which ended the generation, before the stream is closed
+func (b *streamBuffer) Fail(err error) {
+	b.errMu.Lock()
+	defer b.errMu.Unlock()
+	b.err = err
+}
+
+// Err returns the error which ended the generation, if it failed
+func (b *streamBuffer) Err() error {
+	b.errMu.Lock()
+	defer b.errMu.Unlock()
+	return b.err
+}
+
+// Chunks returns the chunks to send to the client
+func (b *streamBuffer) Chunks() <-chan schema.OpenAIResponse {
+	return b.chunks
+}
+
+// Dropped returns whether the client was dropped for not keeping up with the stream
+func (b *streamBuffer) Dropped() bool {
+	return b.dropped.Load()
+}
+
+// writeSlowClientError ends the stream of a dropped client with an error event
+func writeSlowClientError(w *bufio.Writer) {
+	data, _ := json.Marshal(schema.ErrorResponse{Error: &schema.APIError{
+		Message: "the client did not read the stream fast enough",
+		Code:    "slow_client",
+		Type:    "server_error",
+	}})
+	fmt.Fprintf(w, "data: %s\n\n", data)
+	w.Flush()
+}
@@ -0,0 +1,131 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStreamBuffer(policy string, size int) (*streamBuffer, context.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	req := &schema.OpenAIRequest{Context: ctx, Cancel: cancel}
+	return newStreamBuffer(req, config.NewApplicationConfig(config.WithStreamBackpressure(policy), config.WithStreamBufferSize(size))), ctx
+}
+
+func TestStreamBackpressureStress(t *testing.T) {
+	const streams, chunks, size = 50, 2000, 8
+
+	var wg sync.WaitGroup
+	for s := 0; s < streams; s++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b, _ := newTestStreamBuffer(StreamBackpressureBlock, size)
+			go func() {
+				for i := 0; i < chunks; i++ {
+					b.Send(schema.OpenAIResponse{ID: fmt.Sprint(i)})
+					// the producer never gets ahead of the client by more than the buffer
+					assert.LessOrEqual(t, len(b.Chunks()), size)
+				}
+				b.Close()
+			}()
+
+			// a client slower than the backend
+			received := 0
+			for ev := range b.Chunks() {
+				if !assert.Equal(t, fmt.Sprint(received), ev.ID) {
+					return
+				}
+				received++
+				if received%100 == 0 {
+					time.Sleep(time.Millisecond)
+				}
+			}
+			assert.Equal(t, chunks, received)
+			assert.False(t, b.Dropped())
+		}()
+	}
+	wg.Wait()
+}
+
+func TestStreamBackpressureDrop(t *testing.T) {
+	b, ctx := newTestStreamBuffer(StreamBackpressureDrop, 4)
+
+	// the client does not read: the backend must not be blocked
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			b.Send(schema.OpenAIResponse{ID: fmt.Sprint(i)})
+		}
+		b.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the backend was blocked by the slow client")
+	}
+
+	assert.True(t, b.Dropped())
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	received := 0
+	for range b.Chunks() {
+		received++
+	}
+	assert.Equal(t, 4, received)
+
+	var out bytes.Buffer
+	w := bufio.NewWriter(&out)
+	writeSlowClientError(w)
+	assert.Contains(t, out.String(), `"code":"slow_client"`)
+}
+
+func TestStreamBackpressureBlockCancelled(t *testing.T) {
+	b, _ := newTestStreamBuffer(StreamBackpressureBlock, 1)
+	b.Send(schema.OpenAIResponse{})
+
+	// the backend is blocked by the full buffer until the request is cancelled
+	sent := make(chan struct{})
+	go func() {
+		b.Send(schema.OpenAIResponse{})
+		close(sent)
+	}()
+	select {
+	case <-sent:
+		t.Fatal("the chunk was sent while the buffer was full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	b.cancel()
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the backend was still blocked after the request was cancelled")
+	}
+	require.False(t, b.Dropped())
+}
+
+func TestStreamBackpressureFailed(t *testing.T) {
+	b, _ := newTestStreamBuffer(StreamBackpressureBlock, 4)
+	require.NoError(t, b.Err())
+
+	// the error of the generation is seen once the client drained the stream
+	failure := errors.New("backend crashed")
+	go func() {
+		b.Send(schema.OpenAIResponse{})
+		b.Fail(failure)
+		b.Close()
+	}()
+	for range b.Chunks() {
+	}
+	assert.ErrorIs(t, b.Err(), failure)
+}
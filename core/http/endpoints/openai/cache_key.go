@@ -0,0 +1,18 @@
+package openai
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+// CacheKeyHeader is the response header carrying the canonical cache key of the request
+const CacheKeyHeader = "LocalAI-Cache-Key"
+
+func setCacheKeyHeader(c *fiber.Ctx, appConfig *config.ApplicationConfig, cfg *config.BackendConfig, prompt string, messages []schema.Message) {
+	if !appConfig.CacheKeyHeader {
+		return
+	}
+	c.Set(CacheKeyHeader, backend.RequestCacheKey(*cfg, prompt, messages))
+}
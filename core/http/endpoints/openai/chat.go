@@ -2,8 +2,8 @@ package openai
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -30,7 +30,7 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 	var id, textContentToReturn string
 	var created int
 
-	process := func(s string, req *schema.OpenAIRequest, config *config.BackendConfig, loader *model.ModelLoader, responses chan schema.OpenAIResponse, extraUsage bool) {
+	process := func(s string, req *schema.OpenAIRequest, config *config.BackendConfig, loader *model.ModelLoader, responses *streamBuffer, extraUsage bool) {
 		initialMessage := schema.OpenAIResponse{
 			ID:      id,
 			Created: created,
@@ -38,10 +38,17 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 			Choices: []schema.Choice{{Delta: &schema.Message{Role: "assistant", Content: &textContentToReturn}}},
 			Object:  "chat.completion.chunk",
 		}
-		responses <- initialMessage
+		responses.Send(initialMessage)
 
-		ComputeChoices(req, s, config, startupOptions, loader, func(s string, c *[]schema.Choice) {}, func(s string, tokenUsage backend.TokenUsage) bool {
-			usage := schema.OpenAIUsage{
+		// reasoning models: the reasoning is streamed in reasoning_content
+		var splitter *reasoningSplitter
+		if config.Reasoning.Enabled {
+			splitter = newReasoningSplitter(config.Reasoning)
+		}
+
+		var usage schema.OpenAIUsage
+		_, _, err := ComputeChoices(req, s, config, startupOptions, loader, func(s string, c *[]schema.Choice) {}, func(s string, tokenUsage backend.TokenUsage) bool {
+			usage = schema.OpenAIUsage{
 				PromptTokens:     tokenUsage.Prompt,
 				CompletionTokens: tokenUsage.Completion,
 				TotalTokens:      tokenUsage.Prompt + tokenUsage.Completion,
@@ -49,30 +56,62 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 			if extraUsage {
 				usage.TimingTokenGeneration = tokenUsage.TimingTokenGeneration
 				usage.TimingPromptProcessing = tokenUsage.TimingPromptProcessing
+				usage.TimingFirstToken = tokenUsage.TimingFirstToken
+			}
+
+			delta := &schema.Message{Content: &s}
+			if splitter != nil {
+				delta = reasoningDelta(splitter.Track(s, tokenUsage.Completion))
+				usage.CompletionTokensDetails = splitter.Usage()
+			}
+			if details := segmentsUsage(tokenUsage); details != nil {
+				usage.CompletionTokensDetails = details
 			}
 
 			resp := schema.OpenAIResponse{
 				ID:      id,
 				Created: created,
 				Model:   req.Model, // we have to return what the user sent here, due to OpenAI spec.
-				Choices: []schema.Choice{{Delta: &schema.Message{Content: &s}, Index: 0}},
+				Choices: []schema.Choice{{Delta: delta, Index: 0}},
 				Object:  "chat.completion.chunk",
 				Usage:   usage,
 			}
+			if tokenUsage.Repetition {
+				resp.Choices[0].FinishReason = FinishReasonRepetition
+			}
 
-			responses <- resp
+			responses.Send(resp)
 			return true
 		})
-		close(responses)
+		if err != nil {
+			log.Error().Err(err).Msg("the streamed generation failed")
+			responses.Fail(err)
+		}
+		if splitter != nil {
+			// send the text held back waiting for a delimiter
+			responses.Send(schema.OpenAIResponse{
+				ID:      id,
+				Created: created,
+				Model:   req.Model, // we have to return what the user sent here, due to OpenAI spec.
+				Choices: []schema.Choice{{Delta: reasoningDelta(splitter.Flush()), Index: 0}},
+				Object:  "chat.completion.chunk",
+				Usage:   usage,
+			})
+		}
+		responses.Close()
 	}
-	processTools := func(noAction string, prompt string, req *schema.OpenAIRequest, config *config.BackendConfig, loader *model.ModelLoader, responses chan schema.OpenAIResponse, extraUsage bool) {
+	processTools := func(noAction string, prompt string, req *schema.OpenAIRequest, config *config.BackendConfig, loader *model.ModelLoader, responses *streamBuffer, extraUsage bool) {
 		result := ""
-		_, tokenUsage, _ := ComputeChoices(req, prompt, config, startupOptions, loader, func(s string, c *[]schema.Choice) {}, func(s string, usage backend.TokenUsage) bool {
+		_, tokenUsage, err := ComputeChoices(req, prompt, config, startupOptions, loader, func(s string, c *[]schema.Choice) {}, func(s string, usage backend.TokenUsage) bool {
 			result += s
 			// TODO: Change generated BNF grammar to be compliant with the schema so we can
 			// stream the result token by token here.
 			return true
 		})
+		if err != nil {
+			log.Error().Err(err).Msg("the streamed generation failed")
+			responses.Fail(err)
+		}
 
 		textContentToReturn = functions.ParseTextContent(result, config.FunctionsConfig)
 		result = functions.CleanupLLMResult(result, config.FunctionsConfig)
@@ -89,21 +128,25 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 				Choices: []schema.Choice{{Delta: &schema.Message{Role: "assistant", Content: &textContentToReturn}}},
 				Object:  "chat.completion.chunk",
 			}
-			responses <- initialMessage
+			responses.Send(initialMessage)
 
 			result, err := handleQuestion(config, req, ml, startupOptions, functionResults, result, prompt)
 			if err != nil {
 				log.Error().Err(err).Msg("error handling question")
+				responses.Fail(err)
+				responses.Close()
 				return
 			}
 			usage := schema.OpenAIUsage{
-				PromptTokens:     tokenUsage.Prompt,
-				CompletionTokens: tokenUsage.Completion,
-				TotalTokens:      tokenUsage.Prompt + tokenUsage.Completion,
+				PromptTokens:            tokenUsage.Prompt,
+				CompletionTokens:        tokenUsage.Completion,
+				TotalTokens:             tokenUsage.Prompt + tokenUsage.Completion,
+				CompletionTokensDetails: tokenUsage.Segments,
 			}
 			if extraUsage {
 				usage.TimingTokenGeneration = tokenUsage.TimingTokenGeneration
 				usage.TimingPromptProcessing = tokenUsage.TimingPromptProcessing
+				usage.TimingFirstToken = tokenUsage.TimingFirstToken
 			}
 
 			resp := schema.OpenAIResponse{
@@ -115,11 +158,13 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 				Usage:   usage,
 			}
 
-			responses <- resp
+			responses.Send(resp)
 
 		default:
 			for i, ss := range functionResults {
 				name, args := ss.Name, ss.Arguments
+				// the chunks of a tool call share its id, which is unique within the response
+				callID := newToolCallID()
 
 				initialMessage := schema.OpenAIResponse{
 					ID:      id,
@@ -131,7 +176,7 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 							ToolCalls: []schema.ToolCall{
 								{
 									Index: i,
-									ID:    id,
+									ID:    callID,
 									Type:  "function",
 									FunctionCall: schema.FunctionCall{
 										Name: name,
@@ -141,9 +186,9 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 						}}},
 					Object: "chat.completion.chunk",
 				}
-				responses <- initialMessage
+				responses.Send(initialMessage)
 
-				responses <- schema.OpenAIResponse{
+				responses.Send(schema.OpenAIResponse{
 					ID:      id,
 					Created: created,
 					Model:   req.Model, // we have to return what the user sent here, due to OpenAI spec.
@@ -154,7 +199,7 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 							ToolCalls: []schema.ToolCall{
 								{
 									Index: i,
-									ID:    id,
+									ID:    callID,
 									Type:  "function",
 									FunctionCall: schema.FunctionCall{
 										Arguments: args,
@@ -163,14 +208,15 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 							},
 						}}},
 					Object: "chat.completion.chunk",
-				}
+				})
 			}
 		}
 
-		close(responses)
+		responses.Close()
 	}
 
-	return func(c *fiber.Ctx) error {
+	var handler fiber.Handler
+	handler = func(c *fiber.Ctx) error {
 		textContentToReturn = ""
 		id = uuid.New().String()
 		created = int(time.Now().Unix())
@@ -195,8 +241,133 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 		}
 		log.Debug().Msgf("Configuration read: %+v", config)
 
+		if len(config.Pipeline) > 0 {
+			return runPipeline(c, handler, cl, ml, startupOptions, config, input)
+		}
+		if len(config.Ensemble.Members) > 0 {
+			return runChatEnsemble(c, handler, config, input)
+		}
+		if len(config.Router.Routes) > 0 {
+			return runRouter(c, handler, cl, ml, startupOptions, config, input)
+		}
+
+		// captured before the conversation is summarized or compressed
+		dataset := newDatasetRequest(config, input)
+
+		userContent := []string{}
+		for _, m := range input.Messages {
+			if m.Role == "user" {
+				userContent = append(userContent, m.StringContent)
+			}
+		}
+		metadata := map[string]interface{}{}
+		if truncated, ok := c.Locals(truncatedMessagesKey).(int); ok {
+			metadata["truncated_messages"] = truncated
+		}
+		if err := checkBackendOverride(config, ml, startupOptions); err != nil {
+			return err
+		}
+		if config.BackendOverride {
+			metadata["backend"] = config.Backend
+		}
+		if startupOptions.ChatTemplateMetadata && config.ChatTemplateHash != "" {
+			metadata["chat_template_hash"] = config.ChatTemplateHash
+			if config.TemplateSource != "" {
+				metadata["chat_template_source"] = config.TemplateSource
+			}
+			// the full template is only exposed when debugging
+			if startupOptions.Debug {
+				metadata["chat_template"] = config.ChatTemplate
+			}
+		}
+
+		responseLanguage, err := forceResponseLanguage(config, input)
+		if err != nil {
+			return err
+		}
+		if responseLanguage != "" {
+			metadata["response_language"] = responseLanguage
+		}
+
+		languages := map[string]string{}
+		if len(config.Languages.Input) > 0 || len(config.Languages.Output) > 0 {
+			detected, err := checkInputLanguage(config, input)
+			if err != nil {
+				return err
+			}
+			languages["input"] = detected
+			metadata["detected_languages"] = languages
+		}
+
+		injectionTagged, err := checkPromptInjection(input.Context, userContent, config, cl, ml, startupOptions)
+		if err != nil {
+			return err
+		}
+		if injectionTagged {
+			metadata["prompt_injection"] = true
+		}
+
+		summarized, summaryCached, err := summarizeConversation(input.Context, input, config, cl, ml, startupOptions)
+		if err != nil {
+			return err
+		}
+		if summarized > 0 {
+			metadata["conversation_summary"] = map[string]interface{}{
+				"summarized_messages": summarized,
+				"cached":              summaryCached,
+			}
+		}
+
+		compressionRatio, err := compressPrompt(input.Context, input, config, cl, ml, startupOptions)
+		if err != nil {
+			return err
+		}
+		if compressionRatio > 0 {
+			metadata["prompt_compression_ratio"] = compressionRatio
+		}
+
+		imagesDownscaled, err := limitInputImages(input, startupOptions)
+		if err != nil {
+			return err
+		}
+		if imagesDownscaled {
+			metadata["images_downscaled"] = true
+		}
+
+		if defaultTools := injectDefaultTools(config, input); len(defaultTools) > 0 {
+			// counting the tokens of the definitions needs the model, it is done on request only
+			var tokenize func(string) (int, error)
+			if extraUsage {
+				tokenize = func(s string) (int, error) {
+					resp, err := backend.ModelTokenize(s, ml, *config, startupOptions)
+					return len(resp.Tokens), err
+				}
+			}
+			defaultToolsInfo, err := defaultToolsMetadata(defaultTools, tokenize)
+			if err != nil {
+				log.Warn().Err(err).Str("model", config.Name).Msg("failed counting the tokens of the default tools")
+				defaultToolsInfo, _ = defaultToolsMetadata(defaultTools, nil)
+			}
+			metadata["default_tools"] = defaultToolsInfo
+		}
+
 		funcs := input.Functions
 		shouldUseFn := len(input.Functions) > 0 && config.ShouldUseFunctions()
+
+		if input.TokenBudget != nil {
+			if err := backend.ValidateTokenBudget(input.TokenBudget); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+			config.SetTokenBudget(input.TokenBudget, shouldUseFn)
+		}
+		effort, effortSettings, err := config.Reasoning.ResolveEffort(input.ReasoningEffort)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if effort != "" {
+			applyReasoningEffort(config, input, effortSettings, shouldUseFn)
+			metadata["reasoning_effort"] = effort
+		}
 		strictMode := false
 
 		for _, f := range input.Functions {
@@ -318,8 +489,17 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 			}
 		}
 
+		setCacheKeyHeader(c, startupOptions, config, predInput, input.Messages)
+		setGenerationHeaders(c, startupOptions, config, input.N)
+
+		gpuStats := startGPUStats(startupOptions, config.Name)
+
 		switch {
 		case toStream:
+			format, err := streamFormat(c)
+			if err != nil {
+				return err
+			}
 
 			log.Debug().Msgf("Stream request received")
 			c.Context().SetContentType("text/event-stream")
@@ -330,7 +510,10 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 			c.Set("Transfer-Encoding", "chunked")
 			c.Set("X-Correlation-ID", id)
 
-			responses := make(chan schema.OpenAIResponse)
+			responses := newStreamBuffer(input, startupOptions)
+			started := time.Now()
+			finalChunk := announceStreamTrailers(c, startupOptions.StreamTrailers)
+			header := &c.Response().Header
 
 			if !shouldUseFn {
 				go process(predInput, input, config, ml, responses, extraUsage)
@@ -339,23 +522,30 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 			}
 
 			c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+				defer gpuStats.Stop(nil)
 				usage := &schema.OpenAIUsage{}
 				toolsCalled := false
-				for ev := range responses {
+				repeated := false
+				answer := &streamedAnswer{}
+				forwardStream(w, responses.Chunks(), startupOptions.StreamHeartbeatInterval, loadingProgress(config, ml, startupOptions), func(ev schema.OpenAIResponse) {
 					usage = &ev.Usage // Copy a pointer to the latest usage chunk so that the stop message can reference it
 					if len(ev.Choices[0].Delta.ToolCalls) > 0 {
 						toolsCalled = true
 					}
-					var buf bytes.Buffer
-					enc := json.NewEncoder(&buf)
-					enc.Encode(ev)
-					log.Debug().Msgf("Sending chunk: %s", buf.String())
-					_, err := fmt.Fprintf(w, "data: %v\n", buf.String())
-					if err != nil {
+					if takeFinishReason(&ev) == FinishReasonRepetition {
+						repeated = true
+					}
+					if dataset != nil {
+						answer.Add(ev.Choices[0].Delta)
+					}
+					if err := writeStreamChunk(w, format, ev); err != nil {
 						log.Debug().Msgf("Sending chunk failed: %v", err)
 						input.Cancel()
 					}
-					w.Flush()
+				})
+				if responses.Dropped() {
+					writeSlowClientError(w)
+					return
 				}
 
 				finishReason := "stop"
@@ -363,35 +553,70 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 					finishReason = "tool_calls"
 				} else if toolsCalled && len(input.Tools) == 0 {
 					finishReason = "function_call"
+				} else if repeated {
+					finishReason = FinishReasonRepetition
 				}
 
-				resp := &schema.OpenAIResponse{
-					ID:      id,
-					Created: created,
-					Model:   input.Model, // we have to return what the user sent here, due to OpenAI spec.
-					Choices: []schema.Choice{
-						{
-							FinishReason: finishReason,
-							Index:        0,
-							Delta:        &schema.Message{Content: &textContentToReturn},
-						}},
-					Object: "chat.completion.chunk",
-					Usage:  *usage,
-				}
-				respData, _ := json.Marshal(resp)
+				gpuStats.Stop(metadata)
+				setStreamTrailers(header, startupOptions.StreamTrailers, finishReason, *usage, started)
+				// the raw streams end with the text, their metadata is only sent in the trailers
+				if finalChunk && format == StreamFormatOpenAI {
+					resp := &schema.OpenAIResponse{
+						ID:      id,
+						Created: created,
+						Model:   input.Model, // we have to return what the user sent here, due to OpenAI spec.
+						Choices: []schema.Choice{
+							{
+								FinishReason: finishReason,
+								Index:        0,
+								Delta:        &schema.Message{Content: &textContentToReturn},
+							}},
+						Object:   "chat.completion.chunk",
+						Usage:    *usage,
+						Metadata: responseMetadata(metadata),
+					}
+					respData, _ := json.Marshal(resp)
 
-				w.WriteString(fmt.Sprintf("data: %s\n\n", respData))
+					w.WriteString(fmt.Sprintf("data: %s\n\n", respData))
+				}
 				w.WriteString("data: [DONE]\n\n")
 				w.Flush()
+
+				// interrupted and failed streams are not recorded
+				if input.Context.Err() == nil && responses.Err() == nil {
+					dataset.Record(config, startupOptions, answer.Message(), *usage)
+				}
 			}))
 			return nil
 
 		// no streaming mode
 		default:
+			defer gpuStats.Stop(nil)
+
+			// reasoning models: the reasoning is returned in reasoning_content.
+			// The output is streamed from the backend to count the reasoning tokens
+			var splitter *reasoningSplitter
+			var tokenCallback func(string, backend.TokenUsage) bool
+			if config.Reasoning.Enabled && !shouldUseFn {
+				splitter = newReasoningSplitter(config.Reasoning)
+				tokenCallback = func(s string, tokenUsage backend.TokenUsage) bool {
+					splitter.Track(s, tokenUsage.Completion)
+					return true
+				}
+			}
+
 			result, tokenUsage, err := ComputeChoices(input, predInput, config, startupOptions, ml, func(s string, c *[]schema.Choice) {
 				if !shouldUseFn {
+					message := &schema.Message{Role: "assistant", Content: &s}
+					if splitter != nil {
+						reasoning, content := splitReasoning(config.Reasoning, s)
+						message.Content = &content
+						if reasoning != "" {
+							message.ReasoningContent = &reasoning
+						}
+					}
 					// no function is called, just reply and use stop as finish reason
-					*c = append(*c, schema.Choice{FinishReason: "stop", Index: 0, Message: &schema.Message{Role: "assistant", Content: &s}})
+					*c = append(*c, schema.Choice{FinishReason: "stop", Index: 0, Message: message})
 					return
 				}
 
@@ -429,7 +654,7 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 							toolChoice.Message.Content = textContentToReturn
 							toolChoice.Message.ToolCalls = append(toolChoice.Message.ToolCalls,
 								schema.ToolCall{
-									ID:   id,
+									ID:   newToolCallID(),
 									Type: "function",
 									FunctionCall: schema.FunctionCall{
 										Name:      name,
@@ -459,10 +684,18 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 					}
 				}
 
-			}, nil)
+			}, tokenCallback)
 			if err != nil {
+				var contextLengthError *backend.ContextLengthError
+				if errors.As(err, &contextLengthError) && config.ContextOverflow == backend.ContextOverflowTruncate {
+					log.Debug().Str("model", config.Name).Msg("the request exceeds the context size, dropping the oldest message")
+					if retried, retryErr := retryTruncated(c, handler); retried {
+						return retryErr
+					}
+				}
 				return err
 			}
+			setCPUFallbackHeader(c, tokenUsage)
 			usage := schema.OpenAIUsage{
 				PromptTokens:     tokenUsage.Prompt,
 				CompletionTokens: tokenUsage.Completion,
@@ -471,23 +704,62 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 			if extraUsage {
 				usage.TimingTokenGeneration = tokenUsage.TimingTokenGeneration
 				usage.TimingPromptProcessing = tokenUsage.TimingPromptProcessing
+				usage.TimingFirstToken = tokenUsage.TimingFirstToken
+			}
+			if splitter != nil {
+				usage.CompletionTokensDetails = splitter.Usage()
+			}
+			if tokenUsage.Segments != nil {
+				usage.CompletionTokensDetails = tokenUsage.Segments
+			}
+			if tokenUsage.BudgetExhausted != "" {
+				// the output was cut by its budget
+				for i := range result {
+					result[i].FinishReason = "length"
+				}
+				metadata["token_budget_exhausted"] = tokenUsage.BudgetExhausted
+			}
+			if len(languages) > 0 && len(result) > 0 && result[0].Message != nil {
+				if content, ok := result[0].Message.Content.(*string); ok && content != nil {
+					languages["output"] = checkOutputLanguage(config, *content)
+				}
+			}
+			if config.Citations.Enabled {
+				for _, choice := range result {
+					if choice.Message == nil {
+						continue
+					}
+					if content, ok := choice.Message.Content.(*string); ok && content != nil {
+						stripped, citations := backend.ParseCitations(*content, config.Citations)
+						choice.Message.Content = &stripped
+						choice.Message.Citations = citations
+					}
+				}
 			}
 
+			gpuStats.Stop(metadata)
 			resp := &schema.OpenAIResponse{
-				ID:      id,
-				Created: created,
-				Model:   input.Model, // we have to return what the user sent here, due to OpenAI spec.
-				Choices: result,
-				Object:  "chat.completion",
-				Usage:   usage,
+				ID:       id,
+				Created:  created,
+				Model:    input.Model, // we have to return what the user sent here, due to OpenAI spec.
+				Choices:  result,
+				Object:   "chat.completion",
+				Usage:    usage,
+				Metadata: responseMetadata(metadata),
 			}
 			respData, _ := json.Marshal(resp)
 			log.Debug().Msgf("Response: %s", respData)
 
+			// outputs cut by their budget are not complete examples
+			if answer, ok := datasetAnswer(result); ok && tokenUsage.BudgetExhausted == "" {
+				dataset.Record(config, startupOptions, answer, usage)
+			}
+
 			// Return the prediction in the response body
 			return c.JSON(resp)
 		}
 	}
+	return handler
 }
 
 func handleQuestion(config *config.BackendConfig, input *schema.OpenAIRequest, ml *model.ModelLoader, o *config.ApplicationConfig, funcResults []functions.FuncCallResults, result, prompt string) (string, error) {
@@ -552,3 +824,8 @@ func handleQuestion(config *config.BackendConfig, input *schema.OpenAIRequest, m
 	}
 	return backend.Finetune(*config, prompt, prediction.Response), nil
 }
+
+// newToolCallID returns a new id for a tool call, for the clients to match the results of the calls they send back
+func newToolCallID() string {
+	return "call_" + uuid.New().String()
+}
@@ -2,10 +2,10 @@ package openai
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/mudler/LocalAI/core/backend"
@@ -30,7 +30,7 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, e
 	id := uuid.New().String()
 	created := int(time.Now().Unix())
 
-	process := func(s string, req *schema.OpenAIRequest, config *config.BackendConfig, loader *model.ModelLoader, responses chan schema.OpenAIResponse, extraUsage bool) {
+	process := func(s string, req *schema.OpenAIRequest, config *config.BackendConfig, loader *model.ModelLoader, responses *streamBuffer, extraUsage bool) {
 		ComputeChoices(req, s, config, appConfig, loader, func(s string, c *[]schema.Choice) {}, func(s string, tokenUsage backend.TokenUsage) bool {
 			usage := schema.OpenAIUsage{
 				PromptTokens:     tokenUsage.Prompt,
@@ -40,6 +40,7 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, e
 			if extraUsage {
 				usage.TimingTokenGeneration = tokenUsage.TimingTokenGeneration
 				usage.TimingPromptProcessing = tokenUsage.TimingPromptProcessing
+				usage.TimingFirstToken = tokenUsage.TimingFirstToken
 			}
 			resp := schema.OpenAIResponse{
 				ID:      id,
@@ -54,12 +55,15 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, e
 				Object: "text_completion",
 				Usage:  usage,
 			}
+			if tokenUsage.Repetition {
+				resp.Choices[0].FinishReason = FinishReasonRepetition
+			}
 			log.Debug().Msgf("Sending goroutine: %s", s)
 
-			responses <- resp
+			responses.Send(resp)
 			return true
 		})
-		close(responses)
+		responses.Close()
 	}
 
 	return func(c *fiber.Ctx) error {
@@ -81,6 +85,22 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, e
 			return fmt.Errorf("failed reading parameters from request:%w", err)
 		}
 
+		metadata := map[string]interface{}{}
+		if err := checkBackendOverride(config, ml, appConfig); err != nil {
+			return err
+		}
+		if config.BackendOverride {
+			metadata["backend"] = config.Backend
+		}
+
+		injectionTagged, err := checkPromptInjection(input.Context, config.PromptStrings, config, cl, ml, appConfig)
+		if err != nil {
+			return err
+		}
+		if injectionTagged {
+			metadata["prompt_injection"] = true
+		}
+
 		if config.ResponseFormatMap != nil {
 			d := schema.ChatCompletionResponseFormat{}
 			dat, _ := json.Marshal(config.ResponseFormatMap)
@@ -94,6 +114,11 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, e
 
 		log.Debug().Msgf("Parameter Config: %+v", config)
 
+		setCacheKeyHeader(c, appConfig, config, strings.Join(config.PromptStrings, "\n"), nil)
+		setGenerationHeaders(c, appConfig, config, input.N)
+
+		gpuStats := startGPUStats(appConfig, config.Name)
+
 		if input.Stream {
 			log.Debug().Msgf("Stream request received")
 			c.Context().SetContentType("text/event-stream")
@@ -108,6 +133,10 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, e
 			if len(config.PromptStrings) > 1 {
 				return errors.New("cannot handle more than 1 `PromptStrings` when Streaming")
 			}
+			format, err := streamFormat(c)
+			if err != nil {
+				return err
+			}
 
 			predInput := config.PromptStrings[0]
 
@@ -120,43 +149,61 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, e
 				log.Debug().Msgf("Template found, input modified to: %s", predInput)
 			}
 
-			responses := make(chan schema.OpenAIResponse)
+			responses := newStreamBuffer(input, appConfig)
+			started := time.Now()
+			finalChunk := announceStreamTrailers(c, appConfig.StreamTrailers)
+			header := &c.Response().Header
 
 			go process(predInput, input, config, ml, responses, extraUsage)
 
 			c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
-
-				for ev := range responses {
-					var buf bytes.Buffer
-					enc := json.NewEncoder(&buf)
-					enc.Encode(ev)
-
-					log.Debug().Msgf("Sending chunk: %s", buf.String())
-					fmt.Fprintf(w, "data: %v\n", buf.String())
-					w.Flush()
+				defer gpuStats.Stop(nil)
+				var usage schema.OpenAIUsage
+				finishReason := "stop"
+				forwardStream(w, responses.Chunks(), appConfig.StreamHeartbeatInterval, loadingProgress(config, ml, appConfig), func(ev schema.OpenAIResponse) {
+					usage = ev.Usage
+					if reason := takeFinishReason(&ev); reason != "" {
+						finishReason = reason
+					}
+					if err := writeStreamChunk(w, format, ev); err != nil {
+						log.Debug().Msgf("Sending chunk failed: %v", err)
+						input.Cancel()
+					}
+				})
+				if responses.Dropped() {
+					writeSlowClientError(w)
+					return
 				}
 
-				resp := &schema.OpenAIResponse{
-					ID:      id,
-					Created: created,
-					Model:   input.Model, // we have to return what the user sent here, due to OpenAI spec.
-					Choices: []schema.Choice{
-						{
-							Index:        0,
-							FinishReason: "stop",
+				gpuStats.Stop(metadata)
+				setStreamTrailers(header, appConfig.StreamTrailers, finishReason, usage, started)
+				// the raw streams end with the text, their metadata is only sent in the trailers
+				if finalChunk && format == StreamFormatOpenAI {
+					resp := &schema.OpenAIResponse{
+						ID:      id,
+						Created: created,
+						Model:   input.Model, // we have to return what the user sent here, due to OpenAI spec.
+						Choices: []schema.Choice{
+							{
+								Index:        0,
+								FinishReason: finishReason,
+							},
 						},
-					},
-					Object: "text_completion",
-				}
-				respData, _ := json.Marshal(resp)
+						Object:   "text_completion",
+						Metadata: responseMetadata(metadata),
+					}
+					respData, _ := json.Marshal(resp)
 
-				w.WriteString(fmt.Sprintf("data: %s\n\n", respData))
+					w.WriteString(fmt.Sprintf("data: %s\n\n", respData))
+				}
 				w.WriteString("data: [DONE]\n\n")
 				w.Flush()
 			}))
 			return nil
 		}
 
+		defer gpuStats.Stop(nil)
+
 		var result []schema.Choice
 
 		totalTokenUsage := backend.TokenUsage{}
@@ -178,8 +225,12 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, e
 			if err != nil {
 				return err
 			}
+			setCPUFallbackHeader(c, tokenUsage)
 
 			totalTokenUsage.TimingTokenGeneration += tokenUsage.TimingTokenGeneration
+			if totalTokenUsage.TimingFirstToken == 0 {
+				totalTokenUsage.TimingFirstToken = tokenUsage.TimingFirstToken
+			}
 			totalTokenUsage.TimingPromptProcessing += tokenUsage.TimingPromptProcessing
 
 			result = append(result, r...)
@@ -192,15 +243,18 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, e
 		if extraUsage {
 			usage.TimingTokenGeneration = totalTokenUsage.TimingTokenGeneration
 			usage.TimingPromptProcessing = totalTokenUsage.TimingPromptProcessing
+			usage.TimingFirstToken = totalTokenUsage.TimingFirstToken
 		}
 
+		gpuStats.Stop(metadata)
 		resp := &schema.OpenAIResponse{
-			ID:      id,
-			Created: created,
-			Model:   input.Model, // we have to return what the user sent here, due to OpenAI spec.
-			Choices: result,
-			Object:  "text_completion",
-			Usage:   usage,
+			ID:       id,
+			Created:  created,
+			Model:    input.Model, // we have to return what the user sent here, due to OpenAI spec.
+			Choices:  result,
+			Object:   "text_completion",
+			Usage:    usage,
+			Metadata: responseMetadata(metadata),
 		}
 
 		jsonResult, _ := json.Marshal(resp)
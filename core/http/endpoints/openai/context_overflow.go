@@ -0,0 +1,46 @@
+package openai
+
+import (
+	"encoding/json"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// truncatedMessagesKey counts the messages dropped from the conversation to fit the context of the model
+const truncatedMessagesKey = "truncatedMessages"
+
+// retryTruncated retries the chat request without its oldest message, after the backend rejected it for
+// exceeding the context size. The system messages and the last message are never dropped: it returns
+// false if there is nothing left to drop
+func retryTruncated(c *fiber.Ctx, chat fiber.Handler) (bool, error) {
+	req := map[string]interface{}{}
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return false, err
+	}
+
+	messages, _ := req["messages"].([]interface{})
+	drop := -1
+	for i := 0; i < len(messages)-1; i++ {
+		if m, ok := messages[i].(map[string]interface{}); ok && m["role"] != "system" {
+			drop = i
+			break
+		}
+	}
+	if drop < 0 {
+		return false, nil
+	}
+	req["messages"] = append(messages[:drop:drop], messages[drop+1:]...)
+
+	truncated, _ := c.Locals(truncatedMessagesKey).(int)
+	subCtx, err := subRequest(c, chat, req, func(sub *fiber.Ctx) {
+		sub.Locals(truncatedMessagesKey, truncated+1)
+	})
+	if err != nil {
+		return true, err
+	}
+
+	c.Status(subCtx.Response.StatusCode())
+	c.Response().Header.SetContentTypeBytes(subCtx.Response.Header.ContentType())
+	c.Response().SetBody(subCtx.Response.Body())
+	return true, nil
+}
@@ -0,0 +1,70 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryTruncated(t *testing.T) {
+	// the backend accepts up to 3 messages
+	var handler fiber.Handler
+	handler = func(c *fiber.Ctx) error {
+		req := schema.OpenAIRequest{}
+		if err := json.Unmarshal(c.Body(), &req); err != nil {
+			return err
+		}
+		if len(req.Messages) > 3 {
+			err := backend.ParseContextLengthError(errors.New("rpc error: code = Unknown desc = prompt is too long (5000 tokens, max 4096)"), 0)
+			if retried, retryErr := retryTruncated(c, handler); retried {
+				return retryErr
+			}
+			return err
+		}
+		contents := []string{}
+		for _, m := range req.Messages {
+			contents = append(contents, m.Content.(string))
+		}
+		truncated, _ := c.Locals(truncatedMessagesKey).(int)
+		return c.JSON(map[string]interface{}{"contents": strings.Join(contents, ","), "truncated": truncated})
+	}
+
+	run := func(body string) (int, map[string]interface{}) {
+		app := fiber.New()
+		app.Post("/", handler)
+		resp, err := app.Test(httptest.NewRequest("POST", "/", strings.NewReader(body)))
+		assert.NoError(t, err)
+		data, _ := io.ReadAll(resp.Body)
+		result := map[string]interface{}{}
+		json.Unmarshal(data, &result)
+		return resp.StatusCode, result
+	}
+
+	status, result := run(`{"model": "m", "messages": [
+		{"role": "system", "content": "sys"},
+		{"role": "user", "content": "u1"},
+		{"role": "assistant", "content": "a1"},
+		{"role": "user", "content": "u2"},
+		{"role": "assistant", "content": "a2"},
+		{"role": "user", "content": "u3"}]}`)
+	assert.Equal(t, fiber.StatusOK, status)
+	// the system message and the last messages are kept
+	assert.Equal(t, "sys,a2,u3", result["contents"])
+	assert.Equal(t, float64(3), result["truncated"])
+
+	// only system messages and the last message: nothing can be dropped
+	status, _ = run(`{"model": "m", "messages": [
+		{"role": "system", "content": "s1"},
+		{"role": "system", "content": "s2"},
+		{"role": "system", "content": "s3"},
+		{"role": "user", "content": "u1"}]}`)
+	assert.Equal(t, fiber.StatusInternalServerError, status)
+}
@@ -0,0 +1,160 @@
+package openai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+// maxConversationSummaries is the number of summaries kept in memory
+const maxConversationSummaries = 256
+
+// summaryCache keeps the summaries of the conversations, keyed by the summarized messages, so that
+// the following turns of a conversation reuse (and extend) its summary instead of summarizing it again
+type summaryCache struct {
+	sync.Mutex
+	summaries map[string]string
+	order     []string
+}
+
+var conversationSummaries = &summaryCache{summaries: map[string]string{}}
+
+func (s *summaryCache) Get(key string) (string, bool) {
+	s.Lock()
+	defer s.Unlock()
+	summary, ok := s.summaries[key]
+	return summary, ok
+}
+
+func (s *summaryCache) Add(key, summary string) {
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.summaries[key]; !ok {
+		s.order = append(s.order, key)
+	}
+	s.summaries[key] = summary
+	for len(s.order) > maxConversationSummaries {
+		delete(s.summaries, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// summaryKey identifies the summary of the messages written by the summarizer model
+func summaryKey(summarizer string, messages []schema.Message) string {
+	h := sha256.New()
+	h.Write([]byte(summarizer))
+	for _, m := range messages {
+		data, _ := json.Marshal(m)
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// olderMessages returns the indexes of the messages to summarize: the non-system messages but the last keep ones.
+// The results of the tool calls are kept together with the call
+func olderMessages(messages []schema.Message, keep int) []int {
+	candidates := []int{}
+	for i, m := range messages {
+		if m.Role != "system" {
+			candidates = append(candidates, i)
+		}
+	}
+	n := len(candidates) - keep
+	for n > 0 && messages[candidates[n]].Role == "tool" {
+		n--
+	}
+	if n <= 0 {
+		return nil
+	}
+	return candidates[:n]
+}
+
+// replaceWithSummary replaces the older messages with a system message carrying their summary
+func replaceWithSummary(messages []schema.Message, older []int, summary string) []schema.Message {
+	summarized := map[int]bool{}
+	for _, i := range older {
+		summarized[i] = true
+	}
+	result := []schema.Message{}
+	for i, m := range messages {
+		if !summarized[i] {
+			result = append(result, m)
+			continue
+		}
+		if i == older[0] {
+			content := backend.ConversationSummaryPrefix + summary
+			result = append(result, schema.Message{Role: "system", Content: content, StringContent: content})
+		}
+	}
+	return result
+}
+
+// summarizeConversation replaces the older messages of the conversation with their summary when the messages exceed
+// the threshold configured for the model. It returns the number of summarized messages, and if the summary was cached
+func summarizeConversation(ctx context.Context, input *schema.OpenAIRequest, cfg *config.BackendConfig, cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) (int, bool, error) {
+	c := cfg.ConversationSummary
+	if !c.Enabled {
+		return 0, false, nil
+	}
+
+	threshold := c.Threshold
+	if threshold == 0 && cfg.ContextSize != nil {
+		threshold = *cfg.ContextSize * 3 / 4
+	}
+	// roughly 4 characters per token
+	if messagesSize(input.Messages)/4 <= threshold {
+		return 0, false, nil
+	}
+	older := olderMessages(input.Messages, c.KeptMessages())
+	if len(older) == 0 {
+		return 0, false, nil
+	}
+	messages := []schema.Message{}
+	for _, i := range older {
+		messages = append(messages, input.Messages[i])
+	}
+
+	summaryConfig := cfg
+	if c.Model != "" && c.Model != cfg.Name {
+		var err error
+		summaryConfig, err = cl.LoadBackendConfigFileByName(c.Model, appConfig.ModelPath,
+			config.LoadOptionDebug(appConfig.Debug),
+			config.LoadOptionThreads(appConfig.Threads),
+			config.LoadOptionContextSize(appConfig.ContextSize),
+			config.LoadOptionF16(appConfig.F16),
+		)
+		if err != nil {
+			return 0, false, err
+		}
+	}
+
+	// the longest summarized beginning of the conversation is extended with the following messages
+	previous, summarized := "", 0
+	for n := len(messages); n > 0; n-- {
+		if summary, ok := conversationSummaries.Get(summaryKey(summaryConfig.Name, messages[:n])); ok {
+			previous, summarized = summary, n
+			break
+		}
+	}
+	summary := previous
+	if summarized < len(messages) {
+		var err error
+		summary, err = backend.SummarizeConversation(ctx, previous, messages[summarized:], c, *summaryConfig, ml, appConfig)
+		if err != nil {
+			return 0, false, err
+		}
+		conversationSummaries.Add(summaryKey(summaryConfig.Name, messages), summary)
+	}
+
+	input.Messages = replaceWithSummary(input.Messages, older, summary)
+	log.Debug().Str("model", cfg.Name).Int("summarized", len(older)).Bool("cached", summarized == len(messages)).Msg("conversation summarized")
+	return len(older), summarized == len(messages), nil
+}
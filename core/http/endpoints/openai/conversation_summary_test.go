@@ -0,0 +1,86 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOlderMessages(t *testing.T) {
+	messages := []schema.Message{
+		{Role: "system"}, {Role: "user"}, {Role: "assistant"}, {Role: "user"},
+		{Role: "assistant", ToolCalls: []schema.ToolCall{{ID: "1"}}}, {Role: "tool"}, {Role: "assistant"},
+	}
+	assert.Equal(t, []int{1, 2, 3}, olderMessages(messages, 3))
+	// the results of the tool calls are kept with the call
+	assert.Equal(t, []int{1, 2, 3}, olderMessages(messages, 2))
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, olderMessages(messages, 1))
+	assert.Nil(t, olderMessages(messages, 6))
+}
+
+func TestSummarizeConversation(t *testing.T) {
+	turns := []string{}
+	for i := 0; i < 6; i++ {
+		turns = append(turns, fmt.Sprintf("turn %d: %s", i, strings.Repeat("lorem ipsum ", 20)))
+	}
+	input := func() *schema.OpenAIRequest {
+		req := &schema.OpenAIRequest{Messages: []schema.Message{{Role: "system", Content: "be brief", StringContent: "be brief"}}}
+		for i, turn := range turns {
+			role := "user"
+			if i%2 == 1 {
+				role = "assistant"
+			}
+			req.Messages = append(req.Messages, schema.Message{Role: role, Content: turn, StringContent: turn})
+		}
+		return req
+	}
+
+	keep := 2
+	cfg := &config.BackendConfig{Name: "chat", ConversationSummary: config.ConversationSummary{Enabled: true, Threshold: 10, KeepMessages: &keep}}
+	// the summary of the first four turns is already known: no model is run
+	req := input()
+	conversationSummaries.Add(summaryKey("chat", req.Messages[1:5]), "the user greeted the assistant")
+
+	summarized, cached, err := summarizeConversation(context.Background(), req, cfg, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 4, summarized)
+	assert.True(t, cached)
+	require.Len(t, req.Messages, 4)
+	assert.Equal(t, "be brief", req.Messages[0].StringContent)
+	assert.Equal(t, "system", req.Messages[1].Role)
+	assert.Equal(t, backend.ConversationSummaryPrefix+"the user greeted the assistant", req.Messages[1].StringContent)
+	// the recent turns are kept verbatim
+	assert.Equal(t, turns[4], req.Messages[2].StringContent)
+	assert.Equal(t, turns[5], req.Messages[3].StringContent)
+
+	// below the threshold
+	cfg.ConversationSummary.Threshold = 1000
+	req = input()
+	summarized, _, err = summarizeConversation(context.Background(), req, cfg, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Zero(t, summarized)
+	assert.Len(t, req.Messages, 7)
+
+	cfg.ConversationSummary = config.ConversationSummary{Threshold: 10}
+	summarized, _, _ = summarizeConversation(context.Background(), input(), cfg, nil, nil, nil)
+	assert.Zero(t, summarized)
+}
+
+func TestSummaryCache(t *testing.T) {
+	cache := &summaryCache{summaries: map[string]string{}}
+	for i := 0; i < maxConversationSummaries+10; i++ {
+		cache.Add(fmt.Sprint(i), "summary")
+	}
+	_, ok := cache.Get("0")
+	assert.False(t, ok)
+	_, ok = cache.Get(fmt.Sprint(maxConversationSummaries + 9))
+	assert.True(t, ok)
+	assert.Len(t, cache.summaries, maxConversationSummaries)
+}
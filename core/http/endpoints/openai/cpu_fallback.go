@@ -0,0 +1,17 @@
+package openai
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+)
+
+// CPUFallbackHeader is set on the responses generated on the CPU because the GPU ran out of memory
+const CPUFallbackHeader = "LocalAI-CPU-Fallback"
+
+// setCPUFallbackHeader reports the outputs generated on the CPU. The streamed responses send their headers before
+// the generation, and do not report it
+func setCPUFallbackHeader(c *fiber.Ctx, usage backend.TokenUsage) {
+	if usage.CPUFallback {
+		c.Set(CPUFallbackHeader, "true")
+	}
+}
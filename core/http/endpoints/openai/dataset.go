@@ -0,0 +1,290 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/functions"
+	"github.com/rs/zerolog/log"
+)
+
+const datasetRedacted = "[REDACTED]"
+
+// builtinRedactions are always applied to the recorded texts, so that the most common personal data and
+// secrets do not end up in the datasets
+var builtinRedactions = []*regexp.Regexp{
+	// e-mail addresses
+	regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	// API keys and tokens
+	regexp.MustCompile(`\b(?:sk|pk|rk)-[A-Za-z0-9_-]{16,}`),
+	regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),
+	regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/-]{16,}=*`),
+	// card numbers
+	regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+	// phone numbers
+	regexp.MustCompile(`\+\d[\d ().-]{7,}\d`),
+	regexp.MustCompile(`\(\d{3}\)\s?\d{3}[ .-]\d{4}\b`),
+}
+
+// datasetRecord is a line of a dataset. The messages are in the format of the chat fine-tuning datasets
+type datasetRecord struct {
+	Messages []schema.Message    `json:"messages"`
+	Tools    []functions.Tool    `json:"tools,omitempty"`
+	Model    string              `json:"model,omitempty"`
+	Created  int64               `json:"created,omitempty"`
+	Usage    *schema.OpenAIUsage `json:"usage,omitempty"`
+}
+
+// datasetRequest is the part of a chat request recorded in the dataset, captured before the request is
+// transformed (e.g. summarized or compressed)
+type datasetRequest struct {
+	messages []schema.Message
+	tools    []functions.Tool
+}
+
+// newDatasetRequest captures the request if the model records a dataset and the user consents to it.
+// It returns nil otherwise
+func newDatasetRequest(cfg *config.BackendConfig, input *schema.OpenAIRequest) *datasetRequest {
+	if !cfg.Dataset.Enabled || !input.Store {
+		return nil
+	}
+	r := &datasetRequest{}
+	for _, m := range input.Messages {
+		// only the text is recorded: images, videos and audios never are
+		r.messages = append(r.messages, schema.Message{Role: m.Role, Name: m.Name, Content: m.StringContent, ToolCalls: m.ToolCalls})
+	}
+	for _, f := range input.Functions {
+		r.tools = append(r.tools, functions.Tool{Type: "function", Function: f})
+	}
+	return r
+}
+
+// datasetRedactor replaces the personal data and the secrets in the recorded texts
+type datasetRedactor []*regexp.Regexp
+
+func newDatasetRedactor(d config.Dataset) datasetRedactor {
+	r := append(datasetRedactor{}, builtinRedactions...)
+	for _, rule := range d.Redact {
+		// the rules are checked when the configuration is loaded
+		if re, err := regexp.Compile(rule); err == nil {
+			r = append(r, re)
+		}
+	}
+	return r
+}
+
+func (r datasetRedactor) Redact(s string) string {
+	for _, re := range r {
+		s = re.ReplaceAllString(s, datasetRedacted)
+	}
+	return s
+}
+
+func (r datasetRedactor) message(m schema.Message) schema.Message {
+	if content, ok := m.Content.(string); ok {
+		m.Content = r.Redact(content)
+	}
+	if len(m.ToolCalls) > 0 {
+		calls := make([]schema.ToolCall, len(m.ToolCalls))
+		for i, call := range m.ToolCalls {
+			call.FunctionCall.Arguments = r.Redact(call.FunctionCall.Arguments)
+			calls[i] = call
+		}
+		m.ToolCalls = calls
+	}
+	return m
+}
+
+// Record appends the answer of a completed request to the dataset of the model
+func (r *datasetRequest) Record(cfg *config.BackendConfig, appConfig *config.ApplicationConfig, answer schema.Message, usage schema.OpenAIUsage) {
+	if r == nil {
+		return
+	}
+	redactor := newDatasetRedactor(cfg.Dataset)
+
+	record := datasetRecord{}
+	for _, m := range r.messages {
+		record.Messages = append(record.Messages, redactor.message(m))
+	}
+	record.Messages = append(record.Messages, redactor.message(schema.Message{Role: "assistant", Content: answer.Content, ToolCalls: answer.ToolCalls}))
+	if cfg.Dataset.HasField("tools") {
+		record.Tools = r.tools
+	}
+	if cfg.Dataset.HasField("model") {
+		record.Model = cfg.Name
+	}
+	if cfg.Dataset.HasField("created") {
+		record.Created = time.Now().Unix()
+	}
+	if cfg.Dataset.HasField("usage") {
+		record.Usage = &usage
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		log.Error().Err(err).Str("model", cfg.Name).Msg("failed encoding the dataset record")
+		return
+	}
+	w := datasets.Get(cfg.DatasetDir(appConfig.ModelPath), cfg.Dataset.MaxSize(), cfg.Name)
+	if err := w.Append(append(line, '\n')); err != nil {
+		log.Error().Err(err).Str("model", cfg.Name).Msg("failed writing the dataset record")
+	}
+}
+
+// datasetAnswer returns the answer of a non streamed chat completion
+func datasetAnswer(choices []schema.Choice) (schema.Message, bool) {
+	if len(choices) == 0 || choices[0].Message == nil {
+		return schema.Message{}, false
+	}
+	m := choices[0].Message
+	answer := schema.Message{ToolCalls: m.ToolCalls}
+	switch content := m.Content.(type) {
+	case string:
+		answer.Content = content
+	case *string:
+		if content != nil {
+			answer.Content = *content
+		}
+	}
+	return answer, true
+}
+
+// streamedAnswer accumulates the answer of a streamed chat completion from its chunks
+type streamedAnswer struct {
+	content   string
+	toolCalls []schema.ToolCall
+}
+
+func (a *streamedAnswer) Add(delta *schema.Message) {
+	if delta == nil {
+		return
+	}
+	if content, ok := delta.Content.(*string); ok && content != nil {
+		a.content += *content
+	}
+	for _, call := range delta.ToolCalls {
+		for len(a.toolCalls) <= call.Index {
+			a.toolCalls = append(a.toolCalls, schema.ToolCall{Index: len(a.toolCalls), Type: "function"})
+		}
+		c := &a.toolCalls[call.Index]
+		if call.ID != "" {
+			c.ID = call.ID
+		}
+		c.FunctionCall.Name += call.FunctionCall.Name
+		c.FunctionCall.Arguments += call.FunctionCall.Arguments
+	}
+}
+
+func (a *streamedAnswer) Message() schema.Message {
+	return schema.Message{Content: a.content, ToolCalls: a.toolCalls}
+}
+
+// datasetWriters holds the writers of the datasets, shared by the requests to the same model
+type datasetWriters struct {
+	mu      sync.Mutex
+	writers map[string]*datasetWriter
+}
+
+var datasets = &datasetWriters{writers: map[string]*datasetWriter{}}
+
+func (d *datasetWriters) Get(dir string, maxSize int64, model string) *datasetWriter {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	w, ok := d.writers[dir]
+	if !ok {
+		log.Warn().Str("model", model).Str("dir", dir).Msg("recording the requests of the consenting users in a dataset: the records may still contain personal data the redaction rules do not catch")
+		w = &datasetWriter{dir: dir, now: time.Now}
+		d.writers[dir] = w
+	}
+	w.SetMaxSize(maxSize)
+	return w
+}
+
+// datasetWriter appends lines to JSONL files named after their date, rotated daily and when they exceed
+// the maximum size. The files are only readable by the owner
+type datasetWriter struct {
+	mu      sync.Mutex
+	dir     string
+	maxSize int64
+	now     func() time.Time
+
+	file  *os.File
+	date  string
+	index int
+	size  int64
+}
+
+func (w *datasetWriter) SetMaxSize(maxSize int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.maxSize = maxSize
+}
+
+func (w *datasetWriter) Append(line []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	date := w.now().UTC().Format("2006-01-02")
+	if w.file != nil && date != w.date {
+		w.file.Close()
+		w.file = nil
+	}
+	if w.file == nil {
+		if err := w.open(date); err != nil {
+			return err
+		}
+	}
+	if w.size > 0 && w.size+int64(len(line)) > w.maxSize {
+		w.file.Close()
+		w.file = nil
+		w.index++
+		if err := w.open(date); err != nil {
+			return err
+		}
+	}
+	n, err := w.file.Write(line)
+	w.size += int64(n)
+	return err
+}
+
+func (w *datasetWriter) open(date string) error {
+	if err := os.MkdirAll(w.dir, 0700); err != nil {
+		return err
+	}
+	if date != w.date {
+		// the records are appended to the last file of the day, e.g. after a restart
+		w.index = 0
+		for {
+			if _, err := os.Stat(w.path(date, w.index+1)); err != nil {
+				break
+			}
+			w.index++
+		}
+	}
+	f, err := os.OpenFile(w.path(date, w.index), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file, w.date, w.size = f, date, info.Size()
+	return nil
+}
+
+func (w *datasetWriter) path(date string, index int) string {
+	name := date
+	if index > 0 {
+		name += fmt.Sprintf(".%d", index)
+	}
+	return filepath.Join(w.dir, name+".jsonl")
+}
@@ -0,0 +1,38 @@
+package openai
+
+import (
+	"encoding/json"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/functions"
+)
+
+// injectDefaultTools adds the default tools of the model to the request, returning the tools added
+func injectDefaultTools(cfg *config.BackendConfig, input *schema.OpenAIRequest) []functions.Tool {
+	tools := cfg.ToolsFor(input.Functions)
+	for _, t := range tools {
+		input.Tools = append(input.Tools, t)
+		input.Functions = append(input.Functions, t.Function)
+	}
+	return tools
+}
+
+// defaultToolsMetadata returns the metadata of the default tools added to the request: their number and, when
+// tokenize is set, the number of tokens of their definitions, which are part of the prompt tokens
+func defaultToolsMetadata(tools []functions.Tool, tokenize func(string) (int, error)) (map[string]interface{}, error) {
+	metadata := map[string]interface{}{"tools": len(tools)}
+	if tokenize == nil {
+		return metadata, nil
+	}
+	dat, err := json.Marshal(tools)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := tokenize(string(dat))
+	if err != nil {
+		return nil, err
+	}
+	metadata["tokens"] = tokens
+	return metadata, nil
+}
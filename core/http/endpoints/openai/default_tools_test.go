@@ -0,0 +1,73 @@
+package openai
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/functions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInjectDefaultTools(t *testing.T) {
+	tool := func(name, description string) functions.Tool {
+		return functions.Tool{Type: "function", Function: functions.Function{Name: name, Description: description}}
+	}
+	names := func(input *schema.OpenAIRequest) []string {
+		n := []string{}
+		for _, f := range input.Functions {
+			n = append(n, f.Name+": "+f.Description)
+		}
+		return n
+	}
+	cfg := &config.BackendConfig{DefaultTools: config.DefaultTools{Tools: []functions.Tool{tool("search", "default"), tool("weather", "default")}}}
+
+	// the requests without tools get the default ones
+	input := &schema.OpenAIRequest{}
+	added := injectDefaultTools(cfg, input)
+	assert.Len(t, added, 2)
+	assert.Len(t, input.Tools, 2)
+	assert.Equal(t, []string{"search: default", "weather: default"}, names(input))
+
+	// the tools of the requests override the default ones with the same name, the others are added
+	input = &schema.OpenAIRequest{Tools: []functions.Tool{tool("weather", "request"), tool("calendar", "request")}}
+	input.Functions = functions.Functions{input.Tools[0].Function, input.Tools[1].Function}
+	added = injectDefaultTools(cfg, input)
+	assert.Equal(t, []functions.Tool{tool("search", "default")}, added)
+	assert.Equal(t, []string{"weather: request", "calendar: request", "search: default"}, names(input))
+
+	// the legacy functions are merged as well
+	input = &schema.OpenAIRequest{Functions: functions.Functions{{Name: "search", Description: "request"}}}
+	injectDefaultTools(cfg, input)
+	assert.Equal(t, []string{"search: request", "weather: default"}, names(input))
+
+	// in the replace mode, the requests with tools keep their own only
+	cfg.DefaultTools.Mode = config.DefaultToolsReplace
+	input = &schema.OpenAIRequest{Tools: []functions.Tool{tool("calendar", "request")}, Functions: functions.Functions{{Name: "calendar", Description: "request"}}}
+	assert.Empty(t, injectDefaultTools(cfg, input))
+	assert.Equal(t, []string{"calendar: request"}, names(input))
+	input = &schema.OpenAIRequest{}
+	assert.Len(t, injectDefaultTools(cfg, input), 2)
+}
+
+func TestDefaultToolsMetadata(t *testing.T) {
+	tools := []functions.Tool{{Type: "function", Function: functions.Function{Name: "search"}}}
+
+	metadata, err := defaultToolsMetadata(tools, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"tools": 1}, metadata)
+
+	var tokenized string
+	metadata, err = defaultToolsMetadata(tools, func(s string) (int, error) {
+		tokenized = s
+		return 12, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"tools": 1, "tokens": 12}, metadata)
+	assert.Contains(t, tokenized, `"name":"search"`)
+
+	_, err = defaultToolsMetadata(tools, func(string) (int, error) { return 0, errors.New("no tokenizer") })
+	assert.Error(t, err)
+}
@@ -39,6 +39,9 @@ func EditEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 		}
 
 		log.Debug().Msgf("Parameter Config: %+v", config)
+		if err := checkBackendOverride(config, ml, appConfig); err != nil {
+			return err
+		}
 
 		var result []schema.Choice
 		totalTokenUsage := backend.TokenUsage{}
@@ -60,11 +63,15 @@ func EditEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 			if err != nil {
 				return err
 			}
+			setCPUFallbackHeader(c, tokenUsage)
 
 			totalTokenUsage.Prompt += tokenUsage.Prompt
 			totalTokenUsage.Completion += tokenUsage.Completion
 
 			totalTokenUsage.TimingTokenGeneration += tokenUsage.TimingTokenGeneration
+			if totalTokenUsage.TimingFirstToken == 0 {
+				totalTokenUsage.TimingFirstToken = tokenUsage.TimingFirstToken
+			}
 			totalTokenUsage.TimingPromptProcessing += tokenUsage.TimingPromptProcessing
 
 			result = append(result, r...)
@@ -77,6 +84,7 @@ func EditEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 		if extraUsage {
 			usage.TimingTokenGeneration = totalTokenUsage.TimingTokenGeneration
 			usage.TimingPromptProcessing = totalTokenUsage.TimingPromptProcessing
+			usage.TimingFirstToken = totalTokenUsage.TimingFirstToken
 		}
 
 		id := uuid.New().String()
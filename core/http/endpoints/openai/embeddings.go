@@ -22,7 +22,8 @@ import (
 // @Success 200 {object} schema.OpenAIResponse "Response"
 // @Router /v1/embeddings [post]
 func EmbeddingsEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
-	return func(c *fiber.Ctx) error {
+	var handler fiber.Handler
+	handler = func(c *fiber.Ctx) error {
 		model, input, err := readRequest(c, cl, ml, appConfig, true)
 		if err != nil {
 			return fmt.Errorf("failed reading parameters from request:%w", err)
@@ -34,11 +35,20 @@ func EmbeddingsEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, a
 		}
 
 		log.Debug().Msgf("Parameter Config: %+v", config)
+		if len(config.Ensemble.Members) > 0 {
+			return runEmbeddingsEnsemble(c, handler, config, input)
+		}
+		if err := checkBackendOverride(config, ml, appConfig); err != nil {
+			return err
+		}
+		gpuStats := startGPUStats(appConfig, config.Name)
+		defer gpuStats.Stop(nil)
+
 		items := []schema.Item{}
 
 		for i, s := range config.InputToken {
 			// get the model function to call for the result
-			embedFn, err := backend.ModelEmbedding("", s, ml, *config, appConfig)
+			embedFn, err := backend.ModelEmbedding(input.Context, "", s, ml, *config, appConfig)
 			if err != nil {
 				return err
 			}
@@ -52,7 +62,7 @@ func EmbeddingsEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, a
 
 		for i, s := range config.InputStrings {
 			// get the model function to call for the result
-			embedFn, err := backend.ModelEmbedding(s, []int{}, ml, *config, appConfig)
+			embedFn, err := backend.ModelEmbedding(input.Context, s, []int{}, ml, *config, appConfig)
 			if err != nil {
 				return err
 			}
@@ -64,14 +74,49 @@ func EmbeddingsEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, a
 			items = append(items, schema.Item{Embedding: embeddings, Index: i, Object: "embedding"})
 		}
 
+		if input.Dimensions != 0 {
+			normalize := input.Normalize == nil || *input.Normalize
+			for i := range items {
+				truncated, err := backend.TruncateEmbedding(items[i].Embedding, input.Dimensions, normalize)
+				if err != nil {
+					return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid dimensions: %s", err.Error()))
+				}
+				items[i].Embedding = truncated
+			}
+		}
+
+		usage := schema.OpenAIUsage{}
+		if appConfig.EmbeddingsTokenUsage {
+			counts, err := countEmbeddingTokens(config, func(s string) (int, error) {
+				resp, err := backend.ModelTokenize(s, ml, *config, appConfig)
+				return len(resp.Tokens), err
+			})
+			if err != nil {
+				log.Warn().Err(err).Str("model", config.Name).Msg("failed counting the tokens of the embeddings inputs")
+			} else {
+				usage = setEmbeddingTokens(items, counts)
+			}
+		}
+
+		metadata := map[string]interface{}{}
+		if config.BackendOverride {
+			metadata["backend"] = config.Backend
+		}
+		if len(items) > 0 {
+			metadata["dimensions"] = len(items[0].Embedding)
+		}
+		gpuStats.Stop(metadata)
+
 		id := uuid.New().String()
 		created := int(time.Now().Unix())
 		resp := &schema.OpenAIResponse{
-			ID:      id,
-			Created: created,
-			Model:   input.Model, // we have to return what the user sent here, due to OpenAI spec.
-			Data:    items,
-			Object:  "list",
+			ID:       id,
+			Created:  created,
+			Model:    input.Model, // we have to return what the user sent here, due to OpenAI spec.
+			Data:     items,
+			Object:   "list",
+			Usage:    usage,
+			Metadata: responseMetadata(metadata),
 		}
 
 		jsonResult, _ := json.Marshal(resp)
@@ -80,4 +125,5 @@ func EmbeddingsEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, a
 		// Return the prediction in the response body
 		return c.JSON(resp)
 	}
+	return handler
 }
@@ -0,0 +1,34 @@
+package openai
+
+import (
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+// countEmbeddingTokens returns the number of tokens of each input of the embeddings request, in the order of the
+// embeddings: the inputs already tokenized are counted as they are, the texts are tokenized by the model
+func countEmbeddingTokens(cfg *config.BackendConfig, tokenize func(string) (int, error)) ([]int, error) {
+	counts := []int{}
+	for _, tokens := range cfg.InputToken {
+		counts = append(counts, len(tokens))
+	}
+	for _, s := range cfg.InputStrings {
+		n, err := tokenize(s)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, n)
+	}
+	return counts, nil
+}
+
+// setEmbeddingTokens sets the number of tokens of the input of each embedding, returning their total as the usage
+func setEmbeddingTokens(items []schema.Item, counts []int) schema.OpenAIUsage {
+	usage := schema.OpenAIUsage{}
+	for i := range items {
+		items[i].PromptTokens = counts[i]
+		usage.PromptTokens += counts[i]
+	}
+	usage.TotalTokens = usage.PromptTokens
+	return usage
+}
@@ -0,0 +1,44 @@
+package openai
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddingTokensSumToTheUsage(t *testing.T) {
+	cfg := &config.BackendConfig{
+		InputToken:   [][]int{{1, 2, 3}},
+		InputStrings: []string{"the first document", "a second, longer document of the batch"},
+	}
+	counts, err := countEmbeddingTokens(cfg, func(s string) (int, error) {
+		return len(strings.Fields(s)), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 3, 7}, counts)
+
+	items := make([]schema.Item, len(counts))
+	usage := setEmbeddingTokens(items, counts)
+
+	sum := 0
+	for _, item := range items {
+		sum += item.PromptTokens
+	}
+	assert.Equal(t, 13, sum)
+	assert.Equal(t, sum, usage.PromptTokens)
+	assert.Equal(t, sum, usage.TotalTokens)
+	assert.Zero(t, usage.CompletionTokens)
+}
+
+func TestEmbeddingTokensTokenizerError(t *testing.T) {
+	cfg := &config.BackendConfig{InputStrings: []string{"document"}}
+	_, err := countEmbeddingTokens(cfg, func(s string) (int, error) {
+		return 0, errors.New("tokenization not supported")
+	})
+	assert.Error(t, err)
+}
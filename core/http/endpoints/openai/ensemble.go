@@ -0,0 +1,197 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/rs/zerolog/log"
+)
+
+// ensembleMemberKey marks the requests run by the members of an ensemble model
+const ensembleMemberKey = "ensembleMember"
+
+// runEnsembleMembers runs the request on all the members of the ensemble, with the model of the body replaced.
+// It returns the responses of the members (nil for the failed ones) and their details for the metadata
+func runEnsembleMembers(c *fiber.Ctx, handler fiber.Handler, cfg *config.BackendConfig) ([]*schema.OpenAIResponse, []map[string]interface{}, error) {
+	if c.Locals(ensembleMemberKey) != nil {
+		return nil, nil, fiber.NewError(fiber.StatusBadRequest, "an ensemble model cannot be a member of another ensemble")
+	}
+
+	body := map[string]interface{}{}
+	if err := json.Unmarshal(c.Body(), &body); err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+	body["stream"] = false
+
+	responses := make([]*schema.OpenAIResponse, len(cfg.Ensemble.Members))
+	details := []map[string]interface{}{}
+	succeeded := 0
+	var lastErr error
+	for i, member := range cfg.Ensemble.Members {
+		start := time.Now()
+		info := map[string]interface{}{"model": member}
+
+		body["model"] = member
+		resp, err := ensembleMember(c, handler, body)
+		info["duration_ms"] = time.Since(start).Milliseconds()
+		if err != nil {
+			log.Warn().Err(err).Str("ensemble", cfg.Name).Str("member", member).Msg("ensemble member failed")
+			info["error"] = err.Error()
+			lastErr = err
+		} else {
+			responses[i] = resp
+			info["usage"] = resp.Usage
+			succeeded++
+		}
+		details = append(details, info)
+	}
+
+	required := max(cfg.Ensemble.MinMembers, 1)
+	if succeeded < required {
+		return nil, nil, fmt.Errorf("%d of %d ensemble members succeeded, %d required: %w", succeeded, len(cfg.Ensemble.Members), required, lastErr)
+	}
+	return responses, details, nil
+}
+
+// ensembleMember runs the request on a member of an ensemble, returning its response
+func ensembleMember(c *fiber.Ctx, handler fiber.Handler, body map[string]interface{}) (*schema.OpenAIResponse, error) {
+	return subResponse(c, handler, body, func(sub *fiber.Ctx) {
+		// the member model is set in the body only. The members the API key is not allowed to use fail
+		sub.Request().URI().SetQueryString("")
+		sub.Locals(ensembleMemberKey, true)
+	})
+}
+
+// ensembleUsage sums the usage of the members
+func ensembleUsage(responses []*schema.OpenAIResponse) schema.OpenAIUsage {
+	usage := schema.OpenAIUsage{}
+	for _, r := range responses {
+		if r != nil {
+			usage.PromptTokens += r.Usage.PromptTokens
+			usage.CompletionTokens += r.Usage.CompletionTokens
+			usage.TotalTokens += r.Usage.TotalTokens
+		}
+	}
+	return usage
+}
+
+// runChatEnsemble runs a chat request across the members of an ensemble model, returning the output given by most of them
+func runChatEnsemble(c *fiber.Ctx, chat fiber.Handler, cfg *config.BackendConfig, input *schema.OpenAIRequest) error {
+	if strategy := cfg.Ensemble.EnsembleStrategy(config.EnsembleStrategyVote); strategy != config.EnsembleStrategyVote {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("the ensemble strategy %q does not apply to chat requests", strategy))
+	}
+
+	responses, details, err := runEnsembleMembers(c, chat, cfg)
+	if err != nil {
+		return err
+	}
+
+	outputs, members := []string{}, []int{}
+	for i, r := range responses {
+		if r == nil {
+			continue
+		}
+		if len(r.Choices) == 0 || r.Choices[0].Message == nil {
+			details[i]["error"] = "the member returned no output"
+			continue
+		}
+		content, _ := r.Choices[0].Message.Content.(string)
+		details[i]["output"] = content
+		outputs = append(outputs, content)
+		members = append(members, i)
+	}
+	if len(outputs) == 0 {
+		return errors.New("the ensemble members returned no output")
+	}
+
+	winner, votes := backend.VoteOutputs(outputs)
+	metadata := map[string]interface{}{
+		"ensemble": map[string]interface{}{
+			"strategy":  config.EnsembleStrategyVote,
+			"members":   len(cfg.Ensemble.Members),
+			"succeeded": len(outputs),
+			"votes":     votes,
+			"model":     cfg.Ensemble.Members[members[winner]],
+		},
+	}
+	if input.EnsembleDetails {
+		metadata["ensemble_members"] = details
+	}
+
+	text := outputs[winner]
+	resp := schema.OpenAIResponse{
+		ID:       uuid.New().String(),
+		Created:  int(time.Now().Unix()),
+		Model:    input.Model,
+		Object:   "chat.completion",
+		Usage:    ensembleUsage(responses),
+		Metadata: metadata,
+	}
+	return sendCombinedResponse(c, resp, &schema.Message{Role: "assistant", Content: &text}, input.Stream)
+}
+
+// runEmbeddingsEnsemble runs an embeddings request across the members of an ensemble model, returning the average of their embeddings
+func runEmbeddingsEnsemble(c *fiber.Ctx, embeddings fiber.Handler, cfg *config.BackendConfig, input *schema.OpenAIRequest) error {
+	if strategy := cfg.Ensemble.EnsembleStrategy(config.EnsembleStrategyAverage); strategy != config.EnsembleStrategyAverage {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("the ensemble strategy %q does not apply to embeddings requests", strategy))
+	}
+
+	responses, details, err := runEnsembleMembers(c, embeddings, cfg)
+	if err != nil {
+		return err
+	}
+
+	succeeded := []*schema.OpenAIResponse{}
+	for _, r := range responses {
+		if r != nil {
+			succeeded = append(succeeded, r)
+		}
+	}
+
+	items := []schema.Item{}
+	for i := range succeeded[0].Data {
+		vectors := [][]float32{}
+		for _, r := range succeeded {
+			if len(r.Data) != len(succeeded[0].Data) {
+				return fmt.Errorf("the ensemble members returned a different number of embeddings")
+			}
+			vectors = append(vectors, r.Data[i].Embedding)
+		}
+		avg, err := backend.AverageEmbeddings(vectors)
+		if err != nil {
+			return fmt.Errorf("failed averaging the embeddings of the ensemble members: %w", err)
+		}
+		items = append(items, schema.Item{Embedding: avg, Index: i, Object: "embedding"})
+	}
+
+	metadata := map[string]interface{}{
+		"ensemble": map[string]interface{}{
+			"strategy":  config.EnsembleStrategyAverage,
+			"members":   len(cfg.Ensemble.Members),
+			"succeeded": len(succeeded),
+		},
+	}
+	if len(items) > 0 {
+		metadata["dimensions"] = len(items[0].Embedding)
+	}
+	if input.EnsembleDetails {
+		metadata["ensemble_members"] = details
+	}
+
+	return c.JSON(schema.OpenAIResponse{
+		ID:       uuid.New().String(),
+		Created:  int(time.Now().Unix()),
+		Model:    input.Model,
+		Data:     items,
+		Object:   "list",
+		Usage:    ensembleUsage(responses),
+		Metadata: metadata,
+	})
+}
@@ -0,0 +1,112 @@
+package openai
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunEnsemble(t *testing.T) {
+	answers := map[string]string{"a": "Positive", "b": "negative", "c": "positive."}
+	embeddings := map[string][]float32{"a": {1, 0}, "b": {0, 1}, "c": {0.5, 0.5}}
+	// the members answer with their fixed output
+	member := func(c *fiber.Ctx) error {
+		req := schema.OpenAIRequest{}
+		if err := json.Unmarshal(c.Body(), &req); err != nil {
+			return err
+		}
+		assert.False(t, req.Stream)
+		if !fiberContext.ModelAllowed(c, req.Model) {
+			return fiber.NewError(fiber.StatusForbidden, "model not allowed")
+		}
+		if req.Model == "broken" {
+			return fiber.NewError(fiber.StatusServiceUnavailable, "backend unavailable")
+		}
+		if req.Input != nil {
+			return c.JSON(schema.OpenAIResponse{Data: []schema.Item{{Embedding: embeddings[req.Model]}}})
+		}
+		return c.JSON(schema.OpenAIResponse{
+			Choices: []schema.Choice{{Message: &schema.Message{Role: "assistant", Content: answers[req.Model]}}},
+			Usage:   schema.OpenAIUsage{PromptTokens: 2, CompletionTokens: 3, TotalTokens: 5},
+		})
+	}
+
+	// the models allowed for the API key of the requests, nil for all of them
+	var allowed []string
+	run := func(cfg *config.BackendConfig, body string) (int, schema.OpenAIResponse, string) {
+		input := &schema.OpenAIRequest{}
+		json.Unmarshal([]byte(body), input)
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			fiberContext.SetAllowedModels(c, allowed)
+			return c.Next()
+		})
+		app.Post("/", func(c *fiber.Ctx) error {
+			if input.Input != nil {
+				return runEmbeddingsEnsemble(c, member, cfg, input)
+			}
+			return runChatEnsemble(c, member, cfg, input)
+		})
+		resp, err := app.Test(httptest.NewRequest("POST", "/", strings.NewReader(body)))
+		assert.NoError(t, err)
+		data, _ := io.ReadAll(resp.Body)
+		result := schema.OpenAIResponse{}
+		json.Unmarshal(data, &result)
+		return resp.StatusCode, result, string(data)
+	}
+
+	cfg := &config.BackendConfig{Name: "classifier", Ensemble: config.Ensemble{Members: []string{"b", "broken", "a", "c"}}}
+	chat := `{"model": "classifier", "ensemble_details": true, "messages": [{"role": "user", "content": "great!"}]}`
+	status, resp, _ := run(cfg, chat)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "Positive", resp.Choices[0].Message.Content)
+	assert.Equal(t, 15, resp.Usage.TotalTokens)
+	ensemble := resp.Metadata["ensemble"].(map[string]interface{})
+	assert.Equal(t, 2.0, ensemble["votes"])
+	assert.Equal(t, 3.0, ensemble["succeeded"])
+	assert.Equal(t, "a", ensemble["model"])
+	members := resp.Metadata["ensemble_members"].([]interface{})
+	assert.Len(t, members, 4)
+	assert.Equal(t, "negative", members[0].(map[string]interface{})["output"])
+	assert.Contains(t, members[1].(map[string]interface{})["error"], "backend unavailable")
+
+	// the members never stream
+	status, _, stream := run(cfg, strings.Replace(chat, "{", `{"stream": true,`, 1))
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Contains(t, stream, `"content":"Positive"`)
+	assert.Contains(t, stream, "data: [DONE]")
+
+	// too many failures
+	cfg.Ensemble.MinMembers = 4
+	status, _, _ = run(cfg, chat)
+	assert.Equal(t, fiber.StatusServiceUnavailable, status)
+
+	// the members the API key is not allowed to use fail
+	cfg.Ensemble.MinMembers = 0
+	allowed = []string{"classifier", "a", "c"}
+	status, resp, _ = run(cfg, chat)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "Positive", resp.Choices[0].Message.Content)
+	members = resp.Metadata["ensemble_members"].([]interface{})
+	assert.Contains(t, members[0].(map[string]interface{})["error"], "model not allowed")
+	allowed = nil
+
+	status, resp, _ = run(&config.BackendConfig{Name: "embedder", Ensemble: config.Ensemble{Members: []string{"a", "b", "broken"}}},
+		`{"model": "embedder", "input": "great!"}`)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, []float32{0.5, 0.5}, resp.Data[0].Embedding)
+	assert.Nil(t, resp.Metadata["ensemble_members"])
+
+	// voting does not apply to embeddings
+	status, _, _ = run(&config.BackendConfig{Name: "embedder", Ensemble: config.Ensemble{Members: []string{"a", "b"}, Strategy: config.EnsembleStrategyVote}},
+		`{"model": "embedder", "input": "great!"}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+}
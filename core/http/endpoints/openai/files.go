@@ -29,8 +29,8 @@ func UploadFilesEndpoint(cm *config.BackendConfigLoader, appConfig *config.Appli
 		}
 
 		// Check the file size
-		if file.Size > int64(appConfig.UploadLimitMB*1024*1024) {
-			return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("File size %d exceeds upload limit %d", file.Size, appConfig.UploadLimitMB))
+		if file.Size > int64(appConfig.EffectiveUploadLimitMB()*1024*1024) {
+			return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("File size %d exceeds upload limit %d", file.Size, appConfig.EffectiveUploadLimitMB()))
 		}
 
 		purpose := c.FormValue("purpose", "") //TODO put in purpose dirs
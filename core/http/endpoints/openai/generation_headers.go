@@ -0,0 +1,44 @@
+package openai
+
+import (
+	"math/rand"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+)
+
+// Response headers with the effective generation parameters of the request
+const (
+	ModelHeader       = "LocalAI-Model"
+	SeedHeader        = "LocalAI-Seed"
+	TemperatureHeader = "LocalAI-Temperature"
+	TopPHeader        = "LocalAI-Top-P"
+)
+
+// setGenerationHeaders echoes the generation parameters of the request, after the defaults of the model and of the
+// server are applied, in the response headers. It is called before the response is streamed. The random seed is drawn
+// here instead of by the backend, so that it can be reported, unless several choices are generated: each of them
+// draws its own seed, and none is reported
+func setGenerationHeaders(c *fiber.Ctx, appConfig *config.ApplicationConfig, cfg *config.BackendConfig, n int) {
+	if !appConfig.GenerationHeaders {
+		return
+	}
+	c.Set(ModelHeader, cfg.Name)
+	if cfg.Seed == nil || *cfg.Seed == config.RAND_SEED {
+		if n <= 1 {
+			seed := int(rand.Int31())
+			cfg.Seed = &seed
+		}
+	}
+	if cfg.Seed != nil && *cfg.Seed != config.RAND_SEED {
+		// the backends get the seed as a 32 bits integer
+		c.Set(SeedHeader, strconv.Itoa(int(int32(*cfg.Seed))))
+	}
+	if cfg.Temperature != nil {
+		c.Set(TemperatureHeader, strconv.FormatFloat(*cfg.Temperature, 'f', -1, 64))
+	}
+	if cfg.TopP != nil {
+		c.Set(TopPHeader, strconv.FormatFloat(*cfg.TopP, 'f', -1, 64))
+	}
+}
@@ -0,0 +1,62 @@
+package openai
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetGenerationHeaders(t *testing.T) {
+	temperature, topP := 0.7, 0.95
+	var cfg *config.BackendConfig
+	headers := func(appConfig *config.ApplicationConfig, seed *int, n int) map[string]string {
+		cfg = &config.BackendConfig{Name: "gpt-4", PredictionOptions: schema.PredictionOptions{Seed: seed, Temperature: &temperature, TopP: &topP}}
+		app := fiber.New()
+		app.Get("/", func(c *fiber.Ctx) error {
+			setGenerationHeaders(c, appConfig, cfg, n)
+			return c.SendString("ok")
+		})
+		resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+		require.NoError(t, err)
+		values := map[string]string{}
+		for _, h := range []string{ModelHeader, SeedHeader, TemperatureHeader, TopPHeader} {
+			if v := resp.Header.Get(h); v != "" {
+				values[h] = v
+			}
+		}
+		return values
+	}
+	enabled := &config.ApplicationConfig{GenerationHeaders: true}
+
+	assert.Empty(t, headers(&config.ApplicationConfig{}, nil, 1))
+
+	seed := 42
+	assert.Equal(t, map[string]string{
+		ModelHeader:       "gpt-4",
+		SeedHeader:        "42",
+		TemperatureHeader: "0.7",
+		TopPHeader:        "0.95",
+	}, headers(enabled, &seed, 1))
+
+	// the random seed is drawn and passed to the backend
+	random := config.RAND_SEED
+	values := headers(enabled, &random, 0)
+	require.NotNil(t, cfg.Seed)
+	assert.NotEqual(t, config.RAND_SEED, *cfg.Seed)
+	assert.Equal(t, strconv.Itoa(*cfg.Seed), values[SeedHeader])
+
+	// each choice draws its own seed
+	values = headers(enabled, &random, 3)
+	assert.NotContains(t, values, SeedHeader)
+	assert.Equal(t, config.RAND_SEED, *cfg.Seed)
+
+	// the seeds are truncated to 32 bits by the backends
+	large := 1<<32 + 7
+	assert.Equal(t, "7", headers(enabled, &large, 1)[SeedHeader])
+}
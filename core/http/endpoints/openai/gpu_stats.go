@@ -0,0 +1,51 @@
+package openai
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/xsysinfo"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	gpuSamplerOnce sync.Once
+	gpuSampler     *xsysinfo.GPUSampler
+)
+
+// sharedGPUSampler returns the sampler shared by all the requests, so that the GPUs are queried once per interval
+// regardless of the number of requests in progress
+func sharedGPUSampler(interval time.Duration) *xsysinfo.GPUSampler {
+	gpuSamplerOnce.Do(func() {
+		gpuSampler = xsysinfo.NewGPUSampler(xsysinfo.GPUUtilizations, interval)
+	})
+	return gpuSampler
+}
+
+// gpuStatsRecorder records the utilization of the GPUs during a request, when enabled
+type gpuStatsRecorder struct {
+	recording *xsysinfo.GPURecording
+	model     string
+}
+
+func startGPUStats(appConfig *config.ApplicationConfig, model string) *gpuStatsRecorder {
+	if appConfig.GPUStatsInterval <= 0 {
+		return nil
+	}
+	return &gpuStatsRecorder{recording: sharedGPUSampler(appConfig.GPUStatsInterval).Start(), model: model}
+}
+
+// Stop stops recording and adds the statistics of every GPU to the metadata, if not nil. It can be called
+// more than once, e.g. deferred to stop recording when the request fails
+func (r *gpuStatsRecorder) Stop(metadata map[string]interface{}) {
+	if r == nil {
+		return
+	}
+	stats := r.recording.Stop()
+	if metadata == nil || len(stats) == 0 {
+		return
+	}
+	metadata["gpu_stats"] = stats
+	log.Debug().Str("model", r.model).Interface("gpu_stats", stats).Msg("GPU utilization of the request")
+}
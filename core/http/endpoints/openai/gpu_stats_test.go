@@ -0,0 +1,17 @@
+package openai
+
+import (
+	"testing"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGPUStatsDisabled(t *testing.T) {
+	gpuStats := startGPUStats(&config.ApplicationConfig{}, "model")
+	assert.Nil(t, gpuStats)
+
+	metadata := map[string]interface{}{}
+	gpuStats.Stop(metadata)
+	assert.Nil(t, responseMetadata(metadata))
+}
@@ -0,0 +1,138 @@
+package openai
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/rs/zerolog/log"
+)
+
+// streamHeartbeatEvent is the SSE event of the progress heartbeats. The completion chunks are sent
+// without an event name, so clients can tell the heartbeats apart from them
+const streamHeartbeatEvent = "localai.heartbeat"
+
+// streamProgress tracks the tokens generated in a stream
+type streamProgress struct {
+	start     time.Time
+	tokens    int
+	chunks    int
+	estimated bool
+}
+
+func (p *streamProgress) Track(ev schema.OpenAIResponse) {
+	if len(ev.Choices) > 0 && chunkContent(ev.Choices[0]) != "" {
+		p.chunks++
+	}
+	p.tokens = ev.Usage.CompletionTokens
+	p.estimated = p.tokens == 0
+}
+
+// chunkContent is the text generated in a chunk of a streamed completion
+func chunkContent(choice schema.Choice) string {
+	if choice.Delta == nil {
+		return choice.Text
+	}
+	if choice.Delta.ReasoningContent != nil && *choice.Delta.ReasoningContent != "" {
+		return *choice.Delta.ReasoningContent
+	}
+	switch content := choice.Delta.Content.(type) {
+	case string:
+		return content
+	case *string:
+		if content != nil {
+			return *content
+		}
+	}
+	return ""
+}
+
+func (p *streamProgress) Heartbeat() schema.StreamHeartbeat {
+	elapsed := time.Since(p.start)
+	tokens := p.tokens
+	if p.estimated {
+		// the backend does not report the usage: the chunks carry a token each
+		tokens = p.chunks
+	}
+	heartbeat := schema.StreamHeartbeat{
+		Object:    streamHeartbeatEvent,
+		Tokens:    tokens,
+		Estimated: p.estimated,
+		ElapsedMS: elapsed.Milliseconds(),
+	}
+	if elapsed > 0 {
+		heartbeat.TokensPerSecond = float64(tokens) / elapsed.Seconds()
+	}
+	return heartbeat
+}
+
+// forwardStream calls send with each of the responses, until the channel is closed. If the interval is set,
+// a heartbeat event with the progress of the generation is written every interval in between. If loading is set,
+// the progress of the load of the model is written before the first response
+func forwardStream(w *bufio.Writer, responses <-chan schema.OpenAIResponse, interval time.Duration, loading modelLoading, send func(schema.OpenAIResponse)) {
+	if interval <= 0 && loading == nil {
+		for ev := range responses {
+			send(ev)
+		}
+		return
+	}
+
+	var heartbeats, loadingChecks <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		heartbeats = ticker.C
+	}
+	if loading != nil {
+		ticker := time.NewTicker(streamLoadingInterval)
+		defer ticker.Stop()
+		loadingChecks = ticker.C
+	}
+
+	progress := &streamProgress{start: time.Now(), estimated: true}
+	// lastLoading is the last progress of the load sent, loadStarted when the load started
+	var lastLoading *schema.StreamLoading
+	var loadStarted time.Time
+	loaded := func() {
+		if lastLoading != nil {
+			writeStreamEvent(w, streamLoadingEvent, loadingDone(*lastLoading, time.Since(loadStarted)))
+		}
+		lastLoading, loadingChecks = nil, nil
+	}
+	for {
+		select {
+		case ev, ok := <-responses:
+			if !ok {
+				return
+			}
+			loaded()
+			progress.Track(ev)
+			send(ev)
+		case <-heartbeats:
+			writeStreamEvent(w, streamHeartbeatEvent, progress.Heartbeat())
+		case <-loadingChecks:
+			ev, isLoading := loading()
+			if !isLoading {
+				// the load might not have started yet, until the model of a load reported is loaded
+				if lastLoading != nil {
+					loaded()
+				}
+				continue
+			}
+			lastLoading = &ev
+			loadStarted = time.Now().Add(-time.Duration(ev.ElapsedMS) * time.Millisecond)
+			writeStreamEvent(w, streamLoadingEvent, ev)
+		}
+	}
+}
+
+// writeStreamEvent writes a named SSE event, which the clients can tell apart from the completion chunks
+func writeStreamEvent(w *bufio.Writer, event string, v any) {
+	data, _ := json.Marshal(v)
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		log.Debug().Msgf("Sending %s event failed: %v", event, err)
+	}
+	w.Flush()
+}
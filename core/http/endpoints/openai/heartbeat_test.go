@@ -0,0 +1,88 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForwardStream(t *testing.T) {
+	tokens := []string{"The", " quick", " brown", " fox"}
+	stream := func(interval time.Duration, usage bool) string {
+		responses := make(chan schema.OpenAIResponse)
+		go func() {
+			for i, token := range tokens {
+				time.Sleep(30 * time.Millisecond)
+				ev := schema.OpenAIResponse{Choices: []schema.Choice{{Delta: &schema.Message{Content: &token}}}}
+				if usage {
+					ev.Usage.CompletionTokens = i + 1
+				}
+				responses <- ev
+			}
+			close(responses)
+		}()
+
+		out := &bytes.Buffer{}
+		w := bufio.NewWriter(out)
+		forwardStream(w, responses, interval, nil, func(ev schema.OpenAIResponse) {
+			data, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			w.Flush()
+		})
+		return out.String()
+	}
+
+	// parse splits the SSE stream in the content of the chunks and the heartbeats
+	parse := func(out string) (string, []schema.StreamHeartbeat) {
+		content := ""
+		heartbeats := []schema.StreamHeartbeat{}
+		for _, event := range strings.Split(strings.TrimSuffix(out, "\n\n"), "\n\n") {
+			if rest, ok := strings.CutPrefix(event, "event: "+streamHeartbeatEvent+"\ndata: "); ok {
+				heartbeat := schema.StreamHeartbeat{}
+				require.NoError(t, json.Unmarshal([]byte(rest), &heartbeat))
+				heartbeats = append(heartbeats, heartbeat)
+				continue
+			}
+			data, ok := strings.CutPrefix(event, "data: ")
+			require.True(t, ok, event)
+			resp := schema.OpenAIResponse{}
+			require.NoError(t, json.Unmarshal([]byte(data), &resp))
+			content += resp.Choices[0].Delta.Content.(string)
+		}
+		return content, heartbeats
+	}
+
+	content, heartbeats := parse(stream(10*time.Millisecond, true))
+	assert.Equal(t, "The quick brown fox", content)
+	require.NotEmpty(t, heartbeats)
+	for i, heartbeat := range heartbeats {
+		assert.Equal(t, streamHeartbeatEvent, heartbeat.Object)
+		if i > 0 {
+			assert.GreaterOrEqual(t, heartbeat.Tokens, heartbeats[i-1].Tokens)
+			assert.GreaterOrEqual(t, heartbeat.ElapsedMS, heartbeats[i-1].ElapsedMS)
+		}
+	}
+	assert.False(t, heartbeats[len(heartbeats)-1].Estimated)
+	assert.LessOrEqual(t, heartbeats[len(heartbeats)-1].Tokens, len(tokens))
+
+	// without the usage, the tokens are estimated from the chunks
+	content, heartbeats = parse(stream(10*time.Millisecond, false))
+	assert.Equal(t, "The quick brown fox", content)
+	require.NotEmpty(t, heartbeats)
+	assert.True(t, heartbeats[len(heartbeats)-1].Estimated)
+	assert.Greater(t, heartbeats[len(heartbeats)-1].Tokens, 0)
+
+	// disabled
+	out := stream(0, true)
+	assert.NotContains(t, out, streamHeartbeatEvent)
+	content, _ = parse(out)
+	assert.Equal(t, "The quick brown fox", content)
+}
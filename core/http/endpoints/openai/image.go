@@ -21,6 +21,7 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/utils"
 	"github.com/rs/zerolog/log"
 )
 
@@ -81,6 +82,16 @@ func ImageEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appCon
 			return fmt.Errorf("failed reading parameters from request:%w", err)
 		}
 
+		n := input.N
+		if input.N == 0 {
+			n = 1
+		}
+		if err := checkImageCount(n, config, appConfig); err != nil {
+			return err
+		}
+
+		metadata := map[string]interface{}{}
+
 		src := ""
 		if input.File != "" {
 
@@ -108,6 +119,15 @@ func ImageEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appCon
 				}
 			}
 
+			var downscaled bool
+			fileData, downscaled, err = utils.LimitImageSize(fileData, appConfig.MaxImageDimension, appConfig.RejectOversizedImages)
+			if err != nil {
+				return imageLimitError(err)
+			}
+			if downscaled {
+				metadata["images_downscaled"] = true
+			}
+
 			// Create a temporary file
 			outputFile, err := os.CreateTemp(appConfig.ImageDir, "b64")
 			if err != nil {
@@ -156,13 +176,13 @@ func ImageEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appCon
 
 		b64JSON := config.ResponseFormat == "b64_json"
 
+		if !utils.ValidImageEncoding(input.ResponseEncoding) {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid response_encoding %q: supported encodings are png, jpeg and webp", input.ResponseEncoding))
+		}
+
 		// src and clip_skip
 		var result []schema.Item
 		for _, i := range config.PromptStrings {
-			n := input.N
-			if input.N == 0 {
-				n = 1
-			}
 			for j := 0; j < n; j++ {
 				prompts := strings.Split(i, "|")
 				positive_prompt := prompts[0]
@@ -212,6 +232,15 @@ func ImageEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appCon
 					return err
 				}
 
+				if input.ResponseEncoding != "" && input.ResponseEncoding != utils.ImageEncodingPNG {
+					transcoded, err := utils.TranscodeImage(output, input.ResponseEncoding)
+					if err != nil {
+						return fmt.Errorf("failed encoding the image as %s: %w", input.ResponseEncoding, err)
+					}
+					os.RemoveAll(output)
+					output = transcoded
+				}
+
 				item := &schema.Item{}
 
 				if b64JSON {
@@ -233,9 +262,10 @@ func ImageEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appCon
 		id := uuid.New().String()
 		created := int(time.Now().Unix())
 		resp := &schema.OpenAIResponse{
-			ID:      id,
-			Created: created,
-			Data:    result,
+			ID:       id,
+			Created:  created,
+			Data:     result,
+			Metadata: responseMetadata(metadata),
 		}
 
 		jsonResult, _ := json.Marshal(resp)
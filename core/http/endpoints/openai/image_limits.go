@@ -0,0 +1,50 @@
+package openai
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/utils"
+)
+
+// limitInputImages applies the configured maximum image dimension to the images of the request messages.
+// It returns whether any image was downscaled.
+func limitInputImages(input *schema.OpenAIRequest, appConfig *config.ApplicationConfig) (bool, error) {
+	downscaled := false
+	for i, m := range input.Messages {
+		for j, img := range m.StringImages {
+			out, resized, err := utils.LimitBase64ImageSize(img, appConfig.MaxImageDimension, appConfig.RejectOversizedImages)
+			if err != nil {
+				return false, imageLimitError(err)
+			}
+			if resized {
+				input.Messages[i].StringImages[j] = out
+				downscaled = true
+			}
+		}
+	}
+	return downscaled, nil
+}
+
+func imageLimitError(err error) error {
+	if errors.Is(err, utils.ErrImageTooLarge) {
+		return fiber.NewError(fiber.StatusRequestEntityTooLarge, err.Error())
+	}
+	return err
+}
+
+// checkImageCount checks the number of images requested, after defaulting, against the bounds of the model
+func checkImageCount(n int, cfg *config.BackendConfig, appConfig *config.ApplicationConfig) error {
+	minCount, maxCount := cfg.ImageCountBounds(appConfig.MaxImageCount)
+	if n < minCount || (maxCount > 0 && n > maxCount) {
+		allowed := fmt.Sprintf("at least %d", minCount)
+		if maxCount > 0 {
+			allowed = fmt.Sprintf("between %d and %d", minCount, maxCount)
+		}
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid n %d: the model %s generates %s images per request", n, cfg.Name, allowed))
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package openai
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckImageCount(t *testing.T) {
+	appConfig := &config.ApplicationConfig{MaxImageCount: 10}
+	cfg := &config.BackendConfig{}
+	cfg.Name = "sd"
+
+	assert.NoError(t, checkImageCount(1, cfg, appConfig))
+	assert.NoError(t, checkImageCount(10, cfg, appConfig))
+	err := checkImageCount(11, cfg, appConfig)
+	var fiberErr *fiber.Error
+	require.ErrorAs(t, err, &fiberErr)
+	assert.Equal(t, fiber.StatusBadRequest, fiberErr.Code)
+	assert.Contains(t, fiberErr.Message, "between 1 and 10")
+	assert.Error(t, checkImageCount(0, cfg, appConfig))
+
+	// the model bounds override the global maximum
+	cfg.ImageCount = config.ImageCount{Min: 2, Max: 4}
+	assert.Error(t, checkImageCount(1, cfg, appConfig))
+	assert.NoError(t, checkImageCount(2, cfg, appConfig))
+	assert.NoError(t, checkImageCount(4, cfg, appConfig))
+	assert.ErrorContains(t, checkImageCount(5, cfg, appConfig), "between 2 and 4")
+
+	// without a maximum
+	cfg.ImageCount = config.ImageCount{}
+	appConfig.MaxImageCount = 0
+	assert.NoError(t, checkImageCount(100, cfg, appConfig))
+	assert.ErrorContains(t, checkImageCount(0, cfg, appConfig), "at least 1")
+}
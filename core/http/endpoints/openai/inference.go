@@ -1,11 +1,16 @@
 package openai
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/mudler/LocalAI/core/backend"
 	"github.com/mudler/LocalAI/core/config"
 
 	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/functions"
 	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/rs/zerolog/log"
 )
 
 func ComputeChoices(
@@ -44,22 +49,89 @@ func ComputeChoices(
 
 	tokenUsage := backend.TokenUsage{}
 
+	// streamed outputs are already sent to the client, they cannot be repaired
+	repairJSON := config.JSONRepair.Enabled && config.JSONOutputRequested() && !req.Stream
+
 	for i := 0; i < n; i++ {
-		prediction, err := predFunc()
-		if err != nil {
-			return result, backend.TokenUsage{}, err
-		}
+		var finetunedResponse string
+		repeated := false
+		for attempt := 0; ; attempt++ {
+			prediction, err := predFunc()
+			if err != nil {
+				contextSize := 0
+				if config.ContextSize != nil {
+					contextSize = *config.ContextSize
+				}
+				return result, backend.TokenUsage{}, backend.ParseContextLengthError(err, contextSize)
+			}
+
+			tokenUsage.Prompt += prediction.Usage.Prompt
+			tokenUsage.Completion += prediction.Usage.Completion
+			tokenUsage.TimingPromptProcessing += prediction.Usage.TimingPromptProcessing
+			tokenUsage.TimingTokenGeneration += prediction.Usage.TimingTokenGeneration
+			if tokenUsage.TimingFirstToken == 0 {
+				tokenUsage.TimingFirstToken = prediction.Usage.TimingFirstToken
+			}
+			addSegmentsUsage(&tokenUsage, prediction.Usage)
+			repeated = prediction.Usage.Repetition
+			tokenUsage.CPUFallback = tokenUsage.CPUFallback || prediction.Usage.CPUFallback
 
-		tokenUsage.Prompt += prediction.Usage.Prompt
-		tokenUsage.Completion += prediction.Usage.Completion
-		tokenUsage.TimingPromptProcessing += prediction.Usage.TimingPromptProcessing
-		tokenUsage.TimingTokenGeneration += prediction.Usage.TimingTokenGeneration
+			finetunedResponse = backend.Finetune(*config, predInput, prediction.Response)
+			if !repairJSON {
+				break
+			}
 
-		finetunedResponse := backend.Finetune(*config, predInput, prediction.Response)
+			repaired, ok := repairJSONOutput(config, finetunedResponse)
+			if ok {
+				finetunedResponse = repaired
+				break
+			}
+			if attempt < config.JSONRepair.Retries {
+				log.Warn().Str("model", config.Name).Int("attempt", attempt+1).Msg("the output is not valid JSON, running the inference again")
+				continue
+			}
+			if config.JSONRepair.OnFailure == "error" {
+				return result, tokenUsage, fmt.Errorf("the model output is not valid JSON")
+			}
+			log.Warn().Str("model", config.Name).Msg("the output is not valid JSON and could not be repaired")
+			break
+		}
+		choices := len(result)
 		cb(finetunedResponse, &result)
+		if repeated {
+			tokenUsage.Repetition = true
+			for j := choices; j < len(result); j++ {
+				if result[j].FinishReason == "stop" {
+					result[j].FinishReason = FinishReasonRepetition
+				}
+			}
+		}
 
 		//result = append(result, Choice{Text: prediction})
 
 	}
 	return result, tokenUsage, err
 }
+
+// repairJSONOutput repairs a malformed JSON output, returning whether the result is valid JSON.
+// The reasoning of reasoning models, if any, is kept as is
+func repairJSONOutput(config *config.BackendConfig, s string) (string, bool) {
+	prefix, output := "", s
+	if config.Reasoning.Enabled {
+		_, end := config.Reasoning.Tags()
+		if i := strings.LastIndex(s, end); i >= 0 {
+			prefix, output = s[:i+len(end)], s[i+len(end):]
+		}
+	}
+
+	repaired, ok := functions.RepairJSON(output)
+	if !ok {
+		return s, false
+	}
+	if repaired == strings.TrimSpace(output) {
+		// the output was already valid
+		return s, true
+	}
+	log.Info().Str("model", config.Name).Msg("repaired malformed JSON output")
+	return prefix + repaired, true
+}
@@ -0,0 +1,29 @@
+package openai
+
+import (
+	"testing"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepairJSONOutput(t *testing.T) {
+	cfg := &config.BackendConfig{}
+
+	out, ok := repairJSONOutput(cfg, "{\"a\": 1}\n")
+	assert.True(t, ok)
+	assert.Equal(t, "{\"a\": 1}\n", out, "valid outputs are not modified")
+
+	out, ok = repairJSONOutput(cfg, `{"a": [1, 2,`)
+	assert.True(t, ok)
+	assert.Equal(t, `{"a": [1, 2]}`, out)
+
+	_, ok = repairJSONOutput(cfg, `I cannot answer`)
+	assert.False(t, ok)
+
+	// the reasoning is kept as is
+	cfg.Reasoning.Enabled = true
+	out, ok = repairJSONOutput(cfg, `<think>maybe {"a"</think>{"a": 1,}`)
+	assert.True(t, ok)
+	assert.Equal(t, `<think>maybe {"a"</think>{"a": 1}`, out)
+}
@@ -0,0 +1,97 @@
+package openai
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/langdetect"
+	"github.com/rs/zerolog/log"
+)
+
+var languageNames = map[string]string{
+	"en": "English", "it": "Italian", "es": "Spanish", "fr": "French", "de": "German", "pt": "Portuguese", "nl": "Dutch",
+	"ru": "Russian", "ja": "Japanese", "zh": "Chinese", "ko": "Korean", "ar": "Arabic", "he": "Hebrew", "el": "Greek",
+	"hi": "Hindi", "th": "Thai",
+}
+
+func languageName(code string) string {
+	if name, ok := languageNames[code]; ok {
+		return name
+	}
+	return code
+}
+
+// checkInputLanguage detects the language of the last user message and enforces the language constraints
+// of the model: it returns the detected language, and an error if the input is rejected.
+// In instruct mode, an instruction to answer in the output languages is added to the messages
+func checkInputLanguage(cfg *config.BackendConfig, input *schema.OpenAIRequest) (string, error) {
+	constraints := cfg.Languages
+
+	detected, _ := langdetect.Detect(lastUserContent(input.Messages))
+	if detected != "" && len(constraints.Input) > 0 && !slices.Contains(constraints.Input, detected) {
+		if constraints.Mode == "reject" {
+			return detected, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("the input language (%s) is not supported by the model", detected))
+		}
+		log.Warn().Str("model", cfg.Name).Str("language", detected).Strs("expected", constraints.Input).Msg("off-language input")
+	}
+
+	if constraints.Mode == "instruct" && len(constraints.Output) > 0 {
+		names := []string{}
+		for _, l := range constraints.Output {
+			names = append(names, languageName(l))
+		}
+		instruction := schema.Message{
+			Role:          "system",
+			Content:       "Always answer in " + strings.Join(names, " or ") + ".",
+			StringContent: "Always answer in " + strings.Join(names, " or ") + ".",
+		}
+		input.Messages = append([]schema.Message{instruction}, input.Messages...)
+	}
+
+	return detected, nil
+}
+
+// forceResponseLanguage adds the instruction to answer in the language forced by the response_language of the
+// request, which replaces the output languages of the model for the request. It returns the forced language
+func forceResponseLanguage(cfg *config.BackendConfig, input *schema.OpenAIRequest) (string, error) {
+	if input.ResponseLanguage == "" {
+		return "", nil
+	}
+	code := strings.ToLower(strings.TrimSpace(input.ResponseLanguage))
+	allowed := cfg.Languages.Response.Allowed
+	if len(allowed) == 0 {
+		allowed = slices.Sorted(maps.Keys(languageNames))
+	}
+	if !slices.Contains(allowed, code) {
+		return "", fiber.NewError(fiber.StatusBadRequest,
+			fmt.Sprintf("unsupported response_language %q, supported languages are %s", input.ResponseLanguage, strings.Join(allowed, ", ")))
+	}
+
+	text, err := cfg.Languages.Response.RenderInstruction(code, languageName(code))
+	if err != nil {
+		return "", err
+	}
+	instruction := schema.Message{Role: "system", Content: text, StringContent: text}
+	input.Messages = append([]schema.Message{instruction}, input.Messages...)
+
+	// the answer is checked against the forced language, and the instruction replaces the one of the instruct mode
+	cfg.Languages.Output = []string{code}
+	if cfg.Languages.Mode == "instruct" {
+		cfg.Languages.Mode = "warn"
+	}
+	return code, nil
+}
+
+// checkOutputLanguage detects the language of the output, logging off-language outputs
+func checkOutputLanguage(cfg *config.BackendConfig, output string) string {
+	detected, _ := langdetect.Detect(output)
+	if detected != "" && len(cfg.Languages.Output) > 0 && !slices.Contains(cfg.Languages.Output, detected) {
+		log.Warn().Str("model", cfg.Name).Str("language", detected).Strs("expected", cfg.Languages.Output).Msg("off-language output")
+	}
+	return detected
+}
@@ -0,0 +1,93 @@
+package openai
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckInputLanguage(t *testing.T) {
+	request := func(text string) *schema.OpenAIRequest {
+		return &schema.OpenAIRequest{Messages: []schema.Message{{Role: "user", Content: text, StringContent: text}}}
+	}
+	italian := "Ciao, come stai? Non ho capito la domanda."
+
+	cfg := &config.BackendConfig{Languages: config.LanguageConstraints{Input: []string{"en"}, Mode: "reject"}}
+	detected, err := checkInputLanguage(cfg, request(italian))
+	assert.Equal(t, "it", detected)
+	var fiberErr *fiber.Error
+	assert.True(t, errors.As(err, &fiberErr))
+	assert.Equal(t, fiber.StatusBadRequest, fiberErr.Code)
+
+	detected, err = checkInputLanguage(cfg, request("What is the weather like in Rome today?"))
+	assert.NoError(t, err)
+	assert.Equal(t, "en", detected)
+
+	// inputs whose language is not detected are not rejected
+	_, err = checkInputLanguage(cfg, request("Rome"))
+	assert.NoError(t, err)
+
+	cfg.Languages.Mode = "warn"
+	_, err = checkInputLanguage(cfg, request(italian))
+	assert.NoError(t, err)
+
+	cfg.Languages = config.LanguageConstraints{Output: []string{"it", "fr"}, Mode: "instruct"}
+	req := request("What is the weather like in Rome today?")
+	_, err = checkInputLanguage(cfg, req)
+	assert.NoError(t, err)
+	assert.Len(t, req.Messages, 2)
+	assert.Equal(t, "system", req.Messages[0].Role)
+	assert.Equal(t, "Always answer in Italian or French.", req.Messages[0].Content)
+
+	assert.Equal(t, "fr", checkOutputLanguage(cfg, "Il fait beau à Rome, je pense que vous allez aimer."))
+}
+
+func TestForceResponseLanguage(t *testing.T) {
+	request := func(language string) *schema.OpenAIRequest {
+		return &schema.OpenAIRequest{
+			ResponseLanguage: language,
+			Messages:         []schema.Message{{Role: "user", Content: "Ciao!", StringContent: "Ciao!"}},
+		}
+	}
+
+	cfg := &config.BackendConfig{Languages: config.LanguageConstraints{Output: []string{"it"}, Mode: "instruct"}}
+	req := request("FR")
+	forced, err := forceResponseLanguage(cfg, req)
+	assert.NoError(t, err)
+	assert.Equal(t, "fr", forced)
+	assert.Len(t, req.Messages, 2)
+	assert.Equal(t, "system", req.Messages[0].Role)
+	assert.Equal(t, "Always answer in French, regardless of the language of the messages.", req.Messages[0].StringContent)
+	// the forced language replaces the output languages, without the instruction of the instruct mode
+	assert.Equal(t, []string{"fr"}, cfg.Languages.Output)
+	_, err = checkInputLanguage(cfg, req)
+	assert.NoError(t, err)
+	assert.Len(t, req.Messages, 2)
+
+	// the requests without response_language are left as they are
+	req = request("")
+	forced, err = forceResponseLanguage(cfg, req)
+	assert.NoError(t, err)
+	assert.Empty(t, forced)
+	assert.Len(t, req.Messages, 1)
+
+	cfg = &config.BackendConfig{Languages: config.LanguageConstraints{Response: config.ResponseLanguage{
+		Allowed:     []string{"it", "en"},
+		Instruction: "Rispondi sempre in {{.Code}}.",
+	}}}
+	req = request("en")
+	_, err = forceResponseLanguage(cfg, req)
+	assert.NoError(t, err)
+	assert.Equal(t, "Rispondi sempre in en.", req.Messages[0].StringContent)
+
+	for _, language := range []string{"de", "Klingon"} {
+		_, err = forceResponseLanguage(cfg, request(language))
+		var fiberErr *fiber.Error
+		assert.True(t, errors.As(err, &fiberErr))
+		assert.Equal(t, fiber.StatusBadRequest, fiberErr.Code)
+	}
+}
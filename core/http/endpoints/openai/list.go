@@ -1,8 +1,11 @@
 package openai
 
 import (
+	"slices"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
 	"github.com/mudler/LocalAI/core/schema"
 	"github.com/mudler/LocalAI/core/services"
 	model "github.com/mudler/LocalAI/pkg/model"
@@ -34,11 +37,17 @@ func ListModelsEndpoint(bcl *config.BackendConfigLoader, ml *model.ModelLoader,
 		if err != nil {
 			return err
 		}
+		modelNames = fiberContext.FilterAllowedModels(c, modelNames)
 
 		// Map from a slice of names to a slice of OpenAIModel response objects
 		dataModels := []schema.OpenAIModel{}
 		for _, m := range modelNames {
-			dataModels = append(dataModels, schema.OpenAIModel{ID: m, Object: "model"})
+			entry := schema.OpenAIModel{ID: m, Object: "model"}
+			if cfg, exists := bcl.GetBackendConfig(m); exists && appConfig.ChatTemplateMetadata {
+				entry.ChatTemplateHash = cfg.ChatTemplateHash
+				entry.ChatTemplateSource = cfg.TemplateSource
+			}
+			dataModels = append(dataModels, entry)
 		}
 
 		return c.JSON(schema.ModelsDataResponse{
@@ -47,3 +56,42 @@ func ListModelsEndpoint(bcl *config.BackendConfigLoader, ml *model.ModelLoader,
 		})
 	}
 }
+
+// ModelDetailEndpoint is the OpenAI Retrieve model API endpoint https://platform.openai.com/docs/api-reference/models/retrieve
+// @Summary Describe a model, including the example requests of its configuration
+// @Param model path string true "Model name"
+// @Success 200 {object} schema.ModelDetailResponse "Response"
+// @Router /v1/models/{model} [get]
+func ModelDetailEndpoint(bcl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(ctx *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		name := c.Params("model")
+		if !fiberContext.ModelAllowed(c, name) {
+			return fiber.NewError(fiber.StatusForbidden, "the API key is not allowed to use this model")
+		}
+
+		resp := schema.ModelDetailResponse{OpenAIModel: schema.OpenAIModel{ID: name, Object: "model"}}
+
+		cfg, exists := bcl.GetBackendConfig(name)
+		if !exists {
+			modelNames, err := services.ListModels(bcl, ml, config.NoFilterFn, services.ALWAYS_INCLUDE)
+			if err != nil {
+				return err
+			}
+			if !slices.Contains(modelNames, name) {
+				return fiberContext.NewModelNotFoundError(c, bcl, ml, name, appConfig.ModelSuggestions)
+			}
+			return c.JSON(resp)
+		}
+
+		resp.Backend = cfg.Backend
+		resp.Description = cfg.Description
+		resp.Usage = cfg.Usage
+		resp.Examples = cfg.Examples
+		if appConfig.ChatTemplateMetadata {
+			resp.ChatTemplateHash = cfg.ChatTemplateHash
+			resp.ChatTemplateSource = cfg.TemplateSource
+		}
+
+		return c.JSON(resp)
+	}
+}
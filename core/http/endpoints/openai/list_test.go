@@ -0,0 +1,71 @@
+package openai
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelDetailEndpoint(t *testing.T) {
+	modelPath := t.TempDir()
+	err := os.WriteFile(filepath.Join(modelPath, "fim.yaml"), []byte(`name: fim
+backend: llama-cpp
+description: a code completion model
+template:
+  chat_message: "{{.RoleName}}: {{.Content}}"
+examples:
+- name: fill in the middle
+  endpoint: /v1/completions
+  request:
+    prompt: "<PRE> def add(a, b): <SUF> return c <MID>"
+    max_tokens: 32
+`), 0600)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(modelPath, "loose.gguf"), []byte{}, 0600))
+
+	loader := config.NewBackendConfigLoader(modelPath)
+	assert.NoError(t, loader.LoadBackendConfigsFromPath(modelPath))
+
+	app := fiber.New()
+	app.Get("/v1/models/:model", ModelDetailEndpoint(loader, model.NewModelLoader(modelPath), config.NewApplicationConfig(config.EnableChatTemplateMetadata)))
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/v1/models/fim", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	detail := schema.ModelDetailResponse{}
+	assert.NoError(t, json.Unmarshal(body, &detail))
+	assert.Equal(t, "fim", detail.ID)
+	assert.Equal(t, "llama-cpp", detail.Backend)
+	assert.Len(t, detail.Examples, 1)
+	assert.Equal(t, "/v1/completions", detail.Examples[0].Endpoint)
+	assert.Equal(t, float64(32), detail.Examples[0].Request["max_tokens"])
+	assert.Len(t, detail.ChatTemplateHash, 64)
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/v1/models/loose.gguf", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	body, _ = io.ReadAll(resp.Body)
+	assert.NotContains(t, string(body), "examples")
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/v1/models/missing", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+	app = fiber.New()
+	app.Get("/v1/models/:model", ModelDetailEndpoint(loader, model.NewModelLoader(modelPath), config.NewApplicationConfig(config.EnableModelSuggestions)))
+	resp, err = app.Test(httptest.NewRequest("GET", "/v1/models/fin", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	body, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, `model "fin" not found, did you mean "fim"?`, string(body))
+}
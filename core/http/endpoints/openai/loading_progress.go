@@ -0,0 +1,63 @@
+package openai
+
+import (
+	"time"
+
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+)
+
+// streamLoadingEvent is the SSE event of the progress of the load of the model of a stream
+const streamLoadingEvent = "localai.loading"
+
+// streamLoadingInterval is the interval at which the load of the model of a stream is checked and reported
+var streamLoadingInterval = 500 * time.Millisecond
+
+// maxLoadingProgress caps the estimated progress while the model is loading, the loads taking longer than expected
+const maxLoadingProgress = 0.99
+
+// modelLoading returns the progress of the load of the model, and whether it is loading
+type modelLoading func() (schema.StreamLoading, bool)
+
+// loadingProgress returns the progress of the load of the model of the request, or nil when it is not reported
+func loadingProgress(cfg *config.BackendConfig, ml *model.ModelLoader, appConfig *config.ApplicationConfig) modelLoading {
+	if !appConfig.StreamLoadingProgress {
+		return nil
+	}
+	modelID := backend.ModelID(*cfg)
+	return func() (schema.StreamLoading, bool) {
+		loading, started := ml.IsLoading(modelID)
+		if !loading {
+			return schema.StreamLoading{}, false
+		}
+		return newStreamLoading(cfg.Name, time.Since(started), ml.EstimatedLoadTime(modelID)), true
+	}
+}
+
+// newStreamLoading returns the progress of a load, estimated from the time the recent loads took
+func newStreamLoading(model string, elapsed, estimated time.Duration) schema.StreamLoading {
+	ev := schema.StreamLoading{
+		Object:        streamLoadingEvent,
+		Model:         model,
+		ElapsedMS:     elapsed.Milliseconds(),
+		Indeterminate: estimated <= 0,
+	}
+	if estimated > 0 {
+		progress := min(elapsed.Seconds()/estimated.Seconds(), maxLoadingProgress)
+		ev.EstimatedMS = estimated.Milliseconds()
+		ev.Progress = &progress
+	}
+	return ev
+}
+
+// loadingDone returns the last event of a load, once the model is loaded
+func loadingDone(last schema.StreamLoading, elapsed time.Duration) schema.StreamLoading {
+	progress := 1.0
+	last.ElapsedMS = elapsed.Milliseconds()
+	last.Progress = &progress
+	last.Indeterminate = false
+	last.Done = true
+	return last
+}
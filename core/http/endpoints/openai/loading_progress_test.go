@@ -0,0 +1,116 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStreamLoading(t *testing.T) {
+	ev := newStreamLoading("model", 2*time.Second, 8*time.Second)
+	assert.Equal(t, streamLoadingEvent, ev.Object)
+	assert.Equal(t, int64(2000), ev.ElapsedMS)
+	assert.Equal(t, int64(8000), ev.EstimatedMS)
+	require.NotNil(t, ev.Progress)
+	assert.InDelta(t, 0.25, *ev.Progress, 0.001)
+	assert.False(t, ev.Indeterminate)
+
+	// the loads taking longer than expected are not complete until the model is loaded
+	ev = newStreamLoading("model", 10*time.Second, 8*time.Second)
+	assert.Equal(t, maxLoadingProgress, *ev.Progress)
+
+	// the models never loaded have an indeterminate progress
+	ev = newStreamLoading("model", 2*time.Second, 0)
+	assert.True(t, ev.Indeterminate)
+	assert.Nil(t, ev.Progress)
+	assert.Zero(t, ev.EstimatedMS)
+
+	done := loadingDone(ev, 3*time.Second)
+	assert.True(t, done.Done)
+	assert.False(t, done.Indeterminate)
+	assert.Equal(t, 1.0, *done.Progress)
+	assert.Equal(t, int64(3000), done.ElapsedMS)
+}
+
+func TestForwardStreamLoading(t *testing.T) {
+	defer func(interval time.Duration) { streamLoadingInterval = interval }(streamLoadingInterval)
+	streamLoadingInterval = 10 * time.Millisecond
+
+	// the model is loading for the first checks, then the completion is generated
+	var checks atomic.Int32
+	loading := func() (schema.StreamLoading, bool) {
+		n := checks.Add(1)
+		if n > 5 {
+			return schema.StreamLoading{}, false
+		}
+		return newStreamLoading("model", time.Duration(n)*100*time.Millisecond, time.Second), true
+	}
+	responses := make(chan schema.OpenAIResponse)
+	go func() {
+		for checks.Load() <= 5 {
+			time.Sleep(5 * time.Millisecond)
+		}
+		for _, token := range []string{"Hello", " world"} {
+			responses <- schema.OpenAIResponse{Choices: []schema.Choice{{Delta: &schema.Message{Content: token}}}}
+		}
+		close(responses)
+	}()
+
+	out := &bytes.Buffer{}
+	w := bufio.NewWriter(out)
+	forwardStream(w, responses, 0, loading, func(ev schema.OpenAIResponse) {
+		data, _ := json.Marshal(ev)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		w.Flush()
+	})
+
+	events := []schema.StreamLoading{}
+	content := ""
+	for _, event := range strings.Split(strings.TrimSuffix(out.String(), "\n\n"), "\n\n") {
+		if rest, ok := strings.CutPrefix(event, "event: "+streamLoadingEvent+"\ndata: "); ok {
+			require.Empty(t, content, "a loading event was sent after the first chunk")
+			ev := schema.StreamLoading{}
+			require.NoError(t, json.Unmarshal([]byte(rest), &ev))
+			events = append(events, ev)
+			continue
+		}
+		data, ok := strings.CutPrefix(event, "data: ")
+		require.True(t, ok, event)
+		resp := schema.OpenAIResponse{}
+		require.NoError(t, json.Unmarshal([]byte(data), &resp))
+		content += resp.Choices[0].Delta.Content.(string)
+	}
+	assert.Equal(t, "Hello world", content)
+
+	// the progress events, then a single done event
+	require.Len(t, events, 6)
+	for i, ev := range events[:5] {
+		assert.False(t, ev.Done)
+		assert.InDelta(t, float64(i+1)/10, *ev.Progress, 0.001)
+	}
+	last := events[5]
+	assert.True(t, last.Done)
+	assert.Equal(t, 1.0, *last.Progress)
+	assert.Equal(t, "model", last.Model)
+	assert.GreaterOrEqual(t, last.ElapsedMS, int64(500))
+
+	// the models already loaded send no loading event
+	responses = make(chan schema.OpenAIResponse, 1)
+	responses <- schema.OpenAIResponse{Choices: []schema.Choice{{Delta: &schema.Message{Content: "Hi"}}}}
+	close(responses)
+	out.Reset()
+	forwardStream(w, responses, 0, func() (schema.StreamLoading, bool) { return schema.StreamLoading{}, false }, func(ev schema.OpenAIResponse) {
+		fmt.Fprint(w, "data: chunk\n\n")
+		w.Flush()
+	})
+	assert.Equal(t, "data: chunk\n\n", out.String())
+}
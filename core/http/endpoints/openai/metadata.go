@@ -0,0 +1,10 @@
+package openai
+
+// responseMetadata returns the metadata to attach to a response, or nil if there is none
+// so that the field is omitted
+func responseMetadata(metadata map[string]interface{}) map[string]interface{} {
+	if len(metadata) == 0 {
+		return nil
+	}
+	return metadata
+}
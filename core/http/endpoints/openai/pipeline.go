@@ -0,0 +1,256 @@
+package openai
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/rs/zerolog/log"
+	"github.com/valyala/fasthttp"
+)
+
+// pipelineStageKey marks the requests run by the stages of a pipeline model
+const pipelineStageKey = "pipelineStage"
+
+// subRequestKey marks the requests run by subRequest
+const subRequestKey = "subRequest"
+
+// subRequest runs the handler on a copy of the request with the given JSON body, so that
+// its response can be read. configure can adjust the copied request before running the handler
+func subRequest(c *fiber.Ctx, handler fiber.Handler, body interface{}, configure func(*fiber.Ctx)) (*fasthttp.RequestCtx, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	subCtx := &fasthttp.RequestCtx{}
+	c.Request().CopyTo(&subCtx.Request)
+	subCtx.Request.SetBody(data)
+	subCtx.Request.Header.SetContentType(fiber.MIMEApplicationJSON)
+	c.Context().VisitUserValues(func(k []byte, v interface{}) {
+		subCtx.SetUserValueBytes(k, v)
+	})
+
+	sub := c.App().AcquireCtx(subCtx)
+	defer c.App().ReleaseCtx(sub)
+	sub.Locals(subRequestKey, true)
+	if configure != nil {
+		configure(sub)
+	}
+	return subCtx, handler(sub)
+}
+
+// subResponse runs the handler like subRequest, returning its response, or the error it responded with
+func subResponse(c *fiber.Ctx, handler fiber.Handler, body interface{}, configure func(*fiber.Ctx)) (*schema.OpenAIResponse, error) {
+	subCtx, err := subRequest(c, handler, body, configure)
+	if err != nil {
+		return nil, err
+	}
+	if status := subCtx.Response.StatusCode(); status >= fiber.StatusBadRequest {
+		errResp := schema.ErrorResponse{}
+		if json.Unmarshal(subCtx.Response.Body(), &errResp) == nil && errResp.Error != nil {
+			return nil, fiber.NewError(status, errResp.Error.Message)
+		}
+		return nil, fiber.NewError(status, string(subCtx.Response.Body()))
+	}
+
+	resp := &schema.OpenAIResponse{}
+	if err := json.Unmarshal(subCtx.Response.Body(), resp); err != nil {
+		return nil, fmt.Errorf("failed reading the response: %w", err)
+	}
+	return resp, nil
+}
+
+// runPipeline runs the request through the stages of a pipeline model: the text output of
+// each stage is the input of the next one, starting from the last user message
+func runPipeline(c *fiber.Ctx, chat fiber.Handler, cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig, cfg *config.BackendConfig, input *schema.OpenAIRequest) error {
+	if c.Locals(pipelineStageKey) != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "a pipeline model cannot be a stage of another pipeline")
+	}
+	// the API keys restricted to some models can only run the pipelines of those models
+	for _, stage := range cfg.Pipeline {
+		if !fiberContext.ModelAllowed(c, stage.Model) {
+			return fiber.NewError(fiber.StatusForbidden, fmt.Sprintf("the API key is not allowed to use the model %q of the pipeline", stage.Model))
+		}
+	}
+
+	text := lastUserContent(input.Messages)
+	usage := schema.OpenAIUsage{}
+	stages := []map[string]interface{}{}
+	var audio *schema.MessageAudio
+
+	for i, stage := range cfg.Pipeline {
+		start := time.Now()
+		info := map[string]interface{}{"model": stage.Model, "type": stage.StageType()}
+
+		var err error
+		switch stage.StageType() {
+		case config.PipelineStageTTS:
+			audio, err = pipelineTTS(cl, ml, appConfig, stage, text)
+		default:
+			var out string
+			var stageUsage schema.OpenAIUsage
+			out, stageUsage, err = pipelineChat(c, chat, stage, input.Messages, text)
+			if err == nil {
+				text = out
+				usage.PromptTokens += stageUsage.PromptTokens
+				usage.CompletionTokens += stageUsage.CompletionTokens
+				usage.TotalTokens += stageUsage.TotalTokens
+				info["usage"] = stageUsage
+			}
+		}
+		info["duration_ms"] = time.Since(start).Milliseconds()
+
+		if err != nil {
+			if stage.OnError != config.PipelineOnErrorSkip {
+				return fmt.Errorf("pipeline stage %d (%s) failed: %w", i, stage.Model, err)
+			}
+			log.Warn().Err(err).Str("pipeline", cfg.Name).Str("stage", stage.Model).Msg("pipeline stage failed, skipping it")
+			info["error"] = err.Error()
+		}
+		stages = append(stages, info)
+	}
+
+	message := &schema.Message{Role: "assistant", Content: &text, Audio: audio}
+	resp := schema.OpenAIResponse{
+		ID:       uuid.New().String(),
+		Created:  int(time.Now().Unix()),
+		Model:    input.Model,
+		Object:   "chat.completion",
+		Usage:    usage,
+		Metadata: map[string]interface{}{"pipeline_stages": stages},
+	}
+	return sendCombinedResponse(c, resp, message, input.Stream)
+}
+
+// sendCombinedResponse sends the chat response of the models combining the output of other models
+// (pipelines and ensembles). The whole output is available at once: when streaming it is sent in a single chunk
+func sendCombinedResponse(c *fiber.Ctx, resp schema.OpenAIResponse, message *schema.Message, stream bool) error {
+	c.Set("X-Correlation-ID", resp.ID)
+
+	if !stream {
+		resp.Choices = []schema.Choice{{Index: 0, FinishReason: "stop", Message: message}}
+		return c.JSON(resp)
+	}
+
+	c.Context().SetContentType("text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("Transfer-Encoding", "chunked")
+
+	resp.Object = "chat.completion.chunk"
+	chunk := resp
+	chunk.Usage = schema.OpenAIUsage{}
+	chunk.Metadata = nil
+	chunk.Choices = []schema.Choice{{Index: 0, Delta: message}}
+	empty := ""
+	resp.Choices = []schema.Choice{{Index: 0, FinishReason: "stop", Delta: &schema.Message{Content: &empty}}}
+
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		for _, r := range []schema.OpenAIResponse{chunk, resp} {
+			data, _ := json.Marshal(r)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		w.WriteString("data: [DONE]\n\n")
+		w.Flush()
+	}))
+	return nil
+}
+
+// pipelineChat runs a chat stage of a pipeline, returning its output
+func pipelineChat(c *fiber.Ctx, chat fiber.Handler, stage config.PipelineStage, conversation []schema.Message, text string) (string, schema.OpenAIUsage, error) {
+	messages := []schema.Message{}
+	if stage.SystemPrompt != "" {
+		messages = append(messages, schema.Message{Role: "system", Content: stage.SystemPrompt})
+	}
+	if stage.Conversation {
+		last := -1
+		for i, m := range conversation {
+			if m.Role == "user" {
+				last = i
+			}
+		}
+		for i, m := range conversation {
+			msg := schema.Message{Role: m.Role, Name: m.Name, Content: m.Content}
+			if i == last && m.StringContent != text {
+				msg.Content = text
+			}
+			messages = append(messages, msg)
+		}
+	} else {
+		messages = append(messages, schema.Message{Role: "user", Content: text})
+	}
+
+	req := &schema.OpenAIRequest{
+		PredictionOptions: schema.PredictionOptions{Model: stage.Model},
+		Messages:          messages,
+	}
+	subCtx, err := subRequest(c, chat, req, func(sub *fiber.Ctx) {
+		// the stage model is set in the body only
+		sub.Request().URI().SetQueryString("")
+		sub.Locals(pipelineStageKey, true)
+	})
+	if err != nil {
+		return "", schema.OpenAIUsage{}, err
+	}
+
+	resp := schema.OpenAIResponse{}
+	if err := json.Unmarshal(subCtx.Response.Body(), &resp); err != nil {
+		return "", schema.OpenAIUsage{}, fmt.Errorf("failed reading the stage response: %w", err)
+	}
+	if len(resp.Choices) == 0 || resp.Choices[0].Message == nil {
+		return "", resp.Usage, fmt.Errorf("the stage returned no output")
+	}
+	content, _ := resp.Choices[0].Message.Content.(string)
+	return content, resp.Usage, nil
+}
+
+// pipelineTTS runs the text to speech stage of a pipeline, returning the synthesized audio
+func pipelineTTS(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig, stage config.PipelineStage, text string) (*schema.MessageAudio, error) {
+	cfg, err := cl.LoadBackendConfigFileByName(stage.Model, appConfig.ModelPath,
+		config.LoadOptionDebug(appConfig.Debug),
+		config.LoadOptionThreads(appConfig.Threads),
+		config.LoadOptionContextSize(appConfig.ContextSize),
+		config.LoadOptionF16(appConfig.F16),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	filePath, _, err := backend.ModelTTS(cfg.Backend, text, cfg.Model, cfg.Voice, cfg.Language, ml, appConfig, *cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(filePath)
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &schema.MessageAudio{
+		ID:         uuid.New().String(),
+		Data:       base64.StdEncoding.EncodeToString(data),
+		Format:     "wav",
+		Transcript: text,
+	}, nil
+}
+
+// lastUserContent returns the text content of the last user message
+func lastUserContent(messages []schema.Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].StringContent
+		}
+	}
+	return ""
+}
@@ -0,0 +1,103 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunPipeline(t *testing.T) {
+	requests := []schema.OpenAIRequest{}
+	// the stage models wrap their input with their name
+	chat := func(c *fiber.Ctx) error {
+		req := schema.OpenAIRequest{}
+		if err := json.Unmarshal(c.Body(), &req); err != nil {
+			return err
+		}
+		requests = append(requests, req)
+		if !fiberContext.ModelAllowed(c, req.Model) {
+			return fiber.NewError(fiber.StatusForbidden, "model not allowed")
+		}
+		if req.Model == "broken" {
+			return fiber.NewError(fiber.StatusServiceUnavailable, "backend unavailable")
+		}
+		content := fmt.Sprintf("%s(%s)", req.Model, req.Messages[len(req.Messages)-1].Content)
+		return c.JSON(schema.OpenAIResponse{
+			Choices: []schema.Choice{{Message: &schema.Message{Role: "assistant", Content: content}}},
+			Usage:   schema.OpenAIUsage{PromptTokens: 2, CompletionTokens: 3, TotalTokens: 5},
+		})
+	}
+
+	// the models allowed for the API key of the requests, nil for all of them
+	var allowed []string
+	run := func(cfg *config.BackendConfig) (int, schema.OpenAIResponse) {
+		input := &schema.OpenAIRequest{
+			PredictionOptions: schema.PredictionOptions{Model: cfg.Name},
+			Messages: []schema.Message{
+				{Role: "user", Content: "hi", StringContent: "hi"},
+				{Role: "assistant", Content: "hello"},
+				{Role: "user", Content: "ciao", StringContent: "ciao"},
+			},
+		}
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			fiberContext.SetAllowedModels(c, allowed)
+			return c.Next()
+		})
+		app.Post("/", func(c *fiber.Ctx) error {
+			return runPipeline(c, chat, nil, nil, nil, cfg, input)
+		})
+		resp, err := app.Test(httptest.NewRequest("POST", "/", nil))
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		result := schema.OpenAIResponse{}
+		json.Unmarshal(body, &result)
+		return resp.StatusCode, result
+	}
+
+	cfg := &config.BackendConfig{Name: "assistant", Pipeline: []config.PipelineStage{
+		{Model: "translate", SystemPrompt: "translate to English"},
+		{Model: "llm", Conversation: true},
+		{Model: "broken", OnError: config.PipelineOnErrorSkip},
+		{Model: "translate-back"},
+	}}
+	status, resp := run(cfg)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "translate-back(llm(translate(ciao)))", resp.Choices[0].Message.Content)
+	assert.Equal(t, 15, resp.Usage.TotalTokens)
+	assert.Len(t, resp.Metadata["pipeline_stages"], 4)
+
+	assert.Len(t, requests, 4)
+	assert.Equal(t, "system", requests[0].Messages[0].Role)
+	assert.Equal(t, "translate to English", requests[0].Messages[0].Content)
+	// the conversation is sent with the last user message replaced by the stage input
+	assert.Len(t, requests[1].Messages, 3)
+	assert.Equal(t, "hi", requests[1].Messages[0].Content)
+	assert.Equal(t, "translate(ciao)", requests[1].Messages[2].Content)
+
+	cfg.Pipeline[2].OnError = ""
+	status, _ = run(cfg)
+	assert.Equal(t, fiber.StatusServiceUnavailable, status)
+
+	// the API keys restricted to some models cannot run the pipelines of other models
+	requests = nil
+	allowed = []string{"assistant", "translate", "llm", "broken"}
+	status, _ = run(cfg)
+	assert.Equal(t, fiber.StatusForbidden, status)
+	assert.Empty(t, requests)
+
+	// the stages run with the restriction of the API key
+	allowed = append(allowed, "translate-back")
+	cfg.Pipeline[2].OnError = config.PipelineOnErrorSkip
+	status, _ = run(cfg)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Len(t, requests, 4)
+}
@@ -0,0 +1,83 @@
+package openai
+
+import (
+	"context"
+	"math"
+
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+// messagesSize returns the size of the text of the messages
+func messagesSize(messages []schema.Message) int {
+	size := 0
+	for _, m := range messages {
+		size += len(m.StringContent)
+	}
+	return size
+}
+
+// compressPrompt compresses the text of the non-system messages when they exceed the threshold configured
+// for the model. It returns the compression ratio (compressed size / original size), 0 if they were not compressed
+func compressPrompt(ctx context.Context, input *schema.OpenAIRequest, cfg *config.BackendConfig, cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) (float64, error) {
+	c := cfg.PromptCompression
+	if !c.Enabled {
+		return 0, nil
+	}
+
+	original := messagesSize(input.Messages)
+	compressible := []int{}
+	for i, m := range input.Messages {
+		// multimodal contents are left untouched
+		if _, ok := m.Content.(string); ok && m.Role != "system" && m.StringContent != "" {
+			compressible = append(compressible, i)
+		}
+	}
+
+	threshold := c.Threshold
+	if threshold == 0 && cfg.ContextSize != nil {
+		threshold = *cfg.ContextSize * 3 / 4
+	}
+	// roughly 4 characters per token
+	if original/4 <= threshold || len(compressible) == 0 {
+		return 0, nil
+	}
+
+	var compressionConfig *config.BackendConfig
+	method := backend.PromptCompressionMethod(c)
+	if method == backend.PromptCompressionModel {
+		var err error
+		compressionConfig, err = cl.LoadBackendConfigFileByName(c.Model, appConfig.ModelPath,
+			config.LoadOptionDebug(appConfig.Debug),
+			config.LoadOptionThreads(appConfig.Threads),
+			config.LoadOptionContextSize(appConfig.ContextSize),
+			config.LoadOptionF16(appConfig.F16),
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, i := range compressible {
+		text := input.Messages[i].StringContent
+		compressed := backend.RemoveRedundancy(text, seen)
+		if method == backend.PromptCompressionModel {
+			var err error
+			compressed, err = backend.CompressWithModel(ctx, compressed, c, *compressionConfig, ml, appConfig)
+			if err != nil {
+				return 0, err
+			}
+		}
+		input.Messages[i].Content = compressed
+		input.Messages[i].StringContent = compressed
+	}
+
+	ratio := float64(messagesSize(input.Messages)) / float64(original)
+	ratio = math.Round(ratio*1000) / 1000
+	log.Debug().Str("model", cfg.Name).Str("method", method).Float64("ratio", ratio).Msg("prompt compressed")
+	return ratio, nil
+}
@@ -0,0 +1,48 @@
+package openai
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressPrompt(t *testing.T) {
+	system := strings.Repeat("You are a helpful assistant. ", 10)
+	user := strings.Repeat("Summarize the following meeting notes. ", 10)
+	input := func() *schema.OpenAIRequest {
+		return &schema.OpenAIRequest{Messages: []schema.Message{
+			{Role: "system", Content: system, StringContent: system},
+			{Role: "user", Content: user, StringContent: user},
+		}}
+	}
+
+	contextSize := 1024
+	cfg := &config.BackendConfig{PromptCompression: config.PromptCompression{Enabled: true}}
+	cfg.ContextSize = &contextSize
+
+	// below the threshold
+	req := input()
+	ratio, err := compressPrompt(context.Background(), req, cfg, nil, nil, nil)
+	assert.NoError(t, err)
+	assert.Zero(t, ratio)
+	assert.Equal(t, user, req.Messages[1].StringContent)
+
+	cfg.PromptCompression.Threshold = 10
+	req = input()
+	ratio, err = compressPrompt(context.Background(), req, cfg, nil, nil, nil)
+	assert.NoError(t, err)
+	// the system message is preserved
+	assert.Equal(t, system, req.Messages[0].StringContent)
+	assert.Equal(t, "Summarize the following meeting notes.", req.Messages[1].StringContent)
+	assert.Equal(t, "Summarize the following meeting notes.", req.Messages[1].Content)
+	assert.InDelta(t, float64(len(system)+38)/float64(len(system)+len(user)), ratio, 0.001)
+
+	cfg.PromptCompression.Enabled = false
+	req = input()
+	ratio, _ = compressPrompt(context.Background(), req, cfg, nil, nil, nil)
+	assert.Zero(t, ratio)
+}
@@ -0,0 +1,61 @@
+package openai
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+// checkPromptInjection runs the prompt guard configured for the model (if any) against the user content.
+// It returns true if the response has to be tagged, and an error if the request has to be blocked.
+func checkPromptInjection(ctx context.Context, texts []string, cfg *config.BackendConfig, cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) (bool, error) {
+	guard := cfg.PromptGuard
+	if !guard.Enabled {
+		return false, nil
+	}
+
+	result, err := backend.DetectPromptInjection(texts, guard)
+	if err != nil {
+		return false, err
+	}
+
+	if !result.Detected && guard.ClassifierModel != "" {
+		classifierConfig, err := cl.LoadBackendConfigFileByName(guard.ClassifierModel, appConfig.ModelPath,
+			config.LoadOptionDebug(appConfig.Debug),
+			config.LoadOptionThreads(appConfig.Threads),
+			config.LoadOptionContextSize(appConfig.ContextSize),
+			config.LoadOptionF16(appConfig.F16),
+		)
+		if err != nil {
+			return false, err
+		}
+		detected, err := backend.ClassifyPromptInjection(ctx, texts, guard, *classifierConfig, ml, appConfig)
+		if err != nil {
+			return false, err
+		}
+		if detected {
+			result.Detected = true
+			result.Matches = append(result.Matches, guard.ClassifierModel)
+		}
+	}
+
+	if !result.Detected {
+		return false, nil
+	}
+
+	action := backend.PromptGuardAction(guard)
+	log.Warn().Str("model", cfg.Name).Str("action", action).Strs("matches", result.Matches).Msg("possible prompt injection detected")
+
+	switch action {
+	case backend.PromptGuardActionBlock:
+		return false, fiber.NewError(fiber.StatusBadRequest, "request rejected: possible prompt injection detected")
+	case backend.PromptGuardActionTag:
+		return true, nil
+	}
+
+	return false, nil
+}
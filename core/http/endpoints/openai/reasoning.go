@@ -0,0 +1,150 @@
+package openai
+
+import (
+	"strings"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+// reasoningSplitter separates the reasoning of the model output from the final answer.
+// The output is processed incrementally, so delimiters split across chunks are detected
+type reasoningSplitter struct {
+	startTag, endTag string
+
+	inReasoning bool
+	// trimContent drops the newlines emitted right after the end of the reasoning
+	trimContent bool
+	pending     string
+
+	// tokens counts the completion tokens spent reasoning
+	tokens, lastCompletion int
+}
+
+func newReasoningSplitter(cfg config.Reasoning) *reasoningSplitter {
+	start, end := cfg.Tags()
+	return &reasoningSplitter{startTag: start, endTag: end, inReasoning: cfg.StartsOpen}
+}
+
+// partialSuffix returns the length of the longest suffix of s which is a prefix of tag
+func partialSuffix(s, tag string) int {
+	for n := min(len(s), len(tag)-1); n > 0; n-- {
+		if strings.HasSuffix(s, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// Process consumes a chunk of the output and returns the reasoning and the content which can be emitted.
+// Text that might be the beginning of a delimiter is held back until the next chunk
+func (r *reasoningSplitter) Process(chunk string) (reasoning string, content string) {
+	r.pending += chunk
+	for {
+		tag := r.startTag
+		if r.inReasoning {
+			tag = r.endTag
+		}
+
+		var out string
+		idx := strings.Index(r.pending, tag)
+		if idx >= 0 {
+			out = r.pending[:idx]
+			r.pending = r.pending[idx+len(tag):]
+		} else {
+			keep := partialSuffix(r.pending, tag)
+			out = r.pending[:len(r.pending)-keep]
+			r.pending = r.pending[len(r.pending)-keep:]
+		}
+
+		if r.inReasoning {
+			reasoning += out
+		} else {
+			content += r.trim(out)
+		}
+
+		if idx < 0 {
+			return reasoning, content
+		}
+		if r.inReasoning {
+			r.trimContent = true
+		}
+		r.inReasoning = !r.inReasoning
+	}
+}
+
+// Track processes a streamed chunk like Process, counting the reasoning tokens from the
+// (cumulative) number of completion tokens generated so far
+func (r *reasoningSplitter) Track(chunk string, completionTokens int) (reasoning string, content string) {
+	wasReasoning := r.inReasoning
+	reasoning, content = r.Process(chunk)
+	if wasReasoning || r.inReasoning {
+		r.tokens += completionTokens - r.lastCompletion
+	}
+	r.lastCompletion = completionTokens
+	return reasoning, content
+}
+
+// Usage returns the usage details with the number of reasoning tokens counted by Track
+func (r *reasoningSplitter) Usage() *schema.CompletionTokensDetails {
+	return &schema.CompletionTokensDetails{ReasoningTokens: r.tokens}
+}
+
+// Flush returns the text held back by Process
+func (r *reasoningSplitter) Flush() (reasoning string, content string) {
+	out := r.pending
+	r.pending = ""
+	if r.inReasoning {
+		return out, ""
+	}
+	return "", r.trim(out)
+}
+
+func (r *reasoningSplitter) trim(s string) string {
+	if !r.trimContent {
+		return s
+	}
+	s = strings.TrimLeft(s, "\r\n")
+	if s != "" {
+		r.trimContent = false
+	}
+	return s
+}
+
+// splitReasoning separates the reasoning from the content of a complete output
+func splitReasoning(cfg config.Reasoning, s string) (reasoning string, content string) {
+	splitter := newReasoningSplitter(cfg)
+	reasoning, content = splitter.Process(s)
+	r, c := splitter.Flush()
+	return strings.TrimSpace(reasoning + r), content + c
+}
+
+// applyReasoningEffort applies the settings of the reasoning effort to the request: the budget of the reasoning
+// tokens, unless the request sets its own, and the sampling not set by the request
+func applyReasoningEffort(cfg *config.BackendConfig, input *schema.OpenAIRequest, settings config.ReasoningEffort, tools bool) {
+	if settings.MaxTokens > 0 {
+		budget := schema.TokenBudget{}
+		if input.TokenBudget != nil {
+			budget = *input.TokenBudget
+		}
+		if budget.Reasoning == 0 {
+			budget.Reasoning = settings.MaxTokens
+			cfg.SetTokenBudget(&budget, tools)
+		}
+	}
+	if settings.Temperature != nil && input.Temperature == nil {
+		cfg.Temperature = settings.Temperature
+	}
+	if settings.TopP != nil && input.TopP == nil {
+		cfg.TopP = settings.TopP
+	}
+}
+
+// reasoningDelta returns the delta of a streamed chunk with the reasoning and the content split
+func reasoningDelta(reasoning, content string) *schema.Message {
+	delta := &schema.Message{Content: &content}
+	if reasoning != "" {
+		delta.ReasoningContent = &reasoning
+	}
+	return delta
+}
@@ -0,0 +1,103 @@
+package openai
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func streamReasoning(cfg config.Reasoning, chunks []string) (string, string, int) {
+	splitter := newReasoningSplitter(cfg)
+	reasoning, content := "", ""
+	for i, c := range chunks {
+		r, t := splitter.Track(c, i+1)
+		reasoning += r
+		content += t
+	}
+	r, t := splitter.Flush()
+	return reasoning + r, content + t, splitter.Usage().ReasoningTokens
+}
+
+func TestReasoningSplitterAcrossChunks(t *testing.T) {
+	chunks := []string{"<th", "ink>", "Let me", " think", "</", "thi", "nk>", "\n\n", "The answer", " is 42"}
+	reasoning, content, tokens := streamReasoning(config.Reasoning{Enabled: true}, chunks)
+	assert.Equal(t, "Let me think", reasoning)
+	assert.Equal(t, "The answer is 42", content)
+	assert.Equal(t, 6, tokens)
+}
+
+func TestReasoningSplitterStartsOpen(t *testing.T) {
+	chunks := []string{"hmm", "[/R]", "ok"}
+	reasoning, content, tokens := streamReasoning(config.Reasoning{Enabled: true, StartsOpen: true, EndTag: "[/R]"}, chunks)
+	assert.Equal(t, "hmm", reasoning)
+	assert.Equal(t, "ok", content)
+	assert.Equal(t, 2, tokens)
+}
+
+func TestReasoningSplitterHoldsBackPartialTags(t *testing.T) {
+	splitter := newReasoningSplitter(config.Reasoning{Enabled: true})
+	reasoning, content := splitter.Process("a <")
+	assert.Equal(t, "", reasoning)
+	assert.Equal(t, "a ", content)
+
+	// not a delimiter after all
+	reasoning, content = splitter.Process("b")
+	assert.Equal(t, "", reasoning)
+	assert.Equal(t, "<b", content)
+
+	reasoning, content = splitter.Process("<think>x</thi")
+	assert.Equal(t, "x", reasoning)
+	assert.Equal(t, "", content)
+
+	reasoning, content = splitter.Flush()
+	assert.Equal(t, "</thi", reasoning)
+	assert.Equal(t, "", content)
+}
+
+func TestSplitReasoning(t *testing.T) {
+	reasoning, content := splitReasoning(config.Reasoning{Enabled: true}, "<think>\nstep 1\n</think>\n\nanswer")
+	assert.Equal(t, "step 1", reasoning)
+	assert.Equal(t, "answer", content)
+
+	reasoning, content = splitReasoning(config.Reasoning{Enabled: true}, "no reasoning")
+	assert.Equal(t, "", reasoning)
+	assert.Equal(t, "no reasoning", content)
+}
+
+func TestReasoningEffortPassthrough(t *testing.T) {
+	input := &schema.OpenAIRequest{}
+	require.NoError(t, json.Unmarshal([]byte(`{"model": "qwq", "reasoning_effort": "low"}`), input))
+	cfg := &config.BackendConfig{}
+	cfg.Reasoning = config.Reasoning{Enabled: true, Effort: config.ReasoningEffortHigh}
+
+	effort, settings, err := cfg.Reasoning.ResolveEffort(input.ReasoningEffort)
+	require.NoError(t, err)
+	assert.Equal(t, config.ReasoningEffortLow, effort)
+	applyReasoningEffort(cfg, input, settings, false)
+	budget, _ := cfg.TokenBudget()
+	require.NotNil(t, budget)
+	assert.Equal(t, 1024, budget.Reasoning)
+}
+
+func TestReasoningEffortKeepsTheRequestSettings(t *testing.T) {
+	temperature, effortTemperature := 0.2, 0.9
+	input := &schema.OpenAIRequest{TokenBudget: &schema.TokenBudget{Reasoning: 100, Content: 50}}
+	input.Temperature = &temperature
+	cfg := &config.BackendConfig{}
+	cfg.Temperature = &temperature
+
+	applyReasoningEffort(cfg, input, config.ReasoningEffort{MaxTokens: 4096, Temperature: &effortTemperature}, false)
+	budget, _ := cfg.TokenBudget()
+	assert.Nil(t, budget)
+	assert.Equal(t, 0.2, *cfg.Temperature)
+
+	input = &schema.OpenAIRequest{TokenBudget: &schema.TokenBudget{Content: 50}}
+	applyReasoningEffort(cfg, input, config.ReasoningEffort{MaxTokens: 4096, Temperature: &effortTemperature}, false)
+	budget, _ = cfg.TokenBudget()
+	assert.Equal(t, &schema.TokenBudget{Reasoning: 4096, Content: 50}, budget)
+	assert.Equal(t, 0.9, *cfg.Temperature)
+}
@@ -0,0 +1,17 @@
+package openai
+
+import "github.com/mudler/LocalAI/core/schema"
+
+// FinishReasonRepetition is the finish reason of the outputs stopped because they repeated the same phrase in a loop
+const FinishReasonRepetition = "repetition"
+
+// takeFinishReason returns the finish reason set by the backend on a streamed chunk, clearing it as the finish
+// reason is sent in the final chunk of the stream
+func takeFinishReason(ev *schema.OpenAIResponse) string {
+	if len(ev.Choices) == 0 {
+		return ""
+	}
+	reason := ev.Choices[0].FinishReason
+	ev.Choices[0].FinishReason = ""
+	return reason
+}
@@ -0,0 +1,314 @@
+package openai
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/templates"
+	"github.com/mudler/LocalAI/pkg/utils"
+)
+
+// ReplaysDir is the directory of the upload directory where the replay reports are stored
+const ReplaysDir = "replays"
+
+const (
+	replayEndpointChat       = "chat"
+	replayEndpointCompletion = "completion"
+)
+
+// replayRequest is a request read from a log to be replayed
+type replayRequest struct {
+	key      string
+	line     int
+	endpoint string
+	body     map[string]interface{}
+	expected string
+}
+
+// parseReplayLog reads the requests of a JSONL log. The lines are either OpenAI batch entries
+// ({"custom_id", "url", "body"}), request bodies, or fine-tuning examples, whose trailing assistant reply is the expected output
+func parseReplayLog(data []byte) ([]replayRequest, error) {
+	requests := []replayRequest{}
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("line %d: invalid JSON: %w", i+1, err)
+		}
+		req := replayRequest{key: strconv.Itoa(i + 1), line: i + 1, body: entry}
+		url := ""
+		if body, ok := entry["body"].(map[string]interface{}); ok {
+			if id, ok := entry["custom_id"].(string); ok && id != "" {
+				req.key = id
+			}
+			url, _ = entry["url"].(string)
+			req.body = body
+		}
+
+		_, hasMessages := req.body["messages"]
+		_, hasPrompt := req.body["prompt"]
+		switch {
+		case strings.HasSuffix(url, "/chat/completions") || (url == "" && hasMessages):
+			req.endpoint = replayEndpointChat
+		case strings.HasSuffix(url, "/completions") || (url == "" && hasPrompt):
+			req.endpoint = replayEndpointCompletion
+		default:
+			return nil, fmt.Errorf("line %d: only chat and completion requests can be replayed", i+1)
+		}
+
+		// fine-tuning examples carry no model, and end with the reply of the assistant
+		if _, hasModel := req.body["model"]; !hasModel && req.endpoint == replayEndpointChat {
+			messages, _ := req.body["messages"].([]interface{})
+			if n := len(messages); n > 1 {
+				if last, ok := messages[n-1].(map[string]interface{}); ok && last["role"] == "assistant" {
+					req.expected, _ = last["content"].(string)
+					req.body["messages"] = messages[:n-1]
+				}
+			}
+		}
+		requests = append(requests, req)
+	}
+	if len(requests) == 0 {
+		return nil, errors.New("the log has no requests")
+	}
+	return requests, nil
+}
+
+// runReplay replays the requests against the model, one at a time and with the same seed, so that
+// the outputs are stable across replays of the same model
+func runReplay(c *fiber.Ctx, handlers map[string]fiber.Handler, requests []replayRequest, modelName string, seed int) *schema.ReplayReport {
+	report := &schema.ReplayReport{
+		ID:      "replay-" + uuid.New().String(),
+		Object:  "replay",
+		Created: int(time.Now().Unix()),
+		Model:   modelName,
+		Seed:    seed,
+		Results: []schema.ReplayResult{},
+	}
+
+	for _, req := range requests {
+		start := time.Now()
+		result := schema.ReplayResult{Key: req.key, Line: req.line, Endpoint: req.endpoint, Expected: req.expected}
+
+		req.body["model"] = modelName
+		req.body["seed"] = seed
+		req.body["stream"] = false
+		resp, err := subResponse(c, handlers[req.endpoint], req.body, func(sub *fiber.Ctx) {
+			// the model is set in the body only
+			sub.Request().URI().SetQueryString("")
+		})
+		result.DurationMS = time.Since(start).Milliseconds()
+		switch {
+		case err != nil:
+			result.Error = err.Error()
+		case len(resp.Choices) == 0:
+			result.Error = "the model returned no output"
+		default:
+			result.Usage = resp.Usage
+			if req.endpoint == replayEndpointCompletion {
+				result.Output = resp.Choices[0].Text
+			} else if resp.Choices[0].Message != nil {
+				result.Output, _ = resp.Choices[0].Message.Content.(string)
+			}
+		}
+
+		if result.Error != "" {
+			report.Failed++
+		} else {
+			report.Succeeded++
+			report.Usage.PromptTokens += result.Usage.PromptTokens
+			report.Usage.CompletionTokens += result.Usage.CompletionTokens
+			report.Usage.TotalTokens += result.Usage.TotalTokens
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report
+}
+
+// replayOutput is the output of a replayed request as compared across replays
+func replayOutput(r schema.ReplayResult) string {
+	if r.Error != "" {
+		return "error: " + r.Error
+	}
+	return r.Output
+}
+
+// diffReplay compares the outputs of a replay with the ones of a baseline replay, matching the requests by key
+func diffReplay(baseline, report *schema.ReplayReport) *schema.ReplayDiff {
+	diff := &schema.ReplayDiff{Baseline: baseline.ID, Changes: []schema.ReplayChange{}}
+	outputs := map[string]string{}
+	for _, r := range baseline.Results {
+		outputs[r.Key] = replayOutput(r)
+	}
+
+	for _, r := range report.Results {
+		previous, ok := outputs[r.Key]
+		if !ok {
+			diff.Added++
+			continue
+		}
+		delete(outputs, r.Key)
+		diff.Compared++
+		if output := replayOutput(r); output != previous {
+			diff.Changed++
+			diff.Changes = append(diff.Changes, schema.ReplayChange{Key: r.Key, Baseline: previous, Output: output})
+		} else {
+			diff.Unchanged++
+		}
+	}
+	diff.Removed = len(outputs)
+	return diff
+}
+
+func replayPath(appConfig *config.ApplicationConfig, id string) string {
+	return filepath.Join(appConfig.UploadDir, ReplaysDir, utils.SanitizeFileName(id)+".json")
+}
+
+func saveReplay(appConfig *config.ApplicationConfig, report *schema.ReplayReport) error {
+	if err := os.MkdirAll(filepath.Join(appConfig.UploadDir, ReplaysDir), 0750); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(report, "", " ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(replayPath(appConfig, report.ID), data, 0600)
+}
+
+func loadReplay(appConfig *config.ApplicationConfig, id string) (*schema.ReplayReport, error) {
+	data, err := os.ReadFile(replayPath(appConfig, id))
+	if os.IsNotExist(err) {
+		return nil, fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("replay %q not found", id))
+	}
+	if err != nil {
+		return nil, err
+	}
+	report := &schema.ReplayReport{}
+	if err := json.Unmarshal(data, report); err != nil {
+		return nil, fmt.Errorf("failed reading replay %q: %w", id, err)
+	}
+	return report, nil
+}
+
+// readReplayLog reads the log uploaded with the request, or the one of the uploaded file given by file_id
+func readReplayLog(c *fiber.Ctx, appConfig *config.ApplicationConfig) ([]byte, error) {
+	if id := c.FormValue("file_id"); id != "" {
+		for _, f := range UploadedFiles {
+			if f.ID == id {
+				return os.ReadFile(filepath.Join(appConfig.UploadDir, utils.SanitizeFileName(f.Filename)))
+			}
+		}
+		return nil, fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("unable to find file id %s", id))
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "either a file or a file_id is required")
+	}
+	if file.Size > int64(appConfig.EffectiveUploadLimitMB()*1024*1024) {
+		return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("File size %d exceeds upload limit %d", file.Size, appConfig.EffectiveUploadLimitMB()))
+	}
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// ReplayEndpoint replays a JSONL log of chat and completion requests against a model, storing the report
+// to compare it with the following replays
+// @Summary Replay a log of requests against a model.
+// @Param file formData file false "JSONL log of requests"
+// @Param file_id formData string false "ID of an uploaded JSONL log of requests"
+// @Param model formData string true "model"
+// @Param seed formData int false "seed of all the requests, 0 by default"
+// @Param baseline formData string false "ID of a previous replay to compare the outputs with"
+// @Success 200 {object} schema.ReplayReport "Response"
+// @Router /v1/replays [post]
+func ReplayEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluator *templates.Evaluator, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	handlers := map[string]fiber.Handler{
+		replayEndpointChat:       ChatEndpoint(cl, ml, evaluator, appConfig),
+		replayEndpointCompletion: CompletionEndpoint(cl, ml, evaluator, appConfig),
+	}
+
+	return func(c *fiber.Ctx) error {
+		modelName := c.FormValue("model")
+		if modelName == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "model is required")
+		}
+		seed := 0
+		if s := c.FormValue("seed"); s != "" {
+			var err error
+			if seed, err = strconv.Atoi(s); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid seed %q", s))
+			}
+		}
+
+		var baseline *schema.ReplayReport
+		if id := c.FormValue("baseline"); id != "" {
+			var err error
+			if baseline, err = loadReplay(appConfig, id); err != nil {
+				return err
+			}
+		}
+
+		data, err := readReplayLog(c, appConfig)
+		if err != nil {
+			return err
+		}
+		requests, err := parseReplayLog(data)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		report := runReplay(c, handlers, requests, modelName, seed)
+		if err := saveReplay(appConfig, report); err != nil {
+			return fmt.Errorf("failed saving the replay: %w", err)
+		}
+		if baseline != nil {
+			report.Diff = diffReplay(baseline, report)
+		}
+		return c.JSON(report)
+	}
+}
+
+// GetReplayEndpoint returns a stored replay report, compared with the baseline replay given in the query
+// @Summary Get a replay report.
+// @Param replay_id path string true "replay ID"
+// @Param baseline query string false "ID of a previous replay to compare the outputs with"
+// @Success 200 {object} schema.ReplayReport "Response"
+// @Router /v1/replays/{replay_id} [get]
+func GetReplayEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		report, err := loadReplay(appConfig, c.Params("replay_id"))
+		if err != nil {
+			return err
+		}
+		if id := c.Query("baseline"); id != "" {
+			baseline, err := loadReplay(appConfig, id)
+			if err != nil {
+				return err
+			}
+			report.Diff = diffReplay(baseline, report)
+		}
+		return c.JSON(report)
+	}
+}
@@ -0,0 +1,95 @@
+package openai
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReplayLog(t *testing.T) {
+	requests, err := parseReplayLog([]byte(`{"custom_id": "greeting", "method": "POST", "url": "/v1/chat/completions", "body": {"model": "gpt-4", "messages": [{"role": "user", "content": "hi"}]}}
+
+{"model": "gpt-3.5-turbo-instruct", "prompt": "Once upon a time"}
+{"messages": [{"role": "system", "content": "be brief"}, {"role": "user", "content": "2+2?"}, {"role": "assistant", "content": "4"}]}
+`))
+	require.NoError(t, err)
+	require.Len(t, requests, 3)
+
+	assert.Equal(t, "greeting", requests[0].key)
+	assert.Equal(t, replayEndpointChat, requests[0].endpoint)
+	assert.Equal(t, "gpt-4", requests[0].body["model"])
+
+	assert.Equal(t, "3", requests[1].key)
+	assert.Equal(t, replayEndpointCompletion, requests[1].endpoint)
+
+	// the reply of the fine-tuning example is the expected output
+	assert.Equal(t, "4", requests[2].expected)
+	assert.Len(t, requests[2].body["messages"], 2)
+
+	_, err = parseReplayLog([]byte(`{"model": "embedder", "input": "hi"}`))
+	assert.ErrorContains(t, err, "line 1")
+	_, err = parseReplayLog([]byte("\n\n"))
+	assert.Error(t, err)
+}
+
+func TestRunReplay(t *testing.T) {
+	requests, err := parseReplayLog([]byte(`{"custom_id": "a", "body": {"messages": [{"role": "user", "content": "hi"}]}}
+{"custom_id": "b", "body": {"prompt": "Once upon a time"}}
+{"custom_id": "c", "body": {"messages": [{"role": "user", "content": "fail"}]}}
+`))
+	require.NoError(t, err)
+
+	chat := func(c *fiber.Ctx) error {
+		req := schema.OpenAIRequest{}
+		require.NoError(t, json.Unmarshal(c.Body(), &req))
+		assert.Equal(t, "candidate", req.Model)
+		assert.Equal(t, 7, *req.Seed)
+		assert.False(t, req.Stream)
+		if req.Messages[0].Content == "fail" {
+			return fiber.NewError(fiber.StatusInternalServerError, "backend crashed")
+		}
+		return c.JSON(schema.OpenAIResponse{
+			Choices: []schema.Choice{{Message: &schema.Message{Role: "assistant", Content: "hello"}}},
+			Usage:   schema.OpenAIUsage{PromptTokens: 1, CompletionTokens: 2, TotalTokens: 3},
+		})
+	}
+	completion := func(c *fiber.Ctx) error {
+		return c.JSON(schema.OpenAIResponse{Choices: []schema.Choice{{Text: " there was a model"}}})
+	}
+
+	var report *schema.ReplayReport
+	app := fiber.New()
+	app.Post("/", func(c *fiber.Ctx) error {
+		report = runReplay(c, map[string]fiber.Handler{replayEndpointChat: chat, replayEndpointCompletion: completion}, requests, "candidate", 7)
+		return nil
+	})
+	_, err = app.Test(httptest.NewRequest("POST", "/", strings.NewReader("")))
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, report.Succeeded)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, 3, report.Usage.TotalTokens)
+	assert.Equal(t, "hello", report.Results[0].Output)
+	assert.Equal(t, " there was a model", report.Results[1].Output)
+	assert.Contains(t, report.Results[2].Error, "backend crashed")
+
+	baseline := &schema.ReplayReport{ID: "replay-baseline", Results: []schema.ReplayResult{
+		{Key: "a", Output: "hello"},
+		{Key: "b", Output: " there was a princess"},
+		{Key: "d", Output: "removed"},
+	}}
+	diff := diffReplay(baseline, report)
+	assert.Equal(t, "replay-baseline", diff.Baseline)
+	assert.Equal(t, 2, diff.Compared)
+	assert.Equal(t, 1, diff.Unchanged)
+	assert.Equal(t, 1, diff.Changed)
+	assert.Equal(t, 1, diff.Added)
+	assert.Equal(t, 1, diff.Removed)
+	assert.Equal(t, schema.ReplayChange{Key: "b", Baseline: " there was a princess", Output: " there was a model"}, diff.Changes[0])
+}
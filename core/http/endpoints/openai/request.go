@@ -11,6 +11,7 @@ import (
 	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
 	"github.com/mudler/LocalAI/core/schema"
 	"github.com/mudler/LocalAI/pkg/functions"
+	"github.com/mudler/LocalAI/pkg/grpc"
 	"github.com/mudler/LocalAI/pkg/model"
 	"github.com/mudler/LocalAI/pkg/templates"
 	"github.com/mudler/LocalAI/pkg/utils"
@@ -43,7 +44,46 @@ func readRequest(c *fiber.Ctx, cl *config.BackendConfigLoader, ml *model.ModelLo
 
 	log.Debug().Msgf("Request received: %s", string(received))
 
+	// overriding the backend is reserved to the admin API keys
+	if input.Backend != "" && len(o.ApiKeys) > 0 && !fiberContext.IsAdmin(c) {
+		return "", nil, fiber.NewError(fiber.StatusForbidden, "overriding the backend requires an admin API key")
+	}
+
+	// the requests of the interactive endpoints run first on the backends that do not run requests in parallel
+	priority, err := fiberContext.RequestPriority(c, o)
+	if err != nil {
+		return "", nil, err
+	}
+	fiberContext.SetPriority(c, priority)
+	input.Context = grpc.WithPriority(input.Context, priority)
+
 	modelFile, err := fiberContext.ModelFromContext(c, cl, ml, input.Model, firstModel)
+	if err != nil {
+		return modelFile, input, err
+	}
+	// with a backend override, the model can be a name the backend resolves (e.g. a HuggingFace repository)
+	if modelFile != "" && input.Backend == "" {
+		if err := fiberContext.CheckModelExists(c, cl, ml, modelFile, o.ModelSuggestions); err != nil {
+			return modelFile, input, err
+		}
+	}
+
+	cfg, ok := cl.GetBackendConfig(modelFile)
+	if !ok {
+		cfg = config.BackendConfig{}
+	}
+	input, err = applyTransforms(c, &cfg, input)
+	if err != nil {
+		return modelFile, nil, err
+	}
+	if webhook, timeout, failOpen := cfg.RequestWebhookSettings(o); webhook != "" {
+		input, err = validateWithWebhook(webhook, timeout, failOpen, schema.RequestWebhookCall{
+			Model:     modelFile,
+			Endpoint:  c.Path(),
+			RequestID: c.GetRespHeader(fiber.HeaderXRequestID),
+			Request:   input,
+		})
+	}
 
 	return modelFile, input, err
 }
@@ -59,8 +99,9 @@ func updateRequestConfig(config *config.BackendConfig, input *schema.OpenAIReque
 		config.TopP = input.TopP
 	}
 
-	if input.Backend != "" {
+	if input.Backend != "" && input.Backend != config.Backend {
 		config.Backend = input.Backend
+		config.BackendOverride = true
 	}
 
 	if input.ClipSkip != 0 {
@@ -309,8 +350,8 @@ func mergeRequestWithConfig(modelFile string, input *schema.OpenAIRequest, cm *c
 	// Set the parameters for the language model prediction
 	updateRequestConfig(cfg, input)
 
-	if !cfg.Validate() {
-		return nil, nil, fmt.Errorf("failed to validate config")
+	if err := cfg.ValidateErr(); err != nil {
+		return nil, nil, fmt.Errorf("failed to validate config: %w", err)
 	}
 
 	return cfg, input, err
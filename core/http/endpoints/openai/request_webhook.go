@@ -0,0 +1,99 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/rs/zerolog/log"
+)
+
+// maxRequestWebhookResponse caps the size of the answers of the request webhook
+const maxRequestWebhookResponse = 16 << 20
+
+// callRequestWebhook sends the request to the validation webhook and returns its decision
+func callRequestWebhook(ctx context.Context, webhook string, timeout time.Duration, call schema.RequestWebhookCall) (*schema.RequestWebhookDecision, error) {
+	body, err := json.Marshal(call)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if call.RequestID != "" {
+		req.Header.Set(fiber.HeaderXRequestID, call.RequestID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the webhook returned status %d", resp.StatusCode)
+	}
+
+	decision := &schema.RequestWebhookDecision{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxRequestWebhookResponse)).Decode(decision); err != nil {
+		return nil, fmt.Errorf("invalid webhook response: %w", err)
+	}
+	return decision, nil
+}
+
+// validateWithWebhook runs the request through the validation webhook. It returns the request to serve,
+// which the webhook may have modified, or an error if the request is denied. The model of the request
+// cannot be changed by the webhook
+func validateWithWebhook(webhook string, timeout time.Duration, failOpen bool, call schema.RequestWebhookCall) (*schema.OpenAIRequest, error) {
+	input := call.Request
+	logger := log.With().Str("model", call.Model).Str("endpoint", call.Endpoint).Str("request_id", call.RequestID).Logger()
+
+	decision, err := callRequestWebhook(input.Context, webhook, timeout, call)
+	if err == nil {
+		switch decision.Decision {
+		case schema.RequestWebhookAllow, schema.RequestWebhookDeny, schema.RequestWebhookModify:
+		default:
+			err = fmt.Errorf("invalid webhook decision %q", decision.Decision)
+		}
+	}
+	if err != nil {
+		if failOpen {
+			logger.Warn().Err(err).Msg("request webhook failed, allowing the request")
+			return input, nil
+		}
+		logger.Error().Err(err).Msg("request webhook failed, rejecting the request")
+		return nil, fiber.NewError(fiber.StatusServiceUnavailable, "the request could not be validated")
+	}
+
+	logger.Info().Str("decision", decision.Decision).Str("reason", decision.Reason).Msg("request webhook decision")
+
+	switch decision.Decision {
+	case schema.RequestWebhookDeny:
+		reason := decision.Reason
+		if reason == "" {
+			reason = "the request was denied"
+		}
+		return nil, fiber.NewError(fiber.StatusForbidden, reason)
+	case schema.RequestWebhookModify:
+		modified := new(schema.OpenAIRequest)
+		if err := json.Unmarshal(decision.Request, modified); err != nil {
+			logger.Error().Err(err).Msg("invalid request returned by the request webhook")
+			return nil, fiber.NewError(fiber.StatusServiceUnavailable, "the request could not be validated")
+		}
+		modified.Model = input.Model
+		modified.Context = input.Context
+		modified.Cancel = input.Cancel
+		return modified, nil
+	}
+	return input, nil
+}
@@ -0,0 +1,84 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateWithWebhook(t *testing.T) {
+	var received schema.RequestWebhookCall
+	decision := schema.RequestWebhookDecision{}
+	delay := time.Duration(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		time.Sleep(delay)
+		json.NewEncoder(w).Encode(decision)
+	}))
+	defer server.Close()
+
+	call := func() schema.RequestWebhookCall {
+		return schema.RequestWebhookCall{
+			Model:     "chat",
+			Endpoint:  "/v1/chat/completions",
+			RequestID: "42",
+			Request: &schema.OpenAIRequest{
+				PredictionOptions: schema.PredictionOptions{Model: "chat"},
+				Messages:          []schema.Message{{Role: "user", Content: "hello"}},
+				Context:           context.Background(),
+			},
+		}
+	}
+	statusOf := func(err error) int {
+		var fiberErr *fiber.Error
+		require.True(t, errors.As(err, &fiberErr))
+		return fiberErr.Code
+	}
+
+	decision = schema.RequestWebhookDecision{Decision: schema.RequestWebhookAllow}
+	input, err := validateWithWebhook(server.URL, time.Second, false, call())
+	require.NoError(t, err)
+	assert.Equal(t, "hello", input.Messages[0].Content)
+	assert.Equal(t, "/v1/chat/completions", received.Endpoint)
+	assert.Equal(t, "42", received.RequestID)
+	assert.Equal(t, "chat", received.Request.Model)
+
+	decision = schema.RequestWebhookDecision{Decision: schema.RequestWebhookDeny, Reason: "personal data"}
+	_, err = validateWithWebhook(server.URL, time.Second, true, call())
+	assert.Equal(t, fiber.StatusForbidden, statusOf(err))
+	assert.EqualError(t, err, "personal data")
+
+	// the model cannot be changed
+	decision = schema.RequestWebhookDecision{
+		Decision: schema.RequestWebhookModify,
+		Request:  json.RawMessage(`{"model": "other", "messages": [{"role": "user", "content": "[redacted]"}], "max_tokens": 10}`),
+	}
+	input, err = validateWithWebhook(server.URL, time.Second, false, call())
+	require.NoError(t, err)
+	assert.Equal(t, "chat", input.Model)
+	assert.Equal(t, "[redacted]", input.Messages[0].Content)
+	assert.Equal(t, 10, *input.Maxtokens)
+	assert.NotNil(t, input.Context)
+
+	decision = schema.RequestWebhookDecision{Decision: "maybe"}
+	_, err = validateWithWebhook(server.URL, time.Second, false, call())
+	assert.Equal(t, fiber.StatusServiceUnavailable, statusOf(err))
+
+	// timeouts apply the failure policy
+	decision = schema.RequestWebhookDecision{Decision: schema.RequestWebhookDeny}
+	delay = 200 * time.Millisecond
+	_, err = validateWithWebhook(server.URL, 20*time.Millisecond, false, call())
+	assert.Equal(t, fiber.StatusServiceUnavailable, statusOf(err))
+	input, err = validateWithWebhook(server.URL, 20*time.Millisecond, true, call())
+	require.NoError(t, err)
+	assert.Equal(t, "hello", input.Messages[0].Content)
+}
@@ -0,0 +1,397 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/functions"
+	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/templates"
+	"github.com/rs/zerolog/log"
+	"github.com/valyala/fasthttp"
+)
+
+// ResponsesEndpoint is the OpenAI Responses API endpoint https://platform.openai.com/docs/api-reference/responses/create
+// The request is translated to a chat completion request, and the chat completion result is translated back to the responses format.
+// @Summary Generate a model response for the given input.
+// @Param request body schema.ResponsesRequest true "query params"
+// @Success 200 {object} schema.ResponsesResponse "Response"
+// @Router /v1/responses [post]
+func ResponsesEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluator *templates.Evaluator, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	chat := ChatEndpoint(cl, ml, evaluator, appConfig)
+
+	return func(c *fiber.Ctx) error {
+		input := new(schema.ResponsesRequest)
+		if err := c.BodyParser(input); err != nil {
+			return fmt.Errorf("failed parsing request body: %w", err)
+		}
+
+		chatRequest, err := responsesToChatRequest(input)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		// Run the chat endpoint on a separate request context, so that its response
+		// (which might be a stream) can be read and translated
+		chatCtx, err := subRequest(c, chat, chatRequest, nil)
+		if err != nil {
+			return err
+		}
+
+		for _, h := range []string{"X-Correlation-ID", CacheKeyHeader} {
+			if v := chatCtx.Response.Header.Peek(h); len(v) > 0 {
+				c.Set(h, string(v))
+			}
+		}
+
+		resp := &schema.ResponsesResponse{
+			ID:           "resp_" + uuid.New().String(),
+			Object:       "response",
+			CreatedAt:    int(time.Now().Unix()),
+			Model:        input.Model,
+			Instructions: input.Instructions,
+		}
+
+		if !input.Stream {
+			chatResponse := schema.OpenAIResponse{}
+			if err := json.Unmarshal(chatCtx.Response.Body(), &chatResponse); err != nil {
+				return fmt.Errorf("failed reading chat response: %w", err)
+			}
+			completeResponse(resp, chatResponse)
+			return c.JSON(resp)
+		}
+
+		stream, ok := chatCtx.Response.BodyStream().(io.ReadCloser)
+		if !ok {
+			return fmt.Errorf("expected a streamed chat response")
+		}
+
+		c.Context().SetContentType("text/event-stream")
+		c.Set("Cache-Control", "no-cache")
+		c.Set("Connection", "keep-alive")
+		c.Set("Transfer-Encoding", "chunked")
+
+		c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+			defer stream.Close()
+			streamResponses(stream, w, resp)
+		}))
+		return nil
+	}
+}
+
+// responsesToChatRequest translates a Responses API request to a chat completion request
+func responsesToChatRequest(input *schema.ResponsesRequest) (*schema.OpenAIRequest, error) {
+	req := &schema.OpenAIRequest{
+		PredictionOptions: schema.PredictionOptions{
+			Model:       input.Model,
+			Temperature: input.Temperature,
+			TopP:        input.TopP,
+			Maxtokens:   input.MaxOutputTokens,
+		},
+		Stream: input.Stream,
+	}
+
+	if input.Instructions != "" {
+		req.Messages = append(req.Messages, schema.Message{Role: "system", Content: input.Instructions})
+	}
+
+	switch in := input.Input.(type) {
+	case string:
+		req.Messages = append(req.Messages, schema.Message{Role: "user", Content: in})
+	case []interface{}:
+		dat, _ := json.Marshal(in)
+		items := []schema.ResponsesInputItem{}
+		if err := json.Unmarshal(dat, &items); err != nil {
+			return nil, fmt.Errorf("invalid input: %w", err)
+		}
+		messages, err := responsesInputToMessages(items)
+		if err != nil {
+			return nil, err
+		}
+		req.Messages = append(req.Messages, messages...)
+	case nil:
+		return nil, fmt.Errorf("input is required")
+	default:
+		return nil, fmt.Errorf("unsupported input type: %T", in)
+	}
+
+	for _, t := range input.Tools {
+		if t.Type != "function" {
+			return nil, fmt.Errorf("unsupported tool type: %s", t.Type)
+		}
+		req.Tools = append(req.Tools, functions.Tool{
+			Type: "function",
+			Function: functions.Function{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.Parameters,
+				Strict:      t.Strict,
+			},
+		})
+	}
+
+	// Only forcing a specific function is supported, "auto" is the default behavior
+	if tc, ok := input.ToolChoice.(map[string]interface{}); ok {
+		if name, ok := tc["name"].(string); ok && name != "" {
+			req.ToolsChoice = map[string]interface{}{
+				"type":     "function",
+				"function": map[string]interface{}{"name": name},
+			}
+		}
+	}
+
+	return req, nil
+}
+
+func responsesInputToMessages(items []schema.ResponsesInputItem) ([]schema.Message, error) {
+	messages := []schema.Message{}
+	// function names by call id, to name the tool results
+	callNames := map[string]string{}
+
+	for _, item := range items {
+		switch item.Type {
+		case "", "message":
+			role := item.Role
+			if role == "developer" {
+				role = "system"
+			}
+			content, err := responsesContentToChat(item.Content)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, schema.Message{Role: role, Content: content})
+		case "function_call":
+			callNames[item.CallID] = item.Name
+			toolCall := schema.ToolCall{
+				ID:   item.CallID,
+				Type: "function",
+				FunctionCall: schema.FunctionCall{
+					Name:      item.Name,
+					Arguments: item.Arguments,
+				},
+			}
+			// consecutive function calls belong to the same assistant turn
+			if l := len(messages); l > 0 && messages[l-1].Role == "assistant" && len(messages[l-1].ToolCalls) > 0 {
+				toolCall.Index = len(messages[l-1].ToolCalls)
+				messages[l-1].ToolCalls = append(messages[l-1].ToolCalls, toolCall)
+				continue
+			}
+			messages = append(messages, schema.Message{Role: "assistant", ToolCalls: []schema.ToolCall{toolCall}})
+		case "function_call_output":
+			messages = append(messages, schema.Message{Role: "tool", Name: callNames[item.CallID], Content: item.Output})
+		default:
+			return nil, fmt.Errorf("unsupported input item type: %s", item.Type)
+		}
+	}
+
+	return messages, nil
+}
+
+// responsesContentToChat translates message content parts to the chat completion format
+func responsesContentToChat(content interface{}) (interface{}, error) {
+	parts, ok := content.([]interface{})
+	if !ok {
+		return content, nil
+	}
+
+	dat, _ := json.Marshal(parts)
+	contentParts := []schema.ResponsesContentPart{}
+	if err := json.Unmarshal(dat, &contentParts); err != nil {
+		return nil, fmt.Errorf("invalid content: %w", err)
+	}
+
+	result := []interface{}{}
+	for _, p := range contentParts {
+		switch p.Type {
+		case "input_text", "output_text":
+			result = append(result, map[string]interface{}{"type": "text", "text": p.Text})
+		case "input_image":
+			result = append(result, map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": p.ImageURL}})
+		default:
+			return nil, fmt.Errorf("unsupported content type: %s", p.Type)
+		}
+	}
+	return result, nil
+}
+
+func messageOutputItem(text, status string) schema.ResponsesOutputItem {
+	item := schema.ResponsesOutputItem{
+		Type:   "message",
+		ID:     "msg_" + uuid.New().String(),
+		Status: status,
+		Role:   "assistant",
+	}
+	if status == "completed" {
+		item.Content = []schema.ResponsesContentPart{{Type: "output_text", Text: text, Annotations: []interface{}{}}}
+	}
+	return item
+}
+
+// functionCallOutputItem returns the output item of a tool call, keeping the id of the call given by the chat
+// completion so that the function_call_output items sent back refer to it. A new id is used if it has none
+func functionCallOutputItem(callID, name, arguments, status string) schema.ResponsesOutputItem {
+	if callID == "" {
+		callID = newToolCallID()
+	}
+	return schema.ResponsesOutputItem{
+		Type:      "function_call",
+		ID:        "fc_" + uuid.New().String(),
+		Status:    status,
+		CallID:    callID,
+		Name:      name,
+		Arguments: arguments,
+	}
+}
+
+func responsesUsage(u schema.OpenAIUsage) *schema.ResponsesUsage {
+	return &schema.ResponsesUsage{
+		InputTokens:  u.PromptTokens,
+		OutputTokens: u.CompletionTokens,
+		TotalTokens:  u.TotalTokens,
+	}
+}
+
+// completeResponse fills the response output from a (non streamed) chat completion response
+func completeResponse(resp *schema.ResponsesResponse, chatResponse schema.OpenAIResponse) {
+	resp.Status = "completed"
+	resp.Usage = responsesUsage(chatResponse.Usage)
+	resp.Metadata = chatResponse.Metadata
+	resp.Output = []schema.ResponsesOutputItem{}
+
+	for _, choice := range chatResponse.Choices {
+		if choice.Message == nil {
+			continue
+		}
+		if text, ok := choice.Message.Content.(string); ok && text != "" {
+			resp.Output = append(resp.Output, messageOutputItem(text, "completed"))
+		}
+		for _, tc := range choice.Message.ToolCalls {
+			resp.Output = append(resp.Output, functionCallOutputItem(tc.ID, tc.FunctionCall.Name, tc.FunctionCall.Arguments, "completed"))
+		}
+		if fc, ok := choice.Message.FunctionCall.(map[string]interface{}); ok {
+			name, _ := fc["name"].(string)
+			args, _ := fc["arguments"].(string)
+			resp.Output = append(resp.Output, functionCallOutputItem("", name, args, "completed"))
+		}
+	}
+}
+
+type responsesStreamWriter struct {
+	w        *bufio.Writer
+	sequence int
+}
+
+func (s *responsesStreamWriter) send(ev schema.ResponsesStreamEvent) {
+	ev.SequenceNumber = s.sequence
+	s.sequence++
+	dat, _ := json.Marshal(ev)
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", ev.Type, dat)
+	s.w.Flush()
+}
+
+// streamResponses reads the chat completion chunks from the stream and writes them as Responses API events
+func streamResponses(stream io.Reader, w *bufio.Writer, resp *schema.ResponsesResponse) {
+	out := &responsesStreamWriter{w: w}
+	zero := 0
+
+	resp.Status = "in_progress"
+	resp.Output = []schema.ResponsesOutputItem{}
+	created := *resp
+	out.send(schema.ResponsesStreamEvent{Type: "response.created", Response: &created})
+
+	var message *schema.ResponsesOutputItem
+	text := ""
+	type toolCall struct{ id, name, arguments string }
+	toolCalls := []*toolCall{}
+	usage := schema.OpenAIUsage{}
+
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if !bytes.HasPrefix(line, []byte("data: ")) {
+			continue
+		}
+		data := bytes.TrimPrefix(line, []byte("data: "))
+		if string(data) == "[DONE]" {
+			break
+		}
+
+		chunk := schema.OpenAIResponse{}
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			log.Debug().Err(err).Msg("failed decoding chat chunk")
+			continue
+		}
+		usage = chunk.Usage
+		if chunk.Metadata != nil {
+			resp.Metadata = chunk.Metadata
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta == nil {
+			continue
+		}
+		delta := chunk.Choices[0].Delta
+
+		if content, ok := delta.Content.(string); ok && content != "" && len(delta.ToolCalls) == 0 {
+			if message == nil {
+				item := messageOutputItem("", "in_progress")
+				message = &item
+				out.send(schema.ResponsesStreamEvent{Type: "response.output_item.added", OutputIndex: &zero, Item: message})
+				out.send(schema.ResponsesStreamEvent{Type: "response.content_part.added", OutputIndex: &zero, ContentIndex: &zero, ItemID: message.ID,
+					Part: &schema.ResponsesContentPart{Type: "output_text", Annotations: []interface{}{}}})
+			}
+			text += content
+			out.send(schema.ResponsesStreamEvent{Type: "response.output_text.delta", OutputIndex: &zero, ContentIndex: &zero, ItemID: message.ID, Delta: content})
+		}
+
+		for _, tc := range delta.ToolCalls {
+			for len(toolCalls) <= tc.Index {
+				toolCalls = append(toolCalls, &toolCall{})
+			}
+			if tc.ID != "" {
+				toolCalls[tc.Index].id = tc.ID
+			}
+			if tc.FunctionCall.Name != "" {
+				toolCalls[tc.Index].name = tc.FunctionCall.Name
+			}
+			toolCalls[tc.Index].arguments += tc.FunctionCall.Arguments
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Error().Err(err).Msg("failed reading chat stream")
+	}
+
+	resp.Status = "completed"
+	resp.Usage = responsesUsage(usage)
+
+	if message != nil {
+		part := schema.ResponsesContentPart{Type: "output_text", Text: text, Annotations: []interface{}{}}
+		out.send(schema.ResponsesStreamEvent{Type: "response.output_text.done", OutputIndex: &zero, ContentIndex: &zero, ItemID: message.ID, Text: text})
+		out.send(schema.ResponsesStreamEvent{Type: "response.content_part.done", OutputIndex: &zero, ContentIndex: &zero, ItemID: message.ID, Part: &part})
+		message.Status = "completed"
+		message.Content = []schema.ResponsesContentPart{part}
+		out.send(schema.ResponsesStreamEvent{Type: "response.output_item.done", OutputIndex: &zero, Item: message})
+		resp.Output = append(resp.Output, *message)
+	}
+
+	for _, tc := range toolCalls {
+		index := len(resp.Output)
+		item := functionCallOutputItem(tc.id, tc.name, "", "in_progress")
+		out.send(schema.ResponsesStreamEvent{Type: "response.output_item.added", OutputIndex: &index, Item: &item})
+		out.send(schema.ResponsesStreamEvent{Type: "response.function_call_arguments.delta", OutputIndex: &index, ItemID: item.ID, Delta: tc.arguments})
+		out.send(schema.ResponsesStreamEvent{Type: "response.function_call_arguments.done", OutputIndex: &index, ItemID: item.ID, Arguments: tc.arguments})
+		item.Status = "completed"
+		item.Arguments = tc.arguments
+		out.send(schema.ResponsesStreamEvent{Type: "response.output_item.done", OutputIndex: &index, Item: &item})
+		resp.Output = append(resp.Output, item)
+	}
+
+	out.send(schema.ResponsesStreamEvent{Type: "response.completed", Response: resp})
+}
@@ -0,0 +1,149 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponsesToChatRequest(t *testing.T) {
+	input := &schema.ResponsesRequest{}
+	err := json.Unmarshal([]byte(`{
+		"model": "test",
+		"instructions": "be brief",
+		"max_output_tokens": 10,
+		"input": [
+			{"role": "user", "content": [{"type": "input_text", "text": "what's the weather?"}]},
+			{"type": "function_call", "call_id": "call_1", "name": "get_weather", "arguments": "{}"},
+			{"type": "function_call_output", "call_id": "call_1", "output": "sunny"}
+		],
+		"tools": [{"type": "function", "name": "get_weather", "parameters": {"type": "object"}}],
+		"tool_choice": {"type": "function", "name": "get_weather"}
+	}`), input)
+	assert.NoError(t, err)
+
+	req, err := responsesToChatRequest(input)
+	assert.NoError(t, err)
+	assert.Equal(t, "test", req.Model)
+	assert.Equal(t, 10, *req.Maxtokens)
+	assert.Len(t, req.Messages, 4)
+	assert.Equal(t, "system", req.Messages[0].Role)
+	assert.Equal(t, "be brief", req.Messages[0].Content)
+	assert.Equal(t, []interface{}{map[string]interface{}{"type": "text", "text": "what's the weather?"}}, req.Messages[1].Content)
+	assert.Equal(t, "assistant", req.Messages[2].Role)
+	assert.Equal(t, "get_weather", req.Messages[2].ToolCalls[0].FunctionCall.Name)
+	assert.Equal(t, "tool", req.Messages[3].Role)
+	assert.Equal(t, "get_weather", req.Messages[3].Name)
+	assert.Equal(t, "sunny", req.Messages[3].Content)
+	assert.Len(t, req.Tools, 1)
+	assert.Equal(t, "get_weather", req.Tools[0].Function.Name)
+	assert.NotNil(t, req.ToolsChoice)
+
+	_, err = responsesToChatRequest(&schema.ResponsesRequest{Model: "test"})
+	assert.Error(t, err)
+}
+
+func TestCompleteResponse(t *testing.T) {
+	text := "hello"
+	chatResponse := schema.OpenAIResponse{
+		Choices: []schema.Choice{{Message: &schema.Message{
+			Content:   text,
+			ToolCalls: []schema.ToolCall{{ID: "call_1", FunctionCall: schema.FunctionCall{Name: "f", Arguments: `{"a":1}`}}},
+		}}},
+		Usage: schema.OpenAIUsage{PromptTokens: 1, CompletionTokens: 2, TotalTokens: 3},
+	}
+
+	resp := &schema.ResponsesResponse{}
+	completeResponse(resp, chatResponse)
+	assert.Equal(t, "completed", resp.Status)
+	assert.Len(t, resp.Output, 2)
+	assert.Equal(t, "message", resp.Output[0].Type)
+	assert.Equal(t, "hello", resp.Output[0].Content[0].Text)
+	assert.Equal(t, "function_call", resp.Output[1].Type)
+	assert.Equal(t, `{"a":1}`, resp.Output[1].Arguments)
+	assert.Equal(t, "call_1", resp.Output[1].CallID)
+	assert.Equal(t, 3, resp.Usage.TotalTokens)
+}
+
+func TestStreamResponses(t *testing.T) {
+	chunks := []string{
+		`{"choices":[{"delta":{"role":"assistant","content":""}}]}`,
+		`{"choices":[{"delta":{"content":"Hel"}}]}`,
+		`{"choices":[{"delta":{"content":"lo"}}],"usage":{"prompt_tokens":1,"completion_tokens":2,"total_tokens":3}}`,
+	}
+	chat := ""
+	for _, c := range chunks {
+		chat += "data: " + c + "\n\n"
+	}
+	chat += "data: [DONE]\n\n"
+
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	streamResponses(strings.NewReader(chat), w, &schema.ResponsesResponse{ID: "resp_1"})
+
+	events := []string{}
+	var completed schema.ResponsesStreamEvent
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "event: ") {
+			events = append(events, strings.TrimPrefix(line, "event: "))
+		}
+		if strings.HasPrefix(line, "data: ") {
+			assert.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &completed))
+		}
+	}
+
+	assert.Equal(t, []string{
+		"response.created",
+		"response.output_item.added",
+		"response.content_part.added",
+		"response.output_text.delta",
+		"response.output_text.delta",
+		"response.output_text.done",
+		"response.content_part.done",
+		"response.output_item.done",
+		"response.completed",
+	}, events)
+	assert.Equal(t, "completed", completed.Response.Status)
+	assert.Equal(t, "Hello", completed.Response.Output[0].Content[0].Text)
+	assert.Equal(t, 3, completed.Response.Usage.TotalTokens)
+}
+
+func TestStreamResponsesToolCalls(t *testing.T) {
+	chunks := []string{
+		`{"choices":[{"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"f"}}]}}]}`,
+		`{"choices":[{"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"arguments":"{\"a\":1}"}}]}}]}`,
+	}
+	chat := ""
+	for _, c := range chunks {
+		chat += "data: " + c + "\n\n"
+	}
+	chat += "data: [DONE]\n\n"
+
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	resp := &schema.ResponsesResponse{ID: "resp_1"}
+	streamResponses(strings.NewReader(chat), w, resp)
+
+	added := 0
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		ev := schema.ResponsesStreamEvent{}
+		assert.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev))
+		if ev.Type == "response.output_item.added" {
+			added++
+			assert.Equal(t, "call_1", ev.Item.CallID)
+		}
+	}
+	assert.Equal(t, 1, added)
+	assert.Len(t, resp.Output, 1)
+	assert.Equal(t, "call_1", resp.Output[0].CallID)
+	assert.Equal(t, "f", resp.Output[0].Name)
+	assert.Equal(t, `{"a":1}`, resp.Output[0].Arguments)
+}
@@ -0,0 +1,115 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+// routedKey marks the requests run by the model chosen by a router model
+const routedKey = "routed"
+
+// Reasons of the requests routed to the default model of a router
+const (
+	routerFallbackUnknownCategory = "unknown_category"
+	routerFallbackLowConfidence   = "low_confidence"
+	routerFallbackClassifierError = "classifier_error"
+)
+
+// chooseRoute returns the model of the category of the prompt, or the default model and the reason of the fallback
+func chooseRoute(r config.Router, category backend.PromptCategory) (string, string) {
+	model, ok := r.Routes[category.Category]
+	switch {
+	case !ok:
+		return r.Default, routerFallbackUnknownCategory
+	case category.Confidence < r.MinConfidence:
+		return r.Default, routerFallbackLowConfidence
+	}
+	return model, ""
+}
+
+// runRouter runs a chat request on the model of the category of its prompt, according to the classifier
+// of the router model, or on its default model when the category is unknown or uncertain
+func runRouter(c *fiber.Ctx, chat fiber.Handler, cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig, cfg *config.BackendConfig, input *schema.OpenAIRequest) error {
+	if c.Locals(routedKey) != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "a router model cannot route the requests to another router")
+	}
+	if !fiberContext.ModelAllowed(c, cfg.Router.ClassifierModel) {
+		return fiber.NewError(fiber.StatusForbidden, fmt.Sprintf("the API key is not allowed to use the classifier model %q of the router", cfg.Router.ClassifierModel))
+	}
+
+	var category backend.PromptCategory
+	chosen, fallback := cfg.Router.Default, routerFallbackUnknownCategory
+	classifierConfig, err := cl.LoadBackendConfigFileByName(cfg.Router.ClassifierModel, appConfig.ModelPath,
+		config.LoadOptionDebug(appConfig.Debug),
+		config.LoadOptionThreads(appConfig.Threads),
+		config.LoadOptionContextSize(appConfig.ContextSize),
+		config.LoadOptionF16(appConfig.F16),
+	)
+	if err == nil {
+		category, err = backend.ClassifyPrompt(input.Context, lastUserContent(input.Messages), cfg.Router, *classifierConfig, ml, appConfig)
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("router", cfg.Name).Msg("the prompt could not be classified, the request is routed to the default model")
+		fallback = routerFallbackClassifierError
+	} else {
+		chosen, fallback = chooseRoute(cfg.Router, category)
+	}
+	if !fiberContext.ModelAllowed(c, chosen) {
+		return fiber.NewError(fiber.StatusForbidden, fmt.Sprintf("the API key is not allowed to use the model %q chosen by the router", chosen))
+	}
+
+	body := map[string]interface{}{}
+	if err := json.Unmarshal(c.Body(), &body); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+	body["model"] = chosen
+	body["stream"] = false
+	resp, err := subResponse(c, chat, body, func(sub *fiber.Ctx) {
+		// the chosen model is set in the body only
+		sub.Request().URI().SetQueryString("")
+		sub.Locals(routedKey, true)
+	})
+	if err != nil {
+		return err
+	}
+	if len(resp.Choices) == 0 || resp.Choices[0].Message == nil {
+		return errors.New("the model chosen by the router returned no output")
+	}
+
+	route := map[string]interface{}{"model": chosen, "category": category.Category, "confidence": category.Confidence}
+	if fallback != "" {
+		route["fallback"] = fallback
+	}
+	metadata := maps.Clone(resp.Metadata)
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadata["router"] = route
+
+	// the usage includes the classification of the prompt
+	usage := resp.Usage
+	usage.PromptTokens += category.Usage.Prompt
+	usage.CompletionTokens += category.Usage.Completion
+	usage.TotalTokens += category.Usage.Prompt + category.Usage.Completion
+
+	return sendCombinedResponse(c, schema.OpenAIResponse{
+		ID:       uuid.New().String(),
+		Created:  int(time.Now().Unix()),
+		Model:    input.Model,
+		Object:   "chat.completion",
+		Usage:    usage,
+		Metadata: metadata,
+	}, resp.Choices[0].Message, input.Stream)
+}
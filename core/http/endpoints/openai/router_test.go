@@ -0,0 +1,46 @@
+package openai
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChooseRoute(t *testing.T) {
+	r := config.Router{
+		Routes:        map[string]string{"code": "qwen-coder", "math": "deepseek-math"},
+		Default:       "llama-3-8b",
+		MinConfidence: 0.6,
+	}
+
+	model, fallback := chooseRoute(r, backend.PromptCategory{Category: "code", Confidence: 0.9})
+	assert.Equal(t, "qwen-coder", model)
+	assert.Empty(t, fallback)
+
+	model, fallback = chooseRoute(r, backend.PromptCategory{Category: "math", Confidence: 0.4})
+	assert.Equal(t, "llama-3-8b", model)
+	assert.Equal(t, routerFallbackLowConfidence, fallback)
+
+	model, fallback = chooseRoute(r, backend.PromptCategory{Confidence: 1})
+	assert.Equal(t, "llama-3-8b", model)
+	assert.Equal(t, routerFallbackUnknownCategory, fallback)
+}
+
+func TestRunRouterAllowedModels(t *testing.T) {
+	cfg := &config.BackendConfig{Name: "router", Router: config.Router{ClassifierModel: "classifier", Default: "llm"}}
+	app := fiber.New()
+	app.Post("/", func(c *fiber.Ctx) error {
+		// the API key is restricted to the router model, not to its classifier
+		fiberContext.SetAllowedModels(c, []string{"router", "llm"})
+		return runRouter(c, nil, nil, nil, nil, cfg, &schema.OpenAIRequest{})
+	})
+	resp, err := app.Test(httptest.NewRequest("POST", "/", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+}
@@ -0,0 +1,71 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/rs/zerolog/log"
+)
+
+// Formats of the chunks of the streamed completions
+const (
+	// StreamFormatOpenAI sends the chunks as OpenAI JSON objects
+	StreamFormatOpenAI = "openai"
+	// StreamFormatRaw sends the text of the chunks only, for the lightweight clients
+	StreamFormatRaw = "raw"
+)
+
+// streamFormat returns the format of the stream requested with the stream_format query parameter, OpenAI by default
+func streamFormat(c *fiber.Ctx) (string, error) {
+	switch format := c.Query("stream_format", StreamFormatOpenAI); format {
+	case StreamFormatOpenAI, StreamFormatRaw:
+		return format, nil
+	default:
+		return "", fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid stream_format %q: supported formats are %s and %s", format, StreamFormatOpenAI, StreamFormatRaw))
+	}
+}
+
+// writeStreamChunk sends the chunk to the client in the format of the stream. In the raw format, the chunks
+// without text (e.g. the tool calls) are not sent
+func writeStreamChunk(w *bufio.Writer, format string, ev schema.OpenAIResponse) error {
+	var err error
+	if format == StreamFormatRaw {
+		if text := chunkText(ev); text != "" {
+			// each line of the text is a data line of the event, joined back with newlines by the clients
+			_, err = fmt.Fprintf(w, "data: %s\n\n", strings.ReplaceAll(text, "\n", "\ndata: "))
+		}
+	} else {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.Encode(ev)
+		log.Debug().Msgf("Sending chunk: %s", buf.String())
+		_, err = fmt.Fprintf(w, "data: %v\n", buf.String())
+	}
+	w.Flush()
+	return err
+}
+
+// chunkText returns the generated text of a chat or completion chunk
+func chunkText(ev schema.OpenAIResponse) string {
+	if len(ev.Choices) == 0 {
+		return ""
+	}
+	choice := ev.Choices[0]
+	if choice.Delta == nil {
+		return choice.Text
+	}
+	switch content := choice.Delta.Content.(type) {
+	case string:
+		return content
+	case *string:
+		if content != nil {
+			return *content
+		}
+	}
+	return ""
+}
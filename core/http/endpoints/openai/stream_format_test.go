@@ -0,0 +1,82 @@
+package openai
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestStreamFormats(t *testing.T) {
+	text := func(s string) *string { return &s }
+	chunks := []schema.OpenAIResponse{
+		{Object: "chat.completion.chunk", Choices: []schema.Choice{{Delta: &schema.Message{Role: "assistant", Content: text("")}}}},
+		{Object: "chat.completion.chunk", Choices: []schema.Choice{{Delta: &schema.Message{Content: text("Hello")}}}},
+		{Object: "chat.completion.chunk", Choices: []schema.Choice{{Delta: &schema.Message{Content: text(",\nworld")}}}},
+		{Object: "text_completion", Choices: []schema.Choice{{Text: "!"}}},
+	}
+
+	app := fiber.New()
+	app.Get("/stream", func(c *fiber.Ctx) error {
+		format, err := streamFormat(c)
+		if err != nil {
+			return err
+		}
+		responses := make(chan schema.OpenAIResponse, len(chunks))
+		for _, ev := range chunks {
+			responses <- ev
+		}
+		close(responses)
+		c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+			forwardStream(w, responses, 0, nil, func(ev schema.OpenAIResponse) {
+				require.NoError(t, writeStreamChunk(w, format, ev))
+			})
+			w.WriteString("data: [DONE]\n\n")
+			w.Flush()
+		}))
+		return nil
+	})
+
+	// events returns the data of the events of the stream, the lines of each event joined with newlines
+	events := func(query string) []string {
+		resp, err := app.Test(httptest.NewRequest("GET", "/stream"+query, nil))
+		require.NoError(t, err)
+		require.Equal(t, fiber.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		events := []string{}
+		for _, event := range strings.Split(strings.TrimSuffix(string(body), "\n\n"), "\n\n") {
+			lines := []string{}
+			for _, line := range strings.Split(event, "\n") {
+				lines = append(lines, strings.TrimPrefix(line, "data: "))
+			}
+			events = append(events, strings.Join(lines, "\n"))
+		}
+		return events
+	}
+
+	openai := events("")
+	assert.Equal(t, events("?stream_format=openai"), openai)
+	require.Len(t, openai, len(chunks)+1)
+	for i, data := range openai[:len(chunks)] {
+		ev := schema.OpenAIResponse{}
+		require.NoError(t, json.Unmarshal([]byte(data), &ev))
+		assert.Equal(t, chunks[i].Object, ev.Object)
+	}
+	assert.Equal(t, "[DONE]", openai[len(chunks)])
+
+	// the chunks without text are not sent
+	assert.Equal(t, []string{"Hello", ",\nworld", "!", "[DONE]"}, events("?stream_format=raw"))
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/stream?stream_format=ndjson", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+}
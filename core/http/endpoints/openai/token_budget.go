@@ -0,0 +1,31 @@
+package openai
+
+import (
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+// addSegmentsUsage adds the tokens spent in each segment of a prediction with a token budget to the usage
+func addSegmentsUsage(usage *backend.TokenUsage, prediction backend.TokenUsage) {
+	if prediction.Segments == nil {
+		return
+	}
+	if usage.Segments == nil {
+		usage.Segments = &schema.CompletionTokensDetails{}
+	}
+	usage.Segments.ReasoningTokens += prediction.Segments.ReasoningTokens
+	usage.Segments.ContentTokens += prediction.Segments.ContentTokens
+	usage.Segments.ToolArgumentsTokens += prediction.Segments.ToolArgumentsTokens
+	if prediction.BudgetExhausted != "" {
+		usage.BudgetExhausted = prediction.BudgetExhausted
+	}
+}
+
+// segmentsUsage returns a copy of the tokens spent in each segment, as they are updated while the output is streamed
+func segmentsUsage(usage backend.TokenUsage) *schema.CompletionTokensDetails {
+	if usage.Segments == nil {
+		return nil
+	}
+	details := *usage.Segments
+	return &details
+}
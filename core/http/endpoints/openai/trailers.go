@@ -0,0 +1,69 @@
+package openai
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// StreamTrailersBoth sends the metadata of the streamed responses both in the trailers and in the final chunk
+	StreamTrailersBoth = "both"
+	// StreamTrailersOnly sends the metadata of the streamed responses only in the trailers, to the clients accepting them
+	StreamTrailersOnly = "only"
+)
+
+var streamTrailers = []string{
+	"LocalAI-Finish-Reason",
+	"LocalAI-Prompt-Tokens",
+	"LocalAI-Completion-Tokens",
+	"LocalAI-Total-Tokens",
+	"LocalAI-Timing-Prompt-Processing",
+	"LocalAI-Timing-Token-Generation",
+	"LocalAI-Timing-First-Token",
+	"LocalAI-Duration-Ms",
+}
+
+// announceStreamTrailers declares the trailers of a streamed response, which must be done before the stream
+// starts. It returns whether the final chunk, with the same metadata, has to be sent as well
+func announceStreamTrailers(c *fiber.Ctx, mode string) bool {
+	if mode != StreamTrailersBoth && mode != StreamTrailersOnly {
+		return true
+	}
+	c.Response().Header.SetTrailer(strings.Join(streamTrailers, ", "))
+	return mode == StreamTrailersBoth || !acceptsTrailers(c)
+}
+
+// acceptsTrailers returns whether the client declared it reads the trailers, with the TE header
+func acceptsTrailers(c *fiber.Ctx) bool {
+	for _, v := range strings.Split(c.Get(fiber.HeaderTE), ",") {
+		coding, _, _ := strings.Cut(v, ";")
+		if strings.EqualFold(strings.TrimSpace(coding), "trailers") {
+			return true
+		}
+	}
+	return false
+}
+
+// setStreamTrailers sets the values of the trailers, sent once the stream is over
+func setStreamTrailers(h *fasthttp.ResponseHeader, mode string, finishReason string, usage schema.OpenAIUsage, started time.Time) {
+	if mode != StreamTrailersBoth && mode != StreamTrailersOnly {
+		return
+	}
+	h.Set("LocalAI-Finish-Reason", finishReason)
+	h.Set("LocalAI-Prompt-Tokens", strconv.Itoa(usage.PromptTokens))
+	h.Set("LocalAI-Completion-Tokens", strconv.Itoa(usage.CompletionTokens))
+	h.Set("LocalAI-Total-Tokens", strconv.Itoa(usage.TotalTokens))
+	if usage.TimingPromptProcessing != 0 || usage.TimingTokenGeneration != 0 {
+		h.Set("LocalAI-Timing-Prompt-Processing", strconv.FormatFloat(usage.TimingPromptProcessing, 'f', -1, 64))
+		h.Set("LocalAI-Timing-Token-Generation", strconv.FormatFloat(usage.TimingTokenGeneration, 'f', -1, 64))
+	}
+	if usage.TimingFirstToken != 0 {
+		h.Set("LocalAI-Timing-First-Token", strconv.FormatFloat(usage.TimingFirstToken, 'f', -1, 64))
+	}
+	h.Set("LocalAI-Duration-Ms", strconv.FormatInt(time.Since(started).Milliseconds(), 10))
+}
@@ -0,0 +1,66 @@
+package openai
+
+import (
+	"bufio"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestStreamTrailers(t *testing.T) {
+	app := fiber.New()
+	app.Get("/stream/:mode", func(c *fiber.Ctx) error {
+		mode := c.Params("mode")
+		finalChunk := announceStreamTrailers(c, mode)
+		header := &c.Response().Header
+		c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+			w.WriteString("data: content\n\n")
+			setStreamTrailers(header, mode, "stop", schema.OpenAIUsage{PromptTokens: 3, CompletionTokens: 5, TotalTokens: 8}, time.Now())
+			if finalChunk {
+				w.WriteString("data: final\n\n")
+			}
+			w.WriteString("data: [DONE]\n\n")
+		}))
+		return nil
+	})
+
+	stream := func(mode string, te string) (string, map[string]string) {
+		req := httptest.NewRequest("GET", "/stream/"+mode, nil)
+		if te != "" {
+			req.Header.Set("TE", te)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		trailers := map[string]string{}
+		for k := range resp.Trailer {
+			trailers[k] = resp.Trailer.Get(k)
+		}
+		return string(body), trailers
+	}
+
+	body, trailers := stream("off", "trailers")
+	assert.Equal(t, "data: content\n\ndata: final\n\ndata: [DONE]\n\n", body)
+	assert.Empty(t, trailers)
+
+	body, trailers = stream(StreamTrailersBoth, "")
+	assert.Equal(t, "data: content\n\ndata: final\n\ndata: [DONE]\n\n", body)
+	assert.Equal(t, "stop", trailers["Localai-Finish-Reason"])
+	assert.Equal(t, "8", trailers["Localai-Total-Tokens"])
+	assert.Contains(t, trailers, "Localai-Duration-Ms")
+
+	// the final chunk is only omitted for the clients reading the trailers
+	body, trailers = stream(StreamTrailersOnly, "gzip, trailers")
+	assert.Equal(t, "data: content\n\ndata: [DONE]\n\n", body)
+	assert.Equal(t, "5", trailers["Localai-Completion-Tokens"])
+	body, _ = stream(StreamTrailersOnly, "")
+	assert.Contains(t, body, "data: final")
+}
@@ -7,10 +7,13 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/mudler/LocalAI/core/backend"
 	"github.com/mudler/LocalAI/core/config"
 	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/rs/zerolog/log"
@@ -21,6 +24,8 @@ import (
 // @accept multipart/form-data
 // @Param model formData string true "model"
 // @Param file formData file true "file"
+// @Param timestamp_granularities[] formData []string false "timestamp granularities: segment (default), word, token"
+// @Param suppress_low_confidence formData string false "drop the low confidence segments: true, or the average log probability threshold"
 // @Success 200 {object} map[string]string	 "Response"
 // @Router /v1/audio/transcriptions [post]
 func TranscriptEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
@@ -59,19 +64,108 @@ func TranscriptEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, a
 		}
 
 		if _, err := io.Copy(dstFile, f); err != nil {
+			dstFile.Close()
 			log.Debug().Msgf("Audio file copying error %+v - %+v - err %+v", file.Filename, dst, err)
 			return err
 		}
+		dstFile.Close()
 
 		log.Debug().Msgf("Audio file copied to: %+v", dst)
 
-		tr, err := backend.ModelTranscription(dst, input.Language, input.Translate, ml, *config, appConfig)
+		dst, format, converted, err := prepareAudio(dst, config.AudioConversion)
 		if err != nil {
 			return err
 		}
 
+		confidence, err := suppressLowConfidence(c.FormValue("suppress_low_confidence"), config.TranscriptionConfidence)
+		if err != nil {
+			return err
+		}
+
+		var granularities []string
+		if form, err := c.MultipartForm(); err == nil {
+			granularities = append(form.Value["timestamp_granularities[]"], form.Value["timestamp_granularities"]...)
+		}
+
+		tr, err := backend.ModelTranscription(dst, input.Language, input.Translate, granularities, ml, *config, appConfig)
+		if err != nil {
+			return err
+		}
+
+		if format != "" {
+			tr.Metadata = map[string]interface{}{"audio_format": format, "audio_converted": converted}
+		}
+		if confidence != nil {
+			if tr.Metadata == nil {
+				tr.Metadata = map[string]interface{}{}
+			}
+			tr.Metadata["suppressed_segments"] = backend.SuppressLowConfidence(tr, *confidence)
+		}
+
 		log.Debug().Msgf("Trascribed: %+v", tr)
 		// TODO: handle different outputs here
 		return c.Status(http.StatusOK).JSON(tr)
 	}
 }
+
+// suppressLowConfidence returns the thresholds of the low confidence segments dropped by the request, nil if it
+// does not drop them: suppress_low_confidence is true to use the thresholds of the model, or the threshold of the
+// average log probability
+func suppressLowConfidence(value string, confidence config.TranscriptionConfidence) (*config.TranscriptionConfidence, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if suppress, err := strconv.ParseBool(value); err == nil {
+		if !suppress {
+			return nil, nil
+		}
+		return &confidence, nil
+	}
+	threshold, err := strconv.ParseFloat(value, 64)
+	if err != nil || threshold > 0 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "suppress_low_confidence must be true, false or a log probability threshold (not positive)")
+	}
+	confidence.LogprobThreshold = &threshold
+	return &confidence, nil
+}
+
+// prepareAudio detects the format of the uploaded audio file from its content, gives the file the matching
+// extension, as the backends rely on it, and converts it to wav if the model requires it. It returns the
+// path of the file to transcribe and its original format (empty if unknown)
+func prepareAudio(src string, conversion config.AudioConversion) (string, string, bool, error) {
+	format, err := utils.DetectAudioFormat(src)
+	if err != nil {
+		return "", "", false, err
+	}
+	if format == "" {
+		log.Debug().Msgf("Unknown format of the audio file %s, sending it as is", src)
+		return src, "", false, nil
+	}
+
+	dst := strings.TrimSuffix(src, filepath.Ext(src)) + "." + format
+	if dst != src {
+		if err := os.Rename(src, dst); err != nil {
+			return "", "", false, err
+		}
+	}
+
+	var wav *utils.WavInfo
+	if format == utils.AudioFormatWAV {
+		// an unreadable fmt chunk is converted as well
+		wav, _ = utils.ReadWavInfo(dst)
+	}
+	if !conversion.NeedsConversion(format, wav) {
+		return dst, format, false, nil
+	}
+
+	sampleRate := conversion.SampleRate
+	if sampleRate == 0 {
+		sampleRate = 16000
+	}
+	converted := strings.TrimSuffix(dst, filepath.Ext(dst)) + ".converted.wav"
+	log.Debug().Msgf("Converting the %s audio file %s to %dHz wav", format, dst, sampleRate)
+	if err := utils.AudioToWavWithSampleRate(dst, converted, sampleRate); err != nil {
+		return "", "", false, fmt.Errorf("failed converting the %s audio file: %w", format, err)
+	}
+	return converted, format, true, nil
+}
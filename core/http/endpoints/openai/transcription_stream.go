@@ -0,0 +1,391 @@
+package openai
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/go-audio/audio"
+	"github.com/go-audio/wav"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/grpc/proto"
+	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+// streamSampleRate is the sample rate of the audio streamed by the clients (mono, 16 bit little endian PCM)
+const streamSampleRate = 16000
+
+const (
+	// streamVADInterval is the amount of new audio (in seconds) after which the voice activity is detected again
+	streamVADInterval = 0.2
+	// streamPreRoll is the audio (in seconds) kept before the speech starts
+	streamPreRoll = 0.3
+	// streamSpeechPad is the audio (in seconds) kept after the speech ends
+	streamSpeechPad = 0.2
+)
+
+// vadSpeech is a speech segment detected in the buffered audio, in seconds from its start
+type vadSpeech struct {
+	Start, End float64
+}
+
+// vadSegmenter splits a stream of audio into utterances from the speech segments detected by the VAD model
+type vadSegmenter struct {
+	// Silence is the pause (in seconds) ending an utterance
+	Silence float64
+	// MinSpeech is the minimum duration (in seconds) of a speech segment: shorter ones are ignored as noise
+	MinSpeech float64
+	// MaxUtterance is the maximum duration (in seconds) of an utterance: longer ones are split
+	MaxUtterance float64
+	// MinEnergy is the minimum RMS energy of a speech segment: quieter ones are ignored as noise
+	MinEnergy float64
+
+	speaking bool
+}
+
+// vadDecision is what the stream has to do after a voice activity detection
+type vadDecision struct {
+	Started, Stopped bool
+	// SpeechStart is the start (in seconds) of the speech in the buffered audio
+	SpeechStart float64
+	// Final is the end (in seconds) of the buffered audio to transcribe as a final transcript, 0 if none
+	Final float64
+	// Discard is the audio (in seconds) at the start of the buffer that can be dropped
+	Discard float64
+}
+
+// update returns the decision for the buffered audio, given the speech segments detected in it.
+// The end of a segment still ongoing at the end of the audio is 0
+func (s *vadSegmenter) update(segments []vadSpeech, samples []float32) vadDecision {
+	duration := float64(len(samples)) / streamSampleRate
+	d := vadDecision{}
+
+	speech := []vadSpeech{}
+	for _, seg := range segments {
+		if seg.End <= 0 || seg.End > duration {
+			seg.End = duration
+		}
+		if seg.End-seg.Start < s.MinSpeech || rms(samples, seg) < s.MinEnergy {
+			continue
+		}
+		speech = append(speech, seg)
+	}
+
+	if len(speech) == 0 {
+		if s.speaking {
+			// what looked like speech was noise
+			s.speaking = false
+			d.Stopped = true
+		}
+		d.Discard = math.Max(0, duration-streamPreRoll)
+		return d
+	}
+
+	if !s.speaking {
+		s.speaking = true
+		d.Started = true
+	}
+
+	first, last := speech[0].Start, speech[len(speech)-1].End
+	d.SpeechStart = first
+	switch {
+	case duration-last >= s.Silence:
+		s.speaking = false
+		d.Stopped = true
+		d.Final = math.Min(duration, last+streamSpeechPad)
+		d.Discard = d.Final
+	case s.MaxUtterance > 0 && duration-first >= s.MaxUtterance:
+		d.Final = duration
+		d.Discard = duration
+	}
+	return d
+}
+
+// rms returns the root mean square energy of the audio of a segment
+func rms(samples []float32, seg vadSpeech) float64 {
+	start := max(0, int(seg.Start*streamSampleRate))
+	end := min(len(samples), int(seg.End*streamSampleRate))
+	if end <= start {
+		return 0
+	}
+	sum := 0.0
+	for _, s := range samples[start:end] {
+		sum += float64(s) * float64(s)
+	}
+	return math.Sqrt(sum / float64(end-start))
+}
+
+// pcmToFloat converts 16 bit little endian PCM to samples between -1 and 1
+func pcmToFloat(data []byte) []float32 {
+	samples := make([]float32, len(data)/2)
+	for i := range samples {
+		samples[i] = float32(int16(binary.LittleEndian.Uint16(data[i*2:]))) / 32768
+	}
+	return samples
+}
+
+// TranscriptStreamEndpoint transcribes the audio streamed over a websocket, splitting it into utterances
+// with a VAD model. The client sends binary messages of 16kHz mono 16 bit little endian PCM audio,
+// and receives JSON events: speech_started, speech_stopped, transcript.interim and transcript.final.
+// @Summary Transcribes streamed audio, detecting the voice activity.
+// @Param model query string true "transcription model"
+// @Param vad_model query string true "VAD model"
+// @Param language query string false "language"
+// @Param silence_ms query int false "silence ending an utterance, in milliseconds (default 700)"
+// @Param min_speech_ms query int false "minimum duration of speech, in milliseconds (default 250)"
+// @Param max_utterance_ms query int false "maximum duration of an utterance, in milliseconds (default 30000)"
+// @Param interim_ms query int false "interval between interim transcripts, in milliseconds (default 1000, 0 disables them)"
+// @Param sensitivity query number false "VAD sensitivity between 0 and 1 (default 0.5)"
+// @Router /v1/audio/transcriptions/stream [get]
+func TranscriptStreamEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		if !websocket.IsWebSocketUpgrade(c) {
+			return fiber.ErrUpgradeRequired
+		}
+
+		loadConfig := func(name string) (*config.BackendConfig, error) {
+			modelFile, err := fiberContext.ModelFromContext(c, cl, ml, name, false)
+			if err != nil {
+				return nil, err
+			}
+			return cl.LoadBackendConfigFileByName(modelFile, appConfig.ModelPath,
+				config.LoadOptionDebug(appConfig.Debug),
+				config.LoadOptionThreads(appConfig.Threads),
+				config.LoadOptionContextSize(appConfig.ContextSize),
+				config.LoadOptionF16(appConfig.F16),
+			)
+		}
+
+		if c.Query("vad_model") == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "vad_model is required")
+		}
+		transcriptionCfg, err := loadConfig(c.Query("model"))
+		if err != nil {
+			return err
+		}
+		vadCfg, err := loadConfig(c.Query("vad_model"))
+		if err != nil {
+			return err
+		}
+
+		sensitivity := math.Min(1, math.Max(0, c.QueryFloat("sensitivity", 0.5)))
+		segmenter := &vadSegmenter{
+			Silence:      float64(c.QueryInt("silence_ms", 700)) / 1000,
+			MinSpeech:    float64(c.QueryInt("min_speech_ms", 250)) / 1000,
+			MaxUtterance: float64(c.QueryInt("max_utterance_ms", 30000)) / 1000,
+			// the quieter the audio, the more it is likely to be background noise
+			MinEnergy: 0.02 * (1 - sensitivity),
+		}
+		interim := float64(c.QueryInt("interim_ms", 1000)) / 1000
+		language := c.Query("language", transcriptionCfg.Language)
+
+		return websocket.New(func(conn *websocket.Conn) {
+			s := &transcriptionStream{
+				conn:      conn,
+				ml:        ml,
+				appConfig: appConfig,
+				cfg:       transcriptionCfg,
+				vadCfg:    vadCfg,
+				segmenter: segmenter,
+				interim:   interim,
+				language:  language,
+			}
+			if err := s.run(); err != nil {
+				log.Error().Err(err).Msg("transcription stream failed")
+				s.send(schema.TranscriptionStreamEvent{Type: "error", Error: err.Error()})
+			}
+		})(c)
+	}
+}
+
+type transcriptionStream struct {
+	conn      *websocket.Conn
+	ml        *model.ModelLoader
+	appConfig *config.ApplicationConfig
+	cfg       *config.BackendConfig
+	vadCfg    *config.BackendConfig
+	segmenter *vadSegmenter
+	interim   float64
+	language  string
+
+	// buffer is the audio not transcribed yet, starting at offset seconds from the start of the stream
+	buffer []float32
+	offset float64
+	// checked and transcribed are the durations (in seconds) of the buffer at the last VAD and interim transcript
+	checked, transcribed float64
+}
+
+func (s *transcriptionStream) run() error {
+	for {
+		messageType, data, err := s.conn.ReadMessage()
+		if err != nil {
+			// the client closed the stream
+			return nil
+		}
+
+		switch messageType {
+		case websocket.BinaryMessage:
+			s.buffer = append(s.buffer, pcmToFloat(data)...)
+		case websocket.TextMessage:
+			// {"type": "flush"} ends the current utterance
+			msg := struct {
+				Type string `json:"type"`
+			}{}
+			if json.Unmarshal(data, &msg) == nil && msg.Type == "flush" {
+				if err := s.flush(); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		duration := s.duration()
+		if duration-s.checked < streamVADInterval {
+			continue
+		}
+		s.checked = duration
+
+		if err := s.detect(); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *transcriptionStream) duration() float64 {
+	return float64(len(s.buffer)) / streamSampleRate
+}
+
+// detect runs the VAD model on the buffered audio, sending the events of the voice activity and the transcripts
+func (s *transcriptionStream) detect() error {
+	vadModel, err := s.ml.Load(backend.ModelOptions(*s.vadCfg, s.appConfig, model.WithBackendString(s.vadCfg.Backend), model.WithModel(s.vadCfg.Model))...)
+	if err != nil {
+		return err
+	}
+	resp, err := vadModel.VAD(context.Background(), &proto.VADRequest{Audio: s.buffer})
+	if err != nil {
+		return err
+	}
+	segments := []vadSpeech{}
+	for _, seg := range resp.Segments {
+		segments = append(segments, vadSpeech{Start: float64(seg.Start), End: float64(seg.End)})
+	}
+
+	d := s.segmenter.update(segments, s.buffer)
+	if d.Started {
+		s.send(schema.TranscriptionStreamEvent{Type: "speech_started", Start: s.offset + d.SpeechStart})
+	}
+	if d.Stopped {
+		s.send(schema.TranscriptionStreamEvent{Type: "speech_stopped", End: s.offset + math.Max(d.Final, d.Discard)})
+	}
+
+	switch {
+	case d.Final > 0:
+		if err := s.transcribe("transcript.final", d.Final); err != nil {
+			return err
+		}
+	case s.segmenter.speaking && s.interim > 0 && s.duration()-s.transcribed >= s.interim:
+		if err := s.transcribe("transcript.interim", s.duration()); err != nil {
+			return err
+		}
+	}
+
+	s.discard(d.Discard)
+	return nil
+}
+
+// flush sends the final transcript of the buffered speech
+func (s *transcriptionStream) flush() error {
+	if s.segmenter.speaking {
+		s.segmenter.speaking = false
+		s.send(schema.TranscriptionStreamEvent{Type: "speech_stopped", End: s.offset + s.duration()})
+		if err := s.transcribe("transcript.final", s.duration()); err != nil {
+			return err
+		}
+	}
+	s.discard(s.duration())
+	return nil
+}
+
+// discard drops the audio at the start of the buffer
+func (s *transcriptionStream) discard(seconds float64) {
+	n := min(len(s.buffer), int(seconds*streamSampleRate))
+	if n <= 0 {
+		return
+	}
+	s.buffer = s.buffer[n:]
+	s.offset += float64(n) / streamSampleRate
+	s.checked = math.Max(0, s.checked-float64(n)/streamSampleRate)
+	s.transcribed = 0
+}
+
+// transcribe sends the transcript of the buffered audio up to end seconds
+func (s *transcriptionStream) transcribe(eventType string, end float64) error {
+	samples := s.buffer[:min(len(s.buffer), int(end*streamSampleRate))]
+	if len(samples) == 0 {
+		return nil
+	}
+	s.transcribed = s.duration()
+
+	dir, err := os.MkdirTemp("", "whisper")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	dst := filepath.Join(dir, "audio.wav")
+	if err := writeWav(dst, samples); err != nil {
+		return err
+	}
+
+	tr, err := backend.ModelTranscription(dst, s.language, false, nil, s.ml, *s.cfg, s.appConfig)
+	if err != nil {
+		return err
+	}
+	s.send(schema.TranscriptionStreamEvent{
+		Type:  eventType,
+		Text:  tr.Text,
+		Start: s.offset,
+		End:   s.offset + float64(len(samples))/streamSampleRate,
+	})
+	return nil
+}
+
+func (s *transcriptionStream) send(event schema.TranscriptionStreamEvent) {
+	if err := s.conn.WriteJSON(event); err != nil {
+		log.Debug().Err(err).Msg("failed sending transcription event")
+	}
+}
+
+// writeWav writes the samples to a 16kHz mono 16 bit wav file
+func writeWav(dst string, samples []float32) error {
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data := make([]int, len(samples))
+	for i, s := range samples {
+		data[i] = int(math.Max(-1, math.Min(1, float64(s))) * 32767)
+	}
+
+	enc := wav.NewEncoder(f, streamSampleRate, 16, 1, 1)
+	if err := enc.Write(&audio.IntBuffer{
+		Format:         &audio.Format{NumChannels: 1, SampleRate: streamSampleRate},
+		Data:           data,
+		SourceBitDepth: 16,
+	}); err != nil {
+		return fmt.Errorf("failed writing the audio: %w", err)
+	}
+	return enc.Close()
+}
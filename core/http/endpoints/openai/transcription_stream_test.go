@@ -0,0 +1,65 @@
+package openai
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVADSegmenter(t *testing.T) {
+	audio := func(seconds float64, level float32) []float32 {
+		samples := make([]float32, int(seconds*streamSampleRate))
+		for i := range samples {
+			samples[i] = level
+			if i%2 == 0 {
+				samples[i] = -level
+			}
+		}
+		return samples
+	}
+
+	s := &vadSegmenter{Silence: 0.5, MinSpeech: 0.25, MaxUtterance: 10, MinEnergy: 0.01}
+
+	// silence: only the pre-roll is kept
+	d := s.update(nil, audio(1, 0))
+	assert.Equal(t, vadDecision{Discard: 1 - streamPreRoll}, d)
+
+	// short or quiet segments are noise
+	buffer := append(audio(0.5, 0.2), audio(1, 0.001)...)
+	d = s.update([]vadSpeech{{Start: 0, End: 0.1}, {Start: 0.5, End: 1.5}}, buffer)
+	assert.False(t, d.Started)
+
+	// ongoing speech
+	buffer = append(audio(0.2, 0), audio(0.6, 0.2)...)
+	d = s.update([]vadSpeech{{Start: 0.2}}, buffer)
+	assert.True(t, d.Started)
+	assert.Equal(t, 0.2, d.SpeechStart)
+	assert.Zero(t, d.Final)
+	assert.Zero(t, d.Discard)
+
+	// a short pause does not end the utterance
+	buffer = append(buffer, audio(0.3, 0)...)
+	d = s.update([]vadSpeech{{Start: 0.2, End: 0.8}}, buffer)
+	assert.False(t, d.Started)
+	assert.False(t, d.Stopped)
+	assert.Zero(t, d.Final)
+
+	// the silence ends it
+	buffer = append(buffer, audio(0.3, 0)...)
+	d = s.update([]vadSpeech{{Start: 0.2, End: 0.8}}, buffer)
+	assert.True(t, d.Stopped)
+	assert.InDelta(t, 0.8+streamSpeechPad, d.Final, 1e-9)
+	assert.Equal(t, d.Final, d.Discard)
+
+	// long utterances are split
+	s.MaxUtterance = 1
+	buffer = audio(1.5, 0.2)
+	d = s.update([]vadSpeech{{Start: 0.1}}, buffer)
+	assert.True(t, d.Started)
+	assert.False(t, d.Stopped)
+	assert.Equal(t, 1.5, d.Final)
+}
+
+func TestPCMToFloat(t *testing.T) {
+	assert.Equal(t, []float32{0, 0.5, -1}, pcmToFloat([]byte{0, 0, 0, 0x40, 0, 0x80}))
+}
@@ -0,0 +1,103 @@
+package openai
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrepareAudio(t *testing.T) {
+	samples := map[string][]byte{
+		utils.AudioFormatMP3:  []byte("ID3\x04\x00\x00\x00\x00\x00\x00"),
+		utils.AudioFormatFLAC: []byte("fLaC\x00\x00\x00\x22"),
+		utils.AudioFormatOGG:  []byte("OggS\x00\x02\x00\x00"),
+		utils.AudioFormatWebM: {0x1A, 0x45, 0xDF, 0xA3, 0x9F, 0x42, 0x86, 0x81},
+		utils.AudioFormatM4A:  []byte("\x00\x00\x00\x20ftypM4A \x00\x00\x02\x00"),
+	}
+	for format, data := range samples {
+		t.Run(format, func(t *testing.T) {
+			// the name of the upload does not match its content
+			src := filepath.Join(t.TempDir(), "recording.wav")
+			require.NoError(t, os.WriteFile(src, data, 0600))
+
+			dst, detected, converted, err := prepareAudio(src, config.AudioConversion{})
+			require.NoError(t, err)
+			assert.Equal(t, format, detected)
+			assert.False(t, converted)
+			assert.Equal(t, filepath.Join(filepath.Dir(src), "recording."+format), dst)
+			assert.FileExists(t, dst)
+
+			// the formats read by the backend are not converted
+			dst, _, converted, err = prepareAudio(dst, config.AudioConversion{Enabled: true, Formats: []string{format}})
+			require.NoError(t, err)
+			assert.False(t, converted)
+			assert.Equal(t, filepath.Join(filepath.Dir(src), "recording."+format), dst)
+		})
+	}
+
+	src := filepath.Join(t.TempDir(), "recording")
+	require.NoError(t, os.WriteFile(src, []byte("not audio"), 0600))
+	dst, detected, converted, err := prepareAudio(src, config.AudioConversion{Enabled: true})
+	require.NoError(t, err)
+	assert.Equal(t, src, dst)
+	assert.Empty(t, detected)
+	assert.False(t, converted)
+}
+
+func TestPrepareAudioConversion(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg is not installed")
+	}
+	dir := t.TempDir()
+	src := filepath.Join(dir, "tone.ogg")
+	out, err := exec.Command("ffmpeg", "-f", "lavfi", "-i", "sine=frequency=440:duration=1", "-ar", "44100", "-ac", "2", src).CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	dst, detected, converted, err := prepareAudio(src, config.AudioConversion{Enabled: true})
+	require.NoError(t, err)
+	assert.Equal(t, utils.AudioFormatOGG, detected)
+	assert.True(t, converted)
+	info, err := utils.ReadWavInfo(dst)
+	require.NoError(t, err)
+	assert.Equal(t, utils.WavInfo{AudioFormat: 1, Channels: 1, SampleRate: 16000, BitsPerSample: 16}, *info)
+
+	// the converted file is already in the format the backend reads
+	_, _, converted, err = prepareAudio(dst, config.AudioConversion{Enabled: true})
+	require.NoError(t, err)
+	assert.False(t, converted)
+}
+
+func TestSuppressLowConfidence(t *testing.T) {
+	threshold := -0.5
+	model := config.TranscriptionConfidence{LogprobThreshold: &threshold}
+
+	for _, value := range []string{"", "false", "0"} {
+		confidence, err := suppressLowConfidence(value, model)
+		assert.NoError(t, err)
+		assert.Nil(t, confidence, value)
+	}
+
+	confidence, err := suppressLowConfidence("true", model)
+	require.NoError(t, err)
+	logprob, _ := confidence.Thresholds()
+	assert.Equal(t, -0.5, logprob)
+
+	// a threshold overrides the one of the model
+	confidence, err = suppressLowConfidence("-0.8", model)
+	require.NoError(t, err)
+	logprob, compressionRatio := confidence.Thresholds()
+	assert.Equal(t, -0.8, logprob)
+	assert.Equal(t, 2.4, compressionRatio)
+	assert.Equal(t, -0.5, *model.LogprobThreshold)
+
+	for _, value := range []string{"0.5", "low"} {
+		_, err = suppressLowConfidence(value, model)
+		assert.Error(t, err, value)
+	}
+}
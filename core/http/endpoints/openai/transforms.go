@@ -0,0 +1,58 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/rs/zerolog/log"
+)
+
+// applyTransforms rewrites the JSON request with the request transforms of the model for the endpoint, and records
+// the transforms for its response. The requests made by the pipelines, the ensembles and the routers to the other
+// models are not transformed: the transforms adapt the requests and the responses of the clients only
+func applyTransforms(c *fiber.Ctx, cfg *config.BackendConfig, input *schema.OpenAIRequest) (*schema.OpenAIRequest, error) {
+	if c.Locals(subRequestKey) != nil {
+		return input, nil
+	}
+	transforms := cfg.EndpointTransforms(c.Path())
+	if len(transforms) == 0 {
+		return input, nil
+	}
+	fiberContext.SetResponseTransforms(c, transforms)
+	if !strings.HasPrefix(string(c.Request().Header.ContentType()), fiber.MIMEApplicationJSON) {
+		return input, nil
+	}
+
+	body, transformed := c.Body(), false
+	for _, t := range transforms {
+		if t.Request == "" {
+			continue
+		}
+		out, err := t.TransformRequest(body)
+		if err != nil {
+			if t.FailOnError {
+				return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("the request transformation failed: %s", err))
+			}
+			log.Warn().Err(err).Str("model", cfg.Name).Msg("the request transformation failed, handling the request as it is")
+			continue
+		}
+		body, transformed = out, true
+	}
+	if !transformed {
+		return input, nil
+	}
+
+	request := new(schema.OpenAIRequest)
+	if err := json.Unmarshal(body, request); err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("the transformed request is invalid: %s", err))
+	}
+	request.Context, request.Cancel = input.Context, input.Cancel
+	// the endpoints reading the body again, e.g. to retry the request, get the transformed request
+	c.Request().SetBody(body)
+	return request, nil
+}
@@ -0,0 +1,75 @@
+package openai
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyTransforms(t *testing.T) {
+	cfg := &config.BackendConfig{Name: "gpt-4", Transforms: []config.Transform{
+		{Endpoints: []string{"/v1/chat/completions"}, Request: `{model, messages: [{role: "user", content: .question}]}`, Response: "."},
+		{Endpoints: []string{"/v1/completions"}, Request: `error("rejected")`, FailOnError: true},
+		{Endpoints: []string{"/v1/embeddings"}, Request: `error("ignored")`},
+	}}
+
+	var (
+		input      *schema.OpenAIRequest
+		body       string
+		transforms []config.Transform
+	)
+	handler := func(c *fiber.Ctx) error {
+		parsed := new(schema.OpenAIRequest)
+		if err := c.BodyParser(parsed); err != nil {
+			return err
+		}
+		var err error
+		input, err = applyTransforms(c, cfg, parsed)
+		body, transforms = string(c.Body()), fiberContext.ResponseTransforms(c)
+		return err
+	}
+	app := fiber.New()
+	for _, path := range []string{"/v1/chat/completions", "/v1/completions", "/v1/embeddings", "/v1/edits"} {
+		app.Post(path, handler)
+	}
+	app.Post("/v1/sub", func(c *fiber.Ctx) error {
+		c.Locals(subRequestKey, true)
+		c.Path("/v1/chat/completions")
+		return handler(c)
+	})
+	send := func(path, request string) int {
+		req := httptest.NewRequest("POST", path, strings.NewReader(request))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, fiber.StatusOK, send("/v1/chat/completions", `{"model": "gpt-4", "question": "Hello"}`))
+	require.Len(t, input.Messages, 1)
+	assert.Equal(t, "Hello", input.Messages[0].Content)
+	assert.JSONEq(t, `{"model": "gpt-4", "messages": [{"role": "user", "content": "Hello"}]}`, body)
+	assert.Equal(t, cfg.Transforms[:1], transforms)
+
+	assert.Equal(t, fiber.StatusBadRequest, send("/v1/completions", `{"model": "gpt-4", "prompt": "Hello"}`))
+	assert.Nil(t, input)
+
+	// the requests are handled as they are when the transformation fails
+	assert.Equal(t, fiber.StatusOK, send("/v1/embeddings", `{"model": "gpt-4", "input": "Hello"}`))
+	assert.Equal(t, "Hello", input.Input)
+
+	assert.Equal(t, fiber.StatusOK, send("/v1/edits", `{"model": "gpt-4", "input": "Hello"}`))
+	assert.Empty(t, transforms)
+
+	// the requests of the pipelines, ensembles and routers are not transformed
+	assert.Equal(t, fiber.StatusOK, send("/v1/sub", `{"model": "gpt-4", "question": "Hello"}`))
+	assert.Empty(t, input.Messages)
+	assert.Empty(t, transforms)
+}
@@ -0,0 +1,230 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/rs/zerolog/log"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// readHeaderTimeout and idleTimeout bound the connections served by net/http, which has no timeouts by default
+	readHeaderTimeout = 30 * time.Second
+	idleTimeout       = 2 * time.Minute
+)
+
+// Listen serves the API on the given address until the context is canceled, then waits for the in-flight requests
+// during the shutdown timeout before returning.
+// Plain HTTP/1.1 is served by fiber itself, HTTP/2 and HTTP/3 require TLS and are
+// served by net/http and quic-go, forwarding the requests to the fiber app.
+func Listen(ctx context.Context, app *fiber.App, address string, appConfig *config.ApplicationConfig) error {
+	useTLS := appConfig.TLSCertFile != "" || appConfig.TLSKeyFile != ""
+	if useTLS && (appConfig.TLSCertFile == "" || appConfig.TLSKeyFile == "") {
+		return errors.New("both a TLS certificate and a key file are required to enable TLS")
+	}
+
+	if !appConfig.HTTP2 && !appConfig.HTTP3 {
+		errs := make(chan error, 1)
+		go func() {
+			if useTLS {
+				errs <- app.ListenTLS(address, appConfig.TLSCertFile, appConfig.TLSKeyFile)
+				return
+			}
+			errs <- app.Listen(address)
+		}()
+		return serve(ctx, errs, appConfig.ShutdownTimeout, app.ShutdownWithContext)
+	}
+
+	if !useTLS {
+		return errors.New("HTTP/2 and HTTP/3 require TLS: set a TLS certificate and key file")
+	}
+
+	cert, err := tls.LoadX509KeyPair(appConfig.TLSCertFile, appConfig.TLSKeyFile)
+	if err != nil {
+		return fmt.Errorf("failed loading the TLS certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if appConfig.HTTP2 {
+		tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+	} else {
+		tlsConfig.NextProtos = []string{"http/1.1"}
+	}
+
+	handler := StreamingHandler(app)
+
+	errs := make(chan error, 2)
+	shutdowns := []func(context.Context) error{}
+	if appConfig.HTTP3 {
+		h3 := &http3.Server{
+			Addr:      address,
+			Handler:   handler,
+			TLSConfig: http3.ConfigureTLSConfig(tlsConfig),
+		}
+		// advertise HTTP/3 to the clients connecting over TCP
+		tcpHandler := handler
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := h3.SetQUICHeaders(w.Header()); err != nil {
+				log.Debug().Err(err).Msg("failed setting the HTTP/3 Alt-Svc header")
+			}
+			tcpHandler.ServeHTTP(w, r)
+		})
+
+		log.Warn().Str("address", address).Msg("HTTP/3 support is experimental")
+		go func() {
+			errs <- h3.ListenAndServe()
+		}()
+		shutdowns = append(shutdowns, h3.Shutdown)
+	}
+
+	server := &http.Server{
+		Addr:              address,
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: readHeaderTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+	if !appConfig.HTTP2 {
+		// an empty, non-nil map disables the automatic HTTP/2 support of net/http
+		server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	}
+
+	log.Info().Str("address", address).Bool("http2", appConfig.HTTP2).Bool("http3", appConfig.HTTP3).Msg("Serving the API over TLS")
+	go func() {
+		errs <- server.ListenAndServeTLS("", "")
+	}()
+	shutdowns = append(shutdowns, server.Shutdown)
+
+	return serve(ctx, errs, appConfig.ShutdownTimeout, shutdowns...)
+}
+
+// serve waits for the servers to fail, or for the context to be canceled. The servers are then shut down: they stop
+// accepting connections and wait for the in-flight requests until the timeout
+func serve(ctx context.Context, errs <-chan error, timeout time.Duration, shutdowns ...func(context.Context) error) error {
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Info().Dur("timeout", timeout).Msg("Shutting down the API server, waiting for the in-flight requests")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var err error
+	for _, shutdown := range shutdowns {
+		err = errors.Join(err, shutdown(shutdownCtx))
+	}
+	if err != nil {
+		return fmt.Errorf("the API server was not shut down cleanly: %w", err)
+	}
+	log.Info().Msg("API server shut down")
+	return nil
+}
+
+// hopHeaders are connection specific headers which must not be forwarded over HTTP/2 and HTTP/3
+var hopHeaders = []string{"Connection", "Keep-Alive", "Transfer-Encoding", "Upgrade"}
+
+// StreamingHandler returns a net/http handler running the fiber app.
+// Unlike the fiber adaptor, streamed responses (e.g. server-sent events) are not buffered
+// but forwarded and flushed to the client as they are written.
+// The request bodies are limited to the BodyLimit of the app, as fasthttp does for the app served by fiber.
+func StreamingHandler(app *fiber.App) http.Handler {
+	fiberHandler := app.Handler()
+	bodyLimit := int64(app.Config().BodyLimit)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := fasthttp.AcquireRequest()
+		defer fasthttp.ReleaseRequest(req)
+
+		tooLarge := false
+		if r.Body != nil {
+			n, err := io.Copy(req.BodyWriter(), http.MaxBytesReader(w, r.Body, bodyLimit))
+			var maxBytesErr *http.MaxBytesError
+			switch {
+			case errors.As(err, &maxBytesErr):
+				tooLarge = true
+				req.ResetBody()
+			case err != nil:
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			default:
+				req.Header.SetContentLength(int(n))
+			}
+		}
+		req.Header.SetMethod(r.Method)
+		req.SetRequestURI(r.RequestURI)
+		req.SetHost(r.Host)
+		req.Header.SetHost(r.Host)
+		for key, values := range r.Header {
+			for _, v := range values {
+				req.Header.Add(key, v)
+			}
+		}
+
+		remoteAddr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr)
+		if err != nil {
+			remoteAddr = &net.TCPAddr{}
+		}
+
+		var fctx fasthttp.RequestCtx
+		fctx.Init(req, remoteAddr, nil)
+		if tooLarge {
+			// answered by the error handler of the app, as the bodies rejected by fasthttp
+			c := app.AcquireCtx(&fctx)
+			if err := app.Config().ErrorHandler(c, fiber.ErrRequestEntityTooLarge); err != nil {
+				fctx.Error(fiber.ErrRequestEntityTooLarge.Message, fiber.StatusRequestEntityTooLarge)
+			}
+			app.ReleaseCtx(c)
+		} else {
+			fiberHandler(&fctx)
+		}
+		defer fctx.Response.CloseBodyStream() //nolint:errcheck
+
+		stream := fctx.Response.IsBodyStream()
+		fctx.Response.Header.VisitAll(func(k, v []byte) {
+			key := string(k)
+			for _, h := range hopHeaders {
+				if strings.EqualFold(key, h) {
+					return
+				}
+			}
+			if stream && strings.EqualFold(key, fiber.HeaderContentLength) {
+				return
+			}
+			w.Header().Add(key, string(v))
+		})
+		w.WriteHeader(fctx.Response.StatusCode())
+
+		if !stream {
+			_, _ = w.Write(fctx.Response.Body())
+			return
+		}
+
+		flusher, _ := w.(http.Flusher)
+		body := fctx.Response.BodyStream()
+		buf := make([]byte, 4096)
+		for {
+			n, err := body.Read(buf)
+			if n > 0 {
+				if _, werr := w.Write(buf[:n]); werr != nil {
+					return
+				}
+				if flusher != nil {
+					flusher.Flush()
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package http_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	. "github.com/mudler/LocalAI/core/http"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HTTP/2 support", func() {
+	It("streams server-sent events without buffering", func() {
+		next := make(chan struct{})
+		app := fiber.New()
+		app.Get("/events", func(c *fiber.Ctx) error {
+			c.Set("Content-Type", "text/event-stream")
+			c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+				for i := 0; i < 2; i++ {
+					fmt.Fprintf(w, "data: %d\n\n", i)
+					w.Flush()
+					<-next
+				}
+			})
+			return nil
+		})
+		app.Get("/plain", func(c *fiber.Ctx) error {
+			return c.SendString("ok")
+		})
+
+		server := httptest.NewUnstartedServer(StreamingHandler(app))
+		server.EnableHTTP2 = true
+		server.StartTLS()
+		defer server.Close()
+
+		resp, err := server.Client().Get(server.URL + "/plain")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.ProtoMajor).To(Equal(2))
+		body, err := io.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(body)).To(Equal("ok"))
+		resp.Body.Close()
+
+		resp, err = server.Client().Get(server.URL + "/events")
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.ProtoMajor).To(Equal(2))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("text/event-stream"))
+
+		reader := bufio.NewReader(resp.Body)
+		// the first event must be received while the handler is still waiting to write the second one
+		line, err := reader.ReadString('\n')
+		Expect(err).ToNot(HaveOccurred())
+		Expect(line).To(Equal("data: 0\n"))
+		next <- struct{}{}
+
+		_, err = reader.ReadString('\n')
+		Expect(err).ToNot(HaveOccurred())
+		line, err = reader.ReadString('\n')
+		Expect(err).ToNot(HaveOccurred())
+		Expect(line).To(Equal("data: 1\n"))
+		close(next)
+	})
+
+	It("limits the size of the request bodies", func() {
+		app := fiber.New(fiber.Config{BodyLimit: 1024})
+		app.Post("/echo", func(c *fiber.Ctx) error {
+			return c.SendString(fmt.Sprint(len(c.Body())))
+		})
+
+		server := httptest.NewUnstartedServer(StreamingHandler(app))
+		server.EnableHTTP2 = true
+		server.StartTLS()
+		defer server.Close()
+
+		resp, err := server.Client().Post(server.URL+"/echo", "text/plain", strings.NewReader(strings.Repeat("a", 1024)))
+		Expect(err).ToNot(HaveOccurred())
+		body, err := io.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(string(body)).To(Equal("1024"))
+
+		resp, err = server.Client().Post(server.URL+"/echo", "text/plain", strings.NewReader(strings.Repeat("a", 1025)))
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.ProtoMajor).To(Equal(2))
+		Expect(resp.StatusCode).To(Equal(http.StatusRequestEntityTooLarge))
+	})
+
+	It("requires TLS for HTTP/2 and HTTP/3", func() {
+		appConfig := config.NewApplicationConfig(config.EnableHTTP2)
+		Expect(Listen(context.Background(), fiber.New(), "127.0.0.1:0", appConfig)).To(MatchError(ContainSubstring("require TLS")))
+
+		appConfig = config.NewApplicationConfig(config.WithTLS("cert.pem", ""))
+		Expect(Listen(context.Background(), fiber.New(), "127.0.0.1:0", appConfig)).To(HaveOccurred())
+	})
+
+	Context("shutdown", func() {
+		var (
+			app      *fiber.App
+			address  string
+			release  chan struct{}
+			received chan struct{}
+		)
+
+		BeforeEach(func() {
+			release, received = make(chan struct{}), make(chan struct{})
+			app = fiber.New()
+			app.Get("/slow", func(c *fiber.Ctx) error {
+				close(received)
+				<-release
+				return c.SendString("done")
+			})
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+			address = lis.Addr().String()
+			lis.Close()
+		})
+
+		// listen serves the app until the context is canceled, and returns the error of Listen
+		listen := func(ctx context.Context, timeout time.Duration) chan error {
+			errs := make(chan error, 1)
+			go func() {
+				errs <- Listen(ctx, app, address, config.NewApplicationConfig(config.WithShutdownTimeout(timeout)))
+			}()
+			return errs
+		}
+
+		It("waits for the in-flight requests", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			errs := listen(ctx, 5*time.Second)
+
+			responses := make(chan string, 1)
+			go func() {
+				defer GinkgoRecover()
+				var resp *http.Response
+				Eventually(func() (err error) {
+					resp, err = http.Get("http://" + address + "/slow")
+					return err
+				}, "5s").ShouldNot(HaveOccurred())
+				defer resp.Body.Close()
+				body, _ := io.ReadAll(resp.Body)
+				responses <- string(body)
+			}()
+			Eventually(received, "5s").Should(BeClosed())
+
+			cancel()
+			Consistently(errs, "200ms").ShouldNot(Receive())
+			close(release)
+			Eventually(responses, "5s").Should(Receive(Equal("done")))
+			Eventually(errs, "5s").Should(Receive(BeNil()))
+		})
+
+		It("fails when the in-flight requests outlast the timeout", func() {
+			defer close(release)
+			ctx, cancel := context.WithCancel(context.Background())
+			errs := listen(ctx, 100*time.Millisecond)
+
+			go func() {
+				defer GinkgoRecover()
+				Eventually(func() error {
+					resp, err := http.Get("http://" + address + "/slow")
+					if err == nil {
+						resp.Body.Close()
+					}
+					return err
+				}, "5s").ShouldNot(HaveOccurred())
+			}()
+			Eventually(received, "5s").Should(BeClosed())
+
+			cancel()
+			Eventually(errs, "5s").Should(Receive(MatchError(ContainSubstring("not shut down cleanly"))))
+		})
+	})
+})
@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/rs/zerolog/log"
+)
+
+// APIVersionHeader pins the version of the responses in the requests, and is set to the version used in the responses
+const APIVersionHeader = "X-LocalAI-API-Version"
+
+// apiVersionTranslation translates the JSON responses of endpoints from a version to the previous one
+type apiVersionTranslation struct {
+	// endpoints are the paths of the endpoints translated, without the /v1 prefix
+	endpoints []string
+	translate func(response map[string]interface{})
+}
+
+// apiVersionTranslations are the translations from each version to the previous one, by version translated from
+var apiVersionTranslations = map[int][]apiVersionTranslation{
+	2: {
+		{
+			endpoints: []string{"/chat/completions", "/completions", "/edits", "/embeddings"},
+			translate: func(response map[string]interface{}) {
+				delete(response, "metadata")
+				if usage, ok := response["usage"].(map[string]interface{}); ok {
+					for key := range usage {
+						if strings.HasPrefix(key, "timing_") {
+							delete(usage, key)
+						}
+					}
+				}
+				if data, ok := response["data"].([]interface{}); ok {
+					for _, item := range data {
+						if item, ok := item.(map[string]interface{}); ok {
+							delete(item, "prompt_tokens")
+						}
+					}
+				}
+			},
+		},
+	},
+}
+
+// APIVersions returns the versions of the responses the clients can pin
+func APIVersions(appConfig *config.ApplicationConfig) schema.CapabilitiesAPIVersions {
+	versions := schema.CapabilitiesAPIVersions{Header: APIVersionHeader, Default: defaultAPIVersion(appConfig)}
+	for v := 1; v <= schema.LatestAPIVersion; v++ {
+		versions.Supported = append(versions.Supported, v)
+	}
+	return versions
+}
+
+func defaultAPIVersion(appConfig *config.ApplicationConfig) int {
+	if appConfig.DefaultAPIVersion > 0 {
+		return appConfig.DefaultAPIVersion
+	}
+	return schema.LatestAPIVersion
+}
+
+// APIVersion returns a middleware translating the JSON responses of the endpoints to the version pinned by the
+// X-LocalAI-API-Version header of the request, or to the default one. The errors and the streamed responses are
+// returned as they are
+func APIVersion(appConfig *config.ApplicationConfig) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		version := defaultAPIVersion(appConfig)
+		if h := c.Get(APIVersionHeader); h != "" {
+			v, err := strconv.Atoi(h)
+			if err != nil || v < 1 || v > schema.LatestAPIVersion {
+				return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("unsupported %s %q: the supported versions are 1 to %d", APIVersionHeader, h, schema.LatestAPIVersion))
+			}
+			version = v
+		}
+
+		err := c.Next()
+		c.Set(APIVersionHeader, strconv.Itoa(version))
+		if err != nil || version == schema.LatestAPIVersion || c.Response().IsBodyStream() ||
+			c.Response().StatusCode() >= fiber.StatusBadRequest ||
+			!strings.HasPrefix(string(c.Response().Header.ContentType()), fiber.MIMEApplicationJSON) {
+			return err
+		}
+
+		body, err := translateResponse(strings.TrimPrefix(c.Path(), "/v1"), c.Response().Body(), version)
+		if err != nil {
+			log.Warn().Err(err).Str("path", c.Path()).Int("version", version).Msg("failed translating the response, returning it as it is")
+			return nil
+		}
+		c.Response().SetBody(body)
+		return nil
+	}
+}
+
+// translateResponse translates the JSON response of the endpoint from the latest version to the version
+func translateResponse(endpoint string, body []byte, version int) ([]byte, error) {
+	var translations []apiVersionTranslation
+	for v := schema.LatestAPIVersion; v > version; v-- {
+		for _, t := range apiVersionTranslations[v] {
+			if slices.Contains(t.endpoints, endpoint) {
+				translations = append(translations, t)
+			}
+		}
+	}
+	if len(translations) == 0 {
+		return body, nil
+	}
+
+	response := map[string]interface{}{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+	for _, t := range translations {
+		t.translate(response)
+	}
+	return json.Marshal(response)
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranslateResponse(t *testing.T) {
+	chat := `{"object": "chat.completion", "choices": [{"index": 0, "message": {"role": "assistant", "content": "Hi"}}],
+		"usage": {"prompt_tokens": 3, "completion_tokens": 1, "total_tokens": 4, "timing_prompt_processing": 1.5, "timing_first_token": 2.5},
+		"metadata": {"backend": "llama-cpp"}}`
+
+	// the latest version is returned as it is
+	body, err := translateResponse("/chat/completions", []byte(chat), schema.LatestAPIVersion)
+	require.NoError(t, err)
+	assert.JSONEq(t, chat, string(body))
+
+	// the version 1 has no LocalAI extensions
+	body, err = translateResponse("/chat/completions", []byte(chat), 1)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"object": "chat.completion", "choices": [{"index": 0, "message": {"role": "assistant", "content": "Hi"}}],
+		"usage": {"prompt_tokens": 3, "completion_tokens": 1, "total_tokens": 4}}`, string(body))
+
+	body, err = translateResponse("/embeddings", []byte(`{"data": [{"embedding": [0.5], "index": 0, "prompt_tokens": 2}], "usage": {"prompt_tokens": 2, "total_tokens": 2}}`), 1)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"data": [{"embedding": [0.5], "index": 0}], "usage": {"prompt_tokens": 2, "total_tokens": 2}}`, string(body))
+
+	// the other endpoints are not translated
+	other := `{"metadata": {"kept": true}}`
+	body, err = translateResponse("/models", []byte(other), 1)
+	require.NoError(t, err)
+	assert.Equal(t, other, string(body))
+
+	_, err = translateResponse("/completions", []byte(`not json`), 1)
+	assert.Error(t, err)
+}
+
+func TestAPIVersion(t *testing.T) {
+	newApp := func(appConfig *config.ApplicationConfig) *fiber.App {
+		app := fiber.New()
+		app.Use(APIVersion(appConfig))
+		app.Post("/v1/completions", func(c *fiber.Ctx) error {
+			return c.JSON(fiber.Map{"choices": []fiber.Map{{"text": "Hi"}}, "metadata": fiber.Map{"backend": "llama-cpp"}})
+		})
+		app.Post("/v1/failed", func(c *fiber.Ctx) error {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"metadata": fiber.Map{}})
+		})
+		return app
+	}
+	send := func(app *fiber.App, path, version string) (int, string, string) {
+		req := httptest.NewRequest("POST", path, nil)
+		if version != "" {
+			req.Header.Set(APIVersionHeader, version)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, resp.Header.Get(APIVersionHeader), string(body)
+	}
+
+	app := newApp(config.NewApplicationConfig())
+	status, version, body := send(app, "/v1/completions", "")
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "2", version)
+	assert.JSONEq(t, `{"choices": [{"text": "Hi"}], "metadata": {"backend": "llama-cpp"}}`, body)
+
+	status, version, body = send(app, "/v1/completions", "1")
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "1", version)
+	assert.JSONEq(t, `{"choices": [{"text": "Hi"}]}`, body)
+
+	for _, unsupported := range []string{"0", "3", "latest"} {
+		status, _, _ = send(app, "/v1/completions", unsupported)
+		assert.Equal(t, fiber.StatusBadRequest, status, unsupported)
+	}
+
+	// the errors are returned as they are
+	status, _, body = send(app, "/v1/failed", "1")
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	assert.JSONEq(t, `{"metadata": {}}`, body)
+
+	// the default version applies to the requests without the header
+	app = newApp(config.NewApplicationConfig(config.WithDefaultAPIVersion(1)))
+	_, version, body = send(app, "/v1/completions", "")
+	assert.Equal(t, "1", version)
+	assert.JSONEq(t, `{"choices": [{"text": "Hi"}]}`, body)
+	_, version, _ = send(app, "/v1/completions", "2")
+	assert.Equal(t, "2", version)
+
+	assert.Equal(t, schema.CapabilitiesAPIVersions{Header: APIVersionHeader, Supported: []int{1, 2}, Default: 1}, APIVersions(config.NewApplicationConfig(config.WithDefaultAPIVersion(1))))
+}
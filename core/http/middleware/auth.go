@@ -3,11 +3,13 @@ package middleware
 import (
 	"crypto/subtle"
 	"errors"
+	"slices"
 
 	"github.com/dave-gray101/v2keyauth"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/keyauth"
 	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
 	"github.com/mudler/LocalAI/core/http/utils"
 )
 
@@ -60,6 +62,8 @@ func getApiKeyValidationFunction(applicationConfig *config.ApplicationConfig) fu
 			}
 			for _, validKey := range applicationConfig.ApiKeys {
 				if subtle.ConstantTimeCompare([]byte(apiKey), []byte(validKey)) == 1 {
+					fiberContext.SetAllowedModels(ctx, applicationConfig.ApiKeyModels[validKey])
+					fiberContext.SetAdmin(ctx, slices.Contains(applicationConfig.AdminApiKeys, validKey))
 					return true, nil
 				}
 			}
@@ -73,6 +77,8 @@ func getApiKeyValidationFunction(applicationConfig *config.ApplicationConfig) fu
 		}
 		for _, validKey := range applicationConfig.ApiKeys {
 			if apiKey == validKey {
+				fiberContext.SetAllowedModels(ctx, applicationConfig.ApiKeyModels[validKey])
+				fiberContext.SetAdmin(ctx, slices.Contains(applicationConfig.AdminApiKeys, validKey))
 				return true, nil
 			}
 		}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dave-gray101/v2keyauth"
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApiKeyModelAllowlist(t *testing.T) {
+	appConfig := config.NewApplicationConfig(
+		config.WithApiKeys([]string{"free", "premium"}),
+		config.WithApiKeyModels(map[string][]string{"free": {"small"}}),
+	)
+	kaConfig, err := GetKeyAuthConfig(appConfig)
+	require.NoError(t, err)
+
+	modelPath := t.TempDir()
+	cl := config.NewBackendConfigLoader(modelPath)
+	ml := model.NewModelLoader(modelPath)
+
+	app := fiber.New()
+	app.Use(v2keyauth.New(*kaConfig))
+	app.Get("/models", func(c *fiber.Ctx) error {
+		return c.JSON(fiberContext.FilterAllowedModels(c, []string{"small", "large"}))
+	})
+	app.Post("/use/:model", func(c *fiber.Ctx) error {
+		m, err := fiberContext.ModelFromContext(c, cl, ml, "", false)
+		if err != nil {
+			return err
+		}
+		return c.SendString(m)
+	})
+
+	for _, tc := range []struct {
+		key          string
+		expectModels []string
+	}{
+		{key: "free", expectModels: []string{"small"}},
+		{key: "premium", expectModels: []string{"small", "large"}},
+	} {
+		req := httptest.NewRequest("GET", "/models", nil)
+		req.Header.Set("Authorization", "Bearer "+tc.key)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, 200, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		models := []string{}
+		require.NoError(t, json.Unmarshal(body, &models))
+		require.Equal(t, tc.expectModels, models, tc.key)
+	}
+
+	for _, tc := range []struct {
+		key          string
+		model        string
+		expectStatus int
+	}{
+		{key: "free", model: "small", expectStatus: 200},
+		{key: "free", model: "large", expectStatus: 403},
+		{key: "premium", model: "large", expectStatus: 200},
+	} {
+		req := httptest.NewRequest("POST", "/use/"+tc.model, nil)
+		req.Header.Set("Authorization", "Bearer "+tc.key)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, tc.expectStatus, resp.StatusCode, tc.key+" "+tc.model)
+	}
+}
+
+func TestAdminApiKeys(t *testing.T) {
+	appConfig := config.NewApplicationConfig(
+		config.WithApiKeys([]string{"user"}),
+		config.WithAdminApiKeys([]string{"admin"}),
+	)
+	kaConfig, err := GetKeyAuthConfig(appConfig)
+	require.NoError(t, err)
+
+	app := fiber.New()
+	app.Use(v2keyauth.New(*kaConfig))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.JSON(fiberContext.IsAdmin(c))
+	})
+
+	for key, expectAdmin := range map[string]bool{"user": false, "admin": true} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, 200, resp.StatusCode, key)
+		body, _ := io.ReadAll(resp.Body)
+		admin := false
+		require.NoError(t, json.Unmarshal(body, &admin))
+		require.Equal(t, expectAdmin, admin, key)
+	}
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/valyala/fasthttp"
+)
+
+// compressionLevels are the brotli and gzip (and deflate) levels of the compression of the responses, by name
+var compressionLevels = map[string][2]int{
+	"best-speed":       {fasthttp.CompressBrotliBestSpeed, fasthttp.CompressBestSpeed},
+	"default":          {fasthttp.CompressBrotliDefaultCompression, fasthttp.CompressDefaultCompression},
+	"best-compression": {fasthttp.CompressBrotliBestCompression, fasthttp.CompressBestCompression},
+}
+
+// Compress compresses the responses with brotli, gzip or deflate, the first accepted by the client (Accept-Encoding).
+// The streamed responses (server-sent events, audio streams) are sent as they are: the compression would hold
+// their chunks back until enough of them is written
+func Compress(appConfig *config.ApplicationConfig) fiber.Handler {
+	levels, ok := compressionLevels[appConfig.Compression]
+	if !ok {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+	compressor := fasthttp.CompressHandlerBrotliLevel(func(*fasthttp.RequestCtx) {}, levels[0], levels[1])
+
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+		if c.Response().IsBodyStream() {
+			return nil
+		}
+		compressor(c.Context())
+		return nil
+	}
+}
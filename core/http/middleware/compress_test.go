@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompress(t *testing.T) {
+	page := "<html><body>" + strings.Repeat("<p>model</p>", 100) + "</body></html>"
+
+	newApp := func(level string) *fiber.App {
+		app := fiber.New()
+		app.Use(Compress(&config.ApplicationConfig{Compression: level}))
+		app.Get("/", func(c *fiber.Ctx) error {
+			c.Type("html")
+			return c.SendString(page)
+		})
+		app.Get("/events", func(c *fiber.Ctx) error {
+			c.Set("Content-Type", "text/event-stream")
+			c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+				w.WriteString("data: " + page + "\n\n")
+				w.Flush()
+			})
+			return nil
+		})
+		return app
+	}
+
+	send := func(app *fiber.App, path, acceptEncoding string) (string, string) {
+		req := httptest.NewRequest("GET", path, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var body io.Reader = resp.Body
+		if resp.Header.Get("Content-Encoding") == "gzip" {
+			body, err = gzip.NewReader(resp.Body)
+			require.NoError(t, err)
+		}
+		out, err := io.ReadAll(body)
+		require.NoError(t, err)
+		return resp.Header.Get("Content-Encoding"), string(out)
+	}
+
+	app := newApp("default")
+
+	encoding, body := send(app, "/", "gzip")
+	assert.Equal(t, "gzip", encoding)
+	assert.Equal(t, page, body)
+
+	encoding, body = send(app, "/", "")
+	assert.Empty(t, encoding)
+	assert.Equal(t, page, body)
+
+	encoding, body = send(app, "/events", "gzip")
+	assert.Empty(t, encoding)
+	assert.Equal(t, "data: "+page+"\n\n", body)
+
+	encoding, body = send(newApp(""), "/", "gzip")
+	assert.Empty(t, encoding)
+	assert.Equal(t, page, body)
+}
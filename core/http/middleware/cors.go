@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/rs/zerolog/log"
+)
+
+// CORS lets the browser apps of other origins call the API: the ones in CORSAllowOrigins, or all of them when
+// it is empty. With CORSAllowCredentials the apps can send their cookies and credentials too, which is only
+// allowed for a list of origins: the origin of the request is returned instead of a wildcard
+func CORS(appConfig *config.ApplicationConfig) fiber.Handler {
+	cfg := cors.Config{AllowOrigins: appConfig.CORSAllowOrigins}
+	if appConfig.CORSAllowCredentials {
+		if origins := strings.TrimSpace(appConfig.CORSAllowOrigins); origins == "" || origins == "*" {
+			log.Warn().Msg("CORS credentials are only allowed for a list of origins, they are disabled")
+		} else {
+			cfg.AllowCredentials = true
+		}
+	}
+	if cfg.AllowOrigins == "" {
+		cfg.AllowOrigins = "*"
+	}
+	return cors.New(cfg)
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCORS(t *testing.T) {
+	newApp := func(appConfig *config.ApplicationConfig) *fiber.App {
+		app := fiber.New()
+		app.Use(CORS(appConfig))
+		app.Get("/api/summary", func(c *fiber.Ctx) error {
+			return c.JSON(fiber.Map{})
+		})
+		return app
+	}
+
+	send := func(app *fiber.App, method, origin string) (int, map[string]string) {
+		req := httptest.NewRequest(method, "/api/summary", nil)
+		req.Header.Set(fiber.HeaderOrigin, origin)
+		if method == fiber.MethodOptions {
+			req.Header.Set(fiber.HeaderAccessControlRequestMethod, fiber.MethodGet)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode, map[string]string{
+			"origin":      resp.Header.Get(fiber.HeaderAccessControlAllowOrigin),
+			"credentials": resp.Header.Get(fiber.HeaderAccessControlAllowCredentials),
+		}
+	}
+
+	app := newApp(&config.ApplicationConfig{
+		CORSAllowOrigins:     "https://app.example.com, https://admin.example.com",
+		CORSAllowCredentials: true,
+	})
+
+	status, headers := send(app, fiber.MethodGet, "https://app.example.com")
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, map[string]string{"origin": "https://app.example.com", "credentials": "true"}, headers)
+
+	status, headers = send(app, fiber.MethodOptions, "https://admin.example.com")
+	assert.Equal(t, fiber.StatusNoContent, status)
+	assert.Equal(t, map[string]string{"origin": "https://admin.example.com", "credentials": "true"}, headers)
+
+	_, headers = send(app, fiber.MethodGet, "https://evil.example.com")
+	assert.Equal(t, map[string]string{"origin": "", "credentials": ""}, headers)
+
+	// all the origins are allowed without a list, but not their credentials
+	app = newApp(&config.ApplicationConfig{CORSAllowCredentials: true})
+	_, headers = send(app, fiber.MethodGet, "https://evil.example.com")
+	assert.Equal(t, map[string]string{"origin": "*", "credentials": ""}, headers)
+}
@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/dave-gray101/v2keyauth"
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// IdempotencyKeyHeader is the request header with the idempotency key chosen by the client
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on the responses replayed for a repeated idempotency key
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	// maxIdempotentBodyBytes is the size of the largest response stored for replay, the larger ones are run again
+	maxIdempotentBodyBytes = 1 << 20
+	// maxIdempotentResponses caps the number of keys kept during the window, the requests with new keys are run
+	// without being stored once it is reached
+	maxIdempotentResponses = 10000
+	// idempotencySweepInterval is the interval between the removals of the expired keys
+	idempotencySweepInterval = time.Minute
+)
+
+// idempotentResponse is the response of the first request with an idempotency key
+type idempotentResponse struct {
+	// fingerprint is the hash of the body of the request, to detect the keys reused for other requests
+	fingerprint string
+	// done is unset while the first request is in progress
+	done        bool
+	status      int
+	contentType string
+	body        []byte
+	// expires is the end of the window, from the start of the request while it is in progress
+	expires time.Time
+}
+
+// idempotencyStore keeps the responses by idempotency key, until the window expires
+type idempotencyStore struct {
+	mu        sync.Mutex
+	responses map[string]*idempotentResponse
+	window    time.Duration
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+// Idempotency returns a middleware replaying the response of the first request with the same Idempotency-Key header
+// during the window, instead of running the request again. It is a no-op when the window is 0
+func Idempotency(appConfig *config.ApplicationConfig) fiber.Handler {
+	if appConfig.IdempotencyWindow <= 0 {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+	return newIdempotencyStore(appConfig.IdempotencyWindow, time.Now).handler
+}
+
+func newIdempotencyStore(window time.Duration, now func() time.Time) *idempotencyStore {
+	return &idempotencyStore{responses: map[string]*idempotentResponse{}, window: window, now: now}
+}
+
+func (s *idempotencyStore) handler(c *fiber.Ctx) error {
+	idempotencyKey := c.Get(IdempotencyKeyHeader)
+	if idempotencyKey == "" || c.Method() != fiber.MethodPost {
+		return c.Next()
+	}
+	// the keys are scoped to the API key, whichever header or cookie it was sent with, and the endpoint of the request
+	key := hashStrings(v2keyauth.TokenFromContext(c), c.Path(), idempotencyKey)
+	fingerprint := hashStrings(string(c.Body()))
+
+	s.mu.Lock()
+	s.expire(false)
+	if r, exists := s.responses[key]; exists && s.now().After(r.expires) {
+		delete(s.responses, key)
+	}
+	if r, exists := s.responses[key]; exists {
+		s.mu.Unlock()
+		switch {
+		case r.fingerprint != fingerprint:
+			return fiber.NewError(fiber.StatusUnprocessableEntity, "the Idempotency-Key was already used for a different request")
+		case !r.done:
+			return fiber.NewError(fiber.StatusConflict, "a request with the same Idempotency-Key is in progress")
+		}
+		c.Set(IdempotentReplayedHeader, "true")
+		c.Set(fiber.HeaderContentType, r.contentType)
+		return c.Status(r.status).Send(r.body)
+	}
+	if len(s.responses) >= maxIdempotentResponses {
+		s.expire(true)
+	}
+	if len(s.responses) >= maxIdempotentResponses {
+		s.mu.Unlock()
+		log.Warn().Int("keys", len(s.responses)).Msg("too many idempotency keys in the window, the request is not stored for replay")
+		return c.Next()
+	}
+	// the requests which never complete, e.g. panicking, release their key at the end of the window
+	pending := &idempotentResponse{fingerprint: fingerprint, expires: s.now().Add(s.window)}
+	s.responses[key] = pending
+	s.mu.Unlock()
+
+	stored := false
+	defer func() {
+		if stored {
+			return
+		}
+		// the failed requests can be retried
+		s.mu.Lock()
+		if s.responses[key] == pending {
+			delete(s.responses, key)
+		}
+		s.mu.Unlock()
+	}()
+
+	err := c.Next()
+
+	status := c.Response().StatusCode()
+	// the failed requests can be retried, and the streamed and too large responses cannot be replayed
+	if err != nil || status >= fiber.StatusInternalServerError || c.Response().IsBodyStream() ||
+		len(c.Response().Body()) > maxIdempotentBodyBytes {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored = true
+	pending.done = true
+	pending.status = status
+	pending.contentType = string(c.Response().Header.ContentType())
+	pending.body = append([]byte(nil), c.Response().Body()...)
+	pending.expires = s.now().Add(s.window)
+	return nil
+}
+
+// expire removes the responses whose window expired, at most once per sweep interval unless forced.
+// It is called with the lock held
+func (s *idempotencyStore) expire(force bool) {
+	now := s.now()
+	if !force && now.Sub(s.lastSweep) < idempotencySweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, r := range s.responses {
+		if now.After(r.expires) {
+			delete(s.responses, key)
+		}
+	}
+}
+
+func hashStrings(values ...string) string {
+	h := sha256.New()
+	for _, v := range values {
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,217 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dave-gray101/v2keyauth"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotency(t *testing.T) {
+	now := time.Now()
+	store := newIdempotencyStore(time.Minute, func() time.Time { return now })
+
+	runs := 0
+	app := fiber.New()
+	app.Use(store.handler)
+	app.Post("/v1/chat/completions", func(c *fiber.Ctx) error {
+		runs++
+		return c.JSON(fiber.Map{"run": runs})
+	})
+	app.Post("/v1/fail", func(c *fiber.Ctx) error {
+		runs++
+		return c.Status(fiber.StatusServiceUnavailable).SendString("busy")
+	})
+
+	send := func(path, key, body string) (int, string, string) {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		out, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(out), resp.Header.Get(IdempotentReplayedHeader)
+	}
+
+	t.Run("replays the first response", func(t *testing.T) {
+		status, body, replayed := send("/v1/chat/completions", "key-1", `{"model":"a"}`)
+		assert.Equal(t, 200, status)
+		assert.Equal(t, `{"run":1}`, body)
+		assert.Empty(t, replayed)
+
+		status, body, replayed = send("/v1/chat/completions", "key-1", `{"model":"a"}`)
+		assert.Equal(t, 200, status)
+		assert.Equal(t, `{"run":1}`, body)
+		assert.Equal(t, "true", replayed)
+		assert.Equal(t, 1, runs)
+	})
+
+	t.Run("rejects the keys reused for other requests", func(t *testing.T) {
+		status, _, _ := send("/v1/chat/completions", "key-1", `{"model":"b"}`)
+		assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+		assert.Equal(t, 1, runs)
+	})
+
+	t.Run("runs the requests without a key", func(t *testing.T) {
+		_, body, _ := send("/v1/chat/completions", "", `{"model":"a"}`)
+		assert.Equal(t, `{"run":2}`, body)
+	})
+
+	t.Run("does not replay the failed requests", func(t *testing.T) {
+		send("/v1/fail", "key-2", `{}`)
+		status, _, replayed := send("/v1/fail", "key-2", `{}`)
+		assert.Equal(t, fiber.StatusServiceUnavailable, status)
+		assert.Empty(t, replayed)
+		assert.Equal(t, 4, runs)
+	})
+
+	t.Run("runs the request again once the window expired", func(t *testing.T) {
+		now = now.Add(time.Minute + time.Second)
+		status, body, replayed := send("/v1/chat/completions", "key-1", `{"model":"a"}`)
+		assert.Equal(t, 200, status)
+		assert.Equal(t, `{"run":5}`, body)
+		assert.Empty(t, replayed)
+		assert.Len(t, store.responses, 1)
+	})
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	store := newIdempotencyStore(time.Minute, time.Now)
+	store.responses[hashStrings("", "/v1/chat/completions", "key")] = &idempotentResponse{fingerprint: hashStrings("{}"), expires: time.Now().Add(time.Minute)}
+
+	app := fiber.New()
+	app.Use(store.handler)
+	app.Post("/v1/chat/completions", func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader("{}"))
+	req.Header.Set(IdempotencyKeyHeader, "key")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+}
+
+func TestIdempotencyScope(t *testing.T) {
+	kaConfig, err := GetKeyAuthConfig(config.NewApplicationConfig(config.WithApiKeys([]string{"alice", "bob"})))
+	require.NoError(t, err)
+	store := newIdempotencyStore(time.Minute, time.Now)
+
+	runs := 0
+	app := fiber.New()
+	app.Use(v2keyauth.New(*kaConfig))
+	app.Use(store.handler)
+	app.Post("/v1/chat/completions", func(c *fiber.Ctx) error {
+		runs++
+		return c.JSON(fiber.Map{"run": runs})
+	})
+	app.Post("/v1/large", func(c *fiber.Ctx) error {
+		runs++
+		return c.SendString(strings.Repeat("a", maxIdempotentBodyBytes+1))
+	})
+
+	send := func(path, header, value string) (string, string) {
+		req := httptest.NewRequest("POST", path, strings.NewReader("{}"))
+		req.Header.Set(IdempotencyKeyHeader, "key")
+		req.Header.Set(header, value)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, 200, resp.StatusCode)
+		out, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(out), resp.Header.Get(IdempotentReplayedHeader)
+	}
+
+	body, _ := send("/v1/chat/completions", "x-api-key", "Bearer alice")
+	assert.Equal(t, `{"run":1}`, body)
+	// the same API key sent with another header shares the keys
+	body, replayed := send("/v1/chat/completions", fiber.HeaderAuthorization, "Bearer alice")
+	assert.Equal(t, `{"run":1}`, body)
+	assert.Equal(t, "true", replayed)
+	// other API keys do not
+	body, replayed = send("/v1/chat/completions", fiber.HeaderCookie, "token=bob")
+	assert.Equal(t, `{"run":2}`, body)
+	assert.Empty(t, replayed)
+
+	send("/v1/large", "xi-api-key", "Bearer alice")
+	_, replayed = send("/v1/large", "xi-api-key", "Bearer alice")
+	assert.Empty(t, replayed)
+	assert.Equal(t, 4, runs)
+}
+
+func TestIdempotencyPanic(t *testing.T) {
+	now := time.Now()
+	store := newIdempotencyStore(time.Minute, func() time.Time { return now })
+
+	runs := 0
+	app := fiber.New()
+	app.Use(recover.New())
+	app.Use(store.handler)
+	app.Post("/v1/chat/completions", func(c *fiber.Ctx) error {
+		runs++
+		if runs == 1 {
+			panic("boom")
+		}
+		return c.SendString("ok")
+	})
+
+	send := func() int {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader("{}"))
+		req.Header.Set(IdempotencyKeyHeader, "key")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, fiber.StatusInternalServerError, send())
+	// the key of the request which panicked is released
+	assert.Empty(t, store.responses)
+	assert.Equal(t, 200, send())
+	assert.Equal(t, 2, runs)
+}
+
+func TestIdempotencyLimits(t *testing.T) {
+	now := time.Now()
+	store := newIdempotencyStore(time.Minute, func() time.Time { return now })
+
+	runs := 0
+	app := fiber.New()
+	app.Use(store.handler)
+	app.Post("/v1/chat/completions", func(c *fiber.Ctx) error {
+		runs++
+		return c.SendString("ok")
+	})
+	send := func() string {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader("{}"))
+		req.Header.Set(IdempotencyKeyHeader, "key")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.Header.Get(IdempotentReplayedHeader)
+	}
+
+	// the pending requests which never completed expire with the window
+	for i := range maxIdempotentResponses {
+		store.responses[hashStrings("other", fmt.Sprint(i))] = &idempotentResponse{fingerprint: "x", expires: now.Add(time.Minute)}
+	}
+	// once the cap is reached, the requests are not stored
+	send()
+	assert.Empty(t, send())
+	assert.Equal(t, 2, runs)
+	assert.Len(t, store.responses, maxIdempotentResponses)
+
+	now = now.Add(time.Minute + time.Second)
+	send()
+	assert.Equal(t, "true", send())
+	assert.Equal(t, 3, runs)
+	assert.Len(t, store.responses, 1)
+}
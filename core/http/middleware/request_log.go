@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// ContextLogger sets a logger with the ID of the request in the user context of the request, so that the logs written
+// with fiberContext.Logger are correlated with the request log. It runs after the request ID is assigned
+func ContextLogger() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		logger := log.With().Str("request_id", c.GetRespHeader(fiber.HeaderXRequestID)).Logger()
+		c.SetUserContext(logger.WithContext(c.UserContext()))
+		return c.Next()
+	}
+}
+
+// RequestLogger returns the logger of the request logs, or a disabled one for the requests left out of the sample.
+// It is called once the request is served, so that the model and the status of the response are known
+func RequestLogger(logger zerolog.Logger, cl *config.BackendConfigLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) zerolog.Logger {
+	return func(c *fiber.Ctx) zerolog.Logger {
+		rate, logErrors := appConfig.RequestLogSampleRate, appConfig.RequestLogErrors
+		if cfg, ok := cl.GetBackendConfig(fiberContext.RequestModel(c)); ok {
+			if cfg.RequestLog.SampleRate != nil {
+				rate = *cfg.RequestLog.SampleRate
+			}
+			if cfg.RequestLog.AlwaysLogErrors != nil {
+				logErrors = *cfg.RequestLog.AlwaysLogErrors
+			}
+		}
+
+		if logErrors && c.Response().StatusCode() >= fiber.StatusBadRequest {
+			return logger
+		}
+		if sampleRequest(c.GetRespHeader(fiber.HeaderXRequestID), rate) {
+			return logger
+		}
+		return zerolog.Nop()
+	}
+}
+
+// sampleRequest tells if the request is in the sample. The decision is deterministic per request ID,
+// so that the logs of the same request are correlated across services sharing it
+func sampleRequest(requestID string, rate float64) bool {
+	switch {
+	case rate >= 1:
+		return true
+	case rate <= 0:
+		return false
+	case requestID == "":
+		return rand.Float64() < rate
+	}
+	h := fnv.New64a()
+	h.Write([]byte(requestID))
+	return float64(h.Sum64())/math.MaxUint64 < rate
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/contrib/fiberzerolog"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleRequest(t *testing.T) {
+	assert.True(t, sampleRequest("", 1))
+	assert.False(t, sampleRequest("", 0))
+
+	sampled := 0
+	for i := 0; i < 10000; i++ {
+		id := fmt.Sprintf("request-%d", i)
+		in := sampleRequest(id, 0.1)
+		// the same request is always in (or out of) the sample
+		assert.Equal(t, in, sampleRequest(id, 0.1))
+		if in {
+			sampled++
+			// and stays in it with higher rates
+			assert.True(t, sampleRequest(id, 0.5))
+		}
+	}
+	assert.InDelta(t, 1000, sampled, 150)
+}
+
+func TestRequestLogger(t *testing.T) {
+	modelPath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(modelPath, "quiet.yaml"), []byte("name: quiet\nrequest_log:\n  sample_rate: 0\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(modelPath, "silent.yaml"), []byte("name: silent\nrequest_log:\n  sample_rate: 0\n  always_log_errors: false\n"), 0600))
+	cl := config.NewBackendConfigLoader(modelPath)
+	require.NoError(t, cl.LoadBackendConfigsFromPath(modelPath))
+	ml := model.NewModelLoader(modelPath)
+
+	out := &bytes.Buffer{}
+	logger := zerolog.New(out)
+	app := fiber.New()
+	app.Use(requestid.New())
+	app.Use(fiberzerolog.New(fiberzerolog.Config{
+		Logger:    &logger,
+		GetLogger: RequestLogger(logger, cl, config.NewApplicationConfig()),
+	}))
+	app.Post("/:model/:status", func(c *fiber.Ctx) error {
+		if _, err := fiberContext.ModelFromContext(c, cl, ml, "", false); err != nil {
+			return err
+		}
+		status, _ := c.ParamsInt("status")
+		return c.SendStatus(status)
+	})
+
+	for _, tc := range []struct {
+		model  string
+		status int
+		logged bool
+	}{
+		{model: "other", status: 200, logged: true},
+		{model: "quiet", status: 200, logged: false},
+		{model: "quiet", status: 500, logged: true},
+		{model: "silent", status: 500, logged: false},
+	} {
+		out.Reset()
+		resp, err := app.Test(httptest.NewRequest("POST", fmt.Sprintf("/%s/%d", tc.model, tc.status), nil))
+		require.NoError(t, err)
+		require.Equal(t, tc.status, resp.StatusCode)
+		assert.Equal(t, tc.logged, strings.Contains(out.String(), tc.model), "%s %d", tc.model, tc.status)
+	}
+}
+
+func TestContextLogger(t *testing.T) {
+	out := &bytes.Buffer{}
+	global := log.Logger
+	log.Logger = zerolog.New(out)
+	defer func() { log.Logger = global }()
+
+	app := fiber.New()
+	app.Use(requestid.New())
+	app.Use(ContextLogger())
+	app.Get("/", func(c *fiber.Ctx) error {
+		fiberContext.Logger(c).Info().Msg("handling the request")
+		return nil
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(fiber.HeaderXRequestID, "a-request-id")
+	_, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), `"request_id":"a-request-id"`)
+	assert.Contains(t, out.String(), "handling the request")
+}
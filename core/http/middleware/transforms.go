@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/rs/zerolog/log"
+)
+
+// ResponseTransforms rewrites the JSON responses of the requests to the models with response transforms, recorded
+// by the endpoints while handling the request. The errors and the streamed responses are returned as they are
+func ResponseTransforms(c *fiber.Ctx) error {
+	err := c.Next()
+	transforms := fiberContext.ResponseTransforms(c)
+	if err != nil || len(transforms) == 0 || c.Response().IsBodyStream() ||
+		c.Response().StatusCode() >= fiber.StatusBadRequest ||
+		!strings.HasPrefix(string(c.Response().Header.ContentType()), fiber.MIMEApplicationJSON) {
+		return err
+	}
+
+	body := c.Response().Body()
+	for _, t := range transforms {
+		if t.Response == "" {
+			continue
+		}
+		transformed, err := t.TransformResponse(body)
+		if err != nil {
+			if t.FailOnError {
+				return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("the response transformation failed: %s", err))
+			}
+			log.Warn().Err(err).Str("path", c.Path()).Msg("the response transformation failed, returning the response as it is")
+			continue
+		}
+		body = transformed
+	}
+	c.Response().SetBody(body)
+	return nil
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseTransforms(t *testing.T) {
+	app := fiber.New()
+	app.Use(ResponseTransforms)
+	respond := func(transforms []config.Transform, status int) fiber.Handler {
+		return func(c *fiber.Ctx) error {
+			fiberContext.SetResponseTransforms(c, transforms)
+			return c.Status(status).JSON(fiber.Map{"choices": []fiber.Map{{"text": "Hi"}}})
+		}
+	}
+	app.Post("/plain", respond(nil, fiber.StatusOK))
+	app.Post("/transformed", respond([]config.Transform{{Response: `{text: .choices[0].text}`}, {Request: "."}, {Response: `.text |= ascii_upcase`}}, fiber.StatusOK))
+	app.Post("/failed", respond([]config.Transform{{Response: `{text: .choices[0].text}`}}, fiber.StatusBadRequest))
+	app.Post("/broken", respond([]config.Transform{{Response: `error("broken")`}}, fiber.StatusOK))
+	app.Post("/strict", respond([]config.Transform{{Response: `error("broken")`, FailOnError: true}}, fiber.StatusOK))
+
+	send := func(path string) (int, string) {
+		resp, err := app.Test(httptest.NewRequest("POST", path, nil))
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	status, body := send("/plain")
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.JSONEq(t, `{"choices": [{"text": "Hi"}]}`, body)
+
+	// the response transforms are applied in order
+	status, body = send("/transformed")
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.JSONEq(t, `{"text": "HI"}`, body)
+
+	// the errors are returned as they are
+	status, body = send("/failed")
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	assert.JSONEq(t, `{"choices": [{"text": "Hi"}]}`, body)
+
+	// the failed transformations are skipped, unless they fail the request
+	status, body = send("/broken")
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.JSONEq(t, `{"choices": [{"text": "Hi"}]}`, body)
+
+	status, body = send("/strict")
+	assert.Equal(t, fiber.StatusInternalServerError, status)
+	assert.Contains(t, body, "the response transformation failed")
+}
@@ -65,8 +65,13 @@ func RegisterLocalAIRoutes(router *fiber.App,
 	})
 
 	router.Get("/system", localai.SystemInformations(ml, appConfig))
+	router.Get("/debug/models/:name", localai.ModelDebugEndpoint(cl))
 
 	// misc
 	router.Post("/v1/tokenize", localai.TokenizeEndpoint(cl, ml, appConfig))
+	router.Post("/v1/completions/branches", localai.BranchesEndpoint(cl, ml, appConfig))
+
+	// raw passthrough to the backends
+	router.All("/v1/passthrough/:model/*", localai.PassthroughEndpoint(cl, appConfig))
 
 }
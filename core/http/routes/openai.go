@@ -30,6 +30,25 @@ func RegisterOpenAIRoutes(app *fiber.App,
 		),
 	)
 
+	// responses
+	app.Post("/v1/responses",
+		openai.ResponsesEndpoint(
+			application.BackendLoader(),
+			application.ModelLoader(),
+			application.TemplatesEvaluator(),
+			application.ApplicationConfig(),
+		),
+	)
+
+	app.Post("/responses",
+		openai.ResponsesEndpoint(
+			application.BackendLoader(),
+			application.ModelLoader(),
+			application.TemplatesEvaluator(),
+			application.ApplicationConfig(),
+		),
+	)
+
 	// edit
 	app.Post("/v1/edits",
 		openai.EditEndpoint(
@@ -81,6 +100,17 @@ func RegisterOpenAIRoutes(app *fiber.App,
 	app.Get("/v1/files/:file_id/content", openai.GetFilesContentsEndpoint(application.BackendLoader(), application.ApplicationConfig()))
 	app.Get("/files/:file_id/content", openai.GetFilesContentsEndpoint(application.BackendLoader(), application.ApplicationConfig()))
 
+	// replays
+	app.Post("/v1/replays",
+		openai.ReplayEndpoint(
+			application.BackendLoader(),
+			application.ModelLoader(),
+			application.TemplatesEvaluator(),
+			application.ApplicationConfig(),
+		),
+	)
+	app.Get("/v1/replays/:replay_id", openai.GetReplayEndpoint(application.ApplicationConfig()))
+
 	// completion
 	app.Post("/v1/completions",
 		openai.CompletionEndpoint(
@@ -116,6 +146,7 @@ func RegisterOpenAIRoutes(app *fiber.App,
 
 	// audio
 	app.Post("/v1/audio/transcriptions", openai.TranscriptEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Get("/v1/audio/transcriptions/stream", openai.TranscriptStreamEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
 	app.Post("/v1/audio/speech", localai.TTSEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
 
 	// images
@@ -132,4 +163,5 @@ func RegisterOpenAIRoutes(app *fiber.App,
 	// List models
 	app.Get("/v1/models", openai.ListModelsEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
 	app.Get("/models", openai.ListModelsEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Get("/v1/models/:model", openai.ModelDetailEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
 }
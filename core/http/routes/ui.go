@@ -8,10 +8,12 @@ import (
 
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/gallery"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
 	"github.com/mudler/LocalAI/core/http/elements"
 	"github.com/mudler/LocalAI/core/http/endpoints/localai"
 	"github.com/mudler/LocalAI/core/http/utils"
 	"github.com/mudler/LocalAI/core/p2p"
+	"github.com/mudler/LocalAI/core/schema"
 	"github.com/mudler/LocalAI/core/services"
 	"github.com/mudler/LocalAI/internal"
 	"github.com/mudler/LocalAI/pkg/model"
@@ -23,6 +25,26 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// renderUIError renders the error page for the failures which prevent a page from being rendered, with the reason of
+// the failure and a link to retry. The clients expecting JSON get an error response instead
+func renderUIError(c *fiber.Ctx, status int, message string, err error) error {
+	log.Error().Err(err).Str("path", c.Path()).Msg(message)
+	if string(c.Context().Request.Header.ContentType()) == "application/json" || len(c.Accepts("html")) == 0 {
+		return c.Status(status).JSON(schema.ErrorResponse{
+			Error: &schema.APIError{Message: message + " " + err.Error(), Code: status},
+		})
+	}
+	return c.Status(status).Render("views/error", fiber.Map{
+		"Title":        "LocalAI - Error",
+		"BaseURL":      utils.BaseURL(c),
+		"Version":      internal.PrintableVersion(),
+		"IsP2PEnabled": p2p.IsP2PEnabled(),
+		"Message":      message,
+		"Error":        err.Error(),
+		"RetryURL":     c.OriginalURL(),
+	})
+}
+
 type modelOpCache struct {
 	status *xsync.SyncedMap[string, string]
 }
@@ -87,6 +109,7 @@ func RegisterUIRoutes(app *fiber.App,
 	}
 
 	app.Get("/", localai.WelcomeEndpoint(appConfig, cl, ml, modelStatus))
+	app.Get("/api/summary", localai.SummaryEndpoint(cl, ml, modelStatus))
 
 	if p2p.IsP2PEnabled() {
 		app.Get("/p2p", func(c *fiber.Ctx) error {
@@ -127,7 +150,10 @@ func RegisterUIRoutes(app *fiber.App,
 		app.Get("/browse", func(c *fiber.Ctx) error {
 			term := c.Query("term")
 
-			models, _ := gallery.AvailableGalleryModels(appConfig.Galleries, appConfig.ModelPath)
+			models, err := gallery.AvailableGalleryModels(appConfig.Galleries, appConfig.ModelPath)
+			if err != nil {
+				return renderUIError(c, fiber.StatusBadGateway, "The model galleries could not be fetched.", err)
+			}
 
 			// Get all available tags
 			allTags := map[string]struct{}{}
@@ -178,7 +204,11 @@ func RegisterUIRoutes(app *fiber.App,
 				return c.Status(fiber.StatusBadRequest).SendString(bluemonday.StrictPolicy().Sanitize(err.Error()))
 			}
 
-			models, _ := gallery.AvailableGalleryModels(appConfig.Galleries, appConfig.ModelPath)
+			models, err := gallery.AvailableGalleryModels(appConfig.Galleries, appConfig.ModelPath)
+			if err != nil {
+				// the search results are a fragment of the page, which shows no results
+				log.Warn().Err(err).Msg("failed fetching the model galleries")
+			}
 
 			return c.SendString(elements.ListModels(gallery.GalleryModels(models).Search(form.Search), processingModels, galleryService))
 		})
@@ -307,7 +337,11 @@ func RegisterUIRoutes(app *fiber.App,
 
 	// Show the Chat page
 	app.Get("/chat/:model", func(c *fiber.Ctx) error {
-		backendConfigs, _ := services.ListModels(cl, ml, config.NoFilterFn, services.SKIP_IF_CONFIGURED)
+		backendConfigs, err := services.ListModels(cl, ml, config.NoFilterFn, services.SKIP_IF_CONFIGURED)
+		if err != nil {
+			return renderUIError(c, fiber.StatusInternalServerError, "The models could not be listed.", err)
+		}
+		backendConfigs = fiberContext.FilterAllowedModels(c, backendConfigs)
 
 		summary := fiber.Map{
 			"Title":        "LocalAI - Chat with " + c.Params("model"),
@@ -316,6 +350,7 @@ func RegisterUIRoutes(app *fiber.App,
 			"Model":        c.Params("model"),
 			"Version":      internal.PrintableVersion(),
 			"IsP2PEnabled": p2p.IsP2PEnabled(),
+			"Examples":     modelExamples(cl, c.Params("model")),
 		}
 
 		// Render index
@@ -323,7 +358,11 @@ func RegisterUIRoutes(app *fiber.App,
 	})
 
 	app.Get("/talk/", func(c *fiber.Ctx) error {
-		backendConfigs, _ := services.ListModels(cl, ml, config.NoFilterFn, services.SKIP_IF_CONFIGURED)
+		backendConfigs, err := services.ListModels(cl, ml, config.NoFilterFn, services.SKIP_IF_CONFIGURED)
+		if err != nil {
+			return renderUIError(c, fiber.StatusInternalServerError, "The models could not be listed.", err)
+		}
+		backendConfigs = fiberContext.FilterAllowedModels(c, backendConfigs)
 
 		if len(backendConfigs) == 0 {
 			// If no model is available redirect to the index which suggests how to install models
@@ -345,7 +384,11 @@ func RegisterUIRoutes(app *fiber.App,
 
 	app.Get("/chat/", func(c *fiber.Ctx) error {
 
-		backendConfigs, _ := services.ListModels(cl, ml, config.NoFilterFn, services.SKIP_IF_CONFIGURED)
+		backendConfigs, err := services.ListModels(cl, ml, config.NoFilterFn, services.SKIP_IF_CONFIGURED)
+		if err != nil {
+			return renderUIError(c, fiber.StatusInternalServerError, "The models could not be listed.", err)
+		}
+		backendConfigs = fiberContext.FilterAllowedModels(c, backendConfigs)
 
 		if len(backendConfigs) == 0 {
 			// If no model is available redirect to the index which suggests how to install models
@@ -359,6 +402,7 @@ func RegisterUIRoutes(app *fiber.App,
 			"Model":        backendConfigs[0],
 			"Version":      internal.PrintableVersion(),
 			"IsP2PEnabled": p2p.IsP2PEnabled(),
+			"Examples":     modelExamples(cl, backendConfigs[0]),
 		}
 
 		// Render index
@@ -366,7 +410,7 @@ func RegisterUIRoutes(app *fiber.App,
 	})
 
 	app.Get("/text2image/:model", func(c *fiber.Ctx) error {
-		backendConfigs := cl.GetAllBackendConfigs()
+		backendConfigs := allowedBackendConfigs(c, cl.GetAllBackendConfigs())
 
 		summary := fiber.Map{
 			"Title":        "LocalAI - Generate images with " + c.Params("model"),
@@ -383,7 +427,7 @@ func RegisterUIRoutes(app *fiber.App,
 
 	app.Get("/text2image/", func(c *fiber.Ctx) error {
 
-		backendConfigs := cl.GetAllBackendConfigs()
+		backendConfigs := allowedBackendConfigs(c, cl.GetAllBackendConfigs())
 
 		if len(backendConfigs) == 0 {
 			// If no model is available redirect to the index which suggests how to install models
@@ -404,7 +448,7 @@ func RegisterUIRoutes(app *fiber.App,
 	})
 
 	app.Get("/tts/:model", func(c *fiber.Ctx) error {
-		backendConfigs := cl.GetAllBackendConfigs()
+		backendConfigs := allowedBackendConfigs(c, cl.GetAllBackendConfigs())
 
 		summary := fiber.Map{
 			"Title":        "LocalAI - Generate images with " + c.Params("model"),
@@ -421,7 +465,7 @@ func RegisterUIRoutes(app *fiber.App,
 
 	app.Get("/tts/", func(c *fiber.Ctx) error {
 
-		backendConfigs := cl.GetAllBackendConfigs()
+		backendConfigs := allowedBackendConfigs(c, cl.GetAllBackendConfigs())
 
 		if len(backendConfigs) == 0 {
 			// If no model is available redirect to the index which suggests how to install models
@@ -441,3 +485,23 @@ func RegisterUIRoutes(app *fiber.App,
 		return c.Render("views/tts", summary)
 	})
 }
+
+// modelExamples returns the example requests of the model configuration, if any
+func modelExamples(cl *config.BackendConfigLoader, model string) []schema.RequestExample {
+	cfg, exists := cl.GetBackendConfig(model)
+	if !exists {
+		return nil
+	}
+	return cfg.Examples
+}
+
+// allowedBackendConfigs returns the model configurations the API key of the request is allowed to use
+func allowedBackendConfigs(c *fiber.Ctx, cfgs []config.BackendConfig) []config.BackendConfig {
+	allowed := []config.BackendConfig{}
+	for _, cfg := range cfgs {
+		if fiberContext.ModelAllowed(c, cfg.Name) {
+			allowed = append(allowed, cfg)
+		}
+	}
+	return allowed
+}
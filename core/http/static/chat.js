@@ -157,10 +157,19 @@ function readInputImage() {
     });
 
     if (!response.ok) {
-      Alpine.store("chat").add(
-        "assistant",
-        `<span class='error'>Error: POST /v1/chat/completions ${response.status}</span>`,
-      );
+      let message = `POST /v1/chat/completions ${response.status}`;
+      try {
+        const jsonData = await response.json();
+        if (jsonData.error && jsonData.error.message) {
+          message = jsonData.error.message;
+        }
+      } catch (error) {
+        console.error("Failed to parse the error response:", error);
+      }
+      const span = document.createElement("span");
+      span.className = "error";
+      span.textContent = `Error: ${message}`;
+      Alpine.store("chat").add("assistant", span.outerHTML);
       return;
     }
 
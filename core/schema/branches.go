@@ -0,0 +1,41 @@
+package schema
+
+// BranchesRequest generates a completion up to a branch point, then alternative continuations from it.
+// The branch point is set with either BranchAt or BranchMarker
+type BranchesRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+
+	// BranchAt branches the completion after the given number of generated tokens (0 branches from the prompt)
+	BranchAt *int `json:"branch_at,omitempty"`
+	// BranchMarker branches the completion right after the first occurrence of the marker in the generated text
+	BranchMarker string `json:"branch_marker,omitempty"`
+	// MaxTokens is the maximum number of tokens generated looking for the marker
+	MaxTokens int `json:"max_tokens,omitempty"`
+
+	// N is the number of branches
+	N int `json:"n,omitempty"`
+	// BranchMaxTokens is the maximum number of tokens of each branch
+	BranchMaxTokens int      `json:"branch_max_tokens,omitempty"`
+	Temperature     *float64 `json:"temperature,omitempty"`
+}
+
+type Branch struct {
+	Index int    `json:"index"`
+	Text  string `json:"text"`
+	// Logprob is the sum of the log-probabilities of the tokens of the branch, null if the backend does not report it
+	Logprob *float64 `json:"logprob"`
+	Tokens  int      `json:"tokens"`
+}
+
+type BranchesResponse struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int    `json:"created"`
+	Model   string `json:"model"`
+
+	// Prefix is the text generated up to the branch point, shared by all the branches
+	Prefix   string      `json:"prefix"`
+	Branches []Branch    `json:"branches"`
+	Usage    OpenAIUsage `json:"usage"`
+}
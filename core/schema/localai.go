@@ -32,6 +32,14 @@ type TTSRequest struct {
 	Backend  string `json:"backend" yaml:"backend"`
 	Language string `json:"language,omitempty" yaml:"language,omitempty"`               // (optional) language to use with TTS model
 	Format   string `json:"response_format,omitempty" yaml:"response_format,omitempty"` // (optional) output format
+	// (optional) reference sample of the voice to clone, in base64 or as a data URI
+	VoiceSample string `json:"voice_sample,omitempty" yaml:"voice_sample,omitempty"`
+	// (optional) id of the cloned voice, to store the sample under or to reuse a stored sample
+	VoiceID string `json:"voice_id,omitempty" yaml:"voice_id,omitempty"`
+	// (optional) stream the audio in chunks of chunk_size bytes, or lasting chunk_duration seconds
+	Stream        bool    `json:"stream,omitempty" yaml:"stream,omitempty"`
+	ChunkSize     int     `json:"chunk_size,omitempty" yaml:"chunk_size,omitempty"`
+	ChunkDuration float64 `json:"chunk_duration,omitempty" yaml:"chunk_duration,omitempty"`
 }
 
 // @Description VAD request body
@@ -84,9 +92,127 @@ type P2PNodesResponse struct {
 
 type SysInfoModel struct {
 	ID string `json:"id"`
+	// TensorSplit and MainGPU are the GPU settings the model was loaded with, if any
+	TensorSplit string `json:"tensor_split,omitempty"`
+	MainGPU     string `json:"main_gpu,omitempty"`
+	// TokenCacheEntries and TokenCacheBytes are the number of texts whose tokens are cached for the model, and their size
+	TokenCacheEntries int `json:"token_cache_entries"`
+	TokenCacheBytes   int `json:"token_cache_bytes"`
+	// Resources are the CPU and memory limits of the backend of the model, if any
+	Resources *ModelResources `json:"resources,omitempty"`
+	// RopeScaling is the rope scaling applied automatically to extend the context size of the model, if any
+	RopeScaling *RopeScalingInfo `json:"rope_scaling,omitempty"`
+}
+
+type ModelResources struct {
+	CPUs     float64 `json:"cpus,omitempty"`
+	MemoryMB int     `json:"memory_mb,omitempty"`
+	// Enforced is false when the limits could not be set, e.g. without cgroups
+	Enforced bool `json:"enforced"`
 }
 
 type SystemInformationResponse struct {
 	Backends []string       `json:"backends"`
 	Models   []SysInfoModel `json:"loaded_models"`
 }
+
+// ModelDebugResponse exposes the effective settings of a model configuration, as derived when loading it
+type ModelDebugResponse struct {
+	Name             string   `json:"name"`
+	Backend          string   `json:"backend,omitempty"`
+	StopWords        []string `json:"stopwords"`
+	DerivedStopWords []string `json:"derived_stopwords"`
+
+	RopeScaling *RopeScalingInfo `json:"rope_scaling,omitempty"`
+
+	TemperatureSchedule *TemperatureScheduleInfo `json:"temperature_schedule,omitempty"`
+}
+
+// TemperatureScheduleInfo describes the temperature schedule of a model, and if its backend applies it
+type TemperatureScheduleInfo struct {
+	Type      string             `json:"type"`
+	Points    []TemperaturePoint `json:"points"`
+	Supported bool               `json:"supported"`
+}
+
+// TemperaturePoint is the temperature used from a number of generated tokens
+type TemperaturePoint struct {
+	Tokens      int     `json:"tokens"`
+	Temperature float64 `json:"temperature"`
+}
+
+// RopeScalingInfo describes the rope scaling applied automatically to extend the context size of a model
+type RopeScalingInfo struct {
+	Method             string  `json:"method"`
+	Factor             float32 `json:"factor"`
+	FreqScale          float32 `json:"freq_scale"`
+	TrainedContextSize int     `json:"trained_context_size"`
+	ContextSize        int     `json:"context_size"`
+}
+
+// StreamHeartbeat is the progress of a streamed generation, sent periodically between the chunks
+type StreamHeartbeat struct {
+	Object string `json:"object"`
+	// Tokens is the number of generated tokens. It is estimated from the number of chunks if the backend does not report it
+	Tokens          int     `json:"tokens"`
+	Estimated       bool    `json:"estimated"`
+	ElapsedMS       int64   `json:"elapsed_ms"`
+	TokensPerSecond float64 `json:"tokens_per_second"`
+}
+
+// StreamLoading is the progress of the load of the model of a streamed completion, sent before its first chunk
+type StreamLoading struct {
+	Object    string `json:"object"`
+	Model     string `json:"model"`
+	ElapsedMS int64  `json:"elapsed_ms"`
+	// EstimatedMS is the expected load time, from the recent loads, and Progress the fraction of it elapsed.
+	// They are unset when the model was never loaded, the progress being indeterminate
+	EstimatedMS   int64    `json:"estimated_ms,omitempty"`
+	Progress      *float64 `json:"progress,omitempty"`
+	Indeterminate bool     `json:"indeterminate"`
+	// Done is set in the last event, sent once the model is loaded
+	Done bool `json:"done"`
+}
+
+// CapabilitiesSchemaVersion is the version of the schema of the capabilities manifest, increased on breaking changes
+const CapabilitiesSchemaVersion = 1
+
+// CapabilitiesManifest describes what the LocalAI instance supports, for the service discovery of clients and gateways
+type CapabilitiesManifest struct {
+	SchemaVersion int                 `json:"schema_version"`
+	Version       string              `json:"version"`
+	Endpoints     []CapabilitiesRoute `json:"endpoints"`
+	Features      map[string]bool     `json:"features"`
+	Limits        CapabilitiesLimits  `json:"limits"`
+	Models        int                 `json:"models"`
+	// APIVersions are the versions of the responses the clients can pin
+	APIVersions CapabilitiesAPIVersions `json:"api_versions"`
+}
+
+// LatestAPIVersion is the version of the shape of the responses of the OpenAI compatible endpoints. The clients can
+// pin an older one with the X-LocalAI-API-Version header:
+//   - 1: the OpenAI shape, without the LocalAI extensions (the metadata, the timings in the usage and the token
+//     counts of the embeddings)
+//   - 2: with the LocalAI extensions
+const LatestAPIVersion = 2
+
+// CapabilitiesAPIVersions describes the versions of the responses: the header pinning them, the versions supported
+// and the version of the requests without the header
+type CapabilitiesAPIVersions struct {
+	Header    string `json:"header"`
+	Supported []int  `json:"supported"`
+	Default   int    `json:"default"`
+}
+
+type CapabilitiesRoute struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+// CapabilitiesLimits are the limits of the requests, omitted when not limited
+type CapabilitiesLimits struct {
+	UploadLimitMB     int `json:"upload_limit_mb,omitempty"`
+	ContextSize       int `json:"context_size,omitempty"`
+	MaxImageDimension int `json:"max_image_dimension,omitempty"`
+	MaxImageCount     int `json:"max_image_count,omitempty"`
+}
@@ -13,6 +13,10 @@ type APIError struct {
 	Message string  `json:"message"`
 	Param   *string `json:"param,omitempty"`
 	Type    string  `json:"type"`
+	// Metadata is LocalAI specific and carries the details of some errors
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	// RequestID is LocalAI specific, the X-Request-ID of the request which failed
+	RequestID string `json:"request_id,omitempty"`
 }
 
 type ErrorResponse struct {
@@ -26,12 +30,34 @@ type OpenAIUsage struct {
 	// Extra timing data, disabled by default as is't not a part of OpenAI specification
 	TimingPromptProcessing float64 `json:"timing_prompt_processing,omitempty"`
 	TimingTokenGeneration  float64 `json:"timing_token_generation,omitempty"`
+	// TimingFirstToken is the time to the first token of the output, in milliseconds, measured by LocalAI
+	TimingFirstToken float64 `json:"timing_first_token,omitempty"`
+
+	CompletionTokensDetails *CompletionTokensDetails `json:"completion_tokens_details,omitempty"`
+}
+
+type CompletionTokensDetails struct {
+	ReasoningTokens int `json:"reasoning_tokens"`
+
+	// ContentTokens and ToolArgumentsTokens are returned when the request sets a token budget
+	ContentTokens       int `json:"content_tokens,omitempty"`
+	ToolArgumentsTokens int `json:"tool_arguments_tokens,omitempty"`
+}
+
+// TokenBudget splits the output tokens of a request across the reasoning, the content and the tool call
+// arguments, so that one does not starve the others. A zero budget is unlimited
+type TokenBudget struct {
+	Reasoning     int `json:"reasoning,omitempty" yaml:"reasoning"`
+	Content       int `json:"content,omitempty" yaml:"content"`
+	ToolArguments int `json:"tool_arguments,omitempty" yaml:"tool_arguments"`
 }
 
 type Item struct {
 	Embedding []float32 `json:"embedding"`
 	Index     int       `json:"index"`
 	Object    string    `json:"object,omitempty"`
+	// PromptTokens is the number of tokens of the input of the embedding, when counted
+	PromptTokens int `json:"prompt_tokens,omitempty"`
 
 	// Images
 	URL     string `json:"url,omitempty"`
@@ -47,6 +73,10 @@ type OpenAIResponse struct {
 	Data    []Item   `json:"data,omitempty"`
 
 	Usage OpenAIUsage `json:"usage"`
+
+	// Metadata is LocalAI specific (not part of the OpenAI spec) and carries
+	// additional information about how the request was processed
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
 }
 
 type Choice struct {
@@ -79,6 +109,9 @@ type Message struct {
 	// The message content
 	Content interface{} `json:"content" yaml:"content"`
 
+	// The reasoning of reasoning models, when separated from the content
+	ReasoningContent *string `json:"reasoning_content,omitempty" yaml:"reasoning_content,omitempty"`
+
 	StringContent string   `json:"string_content,omitempty" yaml:"string_content,omitempty"`
 	StringImages  []string `json:"string_images,omitempty" yaml:"string_images,omitempty"`
 	StringVideos  []string `json:"string_videos,omitempty" yaml:"string_videos,omitempty"`
@@ -88,6 +121,29 @@ type Message struct {
 	FunctionCall interface{} `json:"function_call,omitempty" yaml:"function_call,omitempty"`
 
 	ToolCalls []ToolCall `json:"tool_calls,omitempty" yaml:"tool_call,omitempty"`
+
+	// The audio output, e.g. of pipeline models ending with a text to speech stage
+	Audio *MessageAudio `json:"audio,omitempty" yaml:"audio,omitempty"`
+
+	// Citations of the sources parsed from the output of the models configured with citations
+	Citations []Citation `json:"citations,omitempty" yaml:"citations,omitempty"`
+}
+
+// Citation is a reference to a source in the content of a message
+type Citation struct {
+	SourceID string `json:"source_id" yaml:"source_id"`
+	// Start and End are the offsets (in characters) of the cited text in the content
+	Start int    `json:"start" yaml:"start"`
+	End   int    `json:"end" yaml:"end"`
+	Text  string `json:"text" yaml:"text"`
+}
+
+type MessageAudio struct {
+	ID string `json:"id"`
+	// Base64 encoded audio
+	Data       string `json:"data"`
+	Format     string `json:"format"`
+	Transcript string `json:"transcript"`
 }
 
 type ToolCall struct {
@@ -105,6 +161,32 @@ type FunctionCall struct {
 type OpenAIModel struct {
 	ID     string `json:"id"`
 	Object string `json:"object"`
+	// The SHA-256 of the chat template, if enabled
+	ChatTemplateHash string `json:"chat_template_hash,omitempty"`
+	// Where the chat template comes from: config, gguf or guessed
+	ChatTemplateSource string `json:"chat_template_source,omitempty"`
+}
+
+// ModelDetailResponse describes a single model.
+// Other than the OpenAI fields, it carries LocalAI-specific information taken from the model configuration
+type ModelDetailResponse struct {
+	OpenAIModel
+
+	Backend     string `json:"backend,omitempty"`
+	Description string `json:"description,omitempty"`
+	Usage       string `json:"usage,omitempty"`
+
+	// Examples are curated example requests showing how to call the model
+	Examples []RequestExample `json:"examples,omitempty"`
+}
+
+// RequestExample is an example request for a model
+type RequestExample struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description"`
+	// Endpoint is the path the request is sent to, e.g. /v1/chat/completions
+	Endpoint string                 `json:"endpoint" yaml:"endpoint"`
+	Request  map[string]interface{} `json:"request" yaml:"request"`
 }
 
 type DeleteAssistantResponse struct {
@@ -169,6 +251,10 @@ type OpenAIRequest struct {
 	ResponseFormat interface{} `json:"response_format,omitempty"`
 	// image
 	Size string `json:"size"`
+	// Dimensions truncates the embeddings, for models trained with Matryoshka representation learning
+	Dimensions int `json:"dimensions,omitempty" yaml:"dimensions"`
+	// Normalize re-normalizes the truncated embeddings (default: true)
+	Normalize *bool `json:"normalize,omitempty" yaml:"normalize"`
 	// Prompt is read only by completion/image API calls
 	Prompt interface{} `json:"prompt" yaml:"prompt"`
 
@@ -190,9 +276,23 @@ type OpenAIRequest struct {
 
 	Stream bool `json:"stream"`
 
+	// Store is the consent of the user to record the request and its answer in the dataset of the model, if any
+	Store bool `json:"store,omitempty" yaml:"store"`
+
+	// TokenBudget splits the output tokens across reasoning, content and tool arguments
+	TokenBudget *TokenBudget `json:"token_budget,omitempty" yaml:"token_budget"`
+
+	// ReasoningEffort of reasoning models: low, medium or high
+	ReasoningEffort string `json:"reasoning_effort,omitempty" yaml:"reasoning_effort"`
+
+	// ResponseLanguage forces the language of the answer (ISO 639-1 code)
+	ResponseLanguage string `json:"response_language,omitempty" yaml:"response_language"`
+
 	// Image (not supported by OpenAI)
 	Mode int `json:"mode"`
 	Step int `json:"step"`
+	// ResponseEncoding of the generated images: png (default), jpeg or webp
+	ResponseEncoding string `json:"response_encoding,omitempty" yaml:"response_encoding"`
 
 	// A grammar to constrain the LLM output
 	Grammar string `json:"grammar" yaml:"grammar"`
@@ -201,6 +301,9 @@ type OpenAIRequest struct {
 
 	Backend string `json:"backend" yaml:"backend"`
 
+	// EnsembleDetails returns the outputs of the members of ensemble models in the response metadata
+	EnsembleDetails bool `json:"ensemble_details,omitempty" yaml:"ensemble_details"`
+
 	// AutoGPTQ
 	ModelBaseName string `json:"model_base_name" yaml:"model_base_name"`
 }
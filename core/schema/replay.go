@@ -0,0 +1,50 @@
+package schema
+
+// ReplayResult is the output of a request replayed against a model
+type ReplayResult struct {
+	// Key identifies the request across replays: its custom_id, or its line in the log
+	Key      string `json:"key"`
+	Line     int    `json:"line"`
+	Endpoint string `json:"endpoint"`
+	Output   string `json:"output"`
+	// Expected is the assistant reply recorded in the log, for fine-tuning logs
+	Expected   string      `json:"expected,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Usage      OpenAIUsage `json:"usage"`
+	DurationMS int64       `json:"duration_ms"`
+}
+
+// ReplayChange is a request whose output differs from the baseline
+type ReplayChange struct {
+	Key      string `json:"key"`
+	Baseline string `json:"baseline"`
+	Output   string `json:"output"`
+}
+
+// ReplayDiff summarizes the differences of a replay from a baseline replay
+type ReplayDiff struct {
+	Baseline  string `json:"baseline"`
+	Compared  int    `json:"compared"`
+	Unchanged int    `json:"unchanged"`
+	Changed   int    `json:"changed"`
+	// Added and Removed are the requests found only in the replay, or only in the baseline
+	Added   int            `json:"added"`
+	Removed int            `json:"removed"`
+	Changes []ReplayChange `json:"changes"`
+}
+
+// ReplayReport is a log of requests replayed against a model, stored to be compared across model versions
+type ReplayReport struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int    `json:"created"`
+	Model   string `json:"model"`
+	Seed    int    `json:"seed"`
+
+	Succeeded int            `json:"succeeded"`
+	Failed    int            `json:"failed"`
+	Results   []ReplayResult `json:"results"`
+	Usage     OpenAIUsage    `json:"usage"`
+
+	Diff *ReplayDiff `json:"diff,omitempty"`
+}
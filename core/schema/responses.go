@@ -0,0 +1,110 @@
+package schema
+
+// ResponsesRequest is the request of the OpenAI Responses API https://platform.openai.com/docs/api-reference/responses/create
+type ResponsesRequest struct {
+	Model string `json:"model"`
+
+	// Input is either a string or a list of input items
+	Input        interface{} `json:"input"`
+	Instructions string      `json:"instructions,omitempty"`
+
+	Tools      []ResponsesTool `json:"tools,omitempty"`
+	ToolChoice interface{}     `json:"tool_choice,omitempty"`
+
+	Stream          bool     `json:"stream,omitempty"`
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"top_p,omitempty"`
+	MaxOutputTokens *int     `json:"max_output_tokens,omitempty"`
+
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// ResponsesTool is a (function) tool definition of the Responses API
+type ResponsesTool struct {
+	Type        string                 `json:"type"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+	Strict      bool                   `json:"strict,omitempty"`
+}
+
+// ResponsesInputItem is an item of the Responses API input list.
+// Messages have a role and a content, function calls and their outputs have a call id.
+type ResponsesInputItem struct {
+	Type string `json:"type,omitempty"`
+
+	Role string `json:"role,omitempty"`
+	// Content is either a string or a list of content parts
+	Content interface{} `json:"content,omitempty"`
+
+	CallID    string `json:"call_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	Output    string `json:"output,omitempty"`
+}
+
+// ResponsesContentPart is a content part of an input message or of an output message
+type ResponsesContentPart struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+
+	// input_image
+	ImageURL string `json:"image_url,omitempty"`
+
+	// output_text
+	Annotations []interface{} `json:"annotations,omitempty"`
+}
+
+// ResponsesOutputItem is an item of the Responses API output: a message or a function call
+type ResponsesOutputItem struct {
+	Type   string `json:"type"`
+	ID     string `json:"id"`
+	Status string `json:"status"`
+
+	// message
+	Role    string                 `json:"role,omitempty"`
+	Content []ResponsesContentPart `json:"content,omitempty"`
+
+	// function_call
+	CallID    string `json:"call_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+}
+
+type ResponsesUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// ResponsesResponse is the response object of the Responses API
+type ResponsesResponse struct {
+	ID           string                `json:"id"`
+	Object       string                `json:"object"`
+	CreatedAt    int                   `json:"created_at"`
+	Status       string                `json:"status"`
+	Model        string                `json:"model"`
+	Instructions string                `json:"instructions,omitempty"`
+	Output       []ResponsesOutputItem `json:"output"`
+	Usage        *ResponsesUsage       `json:"usage,omitempty"`
+
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// ResponsesStreamEvent is a server-sent event of a streamed Responses API call
+type ResponsesStreamEvent struct {
+	Type           string `json:"type"`
+	SequenceNumber int    `json:"sequence_number"`
+
+	Response *ResponsesResponse `json:"response,omitempty"`
+
+	OutputIndex  *int                  `json:"output_index,omitempty"`
+	ContentIndex *int                  `json:"content_index,omitempty"`
+	ItemID       string                `json:"item_id,omitempty"`
+	Item         *ResponsesOutputItem  `json:"item,omitempty"`
+	Part         *ResponsesContentPart `json:"part,omitempty"`
+
+	Delta     string `json:"delta,omitempty"`
+	Text      string `json:"text,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+}
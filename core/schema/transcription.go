@@ -8,9 +8,48 @@ type Segment struct {
 	End    time.Duration `json:"end"`
 	Text   string        `json:"text"`
 	Tokens []int         `json:"tokens"`
+
+	// TokenTimings is returned only with the "token" timestamp granularity
+	TokenTimings []TokenTiming `json:"token_timings,omitempty"`
+
+	// AvgLogprob is the average log probability of the tokens, when known
+	AvgLogprob *float64 `json:"avg_logprob,omitempty"`
+	// CompressionRatio is the zlib compression ratio of the text, high for the repetitive texts
+	CompressionRatio float64 `json:"compression_ratio,omitempty"`
+}
+
+type TokenTiming struct {
+	Id          int           `json:"id"`
+	Text        string        `json:"text"`
+	Start       time.Duration `json:"start"`
+	End         time.Duration `json:"end"`
+	Probability float32       `json:"probability,omitempty"`
+}
+
+type WordTiming struct {
+	Word        string        `json:"word"`
+	Start       time.Duration `json:"start"`
+	End         time.Duration `json:"end"`
+	Probability float32       `json:"probability,omitempty"`
 }
 
 type TranscriptionResult struct {
 	Segments []Segment `json:"segments"`
 	Text     string    `json:"text"`
+
+	// Words is returned only with the "word" timestamp granularity
+	Words []WordTiming `json:"words,omitempty"`
+
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// TranscriptionStreamEvent is an event of a streaming transcription: the start and end times are in seconds
+// from the start of the stream
+type TranscriptionStreamEvent struct {
+	// Type is one of speech_started, speech_stopped, transcript.interim, transcript.final or error
+	Type  string  `json:"type"`
+	Text  string  `json:"text,omitempty"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Error string  `json:"error,omitempty"`
 }
@@ -0,0 +1,27 @@
+package schema
+
+import "encoding/json"
+
+const (
+	RequestWebhookAllow  = "allow"
+	RequestWebhookDeny   = "deny"
+	RequestWebhookModify = "modify"
+)
+
+// RequestWebhookCall is sent to the request validation webhook before running the inference
+type RequestWebhookCall struct {
+	Model     string         `json:"model"`
+	Endpoint  string         `json:"endpoint"`
+	RequestID string         `json:"request_id,omitempty"`
+	Request   *OpenAIRequest `json:"request"`
+}
+
+// RequestWebhookDecision is the answer of the request validation webhook
+type RequestWebhookDecision struct {
+	// Decision is one of allow, deny or modify
+	Decision string `json:"decision"`
+	// Reason is returned to the client when the request is denied
+	Reason string `json:"reason,omitempty"`
+	// Request replaces the request when the decision is modify
+	Request json.RawMessage `json:"request,omitempty"`
+}
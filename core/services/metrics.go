@@ -2,7 +2,9 @@ package services
 
 import (
 	"context"
+	"time"
 
+	"github.com/mudler/LocalAI/pkg/xsysinfo"
 	"github.com/rs/zerolog/log"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/prometheus"
@@ -10,9 +12,15 @@ import (
 	metricApi "go.opentelemetry.io/otel/sdk/metric"
 )
 
+// deviceSampleInterval is the interval at which the devices are sampled for the gauges, the default scrape interval
+// of Prometheus
+const deviceSampleInterval = 15 * time.Second
+
 type LocalAIMetricsService struct {
 	Meter         metric.Meter
 	ApiTimeMetric metric.Float64Histogram
+
+	devices *xsysinfo.DeviceSampler
 }
 
 func (m *LocalAIMetricsService) ObserveAPICall(method string, path string, duration float64) {
@@ -23,9 +31,20 @@ func (m *LocalAIMetricsService) ObserveAPICall(method string, path string, durat
 	m.ApiTimeMetric.Record(context.Background(), duration, opts)
 }
 
+// ObserveScheduledAPICall is ObserveAPICall for the requests scheduled on the backends with a priority
+func (m *LocalAIMetricsService) ObserveScheduledAPICall(method string, path string, priority int, duration float64) {
+	opts := metric.WithAttributes(
+		attribute.String("method", method),
+		attribute.String("path", path),
+		attribute.Int("priority", priority),
+	)
+	m.ApiTimeMetric.Record(context.Background(), duration, opts)
+}
+
 // setupOTelSDK bootstraps the OpenTelemetry pipeline.
 // If it does not return an error, make sure to call shutdown for proper cleanup.
-func NewLocalAIMetricsService() (*LocalAIMetricsService, error) {
+// The GPU utilization gauges are only exported with gpuStats, as the GPU statistics of the requests
+func NewLocalAIMetricsService(gpuStats bool) (*LocalAIMetricsService, error) {
 	exporter, err := prometheus.New()
 	if err != nil {
 		return nil, err
@@ -38,13 +57,57 @@ func NewLocalAIMetricsService() (*LocalAIMetricsService, error) {
 		return nil, err
 	}
 
+	// the scrapes read the samples taken in the background, instead of querying the devices each time
+	devices := xsysinfo.NewDeviceSampler(deviceSampleInterval, gpuStats)
+
+	_, err = meter.Int64ObservableGauge("memory_headroom_bytes",
+		metric.WithDescription("free system and GPU memory"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			usages, err := devices.MemoryUsages()
+			for _, u := range usages {
+				o.Observe(int64(u.Free), metric.WithAttributes(attribute.String("device", u.Device)))
+			}
+			return err
+		}))
+	if err != nil {
+		return nil, err
+	}
+
+	if gpuStats {
+		gpuUtilization, err := meter.Float64ObservableGauge("gpu_utilization_percent", metric.WithDescription("utilization of the GPUs"))
+		if err != nil {
+			return nil, err
+		}
+		gpuMemoryUsed, err := meter.Int64ObservableGauge("gpu_memory_used_bytes", metric.WithDescription("used GPU memory"))
+		if err != nil {
+			return nil, err
+		}
+		_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+			utilizations, err := devices.GPUUtilizations()
+			for _, u := range utilizations {
+				device := metric.WithAttributes(attribute.String("device", u.Device))
+				o.ObserveFloat64(gpuUtilization, u.Utilization, device)
+				o.ObserveInt64(gpuMemoryUsed, int64(u.MemoryUsed), device)
+			}
+			return err
+		}, gpuUtilization, gpuMemoryUsed)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	devices.Start()
+
 	return &LocalAIMetricsService{
 		Meter:         meter,
 		ApiTimeMetric: apiTimeMetric,
+		devices:       devices,
 	}, nil
 }
 
 func (lams LocalAIMetricsService) Shutdown() error {
+	lams.devices.Stop()
+
 	// TODO: Not sure how to actually do this:
 	//// setupOTelSDK bootstraps the OpenTelemetry pipeline.
 	//// If it does not return an error, make sure to call shutdown for proper cleanup.
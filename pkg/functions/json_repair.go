@@ -0,0 +1,101 @@
+package functions
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+var codeFenceRegex = regexp.MustCompile("(?s)^```[a-zA-Z]*\\s*\n?(.*?)\\s*(```)?$")
+
+// RepairJSON fixes the common malformations of JSON produced by language models:
+// markdown code fences, text around the JSON value, trailing commas, missing closing
+// quotes, braces and brackets (e.g. of truncated outputs).
+// It returns the repaired JSON and whether the result is valid JSON.
+func RepairJSON(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if json.Valid([]byte(s)) {
+		return s, true
+	}
+
+	if m := codeFenceRegex.FindStringSubmatch(s); m != nil {
+		s = strings.TrimSpace(m[1])
+	}
+
+	// skip any text before the JSON value
+	start := strings.IndexAny(s, "{[")
+	if start < 0 {
+		return s, false
+	}
+	s = s[start:]
+
+	var out strings.Builder
+	stack := []byte{}
+	inString, escaped := false, false
+
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if inString {
+			out.WriteByte(ch)
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch ch {
+		case '"':
+			inString = true
+		case '{':
+			stack = append(stack, '}')
+		case '[':
+			stack = append(stack, ']')
+		case '}', ']':
+			if len(stack) == 0 || stack[len(stack)-1] != ch {
+				// unbalanced closing: drop it
+				continue
+			}
+			trimTrailingComma(&out)
+			stack = stack[:len(stack)-1]
+		}
+		out.WriteByte(ch)
+
+		// the value is complete: skip any text after it
+		if len(stack) == 0 {
+			break
+		}
+	}
+
+	// close what was left open, e.g. by a truncated output
+	if inString {
+		if escaped {
+			out.WriteByte('\\')
+		}
+		out.WriteByte('"')
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		trimTrailingComma(&out)
+		if strings.HasSuffix(strings.TrimSpace(out.String()), ":") {
+			out.WriteString("null")
+		}
+		out.WriteByte(stack[i])
+	}
+
+	repaired := out.String()
+	return repaired, json.Valid([]byte(repaired))
+}
+
+// trimTrailingComma removes a comma (and the following whitespace) at the end of the output
+func trimTrailingComma(out *strings.Builder) {
+	s := strings.TrimRight(out.String(), " \t\r\n")
+	if strings.HasSuffix(s, ",") {
+		s = s[:len(s)-1]
+		out.Reset()
+		out.WriteString(s)
+	}
+}
@@ -0,0 +1,32 @@
+package functions_test
+
+import (
+	. "github.com/mudler/LocalAI/pkg/functions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JSON repair", func() {
+	DescribeTable("repairs common malformations",
+		func(input, expected string) {
+			repaired, ok := RepairJSON(input)
+			Expect(ok).To(BeTrue())
+			Expect(repaired).To(MatchJSON(expected))
+		},
+		Entry("valid JSON", `{"a": 1}`, `{"a": 1}`),
+		Entry("trailing commas", `{"a": [1, 2, ], "b": 2, }`, `{"a": [1, 2], "b": 2}`),
+		Entry("unclosed braces", `{"a": {"b": [1, 2`, `{"a": {"b": [1, 2]}}`),
+		Entry("unclosed string", `{"a": "hello`, `{"a": "hello"}`),
+		Entry("dangling key", `{"a": 1, "b":`, `{"a": 1, "b": null}`),
+		Entry("code fences", "```json\n{\"a\": 1}\n```", `{"a": 1}`),
+		Entry("surrounding text", `Sure! Here it is: {"a": "}"} Hope it helps`, `{"a": "}"}`),
+		Entry("escaped quotes", `{"a": "say \"hi\"", }`, `{"a": "say \"hi\""}`),
+	)
+
+	It("reports when the output cannot be repaired", func() {
+		_, ok := RepairJSON(`no JSON here`)
+		Expect(ok).To(BeFalse())
+		_, ok = RepairJSON(`{"a" 1}`)
+		Expect(ok).To(BeFalse())
+	})
+})
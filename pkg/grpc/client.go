@@ -17,7 +17,7 @@ type Client struct {
 	busy     bool
 	parallel bool
 	sync.Mutex
-	opMutex sync.Mutex
+	opMutex priorityMutex
 	wd      WatchDog
 }
 
@@ -52,7 +52,7 @@ func (c *Client) wdUnMark() {
 
 func (c *Client) HealthCheck(ctx context.Context) (bool, error) {
 	if !c.parallel {
-		c.opMutex.Lock()
+		c.opMutex.Lock(PriorityFromContext(ctx))
 		defer c.opMutex.Unlock()
 	}
 	c.setBusy(true)
@@ -82,7 +82,7 @@ func (c *Client) HealthCheck(ctx context.Context) (bool, error) {
 
 func (c *Client) Embeddings(ctx context.Context, in *pb.PredictOptions, opts ...grpc.CallOption) (*pb.EmbeddingResult, error) {
 	if !c.parallel {
-		c.opMutex.Lock()
+		c.opMutex.Lock(PriorityFromContext(ctx))
 		defer c.opMutex.Unlock()
 	}
 	c.setBusy(true)
@@ -101,7 +101,7 @@ func (c *Client) Embeddings(ctx context.Context, in *pb.PredictOptions, opts ...
 
 func (c *Client) Predict(ctx context.Context, in *pb.PredictOptions, opts ...grpc.CallOption) (*pb.Reply, error) {
 	if !c.parallel {
-		c.opMutex.Lock()
+		c.opMutex.Lock(PriorityFromContext(ctx))
 		defer c.opMutex.Unlock()
 	}
 	c.setBusy(true)
@@ -120,7 +120,7 @@ func (c *Client) Predict(ctx context.Context, in *pb.PredictOptions, opts ...grp
 
 func (c *Client) LoadModel(ctx context.Context, in *pb.ModelOptions, opts ...grpc.CallOption) (*pb.Result, error) {
 	if !c.parallel {
-		c.opMutex.Lock()
+		c.opMutex.Lock(PriorityFromContext(ctx))
 		defer c.opMutex.Unlock()
 	}
 	c.setBusy(true)
@@ -138,7 +138,7 @@ func (c *Client) LoadModel(ctx context.Context, in *pb.ModelOptions, opts ...grp
 
 func (c *Client) PredictStream(ctx context.Context, in *pb.PredictOptions, f func(reply *pb.Reply), opts ...grpc.CallOption) error {
 	if !c.parallel {
-		c.opMutex.Lock()
+		c.opMutex.Lock(PriorityFromContext(ctx))
 		defer c.opMutex.Unlock()
 	}
 	c.setBusy(true)
@@ -175,7 +175,7 @@ func (c *Client) PredictStream(ctx context.Context, in *pb.PredictOptions, f fun
 
 func (c *Client) GenerateImage(ctx context.Context, in *pb.GenerateImageRequest, opts ...grpc.CallOption) (*pb.Result, error) {
 	if !c.parallel {
-		c.opMutex.Lock()
+		c.opMutex.Lock(PriorityFromContext(ctx))
 		defer c.opMutex.Unlock()
 	}
 	c.setBusy(true)
@@ -193,7 +193,7 @@ func (c *Client) GenerateImage(ctx context.Context, in *pb.GenerateImageRequest,
 
 func (c *Client) TTS(ctx context.Context, in *pb.TTSRequest, opts ...grpc.CallOption) (*pb.Result, error) {
 	if !c.parallel {
-		c.opMutex.Lock()
+		c.opMutex.Lock(PriorityFromContext(ctx))
 		defer c.opMutex.Unlock()
 	}
 	c.setBusy(true)
@@ -211,7 +211,7 @@ func (c *Client) TTS(ctx context.Context, in *pb.TTSRequest, opts ...grpc.CallOp
 
 func (c *Client) SoundGeneration(ctx context.Context, in *pb.SoundGenerationRequest, opts ...grpc.CallOption) (*pb.Result, error) {
 	if !c.parallel {
-		c.opMutex.Lock()
+		c.opMutex.Lock(PriorityFromContext(ctx))
 		defer c.opMutex.Unlock()
 	}
 	c.setBusy(true)
@@ -229,7 +229,7 @@ func (c *Client) SoundGeneration(ctx context.Context, in *pb.SoundGenerationRequ
 
 func (c *Client) AudioTranscription(ctx context.Context, in *pb.TranscriptRequest, opts ...grpc.CallOption) (*pb.TranscriptResult, error) {
 	if !c.parallel {
-		c.opMutex.Lock()
+		c.opMutex.Lock(PriorityFromContext(ctx))
 		defer c.opMutex.Unlock()
 	}
 	c.setBusy(true)
@@ -247,7 +247,7 @@ func (c *Client) AudioTranscription(ctx context.Context, in *pb.TranscriptReques
 
 func (c *Client) TokenizeString(ctx context.Context, in *pb.PredictOptions, opts ...grpc.CallOption) (*pb.TokenizationResponse, error) {
 	if !c.parallel {
-		c.opMutex.Lock()
+		c.opMutex.Lock(PriorityFromContext(ctx))
 		defer c.opMutex.Unlock()
 	}
 	c.setBusy(true)
@@ -271,7 +271,7 @@ func (c *Client) TokenizeString(ctx context.Context, in *pb.PredictOptions, opts
 
 func (c *Client) Status(ctx context.Context) (*pb.StatusResponse, error) {
 	if !c.parallel {
-		c.opMutex.Lock()
+		c.opMutex.Lock(PriorityFromContext(ctx))
 		defer c.opMutex.Unlock()
 	}
 	c.setBusy(true)
@@ -287,7 +287,7 @@ func (c *Client) Status(ctx context.Context) (*pb.StatusResponse, error) {
 
 func (c *Client) StoresSet(ctx context.Context, in *pb.StoresSetOptions, opts ...grpc.CallOption) (*pb.Result, error) {
 	if !c.parallel {
-		c.opMutex.Lock()
+		c.opMutex.Lock(PriorityFromContext(ctx))
 		defer c.opMutex.Unlock()
 	}
 	c.setBusy(true)
@@ -305,7 +305,7 @@ func (c *Client) StoresSet(ctx context.Context, in *pb.StoresSetOptions, opts ..
 
 func (c *Client) StoresDelete(ctx context.Context, in *pb.StoresDeleteOptions, opts ...grpc.CallOption) (*pb.Result, error) {
 	if !c.parallel {
-		c.opMutex.Lock()
+		c.opMutex.Lock(PriorityFromContext(ctx))
 		defer c.opMutex.Unlock()
 	}
 	c.wdMark()
@@ -323,7 +323,7 @@ func (c *Client) StoresDelete(ctx context.Context, in *pb.StoresDeleteOptions, o
 
 func (c *Client) StoresGet(ctx context.Context, in *pb.StoresGetOptions, opts ...grpc.CallOption) (*pb.StoresGetResult, error) {
 	if !c.parallel {
-		c.opMutex.Lock()
+		c.opMutex.Lock(PriorityFromContext(ctx))
 		defer c.opMutex.Unlock()
 	}
 	c.setBusy(true)
@@ -341,7 +341,7 @@ func (c *Client) StoresGet(ctx context.Context, in *pb.StoresGetOptions, opts ..
 
 func (c *Client) StoresFind(ctx context.Context, in *pb.StoresFindOptions, opts ...grpc.CallOption) (*pb.StoresFindResult, error) {
 	if !c.parallel {
-		c.opMutex.Lock()
+		c.opMutex.Lock(PriorityFromContext(ctx))
 		defer c.opMutex.Unlock()
 	}
 	c.setBusy(true)
@@ -359,7 +359,7 @@ func (c *Client) StoresFind(ctx context.Context, in *pb.StoresFindOptions, opts
 
 func (c *Client) Rerank(ctx context.Context, in *pb.RerankRequest, opts ...grpc.CallOption) (*pb.RerankResult, error) {
 	if !c.parallel {
-		c.opMutex.Lock()
+		c.opMutex.Lock(PriorityFromContext(ctx))
 		defer c.opMutex.Unlock()
 	}
 	c.setBusy(true)
@@ -377,7 +377,7 @@ func (c *Client) Rerank(ctx context.Context, in *pb.RerankRequest, opts ...grpc.
 
 func (c *Client) GetTokenMetrics(ctx context.Context, in *pb.MetricsRequest, opts ...grpc.CallOption) (*pb.MetricsResponse, error) {
 	if !c.parallel {
-		c.opMutex.Lock()
+		c.opMutex.Lock(PriorityFromContext(ctx))
 		defer c.opMutex.Unlock()
 	}
 	c.setBusy(true)
@@ -395,7 +395,7 @@ func (c *Client) GetTokenMetrics(ctx context.Context, in *pb.MetricsRequest, opt
 
 func (c *Client) VAD(ctx context.Context, in *pb.VADRequest, opts ...grpc.CallOption) (*pb.VADResponse, error) {
 	if !c.parallel {
-		c.opMutex.Lock()
+		c.opMutex.Lock(PriorityFromContext(ctx))
 		defer c.opMutex.Unlock()
 	}
 	c.setBusy(true)
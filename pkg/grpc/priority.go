@@ -0,0 +1,88 @@
+package grpc
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+)
+
+type priorityKeyType struct{}
+
+// WithPriority returns a context whose calls to the backends are scheduled with the given priority:
+// when the backend does not run requests in parallel, the waiting calls with a higher priority run first
+func WithPriority(ctx context.Context, priority int) context.Context {
+	return context.WithValue(ctx, priorityKeyType{}, priority)
+}
+
+// PriorityFromContext returns the priority of the calls made with the context, 0 if not set
+func PriorityFromContext(ctx context.Context) int {
+	if ctx == nil {
+		return 0
+	}
+	priority, _ := ctx.Value(priorityKeyType{}).(int)
+	return priority
+}
+
+// priorityMutex is a mutex handed over to the waiting goroutine with the highest priority
+// when unlocked, in the order they started waiting for the same priority
+type priorityMutex struct {
+	mu      sync.Mutex
+	locked  bool
+	seq     uint64
+	waiting priorityWaiters
+}
+
+type priorityWaiter struct {
+	priority int
+	seq      uint64
+	ready    chan struct{}
+}
+
+func (m *priorityMutex) Lock(priority int) {
+	m.mu.Lock()
+	if !m.locked {
+		m.locked = true
+		m.mu.Unlock()
+		return
+	}
+	w := &priorityWaiter{priority: priority, seq: m.seq, ready: make(chan struct{})}
+	m.seq++
+	heap.Push(&m.waiting, w)
+	m.mu.Unlock()
+	<-w.ready
+}
+
+func (m *priorityMutex) Unlock() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.waiting.Len() == 0 {
+		m.locked = false
+		return
+	}
+	// the mutex stays locked, for the next waiter
+	close(heap.Pop(&m.waiting).(*priorityWaiter).ready)
+}
+
+// priorityWaiters is a heap of the waiters, the highest priority first
+type priorityWaiters []*priorityWaiter
+
+func (w priorityWaiters) Len() int { return len(w) }
+
+func (w priorityWaiters) Less(i, j int) bool {
+	if w[i].priority != w[j].priority {
+		return w[i].priority > w[j].priority
+	}
+	return w[i].seq < w[j].seq
+}
+
+func (w priorityWaiters) Swap(i, j int) { w[i], w[j] = w[j], w[i] }
+
+func (w *priorityWaiters) Push(x any) { *w = append(*w, x.(*priorityWaiter)) }
+
+func (w *priorityWaiters) Pop() any {
+	old := *w
+	n := len(old)
+	x := old[n-1]
+	*w = old[:n-1]
+	return x
+}
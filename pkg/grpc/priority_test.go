@@ -0,0 +1,65 @@
+package grpc
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriorityFromContext(t *testing.T) {
+	assert.Equal(t, 0, PriorityFromContext(context.Background()))
+	assert.Equal(t, 10, PriorityFromContext(WithPriority(context.Background(), 10)))
+}
+
+func TestPriorityMutexOrdering(t *testing.T) {
+	var m priorityMutex
+	m.Lock(0)
+
+	var mu sync.Mutex
+	order := []string{}
+	var wg sync.WaitGroup
+	wait := func(name string, priority int) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.Lock(priority)
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			m.Unlock()
+		}()
+		// let the goroutine start waiting, for the order of the same priorities
+		time.Sleep(20 * time.Millisecond)
+	}
+	wait("embeddings-1", 0)
+	wait("batch", -1)
+	wait("chat-1", 10)
+	wait("embeddings-2", 0)
+	wait("chat-2", 10)
+
+	m.Unlock()
+	wg.Wait()
+	// the higher priorities first, then in the order of arrival
+	assert.Equal(t, []string{"chat-1", "chat-2", "embeddings-1", "embeddings-2", "batch"}, order)
+}
+
+func TestPriorityMutexExclusion(t *testing.T) {
+	var m priorityMutex
+	var wg sync.WaitGroup
+	running := 0
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(priority int) {
+			defer wg.Done()
+			m.Lock(priority)
+			running++
+			assert.Equal(t, 1, running)
+			running--
+			m.Unlock()
+		}(i % 3)
+	}
+	wg.Wait()
+}
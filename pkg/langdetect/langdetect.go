@@ -0,0 +1,115 @@
+// Package langdetect is a lightweight language detection for short texts, based on the
+// script of the letters and, for the languages written in the Latin script, on their most common words.
+package langdetect
+
+import (
+	"strings"
+	"unicode"
+)
+
+// scripts maps the writing systems used by a single (common) language to its ISO 639-1 code
+var scripts = []struct {
+	table    *unicode.RangeTable
+	language string
+}{
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Hangul, "ko"},
+	{unicode.Han, "zh"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Greek, "el"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Thai, "th"},
+}
+
+// commonWords are frequent words of the languages written in the Latin script
+var commonWords = map[string][]string{
+	"en": {"the", "and", "is", "are", "of", "to", "in", "it", "you", "that", "this", "what", "with", "for", "not", "have", "how", "can", "be", "was", "i", "my", "your", "do", "does", "on"},
+	"it": {"il", "lo", "la", "gli", "le", "di", "che", "è", "e", "non", "per", "un", "una", "sono", "mi", "ti", "come", "cosa", "con", "del", "della", "questo", "ciao", "perché", "anche", "ho"},
+	"es": {"el", "la", "los", "las", "de", "que", "y", "es", "en", "no", "por", "un", "una", "con", "para", "como", "qué", "está", "del", "mi", "se", "lo", "hola", "pero", "muy", "yo"},
+	"fr": {"le", "la", "les", "de", "des", "et", "est", "un", "une", "que", "qui", "pas", "je", "vous", "il", "en", "pour", "dans", "ce", "avec", "sur", "bonjour", "mais", "nous", "du", "suis"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "ich", "du", "sie", "es", "mit", "zu", "auf", "für", "wie", "was", "den", "dem", "hallo", "bitte", "auch", "sind", "wir"},
+	"pt": {"o", "a", "os", "as", "de", "que", "e", "é", "não", "um", "uma", "para", "com", "por", "como", "em", "do", "da", "você", "eu", "olá", "isso", "mas", "muito", "está", "são"},
+	"nl": {"de", "het", "een", "en", "is", "niet", "van", "ik", "je", "dat", "die", "met", "voor", "op", "zijn", "wat", "hoe", "er", "maar", "ook", "hallo", "we", "wij", "jij", "dit", "aan"},
+}
+
+// Detect returns the ISO 639-1 code of the language of the text, and a confidence between 0 and 1.
+// It returns an empty language if the text is too short or ambiguous
+func Detect(text string) (string, float64) {
+	letters := 0
+	counts := map[string]int{}
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, s := range scripts {
+			if unicode.Is(s.table, r) {
+				counts[s.language]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return "", 0
+	}
+
+	// Japanese texts mix kana and kanji
+	if counts["ja"] > 0 {
+		counts["ja"] += counts["zh"]
+		delete(counts, "zh")
+	}
+	if language, n := best(counts); n*2 > letters {
+		return language, float64(n) / float64(letters)
+	}
+
+	return detectLatin(text)
+}
+
+// detectLatin detects the languages written in the Latin script from their most common words
+func detectLatin(text string) (string, float64) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	if len(words) == 0 {
+		return "", 0
+	}
+
+	counts := map[string]int{}
+	total := 0
+	for _, w := range words {
+		for language, common := range commonWords {
+			for _, c := range common {
+				if w == c {
+					counts[language]++
+					total++
+					break
+				}
+			}
+		}
+	}
+
+	language, n := best(counts)
+	if n == 0 {
+		return "", 0
+	}
+	// the best language must stand out
+	for l, c := range counts {
+		if l != language && c == n {
+			return "", 0
+		}
+	}
+	return language, float64(n) / float64(total)
+}
+
+func best(counts map[string]int) (string, int) {
+	language, n := "", 0
+	for l, c := range counts {
+		if c > n || (c == n && l < language) {
+			language, n = l, c
+		}
+	}
+	return language, n
+}
@@ -0,0 +1,33 @@
+package langdetect_test
+
+import (
+	. "github.com/mudler/LocalAI/pkg/langdetect"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Language detection", func() {
+	DescribeTable("detects the language",
+		func(text, language string) {
+			detected, confidence := Detect(text)
+			Expect(detected).To(Equal(language))
+			Expect(confidence).To(BeNumerically(">", 0))
+		},
+		Entry("English", "What is the capital of France? I would like to know it.", "en"),
+		Entry("Italian", "Ciao, qual è la capitale della Francia? Non lo so.", "it"),
+		Entry("Spanish", "Hola, ¿cuál es la capital de Francia? Yo no lo sé.", "es"),
+		Entry("French", "Bonjour, quelle est la capitale de la France? Je ne sais pas.", "fr"),
+		Entry("German", "Hallo, was ist die Hauptstadt von Frankreich? Ich weiß es nicht.", "de"),
+		Entry("Russian", "Привет, какая столица Франции?", "ru"),
+		Entry("Japanese", "フランスの首都はどこですか？", "ja"),
+		Entry("Chinese", "法国的首都是哪里？", "zh"),
+		Entry("Korean", "프랑스의 수도는 어디입니까?", "ko"),
+	)
+
+	It("does not guess on texts without clues", func() {
+		language, _ := Detect("12345 !!!")
+		Expect(language).To(BeEmpty())
+		language, _ = Detect("Paris")
+		Expect(language).To(BeEmpty())
+	})
+})
@@ -0,0 +1,179 @@
+package model
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ResourceLimits caps the CPU and memory of the process of a backend. Zero values are not limited
+type ResourceLimits struct {
+	CPUs        float64 `json:"cpus,omitempty"`
+	MemoryBytes uint64  `json:"memory_bytes,omitempty"`
+}
+
+// IsSet returns whether the process is limited
+func (l ResourceLimits) IsSet() bool {
+	return l.CPUs > 0 || l.MemoryBytes > 0
+}
+
+var (
+	// cgroupRoot is the mount point of the cgroup v2 hierarchy
+	cgroupRoot = "/sys/fs/cgroup"
+	// procSelfCgroup lists the cgroup of the LocalAI process
+	procSelfCgroup = "/proc/self/cgroup"
+	// cgroupMonitorInterval is the interval at which the backends are checked for throttling and kills by their limits
+	cgroupMonitorInterval = 30 * time.Second
+)
+
+// cpuPeriod is the period of the CPU quota of the backends, in microseconds
+const cpuPeriod = 100000
+
+var cgroupNameRegex = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+
+// cgroup is the cgroup of the process of a backend, a sibling of the one of LocalAI
+type cgroup struct {
+	path     string
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// newCgroup creates the cgroup of the backend of the model, with the limits. It fails when cgroups v2
+// are not available or their cpu and memory controllers cannot be delegated to the backends
+func newCgroup(modelID string, limits ResourceLimits) (*cgroup, error) {
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("cgroups v2 are not available: %w", err)
+	}
+	self, err := selfCgroup()
+	if err != nil {
+		return nil, err
+	}
+	parent := filepath.Join(cgroupRoot, self)
+	if err := enableControllers(parent); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(parent, "localai-backend-"+cgroupNameRegex.ReplaceAllString(modelID, "_"))
+	if err := os.Mkdir(path, 0755); err != nil && !os.IsExist(err) {
+		return nil, fmt.Errorf("failed creating the cgroup: %w", err)
+	}
+	cg := &cgroup{path: path, done: make(chan struct{})}
+	if limits.CPUs > 0 {
+		err = cg.write("cpu.max", fmt.Sprintf("%d %d", int(limits.CPUs*cpuPeriod), cpuPeriod))
+	}
+	if err == nil && limits.MemoryBytes > 0 {
+		err = cg.write("memory.max", strconv.FormatUint(limits.MemoryBytes, 10))
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return cg, nil
+}
+
+// selfCgroup returns the cgroup v2 path of the LocalAI process
+func selfCgroup() (string, error) {
+	data, err := os.ReadFile(procSelfCgroup)
+	if err != nil {
+		return "", fmt.Errorf("failed reading the cgroup of the process: %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("the process is not in a cgroup v2 hierarchy")
+}
+
+// enableControllers delegates the cpu and memory controllers of the cgroup to its children. As a cgroup with
+// processes cannot delegate them (unless it is the root), LocalAI is moved to a child cgroup when needed
+func enableControllers(parent string) error {
+	err := os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte("+cpu +memory"), 0644)
+	if errors.Is(err, syscall.EBUSY) {
+		leaf := filepath.Join(parent, "localai")
+		if err = os.Mkdir(leaf, 0755); err == nil || os.IsExist(err) {
+			err = os.WriteFile(filepath.Join(leaf, "cgroup.procs"), []byte(strconv.Itoa(os.Getpid())), 0644)
+		}
+		if err == nil {
+			err = os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte("+cpu +memory"), 0644)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed enabling the cpu and memory controllers of the cgroup %s: %w", parent, err)
+	}
+	return nil
+}
+
+func (cg *cgroup) write(file, value string) error {
+	if err := os.WriteFile(filepath.Join(cg.path, file), []byte(value), 0644); err != nil {
+		return fmt.Errorf("failed setting %s of the cgroup: %w", file, err)
+	}
+	return nil
+}
+
+// add moves the process to the cgroup
+func (cg *cgroup) add(pid string) error {
+	return cg.write("cgroup.procs", pid)
+}
+
+// stat returns the value of the key of a flat keyed file of the cgroup, such as cpu.stat
+func (cg *cgroup) stat(file, key string) uint64 {
+	f, err := os.Open(filepath.Join(cg.path, file))
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if k, v, ok := strings.Cut(scanner.Text(), " "); ok && k == key {
+			n, _ := strconv.ParseUint(v, 10, 64)
+			return n
+		}
+	}
+	return 0
+}
+
+// monitor logs when the backend is throttled or killed by its limits, until the cgroup is removed
+func (cg *cgroup) monitor(modelID string) {
+	throttled, oomKills := cg.stat("cpu.stat", "nr_throttled"), cg.stat("memory.events", "oom_kill")
+	ticker := time.NewTicker(cgroupMonitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-cg.done:
+			return
+		case <-ticker.C:
+		}
+		if n := cg.stat("cpu.stat", "nr_throttled"); n > throttled {
+			log.Warn().Str("model", modelID).Uint64("periods", n-throttled).Msg("the backend was throttled by its CPU limit")
+			throttled = n
+		}
+		if n := cg.stat("memory.events", "oom_kill"); n > oomKills {
+			log.Error().Str("model", modelID).Uint64("kills", n-oomKills).Msg("a process of the backend was killed by its memory limit")
+			oomKills = n
+		}
+	}
+}
+
+// remove stops monitoring the cgroup and removes it, once its processes exited
+func (cg *cgroup) remove() {
+	cg.stopOnce.Do(func() { close(cg.done) })
+	var err error
+	for i := 0; i < 20; i++ {
+		if err = os.Remove(cg.path); err == nil || os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	log.Warn().Err(err).Str("cgroup", cg.path).Msg("failed removing the cgroup of the backend")
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+
+	process "github.com/mudler/go-processmanager"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Backend cgroups", func() {
+	var root, parent string
+	var oldRoot, oldProcSelfCgroup string
+
+	BeforeEach(func() {
+		oldRoot, oldProcSelfCgroup = cgroupRoot, procSelfCgroup
+		root = GinkgoT().TempDir()
+		parent = filepath.Join(root, "system.slice", "localai.service")
+		Expect(os.MkdirAll(parent, 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(root, "cgroup.controllers"), []byte("cpu memory"), 0644)).To(Succeed())
+		procSelfCgroup = filepath.Join(root, "self")
+		Expect(os.WriteFile(procSelfCgroup, []byte("0::/system.slice/localai.service\n"), 0644)).To(Succeed())
+		cgroupRoot = root
+	})
+
+	AfterEach(func() {
+		cgroupRoot, procSelfCgroup = oldRoot, oldProcSelfCgroup
+	})
+
+	It("creates the cgroup of the backend with its limits", func() {
+		cg, err := newCgroup("org/model:7b", ResourceLimits{CPUs: 2.5, MemoryBytes: 8 << 30})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cg.path).To(Equal(filepath.Join(parent, "localai-backend-org_model_7b")))
+		Expect(os.ReadFile(filepath.Join(parent, "cgroup.subtree_control"))).To(BeEquivalentTo("+cpu +memory"))
+		Expect(os.ReadFile(filepath.Join(cg.path, "cpu.max"))).To(BeEquivalentTo("250000 100000"))
+		Expect(os.ReadFile(filepath.Join(cg.path, "memory.max"))).To(BeEquivalentTo("8589934592"))
+
+		Expect(cg.add("1234")).To(Succeed())
+		Expect(os.ReadFile(filepath.Join(cg.path, "cgroup.procs"))).To(BeEquivalentTo("1234"))
+	})
+
+	It("reads the throttling and the kills of the backend", func() {
+		cg, err := newCgroup("model", ResourceLimits{CPUs: 1})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cg.stat("memory.events", "oom_kill")).To(BeZero())
+		Expect(os.WriteFile(filepath.Join(cg.path, "cpu.stat"), []byte("usage_usec 100\nnr_periods 10\nnr_throttled 4\n"), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(cg.path, "memory.events"), []byte("low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n"), 0644)).To(Succeed())
+		Expect(cg.stat("cpu.stat", "nr_throttled")).To(BeEquivalentTo(4))
+		Expect(cg.stat("memory.events", "oom_kill")).To(BeEquivalentTo(1))
+	})
+
+	It("fails without cgroups v2", func() {
+		Expect(os.Remove(filepath.Join(root, "cgroup.controllers"))).To(Succeed())
+		_, err := newCgroup("model", ResourceLimits{CPUs: 1})
+		Expect(err).To(MatchError(ContainSubstring("cgroups v2 are not available")))
+	})
+
+	It("runs the backend unlimited when the limits cannot be enforced", func() {
+		Expect(os.Remove(filepath.Join(root, "cgroup.controllers"))).To(Succeed())
+		m := NewModel("model", "127.0.0.1:0", process.New())
+		m.limitResources(ResourceLimits{MemoryBytes: 1 << 30})
+		Expect(m.Resources.MemoryBytes).To(BeEquivalentTo(1 << 30))
+		Expect(m.ResourcesEnforced).To(BeFalse())
+	})
+})
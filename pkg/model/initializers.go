@@ -395,6 +395,10 @@ func (ml *ModelLoader) grpcModel(backend string, autodetect bool, o *Options) fu
 			client = NewModel(modelID, serverAddress, process)
 		}
 
+		if o.resourceLimits.IsSet() {
+			client.limitResources(o.resourceLimits)
+		}
+
 		log.Debug().Msgf("Wait for the service to start up")
 
 		// Wait for the service to start up
@@ -414,9 +418,7 @@ func (ml *ModelLoader) grpcModel(backend string, autodetect bool, o *Options) fu
 
 		if !ready {
 			log.Debug().Msgf("GRPC Service NOT ready")
-			if process := client.Process(); process != nil {
-				process.Stop()
-			}
+			client.stopProcess()
 			return nil, fmt.Errorf("grpc service not ready")
 		}
 
@@ -429,17 +431,20 @@ func (ml *ModelLoader) grpcModel(backend string, autodetect bool, o *Options) fu
 
 		res, err := client.GRPC(o.parallelRequests, ml.wd).LoadModel(o.context, &options)
 		if err != nil {
-			if process := client.Process(); process != nil {
-				process.Stop()
+			if client.stopFailedLoad(err) {
+				return nil, fmt.Errorf("could not load model: %w: %w", ErrOutOfMemory, err)
 			}
 			return nil, fmt.Errorf("could not load model: %w", err)
 		}
 		if !res.Success {
-			if process := client.Process(); process != nil {
-				process.Stop()
+			// llama.cpp does not tell why the model failed to load, the allocation failures are in its stderr
+			if client.stopFailedLoad(errors.New(res.Message)) {
+				return nil, fmt.Errorf("could not load model (no success): %w: %s", ErrOutOfMemory, res.Message)
 			}
 			return nil, fmt.Errorf("could not load model (no success): %s", res.Message)
 		}
+		client.TensorSplit, client.MainGPU = options.TensorSplit, options.MainGPU
+		client.RopeScaling = o.ropeScaling
 
 		return client, nil
 	}
@@ -501,6 +506,13 @@ func (ml *ModelLoader) stopActiveBackends(modelID string, singleActiveBackend bo
 func (ml *ModelLoader) Load(opts ...Option) (grpc.Backend, error) {
 	o := NewOptions(opts...)
 
+	// do not wait indefinitely for the model being loaded by another request
+	if o.loadingTimeout > 0 {
+		if err := ml.loading.Wait(o.modelID, o.loadingTimeout); err != nil {
+			return nil, err
+		}
+	}
+
 	// Return earlier if we have a model already loaded
 	// (avoid looping through all the backends)
 	if m := ml.CheckIsLoaded(o.modelID); m != nil {
@@ -509,10 +521,37 @@ func (ml *ModelLoader) Load(opts ...Option) (grpc.Backend, error) {
 		return m.GRPC(o.parallelRequests, ml.wd), nil
 	}
 
+	started := ml.loading.Start(o.modelID)
+	model, err := ml.load(o, opts...)
+	if started {
+		ml.loading.Done(o.modelID, err == nil)
+	}
+	if err != nil {
+		return nil, &ModelLoadFailedError{Model: o.modelID, Err: err}
+	}
+	return model, nil
+}
+
+func (ml *ModelLoader) load(o *Options, opts ...Option) (grpc.Backend, error) {
 	ml.stopActiveBackends(o.modelID, o.singleActiveBackend)
 
+	// make room for the new model if the memory is low
+	if ml.wd != nil {
+		ml.wd.ReclaimMemory()
+	}
+
+	if o.beforeLoad != nil {
+		if err := o.beforeLoad(o.gRPCOptions); err != nil {
+			return nil, err
+		}
+	}
+
 	if o.backendString != "" {
-		return ml.backendLoader(opts...)
+		model, err := ml.backendLoader(opts...)
+		if err == nil && o.onLoad != nil {
+			o.onLoad(model)
+		}
+		return model, err
 	}
 
 	var err error
@@ -541,6 +580,9 @@ func (ml *ModelLoader) Load(opts ...Option) (grpc.Backend, error) {
 		model, modelerr := ml.backendLoader(options...)
 		if modelerr == nil && model != nil {
 			log.Info().Msgf("[%s] Loads OK", key)
+			if o.onLoad != nil {
+				o.onLoad(model)
+			}
 			return model, nil
 		} else if modelerr != nil {
 			err = errors.Join(err, fmt.Errorf("[%s]: %w", key, modelerr))
@@ -22,12 +22,14 @@ type ModelLoader struct {
 	mu        sync.Mutex
 	models    map[string]*Model
 	wd        *WatchDog
+	loading   *loadingTracker
 }
 
 func NewModelLoader(modelPath string) *ModelLoader {
 	nml := &ModelLoader{
 		ModelPath: modelPath,
 		models:    make(map[string]*Model),
+		loading:   newLoadingTracker(),
 	}
 
 	return nml
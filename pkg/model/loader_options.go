@@ -2,7 +2,9 @@ package model
 
 import (
 	"context"
+	"time"
 
+	"github.com/mudler/LocalAI/pkg/grpc"
 	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
 )
 
@@ -21,6 +23,13 @@ type Options struct {
 	grpcAttemptsDelay   int
 	singleActiveBackend bool
 	parallelRequests    bool
+	loadingTimeout      time.Duration
+
+	onLoad     func(grpc.Backend)
+	beforeLoad func(*pb.ModelOptions) error
+
+	resourceLimits ResourceLimits
+	ropeScaling    *RopeScaling
 }
 
 type Option func(*Options)
@@ -92,6 +101,45 @@ func WithModelID(id string) Option {
 	}
 }
 
+// WithLoadingTimeout sets the maximum time to wait for the model when it is being loaded by another request,
+// after which a ModelLoadingError is returned. By default the load is awaited
+func WithLoadingTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.loadingTimeout = timeout
+	}
+}
+
+// WithOnLoad sets a function called when the model has been loaded.
+// It is not called if the model was already loaded
+func WithOnLoad(f func(grpc.Backend)) Option {
+	return func(o *Options) {
+		o.onLoad = f
+	}
+}
+
+// WithBeforeLoad sets a function called before the model is loaded, which can adjust its options
+// or prevent loading it by returning an error. It is not called if the model was already loaded
+func WithBeforeLoad(f func(*pb.ModelOptions) error) Option {
+	return func(o *Options) {
+		o.beforeLoad = f
+	}
+}
+
+// WithResourceLimits caps the CPU and memory of the backend process, with cgroups where available.
+// The limits are set when the backend is started
+func WithResourceLimits(limits ResourceLimits) Option {
+	return func(o *Options) {
+		o.resourceLimits = limits
+	}
+}
+
+// WithRopeScaling records the rope scaling applied to the options of the model, reported by the loaded model
+func WithRopeScaling(scaling *RopeScaling) Option {
+	return func(o *Options) {
+		o.ropeScaling = scaling
+	}
+}
+
 func NewOptions(opts ...Option) *Options {
 	o := &Options{
 		gRPCOptions:       &pb.ModelOptions{},
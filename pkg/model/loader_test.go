@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
 	"github.com/mudler/LocalAI/pkg/model"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -86,6 +87,19 @@ var _ = Describe("ModelLoader", func() {
 		})
 	})
 
+	Context("Load", func() {
+		It("does not load the model when the before load function fails", func() {
+			checkErr := errors.New("the model does not fit")
+			_, err := modelLoader.Load(
+				model.WithModelID("foo"),
+				model.WithBackendString("llama-cpp"),
+				model.WithBeforeLoad(func(*pb.ModelOptions) error { return checkErr }),
+			)
+			Expect(err).To(MatchError(checkErr))
+			Expect(modelLoader.CheckIsLoaded("foo")).To(BeNil())
+		})
+	})
+
 	Context("ShutdownModel", func() {
 		It("should shutdown a loaded model", func() {
 			mockLoader := func(modelID, modelName, modelFile string) (*model.Model, error) {
@@ -0,0 +1,154 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// recentLoadTimes is the number of load times of each model used to estimate the next ones
+	recentLoadTimes = 5
+	// defaultLoadingRetryAfter is the retry delay suggested when the load time cannot be estimated
+	defaultLoadingRetryAfter = 5 * time.Second
+)
+
+// ModelLoadingError is returned when a model is still being loaded by another request
+type ModelLoadingError struct {
+	Model string
+	// Elapsed is the time since the load started, Estimated the expected load time (0 if unknown)
+	Elapsed, Estimated time.Duration
+}
+
+func (e *ModelLoadingError) Error() string {
+	if e.Estimated > 0 {
+		return fmt.Sprintf("model %s is loading (%.0fs elapsed, about %.0fs expected), retry later", e.Model, e.Elapsed.Seconds(), e.Estimated.Seconds())
+	}
+	return fmt.Sprintf("model %s is loading (%.0fs elapsed), retry later", e.Model, e.Elapsed.Seconds())
+}
+
+// RetryAfter returns the time after which the model is expected to be loaded
+func (e *ModelLoadingError) RetryAfter() time.Duration {
+	if remaining := e.Estimated - e.Elapsed; remaining > 0 {
+		return time.Duration(math.Ceil(remaining.Seconds())) * time.Second
+	}
+	return defaultLoadingRetryAfter
+}
+
+// ModelLoadFailedError is returned when a model could not be loaded
+type ModelLoadFailedError struct {
+	Model string
+	Err   error
+}
+
+func (e *ModelLoadFailedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ModelLoadFailedError) Unwrap() error {
+	return e.Err
+}
+
+type loadingModel struct {
+	started time.Time
+	done    chan struct{}
+}
+
+// loadingTracker tracks the models being loaded and the time their previous loads took
+type loadingTracker struct {
+	mu        sync.Mutex
+	loading   map[string]*loadingModel
+	loadTimes map[string][]time.Duration
+}
+
+func newLoadingTracker() *loadingTracker {
+	return &loadingTracker{
+		loading:   map[string]*loadingModel{},
+		loadTimes: map[string][]time.Duration{},
+	}
+}
+
+// Start marks the model as loading. It returns false if it is already being loaded
+func (t *loadingTracker) Start(modelID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.loading[modelID]; ok {
+		return false
+	}
+	t.loading[modelID] = &loadingModel{started: time.Now(), done: make(chan struct{})}
+	return true
+}
+
+// Done marks the load of the model as completed, recording its duration if it succeeded
+func (t *loadingTracker) Done(modelID string, succeeded bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	l, ok := t.loading[modelID]
+	if !ok {
+		return
+	}
+	delete(t.loading, modelID)
+	close(l.done)
+	if succeeded {
+		times := append(t.loadTimes[modelID], time.Since(l.started))
+		if len(times) > recentLoadTimes {
+			times = times[len(times)-recentLoadTimes:]
+		}
+		t.loadTimes[modelID] = times
+	}
+}
+
+// Wait waits up to the timeout for the load of the model to complete. It returns a ModelLoadingError
+// if the model is still loading after the timeout
+func (t *loadingTracker) Wait(modelID string, timeout time.Duration) error {
+	t.mu.Lock()
+	l, ok := t.loading[modelID]
+	t.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	select {
+	case <-l.done:
+		return nil
+	case <-time.After(timeout):
+		return &ModelLoadingError{Model: modelID, Elapsed: time.Since(l.started), Estimated: t.EstimatedLoadTime(modelID)}
+	}
+}
+
+// EstimatedLoadTime returns the average of the recent load times of the model, or of all the models
+// if it was never loaded. It returns 0 if no model was loaded yet
+func (t *loadingTracker) EstimatedLoadTime(modelID string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	times := t.loadTimes[modelID]
+	if len(times) == 0 {
+		for _, ts := range t.loadTimes {
+			times = append(times, ts...)
+		}
+	}
+	if len(times) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, d := range times {
+		total += d
+	}
+	return total / time.Duration(len(times))
+}
+
+// IsLoading returns if the model is being loaded, and since when
+func (ml *ModelLoader) IsLoading(modelID string) (bool, time.Time) {
+	ml.loading.mu.Lock()
+	defer ml.loading.mu.Unlock()
+	if l, ok := ml.loading.loading[modelID]; ok {
+		return true, l.started
+	}
+	return false, time.Time{}
+}
+
+// EstimatedLoadTime returns the expected load time of the model, from the recent load times
+func (ml *ModelLoader) EstimatedLoadTime(modelID string) time.Duration {
+	return ml.loading.EstimatedLoadTime(modelID)
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Model loading tracker", func() {
+	var t *loadingTracker
+
+	BeforeEach(func() {
+		t = newLoadingTracker()
+	})
+
+	It("waits for the models being loaded", func() {
+		Expect(t.Wait("model", time.Millisecond)).To(Succeed())
+
+		Expect(t.Start("model")).To(BeTrue())
+		Expect(t.Start("model")).To(BeFalse())
+
+		err := t.Wait("model", 10*time.Millisecond)
+		var loadingError *ModelLoadingError
+		Expect(errors.As(err, &loadingError)).To(BeTrue())
+		Expect(loadingError.Model).To(Equal("model"))
+		Expect(loadingError.Elapsed).To(BeNumerically(">=", 10*time.Millisecond))
+		// never loaded before
+		Expect(loadingError.Estimated).To(BeZero())
+		Expect(loadingError.RetryAfter()).To(Equal(defaultLoadingRetryAfter))
+
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			t.Done("model", true)
+		}()
+		Expect(t.Wait("model", time.Minute)).To(Succeed())
+	})
+
+	It("estimates the load time from the recent loads", func() {
+		t.loadTimes["model"] = []time.Duration{10 * time.Second, 20 * time.Second}
+		t.loadTimes["other"] = []time.Duration{90 * time.Second}
+		Expect(t.EstimatedLoadTime("model")).To(Equal(15 * time.Second))
+		// models never loaded use the load times of the others
+		Expect(t.EstimatedLoadTime("new")).To(Equal(40 * time.Second))
+
+		Expect(t.Start("model")).To(BeTrue())
+		err := t.Wait("model", time.Millisecond)
+		var loadingError *ModelLoadingError
+		Expect(errors.As(err, &loadingError)).To(BeTrue())
+		Expect(loadingError.Estimated).To(Equal(15 * time.Second))
+		Expect(loadingError.RetryAfter()).To(Equal(15 * time.Second))
+
+		// failed loads are not recorded
+		t.Done("model", false)
+		Expect(t.loadTimes["model"]).To(HaveLen(2))
+		for i := 0; i < recentLoadTimes+2; i++ {
+			Expect(t.Start("model")).To(BeTrue())
+			t.Done("model", true)
+		}
+		Expect(t.loadTimes["model"]).To(HaveLen(recentLoadTimes))
+		Expect(t.EstimatedLoadTime("model")).To(BeNumerically("<", time.Second))
+	})
+})
@@ -5,6 +5,7 @@ import (
 
 	grpc "github.com/mudler/LocalAI/pkg/grpc"
 	process "github.com/mudler/go-processmanager"
+	"github.com/rs/zerolog/log"
 )
 
 type Model struct {
@@ -13,6 +14,29 @@ type Model struct {
 	client  grpc.Backend
 	process *process.Process
 	sync.Mutex
+
+	// TensorSplit and MainGPU are the GPU settings the model was loaded with
+	TensorSplit string `json:"tensor_split,omitempty"`
+	MainGPU     string `json:"main_gpu,omitempty"`
+
+	// RopeScaling is the rope scaling applied automatically to extend the context size of the model, if any
+	RopeScaling *RopeScaling `json:"rope_scaling,omitempty"`
+
+	// Resources are the limits of the backend process, enforced if cgroups are available
+	Resources         ResourceLimits `json:"resources,omitempty"`
+	ResourcesEnforced bool           `json:"resources_enforced,omitempty"`
+	cgroup            *cgroup
+
+	tokens *TokenCache
+}
+
+// RopeScaling describes the rope scaling the model was loaded with, and the context sizes it was computed from
+type RopeScaling struct {
+	Method             string  `json:"method"`
+	Factor             float32 `json:"factor"`
+	FreqScale          float32 `json:"freq_scale"`
+	TrainedContextSize int     `json:"trained_context_size"`
+	ContextSize        int     `json:"context_size"`
 }
 
 func NewModel(ID, address string, process *process.Process) *Model {
@@ -20,13 +44,52 @@ func NewModel(ID, address string, process *process.Process) *Model {
 		ID:      ID,
 		address: address,
 		process: process,
+		tokens:  newTokenCache(),
 	}
 }
 
+// TokenCache returns the cache of the tokens of the texts tokenized by the model
+func (m *Model) TokenCache() *TokenCache {
+	return m.tokens
+}
+
 func (m *Model) Process() *process.Process {
 	return m.process
 }
 
+// limitResources moves the backend process to a cgroup with the limits. Without cgroups, the backend runs unlimited
+func (m *Model) limitResources(limits ResourceLimits) {
+	m.Resources = limits
+	if m.process == nil {
+		// the external backends are not started by LocalAI
+		return
+	}
+	cg, err := newCgroup(m.ID, limits)
+	if err == nil {
+		if err = cg.add(m.process.PID); err != nil {
+			cg.remove()
+		}
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("model", m.ID).Msg("the resource limits of the backend cannot be enforced, it runs unlimited")
+		return
+	}
+	m.cgroup, m.ResourcesEnforced = cg, true
+	go cg.monitor(m.ID)
+}
+
+// stopProcess stops the backend process and removes its cgroup, if any
+func (m *Model) stopProcess() error {
+	if m.process == nil {
+		return nil
+	}
+	err := m.process.Stop()
+	if m.cgroup != nil {
+		m.cgroup.remove()
+	}
+	return err
+}
+
 func (m *Model) GRPC(parallel bool, wd *WatchDog) grpc.Backend {
 	if m.client != nil {
 		return m.client
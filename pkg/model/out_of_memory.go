@@ -0,0 +1,119 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrOutOfMemory is wrapped by the errors of the models which could not be loaded because the memory of the GPU
+// (or of the host) is exhausted
+var ErrOutOfMemory = errors.New("out of memory")
+
+// outOfMemoryMarkers are the messages the backends log or return when an allocation fails: llama.cpp (CUDA, ROCm,
+// Vulkan, SYCL), PyTorch and the others, lower-cased
+var outOfMemoryMarkers = []string{
+	"out of memory",
+	"outofmemoryerror",
+	"cudamalloc failed",
+	"cublas_status_alloc_failed",
+	"hiperroroutofmemory",
+	"erroroutofdevicememory",
+}
+
+// stderrTailSize is how much of the end of the stderr of a backend is searched for the allocation failures
+const stderrTailSize = 16 << 10
+
+// IsOutOfMemory returns whether the message, an error or a log of a backend, reports an allocation failure
+func IsOutOfMemory(s string) bool {
+	s = strings.ToLower(s)
+	for _, marker := range outOfMemoryMarkers {
+		if strings.Contains(s, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// stderrTail returns the end of the stderr of the backend process, empty for the external backends
+func (m *Model) stderrTail() string {
+	if m.process == nil {
+		return ""
+	}
+	f, err := os.Open(m.process.StderrPath())
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	if fi, err := f.Stat(); err == nil && fi.Size() > stderrTailSize {
+		f.Seek(-stderrTailSize, io.SeekEnd)
+	}
+	b, _ := io.ReadAll(f)
+	return string(b)
+}
+
+// canceled returns whether the error is a cancellation or a deadline, locally or on the backend side
+func canceled(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	code := status.Code(err)
+	return code == codes.Canceled || code == codes.DeadlineExceeded
+}
+
+// errorOutOfMemory returns whether the error reports an allocation failure. The cancellations and the deadlines
+// never do, whatever their message
+func errorOutOfMemory(err error) bool {
+	if err == nil || canceled(err) {
+		return false
+	}
+	return errors.Is(err, ErrOutOfMemory) || IsOutOfMemory(err.Error())
+}
+
+// outOfMemory returns whether the error of the backend reports an allocation failure, or its stderr when the backend
+// crashed. The stderr of a running backend can report the failures of earlier requests, which it recovered from
+func (m *Model) outOfMemory(err error) bool {
+	if errorOutOfMemory(err) {
+		return true
+	}
+	if err == nil || canceled(err) || m.process == nil || m.process.IsAlive() {
+		return false
+	}
+	return IsOutOfMemory(m.stderrTail())
+}
+
+// stopFailedLoad stops the backend which failed to load the model, and returns whether the load failed with the error
+// because the memory is exhausted. The backends such as llama.cpp keep running after failing to load, and only
+// report the allocation failures on stderr: it is read once the backend is stopped, and as the backend was just
+// started, it only reports the failures of this load
+func (m *Model) stopFailedLoad(err error) bool {
+	m.stopProcess()
+	if errorOutOfMemory(err) {
+		return true
+	}
+	if canceled(err) {
+		return false
+	}
+	return IsOutOfMemory(m.stderrTail())
+}
+
+// OutOfMemory returns whether the request to the loaded model failed with the error because the memory is exhausted.
+// The backends which crash on allocation failures report them on stderr only
+func (ml *ModelLoader) OutOfMemory(modelID string, err error) bool {
+	if err == nil {
+		return false
+	}
+	// the model is not health checked: the backends which crashed would be removed with their stderr
+	ml.mu.Lock()
+	m, exists := ml.models[modelID]
+	ml.mu.Unlock()
+	if exists {
+		return m.outOfMemory(err)
+	}
+	return errorOutOfMemory(err)
+}
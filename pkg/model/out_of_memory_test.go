@@ -0,0 +1,71 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	process "github.com/mudler/go-processmanager"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Describe("Out of memory errors", func() {
+	It("detects the allocation failures of the backends", func() {
+		for _, s := range []string{
+			"ggml_backend_cuda_buffer_type_alloc_buffer: allocating 4096.00 MiB on device 0: cudaMalloc failed: out of memory",
+			"torch.OutOfMemoryError: CUDA out of memory. Tried to allocate 20.00 MiB",
+			"ggml_vulkan: Device memory allocation of size 1073741824 failed. vk::Device::allocateMemory: ErrorOutOfDeviceMemory",
+		} {
+			Expect(IsOutOfMemory(s)).To(BeTrue(), s)
+		}
+		Expect(IsOutOfMemory("could not load model: rpc error: code = Unknown desc = model not found")).To(BeFalse())
+		Expect(IsOutOfMemory("failed to allocate the sampler: invalid grammar")).To(BeFalse())
+	})
+
+	It("detects the wrapped errors without a model", func() {
+		ml := NewModelLoader("")
+		Expect(ml.OutOfMemory("model", fmt.Errorf("could not load model: %w", ErrOutOfMemory))).To(BeTrue())
+		Expect(ml.OutOfMemory("model", errors.New("failed loading model"))).To(BeFalse())
+		Expect(ml.OutOfMemory("model", nil)).To(BeFalse())
+	})
+
+	It("does not take the cancellations and the deadlines for out of memory errors", func() {
+		ml := NewModelLoader("")
+		Expect(ml.OutOfMemory("model", fmt.Errorf("out of memory: %w", context.DeadlineExceeded))).To(BeFalse())
+		Expect(ml.OutOfMemory("model", status.Error(codes.Canceled, "CUDA out of memory"))).To(BeFalse())
+
+		m := NewModel("model", "127.0.0.1:0", nil)
+		Expect(m.outOfMemory(status.Error(codes.DeadlineExceeded, "context deadline exceeded"))).To(BeFalse())
+	})
+
+	It("reads the stderr of the backends still running after failing to load", func() {
+		// as llama.cpp, the backend answers the load with a failure and keeps running
+		script := filepath.Join(GinkgoT().TempDir(), "backend.sh")
+		Expect(os.WriteFile(script, []byte("#!/bin/sh\necho 'cudaMalloc failed: out of memory' >&2\nsleep 60\n"), 0700)).To(Succeed())
+		proc := process.New(process.WithTemporaryStateDir(), process.WithName(script))
+		Expect(proc.Run()).To(Succeed())
+		DeferCleanup(func() {
+			proc.Stop()
+			os.RemoveAll(proc.StateDir())
+		})
+		Eventually(func() string {
+			data, _ := os.ReadFile(proc.StderrPath())
+			return string(data)
+		}).Should(ContainSubstring("out of memory"))
+
+		m := NewModel("model", "127.0.0.1:0", proc)
+		failed := errors.New("Failed loading model")
+		// the stderr of a running backend is not read
+		Expect(m.outOfMemory(failed)).To(BeFalse())
+		Expect(m.stopFailedLoad(failed)).To(BeTrue())
+		Eventually(proc.IsAlive).Should(BeFalse())
+
+		Expect(NewModel("model", "127.0.0.1:0", nil).stopFailedLoad(failed)).To(BeFalse())
+		Expect(m.stopFailedLoad(status.Error(codes.Canceled, "canceled"))).To(BeFalse())
+	})
+})
@@ -34,7 +34,7 @@ func (ml *ModelLoader) deleteProcess(s string) error {
 		return nil
 	}
 
-	err := process.Stop()
+	err := m.stopProcess()
 	if err != nil {
 		log.Error().Err(err).Msgf("(deleteProcess) error while deleting process %s", s)
 	}
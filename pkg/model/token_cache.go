@@ -0,0 +1,104 @@
+package model
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+)
+
+// tokenCacheMaxBytes is the size of the tokens cached per model
+const tokenCacheMaxBytes = 4 << 20
+
+// TokenCache caches the tokens of the texts tokenized by a model, keyed by the hash of the texts, so that counting
+// the tokens of the same texts again does not require the backend. It lives as long as the model is loaded, and is
+// therefore emptied when the model is reloaded
+type TokenCache struct {
+	mu       sync.Mutex
+	maxBytes int
+	bytes    int
+	entries  map[[sha256.Size]byte]*list.Element
+	// order holds the entries, the most recently used first
+	order *list.List
+}
+
+type tokenCacheEntry struct {
+	key    [sha256.Size]byte
+	tokens []int32
+}
+
+func newTokenCache() *TokenCache {
+	return &TokenCache{
+		maxBytes: tokenCacheMaxBytes,
+		entries:  map[[sha256.Size]byte]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+// size returns the bytes held by the entry: its key and its tokens
+func (e *tokenCacheEntry) size() int {
+	return sha256.Size + 4*len(e.tokens)
+}
+
+// Get returns the tokens of the text, if cached
+func (c *TokenCache) Get(text string) ([]int32, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[sha256.Sum256([]byte(text))]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*tokenCacheEntry).tokens, true
+}
+
+// Add caches the tokens of the text, evicting the least recently used texts until the cache fits in its size.
+// The texts with more tokens than the whole cache holds are not cached
+func (c *TokenCache) Add(text string, tokens []int32) {
+	entry := &tokenCacheEntry{key: sha256.Sum256([]byte(text)), tokens: tokens}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[entry.key]; ok {
+		c.remove(e)
+	}
+	if entry.size() > c.maxBytes {
+		return
+	}
+	c.entries[entry.key] = c.order.PushFront(entry)
+	c.bytes += entry.size()
+	for c.bytes > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+}
+
+// remove is called with the lock held
+func (c *TokenCache) remove(e *list.Element) {
+	entry := e.Value.(*tokenCacheEntry)
+	c.order.Remove(e)
+	delete(c.entries, entry.key)
+	c.bytes -= entry.size()
+}
+
+// Len returns the number of cached texts
+func (c *TokenCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Bytes returns the size of the cached tokens
+func (c *TokenCache) Bytes() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bytes
+}
+
+// TokenCache returns the token cache of the model if it is loaded, nil otherwise.
+// Unlike CheckIsLoaded, the backend is not checked
+func (ml *ModelLoader) TokenCache(modelID string) *TokenCache {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	if m, ok := ml.models[modelID]; ok {
+		return m.TokenCache()
+	}
+	return nil
+}
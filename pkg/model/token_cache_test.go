@@ -0,0 +1,155 @@
+package model_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mudler/LocalAI/pkg/grpc"
+	"github.com/mudler/LocalAI/pkg/grpc/base"
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/mudler/LocalAI/pkg/model"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TokenCache", func() {
+	var modelLoader *model.ModelLoader
+
+	load := func() *model.Model {
+		m, err := modelLoader.LoadModel("foo", "test.model", func(modelID, modelName, modelFile string) (*model.Model, error) {
+			return model.NewModel(modelID, modelFile, nil), nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		return m
+	}
+
+	BeforeEach(func() {
+		modelLoader = model.NewModelLoader("/tmp/test_model_path")
+	})
+
+	It("caches the tokens of the texts", func() {
+		cache := load().TokenCache()
+		_, ok := cache.Get("hello world")
+		Expect(ok).To(BeFalse())
+
+		cache.Add("hello world", []int32{1, 2})
+		tokens, ok := cache.Get("hello world")
+		Expect(ok).To(BeTrue())
+		Expect(tokens).To(Equal([]int32{1, 2}))
+		Expect(cache.Len()).To(Equal(1))
+		Expect(cache.Bytes()).To(Equal(32 + 2*4))
+
+		cache.Add("hello world", []int32{1, 2, 3})
+		Expect(cache.Len()).To(Equal(1))
+		Expect(cache.Bytes()).To(Equal(32 + 3*4))
+	})
+
+	It("evicts the least recently used texts beyond its size", func() {
+		cache := load().TokenCache()
+		// every entry holds 32 + 4*1024 bytes
+		tokens := make([]int32, 1024)
+		n := (4 << 20) / (32 + 4*1024)
+		for i := 0; i < n; i++ {
+			cache.Add(fmt.Sprint(i), tokens)
+		}
+		_, ok := cache.Get("0")
+		Expect(ok).To(BeTrue())
+
+		cache.Add("new", tokens)
+		Expect(cache.Len()).To(Equal(n))
+		Expect(cache.Bytes()).To(BeNumerically("<=", 4<<20))
+		_, ok = cache.Get("0")
+		Expect(ok).To(BeTrue())
+		_, ok = cache.Get("1")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("does not cache the texts larger than the cache", func() {
+		cache := load().TokenCache()
+		cache.Add("small", []int32{1})
+		cache.Add("huge", make([]int32, 1<<20))
+		_, ok := cache.Get("huge")
+		Expect(ok).To(BeFalse())
+		_, ok = cache.Get("small")
+		Expect(ok).To(BeTrue())
+	})
+
+	It("is only available while the model is loaded", func() {
+		Expect(modelLoader.TokenCache("foo")).To(BeNil())
+
+		load().TokenCache().Add("hello world", []int32{1, 2})
+		Expect(modelLoader.TokenCache("foo").Len()).To(Equal(1))
+
+		Expect(modelLoader.ShutdownModel("foo")).To(Succeed())
+		Expect(modelLoader.TokenCache("foo")).To(BeNil())
+
+		// the reloaded model may tokenize differently
+		load()
+		Expect(modelLoader.TokenCache("foo").Len()).To(BeZero())
+	})
+})
+
+// whitespaceTokenizer is a backend tokenizing the texts by words
+type whitespaceTokenizer struct {
+	base.SingleThread
+}
+
+func (t *whitespaceTokenizer) TokenizeString(opts *pb.PredictOptions) (pb.TokenizationResponse, error) {
+	words := strings.Fields(opts.Prompt)
+	tokens := make([]int32, len(words))
+	for i := range words {
+		tokens[i] = int32(i)
+	}
+	return pb.TokenizationResponse{Length: int32(len(tokens)), Tokens: tokens}, nil
+}
+
+// BenchmarkTokenCount compares counting the tokens of a text with a round trip to the backend
+// and with the token cache of the model
+func BenchmarkTokenCount(b *testing.B) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	address := lis.Addr().String()
+	lis.Close()
+	go grpc.StartServer(address, &whitespaceTokenizer{})
+
+	backend := grpc.NewClient(address, false, nil, false)
+	ctx := context.Background()
+	for ok := false; !ok; ok, _ = backend.HealthCheck(ctx) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	text := strings.Repeat("the quick brown fox jumps over the lazy dog ", 100)
+	count := func(cache *model.TokenCache) int {
+		if cache != nil {
+			if tokens, ok := cache.Get(text); ok {
+				return len(tokens)
+			}
+		}
+		resp, err := backend.TokenizeString(ctx, &pb.PredictOptions{Prompt: text})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if cache != nil {
+			cache.Add(text, resp.Tokens)
+		}
+		return int(resp.Length)
+	}
+
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			count(nil)
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		cache := model.NewModel("foo", address, nil).TokenCache()
+		for i := 0; i < b.N; i++ {
+			count(cache)
+		}
+	})
+}
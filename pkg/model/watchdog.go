@@ -1,9 +1,11 @@
 package model
 
 import (
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/mudler/LocalAI/pkg/xsysinfo"
 	process "github.com/mudler/go-processmanager"
 	"github.com/rs/zerolog/log"
 )
@@ -27,6 +29,10 @@ type WatchDog struct {
 	stop                 chan bool
 
 	busyCheck, idleCheck bool
+
+	// memoryThreshold is the minimum percentage of free memory, below which idle backends are stopped
+	memoryThreshold float64
+	memoryUsages    func() ([]xsysinfo.MemoryUsage, error)
 }
 
 type ProcessManager interface {
@@ -44,9 +50,18 @@ func NewWatchDog(pm ProcessManager, timeoutBusy, timeoutIdle time.Duration, busy
 		busyCheck:       busy,
 		idleCheck:       idle,
 		addressModelMap: make(map[string]string),
+		memoryUsages:    xsysinfo.MemoryUsages,
 	}
 }
 
+// EnableMemoryCheck makes the watchdog stop the least recently used idle backends
+// when the free system or GPU memory drops below the given percentage
+func (wd *WatchDog) EnableMemoryCheck(minFreePercent float64) {
+	wd.Lock()
+	defer wd.Unlock()
+	wd.memoryThreshold = minFreePercent
+}
+
 func (wd *WatchDog) Shutdown() {
 	wd.Lock()
 	defer wd.Unlock()
@@ -88,7 +103,7 @@ func (wd *WatchDog) Run() {
 			log.Info().Msg("[WatchDog] Stopping watchdog")
 			return
 		case <-time.After(30 * time.Second):
-			if !wd.busyCheck && !wd.idleCheck {
+			if !wd.busyCheck && !wd.idleCheck && wd.memoryThreshold <= 0 {
 				log.Info().Msg("[WatchDog] No checks enabled, stopping watchdog")
 				return
 			}
@@ -98,6 +113,7 @@ func (wd *WatchDog) Run() {
 			if wd.idleCheck {
 				wd.checkIdle()
 			}
+			wd.ReclaimMemory()
 		}
 	}
 }
@@ -154,3 +170,72 @@ func (wd *WatchDog) checkBusy() {
 		}
 	}
 }
+
+// ReclaimMemory stops the least recently used idle backends until the free memory of all
+// the devices is above the memory threshold, if one is set
+func (wd *WatchDog) ReclaimMemory() {
+	for {
+		model, ok := wd.nextMemoryEviction()
+		if !ok {
+			return
+		}
+		// the lock is not held while shutting down, as the backend might still need to report to the watchdog
+		if err := wd.pm.ShutdownModel(model); err != nil {
+			log.Error().Err(err).Str("model", model).Msg("[watchdog] error shutting down model")
+		}
+	}
+}
+
+// nextMemoryEviction returns the least recently used idle backend to stop if the memory is low
+func (wd *WatchDog) nextMemoryEviction() (string, bool) {
+	wd.Lock()
+	defer wd.Unlock()
+	if wd.memoryThreshold <= 0 {
+		return "", false
+	}
+
+	device, free, low := wd.memoryPressure()
+	if !low {
+		return "", false
+	}
+
+	// idle backends, least recently used first
+	idle := []string{}
+	for address := range wd.idleTime {
+		idle = append(idle, address)
+	}
+	sort.Slice(idle, func(i, j int) bool {
+		return wd.idleTime[idle[i]].Before(wd.idleTime[idle[j]])
+	})
+
+	for _, address := range idle {
+		model, ok := wd.addressModelMap[address]
+		delete(wd.idleTime, address)
+		if !ok {
+			log.Warn().Msgf("[WatchDog] Address %s unresolvable", address)
+			continue
+		}
+		delete(wd.addressModelMap, address)
+		delete(wd.addressMap, address)
+
+		log.Warn().Str("device", device).Float64("free_percent", free).Float64("threshold_percent", wd.memoryThreshold).Str("model", model).Msg("[WatchDog] Memory is low, stopping the least recently used backend")
+		return model, true
+	}
+
+	log.Warn().Str("device", device).Float64("free_percent", free).Msg("[WatchDog] Memory is low but there are no idle backends to stop")
+	return "", false
+}
+
+// memoryPressure returns the first device whose free memory is below the threshold
+func (wd *WatchDog) memoryPressure() (string, float64, bool) {
+	usages, err := wd.memoryUsages()
+	if err != nil {
+		log.Debug().Err(err).Msg("[WatchDog] failed reading the memory usage")
+	}
+	for _, u := range usages {
+		if free := u.FreePercent(); free < wd.memoryThreshold {
+			return u.Device, free, true
+		}
+	}
+	return "", 0, false
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"time"
+
+	"github.com/mudler/LocalAI/pkg/xsysinfo"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type shutdownRecorder struct {
+	stopped []string
+}
+
+func (s *shutdownRecorder) ShutdownModel(modelName string) error {
+	s.stopped = append(s.stopped, modelName)
+	return nil
+}
+
+var _ = Describe("WatchDog memory check", func() {
+	var (
+		pm *shutdownRecorder
+		wd *WatchDog
+	)
+
+	BeforeEach(func() {
+		pm = &shutdownRecorder{}
+		wd = NewWatchDog(pm, time.Minute, time.Minute, false, false)
+		wd.EnableMemoryCheck(20)
+
+		now := time.Now()
+		for i, m := range []string{"recent", "older", "oldest"} {
+			address := m + "-address"
+			wd.AddAddressModelMap(address, m)
+			wd.idleTime[address] = now.Add(-time.Duration(i) * time.Minute)
+		}
+		wd.AddAddressModelMap("busy-address", "busy")
+		wd.Mark("busy-address")
+	})
+
+	It("stops the least recently used idle backends until the memory is above the threshold", func() {
+		wd.memoryUsages = func() ([]xsysinfo.MemoryUsage, error) {
+			// every stopped backend frees 10% of the GPU memory
+			return []xsysinfo.MemoryUsage{
+				{Device: "system", Total: 100, Free: 50},
+				{Device: "gpu0", Total: 100, Free: uint64(5 + 10*len(pm.stopped))},
+			}, nil
+		}
+		wd.ReclaimMemory()
+		Expect(pm.stopped).To(Equal([]string{"oldest", "older"}))
+	})
+
+	It("does not stop busy backends", func() {
+		wd.memoryUsages = func() ([]xsysinfo.MemoryUsage, error) {
+			return []xsysinfo.MemoryUsage{{Device: "system", Total: 100, Free: 1}}, nil
+		}
+		wd.ReclaimMemory()
+		Expect(pm.stopped).To(Equal([]string{"oldest", "older", "recent"}))
+	})
+
+	It("does nothing when the memory is above the threshold", func() {
+		wd.memoryUsages = func() ([]xsysinfo.MemoryUsage, error) {
+			return []xsysinfo.MemoryUsage{{Device: "system", Total: 100, Free: 21}}, nil
+		}
+		wd.ReclaimMemory()
+		Expect(pm.stopped).To(BeEmpty())
+	})
+})
@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	AudioFormatWAV  = "wav"
+	AudioFormatMP3  = "mp3"
+	AudioFormatFLAC = "flac"
+	AudioFormatOGG  = "ogg"
+	AudioFormatWebM = "webm"
+	AudioFormatM4A  = "m4a"
+	AudioFormatMP4  = "mp4"
+	AudioFormatAAC  = "aac"
+	AudioFormatAMR  = "amr"
+)
+
+// AudioFormats are the audio formats DetectAudioFormat recognizes
+var AudioFormats = []string{
+	AudioFormatWAV, AudioFormatMP3, AudioFormatFLAC, AudioFormatOGG, AudioFormatWebM,
+	AudioFormatM4A, AudioFormatMP4, AudioFormatAAC, AudioFormatAMR,
+}
+
+// audioHeaderSize is the size of the beginning of the files read to detect their format
+const audioHeaderSize = 512
+
+// WavInfo describes the PCM stream of a wav file
+type WavInfo struct {
+	// AudioFormat is 1 for PCM, 3 for IEEE float
+	AudioFormat   int
+	Channels      int
+	SampleRate    int
+	BitsPerSample int
+}
+
+// DetectAudioFormat returns the format of the audio file from its content, regardless of its name.
+// It returns an empty string if the format is unknown
+func DetectAudioFormat(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	header := make([]byte, audioHeaderSize)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	return DetectAudioFormatFromHeader(header[:n]), nil
+}
+
+// DetectAudioFormatFromHeader returns the format of the audio from the magic numbers at its beginning
+func DetectAudioFormatFromHeader(header []byte) string {
+	switch {
+	case len(header) >= 12 && bytes.Equal(header[:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WAVE")):
+		return AudioFormatWAV
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return AudioFormatFLAC
+	case bytes.HasPrefix(header, []byte("OggS")):
+		return AudioFormatOGG
+	case bytes.HasPrefix(header, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		// matroska, the container of webm
+		return AudioFormatWebM
+	case bytes.HasPrefix(header, []byte("#!AMR")):
+		return AudioFormatAMR
+	case len(header) >= 12 && bytes.Equal(header[4:8], []byte("ftyp")):
+		if brand := string(header[8:12]); brand == "M4A " || brand == "M4B " {
+			return AudioFormatM4A
+		}
+		return AudioFormatMP4
+	case bytes.HasPrefix(header, []byte("ID3")):
+		return AudioFormatMP3
+	case len(header) >= 2 && header[0] == 0xFF && header[1]&0xF6 == 0xF0:
+		// ADTS frame: the sync word followed by the layer bits set to 0
+		return AudioFormatAAC
+	case len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0 && header[1]&0x06 != 0:
+		// MPEG audio frame: the sync word followed by a layer
+		return AudioFormatMP3
+	}
+	return ""
+}
+
+// ReadWavInfo reads the format of the PCM stream of a wav file
+func ReadWavInfo(path string) (*WavInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return nil, err
+	}
+	if DetectAudioFormatFromHeader(header) != AudioFormatWAV {
+		return nil, fmt.Errorf("%s is not a wav file", path)
+	}
+	// the chunks following the header, looking for the "fmt " one
+	for {
+		chunk := make([]byte, 8)
+		if _, err := io.ReadFull(f, chunk); err != nil {
+			return nil, err
+		}
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		if !bytes.Equal(chunk[:4], []byte("fmt ")) {
+			// the chunks are padded to an even size
+			if _, err := f.Seek(size+size%2, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if size < 16 {
+			return nil, fmt.Errorf("invalid fmt chunk in %s", path)
+		}
+		fmtChunk := make([]byte, 16)
+		if _, err := io.ReadFull(f, fmtChunk); err != nil {
+			return nil, err
+		}
+		return &WavInfo{
+			AudioFormat:   int(binary.LittleEndian.Uint16(fmtChunk[0:])),
+			Channels:      int(binary.LittleEndian.Uint16(fmtChunk[2:])),
+			SampleRate:    int(binary.LittleEndian.Uint32(fmtChunk[4:])),
+			BitsPerSample: int(binary.LittleEndian.Uint16(fmtChunk[14:])),
+		}, nil
+	}
+}
+
+// WavDuration returns the duration of the PCM stream of a wav file, from the size of its data chunk
+func WavDuration(path string) (time.Duration, error) {
+	info, err := ReadWavInfo(path)
+	if err != nil {
+		return 0, err
+	}
+	bytesPerSecond := info.SampleRate * info.Channels * info.BitsPerSample / 8
+	if bytesPerSecond <= 0 {
+		return 0, fmt.Errorf("invalid fmt chunk in %s", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(12, io.SeekStart); err != nil {
+		return 0, err
+	}
+	for {
+		chunk := make([]byte, 8)
+		if _, err := io.ReadFull(f, chunk); err != nil {
+			return 0, fmt.Errorf("no data chunk in %s: %w", path, err)
+		}
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		if bytes.Equal(chunk[:4], []byte("data")) {
+			return time.Duration(size) * time.Second / time.Duration(bytesPerSecond), nil
+		}
+		if _, err := f.Seek(size+size%2, io.SeekCurrent); err != nil {
+			return 0, err
+		}
+	}
+}
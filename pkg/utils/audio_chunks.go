@@ -0,0 +1,209 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+)
+
+// audioFrame is a frame of an audio stream, ending at end in the stream
+type audioFrame struct {
+	end      int
+	duration time.Duration
+}
+
+// ChunkAudio splits the audio in chunks of at least size bytes, or lasting at least duration when it is set,
+// whose boundaries are frame boundaries: the blocks of samples of the wav files, the frames of the mp3 and aac (ADTS)
+// files and the pages of the ogg files. The headers are sent with the first chunk. The audio in other formats, or
+// which cannot be parsed, is returned in a single chunk
+func ChunkAudio(data []byte, size int, duration time.Duration) [][]byte {
+	if size <= 0 && duration <= 0 {
+		return [][]byte{data}
+	}
+	var frames []audioFrame
+	switch DetectAudioFormatFromHeader(data[:min(len(data), audioHeaderSize)]) {
+	case AudioFormatWAV:
+		return chunkWav(data, size, duration)
+	case AudioFormatMP3:
+		frames = mp3Frames(data)
+	case AudioFormatAAC:
+		frames = adtsFrames(data)
+	case AudioFormatOGG:
+		frames = oggPages(data)
+	}
+	if len(frames) == 0 {
+		return [][]byte{data}
+	}
+
+	// the data which cannot be parsed after the last frame (e.g. an ID3v1 tag) goes with the last chunk
+	frames[len(frames)-1].end = len(data)
+	chunks := [][]byte{}
+	start := 0
+	var elapsed time.Duration
+	for _, f := range frames {
+		elapsed += f.duration
+		if (duration > 0 && elapsed >= duration) || (duration <= 0 && f.end-start >= size) {
+			chunks = append(chunks, data[start:f.end])
+			start, elapsed = f.end, 0
+		}
+	}
+	if start < len(data) {
+		chunks = append(chunks, data[start:])
+	}
+	return chunks
+}
+
+// chunkWav splits a wav file in chunks of whole blocks of samples
+func chunkWav(data []byte, size int, duration time.Duration) [][]byte {
+	blockAlign, byteRate, dataStart := 0, 0, 0
+	for off := 12; off+8 <= len(data); {
+		id, chunkSize := data[off:off+4], int(binary.LittleEndian.Uint32(data[off+4:]))
+		if bytes.Equal(id, []byte("data")) {
+			dataStart = off + 8
+			break
+		}
+		if bytes.Equal(id, []byte("fmt ")) && off+24 <= len(data) {
+			byteRate = int(binary.LittleEndian.Uint32(data[off+16:]))
+			blockAlign = int(binary.LittleEndian.Uint16(data[off+20:]))
+		}
+		// the chunks are padded to an even size
+		off += 8 + chunkSize + chunkSize%2
+	}
+	if dataStart == 0 || blockAlign <= 0 {
+		return [][]byte{data}
+	}
+
+	step := size
+	if duration > 0 {
+		step = int(int64(byteRate) * int64(duration) / int64(time.Second))
+	}
+	step = max(blockAlign, (step+blockAlign-1)/blockAlign*blockAlign)
+
+	chunks := [][]byte{}
+	for start, end := 0, min(len(data), dataStart+step); start < len(data); start, end = end, min(len(data), end+step) {
+		chunks = append(chunks, data[start:end])
+	}
+	return chunks
+}
+
+var (
+	// mp3Bitrates are the bitrates (kbps) by bitrate index of MPEG-1 layers I, II and III, and of MPEG-2 layers I, II and III
+	mp3Bitrates = [6][15]int{
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	}
+	// mp3SampleRates are the sample rates by sample rate index of MPEG-1, MPEG-2 and MPEG-2.5
+	mp3SampleRates  = [3][3]int{{44100, 48000, 32000}, {22050, 24000, 16000}, {11025, 12000, 8000}}
+	adtsSampleRates = []int{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+)
+
+// mp3Frames returns the frames of an mp3 file, the ID3v2 tag going with the first one
+func mp3Frames(data []byte) []audioFrame {
+	off := 0
+	if len(data) >= 10 && bytes.HasPrefix(data, []byte("ID3")) {
+		// the size of the tag is a syncsafe integer, excluding the header and the footer
+		off = 10 + (int(data[6]&0x7f)<<21 | int(data[7]&0x7f)<<14 | int(data[8]&0x7f)<<7 | int(data[9]&0x7f))
+		if data[5]&0x10 != 0 {
+			off += 10
+		}
+	}
+
+	frames := []audioFrame{}
+	for off+4 <= len(data) {
+		h := data[off:]
+		version, layer := (h[1]>>3)&3, (h[1]>>1)&3
+		bitrateIndex, sampleRateIndex, padding := int(h[2]>>4), int(h[2]>>2)&3, int(h[2]>>1)&1
+		if h[0] != 0xff || h[1]&0xe0 != 0xe0 || version == 1 || layer == 0 || bitrateIndex == 0 || bitrateIndex == 15 || sampleRateIndex == 3 {
+			break
+		}
+
+		// version is 3 for MPEG-1, 2 for MPEG-2 and 0 for MPEG-2.5, layer is 3 for layer I and 1 for layer III
+		mpeg1 := version == 3
+		table, rates := 3-int(layer), mp3SampleRates[0]
+		if !mpeg1 {
+			table += 3
+			rates = mp3SampleRates[1]
+			if version == 0 {
+				rates = mp3SampleRates[2]
+			}
+		}
+		bitrate, sampleRate := mp3Bitrates[table][bitrateIndex]*1000, rates[sampleRateIndex]
+
+		samples, length := 1152, 0
+		switch {
+		case layer == 3:
+			samples = 384
+			length = (12*bitrate/sampleRate + padding) * 4
+		case layer == 1 && !mpeg1:
+			samples = 576
+			length = samples/8*bitrate/sampleRate + padding
+		default:
+			length = samples/8*bitrate/sampleRate + padding
+		}
+		if off+length > len(data) {
+			break
+		}
+		off += length
+		frames = append(frames, audioFrame{end: off, duration: time.Duration(samples) * time.Second / time.Duration(sampleRate)})
+	}
+	return frames
+}
+
+// adtsFrames returns the frames of an aac (ADTS) file
+func adtsFrames(data []byte) []audioFrame {
+	frames := []audioFrame{}
+	for off := 0; off+7 <= len(data); {
+		h := data[off:]
+		sampleRateIndex := int(h[2]>>2) & 0xf
+		length := int(h[3]&3)<<11 | int(h[4])<<3 | int(h[5])>>5
+		if h[0] != 0xff || h[1]&0xf6 != 0xf0 || sampleRateIndex >= len(adtsSampleRates) || length < 7 || off+length > len(data) {
+			break
+		}
+		// each raw data block holds 1024 samples
+		samples := 1024 * (int(h[6]&3) + 1)
+		off += length
+		frames = append(frames, audioFrame{end: off, duration: time.Duration(samples) * time.Second / time.Duration(adtsSampleRates[sampleRateIndex])})
+	}
+	return frames
+}
+
+// oggPages returns the pages of an ogg file. Their duration is only known for the opus streams, whose granule
+// positions count the samples at 48 kHz
+func oggPages(data []byte) []audioFrame {
+	pages := []audioFrame{}
+	opus := false
+	var granule uint64
+	for off := 0; off+27 <= len(data); {
+		p := data[off:]
+		segments := int(p[26])
+		if !bytes.HasPrefix(p, []byte("OggS")) || off+27+segments > len(data) {
+			break
+		}
+		length := 27 + segments
+		for _, s := range p[27 : 27+segments] {
+			length += int(s)
+		}
+		if off+length > len(data) {
+			break
+		}
+		if off == 0 {
+			opus = bytes.HasPrefix(p[27+segments:], []byte("OpusHead"))
+		}
+
+		var duration time.Duration
+		// the pages where no packet ends have no granule position
+		if position := binary.LittleEndian.Uint64(p[6:]); position != ^uint64(0) {
+			if opus && position > granule {
+				duration = time.Duration(position-granule) * time.Second / 48000
+			}
+			granule = position
+		}
+		off += length
+		pages = append(pages, audioFrame{end: off, duration: duration})
+	}
+	return pages
+}
@@ -0,0 +1,113 @@
+package utils_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	. "github.com/mudler/LocalAI/pkg/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// mp3Frame returns an MPEG-1 layer III frame at 128 kbps and 44.1 kHz, of 417 bytes (418 with padding)
+func mp3Frame(padding bool) []byte {
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x64})
+	if padding {
+		frame[2] |= 0x02
+		frame = append(frame, 0)
+	}
+	return frame
+}
+
+// adtsFrame returns an aac frame at 44.1 kHz of the given length
+func adtsFrame(length int) []byte {
+	frame := make([]byte, length)
+	copy(frame, []byte{0xFF, 0xF1, 0x50, 0x80 | byte(length>>11)&3, byte(length >> 3), byte(length&7)<<5 | 0x1F, 0xFC})
+	return frame
+}
+
+// oggPage returns an ogg page with the payload and the granule position
+func oggPage(payload []byte, granule uint64) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("OggS")
+	buf.Write([]byte{0, 0})
+	binary.Write(&buf, binary.LittleEndian, granule)
+	buf.Write(make([]byte, 12))
+	segments := []byte{}
+	for n := len(payload); ; n -= 255 {
+		segments = append(segments, byte(min(n, 255)))
+		if n < 255 {
+			break
+		}
+	}
+	buf.WriteByte(byte(len(segments)))
+	buf.Write(segments)
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+// expectChunks checks that the chunks are the audio split at the given offsets
+func expectChunks(chunks [][]byte, audio []byte, ends ...int) {
+	Expect(bytes.Join(chunks, nil)).To(Equal(audio))
+	offsets := []int{}
+	end := 0
+	for _, c := range chunks {
+		end += len(c)
+		offsets = append(offsets, end)
+	}
+	Expect(offsets).To(Equal(ends))
+}
+
+var _ = Describe("utils/audio chunks", func() {
+	It("splits the wav files in whole blocks of samples", func() {
+		header := testWav(16000, 2, 16)
+		wav := append(header, make([]byte, 100)...)
+		// the blocks of stereo 16 bits samples are 4 bytes
+		expectChunks(ChunkAudio(wav, 30, 0), wav, len(header)+32, len(header)+64, len(header)+96, len(wav))
+
+		header = testWav(16000, 1, 16)
+		wav = append(header, make([]byte, 16000*2)...)
+		chunks := ChunkAudio(wav, 0, 250*time.Millisecond)
+		expectChunks(chunks, wav, len(header)+8000, len(header)+16000, len(header)+24000, len(wav))
+	})
+
+	It("splits the mp3 files in frames", func() {
+		tag := append([]byte("ID3\x04\x00\x00\x00\x00\x00\x14"), make([]byte, 20)...)
+		mp3 := bytes.Join([][]byte{tag, mp3Frame(false), mp3Frame(true), mp3Frame(false), []byte("TAG")}, nil)
+		expectChunks(ChunkAudio(mp3, 500, 0), mp3, 30+417+418, len(mp3))
+		expectChunks(ChunkAudio(mp3, 1, 0), mp3, 30+417, 30+417+418, len(mp3))
+
+		// the frames last 1152 samples, about 26 ms
+		mp3 = bytes.Repeat(mp3Frame(false), 8)
+		expectChunks(ChunkAudio(mp3, 0, 100*time.Millisecond), mp3, 4*417, 8*417)
+	})
+
+	It("splits the aac files in frames", func() {
+		aac := bytes.Join([][]byte{adtsFrame(300), adtsFrame(200), adtsFrame(400), adtsFrame(250)}, nil)
+		expectChunks(ChunkAudio(aac, 400, 0), aac, 500, 900, 1150)
+		// the frames last 1024 samples, about 23 ms
+		expectChunks(ChunkAudio(aac, 0, 40*time.Millisecond), aac, 500, 1150)
+	})
+
+	It("splits the ogg files in pages", func() {
+		head := oggPage([]byte("OpusHead\x01\x01"), 0)
+		tags := oggPage([]byte("OpusTags"), 0)
+		audio := bytes.Repeat([]byte{1}, 600)
+		// the opus granule positions count the samples at 48 kHz, 20 ms are 960 samples
+		ogg := bytes.Join([][]byte{head, tags, oggPage(audio, 960), oggPage(audio, 1920), oggPage(audio, 2880)}, nil)
+		page := len(oggPage(audio, 0))
+
+		expectChunks(ChunkAudio(ogg, 0, 40*time.Millisecond), ogg, len(head)+len(tags)+2*page, len(ogg))
+		expectChunks(ChunkAudio(ogg, page, 0), ogg, len(head)+len(tags)+page, len(head)+len(tags)+2*page, len(ogg))
+	})
+
+	It("sends the audio which cannot be split at once", func() {
+		flac := append([]byte("fLaC\x00\x00\x00\x22"), make([]byte, 1000)...)
+		expectChunks(ChunkAudio(flac, 100, 0), flac, len(flac))
+
+		wav := append(testWav(16000, 1, 16), make([]byte, 100)...)
+		expectChunks(ChunkAudio(wav, 0, 0), wav, len(wav))
+	})
+})
@@ -0,0 +1,82 @@
+package utils_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/mudler/LocalAI/pkg/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// testWav returns a wav file header with an extra chunk before the fmt one
+func testWav(sampleRate, channels, bits int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(0))
+	buf.WriteString("WAVE")
+	buf.WriteString("LIST")
+	binary.Write(&buf, binary.LittleEndian, uint32(3))
+	buf.Write([]byte{1, 2, 3, 0})
+	buf.WriteString("fmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, uint16(1))
+	binary.Write(&buf, binary.LittleEndian, uint16(channels))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate*channels*bits/8))
+	binary.Write(&buf, binary.LittleEndian, uint16(channels*bits/8))
+	binary.Write(&buf, binary.LittleEndian, uint16(bits))
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(0))
+	return buf.Bytes()
+}
+
+var _ = Describe("utils/audio tests", func() {
+	DescribeTable("detects the audio formats from their content",
+		func(header []byte, format string) {
+			Expect(DetectAudioFormatFromHeader(header)).To(Equal(format))
+		},
+		Entry("wav", testWav(16000, 1, 16), AudioFormatWAV),
+		Entry("mp3 with ID3 tags", []byte("ID3\x04\x00\x00\x00\x00\x00\x00"), AudioFormatMP3),
+		Entry("mp3 frame", []byte{0xFF, 0xFB, 0x90, 0x64}, AudioFormatMP3),
+		Entry("aac", []byte{0xFF, 0xF1, 0x50, 0x80}, AudioFormatAAC),
+		Entry("flac", []byte("fLaC\x00\x00\x00\x22"), AudioFormatFLAC),
+		Entry("ogg", []byte("OggS\x00\x02\x00\x00"), AudioFormatOGG),
+		Entry("webm", []byte{0x1A, 0x45, 0xDF, 0xA3, 0x9F, 0x42, 0x86, 0x81}, AudioFormatWebM),
+		Entry("m4a", []byte("\x00\x00\x00\x20ftypM4A \x00\x00\x02\x00"), AudioFormatM4A),
+		Entry("mp4", []byte("\x00\x00\x00\x18ftypisom\x00\x00\x02\x00"), AudioFormatMP4),
+		Entry("amr", []byte("#!AMR\n"), AudioFormatAMR),
+		Entry("unknown", []byte("hello world"), ""),
+		Entry("empty", []byte{}, ""),
+	)
+
+	It("detects the format of the files regardless of their extension", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "audio.wav")
+		Expect(os.WriteFile(path, []byte("fLaC\x00\x00\x00\x22"), 0600)).To(Succeed())
+		Expect(DetectAudioFormat(path)).To(Equal(AudioFormatFLAC))
+	})
+
+	It("reads the format of wav files", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "audio.wav")
+		Expect(os.WriteFile(path, testWav(44100, 2, 16), 0600)).To(Succeed())
+		info, err := ReadWavInfo(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*info).To(Equal(WavInfo{AudioFormat: 1, Channels: 2, SampleRate: 44100, BitsPerSample: 16}))
+
+		Expect(os.WriteFile(path, []byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00"), 0600)).To(Succeed())
+		_, err = ReadWavInfo(path)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("reads the duration of wav files", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "audio.wav")
+		wav := testWav(16000, 1, 16)
+		// 5 seconds of 16 bits mono samples in the data chunk
+		binary.LittleEndian.PutUint32(wav[len(wav)-4:], 16000*2*5)
+		Expect(os.WriteFile(path, wav, 0600)).To(Succeed())
+		Expect(WavDuration(path)).To(Equal(5 * time.Second))
+	})
+})
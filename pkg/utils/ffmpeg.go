@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 )
 
@@ -17,7 +18,12 @@ func ffmpegCommand(args []string) (string, error) {
 // AudioToWav converts audio to wav for transcribe.
 // TODO: use https://github.com/mccoyst/ogg?
 func AudioToWav(src, dst string) error {
-	commandArgs := []string{"-i", src, "-format", "s16le", "-ar", "16000", "-ac", "1", "-acodec", "pcm_s16le", dst}
+	return AudioToWavWithSampleRate(src, dst, 16000)
+}
+
+// AudioToWavWithSampleRate converts audio to 16 bits mono wav with the given sample rate.
+func AudioToWavWithSampleRate(src, dst string, sampleRate int) error {
+	commandArgs := []string{"-i", src, "-format", "s16le", "-ar", strconv.Itoa(sampleRate), "-ac", "1", "-acodec", "pcm_s16le", dst}
 	out, err := ffmpegCommand(commandArgs)
 	if err != nil {
 		return fmt.Errorf("error: %w out: %s", err, out)
@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "golang.org/x/image/webp"
+)
+
+// ErrImageTooLarge is returned when an image exceeds the maximum allowed dimension and downscaling is disabled
+var ErrImageTooLarge = errors.New("image exceeds the maximum allowed dimension")
+
+// LimitImageSize makes sure that the longest side of the image is not larger than maxDim.
+// Bigger images are downscaled preserving the aspect ratio, or rejected with ErrImageTooLarge if reject is set.
+// It returns the (possibly re-encoded) image and whether it was downscaled.
+// A maxDim <= 0 disables the check.
+func LimitImageSize(data []byte, maxDim int, reject bool) ([]byte, bool, error) {
+	if maxDim <= 0 {
+		return data, false, nil
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if errors.Is(err, image.ErrFormat) {
+		// the formats without a decoder are left to the backends
+		return data, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed decoding image: %w", err)
+	}
+	if cfg.Width <= maxDim && cfg.Height <= maxDim {
+		return data, false, nil
+	}
+	if reject {
+		return nil, false, fmt.Errorf("%w: %dx%d (max %d)", ErrImageTooLarge, cfg.Width, cfg.Height, maxDim)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed decoding image: %w", err)
+	}
+
+	width, height := cfg.Width, cfg.Height
+	if width >= height {
+		height = max(1, height*maxDim/width)
+		width = maxDim
+	} else {
+		width = max(1, width*maxDim/height)
+		height = maxDim
+	}
+
+	resized := downscale(img, width, height)
+
+	// there is no webp encoder, the images other than jpeg are re-encoded as png
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, resized, &jpeg.Options{Quality: 90})
+	} else {
+		err = png.Encode(&buf, resized)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed encoding image: %w", err)
+	}
+
+	return buf.Bytes(), true, nil
+}
+
+// LimitBase64ImageSize is LimitImageSize for base64 encoded images
+func LimitBase64ImageSize(b64 string, maxDim int, reject bool) (string, bool, error) {
+	if maxDim <= 0 {
+		return b64, false, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return "", false, err
+	}
+	out, downscaled, err := LimitImageSize(data, maxDim, reject)
+	if err != nil || !downscaled {
+		return b64, false, err
+	}
+	return base64.StdEncoding.EncodeToString(out), true, nil
+}
+
+// downscale resizes the image by averaging the source pixels covered by each destination pixel (box filter)
+func downscale(src image.Image, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	b := src.Bounds()
+	srcW, srcH := b.Dx(), b.Dy()
+
+	for y := 0; y < height; y++ {
+		y0 := b.Min.Y + y*srcH/height
+		y1 := max(y0+1, b.Min.Y+(y+1)*srcH/height)
+		for x := 0; x < width; x++ {
+			x0 := b.Min.X + x*srcW/width
+			x1 := max(x0+1, b.Min.X+(x+1)*srcW/width)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					bl += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(bl / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
+
+// Encodings of the generated images
+const (
+	ImageEncodingPNG  = "png"
+	ImageEncodingJPEG = "jpeg"
+	ImageEncodingWebP = "webp"
+)
+
+// ValidImageEncoding returns true if the encoding is supported by TranscodeImage
+func ValidImageEncoding(encoding string) bool {
+	switch encoding {
+	case "", ImageEncodingPNG, ImageEncodingJPEG, ImageEncodingWebP:
+		return true
+	}
+	return false
+}
+
+// TranscodeImage converts the PNG image src to the given encoding and returns the path of the new file.
+// WebP images are encoded with ffmpeg (built with libwebp).
+// PNG (or an empty encoding) returns src as is
+func TranscodeImage(src, encoding string) (string, error) {
+	base := strings.TrimSuffix(src, filepath.Ext(src))
+	switch encoding {
+	case "", ImageEncodingPNG:
+		return src, nil
+	case ImageEncodingJPEG:
+		f, err := os.Open(src)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		img, _, err := image.Decode(f)
+		if err != nil {
+			return "", fmt.Errorf("failed decoding image: %w", err)
+		}
+
+		dst := base + ".jpg"
+		out, err := os.Create(dst)
+		if err != nil {
+			return "", err
+		}
+		defer out.Close()
+		if err := jpeg.Encode(out, img, &jpeg.Options{Quality: 90}); err != nil {
+			return "", err
+		}
+		return dst, nil
+	case ImageEncodingWebP:
+		dst := base + ".webp"
+		out, err := ffmpegCommand([]string{"-y", "-i", src, "-c:v", "libwebp", "-quality", "90", dst})
+		if err != nil {
+			return "", fmt.Errorf("error: %w out: %s", err, out)
+		}
+		return dst, nil
+	}
+	return "", fmt.Errorf("unsupported image encoding: %s", encoding)
+}
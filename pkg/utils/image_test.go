@@ -0,0 +1,108 @@
+package utils_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	. "github.com/mudler/LocalAI/pkg/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func testPNG(width, height int) []byte {
+	var buf bytes.Buffer
+	Expect(png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height)))).To(Succeed())
+	return buf.Bytes()
+}
+
+var _ = Describe("utils/image tests", func() {
+	It("leaves images within the limit untouched", func() {
+		data := testPNG(100, 50)
+		out, downscaled, err := LimitImageSize(data, 100, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(downscaled).To(BeFalse())
+		Expect(out).To(Equal(data))
+	})
+
+	It("downscales preserving the aspect ratio", func() {
+		out, downscaled, err := LimitImageSize(testPNG(400, 200), 100, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(downscaled).To(BeTrue())
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(out))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.Width).To(Equal(100))
+		Expect(cfg.Height).To(Equal(50))
+	})
+
+	It("rejects oversized images if requested", func() {
+		_, _, err := LimitImageSize(testPNG(50, 400), 100, true)
+		Expect(err).To(MatchError(ErrImageTooLarge))
+	})
+
+	It("downscales webp images", func() {
+		// a 400x200 lossless webp image
+		data, err := base64.StdEncoding.DecodeString("UklGRhYAAABXRUJQVlA4TAkAAAAvj8ExAIiI/gcA")
+		Expect(err).ToNot(HaveOccurred())
+
+		out, downscaled, err := LimitImageSize(data, 400, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(downscaled).To(BeFalse())
+		Expect(out).To(Equal(data))
+
+		out, downscaled, err = LimitImageSize(data, 100, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(downscaled).To(BeTrue())
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(out))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(format).To(Equal("png"))
+		Expect(cfg.Width).To(Equal(100))
+		Expect(cfg.Height).To(Equal(50))
+
+		_, _, err = LimitImageSize(data, 100, true)
+		Expect(err).To(MatchError(ErrImageTooLarge))
+	})
+
+	It("leaves the images of unknown formats to the backends", func() {
+		out, downscaled, err := LimitImageSize([]byte("BM not a supported format"), 100, true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(downscaled).To(BeFalse())
+		Expect(out).To(Equal([]byte("BM not a supported format")))
+	})
+
+	It("is disabled with a zero limit", func() {
+		out, downscaled, err := LimitImageSize([]byte("not an image"), 0, true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(downscaled).To(BeFalse())
+		Expect(out).To(Equal([]byte("not an image")))
+	})
+
+	It("transcodes PNG images", func() {
+		dir, err := os.MkdirTemp("", "")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		src := filepath.Join(dir, "image.png")
+		Expect(os.WriteFile(src, testPNG(37, 21), 0600)).To(Succeed())
+
+		dst, err := TranscodeImage(src, ImageEncodingPNG)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(dst).To(Equal(src))
+
+		dst, err = TranscodeImage(src, ImageEncodingJPEG)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(dst).To(Equal(filepath.Join(dir, "image.jpg")))
+		data, err := os.ReadFile(dst)
+		Expect(err).ToNot(HaveOccurred())
+		_, format, err := image.DecodeConfig(bytes.NewReader(data))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(format).To(Equal("jpeg"))
+
+		Expect(ValidImageEncoding("gif")).To(BeFalse())
+		_, err = TranscodeImage(src, "gif")
+		Expect(err).To(HaveOccurred())
+	})
+})
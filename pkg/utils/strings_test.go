@@ -0,0 +1,20 @@
+package utils_test
+
+import (
+	. "github.com/mudler/LocalAI/pkg/utils"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("EditDistance", func() {
+	It("counts the edits between two strings", func() {
+		Expect(EditDistance("", "")).To(Equal(0))
+		Expect(EditDistance("llama", "llama")).To(Equal(0))
+		Expect(EditDistance("", "phi")).To(Equal(3))
+		Expect(EditDistance("lama", "llama")).To(Equal(1))
+		Expect(EditDistance("gpt4", "gtp4")).To(Equal(2))
+		Expect(EditDistance("kitten", "sitting")).To(Equal(3))
+		Expect(EditDistance("modèle", "modele")).To(Equal(1))
+	})
+})
@@ -0,0 +1,96 @@
+package xsysinfo
+
+import (
+	"sync"
+	"time"
+)
+
+// DeviceSampler samples the memory of the system and of the GPUs, and optionally the utilization of the GPUs, in the
+// background at an interval, so that the readers, such as the metrics scrapes, never query the devices themselves
+type DeviceSampler struct {
+	interval time.Duration
+	memory   func() ([]MemoryUsage, error)
+	// gpus is nil when the utilization of the GPUs is not sampled
+	gpus func() ([]GPUUtilization, error)
+
+	mu              sync.Mutex
+	memoryUsages    []MemoryUsage
+	memoryErr       error
+	gpuUtilizations []GPUUtilization
+	gpuErr          error
+
+	start sync.Once
+	stop  sync.Once
+	done  chan struct{}
+}
+
+// NewDeviceSampler returns a sampler querying the devices at the interval, once it is started. The utilization of the
+// GPUs is only sampled with gpuUtilization
+func NewDeviceSampler(interval time.Duration, gpuUtilization bool) *DeviceSampler {
+	s := &DeviceSampler{
+		interval: interval,
+		memory:   MemoryUsages,
+		done:     make(chan struct{}),
+	}
+	if gpuUtilization {
+		s.gpus = GPUUtilizations
+	}
+	return s
+}
+
+// Start takes the first sample and starts sampling in the background, until the sampler is stopped
+func (s *DeviceSampler) Start() {
+	s.start.Do(func() {
+		s.sample()
+		go s.run()
+	})
+}
+
+// Stop stops the sampling in the background
+func (s *DeviceSampler) Stop() {
+	s.stop.Do(func() {
+		close(s.done)
+	})
+}
+
+// MemoryUsages returns the latest sample of the memory usages, and the error of the query if it failed
+func (s *DeviceSampler) MemoryUsages() ([]MemoryUsage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.memoryUsages, s.memoryErr
+}
+
+// GPUUtilizations returns the latest sample of the utilization of the GPUs, and the error of the query if it failed.
+// It returns none when the utilization is not sampled
+func (s *DeviceSampler) GPUUtilizations() ([]GPUUtilization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gpuUtilizations, s.gpuErr
+}
+
+func (s *DeviceSampler) run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.sample()
+		}
+	}
+}
+
+func (s *DeviceSampler) sample() {
+	usages, err := s.memory()
+	var utilizations []GPUUtilization
+	var gpuErr error
+	if s.gpus != nil {
+		utilizations, gpuErr = s.gpus()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.memoryUsages, s.memoryErr = usages, err
+	s.gpuUtilizations, s.gpuErr = utilizations, gpuErr
+}
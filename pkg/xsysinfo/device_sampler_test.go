@@ -0,0 +1,54 @@
+package xsysinfo
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceSampler(t *testing.T) {
+	var queries atomic.Int32
+	sampler := NewDeviceSampler(10*time.Millisecond, false)
+	sampler.memory = func() ([]MemoryUsage, error) {
+		n := queries.Add(1)
+		return []MemoryUsage{{Device: "system", Total: 16 << 30, Free: uint64(n) << 30}}, nil
+	}
+
+	sampler.Start()
+	// the first sample is taken on start
+	usages, err := sampler.MemoryUsages()
+	require.NoError(t, err)
+	assert.Equal(t, []MemoryUsage{{Device: "system", Total: 16 << 30, Free: 1 << 30}}, usages)
+
+	// the readers do not query the devices
+	require.Eventually(t, func() bool { return queries.Load() >= 3 }, time.Second, time.Millisecond)
+	sampler.Stop()
+	time.Sleep(20 * time.Millisecond)
+	n := queries.Load()
+	for range 10 {
+		usages, _ = sampler.MemoryUsages()
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, n, queries.Load())
+	assert.Equal(t, uint64(n)<<30, usages[0].Free)
+	utilizations, err := sampler.GPUUtilizations()
+	assert.NoError(t, err)
+	assert.Empty(t, utilizations)
+}
+
+func TestDeviceSamplerGPUUtilization(t *testing.T) {
+	sampler := NewDeviceSampler(time.Hour, true)
+	sampler.memory = func() ([]MemoryUsage, error) { return nil, nil }
+	sampler.gpus = func() ([]GPUUtilization, error) {
+		return []GPUUtilization{{Device: "gpu0", Utilization: 42, MemoryUsed: 1 << 30, MemoryTotal: 8 << 30}}, nil
+	}
+	sampler.Start()
+	defer sampler.Stop()
+
+	utilizations, err := sampler.GPUUtilizations()
+	require.NoError(t, err)
+	assert.Equal(t, []GPUUtilization{{Device: "gpu0", Utilization: 42, MemoryUsed: 1 << 30, MemoryTotal: 8 << 30}}, utilizations)
+}
@@ -1,6 +1,11 @@
 package xsysinfo
 
 import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
 	"github.com/jaypipes/ghw"
 	"github.com/jaypipes/ghw/pkg/gpu"
 )
@@ -13,3 +18,57 @@ func GPUs() ([]*gpu.GraphicsCard, error) {
 
 	return gpu.GraphicsCards, nil
 }
+
+// GPUUtilization is the utilization of a GPU at a point in time
+type GPUUtilization struct {
+	Device string
+	// Utilization is the percentage of time the GPU was busy over the last sample period of the driver
+	Utilization float64
+	MemoryUsed  uint64
+	MemoryTotal uint64
+}
+
+// GPUUtilizations returns the utilization of the NVIDIA GPUs, or none when nvidia-smi is not available
+func GPUUtilizations() ([]GPUUtilization, error) {
+	if _, err := exec.LookPath("nvidia-smi"); err != nil {
+		return nil, nil
+	}
+	out, err := exec.Command("nvidia-smi", "--query-gpu=index,utilization.gpu,memory.used,memory.total", "--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed querying the GPU utilization: %w", err)
+	}
+	return parseNvidiaSMIUtilization(string(out))
+}
+
+// parseNvidiaSMIUtilization parses the "index, utilization, used, total" CSV lines (memory in MiB) returned by nvidia-smi
+func parseNvidiaSMIUtilization(out string) ([]GPUUtilization, error) {
+	utilizations := []GPUUtilization{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected nvidia-smi output: %q", line)
+		}
+		utilization, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected nvidia-smi output: %q", line)
+		}
+		used, err := strconv.ParseUint(strings.TrimSpace(fields[2]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected nvidia-smi output: %q", line)
+		}
+		total, err := strconv.ParseUint(strings.TrimSpace(fields[3]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected nvidia-smi output: %q", line)
+		}
+		utilizations = append(utilizations, GPUUtilization{
+			Device:      "gpu" + strings.TrimSpace(fields[0]),
+			Utilization: utilization,
+			MemoryUsed:  used * 1024 * 1024,
+			MemoryTotal: total * 1024 * 1024,
+		})
+	}
+	return utilizations, nil
+}
@@ -0,0 +1,123 @@
+package xsysinfo
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// GPUStats summarizes the utilization of a GPU sampled during a recording
+type GPUStats struct {
+	Device             string  `json:"device"`
+	Samples            int     `json:"samples"`
+	AverageUtilization float64 `json:"avg_utilization_percent"`
+	MaxUtilization     float64 `json:"max_utilization_percent"`
+	MaxMemoryUsed      uint64  `json:"max_memory_used_bytes"`
+	MemoryTotal        uint64  `json:"memory_total_bytes"`
+}
+
+// GPUSampler samples the utilization of the GPUs at an interval, only while there are recordings in progress.
+// The samples are shared by the concurrent recordings, so that the GPUs are queried once per interval at most
+type GPUSampler struct {
+	query    func() ([]GPUUtilization, error)
+	interval time.Duration
+
+	mu         sync.Mutex
+	recordings map[*GPURecording]struct{}
+	running    bool
+	last       []GPUUtilization
+}
+
+// NewGPUSampler returns a sampler querying the GPUs with the query function, usually GPUUtilizations
+func NewGPUSampler(query func() ([]GPUUtilization, error), interval time.Duration) *GPUSampler {
+	return &GPUSampler{query: query, interval: interval, recordings: map[*GPURecording]struct{}{}}
+}
+
+// Start starts recording the utilization of the GPUs, until the recording is stopped
+func (s *GPUSampler) Start() *GPURecording {
+	r := &GPURecording{sampler: s, devices: map[string]*GPUStats{}}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordings[r] = struct{}{}
+	if !s.running {
+		s.running = true
+		go s.run()
+	}
+	return r
+}
+
+// Last returns the latest sample of the GPUs, if any was taken
+func (s *GPUSampler) Last() []GPUUtilization {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+func (s *GPUSampler) run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		sample, err := s.query()
+		if err != nil {
+			log.Debug().Err(err).Msg("failed sampling the GPUs")
+		}
+
+		s.mu.Lock()
+		if err == nil {
+			s.last = sample
+			for r := range s.recordings {
+				r.add(sample)
+			}
+		}
+		if len(s.recordings) == 0 {
+			s.running = false
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
+		<-ticker.C
+	}
+}
+
+// GPURecording collects the samples of the GPUs taken while it is in progress
+type GPURecording struct {
+	sampler *GPUSampler
+	devices map[string]*GPUStats
+	order   []string
+}
+
+// add is called with the lock of the sampler held
+func (r *GPURecording) add(sample []GPUUtilization) {
+	for _, u := range sample {
+		stats, ok := r.devices[u.Device]
+		if !ok {
+			stats = &GPUStats{Device: u.Device}
+			r.devices[u.Device] = stats
+			r.order = append(r.order, u.Device)
+		}
+		// the running average of the utilization
+		stats.Samples++
+		stats.AverageUtilization += (u.Utilization - stats.AverageUtilization) / float64(stats.Samples)
+		stats.MaxUtilization = max(stats.MaxUtilization, u.Utilization)
+		stats.MaxMemoryUsed = max(stats.MaxMemoryUsed, u.MemoryUsed)
+		stats.MemoryTotal = u.MemoryTotal
+	}
+}
+
+// Stop stops the recording and returns the statistics of every GPU sampled while it was in progress.
+// Recordings shorter than the interval may have no sample, the latest one is used then
+func (r *GPURecording) Stop() []GPUStats {
+	s := r.sampler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.recordings, r)
+	if len(r.order) == 0 {
+		r.add(s.last)
+	}
+	stats := make([]GPUStats, 0, len(r.order))
+	for _, device := range r.order {
+		stats = append(stats, *r.devices[device])
+	}
+	return stats
+}
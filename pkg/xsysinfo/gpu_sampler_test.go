@@ -0,0 +1,58 @@
+package xsysinfo
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNvidiaSMIUtilization(t *testing.T) {
+	utilizations, err := parseNvidiaSMIUtilization("0, 87, 20480, 24576\n1, 3, 512, 24576\n")
+	require.NoError(t, err)
+	assert.Equal(t, []GPUUtilization{
+		{Device: "gpu0", Utilization: 87, MemoryUsed: 20480 << 20, MemoryTotal: 24576 << 20},
+		{Device: "gpu1", Utilization: 3, MemoryUsed: 512 << 20, MemoryTotal: 24576 << 20},
+	}, utilizations)
+
+	_, err = parseNvidiaSMIUtilization("0, [N/A], 512, 24576")
+	assert.Error(t, err)
+}
+
+func TestGPUSampler(t *testing.T) {
+	var queries atomic.Int32
+	sampler := NewGPUSampler(func() ([]GPUUtilization, error) {
+		n := queries.Add(1)
+		return []GPUUtilization{
+			{Device: "gpu0", Utilization: float64(n * 10), MemoryUsed: uint64(n) << 30, MemoryTotal: 8 << 30},
+			{Device: "gpu1", Utilization: 0, MemoryUsed: 1 << 30, MemoryTotal: 8 << 30},
+		}, nil
+	}, 10*time.Millisecond)
+
+	recording := sampler.Start()
+	require.Eventually(t, func() bool { return queries.Load() >= 3 }, time.Second, time.Millisecond)
+	stats := recording.Stop()
+
+	require.Len(t, stats, 2)
+	assert.Equal(t, "gpu0", stats[0].Device)
+	assert.GreaterOrEqual(t, stats[0].Samples, 3)
+	assert.Equal(t, float64(stats[0].Samples*10), stats[0].MaxUtilization)
+	assert.InDelta(t, float64((stats[0].Samples+1)*5), stats[0].AverageUtilization, 0.001)
+	assert.Equal(t, uint64(stats[0].Samples)<<30, stats[0].MaxMemoryUsed)
+	assert.Equal(t, uint64(8<<30), stats[0].MemoryTotal)
+	assert.Equal(t, "gpu1", stats[1].Device)
+	assert.Zero(t, stats[1].MaxUtilization)
+
+	// the sampling stops without recordings
+	require.Eventually(t, func() bool {
+		sampler.mu.Lock()
+		defer sampler.mu.Unlock()
+		return !sampler.running
+	}, time.Second, time.Millisecond)
+	n := queries.Load()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, n, queries.Load())
+	assert.NotEmpty(t, sampler.Last())
+}
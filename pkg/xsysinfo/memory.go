@@ -0,0 +1,84 @@
+package xsysinfo
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/mem"
+)
+
+// MemoryUsage is the memory usage of the system or of a GPU, in bytes
+type MemoryUsage struct {
+	Device string
+	Total  uint64
+	Free   uint64
+}
+
+// FreePercent returns the percentage of free memory of the device
+func (m MemoryUsage) FreePercent() float64 {
+	if m.Total == 0 {
+		return 100
+	}
+	return float64(m.Free) * 100 / float64(m.Total)
+}
+
+// TotalMemory returns the total memory of the system, in bytes
+func TotalMemory() (uint64, error) {
+	vm, err := mem.VirtualMemory()
+	if err != nil {
+		return 0, err
+	}
+	return vm.Total, nil
+}
+
+// MemoryUsages returns the memory usage of the system and, when nvidia-smi is available, of the NVIDIA GPUs
+func MemoryUsages() ([]MemoryUsage, error) {
+	vm, err := mem.VirtualMemory()
+	if err != nil {
+		return nil, err
+	}
+	usages := []MemoryUsage{{Device: "system", Total: vm.Total, Free: vm.Available}}
+
+	if _, err := exec.LookPath("nvidia-smi"); err != nil {
+		return usages, nil
+	}
+	out, err := exec.Command("nvidia-smi", "--query-gpu=index,memory.total,memory.free", "--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return usages, fmt.Errorf("failed querying the GPU memory: %w", err)
+	}
+	gpus, err := parseNvidiaSMIMemory(string(out))
+	if err != nil {
+		return usages, err
+	}
+	return append(usages, gpus...), nil
+}
+
+// parseNvidiaSMIMemory parses the "index, total, free" CSV lines (in MiB) returned by nvidia-smi
+func parseNvidiaSMIMemory(out string) ([]MemoryUsage, error) {
+	usages := []MemoryUsage{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected nvidia-smi output: %q", line)
+		}
+		total, err := strconv.ParseUint(strings.TrimSpace(fields[1]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected nvidia-smi output: %q", line)
+		}
+		free, err := strconv.ParseUint(strings.TrimSpace(fields[2]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected nvidia-smi output: %q", line)
+		}
+		usages = append(usages, MemoryUsage{
+			Device: "gpu" + strings.TrimSpace(fields[0]),
+			Total:  total * 1024 * 1024,
+			Free:   free * 1024 * 1024,
+		})
+	}
+	return usages, nil
+}
@@ -0,0 +1,109 @@
+package e2e_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// BenchmarkTimeToFirstToken measures the time to the first token of the streamed chat completions while other
+// completions are generated, and the throughput of the latter, for each of the models in LOCALAI_BENCHMARK_MODELS
+// (e.g. the same model with and without first_token.priority). The number of the background completions is set
+// with LOCALAI_BENCHMARK_CONCURRENCY, 2 by default
+func BenchmarkTimeToFirstToken(b *testing.B) {
+	models := os.Getenv("LOCALAI_BENCHMARK_MODELS")
+	if localAIURL == "" || models == "" {
+		b.Skip("LOCALAI_API and LOCALAI_BENCHMARK_MODELS are not set")
+	}
+	concurrency := 2
+	if n, err := strconv.Atoi(os.Getenv("LOCALAI_BENCHMARK_CONCURRENCY")); err == nil && n >= 0 {
+		concurrency = n
+	}
+
+	defaultConfig := openai.DefaultConfig("")
+	defaultConfig.BaseURL = localAIURL
+	client := openai.NewClientWithConfig(defaultConfig)
+
+	// stream returns the time to the first token of a completion, and the number of tokens streamed
+	stream := func(ctx context.Context, model, prompt string, maxTokens int) (time.Duration, int, error) {
+		started := time.Now()
+		s, err := client.CreateChatCompletionStream(ctx, openai.ChatCompletionRequest{
+			Model:     model,
+			MaxTokens: maxTokens,
+			Messages:  []openai.ChatCompletionMessage{{Role: "user", Content: prompt}},
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+		defer s.Close()
+		var firstToken time.Duration
+		tokens := 0
+		for {
+			resp, err := s.Recv()
+			if errors.Is(err, io.EOF) {
+				return firstToken, tokens, nil
+			}
+			if err != nil {
+				return 0, 0, err
+			}
+			if len(resp.Choices) > 0 && resp.Choices[0].Delta.Content != "" {
+				if tokens == 0 {
+					firstToken = time.Since(started)
+				}
+				tokens++
+			}
+		}
+	}
+
+	for _, model := range strings.Split(models, ",") {
+		model = strings.TrimSpace(model)
+		b.Run(model, func(b *testing.B) {
+			ctx, cancel := context.WithCancel(context.Background())
+			var (
+				wg        sync.WaitGroup
+				generated atomic.Int64
+			)
+			for i := 0; i < concurrency; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for ctx.Err() == nil {
+						_, tokens, _ := stream(ctx, model, "Write a long story about a lighthouse keeper.", 256)
+						generated.Add(int64(tokens))
+					}
+				}()
+			}
+
+			// the prompt is long enough for its processing to compete with the generation of the other completions
+			prompt := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 50) + "How many foxes are there?"
+			var total time.Duration
+			started := time.Now()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				firstToken, _, err := stream(context.Background(), model, prompt, 16)
+				if err != nil {
+					b.Fatal(err)
+				}
+				total += firstToken
+			}
+			b.StopTimer()
+			elapsed := time.Since(started)
+			cancel()
+			wg.Wait()
+
+			b.ReportMetric(float64(total.Milliseconds())/float64(b.N), "ms/first-token")
+			if concurrency > 0 {
+				b.ReportMetric(float64(generated.Load())/elapsed.Seconds(), "background-tokens/s")
+			}
+		})
+	}
+}